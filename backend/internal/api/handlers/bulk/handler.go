@@ -0,0 +1,169 @@
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"k8s.io/client-go/dynamic"
+)
+
+var manager *Manager
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Allow all origins in development
+	},
+}
+
+// Initialize sets up the bulk job handlers with the cluster manager
+func Initialize(cm *kubernetes.ClusterManager) {
+	manager = NewManager(func(context string) (*clusterClients, error) {
+		conn, err := cm.GetConnection(context)
+		if err != nil || conn == nil {
+			return nil, fmt.Errorf("cluster %s not connected", context)
+		}
+		dynamicClient, err := dynamic.NewForConfig(conn.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+		}
+		return &clusterClients{clientset: conn.ClientSet, dynamic: dynamicClient}, nil
+	})
+}
+
+// StartJob resolves the targets and starts running the verb against them
+// POST /api/v1/bulk/jobs
+func StartJob(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	var req JobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	job, err := manager.Start(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListJobs lists running and recent jobs without their items
+// GET /api/v1/bulk/jobs
+func ListJobs(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": manager.List()})
+}
+
+// GetJob returns a job with the progress of every item
+// GET /api/v1/bulk/jobs/:id
+func GetJob(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	job, err := manager.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob stops a job midway
+// POST /api/v1/bulk/jobs/:id/cancel
+func CancelJob(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	job, err := manager.Cancel(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// JobWebSocket streams a job's progress: a snapshot, then item events, then a final
+// job event before the connection closes. Sending {"action":"cancel"} cancels the job.
+// GET /api/v1/bulk/jobs/:id/ws
+func JobWebSocket(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	id := c.Param("id")
+	snapshot, events, unsubscribe, err := manager.Subscribe(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade bulk job connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var writeMutex sync.Mutex
+	write := func(event JobEvent) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(event)
+	}
+
+	// Read cancel requests until the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var message struct {
+				Action string `json:"action"`
+			}
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			if message.Action == "cancel" {
+				if _, err := manager.Cancel(id); err != nil && !errors.Is(err, errJobNotFound) {
+					log.Printf("Failed to cancel bulk job %s: %v", id, err)
+				}
+			}
+		}
+	}()
+
+	if err := write(JobEvent{Type: "snapshot", JobID: id, Job: snapshot}); err != nil {
+		return
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				writeMutex.Lock()
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "job finished"))
+				writeMutex.Unlock()
+				return
+			}
+			if err := write(event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
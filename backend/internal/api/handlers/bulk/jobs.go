@@ -0,0 +1,279 @@
+package bulk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency = 5
+	maxConcurrency     = 20
+	maxJobItems        = 500
+	maxRetainedJobs    = 100 // finished jobs beyond this are forgotten, oldest first
+)
+
+var errJobNotFound = errors.New("job not found")
+
+// Manager runs bulk jobs and keeps their progress in memory
+type Manager struct {
+	clients clientFactory
+	now     func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*jobState
+}
+
+// jobState is a job and the subscribers following it; guarded by Manager.mu
+type jobState struct {
+	job         Job
+	request     JobRequest
+	cancel      context.CancelFunc
+	subscribers map[chan JobEvent]struct{}
+}
+
+// NewManager creates a job manager that reaches clusters through clients
+func NewManager(clients clientFactory) *Manager {
+	return &Manager{
+		clients: clients,
+		now:     time.Now,
+		jobs:    make(map[string]*jobState),
+	}
+}
+
+// Start validates the request, resolves its targets and runs the job in the background
+func (m *Manager) Start(ctx context.Context, req JobRequest) (*Job, error) {
+	if err := validateRequest(&req); err != nil {
+		return nil, err
+	}
+	targets, err := resolveTargets(ctx, m.clients, req.Targets, req.Verb)
+	if err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	state := &jobState{
+		job: Job{
+			ID:          newJobID(),
+			Verb:        req.Verb,
+			DryRun:      req.DryRun,
+			Concurrency: req.Concurrency,
+			Status:      StatusRunning,
+			CreatedAt:   m.now(),
+			Total:       len(targets),
+			Items:       make([]JobItem, len(targets)),
+		},
+		request:     req,
+		cancel:      cancel,
+		subscribers: make(map[chan JobEvent]struct{}),
+	}
+	for i, target := range targets {
+		state.job.Items[i] = JobItem{TargetRef: target, Status: StatusPending}
+	}
+
+	m.mu.Lock()
+	m.jobs[state.job.ID] = state
+	m.pruneLocked()
+	job := copyJob(&state.job, true)
+	m.mu.Unlock()
+
+	go m.run(jobCtx, state)
+	return job, nil
+}
+
+// Get returns a copy of a job
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+	return copyJob(&state.job, true), nil
+}
+
+// List returns every job without its items, newest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, state := range m.jobs {
+		jobs = append(jobs, *copyJob(&state.job, false))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel stops a running job; items already running finish or are interrupted,
+// and items not yet started are marked cancelled
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	state, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, errJobNotFound
+	}
+	state.cancel()
+	return m.Get(id)
+}
+
+// Subscribe returns a snapshot of the job and a channel of later events. The channel
+// is closed after the final job event, or immediately if the job already finished.
+func (m *Manager) Subscribe(id string) (*Job, <-chan JobEvent, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, nil, errJobNotFound
+	}
+	// Every item sends at most two events, plus the final job event, so sends never block
+	events := make(chan JobEvent, 2*len(state.job.Items)+1)
+	snapshot := copyJob(&state.job, true)
+	if state.job.FinishedAt != nil {
+		close(events)
+		return snapshot, events, func() {}, nil
+	}
+	state.subscribers[events] = struct{}{}
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := state.subscribers[events]; ok {
+			delete(state.subscribers, events)
+			close(events)
+		}
+	}
+	return snapshot, events, unsubscribe, nil
+}
+
+// run works through the items with bounded concurrency until they are done or the job is cancelled
+func (m *Manager) run(ctx context.Context, state *jobState) {
+	defer state.cancel()
+
+	slots := make(chan struct{}, state.request.Concurrency)
+	var wg sync.WaitGroup
+	for i := range state.job.Items {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			m.runItem(ctx, state, index)
+		}(i)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finished := m.now()
+	for i := range state.job.Items {
+		if item := &state.job.Items[i]; item.Status == StatusPending {
+			item.Status = StatusCancelled
+			state.job.Cancelled++
+		}
+	}
+	state.job.Status = StatusCompleted
+	if ctx.Err() != nil && state.job.Cancelled > 0 {
+		state.job.Status = StatusCancelled
+	}
+	state.job.FinishedAt = &finished
+	event := JobEvent{Type: "job", JobID: state.job.ID, Job: copyJob(&state.job, false)}
+	for subscriber := range state.subscribers {
+		subscriber <- event
+		close(subscriber)
+	}
+	state.subscribers = map[chan JobEvent]struct{}{}
+}
+
+func (m *Manager) runItem(ctx context.Context, state *jobState, index int) {
+	m.mu.Lock()
+	item := &state.job.Items[index]
+	ref := item.TargetRef
+	started := m.now()
+	item.Status = StatusRunning
+	item.StartedAt = &started
+	m.publishLocked(state, index)
+	m.mu.Unlock()
+
+	message, err := execute(ctx, m.clients, ref, &state.request, started)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finished := m.now()
+	item.FinishedAt = &finished
+	switch {
+	case err != nil && ctx.Err() != nil:
+		item.Status = StatusCancelled
+		item.Error = "cancelled while running: " + err.Error()
+		state.job.Cancelled++
+	case err != nil:
+		item.Status = StatusFailed
+		item.Error = err.Error()
+		state.job.Failed++
+	default:
+		item.Status = StatusSucceeded
+		item.Message = message
+		if state.job.DryRun {
+			item.Message = fmt.Sprintf("%s (dry run)", message)
+		}
+		state.job.Succeeded++
+	}
+	m.publishLocked(state, index)
+}
+
+func (m *Manager) publishLocked(state *jobState, index int) {
+	item := state.job.Items[index]
+	event := JobEvent{Type: "item", JobID: state.job.ID, Index: index, Item: &item}
+	for subscriber := range state.subscribers {
+		subscriber <- event
+	}
+}
+
+// pruneLocked forgets the oldest finished jobs once more than maxRetainedJobs are kept
+func (m *Manager) pruneLocked() {
+	if len(m.jobs) <= maxRetainedJobs {
+		return
+	}
+	var finished []*jobState
+	for _, state := range m.jobs {
+		if state.job.FinishedAt != nil {
+			finished = append(finished, state)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].job.FinishedAt.Before(*finished[j].job.FinishedAt)
+	})
+	for _, state := range finished {
+		if len(m.jobs) <= maxRetainedJobs {
+			return
+		}
+		delete(m.jobs, state.job.ID)
+	}
+}
+
+func copyJob(job *Job, withItems bool) *Job {
+	copied := *job
+	copied.Items = nil
+	if withItems {
+		copied.Items = append([]JobItem(nil), job.Items...)
+	}
+	return &copied
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "job-" + hex.EncodeToString(b)
+}
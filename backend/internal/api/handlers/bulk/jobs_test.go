@@ -0,0 +1,201 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var deploymentsGVR = resources["deployment"].gvr
+
+func unstructuredDeployment(namespace, name string, labels map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace, "labels": labels},
+		"spec":       map[string]interface{}{"replicas": int64(1)},
+	}}
+}
+
+// testClusters serves fake clients for the prod and staging contexts
+func testClusters() (clientFactory, map[string]*dynamicfake.FakeDynamicClient) {
+	dynamicClients := map[string]*dynamicfake.FakeDynamicClient{
+		"prod": dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+			unstructuredDeployment("shop", "api", map[string]interface{}{"tier": "web"}),
+			unstructuredDeployment("shop", "worker", map[string]interface{}{"tier": "batch"}),
+		),
+		"staging": dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+			unstructuredDeployment("shop", "api", map[string]interface{}{"tier": "web"}),
+		),
+	}
+	clientsets := map[string]*fake.Clientset{
+		"prod": fake.NewSimpleClientset(
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "shop"}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "kube-system"}},
+		),
+		"staging": fake.NewSimpleClientset(),
+	}
+	return func(context string) (*clusterClients, error) {
+		dynamicClient, ok := dynamicClients[context]
+		if !ok {
+			return nil, fmt.Errorf("cluster %s not connected", context)
+		}
+		return &clusterClients{clientset: clientsets[context], dynamic: dynamicClient}, nil
+	}, dynamicClients
+}
+
+func waitForJob(t *testing.T, manager *Manager, id string) *Job {
+	var job *Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = manager.Get(id)
+		require.NoError(t, err)
+		return job.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestBulkRestartAcrossClusters(t *testing.T) {
+	clients, dynamicClients := testClusters()
+	manager := NewManager(clients)
+	ctx := context.Background()
+
+	job, err := manager.Start(ctx, JobRequest{
+		Verb: VerbRestart,
+		Targets: TargetSet{Items: []TargetRef{
+			{Context: "prod", Kind: "Deployment", Namespace: "shop", Name: "api"},
+			{Context: "staging", Kind: "deployments", Namespace: "shop", Name: "api"},
+			{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "api"}, // duplicate
+			{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "missing"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, defaultConcurrency, job.Concurrency)
+
+	job = waitForJob(t, manager, job.ID)
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, 2, job.Succeeded)
+	assert.Equal(t, 1, job.Failed)
+	assert.Equal(t, StatusFailed, job.Items[2].Status)
+	assert.Contains(t, job.Items[2].Error, "not found")
+	assert.Equal(t, "restarted", job.Items[0].Message)
+
+	for _, context := range []string{"prod", "staging"} {
+		api, err := dynamicClients[context].Resource(deploymentsGVR).Namespace("shop").Get(ctx, "api", metav1.GetOptions{})
+		require.NoError(t, err)
+		restartedAt, found, _ := unstructured.NestedString(api.Object, "spec", "template", "metadata", "annotations", "kubectl.kubernetes.io/restartedAt")
+		assert.True(t, found, context)
+		assert.NotEmpty(t, restartedAt)
+	}
+	assert.Len(t, manager.List(), 1)
+	assert.Nil(t, manager.List()[0].Items, "listed jobs omit their items")
+}
+
+func TestBulkTargets(t *testing.T) {
+	clients, _ := testClusters()
+	ctx := context.Background()
+
+	refs, err := resolveTargets(ctx, clients, TargetSet{Selector: &SelectorTargets{
+		Contexts: []string{"prod", "staging"}, Kind: "Deployment", LabelSelector: "tier=web",
+	}}, VerbScale)
+	require.NoError(t, err)
+	assert.Equal(t, []TargetRef{
+		{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "api"},
+		{Context: "staging", Kind: "deployment", Namespace: "shop", Name: "api"},
+	}, refs)
+
+	refs, err = resolveTargets(ctx, clients, TargetSet{Query: &QueryTargets{
+		Contexts: []string{"prod"}, Query: "SELECT name FROM deployments WHERE namespace = 'shop'",
+	}}, VerbRestart)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []TargetRef{
+		{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "api"},
+		{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "worker"},
+	}, refs)
+
+	_, err = resolveTargets(ctx, clients, TargetSet{}, VerbRestart)
+	assert.ErrorContains(t, err, "exactly one")
+	_, err = resolveTargets(ctx, clients, TargetSet{Items: []TargetRef{{Context: "prod", Kind: "configmap", Namespace: "shop", Name: "a"}}}, VerbRestart)
+	assert.ErrorContains(t, err, "restart is not supported for configmap")
+	_, err = resolveTargets(ctx, clients, TargetSet{Selector: &SelectorTargets{Contexts: []string{"prod"}, Kind: "deployment"}}, VerbDelete)
+	assert.ErrorContains(t, err, "labelSelector is required")
+	_, err = resolveTargets(ctx, clients, TargetSet{Items: []TargetRef{{Context: "prod", Kind: "node", Namespace: "ignored", Name: "n1"}}}, VerbCordon)
+	assert.NoError(t, err)
+
+	assert.Error(t, validateRequest(&JobRequest{Verb: VerbScale}))
+	assert.Error(t, validateRequest(&JobRequest{Verb: VerbLabel, Set: map[string]string{"bad key": "x"}}))
+	assert.Error(t, validateRequest(&JobRequest{Verb: VerbRestart, Concurrency: 50}))
+	assert.Error(t, validateRequest(&JobRequest{Verb: "drain"}))
+}
+
+func TestBulkCancelAndProgress(t *testing.T) {
+	clients, dynamicClients := testClusters()
+	release := make(chan struct{})
+	dynamicClients["prod"].PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+	manager := NewManager(clients)
+	replicas := int32(3)
+
+	job, err := manager.Start(context.Background(), JobRequest{
+		Verb:        VerbScale,
+		Replicas:    &replicas,
+		Concurrency: 1,
+		Targets: TargetSet{Items: []TargetRef{
+			{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "api"},
+			{Context: "prod", Kind: "deployment", Namespace: "shop", Name: "worker"},
+		}},
+	})
+	require.NoError(t, err)
+	snapshot, events, unsubscribe, err := manager.Subscribe(job.ID)
+	require.NoError(t, err)
+	defer unsubscribe()
+	assert.Len(t, snapshot.Items, 2)
+
+	// The first item is blocked in its patch; cancel before the second starts
+	require.Eventually(t, func() bool {
+		job, _ := manager.Get(job.ID)
+		return job.Items[0].Status == StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = manager.Cancel(job.ID)
+	require.NoError(t, err)
+	close(release)
+
+	var received []JobEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	require.NotEmpty(t, received)
+	final := received[len(received)-1]
+	assert.Equal(t, "job", final.Type)
+	assert.Equal(t, StatusCancelled, final.Job.Status)
+	assert.Equal(t, 1, final.Job.Succeeded)
+	assert.Equal(t, 1, final.Job.Cancelled)
+
+	job = waitForJob(t, manager, job.ID)
+	assert.Equal(t, "scaled to 3", job.Items[0].Message)
+	assert.Equal(t, StatusCancelled, job.Items[1].Status)
+
+	// Subscribing to a finished job yields just the snapshot
+	_, events, _, err = manager.Subscribe(job.ID)
+	require.NoError(t, err)
+	_, open := <-events
+	assert.False(t, open)
+
+	_, err = manager.Cancel("job-unknown")
+	assert.ErrorIs(t, err, errJobNotFound)
+}
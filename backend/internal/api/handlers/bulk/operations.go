@@ -0,0 +1,334 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/sqlquery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterClients are the clients a job uses in one cluster
+type clusterClients struct {
+	clientset kubernetes.Interface // runs SQL query targets
+	dynamic   dynamic.Interface
+}
+
+// clientFactory returns the clients for a cluster context
+type clientFactory func(context string) (*clusterClients, error)
+
+// resourceInfo describes a kind bulk jobs can act on
+type resourceInfo struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+	verbs      []string // in addition to delete, label and annotate
+}
+
+// resources are keyed by lowercase kind; the SQL query resource types map onto them
+var resources = map[string]resourceInfo{
+	"pod":         {gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, namespaced: true},
+	"service":     {gvr: schema.GroupVersionResource{Version: "v1", Resource: "services"}, namespaced: true},
+	"configmap":   {gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, namespaced: true},
+	"secret":      {gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, namespaced: true},
+	"namespace":   {gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}},
+	"node":        {gvr: schema.GroupVersionResource{Version: "v1", Resource: "nodes"}, verbs: []string{VerbCordon, VerbUncordon}},
+	"deployment":  {gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, namespaced: true, verbs: []string{VerbRestart, VerbScale}},
+	"statefulset": {gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, namespaced: true, verbs: []string{VerbRestart, VerbScale}},
+	"daemonset":   {gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, namespaced: true, verbs: []string{VerbRestart}},
+	"replicaset":  {gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}, namespaced: true, verbs: []string{VerbScale}},
+	"job":         {gvr: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, namespaced: true},
+	"cronjob":     {gvr: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, namespaced: true},
+}
+
+var verbs = map[string]bool{
+	VerbRestart: true, VerbScale: true, VerbDelete: true, VerbLabel: true,
+	VerbAnnotate: true, VerbCordon: true, VerbUncordon: true,
+}
+
+// lookupResource accepts a kind in any case, singular or plural
+func lookupResource(kind string) (string, resourceInfo, error) {
+	key := strings.ToLower(kind)
+	if info, ok := resources[key]; ok {
+		return key, info, nil
+	}
+	for key, info := range resources {
+		if info.gvr.Resource == strings.ToLower(kind) {
+			return key, info, nil
+		}
+	}
+	return "", resourceInfo{}, fmt.Errorf("unsupported kind %q", kind)
+}
+
+func (r resourceInfo) supports(verb string) bool {
+	switch verb {
+	case VerbDelete, VerbLabel, VerbAnnotate:
+		return true
+	}
+	for _, supported := range r.verbs {
+		if supported == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRequest checks the verb and its arguments and fills in defaults
+func validateRequest(req *JobRequest) error {
+	if !verbs[req.Verb] {
+		return fmt.Errorf("unsupported verb %q: use restart, scale, delete, label, annotate, cordon or uncordon", req.Verb)
+	}
+	switch req.Verb {
+	case VerbScale:
+		if req.Replicas == nil || *req.Replicas < 0 {
+			return fmt.Errorf("scale requires replicas of 0 or more")
+		}
+	case VerbLabel, VerbAnnotate:
+		if len(req.Set) == 0 && len(req.Remove) == 0 {
+			return fmt.Errorf("%s requires set or remove", req.Verb)
+		}
+		for key, value := range req.Set {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return fmt.Errorf("invalid key %q: %s", key, strings.Join(errs, "; "))
+			}
+			if req.Verb == VerbLabel {
+				if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+					return fmt.Errorf("invalid value for %q: %s", key, strings.Join(errs, "; "))
+				}
+			}
+		}
+	}
+
+	if req.Concurrency == 0 {
+		req.Concurrency = defaultConcurrency
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", maxConcurrency)
+	}
+	return nil
+}
+
+// resolveTargets expands a target set into the resources a job will act on
+func resolveTargets(ctx context.Context, clients clientFactory, targets TargetSet, verb string) ([]TargetRef, error) {
+	set := 0
+	for _, given := range []bool{len(targets.Items) > 0, targets.Selector != nil, targets.Query != nil} {
+		if given {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("targets must have exactly one of items, selector or query")
+	}
+
+	var refs []TargetRef
+	var err error
+	switch {
+	case len(targets.Items) > 0:
+		refs = targets.Items
+	case targets.Selector != nil:
+		refs, err = selectorTargets(ctx, clients, targets.Selector)
+	default:
+		refs, err = queryTargets(ctx, clients, targets.Query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[TargetRef]bool, len(refs))
+	resolved := make([]TargetRef, 0, len(refs))
+	for _, ref := range refs {
+		key, info, err := lookupResource(ref.Kind)
+		if err != nil {
+			return nil, err
+		}
+		if !info.supports(verb) {
+			return nil, fmt.Errorf("%s is not supported for %s", verb, key)
+		}
+		if ref.Context == "" || ref.Name == "" {
+			return nil, fmt.Errorf("every target needs a context and a name")
+		}
+		ref.Kind = key
+		if !info.namespaced {
+			ref.Namespace = ""
+		} else if ref.Namespace == "" {
+			return nil, fmt.Errorf("%s %s needs a namespace", key, ref.Name)
+		}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		resolved = append(resolved, ref)
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no resources match the targets")
+	}
+	if len(resolved) > maxJobItems {
+		return nil, fmt.Errorf("targets match %d resources; a job can act on at most %d", len(resolved), maxJobItems)
+	}
+	return resolved, nil
+}
+
+// selectorTargets lists the resources matching a label selector in each cluster
+func selectorTargets(ctx context.Context, clients clientFactory, selector *SelectorTargets) ([]TargetRef, error) {
+	key, info, err := lookupResource(selector.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(selector.LabelSelector) == "" {
+		return nil, fmt.Errorf("labelSelector is required; list targets explicitly to act on unlabelled resources")
+	}
+	if _, err := labels.Parse(selector.LabelSelector); err != nil {
+		return nil, fmt.Errorf("invalid labelSelector: %w", err)
+	}
+	if len(selector.Contexts) == 0 {
+		return nil, fmt.Errorf("selector needs at least one context")
+	}
+
+	namespaces := selector.Namespaces
+	if len(namespaces) == 0 || !info.namespaced {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	var refs []TargetRef
+	for _, clusterContext := range selector.Contexts {
+		cluster, err := clients(clusterContext)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", clusterContext, err)
+		}
+		for _, namespace := range namespaces {
+			list, err := cluster.dynamic.Resource(info.gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.LabelSelector})
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s: failed to list %s: %w", clusterContext, info.gvr.Resource, err)
+			}
+			for _, item := range list.Items {
+				refs = append(refs, TargetRef{Context: clusterContext, Kind: key, Namespace: item.GetNamespace(), Name: item.GetName()})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// queryTargets runs an SQL query in each cluster; every row is a target
+func queryTargets(ctx context.Context, clients clientFactory, query *QueryTargets) ([]TargetRef, error) {
+	parsed, err := sqlquery.ParseValidatedQuery(query.Query)
+	if err != nil {
+		return nil, err
+	}
+	if len(query.Contexts) == 0 {
+		return nil, fmt.Errorf("query needs at least one context")
+	}
+	// Only the identity of each row is needed, whatever the query selects
+	parsed.Fields = []string{"metadata.name", "metadata.namespace"}
+
+	var refs []TargetRef
+	for _, clusterContext := range query.Contexts {
+		cluster, err := clients(clusterContext)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", clusterContext, err)
+		}
+		result, err := sqlquery.NewQueryExecutor(cluster.clientset).Execute(ctx, parsed)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", clusterContext, err)
+		}
+		for _, row := range result.Data {
+			name, _ := row["metadata.name"].(string)
+			namespace, _ := row["metadata.namespace"].(string)
+			refs = append(refs, TargetRef{Context: clusterContext, Kind: parsed.ResourceType, Namespace: namespace, Name: name})
+		}
+	}
+	return refs, nil
+}
+
+// execute runs the job's verb against one target and describes what it did
+func execute(ctx context.Context, clients clientFactory, ref TargetRef, req *JobRequest, now time.Time) (string, error) {
+	_, info, err := lookupResource(ref.Kind)
+	if err != nil {
+		return "", err
+	}
+	cluster, err := clients(ref.Context)
+	if err != nil {
+		return "", err
+	}
+	var resource dynamic.ResourceInterface = cluster.dynamic.Resource(info.gvr)
+	if info.namespaced {
+		resource = cluster.dynamic.Resource(info.gvr).Namespace(ref.Namespace)
+	}
+
+	var dryRun []string
+	if req.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	var patch map[string]interface{}
+	var message string
+	switch req.Verb {
+	case VerbDelete:
+		propagation := metav1.DeletePropagationBackground
+		if err := resource.Delete(ctx, ref.Name, metav1.DeleteOptions{PropagationPolicy: &propagation, DryRun: dryRun}); err != nil {
+			return "", err
+		}
+		return "deleted", nil
+	case VerbRestart:
+		// The same annotation kubectl rollout restart sets
+		patch = map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/restartedAt": now.Format(time.RFC3339),
+			}},
+		}}}
+		message = "restarted"
+	case VerbScale:
+		patch = map[string]interface{}{"spec": map[string]interface{}{"replicas": *req.Replicas}}
+		message = fmt.Sprintf("scaled to %d", *req.Replicas)
+	case VerbCordon, VerbUncordon:
+		patch = map[string]interface{}{"spec": map[string]interface{}{"unschedulable": req.Verb == VerbCordon}}
+		message = req.Verb + "ed"
+	case VerbLabel, VerbAnnotate:
+		field := "labels"
+		if req.Verb == VerbAnnotate {
+			field = "annotations"
+		}
+		values := map[string]interface{}{}
+		for key, value := range req.Set {
+			values[key] = value
+		}
+		for _, key := range req.Remove {
+			values[key] = nil
+		}
+		patch = map[string]interface{}{"metadata": map[string]interface{}{field: values}}
+		message = describeMetadataChange(field, req)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	if _, err := resource.Patch(ctx, ref.Name, types.MergePatchType, data, metav1.PatchOptions{DryRun: dryRun, FieldManager: manifests.FieldManager}); err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+func describeMetadataChange(field string, req *JobRequest) string {
+	var parts []string
+	if len(req.Set) > 0 {
+		keys := make([]string, 0, len(req.Set))
+		for key := range req.Set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts = append(parts, "set "+strings.Join(keys, ", "))
+	}
+	if len(req.Remove) > 0 {
+		parts = append(parts, "removed "+strings.Join(req.Remove, ", "))
+	}
+	return fmt.Sprintf("%s: %s", field, strings.Join(parts, "; "))
+}
@@ -0,0 +1,102 @@
+package bulk
+
+import (
+	"time"
+)
+
+// Verbs a bulk job can run
+const (
+	VerbRestart  = "restart"
+	VerbScale    = "scale"
+	VerbDelete   = "delete"
+	VerbLabel    = "label"
+	VerbAnnotate = "annotate"
+	VerbCordon   = "cordon"
+	VerbUncordon = "uncordon"
+)
+
+// Job and item states
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusCompleted = "completed" // a job whose items all ran, whether or not they succeeded
+)
+
+// TargetRef is one resource in one cluster
+type TargetRef struct {
+	Context   string `json:"context"`
+	Kind      string `json:"kind"` // deployment, statefulset, node, ...
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// SelectorTargets selects resources of one kind by label across clusters and namespaces
+type SelectorTargets struct {
+	Contexts      []string `json:"contexts"`
+	Kind          string   `json:"kind"`
+	Namespaces    []string `json:"namespaces,omitempty"` // empty means all namespaces
+	LabelSelector string   `json:"labelSelector"`
+}
+
+// QueryTargets selects the rows of an SQL query, run in each cluster
+type QueryTargets struct {
+	Contexts []string `json:"contexts"`
+	Query    string   `json:"query"` // e.g. SELECT name FROM deployments WHERE namespace = 'shop'
+}
+
+// TargetSet says which resources a job acts on; exactly one field is set
+type TargetSet struct {
+	Items    []TargetRef      `json:"items,omitempty"`
+	Selector *SelectorTargets `json:"selector,omitempty"`
+	Query    *QueryTargets    `json:"query,omitempty"`
+}
+
+// JobRequest starts a bulk job
+type JobRequest struct {
+	Targets     TargetSet         `json:"targets"`
+	Verb        string            `json:"verb"`
+	Replicas    *int32            `json:"replicas,omitempty"` // scale
+	Set         map[string]string `json:"set,omitempty"`      // label, annotate
+	Remove      []string          `json:"remove,omitempty"`   // label, annotate
+	DryRun      bool              `json:"dryRun,omitempty"`
+	Concurrency int               `json:"concurrency,omitempty"` // default 5, at most 20
+}
+
+// JobItem is the progress of one target
+type JobItem struct {
+	TargetRef
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Job is a bulk operation and the progress of each of its items
+type Job struct {
+	ID          string     `json:"id"`
+	Verb        string     `json:"verb"`
+	DryRun      bool       `json:"dryRun"`
+	Concurrency int        `json:"concurrency"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Total       int        `json:"total"`
+	Succeeded   int        `json:"succeeded"`
+	Failed      int        `json:"failed"`
+	Cancelled   int        `json:"cancelled"`
+	Items       []JobItem  `json:"items,omitempty"`
+}
+
+// JobEvent is streamed to WebSocket subscribers: a snapshot when they connect,
+// an item event whenever an item starts or finishes, and a job event at the end
+type JobEvent struct {
+	Type  string   `json:"type"` // snapshot, item, job
+	JobID string   `json:"jobId"`
+	Index int      `json:"index,omitempty"`
+	Item  *JobItem `json:"item,omitempty"`
+	Job   *Job     `json:"job,omitempty"`
+}
@@ -0,0 +1,614 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const maxSummaryPaths = 3
+
+var (
+	errInvalidRequest = errors.New("invalid comparison request")
+	errNotFound       = errors.New("resource not found on either side")
+)
+
+// skippedResources churn with cluster activity or mirror other objects, so comparing
+// them between clusters only reports noise
+var skippedResources = map[schema.GroupResource]bool{
+	{Resource: "events"}:                                    true,
+	{Resource: "endpoints"}:                                 true,
+	{Group: "events.k8s.io", Resource: "events"}:            true,
+	{Group: "discovery.k8s.io", Resource: "endpointslices"}: true,
+	{Group: "coordination.k8s.io", Resource: "leases"}:      true,
+	{Group: "apps", Resource: "controllerrevisions"}:        true,
+}
+
+// podTemplatePaths locate the pod spec of the workloads whose images are pinned to the
+// digests their pods run when comparing by digest
+var podTemplatePaths = map[schema.GroupKind][]string{
+	{Group: "apps", Kind: "Deployment"}:  {"spec", "template", "spec"},
+	{Group: "apps", Kind: "StatefulSet"}: {"spec", "template", "spec"},
+	{Group: "apps", Kind: "DaemonSet"}:   {"spec", "template", "spec"},
+	{Group: "apps", Kind: "ReplicaSet"}:  {"spec", "template", "spec"},
+	{Kind: "ReplicationController"}:      {"spec", "template", "spec"},
+	{Group: "batch", Kind: "Job"}:        {"spec", "template", "spec"},
+}
+
+// cronJobKind runs its pods through Jobs, so its images cannot be resolved to digests
+var cronJobKind = schema.GroupKind{Group: "batch", Kind: "CronJob"}
+
+// containerFields pairs the container lists of a pod spec with their statuses
+var containerFields = [][2]string{{"containers", "containerStatuses"}, {"initContainers", "initContainerStatuses"}}
+
+// objectPair is the same object read from both sides; a nil side does not have it
+type objectPair struct {
+	apiVersion string
+	kind       string
+	name       string
+	a, b       *unstructured.Unstructured
+	err        error
+}
+
+// compareResources reads the requested objects from both sides, normalizes them and diffs them
+func compareResources(ctx context.Context, req CompareRequest, clients manifests.ClientFactory) (*CompareReport, error) {
+	if err := validate(&req); err != nil {
+		return nil, err
+	}
+	a, err := clients(req.ClusterA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := clients(req.ClusterB)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompareReport{
+		ClusterA:    req.ClusterA,
+		NamespaceA:  req.NamespaceA,
+		ClusterB:    req.ClusterB,
+		NamespaceB:  req.NamespaceB,
+		Images:      req.Images,
+		Resources:   []ResourceComparison{},
+		GeneratedAt: time.Now(),
+	}
+	var pairs []objectPair
+	if req.Name != "" {
+		pair, err := fetchObject(ctx, req, a, b)
+		if err != nil {
+			return nil, err
+		}
+		if req.Images == ImagesByDigest && schema.FromAPIVersionAndKind(pair.apiVersion, pair.kind).GroupKind() == cronJobKind {
+			return nil, fmt.Errorf("%w: images=digest cannot resolve the digests of a CronJob, whose pods belong to its Jobs; compare its Jobs instead", errInvalidRequest)
+		}
+		pairs = []objectPair{*pair}
+	} else {
+		if pairs, report.Warnings, err = fetchNamespaces(ctx, req, a, b); err != nil {
+			return nil, err
+		}
+	}
+	if req.Images == ImagesByDigest {
+		report.Warnings = append(report.Warnings, pinWorkloadDigests(ctx, req, a, b, pairs)...)
+	}
+	for _, pair := range pairs {
+		report.add(comparePair(pair, req))
+	}
+	return report, nil
+}
+
+func validate(req *CompareRequest) error {
+	if req.NamespaceB == "" {
+		req.NamespaceB = req.NamespaceA
+	}
+	switch req.Images {
+	case "":
+		req.Images = ImagesByReference
+	case ImagesByReference, ImagesByDigest:
+	default:
+		return fmt.Errorf("%w: images must be reference or digest", errInvalidRequest)
+	}
+	switch {
+	case req.Name != "" && req.Kind == "":
+		return fmt.Errorf("%w: kind is required with name", errInvalidRequest)
+	case req.Name == "" && req.Kind != "":
+		return fmt.Errorf("%w: name is required with kind; use kinds to compare every object of a kind", errInvalidRequest)
+	case req.Name == "" && req.NamespaceA == "":
+		return fmt.Errorf("%w: namespaceA is required to compare namespaces", errInvalidRequest)
+	case req.ClusterA == req.ClusterB && req.NamespaceA == req.NamespaceB:
+		return fmt.Errorf("%w: both sides are the same", errInvalidRequest)
+	}
+	return nil
+}
+
+// fetchObject reads one object from both sides; a side that does not serve the kind lacks the object
+func fetchObject(ctx context.Context, req CompareRequest, a, b *manifests.ClusterClients) (*objectPair, error) {
+	typeA, errA := resolveKind(a.Discovery, req.Kind, req.APIVersion)
+	typeB, errB := resolveKind(b.Discovery, req.Kind, req.APIVersion)
+	if errA != nil && errB != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRequest, errA)
+	}
+	if (errA == nil && typeA.Namespaced || errB == nil && typeB.Namespaced) && req.NamespaceA == "" {
+		return nil, fmt.Errorf("%w: namespaceA is required for namespaced resources", errInvalidRequest)
+	}
+
+	pair := &objectPair{name: req.Name}
+	get := func(clients *manifests.ClusterClients, resource manifests.ResourceType, namespace string) (*unstructured.Unstructured, error) {
+		if !resource.Namespaced {
+			namespace = ""
+		}
+		obj, err := clients.Dynamic.Resource(resource.GVR).Namespace(namespace).Get(ctx, req.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return obj, err
+	}
+	if errA == nil {
+		pair.apiVersion, pair.kind = typeA.GVR.GroupVersion().String(), typeA.Kind
+		if pair.a, pair.err = get(a, typeA, req.NamespaceA); pair.err != nil {
+			pair.err = fmt.Errorf("%s: %v", req.ClusterA, pair.err)
+			return pair, nil
+		}
+	}
+	if errB == nil {
+		if pair.kind == "" {
+			pair.apiVersion, pair.kind = typeB.GVR.GroupVersion().String(), typeB.Kind
+		}
+		if pair.b, pair.err = get(b, typeB, req.NamespaceB); pair.err != nil {
+			pair.err = fmt.Errorf("%s: %v", req.ClusterB, pair.err)
+			return pair, nil
+		}
+	}
+	if pair.a == nil && pair.b == nil {
+		return nil, fmt.Errorf("%w: %s %s", errNotFound, pair.kind, req.Name)
+	}
+	return pair, nil
+}
+
+// resolveKind finds the resource serving kind, in apiVersion when given, otherwise in
+// whichever group serves it at its preferred version
+func resolveKind(client discovery.DiscoveryInterface, kind, apiVersion string) (manifests.ResourceType, error) {
+	var lists []*metav1.APIResourceList
+	if apiVersion != "" {
+		list, err := client.ServerResourcesForGroupVersion(apiVersion)
+		if err != nil {
+			return manifests.ResourceType{}, fmt.Errorf("failed to discover resources for %s: %v", apiVersion, err)
+		}
+		lists = []*metav1.APIResourceList{list}
+	} else {
+		var err error
+		if lists, err = client.ServerPreferredResources(); err != nil && len(lists) == 0 {
+			return manifests.ResourceType{}, fmt.Errorf("failed to discover resources: %v", err)
+		}
+	}
+	for _, resource := range manifests.ServedTypes(lists, "") {
+		if strings.EqualFold(resource.Kind, kind) {
+			return resource, nil
+		}
+	}
+	if apiVersion != "" {
+		return manifests.ResourceType{}, fmt.Errorf("kind %s is not served in %s", kind, apiVersion)
+	}
+	return manifests.ResourceType{}, fmt.Errorf("kind %s is not served", kind)
+}
+
+// fetchNamespaces lists every namespaced object on both sides and pairs them by group, kind and name
+func fetchNamespaces(ctx context.Context, req CompareRequest, a, b *manifests.ClusterClients) ([]objectPair, []string, error) {
+	typesA, errA := namespacedTypes(a.Discovery, req.Kinds)
+	typesB, errB := namespacedTypes(b.Discovery, req.Kinds)
+	if errA != nil {
+		return nil, nil, fmt.Errorf("%s: %w", req.ClusterA, errA)
+	}
+	if errB != nil {
+		return nil, nil, fmt.Errorf("%s: %w", req.ClusterB, errB)
+	}
+	if len(req.Kinds) > 0 {
+		for _, kind := range req.Kinds {
+			if !servesKind(typesA, kind) && !servesKind(typesB, kind) {
+				return nil, nil, fmt.Errorf("%w: kind %s is not served by either cluster", errInvalidRequest, kind)
+			}
+		}
+	}
+
+	var (
+		wg                   sync.WaitGroup
+		objectsA, objectsB   map[string]*unstructured.Unstructured
+		warningsA, warningsB []string
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		objectsA, warningsA = listObjects(ctx, a.Dynamic, typesA, req.NamespaceA, req.ClusterA)
+	}()
+	go func() {
+		defer wg.Done()
+		objectsB, warningsB = listObjects(ctx, b.Dynamic, typesB, req.NamespaceB, req.ClusterB)
+	}()
+	wg.Wait()
+
+	keys := make([]string, 0, len(objectsA)+len(objectsB))
+	for key := range objectsA {
+		keys = append(keys, key)
+	}
+	for key := range objectsB {
+		if _, ok := objectsA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]objectPair, 0, len(keys))
+	for _, key := range keys {
+		pair := objectPair{a: objectsA[key], b: objectsB[key]}
+		either := pair.a
+		if either == nil {
+			either = pair.b
+		}
+		pair.apiVersion, pair.kind, pair.name = either.GetAPIVersion(), either.GetKind(), either.GetName()
+		pairs = append(pairs, pair)
+	}
+	return pairs, append(warningsA, warningsB...), nil
+}
+
+// namespacedTypes are the listable namespaced types worth comparing, narrowed to kinds
+func namespacedTypes(client discovery.DiscoveryInterface, kinds []string) ([]manifests.ResourceType, error) {
+	resourceTypes, err := manifests.DiscoverResourceTypes(client)
+	if err != nil {
+		return nil, err
+	}
+	var types []manifests.ResourceType
+	for _, resource := range resourceTypes {
+		gr := resource.GVR.GroupResource()
+		if !resource.Namespaced || skippedResources[gr] || gr.Group == "metrics.k8s.io" {
+			continue
+		}
+		if len(kinds) > 0 && !matchesKind(resource, kinds...) {
+			continue
+		}
+		types = append(types, resource)
+	}
+	return types, nil
+}
+
+func servesKind(types []manifests.ResourceType, kind string) bool {
+	for _, resource := range types {
+		if resource.Matches(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesKind(resource manifests.ResourceType, kinds ...string) bool {
+	for _, kind := range kinds {
+		if resource.Matches(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// listObjects lists every type in a namespace, keyed by group, kind and name. Objects
+// that controllers or the control plane create are left out: their names differ
+// between clusters and their owners are compared instead.
+func listObjects(ctx context.Context, client dynamic.Interface, types []manifests.ResourceType, namespace, cluster string) (map[string]*unstructured.Unstructured, []string) {
+	items, warnings := manifests.ListObjects(ctx, manifests.DynamicLister(client, metav1.ListOptions{}), types, namespace)
+	objects := make(map[string]*unstructured.Unstructured, len(items))
+	for i := range items {
+		obj := &items[i]
+		if manifests.IsGenerated(obj) {
+			continue
+		}
+		objects[objectKey(obj)] = obj
+	}
+	for i, warning := range warnings {
+		warnings[i] = cluster + ": " + warning
+	}
+	return objects, warnings
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	return gvk.Kind + "." + gvk.Group + "/" + obj.GetName()
+}
+
+// comparePair normalizes both sides and diffs them from A to B
+func comparePair(pair objectPair, req CompareRequest) ResourceComparison {
+	result := ResourceComparison{APIVersion: pair.apiVersion, Kind: pair.kind, Name: pair.name}
+	switch {
+	case pair.err != nil:
+		result.Status, result.Error = StatusError, pair.err.Error()
+		return result
+	case pair.b == nil:
+		result.Status = StatusOnlyInA
+		return result
+	case pair.a == nil:
+		result.Status = StatusOnlyInB
+		return result
+	}
+
+	a, b := normalize(pair.a, req.Images), normalize(pair.b, req.Images)
+	diffs := manifests.DiffObjects(a.Object, b.Object, manifests.DiffOptions{})
+	result.Diffs = filterDiffs(manifests.MaskSecretDiffs(a, diffs), req)
+	result.Status = StatusIdentical
+	if len(result.Diffs) > 0 {
+		result.Status = StatusDifferent
+		result.Drift, result.Summary = summarize(result.Diffs)
+	}
+	return result
+}
+
+// normalize strips what the server adds and the namespace, which is expected to differ
+func normalize(obj *unstructured.Unstructured, images string) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	if images == ImagesByDigest {
+		pinPodDigests(obj)
+	}
+	manifests.NormalizeManifest(obj)
+	unstructured.RemoveNestedField(obj.Object, "metadata", "namespace")
+	return obj
+}
+
+// pinPodDigests appends the digest a Pod's containers are running to images that are
+// referenced by tag, before the status it comes from is stripped
+func pinPodDigests(obj *unstructured.Unstructured) {
+	if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Kind: "Pod"}) {
+		return
+	}
+	pinDigests(obj, runningDigests([]*unstructured.Unstructured{obj}), "spec")
+}
+
+// pinWorkloadDigests pins the images of each workload to the digests its pods run, read
+// from the containerStatuses of the pods its selector matches on that side. Pods are listed
+// once per side. CronJobs keep their references and are reported in the warnings.
+func pinWorkloadDigests(ctx context.Context, req CompareRequest, a, b *manifests.ClusterClients, pairs []objectPair) []string {
+	type side struct {
+		clients   *manifests.ClusterClients
+		cluster   string
+		namespace string
+		pods      []*unstructured.Unstructured
+		listed    bool
+	}
+	sides := []*side{
+		{clients: a, cluster: req.ClusterA, namespace: req.NamespaceA},
+		{clients: b, cluster: req.ClusterB, namespace: req.NamespaceB},
+	}
+
+	var (
+		warnings []string
+		cronJobs []string
+	)
+	for i := range pairs {
+		pair := &pairs[i]
+		for j, obj := range []*unstructured.Unstructured{pair.a, pair.b} {
+			if obj == nil {
+				continue
+			}
+			gk := obj.GroupVersionKind().GroupKind()
+			if gk == cronJobKind {
+				if j == 0 || pair.a == nil {
+					cronJobs = append(cronJobs, obj.GetName())
+				}
+				continue
+			}
+			path, ok := podTemplatePaths[gk]
+			if !ok {
+				continue
+			}
+			s := sides[j]
+			if !s.listed {
+				s.listed = true
+				list, err := s.clients.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace(s.namespace).List(ctx, metav1.ListOptions{})
+				if err != nil {
+					warnings = append(warnings, fmt.Sprintf("%s: failed to list pods, images are compared by reference: %v", s.cluster, err))
+				} else {
+					for k := range list.Items {
+						s.pods = append(s.pods, &list.Items[k])
+					}
+				}
+			}
+			selector, err := workloadSelector(obj)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %s %s has an invalid selector: %v", s.cluster, obj.GetKind(), obj.GetName(), err))
+				continue
+			}
+			var selected []*unstructured.Unstructured
+			for _, pod := range s.pods {
+				if selector.Matches(labels.Set(pod.GetLabels())) {
+					selected = append(selected, pod)
+				}
+			}
+			pinDigests(obj, runningDigests(selected), path...)
+		}
+	}
+	if len(cronJobs) > 0 {
+		warnings = append(warnings, fmt.Sprintf("images of CronJobs %s are compared by reference: their pods belong to Jobs", strings.Join(cronJobs, ", ")))
+	}
+	return warnings
+}
+
+// workloadSelector returns the pod selector of a workload; one without a selector selects nothing
+func workloadSelector(obj *unstructured.Unstructured) (labels.Selector, error) {
+	if obj.GetKind() == "ReplicationController" {
+		selector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+		if len(selector) == 0 {
+			return labels.Nothing(), nil
+		}
+		return labels.SelectorFromSet(selector), nil
+	}
+	raw, found, _ := unstructured.NestedMap(obj.Object, "spec", "selector")
+	if !found {
+		return labels.Nothing(), nil
+	}
+	var selector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &selector); err != nil {
+		return nil, err
+	}
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+		return labels.Nothing(), nil
+	}
+	return metav1.LabelSelectorAsSelector(&selector)
+}
+
+// runningDigests collects the digests the containers of the pods run, by container list
+// and container name. Pods that run different digests, e.g. mid-rollout, yield them all, sorted.
+func runningDigests(pods []*unstructured.Unstructured) map[string]map[string][]string {
+	digests := make(map[string]map[string][]string)
+	for _, field := range containerFields {
+		byName := make(map[string][]string)
+		for _, pod := range pods {
+			statuses, _, _ := unstructured.NestedSlice(pod.Object, "status", field[1])
+			for _, status := range statuses {
+				s, ok := status.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := s["name"].(string)
+				imageID, _ := s["imageID"].(string)
+				if digest := imageDigest(imageID); digest != "" && !containsString(byName[name], digest) {
+					byName[name] = append(byName[name], digest)
+				}
+			}
+		}
+		for _, list := range byName {
+			sort.Strings(list)
+		}
+		digests[field[0]] = byName
+	}
+	return digests
+}
+
+// pinDigests appends the running digests to the images of the pod spec at path that are
+// referenced by tag
+func pinDigests(obj *unstructured.Unstructured, digests map[string]map[string][]string, path ...string) {
+	for _, field := range containerFields {
+		fieldPath := append(append([]string{}, path...), field[0])
+		containers, _, _ := unstructured.NestedSlice(obj.Object, fieldPath...)
+		for _, container := range containers {
+			c, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := c["name"].(string)
+			image, _ := c["image"].(string)
+			if running := digests[field[0]][name]; len(running) > 0 && imageDigest(image) == "" {
+				c["image"] = image + "@" + strings.Join(running, ",")
+			}
+		}
+		if containers != nil {
+			_ = unstructured.SetNestedSlice(obj.Object, containers, fieldPath...)
+		}
+	}
+}
+
+func containsString(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// imageDigest returns the digest of an image reference or image ID, e.g. sha256:ab12...
+func imageDigest(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	if strings.HasPrefix(image, "sha256:") {
+		return image
+	}
+	return ""
+}
+
+// filterDiffs drops ignored paths and, when comparing by digest, images whose digests match
+func filterDiffs(diffs []manifests.FieldDiff, req CompareRequest) []manifests.FieldDiff {
+	var kept []manifests.FieldDiff
+	for _, diff := range diffs {
+		if ignored(diff.Path, req.IgnorePaths) {
+			continue
+		}
+		if req.Images == ImagesByDigest && diff.Type == manifests.FieldChanged && strings.HasSuffix(diff.Path, ".image") {
+			from, _ := diff.From.(string)
+			to, _ := diff.To.(string)
+			if digest := imageDigest(from); digest != "" && digest == imageDigest(to) {
+				continue
+			}
+		}
+		kept = append(kept, diff)
+	}
+	return kept
+}
+
+func ignored(path string, ignorePaths []string) bool {
+	for _, prefix := range ignorePaths {
+		if path == prefix || strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// summarize counts the diffs by type and names the first few fields
+func summarize(diffs []manifests.FieldDiff) (DriftCounts, string) {
+	var counts DriftCounts
+	paths := make([]string, 0, maxSummaryPaths)
+	for _, diff := range diffs {
+		switch diff.Type {
+		case manifests.FieldChanged:
+			counts.Changed++
+		case manifests.FieldAdded:
+			counts.Added++
+		case manifests.FieldRemoved:
+			counts.Removed++
+		}
+		if len(paths) < maxSummaryPaths {
+			paths = append(paths, diff.Path)
+		}
+	}
+
+	var parts []string
+	for _, part := range []struct {
+		count int
+		label string
+	}{{counts.Changed, "changed"}, {counts.Added, "added"}, {counts.Removed, "removed"}} {
+		if part.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", part.count, part.label))
+		}
+	}
+	summary := strings.Join(parts, ", ") + ": " + strings.Join(paths, ", ")
+	if more := len(diffs) - len(paths); more > 0 {
+		summary += fmt.Sprintf(" and %d more", more)
+	}
+	return counts, summary
+}
+
+func (r *CompareReport) add(result ResourceComparison) {
+	switch result.Status {
+	case StatusIdentical:
+		r.Summary.Identical++
+	case StatusDifferent:
+		r.Summary.Different++
+	case StatusOnlyInA:
+		r.Summary.OnlyInA++
+	case StatusOnlyInB:
+		r.Summary.OnlyInB++
+	default:
+		r.Summary.Errors++
+	}
+	r.Resources = append(r.Resources, result)
+}
@@ -0,0 +1,327 @@
+package compare
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakePreferredDiscovery serves its Resources as the preferred ones, which the fake
+// discovery client leaves empty
+type fakePreferredDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d fakePreferredDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+var testResources = []*metav1.APIResourceList{
+	{GroupVersion: "v1", APIResources: []metav1.APIResource{
+		{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "services", Kind: "Service", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+	}},
+	{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+		{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "deployments/status", Kind: "Deployment", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+	}},
+}
+
+func deployment(namespace, image string, replicas int64, uid string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name": "payments", "namespace": namespace, "uid": uid, "resourceVersion": uid + "00",
+			"annotations": map[string]interface{}{"deployment.kubernetes.io/revision": uid},
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "payments", "image": image}},
+			}},
+		},
+		"status": map[string]interface{}{"readyReplicas": replicas},
+	}}
+}
+
+func object(apiVersion, kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+	}}
+	for key, value := range fields {
+		obj.Object[key] = value
+	}
+	return obj
+}
+
+// testClusters serves staging and prod, each with the payments Deployment
+func testClusters() manifests.ClientFactory {
+	owned := object("v1", "Pod", "shop", "payments-abc", nil)
+	owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "payments-abc"}})
+
+	objects := map[string][]runtime.Object{
+		"staging": {
+			deployment("shop", "registry.staging/payments:1.4@sha256:aaa", 1, "s1"),
+			object("v1", "ConfigMap", "shop", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "test", "region": "eu"}}),
+			object("v1", "ConfigMap", "shop", "kube-root-ca.crt", nil),
+			object("v1", "ConfigMap", "shop", "feature-flags", nil),
+			object("v1", "Event", "shop", "payments.1", nil),
+			owned,
+		},
+		"prod": {
+			deployment("payments", "registry.prod/payments:v1.4@sha256:aaa", 3, "p1"),
+			object("v1", "ConfigMap", "payments", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "live", "region": "eu"}}),
+			object("v1", "Service", "payments", "payments", map[string]interface{}{"spec": map[string]interface{}{"clusterIP": "10.0.0.7"}}),
+		},
+	}
+	return fakeClusters(objects)
+}
+
+// fakeClusters serves the given objects per cluster context
+func fakeClusters(objects map[string][]runtime.Object) manifests.ClientFactory {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, list := range testResources {
+		gv, _ := schema.ParseGroupVersion(list.GroupVersion)
+		for _, resource := range list.APIResources {
+			listKinds[gv.WithResource(resource.Name)] = resource.Kind + "List"
+		}
+	}
+	return func(context string) (*manifests.ClusterClients, error) {
+		items, ok := objects[context]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", manifests.ErrClusterNotConnected, context)
+		}
+		return &manifests.ClusterClients{
+			Dynamic:   dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, items...),
+			Discovery: fakePreferredDiscovery{&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: testResources}}},
+		}, nil
+	}
+}
+
+func TestCompareObject(t *testing.T) {
+	ctx := context.Background()
+	req := CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", NamespaceB: "payments", Kind: "deployment", Name: "payments"}
+
+	report, err := compareResources(ctx, req, testClusters())
+	require.NoError(t, err)
+	require.Len(t, report.Resources, 1)
+	result := report.Resources[0]
+	assert.Equal(t, "apps/v1", result.APIVersion)
+	assert.Equal(t, "Deployment", result.Kind)
+	assert.Equal(t, StatusDifferent, result.Status)
+	// Namespaces, UIDs, resource versions, revisions and status are noise
+	assert.Equal(t, []string{"spec.replicas", "spec.template.spec.containers[name=payments].image"}, diffPaths(result))
+	assert.Equal(t, DriftCounts{Changed: 2}, result.Drift)
+	assert.Equal(t, "2 changed: spec.replicas, spec.template.spec.containers[name=payments].image", result.Summary)
+	assert.Equal(t, CompareSummary{Different: 1}, report.Summary)
+
+	// The images share a digest, and replicas are expected to differ
+	req.Images = ImagesByDigest
+	req.IgnorePaths = []string{"spec.replicas"}
+	report, err = compareResources(ctx, req, testClusters())
+	require.NoError(t, err)
+	assert.Equal(t, StatusIdentical, report.Resources[0].Status)
+	assert.Empty(t, report.Resources[0].Diffs)
+
+	req = CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", NamespaceB: "payments", Kind: "Service", APIVersion: "v1", Name: "payments"}
+	report, err = compareResources(ctx, req, testClusters())
+	require.NoError(t, err)
+	assert.Equal(t, StatusOnlyInB, report.Resources[0].Status)
+
+	req.Name = "missing"
+	_, err = compareResources(ctx, req, testClusters())
+	assert.ErrorIs(t, err, errNotFound)
+
+	req = CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", Kind: "Widget", Name: "payments"}
+	_, err = compareResources(ctx, req, testClusters())
+	assert.ErrorIs(t, err, errInvalidRequest)
+
+	req.ClusterB = "dev"
+	_, err = compareResources(ctx, req, testClusters())
+	assert.ErrorIs(t, err, manifests.ErrClusterNotConnected)
+}
+
+func TestCompareNamespaces(t *testing.T) {
+	req := CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", NamespaceB: "payments", Images: ImagesByDigest}
+	report, err := compareResources(context.Background(), req, testClusters())
+	require.NoError(t, err)
+
+	statuses := map[string]string{}
+	for _, resource := range report.Resources {
+		statuses[resource.Kind+"/"+resource.Name] = resource.Status
+	}
+	// Events, owned Pods and the control plane's ConfigMap are not compared
+	assert.Equal(t, map[string]string{
+		"ConfigMap/feature-flags": StatusOnlyInA,
+		"ConfigMap/settings":      StatusDifferent,
+		"Deployment/payments":     StatusDifferent,
+		"Service/payments":        StatusOnlyInB,
+	}, statuses)
+	assert.Equal(t, CompareSummary{Different: 2, OnlyInA: 1, OnlyInB: 1}, report.Summary)
+
+	req.Kinds = []string{"configmaps"}
+	report, err = compareResources(context.Background(), req, testClusters())
+	require.NoError(t, err)
+	require.Len(t, report.Resources, 2)
+	assert.Equal(t, "feature-flags", report.Resources[0].Name)
+	settings := report.Resources[1]
+	assert.Equal(t, []string{"data.mode"}, diffPaths(settings))
+	assert.Equal(t, "test", settings.Diffs[0].From)
+	assert.Equal(t, "live", settings.Diffs[0].To)
+
+	req.Kinds = []string{"Widget"}
+	_, err = compareResources(context.Background(), req, testClusters())
+	assert.ErrorIs(t, err, errInvalidRequest)
+}
+
+func TestCompareSecretsMasked(t *testing.T) {
+	a := object("v1", "Secret", "shop", "db", map[string]interface{}{"data": map[string]interface{}{"password": "c3RhZ2luZw=="}})
+	b := object("v1", "Secret", "payments", "db", map[string]interface{}{"data": map[string]interface{}{"password": "cHJvZA=="}})
+	result := comparePair(objectPair{apiVersion: "v1", kind: "Secret", name: "db", a: a, b: b}, CompareRequest{})
+	assert.Equal(t, StatusDifferent, result.Status)
+	assert.Equal(t, []manifests.FieldDiff{
+		{Path: "data.password", Type: manifests.FieldChanged, From: manifests.MaskedValue, To: manifests.MaskedValue},
+	}, result.Diffs)
+
+	// The control plane's service account tokens are not compared
+	token := object("v1", "Secret", "shop", "default-token", map[string]interface{}{"type": "kubernetes.io/service-account-token"})
+	assert.True(t, manifests.IsGenerated(token))
+	assert.False(t, manifests.IsGenerated(a))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  CompareRequest
+	}{
+		{"name without kind", CompareRequest{ClusterA: "a", ClusterB: "b", NamespaceA: "x", Name: "web"}},
+		{"kind without name", CompareRequest{ClusterA: "a", ClusterB: "b", NamespaceA: "x", Kind: "Deployment"}},
+		{"namespaces without namespace", CompareRequest{ClusterA: "a", ClusterB: "b"}},
+		{"same side", CompareRequest{ClusterA: "a", ClusterB: "a", NamespaceA: "x"}},
+		{"unknown image mode", CompareRequest{ClusterA: "a", ClusterB: "b", NamespaceA: "x", Images: "tag"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, validate(&tt.req), errInvalidRequest)
+		})
+	}
+
+	req := CompareRequest{ClusterA: "a", ClusterB: "b", NamespaceA: "x"}
+	require.NoError(t, validate(&req))
+	assert.Equal(t, "x", req.NamespaceB)
+	assert.Equal(t, ImagesByReference, req.Images)
+}
+
+func TestPinPodDigests(t *testing.T) {
+	pod := object("v1", "Pod", "shop", "web", map[string]interface{}{
+		"spec": map[string]interface{}{"containers": []interface{}{
+			map[string]interface{}{"name": "web", "image": "web:1.0"},
+			map[string]interface{}{"name": "proxy", "image": "proxy@sha256:bbb"},
+		}},
+		"status": map[string]interface{}{"containerStatuses": []interface{}{
+			map[string]interface{}{"name": "web", "imageID": "docker-pullable://web@sha256:aaa"},
+			map[string]interface{}{"name": "proxy", "imageID": "docker-pullable://proxy@sha256:bbb"},
+		}},
+	})
+	pinPodDigests(pod)
+	containers, _, _ := unstructured.NestedSlice(pod.Object, "spec", "containers")
+	assert.Equal(t, "web:1.0@sha256:aaa", containers[0].(map[string]interface{})["image"])
+	assert.Equal(t, "proxy@sha256:bbb", containers[1].(map[string]interface{})["image"])
+}
+
+// TestCompareWorkloadDigests verifies workload images referenced by tag are compared
+// by the digests their pods run
+func TestCompareWorkloadDigests(t *testing.T) {
+	selected := func(namespace, image string) *unstructured.Unstructured {
+		obj := deployment(namespace, image, 1, "x")
+		require.NoError(t, unstructured.SetNestedField(obj.Object, map[string]interface{}{
+			"matchLabels": map[string]interface{}{"app": "payments"},
+		}, "spec", "selector"))
+		return obj
+	}
+	pod := func(namespace, name, digest string) *unstructured.Unstructured {
+		obj := object("v1", "Pod", namespace, name, map[string]interface{}{
+			"status": map[string]interface{}{"containerStatuses": []interface{}{
+				map[string]interface{}{"name": "payments", "imageID": "docker-pullable://payments@" + digest},
+			}},
+		})
+		obj.SetLabels(map[string]string{"app": "payments"})
+		obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "payments-1"}})
+		return obj
+	}
+	clusters := fakeClusters(map[string][]runtime.Object{
+		"staging": {selected("shop", "payments:latest"), pod("shop", "payments-1-a", "sha256:aaa")},
+		"prod":    {selected("payments", "payments:latest"), pod("payments", "payments-1-b", "sha256:bbb"), pod("payments", "payments-1-c", "sha256:aaa")},
+		"dev":     {selected("shop", "payments:latest"), pod("shop", "payments-1-d", "sha256:aaa")},
+	})
+	ctx := context.Background()
+	req := CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", NamespaceB: "payments", Kind: "deployment", Name: "payments", Images: ImagesByDigest}
+
+	// The same tag running different digests is a difference
+	report, err := compareResources(ctx, req, clusters)
+	require.NoError(t, err)
+	result := report.Resources[0]
+	assert.Equal(t, StatusDifferent, result.Status)
+	require.Len(t, result.Diffs, 1)
+	assert.Equal(t, "payments:latest@sha256:aaa", result.Diffs[0].From)
+	assert.Equal(t, "payments:latest@sha256:aaa,sha256:bbb", result.Diffs[0].To)
+
+	req.ClusterB, req.NamespaceB = "dev", "shop"
+	report, err = compareResources(ctx, req, clusters)
+	require.NoError(t, err)
+	assert.Equal(t, StatusIdentical, report.Resources[0].Status)
+
+	// Comparing by reference ignores the pods
+	req.ClusterB, req.NamespaceB, req.Images = "prod", "payments", ImagesByReference
+	report, err = compareResources(ctx, req, clusters)
+	require.NoError(t, err)
+	assert.Equal(t, StatusIdentical, report.Resources[0].Status)
+}
+
+func TestCompareCronJobDigestsRejected(t *testing.T) {
+	resources := append(testResources, &metav1.APIResourceList{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{
+		{Name: "cronjobs", Kind: "CronJob", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+	}})
+	cronJob := object("batch/v1", "CronJob", "shop", "report", nil)
+	clients := func(context string) (*manifests.ClusterClients, error) {
+		return &manifests.ClusterClients{
+			Dynamic: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				{Group: "batch", Version: "v1", Resource: "cronjobs"}: "CronJobList",
+			}, cronJob.DeepCopy()),
+			Discovery: fakePreferredDiscovery{&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: resources}}},
+		}, nil
+	}
+
+	req := CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", Kind: "CronJob", Name: "report", Images: ImagesByDigest}
+	_, err := compareResources(context.Background(), req, clients)
+	assert.ErrorIs(t, err, errInvalidRequest)
+
+	req = CompareRequest{ClusterA: "staging", NamespaceA: "shop", ClusterB: "prod", Kinds: []string{"cronjobs"}, Images: ImagesByDigest}
+	report, err := compareResources(context.Background(), req, clients)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"images of CronJobs report are compared by reference: their pods belong to Jobs"}, report.Warnings)
+}
+
+func diffPaths(result ResourceComparison) []string {
+	paths := make([]string, 0, len(result.Diffs))
+	for _, diff := range result.Diffs {
+		paths = append(paths, diff.Path)
+	}
+	return paths
+}
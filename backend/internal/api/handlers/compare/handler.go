@@ -0,0 +1,45 @@
+package compare
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+)
+
+var clusterManager *kubernetes.ClusterManager
+
+// Initialize sets up the comparison handlers with the cluster manager
+func Initialize(cm *kubernetes.ClusterManager) {
+	clusterManager = cm
+}
+
+// CompareResources diffs one object, or every object in two namespaces, between two clusters
+// POST /api/v1/compare/resources
+func CompareResources(c *gin.Context) {
+	if clusterManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := compareResources(c.Request.Context(), req, manifests.ConnectedClients(clusterManager))
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errInvalidRequest):
+			code = http.StatusBadRequest
+		case errors.Is(err, errNotFound), errors.Is(err, manifests.ErrClusterNotConnected):
+			code = http.StatusNotFound
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,78 @@
+package compare
+
+import (
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+)
+
+// Comparison statuses reported per resource
+const (
+	StatusIdentical = "identical"
+	StatusDifferent = "different"
+	StatusOnlyInA   = "only-in-a"
+	StatusOnlyInB   = "only-in-b"
+	StatusError     = "error" // could not be compared, see Error
+)
+
+// How images are compared
+const (
+	ImagesByReference = "reference" // the full image reference, registry and tag included
+	ImagesByDigest    = "digest"    // only the digest, pinned from the pods a workload runs, so retagged or mirrored images match; not for CronJobs
+)
+
+// CompareRequest names the two sides to compare. With Kind and Name it compares one
+// object; without them every object in the two namespaces.
+type CompareRequest struct {
+	ClusterA    string   `json:"clusterA" binding:"required"`
+	NamespaceA  string   `json:"namespaceA,omitempty"`
+	ClusterB    string   `json:"clusterB" binding:"required"`
+	NamespaceB  string   `json:"namespaceB,omitempty"` // defaults to namespaceA
+	Kind        string   `json:"kind,omitempty"`
+	APIVersion  string   `json:"apiVersion,omitempty"` // narrows kind to a group; the preferred version is used when empty
+	Name        string   `json:"name,omitempty"`
+	Kinds       []string `json:"kinds,omitempty"`       // namespace comparisons only: kinds or resource names to compare, all when empty
+	Images      string   `json:"images,omitempty"`      // reference or digest, default reference
+	IgnorePaths []string `json:"ignorePaths,omitempty"` // fields to ignore with everything under them, e.g. spec.replicas
+}
+
+// DriftCounts counts the fields that differ from side A to side B
+type DriftCounts struct {
+	Changed int `json:"changed"`
+	Added   int `json:"added"`   // set only on side B
+	Removed int `json:"removed"` // set only on side A
+}
+
+// ResourceComparison compares one object on both sides
+type ResourceComparison struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Name       string                `json:"name"`
+	Status     string                `json:"status"`
+	Drift      DriftCounts           `json:"drift"`
+	Summary    string                `json:"summary,omitempty"` // e.g. 2 changed: spec.replicas, spec.template.spec.containers[name=web].image
+	Diffs      []manifests.FieldDiff `json:"diffs,omitempty"`   // from side A to side B
+	Error      string                `json:"error,omitempty"`
+}
+
+// CompareSummary counts resources by status
+type CompareSummary struct {
+	Identical int `json:"identical"`
+	Different int `json:"different"`
+	OnlyInA   int `json:"onlyInA"`
+	OnlyInB   int `json:"onlyInB"`
+	Errors    int `json:"errors"`
+}
+
+// CompareReport is the comparison of two clusters or namespaces
+type CompareReport struct {
+	ClusterA    string               `json:"clusterA"`
+	NamespaceA  string               `json:"namespaceA,omitempty"`
+	ClusterB    string               `json:"clusterB"`
+	NamespaceB  string               `json:"namespaceB,omitempty"`
+	Images      string               `json:"images"`
+	Summary     CompareSummary       `json:"summary"`
+	Resources   []ResourceComparison `json:"resources"`
+	Warnings    []string             `json:"warnings,omitempty"` // types that could not be listed on a side
+	GeneratedAt time.Time            `json:"generatedAt"`
+}
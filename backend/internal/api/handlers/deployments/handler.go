@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -55,13 +56,8 @@ func List(c *gin.Context) {
 		}
 
 		for _, namespace := range namespaces {
-			listOptions := metav1.ListOptions{}
-			
 			// Get deployments from the cluster
-			deploymentList, err := conn.ClientSet.AppsV1().Deployments(namespace).List(
-				context.Background(),
-				listOptions,
-			)
+			deploymentList, err := listDeployments(contextName, conn, namespace)
 			if err != nil {
 				continue
 			}
@@ -86,6 +82,22 @@ func List(c *gin.Context) {
 	})
 }
 
+// listDeployments reads deployments from the cluster's informer cache, falling back
+// to the API when the cache is unavailable
+func listDeployments(contextName string, conn *kubernetes.ClusterConnection, namespace string) (*appsv1.DeploymentList, error) {
+	if resources, err := clusterManager.GetResourceCache(contextName); err == nil {
+		cached, err := kubernetes.ListAs[*appsv1.Deployment](resources, "deployments", namespace)
+		if err == nil {
+			deployments := &appsv1.DeploymentList{Items: make([]appsv1.Deployment, 0, len(cached))}
+			for _, deployment := range cached {
+				deployments.Items = append(deployments.Items, *deployment)
+			}
+			return deployments, nil
+		}
+	}
+	return conn.ClientSet.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+}
+
 // Get handles getting a single deployment details
 func Get(c *gin.Context) {
 	context := c.Param("context")
@@ -67,9 +67,10 @@ func List(c *gin.Context) {
 		listOptions.FieldSelector = fmt.Sprintf("involvedObject.name=%s", involvedObjectName)
 	}
 
-	var allEvents []corev1.Event
+	// Prefer the shared informer cache, falling back to the API when it is unavailable
+	allEvents, cached := cachedEvents(context, namespace, involvedObjectName)
 	
-	if namespace != "" && namespace != "all" {
+	if !cached && namespace != "" && namespace != "all" {
 		// Fetch from specific namespace
 		events, err := conn.ClientSet.CoreV1().Events(namespace).List(c.Request.Context(), listOptions)
 		if err != nil {
@@ -77,7 +78,7 @@ func List(c *gin.Context) {
 			return
 		}
 		allEvents = events.Items
-	} else {
+	} else if !cached {
 		// Fetch from all namespaces - we need to get events from each namespace
 		// to ensure we get the latest events across all namespaces
 		namespaces, err := conn.ClientSet.CoreV1().Namespaces().List(c.Request.Context(), metav1.ListOptions{})
@@ -139,6 +140,34 @@ func List(c *gin.Context) {
 	})
 }
 
+// cachedEvents reads events from the cluster's informer cache. ok is false when the
+// cache cannot serve them (e.g. missing list/watch permission) and the caller should
+// query the API instead.
+func cachedEvents(clusterContext, namespace, involvedObjectName string) ([]corev1.Event, bool) {
+	resources, err := clusterManager.GetResourceCache(clusterContext)
+	if err != nil {
+		return nil, false
+	}
+	if namespace == "all" {
+		namespace = metav1.NamespaceAll
+	}
+
+	events, err := kubernetes.ListAs[*corev1.Event](resources, "events", namespace)
+	if err != nil {
+		log.Printf("Event cache unavailable for %s, falling back to API: %v", clusterContext, err)
+		return nil, false
+	}
+
+	result := make([]corev1.Event, 0, len(events))
+	for _, event := range events {
+		if involvedObjectName != "" && event.InvolvedObject.Name != involvedObjectName {
+			continue
+		}
+		result = append(result, *event)
+	}
+	return result, true
+}
+
 func transformEventList(events []corev1.Event, typeFilter, reasonFilter, kindFilter string) []EventInfo {
 	var result []EventInfo
 	
@@ -222,20 +251,24 @@ func GetReasons(c *gin.Context) {
 		return
 	}
 
-	var events *corev1.EventList
-	if namespace != "" && namespace != "all" {
-		events, err = conn.ClientSet.CoreV1().Events(namespace).List(c.Request.Context(), metav1.ListOptions{})
-	} else {
-		events, err = conn.ClientSet.CoreV1().Events("").List(c.Request.Context(), metav1.ListOptions{})
-	}
+	items, cached := cachedEvents(context, namespace, "")
+	if !cached {
+		var events *corev1.EventList
+		if namespace != "" && namespace != "all" {
+			events, err = conn.ClientSet.CoreV1().Events(namespace).List(c.Request.Context(), metav1.ListOptions{})
+		} else {
+			events, err = conn.ClientSet.CoreV1().Events("").List(c.Request.Context(), metav1.ListOptions{})
+		}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items = events.Items
 	}
 
 	reasonMap := make(map[string]int)
-	for _, event := range events.Items {
+	for _, event := range items {
 		reasonMap[event.Reason]++
 	}
 
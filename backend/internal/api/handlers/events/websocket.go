@@ -10,9 +10,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 var upgrader = websocket.Upgrader{
@@ -180,9 +180,9 @@ func EventsWebSocket(c *gin.Context) {
 }
 
 func watchClusterEvents(ctx context.Context, clusterContext string, sub EventSubscription, updates chan<- EventUpdate) {
-	conn, err := clusterManager.GetConnection(clusterContext)
-	if err != nil || conn == nil {
-		log.Printf("Failed to get connection for cluster %s: %v", clusterContext, err)
+	resources, err := clusterManager.GetResourceCache(clusterContext)
+	if err != nil {
+		log.Printf("Failed to get resource cache for cluster %s: %v", clusterContext, err)
 		return
 	}
 
@@ -193,32 +193,33 @@ func watchClusterEvents(ctx context.Context, clusterContext string, sub EventSub
 	}
 
 	for _, namespace := range namespaces {
-		go watchNamespaceEvents(ctx, conn.ClientSet, clusterContext, namespace, sub, updates)
+		go watchNamespaceEvents(ctx, resources, clusterContext, namespace, sub, updates)
 	}
 }
 
-func watchNamespaceEvents(ctx context.Context, clientSet kubernetes.Interface, clusterContext, namespace string, sub EventSubscription, updates chan<- EventUpdate) {
-	// Create watch options
-	watchOptions := metav1.ListOptions{
-		Watch: true,
-	}
-
-	// Create watcher
-	watcher, err := clientSet.CoreV1().Events(namespace).Watch(ctx, watchOptions)
+func watchNamespaceEvents(ctx context.Context, resources *kubernetes.ResourceCache, clusterContext, namespace string, sub EventSubscription, updates chan<- EventUpdate) {
+	// Subscribe to the shared event informer, replaying current events like a fresh watch would
+	subscription, err := resources.Subscribe("events", namespace, true)
 	if err != nil {
-		log.Printf("Failed to create watcher for namespace %s: %v", namespace, err)
+		log.Printf("Failed to subscribe to events for namespace %s: %v", namespace, err)
 		return
 	}
-	defer watcher.Stop()
+	defer subscription.Close()
 
 	// Process events
+	var dropped int64
 	for {
 		select {
-		case event, ok := <-watcher.ResultChan():
+		case event, ok := <-subscription.Events():
 			if !ok {
-				log.Printf("Watcher channel closed for namespace %s", namespace)
+				log.Printf("Event subscription closed for namespace %s", namespace)
 				return
 			}
+			// The event feed is best effort; note when this reader fell behind
+			if total := subscription.Dropped(); total > dropped {
+				log.Printf("Event subscription for namespace %s fell behind, %d events dropped", namespace, total-dropped)
+				dropped = total
+			}
 
 			k8sEvent, ok := event.Object.(*corev1.Event)
 			if !ok {
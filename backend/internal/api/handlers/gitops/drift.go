@@ -0,0 +1,194 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resourceResolver maps a kind and apiVersion to its resource and whether it is namespaced
+type resourceResolver func(kind, apiVersion string) (schema.GroupVersionResource, bool, error)
+
+// detectDrift renders the repository and compares it with each cluster in parallel
+func detectDrift(ctx context.Context, root string, req DriftRequest, clients manifests.ClientFactory) (*DriftReport, error) {
+	source, desired, err := loadSource(ctx, root, req)
+	if err != nil {
+		return nil, err
+	}
+	if desired, err = dedupe(desired); err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{Source: *source, Clusters: make([]ClusterDrift, len(req.Contexts)), GeneratedAt: time.Now()}
+	var wg sync.WaitGroup
+	for i, clusterContext := range req.Contexts {
+		wg.Add(1)
+		go func(i int, clusterContext string) {
+			defer wg.Done()
+			report.Clusters[i] = ClusterDrift{Context: clusterContext, Resources: []ResourceDrift{}}
+			cluster, err := clients(clusterContext)
+			if err != nil {
+				report.Clusters[i].Error = err.Error()
+				return
+			}
+			report.Clusters[i] = compareCluster(ctx, cluster, desired, req)
+			report.Clusters[i].Context = clusterContext
+		}(i, clusterContext)
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// dedupe drops repeated identical definitions of an object. Conflicting definitions,
+// e.g. from two overlays of the same base, are an error: dir must select one of them.
+func dedupe(desired []desiredObject) ([]desiredObject, error) {
+	seen := make(map[string]desiredObject)
+	var unique []desiredObject
+	for _, item := range desired {
+		obj := item.object
+		key := liveKey(obj.GroupVersionKind().Group, obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		if first, ok := seen[key]; ok {
+			if equality.Semantic.DeepEqual(first.object.Object, obj.Object) {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s %s is defined differently in %s and %s; set dir to one of them",
+				errInvalidSource, obj.GetKind(), obj.GetName(), first.file, item.file)
+		}
+		seen[key] = item
+		unique = append(unique, item)
+	}
+	return unique, nil
+}
+
+// liveKey identifies a live object independently of the version it was read at
+func liveKey(group, kind, namespace, name string) string {
+	return group + "/" + kind + "/" + namespace + "/" + name
+}
+
+// compareCluster compares every desired object with its live counterpart. Fields only
+// the live object sets are ignored, since the server defaults many of them.
+func compareCluster(ctx context.Context, cluster *manifests.ClusterClients, desired []desiredObject, req DriftRequest) ClusterDrift {
+	drift := ClusterDrift{Resources: []ResourceDrift{}}
+	resolve := discoveryResolver(cluster.Discovery)
+	defaultNamespace := req.Namespace
+	if defaultNamespace == "" {
+		defaultNamespace = "default"
+	}
+
+	type scope struct {
+		gvr       schema.GroupVersionResource
+		namespace string
+	}
+	scopes := make(map[scope]bool)
+	managed := make(map[string]bool)
+
+	for _, item := range desired {
+		obj := item.object
+		result := ResourceDrift{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			File:       item.file,
+		}
+		gvr, namespaced, err := resolve(obj.GetKind(), obj.GetAPIVersion())
+		if err != nil {
+			result.Status, result.Error = StatusUnknown, err.Error()
+			drift.add(result)
+			continue
+		}
+		if !namespaced {
+			result.Namespace = ""
+		} else if result.Namespace == "" {
+			result.Namespace = defaultNamespace
+		}
+		managed[liveKey(gvr.Group, result.Kind, result.Namespace, result.Name)] = true
+		if namespaced {
+			scopes[scope{gvr: gvr, namespace: result.Namespace}] = true
+		}
+
+		live, err := cluster.Dynamic.Resource(gvr).Namespace(result.Namespace).Get(ctx, result.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			result.Status = StatusMissing
+		case err != nil:
+			result.Status, result.Error = StatusUnknown, err.Error()
+		default:
+			want := obj.DeepCopy()
+			want.SetNamespace(result.Namespace)
+			manifests.NormalizeManifest(want)
+			manifests.NormalizeManifest(live)
+			diffs := manifests.DiffObjects(want.Object, live.Object, manifests.DiffOptions{IgnoreAdded: true})
+			result.Diffs = manifests.MaskSecretDiffs(want, diffs)
+			result.Status = StatusInSync
+			if len(result.Diffs) > 0 {
+				result.Status = StatusModified
+			}
+		}
+		drift.add(result)
+	}
+
+	if !req.DetectExtra {
+		return drift
+	}
+	// Extra objects are looked for only among the kinds and namespaces Git manages, and
+	// objects created by controllers, which carry ownerReferences, are left out
+	ordered := make([]scope, 0, len(scopes))
+	for s := range scopes {
+		ordered = append(ordered, s)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].gvr.String()+ordered[i].namespace < ordered[j].gvr.String()+ordered[j].namespace
+	})
+	for _, s := range ordered {
+		list, err := cluster.Dynamic.Resource(s.gvr).Namespace(s.namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			drift.add(ResourceDrift{
+				APIVersion: s.gvr.GroupVersion().String(),
+				Kind:       s.gvr.Resource,
+				Namespace:  s.namespace,
+				Status:     StatusUnknown,
+				Error:      fmt.Sprintf("failed to list %s: %v", s.gvr.Resource, err),
+			})
+			continue
+		}
+		for i := range list.Items {
+			live := &list.Items[i]
+			if managed[liveKey(s.gvr.Group, live.GetKind(), live.GetNamespace(), live.GetName())] || manifests.IsGenerated(live) {
+				continue
+			}
+			drift.add(ResourceDrift{
+				APIVersion: live.GetAPIVersion(),
+				Kind:       live.GetKind(),
+				Namespace:  live.GetNamespace(),
+				Name:       live.GetName(),
+				Status:     StatusExtra,
+			})
+		}
+	}
+	return drift
+}
+
+func (d *ClusterDrift) add(result ResourceDrift) {
+	switch result.Status {
+	case StatusInSync:
+		d.Summary.InSync++
+	case StatusModified:
+		d.Summary.Modified++
+	case StatusMissing:
+		d.Summary.Missing++
+	case StatusExtra:
+		d.Summary.Extra++
+	default:
+		d.Summary.Unknown++
+	}
+	d.Resources = append(d.Resources, result)
+}
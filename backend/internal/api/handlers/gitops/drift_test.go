@@ -0,0 +1,314 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	configMapsGVR  = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	secretsGVR     = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+const baseDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: shop/web:1.0
+        resources:
+          requests:
+            cpu: "0.5"
+`
+
+const overlayKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: shop
+resources:
+- ../../base
+images:
+- name: shop/web
+  newTag: "1.1"
+`
+
+// newRepository commits a base, an overlay, a plain manifest directory and a Secret to a new repository
+func newRepository(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base/deployment.yaml":             baseDeployment,
+		"base/kustomization.yaml":          "resources:\n- deployment.yaml\n",
+		"overlays/prod/kustomization.yaml": overlayKustomization,
+		"plain/config.yaml":                "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: shop\ndata:\n  mode: fast\n---\n",
+		"plain/.github/workflow.yaml":      "on: push\n",
+		"plain/notes.yaml":                 "owner: platform\n",
+		"secrets/secret.yaml":              "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n  namespace: shop\nstringData:\n  password: hunter2\ndata:\n  user: YWRtaW4=\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func liveDeployment(image, cpu string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name": "web", "namespace": "shop", "uid": "d1", "resourceVersion": "7", "generation": int64(3),
+			"annotations": map[string]interface{}{"deployment.kubernetes.io/revision": "3"},
+		},
+		"spec": map[string]interface{}{
+			"replicas":             int64(2),
+			"revisionHistoryLimit": int64(10),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name": "web", "image": image, "imagePullPolicy": "IfNotPresent",
+					"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": cpu}},
+				}},
+			}},
+		},
+		"status": map[string]interface{}{"readyReplicas": int64(2)},
+	}}
+}
+
+func liveConfigMap(name string, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "shop"},
+		"data":       data,
+	}}
+}
+
+func liveSecret(name string, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "shop"},
+		"type":       "Opaque",
+		"data":       data,
+	}}
+}
+
+// testResources are the types the test clusters serve
+var testResources = []*metav1.APIResourceList{
+	{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}},
+	{GroupVersion: "v1", APIResources: []metav1.APIResource{
+		{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+		{Name: "secrets", Kind: "Secret", Namespaced: true},
+	}},
+}
+
+func testClients(objects map[string][]runtime.Object) manifests.ClientFactory {
+	return func(context string) (*manifests.ClusterClients, error) {
+		live, ok := objects[context]
+		if !ok {
+			return nil, fmt.Errorf("cluster %s not connected", context)
+		}
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			deploymentsGVR: "DeploymentList",
+			configMapsGVR:  "ConfigMapList",
+			secretsGVR:     "SecretList",
+		}, live...)
+		return &manifests.ClusterClients{
+			Dynamic:   client,
+			Discovery: &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: testResources}},
+		}, nil
+	}
+}
+
+func TestDriftAgainstKustomizeOverlay(t *testing.T) {
+	repo := newRepository(t)
+	clients := testClients(map[string][]runtime.Object{
+		"prod":    {liveDeployment("shop/web:1.1", "500m")},
+		"staging": {liveDeployment("shop/web:1.0", "500m")},
+	})
+
+	report, err := detectDrift(context.Background(), "", DriftRequest{
+		Path: repo, Dir: "overlays/prod", Contexts: []string{"prod", "staging", "dev"},
+	}, clients)
+	require.NoError(t, err)
+	assert.NotEmpty(t, report.Source.Commit)
+	assert.False(t, report.Source.Bare)
+	assert.Equal(t, 1, report.Source.Objects)
+	require.Len(t, report.Clusters, 3)
+
+	// Server defaults, status and equivalent quantities are not drift
+	prod := report.Clusters[0]
+	assert.Equal(t, DriftSummary{InSync: 1}, prod.Summary)
+	assert.Equal(t, "overlays/prod", prod.Resources[0].File)
+	assert.Equal(t, "shop", prod.Resources[0].Namespace)
+
+	staging := report.Clusters[1]
+	assert.Equal(t, DriftSummary{Modified: 1}, staging.Summary)
+	require.Len(t, staging.Resources[0].Diffs, 1)
+	diff := staging.Resources[0].Diffs[0]
+	assert.Equal(t, "spec.template.spec.containers[name=web].image", diff.Path)
+	assert.Equal(t, "shop/web:1.1", diff.From)
+	assert.Equal(t, "shop/web:1.0", diff.To)
+
+	assert.Equal(t, "cluster dev not connected", report.Clusters[2].Error)
+}
+
+func TestDriftRendersOverlaysInsteadOfTheirBase(t *testing.T) {
+	repo := newRepository(t)
+	clients := testClients(map[string][]runtime.Object{
+		"prod": {liveDeployment("shop/web:1.1", "500m")},
+	})
+	ctx := context.Background()
+
+	// The base is only rendered through the overlay that uses it
+	report, err := detectDrift(ctx, "", DriftRequest{Path: repo, Contexts: []string{"prod"}}, clients)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Source.Objects)
+	assert.Empty(t, report.Source.Warnings)
+	web := report.Clusters[0].Resources[0]
+	assert.Equal(t, "Deployment", web.Kind)
+	assert.Equal(t, "overlays/prod", web.File)
+	assert.Equal(t, StatusInSync, web.Status)
+
+	// Two overlays that patch the base differently cannot both be the desired state
+	staging := filepath.Join(repo, "overlays/staging/kustomization.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(staging), 0o755))
+	require.NoError(t, os.WriteFile(staging, []byte(strings.Replace(overlayKustomization, `"1.1"`, `"1.2"`, 1)), 0o644))
+	_, err = detectDrift(ctx, "", DriftRequest{Path: repo, Contexts: []string{"prod"}}, clients)
+	assert.ErrorIs(t, err, errInvalidSource)
+	assert.ErrorContains(t, err, "Deployment web is defined differently in overlays/prod and overlays/staging")
+
+	report, err = detectDrift(ctx, "", DriftRequest{Path: repo, Dir: "overlays/staging", Contexts: []string{"prod"}}, clients)
+	require.NoError(t, err)
+	assert.Equal(t, StatusModified, report.Clusters[0].Resources[0].Status)
+}
+
+func TestDriftPlainManifestsAndExtras(t *testing.T) {
+	repo := newRepository(t)
+	clients := testClients(map[string][]runtime.Object{
+		"prod": {
+			liveConfigMap("legacy", map[string]interface{}{"mode": "slow"}),
+			liveConfigMap("kube-root-ca.crt", map[string]interface{}{"ca.crt": "..."}),
+		},
+	})
+
+	report, err := detectDrift(context.Background(), "", DriftRequest{
+		Path: repo, Dir: "plain", Contexts: []string{"prod"}, DetectExtra: true,
+	}, clients)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Source.Objects, "hidden directories and non-manifests are skipped")
+	prod := report.Clusters[0]
+	assert.Equal(t, DriftSummary{Missing: 1, Extra: 1}, prod.Summary)
+	assert.Equal(t, StatusMissing, prod.Resources[0].Status)
+	assert.Equal(t, "plain/config.yaml", prod.Resources[0].File)
+	assert.Equal(t, ResourceDrift{APIVersion: "v1", Kind: "ConfigMap", Namespace: "shop", Name: "legacy", Status: StatusExtra}, prod.Resources[1])
+}
+
+func TestDriftSecrets(t *testing.T) {
+	repo := newRepository(t)
+	clients := testClients(map[string][]runtime.Object{
+		// "hunter2" and "admin", as the server stores stringData
+		"prod":    {liveSecret("db", map[string]interface{}{"password": "aHVudGVyMg==", "user": "YWRtaW4="})},
+		"staging": {liveSecret("db", map[string]interface{}{"password": "c3dvcmRmaXNo"})},
+	})
+
+	report, err := detectDrift(context.Background(), "", DriftRequest{Path: repo, Dir: "secrets", Contexts: []string{"prod", "staging"}}, clients)
+	require.NoError(t, err)
+	assert.Equal(t, DriftSummary{InSync: 1}, report.Clusters[0].Summary, "stringData compares as data")
+
+	// Secret values are masked on both sides
+	staging := report.Clusters[1].Resources[0]
+	assert.Equal(t, StatusModified, staging.Status)
+	assert.Equal(t, []manifests.FieldDiff{
+		{Path: "data.password", Type: manifests.FieldChanged, From: manifests.MaskedValue, To: manifests.MaskedValue},
+		{Path: "data.user", Type: manifests.FieldRemoved, From: manifests.MaskedValue},
+	}, staging.Diffs)
+}
+
+func TestDriftRevisions(t *testing.T) {
+	repo := newRepository(t)
+	clients := testClients(map[string][]runtime.Object{
+		"prod": {liveConfigMap("settings", map[string]interface{}{"mode": "fast"})},
+	})
+	ctx := context.Background()
+
+	// The working tree includes uncommitted changes; a ref reads the commit instead
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "plain/config.yaml"),
+		[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: shop\ndata:\n  mode: safe\n"), 0o644))
+	report, err := detectDrift(ctx, "", DriftRequest{Path: repo, Dir: "plain", Contexts: []string{"prod"}}, clients)
+	require.NoError(t, err)
+	assert.Equal(t, StatusModified, report.Clusters[0].Resources[0].Status)
+
+	report, err = detectDrift(ctx, "", DriftRequest{Path: repo, Ref: "main", Dir: "plain", Contexts: []string{"prod"}}, clients)
+	require.NoError(t, err)
+	assert.Equal(t, StatusInSync, report.Clusters[0].Resources[0].Status)
+
+	// Bare repositories are read at HEAD
+	bare := filepath.Join(t.TempDir(), "shop.git")
+	runGit(t, repo, "clone", "-q", "--bare", repo, bare)
+	report, err = detectDrift(ctx, "", DriftRequest{Path: bare, Dir: "plain", Contexts: []string{"prod"}}, clients)
+	require.NoError(t, err)
+	assert.True(t, report.Source.Bare)
+	assert.Equal(t, StatusInSync, report.Clusters[0].Resources[0].Status)
+
+	// Paths are confined to the repository root when one is configured
+	root := repo
+	_, err = detectDrift(ctx, root, DriftRequest{Path: ".", Dir: "plain", Contexts: []string{"prod"}}, clients)
+	assert.NoError(t, err)
+	for _, req := range []DriftRequest{
+		{Path: bare, Contexts: []string{"prod"}},
+		{Path: repo, Dir: "../..", Contexts: []string{"prod"}},
+		{Path: repo, Ref: "no-such-branch", Contexts: []string{"prod"}},
+		{Path: t.TempDir(), Contexts: []string{"prod"}},
+	} {
+		_, err = detectDrift(ctx, root, req, clients)
+		assert.ErrorIs(t, err, errInvalidSource, req)
+	}
+	_, err = detectDrift(ctx, "", DriftRequest{Path: t.TempDir(), Contexts: []string{"prod"}}, clients)
+	assert.ErrorContains(t, err, "is not a git repository")
+}
+
+func TestDiscoveryResolverSkipsSubresources(t *testing.T) {
+	resolve := discoveryResolver(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments/status", Kind: "Deployment", Namespaced: true},
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+		}},
+	}}})
+	gvr, namespaced, err := resolve("Deployment", "apps/v1")
+	require.NoError(t, err)
+	assert.Equal(t, deploymentsGVR, gvr)
+	assert.True(t, namespaced)
+	_, _, err = resolve("Widget", "apps/v1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package gitops
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+var clusterManager *kubernetes.ClusterManager
+
+// Initialize sets up the GitOps handlers with the cluster manager
+func Initialize(cm *kubernetes.ClusterManager) {
+	clusterManager = cm
+}
+
+// DetectDrift renders manifests from a local repository and compares them with each cluster
+// POST /api/v1/gitops/drift
+func DetectDrift(c *gin.Context) {
+	if clusterManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	var req DriftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := detectDrift(c.Request.Context(), os.Getenv(RepoRootEnv), req, manifests.ConnectedClients(clusterManager))
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, errInvalidSource) {
+			code = http.StatusBadRequest
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// discoveryResolver resolves kinds through discovery, fetching each group version once
+func discoveryResolver(client discovery.DiscoveryInterface) resourceResolver {
+	var mu sync.Mutex
+	cache := make(map[string][]manifests.ResourceType)
+	return func(kind, apiVersion string) (schema.GroupVersionResource, bool, error) {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return schema.GroupVersionResource{}, false, fmt.Errorf("invalid apiVersion: %s", apiVersion)
+		}
+		mu.Lock()
+		kinds, ok := cache[gv.String()]
+		mu.Unlock()
+		if !ok {
+			list, err := client.ServerResourcesForGroupVersion(gv.String())
+			if err != nil {
+				return schema.GroupVersionResource{}, false, fmt.Errorf("failed to discover resources for %s: %v", gv, err)
+			}
+			kinds = manifests.ServedTypes([]*metav1.APIResourceList{list}, "")
+			mu.Lock()
+			cache[gv.String()] = kinds
+			mu.Unlock()
+		}
+		for _, k := range kinds {
+			if k.Kind == kind {
+				return k.GVR, k.Namespaced, nil
+			}
+		}
+		return schema.GroupVersionResource{}, false, fmt.Errorf("resource with kind %s not found in %s", kind, apiVersion)
+	}
+}
@@ -0,0 +1,332 @@
+package gitops
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// RepoRootEnv names the directory repository paths are resolved against and confined to;
+// unset accepts any absolute path
+const RepoRootEnv = "GITOPS_REPO_ROOT"
+
+// maxManifestFile bounds the size of a file read from a repository
+const maxManifestFile = 10 << 20
+
+var errInvalidSource = errors.New("invalid source")
+
+// desiredObject is an object rendered from a repository and the file it came from
+type desiredObject struct {
+	object *unstructured.Unstructured
+	file   string
+}
+
+// repository is a local Git working tree or bare repository
+type repository struct {
+	path string
+	bare bool
+}
+
+// openRepository resolves path against root and checks that it is a Git repository
+func openRepository(ctx context.Context, root, path string) (*repository, error) {
+	resolved := filepath.Clean(path)
+	if root != "" {
+		if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(root, resolved)
+		}
+		if !within(root, resolved) {
+			return nil, fmt.Errorf("%w: %s is outside %s", errInvalidSource, path, RepoRootEnv)
+		}
+	} else if !filepath.IsAbs(resolved) {
+		return nil, fmt.Errorf("%w: path must be absolute", errInvalidSource)
+	}
+
+	out, err := git(ctx, resolved, "rev-parse", "--is-bare-repository")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a git repository", errInvalidSource, path)
+	}
+	return &repository{path: resolved, bare: out == "true"}, nil
+}
+
+// commit resolves a branch, tag or commit to a commit hash
+func (r *repository) commit(ctx context.Context, ref string) (string, error) {
+	out, err := git(ctx, r.path, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%w: unknown ref %s", errInvalidSource, ref)
+	}
+	return out, nil
+}
+
+// checkout writes the tree of a commit into dir
+func (r *repository) checkout(ctx context.Context, commit, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", r.path, "archive", "--format=tar", commit)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run git: %v", err)
+	}
+	extractErr := extractTar(stdout, dir)
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return extractErr
+}
+
+// extractTar writes the regular files and directories of an archive into dir, skipping links
+func extractTar(r io.Reader, dir string) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %v", err)
+		}
+		target := filepath.Join(dir, header.Name)
+		if !within(dir, target) {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if header.Size > maxManifestFile {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, archive)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// loadSource renders the requested revision. A working tree without a ref is read as it
+// is, uncommitted changes included; otherwise the commit is exported to a temporary directory.
+func loadSource(ctx context.Context, root string, req DriftRequest) (*Source, []desiredObject, error) {
+	repo, err := openRepository(ctx, root, req.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	source := &Source{Path: req.Path, Ref: req.Ref, Bare: repo.bare, Dir: req.Dir}
+
+	tree := repo.path
+	if repo.bare || req.Ref != "" {
+		ref := req.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if source.Commit, err = repo.commit(ctx, ref); err != nil {
+			return nil, nil, err
+		}
+		if tree, err = os.MkdirTemp("", "kaptivan-gitops-"); err != nil {
+			return nil, nil, err
+		}
+		defer os.RemoveAll(tree)
+		if err := repo.checkout(ctx, source.Commit, tree); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		// A repository without commits has no HEAD yet
+		source.Commit, _ = repo.commit(ctx, "HEAD")
+	}
+
+	dir := filepath.Join(tree, req.Dir)
+	if !within(tree, dir) {
+		return nil, nil, fmt.Errorf("%w: dir %s is outside the repository", errInvalidSource, req.Dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil, fmt.Errorf("%w: dir %s not found", errInvalidSource, req.Dir)
+	}
+
+	objects, warnings := renderTree(tree, dir)
+	source.Objects = len(objects)
+	source.Warnings = warnings
+	return source, objects, nil
+}
+
+// renderTree renders every manifest under dir. A directory with a kustomization is built
+// with Kustomize and its files are not read on their own; elsewhere YAML and JSON files
+// are read as plain manifests. Directories and files that a kustomization under dir uses
+// as a resource, base or component are only rendered through it, so an overlay replaces
+// its base. Files are reported relative to tree.
+func renderTree(tree, dir string) ([]desiredObject, []string) {
+	var (
+		objects  []desiredObject
+		warnings []string
+	)
+	referenced := kustomizationReferences(dir)
+	add := func(file string, decoded []*unstructured.Unstructured) {
+		for _, obj := range decoded {
+			if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+				continue // not a Kubernetes object, e.g. a CI config
+			}
+			objects = append(objects, desiredObject{object: obj, file: file})
+		}
+	}
+
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(tree, path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !hasKustomization(path) {
+				return nil
+			}
+			if referenced[path] {
+				return filepath.SkipDir
+			}
+			rendered, err := renderKustomization(path)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: kustomize build failed: %v", rel, err))
+				return filepath.SkipDir
+			}
+			decoded, err := manifests.DecodeManifest(rendered)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %v", rel, err))
+				return filepath.SkipDir
+			}
+			add(rel, decoded)
+			return filepath.SkipDir
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		if referenced[path] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxManifestFile {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+		decoded, err := manifests.DecodeManifest(data)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+		add(rel, decoded)
+		return nil
+	})
+	return objects, warnings
+}
+
+func hasKustomization(dir string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.Mode().IsRegular() {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizationReferences returns the local paths that the kustomizations under dir
+// include as resources, bases or components. Remote references match no path.
+func kustomizationReferences(dir string) map[string]bool {
+	referenced := make(map[string]bool)
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		kustomization, ok := readKustomization(path)
+		if !ok {
+			return nil
+		}
+		for _, refs := range [][]string{kustomization.Resources, kustomization.Bases, kustomization.Components} {
+			for _, ref := range refs {
+				referenced[filepath.Join(path, ref)] = true
+			}
+		}
+		return nil
+	})
+	return referenced
+}
+
+// readKustomization parses the kustomization file of a directory, if it has one
+func readKustomization(dir string) (*types.Kustomization, bool) {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		var kustomization types.Kustomization
+		if err := yaml.Unmarshal(data, &kustomization); err != nil {
+			return nil, false
+		}
+		return &kustomization, true
+	}
+	return nil, false
+}
+
+// renderKustomization runs kustomize build on a directory
+func renderKustomization(dir string) ([]byte, error) {
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, err
+	}
+	return resources.AsYaml()
+}
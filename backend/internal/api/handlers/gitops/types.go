@@ -0,0 +1,73 @@
+package gitops
+
+import (
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+)
+
+// Drift statuses reported per resource
+const (
+	StatusInSync   = "in-sync"
+	StatusModified = "modified"
+	StatusMissing  = "missing" // in Git but not in the cluster
+	StatusExtra    = "extra"   // in the cluster but not in Git
+	StatusUnknown  = "unknown" // could not be compared, see Error
+)
+
+// DriftRequest names a repository revision and the clusters to compare it with
+type DriftRequest struct {
+	Path        string   `json:"path" binding:"required"`           // working tree or bare repository
+	Ref         string   `json:"ref,omitempty"`                     // branch, tag or commit; empty reads the working tree as it is, or HEAD of a bare repository
+	Dir         string   `json:"dir,omitempty"`                     // directory in the repository to render, e.g. overlays/prod
+	Contexts    []string `json:"contexts" binding:"required,min=1"` // clusters to compare
+	Namespace   string   `json:"namespace,omitempty"`               // for namespaced manifests without one; default "default"
+	DetectExtra bool     `json:"detectExtra,omitempty"`             // also report live objects missing from Git
+}
+
+// Source is the rendered revision of a repository
+type Source struct {
+	Path     string   `json:"path"`
+	Ref      string   `json:"ref,omitempty"`
+	Commit   string   `json:"commit,omitempty"`
+	Bare     bool     `json:"bare"`
+	Dir      string   `json:"dir,omitempty"`
+	Objects  int      `json:"objects"`
+	Warnings []string `json:"warnings,omitempty"` // files that could not be rendered
+}
+
+// ResourceDrift compares one object in Git with the cluster
+type ResourceDrift struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Namespace  string                `json:"namespace,omitempty"`
+	Name       string                `json:"name"`
+	File       string                `json:"file,omitempty"` // where the object is defined, relative to the repository
+	Status     string                `json:"status"`
+	Diffs      []manifests.FieldDiff `json:"diffs,omitempty"` // from Git to the cluster
+	Error      string                `json:"error,omitempty"`
+}
+
+// DriftSummary counts resources by status
+type DriftSummary struct {
+	InSync   int `json:"inSync"`
+	Modified int `json:"modified"`
+	Missing  int `json:"missing"`
+	Extra    int `json:"extra"`
+	Unknown  int `json:"unknown"`
+}
+
+// ClusterDrift is the drift of one cluster from the repository
+type ClusterDrift struct {
+	Context   string          `json:"context"`
+	Summary   DriftSummary    `json:"summary"`
+	Resources []ResourceDrift `json:"resources"`
+	Error     string          `json:"error,omitempty"` // the cluster could not be reached
+}
+
+// DriftReport compares a repository revision with every requested cluster
+type DriftReport struct {
+	Source      Source         `json:"source"`
+	Clusters    []ClusterDrift `json:"clusters"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+}
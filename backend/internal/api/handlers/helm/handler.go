@@ -0,0 +1,141 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic"
+)
+
+var clusterManager *kubernetes.ClusterManager
+
+// Initialize sets up the Helm handlers with the cluster manager
+func Initialize(cm *kubernetes.ClusterManager) {
+	clusterManager = cm
+}
+
+// ListReleases lists the latest revision of every release
+// GET /api/v1/helm/:context/releases?namespace=shop
+func ListReleases(c *gin.Context) {
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	releases, err := service.List(c.Request.Context(), c.Query("namespace"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"releases": releases})
+}
+
+// GetRelease returns a revision of a release with its manifest, values, history and live objects
+// GET /api/v1/helm/:context/releases/:namespace/:name?revision=3
+func GetRelease(c *gin.Context) {
+	revision, err := revisionParam(c, "revision")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	release, err := service.Get(c.Request.Context(), c.Param("namespace"), c.Param("name"), revision)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, release)
+}
+
+// GetHistory lists the revisions of a release
+// GET /api/v1/helm/:context/releases/:namespace/:name/history
+func GetHistory(c *gin.Context) {
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	history, err := service.History(c.Request.Context(), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetDiff diffs the manifests and values of two revisions
+// GET /api/v1/helm/:context/releases/:namespace/:name/diff?from=1&to=3
+// to defaults to the latest revision and from to the revision before to
+func GetDiff(c *gin.Context) {
+	from, err := revisionParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := revisionParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	diff, err := service.Diff(c.Request.Context(), c.Param("namespace"), c.Param("name"), from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func serviceFor(c *gin.Context) (*Service, bool) {
+	if clusterManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return nil, false
+	}
+	context := c.Param("context")
+	conn, err := clusterManager.GetConnection(context)
+	if err != nil || conn == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Cluster %s not connected", context)})
+		return nil, false
+	}
+	dynamicClient, err := dynamic.NewForConfig(conn.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create dynamic client: %v", err)})
+		return nil, false
+	}
+	return NewService(conn.ClientSet, dynamicClient), true
+}
+
+func revisionParam(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 0 {
+		return 0, fmt.Errorf("%s must be a revision number", name)
+	}
+	return revision, nil
+}
+
+func respondError(c *gin.Context, err error) {
+	code := http.StatusInternalServerError
+	var status apierrors.APIStatus
+	switch {
+	case errors.Is(err, errNoPreviousRevision):
+		code = http.StatusBadRequest
+	case errors.Is(err, errReleaseNotFound), errors.Is(err, errRevisionNotFound):
+		code = http.StatusNotFound
+	case errors.As(err, &status) && status.Status().Code != 0:
+		code = int(status.Status().Code)
+	}
+	c.JSON(code, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,180 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"sigs.k8s.io/yaml"
+)
+
+// releaseSecretType is the type of the Secrets Helm 3 stores releases in
+const releaseSecretType = "helm.sh/release.v1"
+
+// maxReleaseRecord bounds the decompressed size of a release record, so a small
+// crafted record cannot expand into gigabytes
+const maxReleaseRecord = 64 << 20
+
+// secretKindLine finds Secret documents that could not be parsed
+var secretKindLine = regexp.MustCompile(`(?m)^kind:\s*["']?Secret["']?\s*$`)
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// storedRelease is the part of a Helm 3 release record that Kaptivan reads
+type storedRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		FirstDeployed releaseTime `json:"first_deployed"`
+		LastDeployed  releaseTime `json:"last_deployed"`
+		Description   string      `json:"description"`
+		Status        string      `json:"status"`
+		Notes         string      `json:"notes"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Config   map[string]interface{} `json:"config"`
+	Manifest string                 `json:"manifest"`
+}
+
+// releaseTime accepts the empty string Helm writes for unset times
+type releaseTime struct {
+	time.Time
+}
+
+func (t *releaseTime) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` || string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &t.Time)
+}
+
+// decodeRelease reverses Helm's encoding of a release record: JSON, gzipped, then base64
+func decodeRelease(data string) (*storedRelease, error) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid release encoding: %v", err)
+	}
+	if bytes.HasPrefix(raw, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid release compression: %v", err)
+		}
+		defer reader.Close()
+		if raw, err = io.ReadAll(io.LimitReader(reader, maxReleaseRecord+1)); err != nil {
+			return nil, fmt.Errorf("invalid release compression: %v", err)
+		}
+		if len(raw) > maxReleaseRecord {
+			return nil, fmt.Errorf("release record exceeds %d MiB decompressed", maxReleaseRecord>>20)
+		}
+	}
+	var release storedRelease
+	if err := json.Unmarshal(raw, &release); err != nil {
+		return nil, fmt.Errorf("invalid release record: %v", err)
+	}
+	return &release, nil
+}
+
+func (r *storedRelease) revision() Revision {
+	return Revision{
+		Revision:     r.Version,
+		Status:       r.Info.Status,
+		Chart:        r.Chart.Metadata.Name,
+		ChartVersion: r.Chart.Metadata.Version,
+		AppVersion:   r.Chart.Metadata.AppVersion,
+		Updated:      r.Info.LastDeployed.Time,
+		Description:  r.Info.Description,
+	}
+}
+
+// maskManifestSecrets replaces the data and stringData values of every Secret in a
+// rendered manifest with mask(key, value), where key names the Secret, field and data
+// key. Other documents are kept as they are; a Secret that cannot be parsed is hidden.
+func maskManifestSecrets(manifest string, mask func(key, value string) string) string {
+	documents := splitManifest(manifest)
+	for i, document := range documents {
+		documents[i] = maskSecretDocument(document, mask)
+	}
+	return strings.Join(documents, "")
+}
+
+// splitManifest splits a multi-document manifest before each --- separator line
+func splitManifest(manifest string) []string {
+	var (
+		documents []string
+		current   strings.Builder
+	)
+	for _, line := range strings.SplitAfter(manifest, "\n") {
+		if strings.TrimSpace(line) == "---" && current.Len() > 0 {
+			documents = append(documents, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		documents = append(documents, current.String())
+	}
+	return documents
+}
+
+// maskSecretDocument masks one document if it is a Secret, keeping the separator
+// and the comments Helm writes above it
+func maskSecretDocument(document string, mask func(key, value string) string) string {
+	lines := strings.SplitAfter(document, "\n")
+	header := 0
+	for header < len(lines) {
+		trimmed := strings.TrimSpace(lines[header])
+		if trimmed != "---" && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		header++
+	}
+	prefix := strings.Join(lines[:header], "")
+
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(document), &obj); err != nil {
+		if secretKindLine.MatchString(document) {
+			return prefix + "# Secret hidden: the manifest could not be parsed\n"
+		}
+		return document
+	}
+	if kind, _ := obj["kind"].(string); kind != "Secret" {
+		return document
+	}
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range data {
+			data[key] = mask(namespace+"/"+name+"/"+field+"/"+key, fmt.Sprint(value))
+		}
+	}
+	masked, err := yaml.Marshal(obj)
+	if err != nil {
+		return prefix + "# Secret hidden: the manifest could not be parsed\n"
+	}
+	return prefix + string(masked)
+}
+
+// maskAll masks every Secret value the same way
+func maskAll(key, value string) string {
+	return manifests.MaskedValue
+}
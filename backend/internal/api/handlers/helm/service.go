@@ -0,0 +1,377 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// releaseLabels match the Secrets and ConfigMaps Helm stores releases in
+var releaseLabels = labels.Set{"owner": "helm"}
+
+var (
+	errReleaseNotFound    = errors.New("release not found")
+	errRevisionNotFound   = errors.New("revision not found")
+	errNoPreviousRevision = errors.New("no previous revision to compare with")
+)
+
+// Service reads Helm releases from the records Helm keeps in the cluster, without the Helm binary
+type Service struct {
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+}
+
+// NewService creates a Helm release service
+func NewService(clientset kubernetes.Interface, dynamicClient dynamic.Interface) *Service {
+	return &Service{clientset: clientset, dynamic: dynamicClient}
+}
+
+// rawRecord is a release record before decoding; the labels Helm sets identify it
+type rawRecord struct {
+	name      string
+	namespace string
+	version   int
+	data      string
+	storage   string
+}
+
+// List returns the latest revision of every release in namespace, or in all namespaces when empty
+func (s *Service) List(ctx context.Context, namespace string) ([]ReleaseSummary, error) {
+	records, err := s.rawRecords(ctx, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]rawRecord)
+	for _, record := range records {
+		key := record.namespace + "/" + record.name
+		if current, ok := latest[key]; !ok || record.version > current.version {
+			latest[key] = record
+		}
+	}
+
+	releases := make([]ReleaseSummary, 0, len(latest))
+	for _, record := range latest {
+		release, err := decodeRelease(record.data)
+		if err != nil {
+			log.Printf("Skipping Helm release %s/%s revision %d: %v", record.namespace, record.name, record.version, err)
+			continue
+		}
+		releases = append(releases, summary(release, record))
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Namespace != releases[j].Namespace {
+			return releases[i].Namespace < releases[j].Namespace
+		}
+		return releases[i].Name < releases[j].Name
+	})
+	return releases, nil
+}
+
+// History lists the revisions of a release, oldest first
+func (s *Service) History(ctx context.Context, namespace, name string) (*History, error) {
+	revisions, _, err := s.revisions(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	history := &History{Name: name, Namespace: namespace, Revisions: make([]Revision, 0, len(revisions))}
+	for _, release := range revisions {
+		history.Revisions = append(history.Revisions, release.revision())
+	}
+	history.CurrentRevision = revisions[len(revisions)-1].Version
+	return history, nil
+}
+
+// Get returns a revision of a release, the latest when revision is 0, with the live
+// state of every object its manifest renders
+func (s *Service) Get(ctx context.Context, namespace, name string, revision int) (*Release, error) {
+	revisions, records, err := s.revisions(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	index := len(revisions) - 1
+	if revision != 0 {
+		if index = findRevision(revisions, revision); index < 0 {
+			return nil, errRevisionNotFound
+		}
+	}
+	stored := revisions[index]
+
+	release := &Release{
+		ReleaseSummary: summary(stored, records[index]),
+		FirstDeployed:  stored.Info.FirstDeployed.Time,
+		Description:    stored.Info.Description,
+		Notes:          stored.Info.Notes,
+		Values:         stored.Config,
+		Manifest:       maskManifestSecrets(stored.Manifest, maskAll),
+		Resources:      s.ownedResources(ctx, stored.Manifest, namespace),
+		History:        make([]Revision, 0, len(revisions)),
+	}
+	if release.Values == nil {
+		release.Values = map[string]interface{}{}
+	}
+	for _, r := range revisions {
+		release.History = append(release.History, r.revision())
+	}
+	return release, nil
+}
+
+// Diff compares the manifests and user values of two revisions. to defaults to the
+// latest revision and from to the revision before to.
+func (s *Service) Diff(ctx context.Context, namespace, name string, from, to int) (*RevisionDiff, error) {
+	revisions, _, err := s.revisions(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	toIndex := len(revisions) - 1
+	if to != 0 {
+		if toIndex = findRevision(revisions, to); toIndex < 0 {
+			return nil, errRevisionNotFound
+		}
+	}
+	fromIndex := toIndex - 1
+	if from != 0 {
+		if fromIndex = findRevision(revisions, from); fromIndex < 0 {
+			return nil, errRevisionNotFound
+		}
+	} else if fromIndex < 0 {
+		return nil, errNoPreviousRevision
+	}
+	before, after := revisions[fromIndex], revisions[toIndex]
+
+	result := &RevisionDiff{Name: name, Namespace: namespace, From: before.Version, To: after.Version}
+	// Secret values are masked on both sides; a value that changed is marked so the
+	// change still shows in the diff
+	beforeSecrets := make(map[string]string)
+	beforeManifest := maskManifestSecrets(before.Manifest, func(key, value string) string {
+		beforeSecrets[key] = value
+		return manifests.MaskedValue
+	})
+	afterManifest := maskManifestSecrets(after.Manifest, func(key, value string) string {
+		if previous, ok := beforeSecrets[key]; ok && previous != value {
+			return manifests.MaskedValue + " (changed)"
+		}
+		return manifests.MaskedValue
+	})
+	if result.ManifestDiff, err = unifiedDiff(beforeManifest, afterManifest, before.Version, after.Version); err != nil {
+		return nil, err
+	}
+	beforeValues, err := valuesYAML(before.Config)
+	if err != nil {
+		return nil, err
+	}
+	afterValues, err := valuesYAML(after.Config)
+	if err != nil {
+		return nil, err
+	}
+	if result.ValuesDiff, err = unifiedDiff(beforeValues, afterValues, before.Version, after.Version); err != nil {
+		return nil, err
+	}
+	result.Identical = result.ManifestDiff == "" && result.ValuesDiff == ""
+	return result, nil
+}
+
+// rawRecords lists the release records of a namespace, optionally of one release
+func (s *Service) rawRecords(ctx context.Context, namespace, name string) ([]rawRecord, error) {
+	set := labels.Merge(releaseLabels, nil)
+	if name != "" {
+		set["name"] = name
+	}
+	selector, err := labels.ValidatedSelectorFromSet(set)
+	if err != nil {
+		return nil, errReleaseNotFound // not a valid release name
+	}
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+
+	var records []rawRecord
+	secrets, err := s.clientset.CoreV1().Secrets(namespace).List(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list release secrets: %w", err)
+	}
+	for _, secret := range secrets.Items {
+		if secret.Type != releaseSecretType {
+			continue
+		}
+		version, _ := strconv.Atoi(secret.Labels["version"])
+		records = append(records, rawRecord{
+			name:      secret.Labels["name"],
+			namespace: secret.Namespace,
+			version:   version,
+			data:      string(secret.Data["release"]),
+			storage:   StorageSecret,
+		})
+	}
+
+	configMaps, err := s.clientset.CoreV1().ConfigMaps(namespace).List(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list release configmaps: %w", err)
+	}
+	for _, configMap := range configMaps.Items {
+		data, ok := configMap.Data["release"]
+		if !ok {
+			continue
+		}
+		version, _ := strconv.Atoi(configMap.Labels["version"])
+		records = append(records, rawRecord{
+			name:      configMap.Labels["name"],
+			namespace: configMap.Namespace,
+			version:   version,
+			data:      data,
+			storage:   StorageConfigMap,
+		})
+	}
+	return records, nil
+}
+
+// revisions decodes every revision of a release, oldest first, with the records they came from
+func (s *Service) revisions(ctx context.Context, namespace, name string) ([]*storedRelease, []rawRecord, error) {
+	records, err := s.rawRecords(ctx, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].version < records[j].version })
+
+	var (
+		revisions []*storedRelease
+		decoded   []rawRecord
+	)
+	for _, record := range records {
+		release, err := decodeRelease(record.data)
+		if err != nil {
+			log.Printf("Skipping Helm release %s/%s revision %d: %v", namespace, name, record.version, err)
+			continue
+		}
+		revisions = append(revisions, release)
+		decoded = append(decoded, record)
+	}
+	if len(revisions) == 0 {
+		return nil, nil, errReleaseNotFound
+	}
+	return revisions, decoded, nil
+}
+
+func findRevision(revisions []*storedRelease, revision int) int {
+	for i, release := range revisions {
+		if release.Version == revision {
+			return i
+		}
+	}
+	return -1
+}
+
+func summary(release *storedRelease, record rawRecord) ReleaseSummary {
+	revision := release.revision()
+	return ReleaseSummary{
+		Name:         release.Name,
+		Namespace:    record.namespace, // Helm stores a release in its own namespace
+		Revision:     revision.Revision,
+		Status:       revision.Status,
+		Chart:        revision.Chart,
+		ChartVersion: revision.ChartVersion,
+		AppVersion:   revision.AppVersion,
+		Updated:      revision.Updated,
+		Storage:      record.storage,
+	}
+}
+
+// ownedResources looks up every object in a release manifest. Objects without a
+// namespace are installed in the release namespace unless they are cluster-scoped.
+func (s *Service) ownedResources(ctx context.Context, manifest, namespace string) []OwnedResource {
+	resources := []OwnedResource{}
+	objects, err := manifests.DecodeManifest([]byte(manifest))
+	if err != nil {
+		return resources
+	}
+
+	discovered := make(map[string]*metav1.APIResourceList)
+	for _, obj := range objects {
+		resource := OwnedResource{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		}
+		if resource.Kind == "" || resource.Name == "" {
+			continue
+		}
+
+		gv, err := schema.ParseGroupVersion(resource.APIVersion)
+		if err != nil {
+			resource.Error = fmt.Sprintf("invalid apiVersion: %s", resource.APIVersion)
+			resources = append(resources, resource)
+			continue
+		}
+		list, ok := discovered[gv.String()]
+		if !ok {
+			list, _ = s.clientset.Discovery().ServerResourcesForGroupVersion(gv.String())
+			discovered[gv.String()] = list
+		}
+		gvr, namespaced, found := findKind(list, gv, resource.Kind)
+		if !found {
+			resource.Error = fmt.Sprintf("resource with kind %s not found in %s", resource.Kind, resource.APIVersion)
+			resources = append(resources, resource)
+			continue
+		}
+		if !namespaced {
+			resource.Namespace = ""
+		} else if resource.Namespace == "" {
+			resource.Namespace = namespace
+		}
+
+		_, err = s.dynamic.Resource(gvr).Namespace(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			resource.Exists = true
+		case !apierrors.IsNotFound(err):
+			resource.Error = err.Error()
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
+func findKind(list *metav1.APIResourceList, gv schema.GroupVersion, kind string) (schema.GroupVersionResource, bool, bool) {
+	if list == nil {
+		return schema.GroupVersionResource{}, false, false
+	}
+	for _, resource := range list.APIResources {
+		if resource.Kind == kind && !strings.Contains(resource.Name, "/") {
+			return gv.WithResource(resource.Name), resource.Namespaced, true
+		}
+	}
+	return schema.GroupVersionResource{}, false, false
+}
+
+func valuesYAML(values map[string]interface{}) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	data, err := yaml.Marshal(values)
+	return string(data), err
+}
+
+func unifiedDiff(before, after string, from, to int) (string, error) {
+	if before == after {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: fmt.Sprintf("revision %d", from),
+		ToFile:   fmt.Sprintf("revision %d", to),
+		Context:  3,
+	})
+}
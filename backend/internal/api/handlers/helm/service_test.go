@@ -0,0 +1,259 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const webManifest = `---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: %d
+`
+
+// encodeRelease encodes a release record the way Helm does: JSON, gzipped, then base64
+func encodeRelease(t *testing.T, record map[string]interface{}) string {
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return base64.StdEncoding.EncodeToString(compressed.Bytes())
+}
+
+func releaseRecord(name string, version int, status, chartVersion string, replicas int, values map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      name,
+		"namespace": "shop",
+		"version":   version,
+		"info": map[string]interface{}{
+			"first_deployed": "2026-01-01T10:00:00Z",
+			"last_deployed":  fmt.Sprintf("2026-01-0%dT10:00:00Z", version),
+			"deleted":        "",
+			"status":         status,
+			"description":    "Upgrade complete",
+			"notes":          "Visit http://web",
+		},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "version": chartVersion, "appVersion": "2.0"},
+			"values":   map[string]interface{}{"replicas": 1},
+		},
+		"config":   values,
+		"manifest": fmt.Sprintf(webManifest, replicas),
+	}
+}
+
+func releaseSecret(t *testing.T, record map[string]interface{}) *corev1.Secret {
+	name, version := record["name"].(string), record["version"].(int)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, version),
+			Namespace: "shop",
+			Labels:    map[string]string{"owner": "helm", "name": name, "version": fmt.Sprint(version), "status": record["info"].(map[string]interface{})["status"].(string)},
+		},
+		Type: releaseSecretType,
+		Data: map[string][]byte{"release": []byte(encodeRelease(t, record))},
+	}
+}
+
+func newTestService(t *testing.T) *Service {
+	clientset := fake.NewSimpleClientset(
+		releaseSecret(t, releaseRecord("web", 1, "superseded", "1.0.0", 2, map[string]interface{}{"replicas": 2})),
+		releaseSecret(t, releaseRecord("web", 2, "deployed", "1.1.0", 3, map[string]interface{}{"replicas": 3})),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker.v1", Namespace: "batch",
+				Labels: map[string]string{"owner": "helm", "name": "worker", "version": "1"},
+			},
+			Data: map[string]string{"release": encodeRelease(t, releaseRecord("worker", 1, "failed", "0.3.0", 1, nil))},
+		},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "shop", Labels: map[string]string{"owner": "helm"}}},
+	)
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "services", Kind: "Service", Namespaced: true}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments/status", Kind: "Deployment", Namespaced: true},
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+		}},
+	}
+	// Only the Deployment is live; the Service was deleted by hand
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+	}})
+	return NewService(clientset, dynamicClient)
+}
+
+func TestListReleases(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	releases, err := service.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, releases, 2)
+	assert.Equal(t, ReleaseSummary{
+		Name: "worker", Namespace: "batch", Revision: 1, Status: "failed", Chart: "web", ChartVersion: "0.3.0",
+		AppVersion: "2.0", Updated: releases[0].Updated, Storage: StorageConfigMap,
+	}, releases[0])
+	assert.Equal(t, "web", releases[1].Name)
+	assert.Equal(t, 2, releases[1].Revision)
+	assert.Equal(t, "deployed", releases[1].Status)
+	assert.Equal(t, "1.1.0", releases[1].ChartVersion)
+	assert.Equal(t, StorageSecret, releases[1].Storage)
+	assert.Equal(t, 2, releases[1].Updated.Day())
+
+	releases, err = service.List(ctx, "shop")
+	require.NoError(t, err)
+	assert.Len(t, releases, 1)
+}
+
+func TestGetReleaseAndHistory(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	release, err := service.Get(ctx, "shop", "web", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, release.Revision)
+	assert.Equal(t, map[string]interface{}{"replicas": float64(3)}, release.Values)
+	assert.Contains(t, release.Manifest, "replicas: 3")
+	assert.Equal(t, "Visit http://web", release.Notes)
+	assert.Equal(t, []OwnedResource{
+		{APIVersion: "v1", Kind: "Service", Namespace: "shop", Name: "web", Exists: false},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "shop", Name: "web", Exists: true},
+	}, release.Resources)
+	require.Len(t, release.History, 2)
+	assert.Equal(t, "superseded", release.History[0].Status)
+
+	release, err = service.Get(ctx, "shop", "web", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", release.ChartVersion)
+
+	history, err := service.History(ctx, "shop", "web")
+	require.NoError(t, err)
+	assert.Equal(t, 2, history.CurrentRevision)
+	assert.Equal(t, []int{1, 2}, []int{history.Revisions[0].Revision, history.Revisions[1].Revision})
+
+	_, err = service.Get(ctx, "shop", "web", 7)
+	assert.ErrorIs(t, err, errRevisionNotFound)
+	_, err = service.History(ctx, "shop", "missing")
+	assert.ErrorIs(t, err, errReleaseNotFound)
+	_, err = service.History(ctx, "shop", "web,owner!=helm")
+	assert.ErrorIs(t, err, errReleaseNotFound)
+}
+
+func TestDiffRevisions(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	diff, err := service.Diff(ctx, "shop", "web", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 2, diff.To)
+	assert.False(t, diff.Identical)
+	assert.Contains(t, diff.ManifestDiff, "-  replicas: 2\n+  replicas: 3\n")
+	assert.Contains(t, diff.ValuesDiff, "-replicas: 2\n+replicas: 3\n")
+
+	diff, err = service.Diff(ctx, "shop", "web", 2, 2)
+	require.NoError(t, err)
+	assert.True(t, diff.Identical)
+
+	_, err = service.Diff(ctx, "batch", "worker", 0, 0)
+	assert.ErrorIs(t, err, errNoPreviousRevision)
+}
+
+func TestDecodeRelease(t *testing.T) {
+	// Records written without compression still decode
+	plain := base64.StdEncoding.EncodeToString([]byte(`{"name":"web","version":4,"info":{"status":"deployed","last_deployed":""}}`))
+	release, err := decodeRelease(plain)
+	require.NoError(t, err)
+	assert.Equal(t, 4, release.Version)
+	assert.True(t, release.Info.LastDeployed.IsZero())
+
+	_, err = decodeRelease("not base64!")
+	assert.Error(t, err)
+}
+
+func TestDecodeReleaseSizeCap(t *testing.T) {
+	// A small record that expands past the cap is rejected rather than read into memory
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(bytes.Repeat([]byte(" "), maxReleaseRecord+1))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	_, err = decodeRelease(base64.StdEncoding.EncodeToString(compressed.Bytes()))
+	assert.ErrorContains(t, err, "exceeds")
+}
+
+func TestReleaseSecretsMasked(t *testing.T) {
+	const credsManifest = `---
+# Source: creds/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  password: %s
+  user: YWRtaW4=
+stringData:
+  token: %s
+`
+	records := []map[string]interface{}{
+		releaseRecord("creds", 1, "superseded", "1.0.0", 1, nil),
+		releaseRecord("creds", 2, "deployed", "1.0.0", 1, nil),
+	}
+	records[0]["manifest"] = fmt.Sprintf(credsManifest, "b2xk", "first-token") + fmt.Sprintf(webManifest, 1)
+	records[1]["manifest"] = fmt.Sprintf(credsManifest, "bmV3", "first-token") + fmt.Sprintf(webManifest, 1)
+	clientset := fake.NewSimpleClientset(releaseSecret(t, records[0]), releaseSecret(t, records[1]))
+	service := NewService(clientset, dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	ctx := context.Background()
+
+	release, err := service.Get(ctx, "shop", "creds", 0)
+	require.NoError(t, err)
+	for _, value := range []string{"bmV3", "YWRtaW4=", "first-token"} {
+		assert.NotContains(t, release.Manifest, value)
+	}
+	assert.Contains(t, release.Manifest, "# Source: creds/templates/secret.yaml")
+	assert.Contains(t, release.Manifest, "password: '"+manifests.MaskedValue+"'")
+	assert.Contains(t, release.Manifest, "replicas: 1", "other documents are kept")
+
+	diff, err := service.Diff(ctx, "shop", "creds", 0, 0)
+	require.NoError(t, err)
+	for _, value := range []string{"b2xk", "bmV3", "YWRtaW4=", "first-token"} {
+		assert.NotContains(t, diff.ManifestDiff, value)
+	}
+	assert.Contains(t, diff.ManifestDiff, "+  password: '"+manifests.MaskedValue+" (changed)'")
+	assert.NotContains(t, diff.ManifestDiff, "+  user:", "unchanged values do not show as changed")
+}
@@ -0,0 +1,76 @@
+package helm
+
+import (
+	"time"
+)
+
+// Where Helm stores release records
+const (
+	StorageSecret    = "secret"
+	StorageConfigMap = "configmap"
+)
+
+// ReleaseSummary is the latest revision of a release
+type ReleaseSummary struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"` // deployed, failed, pending-upgrade and so on
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+	Updated      time.Time `json:"updated"`
+	Storage      string    `json:"storage"`
+}
+
+// Revision is one entry in a release's history
+type Revision struct {
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+	Updated      time.Time `json:"updated"`
+	Description  string    `json:"description,omitempty"` // e.g. Install complete, Upgrade complete
+}
+
+// History lists a release's revisions, oldest first
+type History struct {
+	Name            string     `json:"name"`
+	Namespace       string     `json:"namespace"`
+	CurrentRevision int        `json:"currentRevision"`
+	Revisions       []Revision `json:"revisions"`
+}
+
+// OwnedResource is an object rendered by a release and whether it exists in the cluster
+type OwnedResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Exists     bool   `json:"exists"`
+	Error      string `json:"error,omitempty"` // the object could not be looked up
+}
+
+// Release is one revision of a release with its manifest, values and live objects
+type Release struct {
+	ReleaseSummary
+	FirstDeployed time.Time              `json:"firstDeployed"`
+	Description   string                 `json:"description,omitempty"`
+	Notes         string                 `json:"notes,omitempty"`
+	Values        map[string]interface{} `json:"values"` // user-supplied values, without chart defaults
+	Manifest      string                 `json:"manifest"`
+	Resources     []OwnedResource        `json:"resources"`
+	History       []Revision             `json:"history"`
+}
+
+// RevisionDiff is a unified diff of the manifests and values of two revisions
+type RevisionDiff struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	From         int    `json:"from"`
+	To           int    `json:"to"`
+	Identical    bool   `json:"identical"`
+	ManifestDiff string `json:"manifestDiff,omitempty"`
+	ValuesDiff   string `json:"valuesDiff,omitempty"`
+}
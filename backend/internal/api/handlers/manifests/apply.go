@@ -0,0 +1,399 @@
+package manifests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// FieldManager is the server-side apply field manager used for every change made by Kaptivan
+const FieldManager = "kaptivan"
+
+// maxApplyBody bounds the size of a manifest sent to the apply endpoint
+const maxApplyBody = 10 << 20
+
+// Apply actions reported per object
+const (
+	ApplyActionCreated    = "created"
+	ApplyActionConfigured = "configured"
+	ApplyActionUnchanged  = "unchanged"
+	ApplyActionConflict   = "conflict"
+	ApplyActionFailed     = "failed"
+)
+
+// ApplyOptions controls how manifests are applied
+type ApplyOptions struct {
+	Namespace string // for namespaced objects without metadata.namespace; default "default"
+	DryRun    bool   // dryRun=All: nothing is persisted and a diff is returned
+	Force     bool   // take ownership of fields managed by other field managers
+}
+
+// ApplyConflict is a field owned by another field manager
+type ApplyConflict struct {
+	Field   string `json:"field"`
+	Manager string `json:"manager,omitempty"`
+	Message string `json:"message"`
+}
+
+// ApplyResult is the outcome of applying one document
+type ApplyResult struct {
+	Index      int             `json:"index"` // position of the object in the manifest
+	APIVersion string          `json:"apiVersion,omitempty"`
+	Kind       string          `json:"kind,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Namespace  string          `json:"namespace,omitempty"`
+	Resource   string          `json:"resource,omitempty"`
+	Action     string          `json:"action"`
+	Diff       string          `json:"diff,omitempty"` // unified diff against the live object, dry runs only
+	Conflicts  []ApplyConflict `json:"conflicts,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// ApplyResponse summarizes an apply request
+type ApplyResponse struct {
+	DryRun       bool          `json:"dryRun"`
+	Force        bool          `json:"force"`
+	FieldManager string        `json:"fieldManager"`
+	Results      []ApplyResult `json:"results"`
+	Succeeded    int           `json:"succeeded"`
+	Failed       int           `json:"failed"`
+}
+
+// resourceResolver maps a kind and apiVersion to its resource and scope
+type resourceResolver func(kind, apiVersion string) (schema.GroupVersionResource, bool, error)
+
+// conflictManager extracts the manager from a conflict message such as
+// `conflict with "kubectl-client-side-apply" using apps/v1`
+var conflictManager = regexp.MustCompile(`conflict with "([^"]+)"`)
+
+// ApplyManifest applies multi-document YAML with server-side apply
+// POST /api/v1/manifests/apply?context=...&namespace=...&dryRun=All&force=true
+// The body is the manifest; a List kind is expanded into its items.
+func ApplyManifest(c *gin.Context) {
+	clusterContext := c.Query("context")
+	if clusterContext == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context is required"})
+		return
+	}
+
+	opts := ApplyOptions{Namespace: c.Query("namespace")}
+	switch dryRun := c.Query("dryRun"); dryRun {
+	case "":
+	case "All":
+		opts.DryRun = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported dryRun value %q: only All is supported", dryRun)})
+		return
+	}
+	if force := c.Query("force"); force != "" {
+		var err error
+		if opts.Force, err = strconv.ParseBool(force); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "force must be true or false"})
+			return
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxApplyBody+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read manifest: %v", err)})
+		return
+	}
+	if len(body) > maxApplyBody {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "manifest is larger than 10MiB"})
+		return
+	}
+	objects, err := DecodeManifest(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(objects) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "manifest contains no objects"})
+		return
+	}
+
+	conn, err := clusterManager.GetConnection(clusterContext)
+	if err != nil || conn == nil {
+		errMsg := "cluster not connected"
+		if err != nil {
+			errMsg = fmt.Sprintf("cluster not connected: %v", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg, "context": clusterContext})
+		return
+	}
+	dynamicClient, err := dynamic.NewForConfig(conn.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create dynamic client: %v", err)})
+		return
+	}
+
+	resolve := func(kind, apiVersion string) (schema.GroupVersionResource, bool, error) {
+		gvr, err := findResourceByKind(conn, kind, apiVersion)
+		if err != nil {
+			return gvr, false, err
+		}
+		namespaced, err := isResourceNamespaced(conn, gvr)
+		return gvr, namespaced, err
+	}
+
+	response := applyObjects(c.Request.Context(), dynamicClient, resolve, objects, opts)
+	c.JSON(response.httpStatus(), response)
+}
+
+// httpStatus is 200 when every object applied, 207 when some failed, and 409 or 422
+// when none applied, depending on whether field manager conflicts were the cause
+func (r *ApplyResponse) httpStatus() int {
+	switch {
+	case r.Failed == 0:
+		return http.StatusOK
+	case r.Succeeded > 0:
+		return http.StatusMultiStatus
+	}
+	for _, result := range r.Results {
+		if result.Action != ApplyActionConflict {
+			return http.StatusUnprocessableEntity
+		}
+	}
+	return http.StatusConflict
+}
+
+// DecodeManifest splits a multi-document YAML or JSON manifest into objects, expanding Lists
+func DecodeManifest(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objects []*unstructured.Unstructured
+	for document := 1; ; document++ {
+		var raw map[string]interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("document %d: invalid YAML: %v", document, err)
+		}
+		if len(raw) == 0 {
+			continue // empty document, e.g. a trailing ---
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.IsList() {
+			if err := obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			}); err != nil {
+				return nil, fmt.Errorf("document %d: invalid list: %v", document, err)
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// applyObjects applies each object in order; a failed object does not stop the rest
+func applyObjects(ctx context.Context, client dynamic.Interface, resolve resourceResolver, objects []*unstructured.Unstructured, opts ApplyOptions) *ApplyResponse {
+	response := &ApplyResponse{
+		DryRun:       opts.DryRun,
+		Force:        opts.Force,
+		FieldManager: FieldManager,
+		Results:      make([]ApplyResult, 0, len(objects)),
+	}
+	for i, obj := range objects {
+		result := applyObject(ctx, client, resolve, obj, opts)
+		result.Index = i
+		if result.Error != "" {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response
+}
+
+// applyObject server-side applies one object and reports what changed
+func applyObject(ctx context.Context, client dynamic.Interface, resolve resourceResolver, obj *unstructured.Unstructured, opts ApplyOptions) ApplyResult {
+	result := ApplyResult{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+		Action:     ApplyActionFailed,
+	}
+	if result.APIVersion == "" || result.Kind == "" {
+		result.Error = "apiVersion and kind are required"
+		return result
+	}
+	if result.Name == "" {
+		result.Error = "metadata.name is required; generateName is not supported by server-side apply"
+		return result
+	}
+
+	gvr, namespaced, err := resolve(result.Kind, result.APIVersion)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to find resource: %v", err)
+		return result
+	}
+	result.Resource = gvr.Resource
+
+	var resource dynamic.ResourceInterface = client.Resource(gvr)
+	if namespaced {
+		if result.Namespace == "" {
+			result.Namespace = opts.Namespace
+			if result.Namespace == "" {
+				result.Namespace = metav1.NamespaceDefault
+			}
+			obj.SetNamespace(result.Namespace)
+		}
+		resource = client.Resource(gvr).Namespace(result.Namespace)
+	} else if result.Namespace != "" {
+		// Cluster-scoped objects ignore metadata.namespace, as kubectl does
+		obj.SetNamespace("")
+		result.Namespace = ""
+	}
+
+	live, err := resource.Get(ctx, result.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		result.Error = fmt.Sprintf("failed to get live object: %v", err)
+		return result
+	}
+
+	applyOptions := metav1.ApplyOptions{FieldManager: FieldManager, Force: opts.Force}
+	if opts.DryRun {
+		applyOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	applied, err := resource.Apply(ctx, result.Name, obj, applyOptions)
+	if err != nil {
+		if conflicts := applyConflicts(err); len(conflicts) > 0 {
+			result.Action = ApplyActionConflict
+			result.Conflicts = conflicts
+			result.Error = fmt.Sprintf("%d field(s) are managed by another field manager; re-apply with force=true to take ownership", len(conflicts))
+			return result
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	before, err := manifestYAML(live)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	after, err := manifestYAML(applied)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	switch {
+	case live == nil:
+		result.Action = ApplyActionCreated
+	case before == after:
+		result.Action = ApplyActionUnchanged
+	default:
+		result.Action = ApplyActionConfigured
+	}
+	if opts.DryRun && before != after {
+		result.Diff, err = maskedDiff(live, applied, objectPath(result))
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// applyConflicts returns the field manager conflicts reported by a failed apply
+func applyConflicts(err error) []ApplyConflict {
+	if !apierrors.IsConflict(err) {
+		return nil
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return []ApplyConflict{{Message: err.Error()}}
+	}
+
+	var conflicts []ApplyConflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := ApplyConflict{Field: cause.Field, Message: cause.Message}
+		if match := conflictManager.FindStringSubmatch(cause.Message); match != nil {
+			conflict.Manager = match[1]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	if len(conflicts) == 0 {
+		return []ApplyConflict{{Message: err.Error()}}
+	}
+	return conflicts
+}
+
+// manifestYAML renders an object for diffing, without server-managed metadata; nil renders empty
+func manifestYAML(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	clean := obj.DeepCopy()
+	cleanManifest(clean)
+	unstructured.RemoveNestedField(clean.Object, "metadata", "creationTimestamp")
+	data, err := yaml.Marshal(clean.Object)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert to YAML: %v", err)
+	}
+	return string(data), nil
+}
+
+// maskedDiff diffs the live and applied objects with Secret values masked
+func maskedDiff(live, applied *unstructured.Unstructured, path string) (string, error) {
+	live, applied = MaskSecretData(live, applied)
+	before, err := manifestYAML(live)
+	if err != nil {
+		return "", err
+	}
+	after, err := manifestYAML(applied)
+	if err != nil {
+		return "", err
+	}
+	diff, err := unifiedDiff(before, after, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff object: %v", err)
+	}
+	return diff, nil
+}
+
+// objectPath names an object in diff headers, e.g. apps/v1/deployments/shop/api
+func objectPath(result ApplyResult) string {
+	parts := []string{result.APIVersion, result.Resource}
+	if result.Namespace != "" {
+		parts = append(parts, result.Namespace)
+	}
+	return strings.Join(append(parts, result.Name), "/")
+}
+
+// unifiedDiff renders a unified diff between the live and applied manifests
+func unifiedDiff(before, after, path string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "live/" + path,
+		ToFile:   "applied/" + path,
+		Context:  3,
+	})
+}
@@ -0,0 +1,280 @@
+package manifests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	secretsGVR    = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+// testResolver resolves the kinds used in these tests
+func testResolver(kind, apiVersion string) (schema.GroupVersionResource, bool, error) {
+	switch kind {
+	case "ConfigMap":
+		return configMapsGVR, true, nil
+	case "Namespace":
+		return namespacesGVR, false, nil
+	case "Secret":
+		return secretsGVR, true, nil
+	}
+	return schema.GroupVersionResource{}, false, fmt.Errorf("resource with kind %s not found in %s", kind, apiVersion)
+}
+
+// fakeApplyClient wraps the fake dynamic client, which ignores apply options, to emulate
+// server-side apply: applied fields replace live ones, dry runs persist nothing, and
+// objects labelled locked conflict unless forced
+type fakeApplyClient struct {
+	*dynamicfake.FakeDynamicClient
+}
+
+func newFakeApplyClient(objects ...runtime.Object) fakeApplyClient {
+	return fakeApplyClient{dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)}
+}
+
+func (c fakeApplyClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return fakeApplyNamespaceable{c.FakeDynamicClient.Resource(gvr), fakeApplier{c.Tracker(), gvr, ""}}
+}
+
+type fakeApplyNamespaceable struct {
+	dynamic.NamespaceableResourceInterface
+	applier fakeApplier
+}
+
+func (r fakeApplyNamespaceable) Namespace(namespace string) dynamic.ResourceInterface {
+	return fakeApplyResource{r.NamespaceableResourceInterface.Namespace(namespace), fakeApplier{r.applier.tracker, r.applier.gvr, namespace}}
+}
+
+func (r fakeApplyNamespaceable) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.applier.apply(name, obj, options)
+}
+
+type fakeApplyResource struct {
+	dynamic.ResourceInterface
+	applier fakeApplier
+}
+
+func (r fakeApplyResource) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.applier.apply(name, obj, options)
+}
+
+type fakeApplier struct {
+	tracker   k8stesting.ObjectTracker
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+func (a fakeApplier) apply(name string, obj *unstructured.Unstructured, options metav1.ApplyOptions) (*unstructured.Unstructured, error) {
+	result := obj.DeepCopy()
+	existing, err := a.tracker.Get(a.gvr, a.namespace, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		live := existing.(*unstructured.Unstructured)
+		if live.GetLabels()["locked"] == "true" && !options.Force {
+			return nil, apierrors.NewApplyConflict([]metav1.StatusCause{{
+				Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.mode", Message: `conflict with "helm" using v1`,
+			}}, "Apply failed with 1 conflict")
+		}
+		result = live.DeepCopy()
+		for key, value := range obj.Object {
+			if key != "metadata" {
+				result.Object[key] = value
+			}
+		}
+	}
+
+	if len(options.DryRun) > 0 {
+		return result, nil
+	}
+	if existing == nil {
+		return result, a.tracker.Create(a.gvr, result, a.namespace)
+	}
+	return result, a.tracker.Update(a.gvr, result, a.namespace)
+}
+
+func TestDecodeManifest(t *testing.T) {
+	objects, err := DecodeManifest([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: b
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: c
+`))
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+	assert.Equal(t, "a", objects[0].GetName())
+	assert.Equal(t, "b", objects[1].GetName())
+	assert.Equal(t, "Namespace", objects[2].GetKind())
+
+	_, err = DecodeManifest([]byte("apiVersion: v1\nkind: [\n"))
+	assert.ErrorContains(t, err, "document 1")
+}
+
+func TestApplyObjects(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings", "namespace": "shop"},
+		"data":       map[string]interface{}{"mode": "slow"},
+	}}
+	client := newFakeApplyClient(live)
+
+	objects, err := DecodeManifest([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  mode: fast
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: payments
+  namespace: ignored
+---
+apiVersion: v1
+kind: Widget
+metadata:
+  name: unknown
+`))
+	require.NoError(t, err)
+
+	response := applyObjects(context.Background(), client, testResolver, objects, ApplyOptions{Namespace: "shop", DryRun: true})
+	assert.True(t, response.DryRun)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, 1, response.Failed)
+	assert.Equal(t, http.StatusMultiStatus, response.httpStatus())
+
+	configured := response.Results[0]
+	assert.Equal(t, ApplyActionConfigured, configured.Action)
+	assert.Equal(t, "shop", configured.Namespace)
+	assert.Contains(t, configured.Diff, "--- live/v1/configmaps/shop/settings")
+	assert.Contains(t, configured.Diff, "-  mode: slow")
+	assert.Contains(t, configured.Diff, "+  mode: fast")
+
+	created := response.Results[1]
+	assert.Equal(t, ApplyActionCreated, created.Action)
+	assert.Empty(t, created.Namespace, "cluster-scoped objects drop metadata.namespace")
+	assert.Contains(t, created.Diff, "+  name: payments")
+
+	assert.Equal(t, ApplyActionFailed, response.Results[2].Action)
+	assert.Contains(t, response.Results[2].Error, "kind Widget not found")
+
+	// The dry run changed nothing; a real apply does, and re-applying is a no-op
+	_, err = client.Resource(namespacesGVR).Get(context.Background(), "payments", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	response = applyObjects(context.Background(), client, testResolver, objects[:2], ApplyOptions{Namespace: "shop"})
+	assert.Equal(t, http.StatusOK, response.httpStatus())
+	assert.Empty(t, response.Results[0].Diff, "diffs are only returned for dry runs")
+	response = applyObjects(context.Background(), client, testResolver, objects[:2], ApplyOptions{Namespace: "shop"})
+	assert.Equal(t, ApplyActionUnchanged, response.Results[0].Action)
+	assert.Equal(t, ApplyActionUnchanged, response.Results[1].Action)
+}
+
+// TestApplyObjectsMasksSecrets verifies dry-run diffs of a Secret show which keys
+// change without showing any value from the cluster or the request
+func TestApplyObjectsMasksSecrets(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "db", "namespace": "shop"},
+		"data":       map[string]interface{}{"password": "b2xk", "user": "YWRtaW4="},
+	}}
+	client := newFakeApplyClient(live)
+	objects, err := DecodeManifest([]byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: shop
+data:
+  password: bmV3
+  user: YWRtaW4=
+stringData:
+  token: plain-token
+`))
+	require.NoError(t, err)
+
+	response := applyObjects(context.Background(), client, testResolver, objects, ApplyOptions{DryRun: true})
+	result := response.Results[0]
+	require.Equal(t, ApplyActionConfigured, result.Action, result.Error)
+	for _, value := range []string{"b2xk", "bmV3", "YWRtaW4=", "plain-token"} {
+		assert.NotContains(t, result.Diff, value)
+	}
+	assert.Contains(t, result.Diff, "-  password: '***'")
+	assert.Contains(t, result.Diff, "+  password: '*** (changed)'")
+	assert.Contains(t, result.Diff, "+  token: '***'")
+	assert.Contains(t, result.Diff, "\n   user: '***'\n", "unchanged keys are context, not changes")
+}
+
+func TestApplyObjectsConflict(t *testing.T) {
+	locked := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings", "namespace": "shop", "labels": map[string]interface{}{"locked": "true"}},
+		"data":       map[string]interface{}{"mode": "slow"},
+	}}
+	client := newFakeApplyClient(locked)
+	objects, err := DecodeManifest([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: shop\ndata:\n  mode: fast\n"))
+	require.NoError(t, err)
+
+	response := applyObjects(context.Background(), client, testResolver, objects, ApplyOptions{})
+	assert.Equal(t, http.StatusConflict, response.httpStatus())
+	result := response.Results[0]
+	assert.Equal(t, ApplyActionConflict, result.Action)
+	assert.Contains(t, result.Error, "force=true")
+	assert.Equal(t, []ApplyConflict{{Field: ".data.mode", Manager: "helm", Message: `conflict with "helm" using v1`}}, result.Conflicts)
+
+	response = applyObjects(context.Background(), client, testResolver, objects, ApplyOptions{Force: true})
+	assert.Equal(t, http.StatusOK, response.httpStatus())
+	assert.Equal(t, ApplyActionConfigured, response.Results[0].Action)
+}
+
+func TestApplyConflicts(t *testing.T) {
+	err := apierrors.NewApplyConflict([]metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Field:   ".spec.replicas",
+		Message: `conflict with "kubectl-client-side-apply" using apps/v1`,
+	}}, "Apply failed with 1 conflict")
+
+	conflicts := applyConflicts(err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, ApplyConflict{
+		Field:   ".spec.replicas",
+		Manager: "kubectl-client-side-apply",
+		Message: `conflict with "kubectl-client-side-apply" using apps/v1`,
+	}, conflicts[0])
+
+	assert.Nil(t, applyConflicts(apierrors.NewBadRequest("invalid")))
+}
@@ -0,0 +1,154 @@
+package manifests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const listWorkers = 8
+
+// ErrClusterNotConnected is returned for contexts without a live connection
+var ErrClusterNotConnected = errors.New("cluster not connected")
+
+// ClusterClients reach one cluster
+type ClusterClients struct {
+	Dynamic   dynamic.Interface
+	Discovery discovery.DiscoveryInterface
+}
+
+// ClientFactory returns the clients for a cluster context
+type ClientFactory func(context string) (*ClusterClients, error)
+
+// ConnectedClients reaches clusters through the cluster manager
+func ConnectedClients(manager *kubernetes.ClusterManager) ClientFactory {
+	return func(context string) (*ClusterClients, error) {
+		conn, err := manager.GetConnection(context)
+		if err != nil || conn == nil {
+			return nil, fmt.Errorf("%w: %s", ErrClusterNotConnected, context)
+		}
+		dynamicClient, err := dynamic.NewForConfig(conn.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+		}
+		return &ClusterClients{Dynamic: dynamicClient, Discovery: conn.ClientSet.Discovery()}, nil
+	}
+}
+
+// ResourceType is a resource type found through discovery
+type ResourceType struct {
+	GVR        schema.GroupVersionResource
+	Kind       string
+	Namespaced bool
+}
+
+// Matches reports whether kind names the type by kind, resource or resource.group
+func (t ResourceType) Matches(kind string) bool {
+	return strings.EqualFold(t.Kind, kind) || t.GVR.Resource == kind || t.GVR.GroupResource().String() == kind
+}
+
+// ServedTypes flattens discovery lists, leaving out subresources and, when verb is
+// set, resources that do not support it
+func ServedTypes(lists []*metav1.APIResourceList, verb string) []ResourceType {
+	var resourceTypes []ResourceType
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || (verb != "" && !hasVerb(resource.Verbs, verb)) {
+				continue
+			}
+			resourceTypes = append(resourceTypes, ResourceType{
+				GVR:        gv.WithResource(resource.Name),
+				Kind:       resource.Kind,
+				Namespaced: resource.Namespaced,
+			})
+		}
+	}
+	return resourceTypes
+}
+
+// DiscoverResourceTypes returns the listable resource types at their preferred
+// versions, tolerating groups that fail discovery. Events are left out: they relate
+// to everything and own nothing.
+func DiscoverResourceTypes(discoveryClient discovery.DiscoveryInterface) ([]ResourceType, error) {
+	lists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && len(lists) == 0 {
+		return nil, fmt.Errorf("failed to discover resources: %v", err)
+	}
+	var resourceTypes []ResourceType
+	for _, resourceType := range ServedTypes(lists, "list") {
+		if resourceType.GVR.Resource != "events" {
+			resourceTypes = append(resourceTypes, resourceType)
+		}
+	}
+	return resourceTypes, nil
+}
+
+func hasVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// Lister lists the objects of one resource type in a namespace
+type Lister func(ctx context.Context, resourceType ResourceType, namespace string) ([]unstructured.Unstructured, error)
+
+// DynamicLister lists whole objects
+func DynamicLister(dynamicClient dynamic.Interface, options metav1.ListOptions) Lister {
+	return func(ctx context.Context, resourceType ResourceType, namespace string) ([]unstructured.Unstructured, error) {
+		list, err := dynamicClient.Resource(resourceType.GVR).Namespace(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+}
+
+// ListObjects lists every type in parallel; types that fail are reported as warnings
+func ListObjects(ctx context.Context, list Lister, resourceTypes []ResourceType, namespace string) ([]unstructured.Unstructured, []string) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		objects  []unstructured.Unstructured
+		warnings []string
+	)
+	slots := make(chan struct{}, listWorkers)
+	for _, resourceType := range resourceTypes {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(resourceType ResourceType) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			items, err := list(ctx, resourceType, namespace)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("failed to list %s: %v", resourceType.GVR.GroupResource(), err))
+				return
+			}
+			objects = append(objects, items...)
+		}(resourceType)
+	}
+	wg.Wait()
+	sort.Strings(warnings)
+	return objects, warnings
+}
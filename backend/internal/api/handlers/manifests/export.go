@@ -0,0 +1,737 @@
+package manifests
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// Export scopes
+const (
+	ExportNamespace = "namespace" // every object in one namespace, plus the namespace itself
+	ExportSelector  = "selector"  // objects matching a label selector, in one namespace or all of them
+	ExportCluster   = "cluster"   // every namespaced and cluster-scoped object
+)
+
+// How an export treats Secrets
+const (
+	SecretsInclude = "include"
+	SecretsOmit    = "omit"
+	SecretsEncrypt = "encrypt" // written as .yaml.enc files that kubectl apply skips
+)
+
+const (
+	// clusterDir holds cluster-scoped objects; it sorts before namespace directories
+	// so that kubectl apply -R creates namespaces and CRDs first
+	clusterDir = "_cluster"
+
+	exportSaltSize        = 16
+	exportKeyIterations   = 600000
+	minExportPassphrase   = 8
+	exportSummaryFile     = "export.txt"
+	encryptedSecretSuffix = ".yaml.enc"
+)
+
+var errExportInvalid = errors.New("invalid export request")
+
+// CleanupOptions selects what is stripped from each exported object
+type CleanupOptions struct {
+	Status          bool `json:"status"`
+	ManagedFields   bool `json:"managedFields"`
+	UID             bool `json:"uid"`             // also ownerReferences and generated Job selectors, which point at UIDs
+	ResourceVersion bool `json:"resourceVersion"` // also generation, creationTimestamp and the Deployment revision
+	ClusterIP       bool `json:"clusterIP"`       // Service clusterIPs and ipFamilies; headless Services keep None
+	NodeName        bool `json:"nodeName"`        // also the volume a claim is bound to
+	Defaults        bool `json:"defaults"`        // fields that still hold the value the API server defaults them to
+}
+
+// cleanupProfiles are the named cleanup presets. backup keeps what identifies objects in
+// this cluster; migration strips everything tied to it.
+var cleanupProfiles = map[string]CleanupOptions{
+	"backup":    {Status: true, ManagedFields: true, ResourceVersion: true},
+	"migration": {Status: true, ManagedFields: true, UID: true, ResourceVersion: true, ClusterIP: true, NodeName: true, Defaults: true},
+}
+
+// ExportRequest describes what to export and how to clean it
+type ExportRequest struct {
+	Context       string          `json:"context" binding:"required"`
+	Scope         string          `json:"scope" binding:"required"`
+	Namespace     string          `json:"namespace,omitempty"`
+	LabelSelector string          `json:"labelSelector,omitempty"`
+	Kinds         []string        `json:"kinds,omitempty"`   // kinds or resource names to export, all when empty
+	Profile       string          `json:"profile,omitempty"` // backup or migration, default migration
+	Cleanup       *CleanupOptions `json:"cleanup,omitempty"` // replaces the profile
+	IncludeOwned  bool            `json:"includeOwned"`      // export objects a controller recreates, such as ReplicaSets and Pods
+	Secrets       string          `json:"secrets,omitempty"` // include, omit or encrypt, default include
+	Passphrase    string          `json:"passphrase,omitempty"`
+}
+
+// ExportSummary counts what an export wrote and left out
+type ExportSummary struct {
+	Objects          int            `json:"objects"`
+	Kinds            map[string]int `json:"kinds"`
+	Skipped          int            `json:"skipped"` // owned or generated objects
+	SecretsOmitted   int            `json:"secretsOmitted"`
+	SecretsEncrypted int            `json:"secretsEncrypted"`
+	Warnings         []string       `json:"warnings,omitempty"`
+}
+
+type exportFile struct {
+	name string
+	data []byte
+}
+
+type exportArchive struct {
+	files   []exportFile
+	summary ExportSummary
+}
+
+// exportSkipped are types that are recreated by the control plane or describe this
+// cluster rather than workloads, so they can't or shouldn't be re-applied elsewhere
+var exportSkipped = map[schema.GroupResource]bool{
+	{Resource: "endpoints"}:                                                true,
+	{Resource: "nodes"}:                                                    true,
+	{Resource: "componentstatuses"}:                                        true,
+	{Group: "discovery.k8s.io", Resource: "endpointslices"}:                true,
+	{Group: "events.k8s.io", Resource: "events"}:                           true,
+	{Group: "coordination.k8s.io", Resource: "leases"}:                     true,
+	{Group: "apps", Resource: "controllerrevisions"}:                       true,
+	{Group: "storage.k8s.io", Resource: "csinodes"}:                        true,
+	{Group: "storage.k8s.io", Resource: "volumeattachments"}:               true,
+	{Group: "certificates.k8s.io", Resource: "certificatesigningrequests"}: true,
+}
+
+// ExportManifests exports a namespace, a label-selected set or the whole cluster as a
+// tar of YAML files laid out as <namespace>/<resource>/<name>.yaml, ready for kubectl apply -R
+// POST /api/v1/manifests/export
+func ExportManifests(c *gin.Context) {
+	var req ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cleanup, err := req.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := clusterManager.GetConnection(req.Context)
+	if err != nil || conn == nil {
+		errMsg := "cluster not connected"
+		if err != nil {
+			errMsg = fmt.Sprintf("cluster not connected: %v", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg, "context": req.Context})
+		return
+	}
+	dynamicClient, err := dynamic.NewForConfig(conn.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create dynamic client: %v", err)})
+		return
+	}
+
+	exportedAt := time.Now().UTC()
+	archive, err := buildExport(c.Request.Context(), conn.ClientSet.Discovery(), dynamicClient, req, cleanup)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, errExportInvalid) {
+			code = http.StatusBadRequest
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	archive.files = append([]exportFile{{
+		name: exportSummaryFile,
+		data: exportSummaryText(req, cleanup, archive.summary, exportedAt),
+	}}, archive.files...)
+
+	c.Header("Content-Type", "application/x-tar")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(req, exportedAt)))
+	c.Header("X-Export-Objects", strconv.Itoa(archive.summary.Objects))
+	c.Status(http.StatusOK)
+	if err := writeExportTar(c.Writer, archive.files, exportedAt); err != nil {
+		log.Printf("Failed to write manifest export for %s: %v", req.Context, err)
+	}
+}
+
+// validate checks the request and resolves its cleanup options
+func (req *ExportRequest) validate() (CleanupOptions, error) {
+	switch req.Scope {
+	case ExportNamespace:
+		if req.Namespace == "" {
+			return CleanupOptions{}, fmt.Errorf("%w: namespace is required for a namespace export", errExportInvalid)
+		}
+	case ExportSelector:
+		if req.LabelSelector == "" {
+			return CleanupOptions{}, fmt.Errorf("%w: labelSelector is required for a selector export", errExportInvalid)
+		}
+		if _, err := labels.Parse(req.LabelSelector); err != nil {
+			return CleanupOptions{}, fmt.Errorf("%w: %v", errExportInvalid, err)
+		}
+	case ExportCluster:
+		if req.Namespace != "" {
+			return CleanupOptions{}, fmt.Errorf("%w: a cluster export covers every namespace", errExportInvalid)
+		}
+	default:
+		return CleanupOptions{}, fmt.Errorf("%w: scope must be namespace, selector or cluster", errExportInvalid)
+	}
+
+	switch req.Secrets {
+	case "":
+		req.Secrets = SecretsInclude
+	case SecretsInclude, SecretsOmit:
+	case SecretsEncrypt:
+		if len(req.Passphrase) < minExportPassphrase {
+			return CleanupOptions{}, fmt.Errorf("%w: encrypting secrets needs a passphrase of at least %d characters", errExportInvalid, minExportPassphrase)
+		}
+	default:
+		return CleanupOptions{}, fmt.Errorf("%w: secrets must be include, omit or encrypt", errExportInvalid)
+	}
+
+	if req.Cleanup != nil {
+		return *req.Cleanup, nil
+	}
+	if req.Profile == "" {
+		req.Profile = "migration"
+	}
+	cleanup, ok := cleanupProfiles[req.Profile]
+	if !ok {
+		return CleanupOptions{}, fmt.Errorf("%w: unknown profile %s, expected backup or migration", errExportInvalid, req.Profile)
+	}
+	return cleanup, nil
+}
+
+// buildExport lists the objects in the request's scope, cleans them and lays them out as files
+func buildExport(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, req ExportRequest, cleanup CleanupOptions) (*exportArchive, error) {
+	resourceTypes, err := DiscoverResourceTypes(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+	resourceTypes, err = exportTypes(resourceTypes, req.Kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaced, clusterScoped []ResourceType
+	for _, resourceType := range resourceTypes {
+		if resourceType.Namespaced {
+			namespaced = append(namespaced, resourceType)
+		} else if req.Namespace == "" {
+			clusterScoped = append(clusterScoped, resourceType)
+		}
+	}
+	options := metav1.ListOptions{LabelSelector: req.LabelSelector}
+	objects, warnings := ListObjects(ctx, DynamicLister(dynamicClient, options), namespaced, req.Namespace)
+	clusterObjects, clusterWarnings := ListObjects(ctx, DynamicLister(dynamicClient, options), clusterScoped, "")
+	objects = append(objects, clusterObjects...)
+	warnings = append(warnings, clusterWarnings...)
+
+	// A namespace export brings its namespace along so it applies to a cluster without it
+	if req.Scope == ExportNamespace && (len(req.Kinds) == 0 || exportsKind(resourceTypes, "", "Namespace")) {
+		namespace, err := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).
+			Get(ctx, req.Namespace, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			objects = append(objects, *namespace)
+		case apierrors.IsNotFound(err):
+			return nil, fmt.Errorf("%w: namespace %s not found", errExportInvalid, req.Namespace)
+		default:
+			warnings = append(warnings, fmt.Sprintf("failed to get namespace %s: %v", req.Namespace, err))
+		}
+	}
+
+	resources := make(map[schema.GroupKind]schema.GroupResource, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		resources[schema.GroupKind{Group: resourceType.GVR.Group, Kind: resourceType.Kind}] = resourceType.GVR.GroupResource()
+	}
+	resources[schema.GroupKind{Kind: "Namespace"}] = schema.GroupResource{Resource: "namespaces"}
+
+	var key, salt []byte
+	if req.Secrets == SecretsEncrypt {
+		salt = make([]byte, exportSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %v", err)
+		}
+		if key, err = pbkdf2.Key(sha256.New, req.Passphrase, salt, exportKeyIterations, 32); err != nil {
+			return nil, fmt.Errorf("failed to derive key: %v", err)
+		}
+	}
+
+	archive := &exportArchive{summary: ExportSummary{Kinds: map[string]int{}, Warnings: warnings}}
+	seen := make(map[string]bool)
+	for i := range objects {
+		obj := &objects[i]
+		if (!req.IncludeOwned && len(obj.GetOwnerReferences()) > 0) || IsAutoCreated(obj) {
+			archive.summary.Skipped++
+			continue
+		}
+		gk := obj.GroupVersionKind().GroupKind()
+		name := exportPath(resources[gk], obj)
+		if seen[name] {
+			continue // the same object served by more than one group, such as core and events.k8s.io Events
+		}
+		seen[name] = true
+
+		isSecret := gk == schema.GroupKind{Kind: "Secret"}
+		if isSecret && req.Secrets == SecretsOmit {
+			archive.summary.SecretsOmitted++
+			continue
+		}
+
+		CleanForExport(obj, cleanup)
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			archive.summary.Warnings = append(archive.summary.Warnings, fmt.Sprintf("failed to encode %s: %v", name, err))
+			continue
+		}
+		if isSecret && req.Secrets == SecretsEncrypt {
+			if data, err = encryptExport(key, salt, data); err != nil {
+				return nil, err
+			}
+			name = strings.TrimSuffix(name, ".yaml") + encryptedSecretSuffix
+			archive.summary.SecretsEncrypted++
+		}
+		archive.files = append(archive.files, exportFile{name: name, data: data})
+		archive.summary.Objects++
+		archive.summary.Kinds[obj.GetKind()]++
+	}
+	sort.Slice(archive.files, func(i, j int) bool { return archive.files[i].name < archive.files[j].name })
+	return archive, nil
+}
+
+// exportTypes drops types that don't belong in an export and narrows the rest to kinds
+func exportTypes(resourceTypes []ResourceType, kinds []string) ([]ResourceType, error) {
+	var exported []ResourceType
+	for _, resourceType := range resourceTypes {
+		gr := resourceType.GVR.GroupResource()
+		if exportSkipped[gr] || gr.Group == "metrics.k8s.io" {
+			continue
+		}
+		exported = append(exported, resourceType)
+	}
+	if len(kinds) == 0 {
+		return exported, nil
+	}
+
+	var selected []ResourceType
+	for _, kind := range kinds {
+		matched := false
+		for _, resourceType := range exported {
+			if resourceType.Matches(kind) {
+				selected = append(selected, resourceType)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%w: kind %s is not served by the cluster or can't be exported", errExportInvalid, kind)
+		}
+	}
+	return selected, nil
+}
+
+func exportsKind(resourceTypes []ResourceType, group, kind string) bool {
+	for _, resourceType := range resourceTypes {
+		if resourceType.GVR.Group == group && resourceType.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// exportPath is <namespace>/<resource>/<name>.yaml, with cluster-scoped objects under _cluster
+func exportPath(resource schema.GroupResource, obj *unstructured.Unstructured) string {
+	dir := obj.GetNamespace()
+	if dir == "" {
+		dir = clusterDir
+	}
+	if resource.Resource == "" {
+		resource.Resource = strings.ToLower(obj.GetKind())
+	}
+	return path.Join(dir, resource.String(), obj.GetName()+".yaml")
+}
+
+// CleanForExport strips what cleanup selects from obj so that it can be applied to
+// another cluster, or to this one after the original is gone
+func CleanForExport(obj *unstructured.Unstructured, cleanup CleanupOptions) {
+	gk := obj.GroupVersionKind().GroupKind()
+	if cleanup.ManagedFields {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	}
+	if cleanup.Status {
+		unstructured.RemoveNestedField(obj.Object, "status")
+	}
+	if cleanup.ResourceVersion {
+		for _, field := range []string{"resourceVersion", "generation", "creationTimestamp", "selfLink", "deletionTimestamp", "deletionGracePeriodSeconds"} {
+			unstructured.RemoveNestedField(obj.Object, "metadata", field)
+		}
+		removeAnnotations(obj, "deployment.kubernetes.io/revision")
+	}
+	if cleanup.UID {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "uid")
+		unstructured.RemoveNestedField(obj.Object, "metadata", "ownerReferences")
+		switch gk {
+		case schema.GroupKind{Group: "batch", Kind: "Job"}:
+			// The selector the Job controller generates matches the old Job's UID
+			if manual, _, _ := unstructured.NestedBool(obj.Object, "spec", "manualSelector"); !manual {
+				unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+				for _, label := range []string{"controller-uid", "batch.kubernetes.io/controller-uid"} {
+					unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "labels", label)
+				}
+			}
+		case schema.GroupKind{Kind: "PersistentVolume"}:
+			unstructured.RemoveNestedField(obj.Object, "spec", "claimRef", "uid")
+			unstructured.RemoveNestedField(obj.Object, "spec", "claimRef", "resourceVersion")
+		}
+	}
+	if cleanup.ClusterIP && gk == (schema.GroupKind{Kind: "Service"}) {
+		if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != "None" {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		}
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		unstructured.RemoveNestedField(obj.Object, "spec", "ipFamilies")
+	}
+	if cleanup.NodeName {
+		switch gk {
+		case schema.GroupKind{Kind: "Pod"}:
+			unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+		case schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+			unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+			removeAnnotations(obj, "pv.kubernetes.io/bind-completed", "pv.kubernetes.io/bound-by-controller",
+				"volume.beta.kubernetes.io/storage-provisioner", "volume.kubernetes.io/storage-provisioner",
+				"volume.kubernetes.io/selected-node")
+		}
+	}
+	if cleanup.Defaults {
+		removeDefaults(obj)
+	}
+	removeEmpty(obj.Object, "metadata", "annotations")
+	removeEmpty(obj.Object, "metadata", "finalizers")
+	removeEmpty(obj.Object, "metadata", "ownerReferences")
+	removeEmpty(obj.Object, "spec")
+}
+
+// Values the API server fills in when a manifest leaves them out. Each is removed only
+// while it still holds the default, so deliberate settings survive.
+var (
+	specDefaults = map[schema.GroupKind]map[string]interface{}{
+		{Group: "apps", Kind: "Deployment"}: {
+			"progressDeadlineSeconds": int64(600),
+			"revisionHistoryLimit":    int64(10),
+			"strategy": map[string]interface{}{
+				"type":          "RollingUpdate",
+				"rollingUpdate": map[string]interface{}{"maxSurge": "25%", "maxUnavailable": "25%"},
+			},
+		},
+		{Group: "apps", Kind: "StatefulSet"}: {
+			"podManagementPolicy":  "OrderedReady",
+			"revisionHistoryLimit": int64(10),
+			"updateStrategy": map[string]interface{}{
+				"type":          "RollingUpdate",
+				"rollingUpdate": map[string]interface{}{"partition": int64(0)},
+			},
+			"persistentVolumeClaimRetentionPolicy": map[string]interface{}{"whenDeleted": "Retain", "whenScaled": "Retain"},
+		},
+		{Group: "apps", Kind: "DaemonSet"}: {
+			"revisionHistoryLimit": int64(10),
+			"updateStrategy": map[string]interface{}{
+				"type":          "RollingUpdate",
+				"rollingUpdate": map[string]interface{}{"maxSurge": int64(0), "maxUnavailable": int64(1)},
+			},
+		},
+		{Kind: "Service"}: {
+			"sessionAffinity":       "None",
+			"internalTrafficPolicy": "Cluster",
+			"ipFamilyPolicy":        "SingleStack",
+		},
+		{Kind: "Namespace"}: {
+			"finalizers": []interface{}{"kubernetes"},
+		},
+	}
+	podSpecDefaults = map[string]interface{}{
+		"dnsPolicy":                     "ClusterFirst",
+		"restartPolicy":                 "Always",
+		"schedulerName":                 "default-scheduler",
+		"terminationGracePeriodSeconds": int64(30),
+		"securityContext":               map[string]interface{}{},
+	}
+	containerDefaults = map[string]interface{}{
+		"terminationMessagePath":   "/dev/termination-log",
+		"terminationMessagePolicy": "File",
+		"resources":                map[string]interface{}{},
+	}
+	portDefaults = map[string]interface{}{
+		"protocol": "TCP",
+	}
+)
+
+func removeDefaults(obj *unstructured.Unstructured) {
+	spec := mapAt(obj.Object, "spec")
+	removeDefaultValues(spec, specDefaults[obj.GroupVersionKind().GroupKind()])
+	if obj.GetKind() == "Service" {
+		for _, port := range mapsAt(spec, "ports") {
+			removeDefaultValues(port, portDefaults)
+		}
+	}
+
+	specPath := podSpecPath(obj)
+	if specPath == nil {
+		return
+	}
+	if len(specPath) > 1 {
+		// Templates carry a null creationTimestamp once they have been round-tripped by the server
+		template := mapAt(obj.Object, specPath[:len(specPath)-1]...)
+		if metadata := mapAt(template, "metadata"); metadata != nil {
+			if value, ok := metadata["creationTimestamp"]; ok && value == nil {
+				delete(metadata, "creationTimestamp")
+			}
+		}
+	}
+	podSpec := mapAt(obj.Object, specPath...)
+	removeDefaultValues(podSpec, podSpecDefaults)
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, container := range mapsAt(podSpec, field) {
+			removeDefaultValues(container, containerDefaults)
+			for _, port := range mapsAt(container, "ports") {
+				removeDefaultValues(port, portDefaults)
+			}
+		}
+	}
+}
+
+func removeDefaultValues(fields map[string]interface{}, defaults map[string]interface{}) {
+	if fields == nil {
+		return
+	}
+	for key, value := range defaults {
+		if current, ok := fields[key]; ok && reflect.DeepEqual(current, value) {
+			delete(fields, key)
+		}
+	}
+}
+
+// mapAt returns the map at fields without copying it, so changes land in obj
+func mapAt(obj map[string]interface{}, fields ...string) map[string]interface{} {
+	current := obj
+	for _, field := range fields {
+		next, ok := current[field].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// mapsAt returns the maps in a list field without copying them
+func mapsAt(obj map[string]interface{}, field string) []map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	items, _ := obj[field].([]interface{})
+	var maps []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
+
+func removeAnnotations(obj *unstructured.Unstructured, keys ...string) {
+	annotations := mapAt(obj.Object, "metadata", "annotations")
+	for _, key := range keys {
+		delete(annotations, key)
+	}
+}
+
+// removeEmpty removes a map or list field that has nothing left in it
+func removeEmpty(obj map[string]interface{}, fields ...string) {
+	value, found, _ := unstructured.NestedFieldNoCopy(obj, fields...)
+	if !found {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			unstructured.RemoveNestedField(obj, fields...)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			unstructured.RemoveNestedField(obj, fields...)
+		}
+	}
+}
+
+// encryptExport seals data with AES-256-GCM, prefixed by the salt the key was derived
+// with and the nonce, so each file decrypts on its own given the passphrase
+func encryptExport(key, salt, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := append(append([]byte{}, salt...), nonce...)
+	return gcm.Seal(sealed, nonce, data, nil), nil
+}
+
+// decryptExport reverses encryptExport
+func decryptExport(passphrase string, sealed []byte) ([]byte, error) {
+	if len(sealed) < exportSaltSize {
+		return nil, errors.New("encrypted file is too short")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, sealed[:exportSaltSize], exportKeyIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[exportSaltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted file is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+func writeExportTar(w io.Writer, files []exportFile, modTime time.Time) error {
+	writer := tar.NewWriter(w)
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.data)),
+			ModTime: modTime,
+			Format:  tar.FormatPAX,
+		}
+		if strings.HasSuffix(file.name, encryptedSecretSuffix) {
+			header.Mode = 0o600
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := writer.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func exportFilename(req ExportRequest, exportedAt time.Time) string {
+	scope := req.Scope
+	if req.Namespace != "" {
+		scope = req.Namespace
+	}
+	name := fmt.Sprintf("%s-%s-%s.tar", req.Context, scope, exportedAt.Format("20060102-150405"))
+	return unsafeFilename.ReplaceAllString(name, "-")
+}
+
+// exportSummaryText describes the export in a file kubectl apply ignores
+func exportSummaryText(req ExportRequest, cleanup CleanupOptions, summary ExportSummary, exportedAt time.Time) []byte {
+	var b bytes.Buffer
+	scope := req.Scope
+	if req.Namespace != "" {
+		scope += " " + req.Namespace
+	}
+	if req.LabelSelector != "" {
+		scope += " " + req.LabelSelector
+	}
+	profile := req.Profile
+	if req.Cleanup != nil {
+		profile = "custom"
+	}
+
+	fmt.Fprintf(&b, "Kaptivan manifest export\n\n")
+	fmt.Fprintf(&b, "Context:  %s\n", req.Context)
+	fmt.Fprintf(&b, "Scope:    %s\n", scope)
+	fmt.Fprintf(&b, "Exported: %s\n", exportedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Profile:  %s (removes %s)\n", profile, strings.Join(cleanup.removed(), ", "))
+	fmt.Fprintf(&b, "Objects:  %d\n", summary.Objects)
+	if summary.Skipped > 0 {
+		fmt.Fprintf(&b, "Skipped:  %d owned or generated objects\n", summary.Skipped)
+	}
+	switch req.Secrets {
+	case SecretsOmit:
+		fmt.Fprintf(&b, "Secrets:  %d omitted\n", summary.SecretsOmitted)
+	case SecretsEncrypt:
+		fmt.Fprintf(&b, "Secrets:  %d encrypted\n", summary.SecretsEncrypted)
+	}
+
+	fmt.Fprintf(&b, "\nApply with kubectl apply -R -f <directory>. Cluster-scoped objects, namespaces\nincluded, are under %s and are applied first.\n", clusterDir)
+	if summary.SecretsEncrypted > 0 {
+		fmt.Fprintf(&b, "\nSecrets are in *%s files: a %d-byte salt, a 12-byte nonce and the\n", encryptedSecretSuffix, exportSaltSize)
+		fmt.Fprintf(&b, "AES-256-GCM ciphertext of the Secret's YAML. The key is PBKDF2-HMAC-SHA256 of\nthe passphrase and salt with %d iterations.\n", exportKeyIterations)
+	}
+	if len(summary.Warnings) > 0 {
+		fmt.Fprintf(&b, "\nWarnings:\n")
+		for _, warning := range summary.Warnings {
+			fmt.Fprintf(&b, "- %s\n", warning)
+		}
+	}
+	return b.Bytes()
+}
+
+func (o CleanupOptions) removed() []string {
+	var removed []string
+	for _, option := range []struct {
+		enabled bool
+		name    string
+	}{
+		{o.Status, "status"},
+		{o.ManagedFields, "managedFields"},
+		{o.UID, "uid"},
+		{o.ResourceVersion, "resourceVersion"},
+		{o.ClusterIP, "clusterIP"},
+		{o.NodeName, "nodeName"},
+		{o.Defaults, "defaults"},
+	} {
+		if option.enabled {
+			removed = append(removed, option.name)
+		}
+	}
+	if len(removed) == 0 {
+		return []string{"nothing"}
+	}
+	return removed
+}
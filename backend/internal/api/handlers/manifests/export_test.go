@@ -0,0 +1,224 @@
+package manifests
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCleanForExport(t *testing.T) {
+	deployment := func() *unstructured.Unstructured {
+		obj := relationObject("apps/v1", "Deployment", "web", "d1", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"replicas":                int64(2),
+				"progressDeadlineSeconds": int64(600),
+				"revisionHistoryLimit":    int64(3), // not the default, kept
+				"strategy": map[string]interface{}{
+					"type":          "RollingUpdate",
+					"rollingUpdate": map[string]interface{}{"maxSurge": "25%", "maxUnavailable": "25%"},
+				},
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"creationTimestamp": nil, "labels": map[string]interface{}{"app": "web"}},
+					"spec": map[string]interface{}{
+						"dnsPolicy":       "ClusterFirst",
+						"restartPolicy":   "Always",
+						"securityContext": map[string]interface{}{},
+						"containers": []interface{}{map[string]interface{}{
+							"name":                   "web",
+							"image":                  "web:1.0",
+							"terminationMessagePath": "/dev/termination-log",
+							"ports":                  []interface{}{map[string]interface{}{"containerPort": int64(80), "protocol": "TCP"}},
+						}},
+					},
+				},
+			},
+			"status": map[string]interface{}{"replicas": int64(2)},
+		})
+		obj.SetResourceVersion("42")
+		obj.SetGeneration(3)
+		obj.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "3", "team": "shop"})
+		obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+		return obj
+	}
+
+	backup := deployment()
+	CleanForExport(backup, cleanupProfiles["backup"])
+	assert.Equal(t, "d1", string(backup.GetUID()))
+	assert.Empty(t, backup.GetResourceVersion())
+	assert.Empty(t, backup.GetManagedFields())
+	assert.Equal(t, map[string]string{"team": "shop"}, backup.GetAnnotations())
+	_, hasStatus := backup.Object["status"]
+	assert.False(t, hasStatus)
+	_, found, _ := unstructured.NestedFieldNoCopy(backup.Object, "spec", "progressDeadlineSeconds")
+	assert.True(t, found, "backup keeps defaulted fields")
+
+	migration := deployment()
+	CleanForExport(migration, cleanupProfiles["migration"])
+	assert.Empty(t, migration.GetUID())
+	assert.Equal(t, map[string]interface{}{
+		"replicas":             int64(2),
+		"revisionHistoryLimit": int64(3),
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":  "web",
+					"image": "web:1.0",
+					"ports": []interface{}{map[string]interface{}{"containerPort": int64(80)}},
+				}},
+			},
+		},
+	}, migration.Object["spec"])
+
+	headless := relationObject("v1", "Service", "db", "s1", map[string]interface{}{
+		"spec": map[string]interface{}{"clusterIP": "None", "clusterIPs": []interface{}{"None"}, "ipFamilies": []interface{}{"IPv4"}},
+	})
+	CleanForExport(headless, cleanupProfiles["migration"])
+	assert.Equal(t, map[string]interface{}{"clusterIP": "None"}, headless.Object["spec"])
+
+	job := relationObject("batch/v1", "Job", "migrate", "j1", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"batch.kubernetes.io/controller-uid": "j1"}},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"batch.kubernetes.io/controller-uid": "j1", "job-name": "migrate"}},
+				"spec":     map[string]interface{}{"restartPolicy": "Never"},
+			},
+		},
+	})
+	CleanForExport(job, CleanupOptions{UID: true})
+	assert.Equal(t, map[string]interface{}{
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"job-name": "migrate"}},
+			"spec":     map[string]interface{}{"restartPolicy": "Never"},
+		},
+	}, job.Object["spec"])
+}
+
+func TestExportRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ExportRequest
+		wantErr bool
+	}{
+		{"namespace", ExportRequest{Scope: ExportNamespace, Namespace: "shop"}, false},
+		{"namespace without namespace", ExportRequest{Scope: ExportNamespace}, true},
+		{"selector", ExportRequest{Scope: ExportSelector, LabelSelector: "app in (web)"}, false},
+		{"invalid selector", ExportRequest{Scope: ExportSelector, LabelSelector: "app in web"}, true},
+		{"cluster with namespace", ExportRequest{Scope: ExportCluster, Namespace: "shop"}, true},
+		{"unknown scope", ExportRequest{Scope: "everything"}, true},
+		{"unknown profile", ExportRequest{Scope: ExportCluster, Profile: "tiny"}, true},
+		{"encrypt without passphrase", ExportRequest{Scope: ExportCluster, Secrets: SecretsEncrypt, Passphrase: "short"}, true},
+		{"unknown secrets mode", ExportRequest{Scope: ExportCluster, Secrets: "redact"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.req.validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errExportInvalid)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	req := ExportRequest{Scope: ExportCluster}
+	cleanup, err := req.validate()
+	require.NoError(t, err)
+	assert.Equal(t, cleanupProfiles["migration"], cleanup)
+	assert.Equal(t, SecretsInclude, req.Secrets)
+
+	req = ExportRequest{Scope: ExportCluster, Profile: "backup", Cleanup: &CleanupOptions{Status: true}}
+	cleanup, err = req.validate()
+	require.NoError(t, err)
+	assert.Equal(t, CleanupOptions{Status: true}, cleanup)
+}
+
+func TestBuildExport(t *testing.T) {
+	discovery, client, _ := newRelationClients()
+	ctx := context.Background()
+	namespaces := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	_, err := client.Resource(namespaces).Create(ctx, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "shop", "uid": "ns1"},
+		"spec":       map[string]interface{}{"finalizers": []interface{}{"kubernetes"}},
+	}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	req := ExportRequest{Context: "prod", Scope: ExportNamespace, Namespace: "shop", Secrets: SecretsEncrypt, Passphrase: "correct horse"}
+	cleanup, err := req.validate()
+	require.NoError(t, err)
+	archive, err := buildExport(ctx, discovery, client, req, cleanup)
+	require.NoError(t, err)
+
+	var names []string
+	files := map[string][]byte{}
+	for _, file := range archive.files {
+		names = append(names, file.name)
+		files[file.name] = file.data
+	}
+	// The ReplicaSet and Pods are recreated by their owners and left out
+	assert.Equal(t, []string{
+		"_cluster/namespaces/shop.yaml",
+		"shop/configmaps/unrelated.yaml",
+		"shop/configmaps/web-config.yaml",
+		"shop/deployments.apps/web.yaml",
+		"shop/horizontalpodautoscalers.autoscaling/web.yaml",
+		"shop/ingresses.networking.k8s.io/web.yaml",
+		"shop/secrets/web-secret.yaml.enc",
+		"shop/secrets/web-tls.yaml.enc",
+		"shop/serviceaccounts/web-sa.yaml",
+		"shop/services/web.yaml",
+	}, names)
+	assert.Equal(t, 3, archive.summary.Skipped)
+	assert.Equal(t, 2, archive.summary.SecretsEncrypted)
+	assert.Equal(t, 2, archive.summary.Kinds["Secret"])
+	assert.Equal(t, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n", string(files["_cluster/namespaces/shop.yaml"]))
+
+	secret, err := decryptExport("correct horse", files["shop/secrets/web-secret.yaml.enc"])
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(secret, &decoded))
+	assert.Equal(t, map[string]interface{}{"name": "web-secret", "namespace": "shop"}, decoded["metadata"])
+	_, err = decryptExport("wrong horse!", files["shop/secrets/web-secret.yaml.enc"])
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeExportTar(&buf, archive.files, time.Now()))
+	reader := tar.NewReader(&buf)
+	header, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "_cluster/namespaces/shop.yaml", header.Name)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, files[header.Name], data)
+
+	// A selector export with owned objects included and secrets omitted
+	req = ExportRequest{Scope: ExportSelector, Namespace: "shop", LabelSelector: "app=web", IncludeOwned: true, Secrets: SecretsOmit}
+	archive, err = buildExport(ctx, discovery, client, req, cleanupProfiles["migration"])
+	require.NoError(t, err)
+	require.Len(t, archive.files, 1)
+	assert.Equal(t, "shop/pods/web-abc-1.yaml", archive.files[0].name)
+	assert.NotContains(t, string(archive.files[0].data), "ownerReferences")
+
+	req = ExportRequest{Scope: ExportNamespace, Namespace: "shop", Kinds: []string{"secret"}, Secrets: SecretsOmit}
+	archive, err = buildExport(ctx, discovery, client, req, cleanupProfiles["migration"])
+	require.NoError(t, err)
+	assert.Empty(t, archive.files)
+	assert.Equal(t, 2, archive.summary.SecretsOmitted)
+
+	req.Kinds = []string{"Widget"}
+	_, err = buildExport(ctx, discovery, client, req, cleanupProfiles["migration"])
+	assert.True(t, errors.Is(err, errExportInvalid))
+}
@@ -0,0 +1,399 @@
+package manifests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+// Patch types accepted by the patch endpoint
+const (
+	PatchTypeJSON      = "json"
+	PatchTypeMerge     = "merge"
+	PatchTypeStrategic = "strategic"
+)
+
+var patchTypes = map[string]types.PatchType{
+	PatchTypeJSON:      types.JSONPatchType,
+	PatchTypeMerge:     types.MergePatchType,
+	PatchTypeStrategic: types.StrategicMergePatchType,
+}
+
+// PatchRequest patches a resource
+type PatchRequest struct {
+	Type            string          `json:"type"`  // json, merge or strategic; default merge
+	Patch           json.RawMessage `json:"patch"` // the patch document
+	ResourceVersion string          `json:"resourceVersion,omitempty"`
+	DryRun          bool            `json:"dryRun,omitempty"`
+}
+
+// MetadataRequest adds, changes and removes labels or annotations
+type MetadataRequest struct {
+	Set             map[string]string `json:"set,omitempty"`
+	Remove          []string          `json:"remove,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	DryRun          bool              `json:"dryRun,omitempty"`
+}
+
+// DeleteRequest controls how a resource is deleted
+type DeleteRequest struct {
+	PropagationPolicy  string // Foreground, Background or Orphan; empty uses the resource's default
+	GracePeriodSeconds *int64
+	ResourceVersion    string
+	DryRun             bool
+}
+
+// MutationResult describes a resource after a patch, label, annotate or delete
+type MutationResult struct {
+	Group           string                 `json:"group"`
+	Version         string                 `json:"version"`
+	Resource        string                 `json:"resource"`
+	Namespace       string                 `json:"namespace,omitempty"`
+	Name            string                 `json:"name"`
+	ResourceVersion string                 `json:"resourceVersion,omitempty"`
+	DryRun          bool                   `json:"dryRun"`
+	Deleted         bool                   `json:"deleted,omitempty"`
+	Object          map[string]interface{} `json:"object,omitempty"` // the patched object, cleaned as in GetManifest
+}
+
+// resourceTarget is the resource a mutation applies to
+type resourceTarget struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+	client    dynamic.ResourceInterface
+}
+
+// resolveTarget finds the resource named by the query parameters, accepting
+// (group, version, resource) or (kind, apiVersion) as GetManifest does.
+// It writes the error response and returns false when the target cannot be resolved.
+func resolveTarget(c *gin.Context) (*resourceTarget, bool) {
+	clusterContext := c.Query("context")
+	name := c.Query("name")
+	group := c.Query("group")
+	version := c.Query("version")
+	resource := c.Query("resource")
+	kind := c.Query("kind")
+	apiVersion := c.Query("apiVersion")
+
+	if clusterContext == "" || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context and name are required"})
+		return nil, false
+	}
+	if (version == "" || resource == "") && (kind == "" || apiVersion == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either (group, version, resource) or (kind, apiVersion) are required"})
+		return nil, false
+	}
+
+	conn, err := clusterManager.GetConnection(clusterContext)
+	if err != nil || conn == nil {
+		errMsg := "cluster not connected"
+		if err != nil {
+			errMsg = fmt.Sprintf("cluster not connected: %v", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg, "context": clusterContext})
+		return nil, false
+	}
+	dynamicClient, err := dynamic.NewForConfig(conn.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create dynamic client: %v", err)})
+		return nil, false
+	}
+
+	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+	if version == "" || resource == "" {
+		if gvr, err = findResourceByKind(conn, kind, apiVersion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to find resource: %v", err)})
+			return nil, false
+		}
+	}
+	namespaced, err := isResourceNamespaced(conn, gvr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to determine if resource is namespaced: %v", err)})
+		return nil, false
+	}
+
+	target := &resourceTarget{gvr: gvr, name: name}
+	if namespaced {
+		target.namespace = c.Query("namespace")
+		if target.namespace == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "namespace is required for namespaced resources"})
+			return nil, false
+		}
+		target.client = dynamicClient.Resource(gvr).Namespace(target.namespace)
+	} else {
+		target.client = dynamicClient.Resource(gvr)
+	}
+	return target, true
+}
+
+// PatchResource patches any resource with a JSON, merge or strategic merge patch
+// POST /api/v1/manifests/patch?context=...&namespace=...&name=...&kind=...&apiVersion=...
+func PatchResource(c *gin.Context) {
+	var req PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, ok := resolveTarget(c)
+	if !ok {
+		return
+	}
+	result, err := patchResource(c.Request.Context(), target, req)
+	respondMutation(c, result, err)
+}
+
+// LabelResource adds, changes and removes labels on any resource
+// POST /api/v1/manifests/labels?context=...&namespace=...&name=...&kind=...&apiVersion=...
+func LabelResource(c *gin.Context) {
+	editMetadata(c, "labels")
+}
+
+// AnnotateResource adds, changes and removes annotations on any resource
+// POST /api/v1/manifests/annotations?context=...&namespace=...&name=...&kind=...&apiVersion=...
+func AnnotateResource(c *gin.Context) {
+	editMetadata(c, "annotations")
+}
+
+func editMetadata(c *gin.Context, field string) {
+	var req MetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	patch, err := metadataPatch(field, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, ok := resolveTarget(c)
+	if !ok {
+		return
+	}
+	result, err := patchResource(c.Request.Context(), target, PatchRequest{
+		Type:            PatchTypeMerge,
+		Patch:           patch,
+		ResourceVersion: req.ResourceVersion,
+		DryRun:          req.DryRun,
+	})
+	respondMutation(c, result, err)
+}
+
+// DeleteResource deletes any resource
+// DELETE /api/v1/manifests/delete?context=...&namespace=...&name=...&kind=...&apiVersion=...
+// &propagationPolicy=Foreground&gracePeriodSeconds=0&resourceVersion=...&dryRun=All
+func DeleteResource(c *gin.Context) {
+	req := DeleteRequest{
+		PropagationPolicy: c.Query("propagationPolicy"),
+		ResourceVersion:   c.Query("resourceVersion"),
+	}
+	if grace := c.Query("gracePeriodSeconds"); grace != "" {
+		seconds, err := strconv.ParseInt(grace, 10, 64)
+		if err != nil || seconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gracePeriodSeconds must be a non-negative integer"})
+			return
+		}
+		req.GracePeriodSeconds = &seconds
+	}
+	switch dryRun := c.Query("dryRun"); dryRun {
+	case "":
+	case "All":
+		req.DryRun = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported dryRun value %q: only All is supported", dryRun)})
+		return
+	}
+	opts, err := deleteOptions(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, ok := resolveTarget(c)
+	if !ok {
+		return
+	}
+	if err := target.client.Delete(c.Request.Context(), target.name, opts); err != nil {
+		respondMutation(c, nil, err)
+		return
+	}
+	result := target.result(nil)
+	result.DryRun = req.DryRun
+	result.Deleted = true
+	result.ResourceVersion = req.ResourceVersion
+	c.JSON(http.StatusOK, result)
+}
+
+// patchResource sends the patch, carrying the resourceVersion precondition inside
+// the patch so the API server rejects it with a conflict when the object has moved on
+func patchResource(ctx context.Context, target *resourceTarget, req PatchRequest) (*MutationResult, error) {
+	if req.Type == "" {
+		req.Type = PatchTypeMerge
+	}
+	patchType, ok := patchTypes[req.Type]
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported patch type %q: use json, merge or strategic", req.Type))
+	}
+	if len(req.Patch) == 0 {
+		return nil, apierrors.NewBadRequest("patch is required")
+	}
+	patch, err := withResourceVersion(patchType, req.Patch, req.ResourceVersion)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	opts := metav1.PatchOptions{FieldManager: FieldManager}
+	if req.DryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	obj, err := target.client.Patch(ctx, target.name, patchType, patch, opts)
+	if err != nil {
+		if patchType == types.StrategicMergePatchType && apierrors.IsUnsupportedMediaType(err) {
+			// Custom resources have no patch strategy metadata
+			return nil, fmt.Errorf("strategic merge patch is not supported for %s, use a json or merge patch: %w", target.gvr.GroupResource(), err)
+		}
+		return nil, err
+	}
+	result := target.result(obj)
+	result.DryRun = req.DryRun
+	return result, nil
+}
+
+// withResourceVersion adds metadata.resourceVersion to a patch; the API server
+// treats a resourceVersion in the patched object as an update precondition
+func withResourceVersion(patchType types.PatchType, patch []byte, resourceVersion string) ([]byte, error) {
+	if patchType == types.JSONPatchType {
+		var operations []map[string]interface{}
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return nil, fmt.Errorf("a json patch must be an array of operations: %v", err)
+		}
+		if operations == nil {
+			return nil, fmt.Errorf("a json patch must be an array of operations, not null")
+		}
+		if resourceVersion == "" {
+			return patch, nil
+		}
+		operations = append(operations, map[string]interface{}{
+			"op": "add", "path": "/metadata/resourceVersion", "value": resourceVersion,
+		})
+		return json.Marshal(operations)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(patch, &document); err != nil {
+		return nil, fmt.Errorf("a %s patch must be a JSON object: %v", patchType, err)
+	}
+	if document == nil {
+		return nil, fmt.Errorf("a %s patch must be a JSON object, not null", patchType)
+	}
+	if resourceVersion == "" {
+		return patch, nil
+	}
+	if err := unstructured.SetNestedField(document, resourceVersion, "metadata", "resourceVersion"); err != nil {
+		return nil, fmt.Errorf("invalid metadata in patch: %v", err)
+	}
+	return json.Marshal(document)
+}
+
+// metadataPatch builds a merge patch that sets and removes keys in metadata.labels
+// or metadata.annotations; removed keys are set to null
+func metadataPatch(field string, req MetadataRequest) ([]byte, error) {
+	if len(req.Set) == 0 && len(req.Remove) == 0 {
+		return nil, fmt.Errorf("nothing to change: set or remove is required")
+	}
+
+	var problems []string
+	values := map[string]interface{}{}
+	for key, value := range req.Set {
+		for _, msg := range validation.IsQualifiedName(key) {
+			problems = append(problems, fmt.Sprintf("key %q: %s", key, msg))
+		}
+		if field == "labels" {
+			for _, msg := range validation.IsValidLabelValue(value) {
+				problems = append(problems, fmt.Sprintf("value of %q: %s", key, msg))
+			}
+		}
+		values[key] = value
+	}
+	for _, key := range req.Remove {
+		if _, ok := req.Set[key]; ok {
+			problems = append(problems, fmt.Sprintf("key %q is both set and removed", key))
+		}
+		values[key] = nil
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid %s: %s", field, strings.Join(problems, "; "))
+	}
+	return json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{field: values}})
+}
+
+// deleteOptions validates a delete request; a resourceVersion becomes a precondition
+func deleteOptions(req DeleteRequest) (metav1.DeleteOptions, error) {
+	opts := metav1.DeleteOptions{GracePeriodSeconds: req.GracePeriodSeconds}
+	if req.PropagationPolicy != "" {
+		policy := metav1.DeletionPropagation(req.PropagationPolicy)
+		switch policy {
+		case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+			opts.PropagationPolicy = &policy
+		default:
+			return opts, fmt.Errorf("unsupported propagationPolicy %q: use Foreground, Background or Orphan", req.PropagationPolicy)
+		}
+	}
+	if req.ResourceVersion != "" {
+		opts.Preconditions = &metav1.Preconditions{ResourceVersion: &req.ResourceVersion}
+	}
+	if req.DryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	return opts, nil
+}
+
+func (t *resourceTarget) result(obj *unstructured.Unstructured) *MutationResult {
+	result := &MutationResult{
+		Group:     t.gvr.Group,
+		Version:   t.gvr.Version,
+		Resource:  t.gvr.Resource,
+		Namespace: t.namespace,
+		Name:      t.name,
+	}
+	if obj != nil {
+		result.ResourceVersion = obj.GetResourceVersion()
+		cleanManifest(obj)
+		result.Object = obj.Object
+	}
+	return result
+}
+
+// respondMutation writes the result, or the API server's status for a failed mutation:
+// 409 when the resourceVersion precondition failed, 404, 422 and so on
+func respondMutation(c *gin.Context, result *MutationResult, err error) {
+	if err == nil {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	code := http.StatusInternalServerError
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		code = int(status.Status().Code)
+	}
+	body := gin.H{"error": err.Error()}
+	if apierrors.IsConflict(err) {
+		body["reason"] = "the resource was modified since resourceVersion was read; reload it and retry"
+	}
+	c.JSON(code, body)
+}
@@ -0,0 +1,140 @@
+package manifests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPatchResource(t *testing.T) {
+	widgets := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	widget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "gear", "namespace": "shop", "labels": map[string]interface{}{"tier": "web"}},
+		"spec":       map[string]interface{}{"size": int64(1)},
+	}}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), widget)
+	target := &resourceTarget{gvr: widgets, namespace: "shop", name: "gear", client: client.Resource(widgets).Namespace("shop")}
+	ctx := context.Background()
+
+	result, err := patchResource(ctx, target, PatchRequest{Patch: json.RawMessage(`{"spec":{"size":3}}`)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Object["spec"].(map[string]interface{})["size"])
+	assert.Equal(t, "widgets", result.Resource)
+
+	result, err = patchResource(ctx, target, PatchRequest{
+		Type:  PatchTypeJSON,
+		Patch: json.RawMessage(`[{"op":"replace","path":"/spec/size","value":5}]`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.Object["spec"].(map[string]interface{})["size"])
+
+	labels, err := metadataPatch("labels", MetadataRequest{Set: map[string]string{"team": "payments"}, Remove: []string{"tier"}})
+	require.NoError(t, err)
+	result, err = patchResource(ctx, target, PatchRequest{Type: PatchTypeMerge, Patch: labels})
+	require.NoError(t, err)
+	metadata := result.Object["metadata"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"team": "payments"}, metadata["labels"])
+
+	// The precondition travels inside the patch so the API server enforces it
+	_, err = patchResource(ctx, target, PatchRequest{Patch: json.RawMessage(`{"spec":{"size":4}}`), ResourceVersion: "42", DryRun: true})
+	require.NoError(t, err)
+	actions := client.Actions()
+	patch := actions[len(actions)-1].(k8stesting.PatchAction).GetPatch()
+	assert.JSONEq(t, `{"spec":{"size":4},"metadata":{"resourceVersion":"42"}}`, string(patch))
+
+	_, err = patchResource(ctx, target, PatchRequest{Type: "apply", Patch: json.RawMessage(`{}`)})
+	assert.True(t, apierrors.IsBadRequest(err))
+	_, err = patchResource(ctx, target, PatchRequest{Type: PatchTypeJSON, Patch: json.RawMessage(`{"spec":{}}`)})
+	assert.ErrorContains(t, err, "array of operations")
+}
+
+func TestWithResourceVersion(t *testing.T) {
+	patch, err := withResourceVersion("application/json-patch+json", []byte(`[{"op":"remove","path":"/spec/paused"}]`), "7")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"remove","path":"/spec/paused"},
+		{"op":"add","path":"/metadata/resourceVersion","value":"7"}
+	]`, string(patch))
+
+	unchanged, err := withResourceVersion("application/merge-patch+json", []byte(`{"spec":{}}`), "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"spec":{}}`, string(unchanged))
+
+	// A null patch is rejected rather than written into
+	_, err = withResourceVersion("application/merge-patch+json", []byte(`null`), "7")
+	assert.ErrorContains(t, err, "not null")
+	_, err = withResourceVersion("application/json-patch+json", []byte(`null`), "7")
+	assert.ErrorContains(t, err, "not null")
+}
+
+func TestMetadataPatch(t *testing.T) {
+	patch, err := metadataPatch("annotations", MetadataRequest{
+		Set:    map[string]string{"example.com/note": "free text, with spaces"},
+		Remove: []string{"old"},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"example.com/note":"free text, with spaces","old":null}}}`, string(patch))
+
+	_, err = metadataPatch("labels", MetadataRequest{Set: map[string]string{"bad key": "x", "ok": "not valid!"}})
+	assert.ErrorContains(t, err, `key "bad key"`)
+	assert.ErrorContains(t, err, `value of "ok"`)
+
+	_, err = metadataPatch("labels", MetadataRequest{Set: map[string]string{"a": "b"}, Remove: []string{"a"}})
+	assert.ErrorContains(t, err, "both set and removed")
+
+	_, err = metadataPatch("labels", MetadataRequest{})
+	assert.Error(t, err)
+}
+
+func TestDeleteOptions(t *testing.T) {
+	grace := int64(0)
+	opts, err := deleteOptions(DeleteRequest{PropagationPolicy: "Foreground", GracePeriodSeconds: &grace, ResourceVersion: "9", DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, metav1.DeletePropagationForeground, *opts.PropagationPolicy)
+	assert.Equal(t, int64(0), *opts.GracePeriodSeconds)
+	assert.Equal(t, "9", *opts.Preconditions.ResourceVersion)
+	assert.Equal(t, []string{metav1.DryRunAll}, opts.DryRun)
+
+	opts, err = deleteOptions(DeleteRequest{})
+	require.NoError(t, err)
+	assert.Nil(t, opts.PropagationPolicy)
+	assert.Nil(t, opts.Preconditions)
+
+	_, err = deleteOptions(DeleteRequest{PropagationPolicy: "Cascade"})
+	assert.Error(t, err)
+}
+
+func TestRespondMutation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	respond := func(err error) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		respondMutation(c, &MutationResult{Name: "gear"}, err)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, respond(nil).Code)
+
+	stale := respond(apierrors.NewConflict(schema.GroupResource{Resource: "widgets"}, "gear", errors.New("the object has been modified")))
+	assert.Equal(t, http.StatusConflict, stale.Code)
+	assert.Contains(t, stale.Body.String(), "reload it and retry")
+
+	assert.Equal(t, http.StatusNotFound, respond(apierrors.NewNotFound(schema.GroupResource{Resource: "widgets"}, "gear")).Code)
+	assert.Equal(t, http.StatusInternalServerError, respond(errors.New("connection refused")).Code)
+}
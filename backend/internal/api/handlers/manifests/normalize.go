@@ -0,0 +1,380 @@
+package manifests
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Field diff types
+const (
+	FieldChanged = "changed" // both objects set the field to different values
+	FieldAdded   = "added"   // only the second object sets the field
+	FieldRemoved = "removed" // only the first object sets the field
+)
+
+// FieldDiff is one field that differs between two objects
+type FieldDiff struct {
+	Path string      `json:"path"` // e.g. spec.template.spec.containers[name=web].image
+	Type string      `json:"type"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// DiffOptions tunes DiffObjects
+type DiffOptions struct {
+	// IgnoreAdded skips fields only the second object sets, such as the defaults
+	// the server fills in on a live object compared with its manifest
+	IgnoreAdded bool
+}
+
+// serverAnnotations are set by clients and controllers rather than by whoever wrote the manifest
+var serverAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+}
+
+// serverFields are spec fields the server assigns for particular kinds
+var serverFields = map[schema.GroupKind][][]string{
+	{Kind: "Service"}:               {{"spec", "clusterIP"}, {"spec", "clusterIPs"}, {"spec", "ipFamilies"}, {"spec", "ipFamilyPolicy"}},
+	{Kind: "Pod"}:                   {{"spec", "nodeName"}},
+	{Kind: "PersistentVolumeClaim"}: {{"spec", "volumeName"}},
+	{Kind: "ServiceAccount"}:        {{"secrets"}},
+}
+
+// NormalizeManifest strips what the API server adds to an object, on top of what
+// cleanManifest removes, so that it compares equal to the manifest it came from
+func NormalizeManifest(obj *unstructured.Unstructured) {
+	cleanManifest(obj)
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "deletionTimestamp")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "deletionGracePeriodSeconds")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "ownerReferences")
+
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		for _, key := range serverAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+		} else {
+			obj.SetAnnotations(annotations)
+		}
+	}
+	for _, field := range serverFields[obj.GroupVersionKind().GroupKind()] {
+		unstructured.RemoveNestedField(obj.Object, field...)
+	}
+	if isSecret(obj) {
+		foldStringData(obj)
+	}
+}
+
+// autoCreated are objects the control plane creates in every namespace
+var autoCreated = map[schema.GroupKind]string{
+	{Kind: "ConfigMap"}:      "kube-root-ca.crt",
+	{Kind: "ServiceAccount"}: "default",
+}
+
+// IsAutoCreated reports objects the control plane makes rather than anyone applying them,
+// such as each namespace's root CA ConfigMap and service account tokens
+func IsAutoCreated(obj *unstructured.Unstructured) bool {
+	if name, ok := autoCreated[obj.GroupVersionKind().GroupKind()]; ok && name == obj.GetName() {
+		return true
+	}
+	if obj.GetLabels()["kube-aggregator.kubernetes.io/automanaged"] != "" {
+		return true
+	}
+	secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+	return isSecret(obj) && secretType == "kubernetes.io/service-account-token"
+}
+
+// IsGenerated reports objects made by controllers or the control plane rather than
+// applied by anyone: owned objects and those IsAutoCreated reports
+func IsGenerated(obj *unstructured.Unstructured) bool {
+	return len(obj.GetOwnerReferences()) > 0 || IsAutoCreated(obj)
+}
+
+// MaskedValue replaces secret values in diffs
+const MaskedValue = "***"
+
+func isSecret(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind().GroupKind() == schema.GroupKind{Kind: "Secret"}
+}
+
+// foldStringData moves a Secret's stringData into data as base64, as the API server does
+// when it stores the Secret; stringData wins over data for the same key
+func foldStringData(obj *unstructured.Unstructured) {
+	stringData, ok := obj.Object["stringData"].(map[string]interface{})
+	delete(obj.Object, "stringData")
+	if !ok || len(stringData) == 0 {
+		return
+	}
+	data, ok := obj.Object["data"].(map[string]interface{})
+	if !ok {
+		data = make(map[string]interface{}, len(stringData))
+	}
+	for key, value := range stringData {
+		if text, ok := value.(string); ok {
+			data[key] = base64.StdEncoding.EncodeToString([]byte(text))
+		}
+	}
+	obj.Object["data"] = data
+}
+
+// MaskSecretDiffs hides the values of a Secret's data in its diffs, keeping which keys differ
+func MaskSecretDiffs(obj *unstructured.Unstructured, diffs []FieldDiff) []FieldDiff {
+	if !isSecret(obj) {
+		return diffs
+	}
+	for i := range diffs {
+		if isSecretDataPath(diffs[i].Path) {
+			diffs[i].From = maskValue(diffs[i].From)
+			diffs[i].To = maskValue(diffs[i].To)
+		}
+	}
+	return diffs
+}
+
+// MaskSecretData returns copies of two versions of an object with their Secret data and
+// stringData values masked. A value the second version changes is masked as changed, so
+// a diff of the copies shows which keys changed without showing any value.
+func MaskSecretData(from, to *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	maskedFrom, maskedTo := from, to
+	if from != nil && isSecret(from) {
+		maskedFrom = from.DeepCopy()
+		maskSecretFields(maskedFrom, nil)
+	}
+	if to != nil && isSecret(to) {
+		maskedTo = to.DeepCopy()
+		var previous *unstructured.Unstructured
+		if from != nil && isSecret(from) {
+			previous = from
+		}
+		maskSecretFields(maskedTo, previous)
+	}
+	return maskedFrom, maskedTo
+}
+
+// maskSecretFields masks a Secret's data and stringData in place, marking values that
+// differ from the same key in previous
+func maskSecretFields(obj, previous *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := obj.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var before map[string]interface{}
+		if previous != nil {
+			before, _ = previous.Object[field].(map[string]interface{})
+		}
+		for key, value := range data {
+			if old, ok := before[key]; ok && !scalarsEqual(old, value) {
+				data[key] = MaskedValue + " (changed)"
+			} else {
+				data[key] = MaskedValue
+			}
+		}
+	}
+}
+
+func isSecretDataPath(path string) bool {
+	for _, field := range []string{"data", "stringData"} {
+		if path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(path, field+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// maskValue masks a value, or each value of a map so that its keys stay visible
+func maskValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key := range v {
+			masked[key] = MaskedValue
+		}
+		return masked
+	}
+	return MaskedValue
+}
+
+// DiffObjects lists the fields that differ between two objects. Lists whose items all
+// have a name, such as containers or env, are matched by name rather than position.
+func DiffObjects(from, to map[string]interface{}, opts DiffOptions) []FieldDiff {
+	var diffs []FieldDiff
+	diffValues("", from, to, opts, &diffs)
+	return diffs
+}
+
+func diffValues(path string, from, to interface{}, opts DiffOptions, diffs *[]FieldDiff) {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		if toValue, ok := to.(map[string]interface{}); ok {
+			diffMaps(path, fromValue, toValue, opts, diffs)
+			return
+		}
+	case []interface{}:
+		if toValue, ok := to.([]interface{}); ok {
+			diffLists(path, fromValue, toValue, opts, diffs)
+			return
+		}
+	}
+	if !scalarsEqual(from, to) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Type: FieldChanged, From: from, To: to})
+	}
+}
+
+func diffMaps(path string, from, to map[string]interface{}, opts DiffOptions, diffs *[]FieldDiff) {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldPath := joinFieldPath(path, key)
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		switch {
+		case !inTo:
+			*diffs = append(*diffs, FieldDiff{Path: fieldPath, Type: FieldRemoved, From: fromValue})
+		case !inFrom:
+			if !opts.IgnoreAdded {
+				*diffs = append(*diffs, FieldDiff{Path: fieldPath, Type: FieldAdded, To: toValue})
+			}
+		default:
+			diffValues(fieldPath, fromValue, toValue, opts, diffs)
+		}
+	}
+}
+
+func diffLists(path string, from, to []interface{}, opts DiffOptions, diffs *[]FieldDiff) {
+	fromNames, fromNamed := itemNames(from)
+	toNames, toNamed := itemNames(to)
+	if !fromNamed || !toNamed {
+		for i := 0; i < len(from) || i < len(to); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(to):
+				*diffs = append(*diffs, FieldDiff{Path: itemPath, Type: FieldRemoved, From: from[i]})
+			case i >= len(from):
+				if !opts.IgnoreAdded {
+					*diffs = append(*diffs, FieldDiff{Path: itemPath, Type: FieldAdded, To: to[i]})
+				}
+			default:
+				diffValues(itemPath, from[i], to[i], opts, diffs)
+			}
+		}
+		return
+	}
+
+	toIndex := make(map[string]int, len(to))
+	for i, name := range toNames {
+		toIndex[name] = i
+	}
+	fromIndex := make(map[string]bool, len(from))
+	for i, name := range fromNames {
+		fromIndex[name] = true
+		itemPath := fmt.Sprintf("%s[name=%s]", path, name)
+		if j, ok := toIndex[name]; ok {
+			diffValues(itemPath, from[i], to[j], opts, diffs)
+		} else {
+			*diffs = append(*diffs, FieldDiff{Path: itemPath, Type: FieldRemoved, From: from[i]})
+		}
+	}
+	if opts.IgnoreAdded {
+		return
+	}
+	for j, name := range toNames {
+		if !fromIndex[name] {
+			*diffs = append(*diffs, FieldDiff{Path: fmt.Sprintf("%s[name=%s]", path, name), Type: FieldAdded, To: to[j]})
+		}
+	}
+}
+
+// itemNames returns the name of every item, or false unless every item is a map with a unique name
+func itemNames(items []interface{}) ([]string, bool) {
+	names := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || seen[name] {
+			return nil, false
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, len(names) > 0
+}
+
+// scalarsEqual treats numbers of different types and equivalent quantities, such as
+// 0.5 and 500m CPU, as equal since the server rewrites them
+func scalarsEqual(from, to interface{}) bool {
+	if reflect.DeepEqual(from, to) {
+		return true
+	}
+	if fromNumber, ok := toFloat(from); ok {
+		toNumber, ok := toFloat(to)
+		return ok && fromNumber == toNumber
+	}
+	fromString, ok := from.(string)
+	if !ok {
+		return false
+	}
+	toString, ok := to.(string)
+	if !ok {
+		return false
+	}
+	fromQuantity, err := resource.ParseQuantity(fromString)
+	if err != nil {
+		return false
+	}
+	toQuantity, err := resource.ParseQuantity(toString)
+	return err == nil && fromQuantity.Cmp(toQuantity) == 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// joinFieldPath appends a key, bracketing keys such as label names that contain dots
+func joinFieldPath(path, key string) string {
+	if strings.ContainsAny(key, "./") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
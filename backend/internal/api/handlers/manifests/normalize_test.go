@@ -0,0 +1,83 @@
+package manifests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeManifest(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name": "web", "namespace": "shop", "uid": "s1", "resourceVersion": "9",
+			"creationTimestamp": "2026-01-01T00:00:00Z",
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				"team": "payments",
+			},
+		},
+		"spec": map[string]interface{}{
+			"clusterIP":  "10.0.0.12",
+			"clusterIPs": []interface{}{"10.0.0.12"},
+			"selector":   map[string]interface{}{"app": "web"},
+		},
+		"status": map[string]interface{}{"loadBalancer": map[string]interface{}{}},
+	}}
+	NormalizeManifest(obj)
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name": "web", "namespace": "shop",
+			"annotations": map[string]interface{}{"team": "payments"},
+		},
+		"spec": map[string]interface{}{"selector": map[string]interface{}{"app": "web"}},
+	}, obj.Object)
+}
+
+func TestDiffObjects(t *testing.T) {
+	from := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app.kubernetes.io/name": "web"}},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "web:1", "cpu": "0.5"},
+				map[string]interface{}{"name": "proxy", "image": "envoy:1"},
+			},
+			"args": []interface{}{"--verbose"},
+		},
+	}
+	to := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app.kubernetes.io/name": "api"}},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "web:2", "cpu": "500m", "imagePullPolicy": "Always"},
+				map[string]interface{}{"name": "debug", "image": "busybox"},
+			},
+			"args":   []interface{}{"--verbose", "--trace"},
+			"paused": true,
+		},
+	}
+
+	assert.Equal(t, []FieldDiff{
+		{Path: `metadata.labels["app.kubernetes.io/name"]`, Type: FieldChanged, From: "web", To: "api"},
+		{Path: "spec.args[1]", Type: FieldAdded, To: "--trace"},
+		{Path: "spec.containers[name=web].image", Type: FieldChanged, From: "web:1", To: "web:2"},
+		{Path: "spec.containers[name=web].imagePullPolicy", Type: FieldAdded, To: "Always"},
+		{Path: "spec.containers[name=proxy]", Type: FieldRemoved, From: map[string]interface{}{"name": "proxy", "image": "envoy:1"}},
+		{Path: "spec.containers[name=debug]", Type: FieldAdded, To: map[string]interface{}{"name": "debug", "image": "busybox"}},
+		{Path: "spec.paused", Type: FieldAdded, To: true},
+	}, DiffObjects(from, to, DiffOptions{}))
+
+	assert.Equal(t, []FieldDiff{
+		{Path: `metadata.labels["app.kubernetes.io/name"]`, Type: FieldChanged, From: "web", To: "api"},
+		{Path: "spec.containers[name=web].image", Type: FieldChanged, From: "web:1", To: "web:2"},
+		{Path: "spec.containers[name=proxy]", Type: FieldRemoved, From: map[string]interface{}{"name": "proxy", "image": "envoy:1"}},
+	}, DiffObjects(from, to, DiffOptions{IgnoreAdded: true}))
+
+	assert.Empty(t, DiffObjects(from, from, DiffOptions{}))
+}
@@ -0,0 +1,745 @@
+package manifests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+const (
+	defaultRelationDepth = 1
+	maxRelationDepth     = 5
+)
+
+// Relationship of a related resource to the requested one
+const (
+	RelationshipOwner        = "owner"         // owns the resource, directly or through other owners
+	RelationshipChild        = "child"         // owned by the resource, directly or through other children
+	RelationshipReferences   = "references"    // referred to by the resource, e.g. a mounted ConfigMap
+	RelationshipReferencedBy = "referenced-by" // refers to the resource, e.g. a Service selecting a Pod
+)
+
+// Edge types in a relation graph
+const (
+	EdgeOwns           = "owns"
+	EdgeSelects        = "selects"
+	EdgeMounts         = "mounts"
+	EdgeEnv            = "env"
+	EdgeServiceAccount = "serviceAccount"
+	EdgeRoutes         = "routes"
+	EdgeTLS            = "tls"
+	EdgeScales         = "scales"
+)
+
+var (
+	errRelatedRootNotFound = errors.New("resource not found")
+	errRelatedInvalid      = errors.New("invalid request")
+)
+
+// RelatedResource is a resource reached from the requested one
+type RelatedResource struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace,omitempty"`
+	Kind         string `json:"kind"`
+	APIVersion   string `json:"apiVersion"`
+	UID          string `json:"uid,omitempty"`
+	Relationship string `json:"relationship"`
+	Via          string `json:"via"`   // the edge type that reached it
+	Depth        int    `json:"depth"` // hops from the requested resource
+}
+
+// RelationNode is a resource in a relation graph
+type RelationNode struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+	Depth      int    `json:"depth"`
+}
+
+// RelationEdge points from an owner to what it owns, or from a resource to what it refers to
+type RelationEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// RelationGraph is every resource within depth hops of the root
+type RelationGraph struct {
+	Root     string         `json:"root"`
+	Depth    int            `json:"depth"`
+	Nodes    []RelationNode `json:"nodes"`
+	Edges    []RelationEdge `json:"edges"`
+	Warnings []string       `json:"warnings,omitempty"` // resource types that could not be listed
+}
+
+// reference is an object another object refers to
+type reference struct {
+	target   *unstructured.Unstructured
+	edgeType string
+}
+
+// referenceResolver finds the objects an object refers to by name or selector.
+// Ownership needs no resolver: it comes from ownerReferences on every object.
+type referenceResolver struct {
+	name    string
+	resolve func(obj *unstructured.Unstructured, index *objectIndex) []reference
+}
+
+// specRelationKinds are the kinds whose spec the reference resolvers read; they are listed in full
+var specRelationKinds = map[schema.GroupKind]bool{
+	{Kind: "Pod"}:                                                true,
+	{Kind: "Service"}:                                            true,
+	{Kind: "ReplicationController"}:                              true,
+	{Group: "apps", Kind: "Deployment"}:                          true,
+	{Group: "apps", Kind: "ReplicaSet"}:                          true,
+	{Group: "apps", Kind: "StatefulSet"}:                         true,
+	{Group: "apps", Kind: "DaemonSet"}:                           true,
+	{Group: "batch", Kind: "Job"}:                                true,
+	{Group: "batch", Kind: "CronJob"}:                            true,
+	{Group: "policy", Kind: "PodDisruptionBudget"}:               true,
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"}:          true,
+	{Group: "networking.k8s.io", Kind: "Ingress"}:                true,
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}:      true,
+	{Group: "autoscaling.k8s.io", Kind: "VerticalPodAutoscaler"}: true,
+}
+
+// namedRelationKinds are only looked up by name, so their metadata is enough
+var namedRelationKinds = map[schema.GroupKind]bool{
+	{Kind: "ConfigMap"}:             true,
+	{Kind: "Secret"}:                true,
+	{Kind: "PersistentVolumeClaim"}: true,
+	{Kind: "ServiceAccount"}:        true,
+}
+
+var referenceResolvers = []referenceResolver{
+	{name: "selector", resolve: selectorReferences},
+	{name: "volume", resolve: volumeReferences},
+	{name: "envFrom", resolve: envReferences},
+	{name: "serviceAccount", resolve: serviceAccountReferences},
+	{name: "ingressBackend", resolve: ingressReferences},
+	{name: "scaleTargetRef", resolve: scaleTargetReferences},
+}
+
+// GetRelatedResources returns resources related to a given resource (owners, children and references)
+// GET /api/v1/manifests/related?context=...&name=...&kind=...&apiVersion=...&namespace=...&depth=2
+func GetRelatedResources(c *gin.Context) {
+	respondRelated(c, c.Query("context"), c.Query("name"))
+}
+
+// GetRelatedResourcesWithPath handles path-based related resources endpoint
+func GetRelatedResourcesWithPath(c *gin.Context) {
+	respondRelated(c, c.Param("context"), c.Param("name"))
+}
+
+func respondRelated(c *gin.Context, clusterContext, name string) {
+	namespace := c.Query("namespace")
+	kind := c.Query("kind")
+	apiVersion := c.Query("apiVersion")
+
+	if clusterContext == "" || name == "" || kind == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context, name, and kind are required"})
+		return
+	}
+	depth := defaultRelationDepth
+	if value := c.Query("depth"); value != "" {
+		var err error
+		if depth, err = strconv.Atoi(value); err != nil || depth < 1 || depth > maxRelationDepth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("depth must be between 1 and %d", maxRelationDepth)})
+			return
+		}
+	}
+
+	conn, err := clusterManager.GetConnection(clusterContext)
+	if err != nil || conn == nil {
+		errMsg := "cluster not connected"
+		if err != nil {
+			errMsg = fmt.Sprintf("cluster not connected: %v", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg, "context": clusterContext})
+		return
+	}
+	dynamicClient, err := dynamic.NewForConfig(conn.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create dynamic client: %v", err)})
+		return
+	}
+	metadataClient, err := metadata.NewForConfig(conn.Config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create metadata client: %v", err)})
+		return
+	}
+
+	graph, related, err := relatedGraph(c.Request.Context(), conn.ClientSet.Discovery(), dynamicClient, metadataClient, kind, apiVersion, namespace, name, depth)
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errRelatedRootNotFound):
+			code = http.StatusNotFound
+		case errors.Is(err, errRelatedInvalid):
+			code = http.StatusBadRequest
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"resources": related,
+		"graph":     graph,
+	})
+}
+
+// relatedGraph lists the resources in the root's scope that the resolvers and ownerReferences
+// can reach, links them and walks the links out to depth hops from the root
+func relatedGraph(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface, metadataClient metadata.Interface, kind, apiVersion, namespace, name string, depth int) (*RelationGraph, []RelatedResource, error) {
+	resourceTypes, err := DiscoverResourceTypes(discoveryClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootType, err := findRelationType(resourceTypes, kind, apiVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rootType.Namespaced && namespace == "" {
+		return nil, nil, fmt.Errorf("%w: namespace is required for namespaced resources", errRelatedInvalid)
+	}
+	if !rootType.Namespaced {
+		namespace = ""
+	}
+
+	// Namespaced resources relate within their namespace; cluster-scoped ones to each other.
+	// Kinds the resolvers read are listed in full, the ones they look up and the root's own
+	// kind by metadata only.
+	var scoped, specTypes, metadataTypes []ResourceType
+	listed := make(map[schema.GroupKind]bool)
+	for _, resourceType := range resourceTypes {
+		if resourceType.Namespaced != rootType.Namespaced {
+			continue
+		}
+		scoped = append(scoped, resourceType)
+		groupKind := resourceType.groupKind()
+		switch {
+		case specRelationKinds[groupKind]:
+			specTypes = append(specTypes, resourceType)
+		case namedRelationKinds[groupKind] || groupKind == rootType.groupKind():
+			metadataTypes = append(metadataTypes, resourceType)
+		default:
+			continue
+		}
+		listed[groupKind] = true
+	}
+	objects, warnings := ListObjects(ctx, DynamicLister(dynamicClient, metav1.ListOptions{}), specTypes, namespace)
+	metadataObjects, metadataWarnings := ListObjects(ctx, metadataLister(metadataClient), metadataTypes, namespace)
+	objects = append(objects, metadataObjects...)
+	warnings = append(warnings, metadataWarnings...)
+
+	// Owners and scale targets of other kinds, such as custom controllers, are listed by
+	// metadata a hop at a time
+	for round := 0; round < depth; round++ {
+		var next []ResourceType
+		targets := relationTargetKinds(objects)
+		for _, resourceType := range scoped {
+			if groupKind := resourceType.groupKind(); targets[groupKind] && !listed[groupKind] {
+				listed[groupKind] = true
+				next = append(next, resourceType)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		nextObjects, nextWarnings := ListObjects(ctx, metadataLister(metadataClient), next, namespace)
+		objects = append(objects, nextObjects...)
+		warnings = append(warnings, nextWarnings...)
+	}
+	sort.Strings(warnings)
+
+	index := newObjectIndex(objects)
+	root := index.get(rootType.GVR.Group, rootType.Kind, namespace, name)
+	if root == nil {
+		return nil, nil, fmt.Errorf("%w: %s %s", errRelatedRootNotFound, rootType.Kind, name)
+	}
+	graph, related := walkRelations(index, buildRelationEdges(index, referenceResolvers), root, depth)
+	graph.Warnings = warnings
+	return graph, related, nil
+}
+
+// relationTargetKinds returns the kinds the objects' owners and scale targets are
+func relationTargetKinds(objects []unstructured.Unstructured) map[schema.GroupKind]bool {
+	kinds := make(map[schema.GroupKind]bool)
+	add := func(apiVersion, kind string) {
+		if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && kind != "" {
+			kinds[gv.WithKind(kind).GroupKind()] = true
+		}
+	}
+	for i := range objects {
+		obj := &objects[i]
+		for _, owner := range obj.GetOwnerReferences() {
+			add(owner.APIVersion, owner.Kind)
+		}
+		if field := scaleTargetField(obj); field != "" {
+			add(nestedString(obj.Object, "spec", field, "apiVersion"), nestedString(obj.Object, "spec", field, "kind"))
+		}
+	}
+	return kinds
+}
+
+func (t ResourceType) groupKind() schema.GroupKind {
+	return schema.GroupKind{Group: t.GVR.Group, Kind: t.Kind}
+}
+
+// findRelationType matches the requested kind, narrowed by apiVersion's group when given
+func findRelationType(resourceTypes []ResourceType, kind, apiVersion string) (ResourceType, error) {
+	group, hasGroup := "", false
+	if apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return ResourceType{}, fmt.Errorf("%w: apiVersion %s", errRelatedInvalid, apiVersion)
+		}
+		group, hasGroup = gv.Group, true
+	}
+	for _, resourceType := range resourceTypes {
+		if strings.EqualFold(resourceType.Kind, kind) && (!hasGroup || resourceType.GVR.Group == group) {
+			return resourceType, nil
+		}
+	}
+	return ResourceType{}, fmt.Errorf("%w: kind %s is not served by the cluster", errRelatedRootNotFound, kind)
+}
+
+// metadataLister lists only the objects' metadata, typed as their own kind
+func metadataLister(metadataClient metadata.Interface) Lister {
+	return func(ctx context.Context, resourceType ResourceType, namespace string) ([]unstructured.Unstructured, error) {
+		list, err := metadataClient.Resource(resourceType.GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]unstructured.Unstructured, 0, len(list.Items))
+		for i := range list.Items {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&list.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			obj := unstructured.Unstructured{Object: content}
+			obj.SetAPIVersion(resourceType.GVR.GroupVersion().String())
+			obj.SetKind(resourceType.Kind)
+			objects = append(objects, obj)
+		}
+		return objects, nil
+	}
+}
+
+// objectIndex finds listed objects by identity, UID and kind
+type objectIndex struct {
+	byID     map[string]*unstructured.Unstructured
+	byUID    map[string]string
+	byKind   map[string]map[string]*unstructured.Unstructured // kind -> namespace/name, ignoring the group
+	ordered  []string
+	external map[string]RelationNode // owners that were not listed, e.g. cluster-scoped owners of namespaced objects
+}
+
+func newObjectIndex(objects []unstructured.Unstructured) *objectIndex {
+	index := &objectIndex{
+		byID:     make(map[string]*unstructured.Unstructured),
+		byUID:    make(map[string]string),
+		byKind:   make(map[string]map[string]*unstructured.Unstructured),
+		external: make(map[string]RelationNode),
+	}
+	for i := range objects {
+		obj := &objects[i]
+		id := relationNodeID(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		if _, ok := index.byID[id]; ok {
+			continue
+		}
+		index.byID[id] = obj
+		index.ordered = append(index.ordered, id)
+		if uid := string(obj.GetUID()); uid != "" {
+			index.byUID[uid] = id
+		}
+		if index.byKind[obj.GetKind()] == nil {
+			index.byKind[obj.GetKind()] = make(map[string]*unstructured.Unstructured)
+		}
+		index.byKind[obj.GetKind()][obj.GetNamespace()+"/"+obj.GetName()] = obj
+	}
+	sort.Strings(index.ordered)
+	return index
+}
+
+func (i *objectIndex) get(group, kind, namespace, name string) *unstructured.Unstructured {
+	return i.byID[relationNodeID(schema.GroupVersion{Group: group}.String(), kind, namespace, name)]
+}
+
+// find looks an object up by kind, namespace and name in any group
+func (i *objectIndex) find(kind, namespace, name string) *unstructured.Unstructured {
+	return i.byKind[kind][namespace+"/"+name]
+}
+
+// relationNodeID identifies an object as Kind.group/namespace/name; the version is left
+// out so that ownerReferences and lists at different versions agree
+func relationNodeID(apiVersion, kind, namespace, name string) string {
+	qualified := kind
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && gv.Group != "" {
+		qualified = kind + "." + gv.Group
+	}
+	return qualified + "/" + namespace + "/" + name
+}
+
+func objectNodeID(obj *unstructured.Unstructured) string {
+	return relationNodeID(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// buildRelationEdges links owners to the objects they own and objects to what they refer to
+func buildRelationEdges(index *objectIndex, resolvers []referenceResolver) []RelationEdge {
+	seen := make(map[RelationEdge]bool)
+	var edges []RelationEdge
+	add := func(edge RelationEdge) {
+		if edge.From != edge.To && !seen[edge] {
+			seen[edge] = true
+			edges = append(edges, edge)
+		}
+	}
+
+	for _, id := range index.ordered {
+		obj := index.byID[id]
+		for _, owner := range obj.GetOwnerReferences() {
+			ownerID, ok := index.byUID[string(owner.UID)]
+			if !ok {
+				// The owner was not listed: it may be cluster-scoped or already deleted
+				ownerID = relationNodeID(owner.APIVersion, owner.Kind, obj.GetNamespace(), owner.Name)
+				if _, listed := index.byID[ownerID]; !listed {
+					ownerID = relationNodeID(owner.APIVersion, owner.Kind, "", owner.Name)
+					index.external[ownerID] = RelationNode{
+						ID: ownerID, Kind: owner.Kind, APIVersion: owner.APIVersion, Name: owner.Name, UID: string(owner.UID),
+					}
+				}
+			}
+			add(RelationEdge{From: ownerID, To: id, Type: EdgeOwns})
+		}
+		for _, resolver := range resolvers {
+			for _, ref := range resolver.resolve(obj, index) {
+				add(RelationEdge{From: id, To: objectNodeID(ref.target), Type: ref.edgeType})
+			}
+		}
+	}
+	return edges
+}
+
+// walkRelations collects the nodes within depth hops of the root. The walk keeps going in
+// the direction it started: up through owners (and what refers to them), down through
+// children (and what they refer to), along references, or back along references.
+func walkRelations(index *objectIndex, edges []RelationEdge, root *unstructured.Unstructured, depth int) (*RelationGraph, []RelatedResource) {
+	outgoing := make(map[string][]RelationEdge)
+	incoming := make(map[string][]RelationEdge)
+	for _, edge := range edges {
+		outgoing[edge.From] = append(outgoing[edge.From], edge)
+		incoming[edge.To] = append(incoming[edge.To], edge)
+	}
+
+	type step struct {
+		id           string
+		depth        int
+		relationship string // empty for the root
+	}
+	type hop struct {
+		id           string
+		edge         RelationEdge
+		relationship string
+	}
+	rootID := objectNodeID(root)
+	graph := &RelationGraph{Root: rootID, Depth: depth, Nodes: []RelationNode{}, Edges: []RelationEdge{}}
+	related := []RelatedResource{}
+	visited := map[string]bool{rootID: true}
+	graph.Nodes = append(graph.Nodes, index.node(rootID, 0))
+	seenEdges := make(map[RelationEdge]bool)
+	queue := []step{{id: rootID}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.depth == depth {
+			continue
+		}
+
+		var next []hop
+		follow := func(relationship string, candidates []RelationEdge, owns bool, forward bool) {
+			for _, edge := range candidates {
+				if (edge.Type == EdgeOwns) != owns {
+					continue
+				}
+				id := edge.From
+				if forward {
+					id = edge.To
+				}
+				next = append(next, hop{id: id, edge: edge, relationship: relationship})
+			}
+		}
+		switch current.relationship {
+		case "":
+			follow(RelationshipOwner, incoming[current.id], true, false)
+			follow(RelationshipChild, outgoing[current.id], true, true)
+			follow(RelationshipReferences, outgoing[current.id], false, true)
+			follow(RelationshipReferencedBy, incoming[current.id], false, false)
+		case RelationshipOwner:
+			follow(RelationshipOwner, incoming[current.id], true, false)
+			follow(RelationshipReferencedBy, incoming[current.id], false, false)
+		case RelationshipChild:
+			follow(RelationshipChild, outgoing[current.id], true, true)
+			follow(RelationshipReferences, outgoing[current.id], false, true)
+		case RelationshipReferences:
+			follow(RelationshipReferences, outgoing[current.id], false, true)
+		case RelationshipReferencedBy:
+			follow(RelationshipReferencedBy, incoming[current.id], false, false)
+		}
+
+		for _, candidate := range next {
+			if !seenEdges[candidate.edge] {
+				seenEdges[candidate.edge] = true
+				graph.Edges = append(graph.Edges, candidate.edge)
+			}
+			if visited[candidate.id] {
+				continue
+			}
+			visited[candidate.id] = true
+			node := index.node(candidate.id, current.depth+1)
+			graph.Nodes = append(graph.Nodes, node)
+			related = append(related, RelatedResource{
+				Name:         node.Name,
+				Namespace:    node.Namespace,
+				Kind:         node.Kind,
+				APIVersion:   node.APIVersion,
+				UID:          node.UID,
+				Relationship: candidate.relationship,
+				Via:          candidate.edge.Type,
+				Depth:        node.Depth,
+			})
+			queue = append(queue, step{id: candidate.id, depth: current.depth + 1, relationship: candidate.relationship})
+		}
+	}
+	return graph, related
+}
+
+func (i *objectIndex) node(id string, depth int) RelationNode {
+	if obj, ok := i.byID[id]; ok {
+		return RelationNode{
+			ID:         id,
+			Kind:       obj.GetKind(),
+			APIVersion: obj.GetAPIVersion(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			UID:        string(obj.GetUID()),
+			Depth:      depth,
+		}
+	}
+	node := i.external[id]
+	node.Depth = depth
+	return node
+}
+
+// podSpec returns the pod spec of a pod or of a built-in workload's pod template
+func podSpec(obj *unstructured.Unstructured) map[string]interface{} {
+	path := podSpecPath(obj)
+	if path == nil {
+		return nil
+	}
+	spec, _, _ := unstructured.NestedMap(obj.Object, path...)
+	return spec
+}
+
+// podSpecPath is where a kind keeps its pod spec, nil for kinds without one
+func podSpecPath(obj *unstructured.Unstructured) []string {
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Pod"}:
+		return []string{"spec"}
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}, schema.GroupKind{Group: "apps", Kind: "ReplicaSet"},
+		schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, schema.GroupKind{Group: "apps", Kind: "DaemonSet"},
+		schema.GroupKind{Group: "batch", Kind: "Job"}, schema.GroupKind{Kind: "ReplicationController"}:
+		return []string{"spec", "template", "spec"}
+	case schema.GroupKind{Group: "batch", Kind: "CronJob"}:
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	return nil
+}
+
+// nestedMaps returns the maps in a list field, skipping anything else
+func nestedMaps(obj map[string]interface{}, fields ...string) []map[string]interface{} {
+	items, _, _ := unstructured.NestedSlice(obj, fields...)
+	var maps []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
+
+func nestedString(obj map[string]interface{}, fields ...string) string {
+	value, _, _ := unstructured.NestedString(obj, fields...)
+	return value
+}
+
+// namedReferences looks up each named object of a kind in the object's namespace
+func namedReferences(obj *unstructured.Unstructured, index *objectIndex, kind, edgeType string, names ...string) []reference {
+	var refs []reference
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if target := index.find(kind, obj.GetNamespace(), name); target != nil {
+			refs = append(refs, reference{target: target, edgeType: edgeType})
+		}
+	}
+	return refs
+}
+
+// selectorReferences links Services, PodDisruptionBudgets and NetworkPolicies to the pods they select
+func selectorReferences(obj *unstructured.Unstructured, index *objectIndex) []reference {
+	var selector metav1.LabelSelector
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Service"}:
+		matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+		selector.MatchLabels = matchLabels
+	case schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"}:
+		raw, _, _ := unstructured.NestedMap(obj.Object, "spec", "selector")
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &selector) != nil {
+			return nil
+		}
+	case schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}:
+		raw, _, _ := unstructured.NestedMap(obj.Object, "spec", "podSelector")
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &selector) != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	// An empty selector matches every pod (or, for a Service, none); neither is a useful link
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+		return nil
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		return nil
+	}
+
+	var refs []reference
+	for _, pod := range index.byKind["Pod"] {
+		if pod.GetNamespace() == obj.GetNamespace() && labelSelector.Matches(labels.Set(pod.GetLabels())) {
+			refs = append(refs, reference{target: pod, edgeType: EdgeSelects})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].target.GetName() < refs[j].target.GetName() })
+	return refs
+}
+
+// volumeReferences links pods and workloads to the ConfigMaps, Secrets and claims they mount
+func volumeReferences(obj *unstructured.Unstructured, index *objectIndex) []reference {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+	var configMaps, secrets, claims []string
+	for _, volume := range nestedMaps(spec, "volumes") {
+		configMaps = append(configMaps, nestedString(volume, "configMap", "name"))
+		secrets = append(secrets, nestedString(volume, "secret", "secretName"))
+		claims = append(claims, nestedString(volume, "persistentVolumeClaim", "claimName"))
+		for _, source := range nestedMaps(volume, "projected", "sources") {
+			configMaps = append(configMaps, nestedString(source, "configMap", "name"))
+			secrets = append(secrets, nestedString(source, "secret", "name"))
+		}
+	}
+	refs := namedReferences(obj, index, "ConfigMap", EdgeMounts, configMaps...)
+	refs = append(refs, namedReferences(obj, index, "Secret", EdgeMounts, secrets...)...)
+	return append(refs, namedReferences(obj, index, "PersistentVolumeClaim", EdgeMounts, claims...)...)
+}
+
+// envReferences links pods and workloads to the ConfigMaps and Secrets their containers read env from
+func envReferences(obj *unstructured.Unstructured, index *objectIndex) []reference {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+	var configMaps, secrets []string
+	for _, field := range []string{"initContainers", "containers"} {
+		for _, container := range nestedMaps(spec, field) {
+			for _, source := range nestedMaps(container, "envFrom") {
+				configMaps = append(configMaps, nestedString(source, "configMapRef", "name"))
+				secrets = append(secrets, nestedString(source, "secretRef", "name"))
+			}
+			for _, env := range nestedMaps(container, "env") {
+				configMaps = append(configMaps, nestedString(env, "valueFrom", "configMapKeyRef", "name"))
+				secrets = append(secrets, nestedString(env, "valueFrom", "secretKeyRef", "name"))
+			}
+		}
+	}
+	refs := namedReferences(obj, index, "ConfigMap", EdgeEnv, configMaps...)
+	return append(refs, namedReferences(obj, index, "Secret", EdgeEnv, secrets...)...)
+}
+
+// serviceAccountReferences links pods and workloads to the ServiceAccount they run as
+func serviceAccountReferences(obj *unstructured.Unstructured, index *objectIndex) []reference {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+	name := nestedString(spec, "serviceAccountName")
+	if name == "" {
+		name = "default"
+	}
+	return namedReferences(obj, index, "ServiceAccount", EdgeServiceAccount, name)
+}
+
+// ingressReferences links Ingresses to their backend Services and TLS Secrets
+func ingressReferences(obj *unstructured.Unstructured, index *objectIndex) []reference {
+	if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}) {
+		return nil
+	}
+	backends := []string{nestedString(obj.Object, "spec", "defaultBackend", "service", "name")}
+	for _, rule := range nestedMaps(obj.Object, "spec", "rules") {
+		for _, path := range nestedMaps(rule, "http", "paths") {
+			backends = append(backends, nestedString(path, "backend", "service", "name"))
+		}
+	}
+	var tlsSecrets []string
+	for _, tls := range nestedMaps(obj.Object, "spec", "tls") {
+		tlsSecrets = append(tlsSecrets, nestedString(tls, "secretName"))
+	}
+	refs := namedReferences(obj, index, "Service", EdgeRoutes, backends...)
+	return append(refs, namedReferences(obj, index, "Secret", EdgeTLS, tlsSecrets...)...)
+}
+
+// scaleTargetReferences links HorizontalPodAutoscalers and VerticalPodAutoscalers to their targets
+func scaleTargetReferences(obj *unstructured.Unstructured, index *objectIndex) []reference {
+	field := scaleTargetField(obj)
+	if field == "" {
+		return nil
+	}
+	kind := nestedString(obj.Object, "spec", field, "kind")
+	name := nestedString(obj.Object, "spec", field, "name")
+	if kind == "" {
+		return nil
+	}
+	return namedReferences(obj, index, kind, EdgeScales, name)
+}
+
+// scaleTargetField is the spec field an autoscaler names its target in, empty for other kinds
+func scaleTargetField(obj *unstructured.Unstructured) string {
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}:
+		return "scaleTargetRef"
+	case schema.GroupKind{Group: "autoscaling.k8s.io", Kind: "VerticalPodAutoscaler"}:
+		return "targetRef"
+	}
+	return ""
+}
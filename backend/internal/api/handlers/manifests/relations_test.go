@@ -0,0 +1,268 @@
+package manifests
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakePreferredDiscovery serves its Resources as the preferred ones, which the fake
+// discovery client leaves empty
+type fakePreferredDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d fakePreferredDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+var relationResources = []*metav1.APIResourceList{
+	{GroupVersion: "v1", APIResources: []metav1.APIResource{
+		{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+		{Name: "services", Kind: "Service", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "nodes", Kind: "Node", Namespaced: false, Verbs: metav1.Verbs{"get", "list"}},
+	}},
+	{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+		{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+		{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+	}},
+	{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{
+		{Name: "ingresses", Kind: "Ingress", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+	}},
+	{GroupVersion: "autoscaling/v2", APIResources: []metav1.APIResource{
+		{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+	}},
+	{GroupVersion: "argoproj.io/v1alpha1", APIResources: []metav1.APIResource{
+		{Name: "rollouts", Kind: "Rollout", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+	}},
+}
+
+func relationObject(apiVersion, kind, name, uid string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "shop", "uid": uid},
+	}}
+	for key, value := range fields {
+		obj.Object[key] = value
+	}
+	return obj
+}
+
+// relationMetadata is an object as the metadata client serves it
+func relationMetadata(obj *unstructured.Unstructured) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()},
+		ObjectMeta: metav1.ObjectMeta{Name: obj.GetName(), Namespace: obj.GetNamespace(), UID: obj.GetUID(), OwnerReferences: obj.GetOwnerReferences()},
+	}
+}
+
+func ownedBy(obj *unstructured.Unstructured, apiVersion, kind, name, uid string) *unstructured.Unstructured {
+	controller := true
+	obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: name, UID: k8stypes.UID(uid), Controller: &controller}})
+	return obj
+}
+
+// newRelationClients serves a Deployment whose Pod mounts a ConfigMap, reads a Secret and
+// runs as a ServiceAccount, with a Service and Ingress in front and an HPA scaling it. The
+// metadata client serves the ConfigMaps, Secrets and ServiceAccount too, and a Rollout.
+func newRelationClients() (fakePreferredDiscovery, *dynamicfake.FakeDynamicClient, *metadatafake.FakeMetadataClient) {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, list := range relationResources {
+		gv, _ := schema.ParseGroupVersion(list.GroupVersion)
+		for _, resource := range list.APIResources {
+			listKinds[gv.WithResource(resource.Name)] = resource.Kind + "List"
+		}
+	}
+	pod := relationObject("v1", "Pod", "web-abc-1", "p1", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"serviceAccountName": "web-sa",
+			"volumes": []interface{}{
+				map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "web-config"}},
+				map[string]interface{}{"name": "gone", "secret": map[string]interface{}{"secretName": "missing"}},
+			},
+			"containers": []interface{}{map[string]interface{}{
+				"name":    "web",
+				"envFrom": []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": "web-secret"}}},
+			}},
+		},
+	})
+	pod.SetLabels(map[string]string{"app": "web"})
+	orphan := ownedBy(relationObject("v1", "Pod", "static-web", "p2", nil), "v1", "Node", "node-1", "n1")
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds,
+		relationObject("apps/v1", "Deployment", "web", "d1", nil),
+		ownedBy(relationObject("apps/v1", "ReplicaSet", "web-abc", "r1", nil), "apps/v1", "Deployment", "web", "d1"),
+		ownedBy(pod, "apps/v1", "ReplicaSet", "web-abc", "r1"),
+		orphan,
+		relationObject("v1", "Service", "web", "s1", map[string]interface{}{
+			"spec": map[string]interface{}{"selector": map[string]interface{}{"app": "web"}},
+		}),
+		relationObject("networking.k8s.io/v1", "Ingress", "web", "i1", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"tls": []interface{}{map[string]interface{}{"secretName": "web-tls"}},
+				"rules": []interface{}{map[string]interface{}{"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{"path": "/", "backend": map[string]interface{}{"service": map[string]interface{}{"name": "web"}}},
+				}}}},
+			},
+		}),
+		relationObject("autoscaling/v2", "HorizontalPodAutoscaler", "web", "h1", map[string]interface{}{
+			"spec": map[string]interface{}{"scaleTargetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web"}},
+		}),
+	)
+	named := []*unstructured.Unstructured{
+		relationObject("v1", "ConfigMap", "web-config", "c1", nil),
+		relationObject("v1", "ConfigMap", "unrelated", "c2", nil),
+		relationObject("v1", "Secret", "web-secret", "x1", nil),
+		relationObject("v1", "Secret", "web-tls", "x2", nil),
+		relationObject("v1", "ServiceAccount", "web-sa", "a1", nil),
+	}
+	scheme := metadatafake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	metadataClient := metadatafake.NewSimpleMetadataClient(scheme,
+		relationMetadata(relationObject("argoproj.io/v1alpha1", "Rollout", "canary", "o1", nil)))
+	for _, obj := range named {
+		if err := client.Tracker().Add(obj); err != nil {
+			panic(err)
+		}
+		if err := metadataClient.Tracker().Add(relationMetadata(obj)); err != nil {
+			panic(err)
+		}
+	}
+	discovery := fakePreferredDiscovery{&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: relationResources}}}
+	return discovery, client, metadataClient
+}
+
+// listedResources returns the resources a fake client listed
+func listedResources(actions []k8stesting.Action) []string {
+	var resources []string
+	for _, action := range actions {
+		if action.GetVerb() == "list" {
+			resources = append(resources, action.GetResource().Resource)
+		}
+	}
+	return resources
+}
+
+// summarize maps kind/name to relationship/via@depth
+func summarize(related []RelatedResource) map[string]string {
+	summary := map[string]string{}
+	for _, resource := range related {
+		summary[resource.Kind+"/"+resource.Name] = resource.Relationship + "/" + resource.Via + "@" + strconv.Itoa(resource.Depth)
+	}
+	return summary
+}
+
+func TestRelatedGraphPod(t *testing.T) {
+	discovery, client, metadataClient := newRelationClients()
+	ctx := context.Background()
+
+	graph, related, err := relatedGraph(ctx, discovery, client, metadataClient, "Pod", "v1", "shop", "web-abc-1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Pod/shop/web-abc-1", graph.Root)
+	assert.Equal(t, map[string]string{
+		"ReplicaSet/web-abc":    "owner/owns@1",
+		"ConfigMap/web-config":  "references/mounts@1",
+		"Secret/web-secret":     "references/env@1",
+		"ServiceAccount/web-sa": "references/serviceAccount@1",
+		"Service/web":           "referenced-by/selects@1",
+	}, summarize(related))
+	assert.Len(t, graph.Nodes, 6)
+	assert.Contains(t, graph.Edges, RelationEdge{From: "ReplicaSet.apps/shop/web-abc", To: "Pod/shop/web-abc-1", Type: EdgeOwns})
+	assert.Contains(t, graph.Edges, RelationEdge{From: "Service/shop/web", To: "Pod/shop/web-abc-1", Type: EdgeSelects})
+	assert.Empty(t, graph.Warnings)
+
+	// Only the kinds the resolvers read are listed in full, and Rollouts not at all
+	assert.ElementsMatch(t, []string{"pods", "services", "deployments", "replicasets", "ingresses", "horizontalpodautoscalers"},
+		listedResources(client.Actions()))
+	assert.ElementsMatch(t, []string{"configmaps", "secrets", "serviceaccounts"}, listedResources(metadataClient.Actions()))
+
+	// Further out, the walk keeps its direction: owners lead to the Deployment and its
+	// HPA, and the Service to its Ingress, but not back down to sibling references
+	_, related, err = relatedGraph(ctx, discovery, client, metadataClient, "pod", "", "shop", "web-abc-1", 3)
+	require.NoError(t, err)
+	summary := summarize(related)
+	assert.Equal(t, "owner/owns@2", summary["Deployment/web"])
+	assert.Equal(t, "referenced-by/scales@3", summary["HorizontalPodAutoscaler/web"])
+	assert.Equal(t, "referenced-by/routes@2", summary["Ingress/web"])
+	assert.NotContains(t, summary, "Secret/web-tls")
+	assert.NotContains(t, summary, "ConfigMap/unrelated")
+}
+
+func TestRelatedGraphOwnersAndChildren(t *testing.T) {
+	discovery, client, metadataClient := newRelationClients()
+	ctx := context.Background()
+
+	_, related, err := relatedGraph(ctx, discovery, client, metadataClient, "Deployment", "apps/v1", "shop", "web", 2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ReplicaSet/web-abc":          "child/owns@1",
+		"HorizontalPodAutoscaler/web": "referenced-by/scales@1",
+		"Pod/web-abc-1":               "child/owns@2",
+	}, summarize(related))
+
+	// Owners that were not listed, such as a Node, still appear
+	graph, related, err := relatedGraph(ctx, discovery, client, metadataClient, "Pod", "v1", "shop", "static-web", 1)
+	require.NoError(t, err)
+	require.Len(t, related, 1)
+	assert.Equal(t, RelatedResource{Name: "node-1", Kind: "Node", APIVersion: "v1", UID: "n1", Relationship: RelationshipOwner, Via: EdgeOwns, Depth: 1}, related[0])
+	assert.Equal(t, "Node//node-1", graph.Nodes[1].ID)
+
+	// The Ingress references its backend and TLS Secret
+	_, related, err = relatedGraph(ctx, discovery, client, metadataClient, "Ingress", "networking.k8s.io/v1", "shop", "web", 1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Service/web":    "references/routes@1",
+		"Secret/web-tls": "references/tls@1",
+	}, summarize(related))
+
+	// Owners of other kinds are listed by metadata once something refers to them
+	replicaSets := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	_, err = client.Resource(replicaSets).Namespace("shop").Create(ctx,
+		ownedBy(relationObject("apps/v1", "ReplicaSet", "canary-xyz", "r2", nil), "argoproj.io/v1alpha1", "Rollout", "canary", "o1"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	graph, related, err = relatedGraph(ctx, discovery, client, metadataClient, "ReplicaSet", "apps/v1", "shop", "canary-xyz", 1)
+	require.NoError(t, err)
+	require.Len(t, related, 1)
+	assert.Equal(t, RelatedResource{Name: "canary", Namespace: "shop", Kind: "Rollout", APIVersion: "argoproj.io/v1alpha1", UID: "o1", Relationship: RelationshipOwner, Via: EdgeOwns, Depth: 1}, related[0])
+	assert.Empty(t, graph.Warnings)
+}
+
+func TestRelatedGraphErrors(t *testing.T) {
+	discovery, client, metadataClient := newRelationClients()
+	ctx := context.Background()
+
+	_, _, err := relatedGraph(ctx, discovery, client, metadataClient, "Pod", "v1", "shop", "missing", 1)
+	assert.True(t, errors.Is(err, errRelatedRootNotFound))
+	_, _, err = relatedGraph(ctx, discovery, client, metadataClient, "Widget", "", "shop", "web", 1)
+	assert.True(t, errors.Is(err, errRelatedRootNotFound))
+	_, _, err = relatedGraph(ctx, discovery, client, metadataClient, "Pod", "v1", "", "web-abc-1", 1)
+	assert.True(t, errors.Is(err, errRelatedInvalid))
+
+	// Types that fail to list are reported and left out
+	metadataClient.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	graph, related, err := relatedGraph(ctx, discovery, client, metadataClient, "Pod", "v1", "shop", "web-abc-1", 1)
+	require.NoError(t, err)
+	assert.NotContains(t, summarize(related), "Secret/web-secret")
+	assert.Equal(t, []string{"failed to list secrets: forbidden"}, graph.Warnings)
+}
@@ -0,0 +1,518 @@
+package nodes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultDrainTimeout = 300 * time.Second
+	defaultConcurrency  = 10
+	maxConcurrency      = 50
+	subscriberBuffer    = 256
+
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+var (
+	errInvalidOptions      = errors.New("invalid drain options")
+	errNodeNotFound        = errors.New("node not found")
+	errDrainRunning        = errors.New("the node is already being drained")
+	errDrainNotFound       = errors.New("the node has not been drained")
+	errClusterNotConnected = errors.New("cluster not connected")
+)
+
+// drainBlockedError lists the pods that stop a drain from starting, as kubectl drain
+// refuses to start without the flags that allow them to be evicted
+type drainBlockedError struct {
+	pods []PodEviction
+}
+
+func (e *drainBlockedError) Error() string {
+	return fmt.Sprintf("%d pods can't be evicted: %s/%s %s", len(e.pods), e.pods[0].Namespace, e.pods[0].Name, e.pods[0].Reason)
+}
+
+// clientFactory returns the clientset for a cluster context
+type clientFactory func(context string) (kubernetes.Interface, error)
+
+// Manager cordons nodes and runs drains, keeping the latest drain of each node in memory
+type Manager struct {
+	clients       clientFactory
+	now           func() time.Time
+	pollInterval  time.Duration // how often a terminating pod is checked
+	retryInterval time.Duration // wait before retrying an eviction a disruption budget refused
+
+	mu     sync.Mutex
+	drains map[string]*drainState // by context and node
+}
+
+// drainState is a drain and the subscribers following it; guarded by Manager.mu
+type drainState struct {
+	drain       Drain
+	uids        []types.UID // of each pod, to tell a terminated pod from a replacement with its name
+	cancel      context.CancelFunc
+	subscribers map[chan DrainEvent]struct{}
+}
+
+// NewManager creates a node manager that reaches clusters through clients
+func NewManager(clients clientFactory) *Manager {
+	return &Manager{
+		clients:       clients,
+		now:           time.Now,
+		pollInterval:  time.Second,
+		retryInterval: 5 * time.Second, // as kubectl drain
+		drains:        make(map[string]*drainState),
+	}
+}
+
+// SetSchedulable cordons a node when unschedulable is true and uncordons it otherwise
+func (m *Manager) SetSchedulable(ctx context.Context, clusterContext, name string, unschedulable bool) (*NodeState, error) {
+	clientset, err := m.clients(clusterContext)
+	if err != nil {
+		return nil, err
+	}
+	node, err := clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", errNodeNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+	state := &NodeState{Context: clusterContext, Name: name, Unschedulable: unschedulable}
+	if node.Spec.Unschedulable == unschedulable {
+		return state, nil
+	}
+	if err := setUnschedulable(ctx, clientset, name, unschedulable); err != nil {
+		return nil, err
+	}
+	state.Changed = true
+	return state, nil
+}
+
+func setUnschedulable(ctx context.Context, clientset kubernetes.Interface, name string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	_, err := clientset.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// Start checks that every pod on the node can be evicted, then cordons the node and
+// evicts its pods in the background
+func (m *Manager) Start(ctx context.Context, clusterContext, name string, opts DrainOptions) (*Drain, error) {
+	if err := validateOptions(&opts); err != nil {
+		return nil, err
+	}
+	key := drainKey(clusterContext, name)
+	if m.running(key) {
+		return nil, errDrainRunning
+	}
+	clientset, err := m.clients(clusterContext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", errNodeNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	list, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on %s: %w", name, err)
+	}
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		if pod.Spec.NodeName == name {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.TimeoutSeconds)*time.Second)
+	state := &drainState{
+		drain: Drain{
+			ID:        newDrainID(),
+			Context:   clusterContext,
+			Node:      name,
+			Options:   opts,
+			Status:    StatusRunning,
+			CreatedAt: m.now(),
+			Pods:      make([]PodEviction, len(pods)),
+		},
+		uids:        make([]types.UID, len(pods)),
+		cancel:      cancel,
+		subscribers: make(map[chan DrainEvent]struct{}),
+	}
+	var blocked []PodEviction
+	for i, pod := range pods {
+		eviction := PodEviction{Namespace: pod.Namespace, Name: pod.Name, Status: StatusPending}
+		skip, block := classifyPod(&pod, opts)
+		switch {
+		case block != "":
+			eviction.Status, eviction.Reason = StatusFailed, block
+			blocked = append(blocked, eviction)
+		case skip != "":
+			eviction.Status, eviction.Reason = StatusSkipped, skip
+			state.drain.Skipped++
+		}
+		state.drain.Pods[i] = eviction
+		state.uids[i] = pod.UID
+	}
+	if len(blocked) > 0 {
+		cancel()
+		return nil, &drainBlockedError{pods: blocked}
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.drains[key]; ok && existing.drain.FinishedAt == nil {
+		m.mu.Unlock()
+		cancel()
+		return nil, errDrainRunning
+	}
+	m.drains[key] = state
+	drain := copyDrain(&state.drain)
+	m.mu.Unlock()
+
+	go m.run(drainCtx, clientset, state)
+	return drain, nil
+}
+
+// classifyPod says why a pod is skipped or, unless opts allow it, blocks the drain.
+// DaemonSet pods would be recreated on the node and mirror pods can't be evicted at all.
+func classifyPod(pod *corev1.Pod, opts DrainOptions) (skip, block string) {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return "mirror pod of a static pod", ""
+	}
+	controller := metav1.GetControllerOf(pod)
+	if controller != nil && controller.Kind == "DaemonSet" {
+		return fmt.Sprintf("managed by DaemonSet %s", controller.Name), ""
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return "", "" // finished pods are evicted whatever else applies
+	}
+	if controller == nil && !opts.Force {
+		return "", "not managed by a controller, so it won't be recreated; set force to evict it"
+	}
+	if !opts.DeleteEmptyDirData {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.EmptyDir != nil {
+				return "", fmt.Sprintf("uses emptyDir volume %s; set deleteEmptyDirData to evict it", volume.Name)
+			}
+		}
+	}
+	return "", ""
+}
+
+func validateOptions(opts *DrainOptions) error {
+	if opts.TimeoutSeconds < 0 {
+		return fmt.Errorf("%w: timeoutSeconds can't be negative", errInvalidOptions)
+	}
+	if opts.TimeoutSeconds == 0 {
+		opts.TimeoutSeconds = int(defaultDrainTimeout / time.Second)
+	}
+	if opts.GracePeriodSeconds != nil && *opts.GracePeriodSeconds < 0 {
+		return fmt.Errorf("%w: gracePeriodSeconds can't be negative", errInvalidOptions)
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.Concurrency < 1 || opts.Concurrency > maxConcurrency {
+		return fmt.Errorf("%w: concurrency must be between 1 and %d", errInvalidOptions, maxConcurrency)
+	}
+	return nil
+}
+
+// Get returns a copy of the latest drain of a node
+func (m *Manager) Get(clusterContext, name string) (*Drain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.drains[drainKey(clusterContext, name)]
+	if !ok {
+		return nil, errDrainNotFound
+	}
+	return copyDrain(&state.drain), nil
+}
+
+// Cancel stops a running drain. Evictions already accepted carry on; the node stays cordoned.
+func (m *Manager) Cancel(clusterContext, name string) (*Drain, error) {
+	m.mu.Lock()
+	state, ok := m.drains[drainKey(clusterContext, name)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, errDrainNotFound
+	}
+	state.cancel()
+	return m.Get(clusterContext, name)
+}
+
+// Subscribe returns a snapshot of the latest drain of a node and a channel of later
+// events. The channel is closed after the final drain event, or immediately if the
+// drain already finished.
+func (m *Manager) Subscribe(clusterContext, name string) (*Drain, <-chan DrainEvent, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.drains[drainKey(clusterContext, name)]
+	if !ok {
+		return nil, nil, nil, errDrainNotFound
+	}
+	events := make(chan DrainEvent, subscriberBuffer)
+	snapshot := copyDrain(&state.drain)
+	if state.drain.FinishedAt != nil {
+		close(events)
+		return snapshot, events, func() {}, nil
+	}
+	state.subscribers[events] = struct{}{}
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := state.subscribers[events]; ok {
+			delete(state.subscribers, events)
+			close(events)
+		}
+	}
+	return snapshot, events, unsubscribe, nil
+}
+
+func (m *Manager) running(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.drains[key]
+	return ok && state.drain.FinishedAt == nil
+}
+
+// run cordons the node and evicts its pods with bounded concurrency
+func (m *Manager) run(ctx context.Context, clientset kubernetes.Interface, state *drainState) {
+	defer state.cancel()
+
+	if err := setUnschedulable(ctx, clientset, state.drain.Node, true); err != nil {
+		m.finish(ctx, state, fmt.Sprintf("failed to cordon %s: %v", state.drain.Node, err))
+		return
+	}
+
+	slots := make(chan struct{}, state.drain.Options.Concurrency)
+	var wg sync.WaitGroup
+	for i := range state.drain.Pods {
+		if state.drain.Pods[i].Status != StatusPending {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			m.evict(ctx, clientset, state, index)
+		}(i)
+	}
+	wg.Wait()
+	m.finish(ctx, state, "")
+}
+
+// evict requests the eviction of a pod, retrying while a disruption budget refuses it,
+// and waits for the pod to go away
+func (m *Manager) evict(ctx context.Context, clientset kubernetes.Interface, state *drainState, index int) {
+	m.mu.Lock()
+	pod := state.drain.Pods[index]
+	uid := state.uids[index]
+	gracePeriod := state.drain.Options.GracePeriodSeconds
+	started := m.now()
+	state.drain.Pods[index].StartedAt = &started
+	m.mu.Unlock()
+
+	eviction := &policyv1.Eviction{
+		ObjectMeta:    metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: gracePeriod},
+	}
+	for attempt := 1; ; attempt++ {
+		err := clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		switch {
+		case err == nil:
+			m.update(state, index, func(p *PodEviction) {
+				p.Status, p.Reason, p.Attempts = StatusEvicting, "", attempt
+			})
+		case apierrors.IsNotFound(err):
+			m.complete(state, index, StatusEvicted, "", attempt)
+			return
+		case apierrors.IsTooManyRequests(err):
+			m.update(state, index, func(p *PodEviction) {
+				p.Status, p.Reason, p.Attempts = StatusBlocked, err.Error(), attempt
+			})
+			select {
+			case <-time.After(m.retryInterval):
+				continue
+			case <-ctx.Done():
+				m.interrupt(ctx, state, index)
+				return
+			}
+		default:
+			if ctx.Err() != nil {
+				m.interrupt(ctx, state, index)
+			} else {
+				m.complete(state, index, StatusFailed, err.Error(), attempt)
+			}
+			return
+		}
+		break
+	}
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		current, err := clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && current.UID != uid) {
+			m.complete(state, index, StatusEvicted, "", 0)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			m.interrupt(ctx, state, index)
+			return
+		}
+	}
+}
+
+// update changes a pod's progress and publishes it
+func (m *Manager) update(state *drainState, index int, change func(*PodEviction)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	change(&state.drain.Pods[index])
+	m.publishLocked(state, index)
+}
+
+// complete records a pod's final state; attempts is left alone when 0
+func (m *Manager) complete(state *drainState, index int, status, message string, attempts int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pod := &state.drain.Pods[index]
+	finished := m.now()
+	pod.Status, pod.FinishedAt = status, &finished
+	if attempts > 0 {
+		pod.Attempts = attempts
+	}
+	switch status {
+	case StatusEvicted:
+		pod.Reason = ""
+		state.drain.Evicted++
+	case StatusFailed:
+		pod.Error = message
+		state.drain.Failed++
+	}
+	m.publishLocked(state, index)
+}
+
+// interrupt ends a pod's eviction when the drain times out or is cancelled
+func (m *Manager) interrupt(ctx context.Context, state *drainState, index int) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		m.complete(state, index, StatusFailed, "drain timed out", 0)
+		return
+	}
+	m.complete(state, index, StatusCancelled, "", 0)
+}
+
+// finish settles pods that never started and the drain's status, then tells subscribers
+func (m *Manager) finish(ctx context.Context, state *drainState, failure string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	finished := m.now()
+	for i := range state.drain.Pods {
+		pod := &state.drain.Pods[i]
+		if pod.Status != StatusPending {
+			continue
+		}
+		pod.Status = StatusCancelled
+		if timedOut {
+			pod.Status, pod.Error = StatusFailed, "drain timed out"
+			state.drain.Failed++
+		}
+	}
+
+	drain := &state.drain
+	switch {
+	case failure != "":
+		drain.Status, drain.Message = StatusFailed, failure
+	case timedOut:
+		drain.Status = StatusFailed
+		drain.Message = fmt.Sprintf("timed out after %ds with %d pods evicted", drain.Options.TimeoutSeconds, drain.Evicted)
+	case ctx.Err() != nil:
+		drain.Status = StatusCancelled
+		drain.Message = fmt.Sprintf("cancelled with %d pods evicted; the node stays cordoned", drain.Evicted)
+	case drain.Failed > 0:
+		drain.Status = StatusFailed
+		drain.Message = fmt.Sprintf("%d pods could not be evicted", drain.Failed)
+	default:
+		drain.Status = StatusSucceeded
+		drain.Message = fmt.Sprintf("evicted %d pods, skipped %d", drain.Evicted, drain.Skipped)
+	}
+	drain.FinishedAt = &finished
+
+	event := DrainEvent{Type: "drain", DrainID: drain.ID, Drain: copyDrain(drain)}
+	for subscriber := range state.subscribers {
+		subscriber <- event
+		close(subscriber)
+	}
+	state.subscribers = map[chan DrainEvent]struct{}{}
+}
+
+// publishLocked sends a pod event to each subscriber with room for it. A slot is always
+// left for the final drain event; a subscriber that falls behind misses pod events but
+// still gets the final state.
+func (m *Manager) publishLocked(state *drainState, index int) {
+	pod := state.drain.Pods[index]
+	event := DrainEvent{Type: "pod", DrainID: state.drain.ID, Index: index, Pod: &pod}
+	for subscriber := range state.subscribers {
+		if len(subscriber) < cap(subscriber)-1 {
+			subscriber <- event
+		}
+	}
+}
+
+func copyDrain(drain *Drain) *Drain {
+	copied := *drain
+	copied.Pods = append([]PodEviction(nil), drain.Pods...)
+	return &copied
+}
+
+func drainKey(clusterContext, name string) string {
+	return clusterContext + "/" + name
+}
+
+func newDrainID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "drain-" + hex.EncodeToString(b)
+}
+
+// blockedPods returns the pods of a drainBlockedError, if err is one
+func blockedPods(err error) ([]PodEviction, bool) {
+	var blocked *drainBlockedError
+	if errors.As(err, &blocked) {
+		return blocked.pods, true
+	}
+	return nil, false
+}
@@ -0,0 +1,228 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func testPod(namespace, name, node, ownerKind string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(name)},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if ownerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: name + "-owner", Controller: &controller}}
+	}
+	return pod
+}
+
+// newTestManager serves node-1 in prod. Evictions of api-1 are refused by its disruption
+// budget pdbRefusals times, or always when pdbRefusals is negative; other evictions
+// delete the pod.
+func newTestManager(t *testing.T, pdbRefusals int, objects ...runtime.Object) (*Manager, *fake.Clientset) {
+	mirror := testPod("kube-system", "kube-apiserver-node-1", "node-1", "Node")
+	mirror.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	objects = append(objects,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		testPod("shop", "web-1", "node-1", "ReplicaSet"),
+		testPod("shop", "api-1", "node-1", "ReplicaSet"),
+		testPod("shop", "web-2", "node-2", "ReplicaSet"),
+		testPod("kube-system", "fluentd-abc", "node-1", "DaemonSet"),
+		mirror,
+	)
+	clientset := fake.NewSimpleClientset(objects...)
+
+	var mu sync.Mutex
+	refused := 0
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		mu.Lock()
+		defer mu.Unlock()
+		if eviction.Name == "api-1" && (pdbRefusals < 0 || refused < pdbRefusals) {
+			refused++
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		if err := clientset.Tracker().Delete(podsGVR, eviction.Namespace, eviction.Name); err != nil {
+			return true, nil, err
+		}
+		return true, nil, nil
+	})
+
+	manager := NewManager(func(context string) (kubernetes.Interface, error) {
+		if context != "prod" {
+			return nil, fmt.Errorf("%w: %s", errClusterNotConnected, context)
+		}
+		return clientset, nil
+	})
+	manager.pollInterval = 5 * time.Millisecond
+	manager.retryInterval = 5 * time.Millisecond
+	return manager, clientset
+}
+
+func waitForDrain(t *testing.T, manager *Manager, node string) *Drain {
+	var drain *Drain
+	require.Eventually(t, func() bool {
+		var err error
+		drain, err = manager.Get("prod", node)
+		require.NoError(t, err)
+		return drain.FinishedAt != nil
+	}, 5*time.Second, 5*time.Millisecond)
+	return drain
+}
+
+func podStatuses(drain *Drain) map[string]string {
+	statuses := map[string]string{}
+	for _, pod := range drain.Pods {
+		statuses[pod.Namespace+"/"+pod.Name] = pod.Status
+	}
+	return statuses
+}
+
+func TestDrainEvictsPodsAndRetriesDisruptionBudgets(t *testing.T) {
+	manager, clientset := newTestManager(t, 2)
+	ctx := context.Background()
+
+	drain, err := manager.Start(ctx, "prod", "node-1", DrainOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, drain.Status)
+	assert.Equal(t, 300, drain.Options.TimeoutSeconds)
+	_, events, unsubscribe, err := manager.Subscribe("prod", "node-1")
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	drain = waitForDrain(t, manager, "node-1")
+	assert.Equal(t, StatusSucceeded, drain.Status)
+	assert.Equal(t, "evicted 2 pods, skipped 2", drain.Message)
+	assert.Equal(t, map[string]string{
+		"kube-system/fluentd-abc":           StatusSkipped,
+		"kube-system/kube-apiserver-node-1": StatusSkipped,
+		"shop/api-1":                        StatusEvicted,
+		"shop/web-1":                        StatusEvicted,
+	}, podStatuses(drain))
+	for _, pod := range drain.Pods {
+		if pod.Name == "api-1" {
+			assert.Equal(t, 3, pod.Attempts, "evicted on the third request, after two budget refusals")
+		}
+	}
+
+	var last DrainEvent
+	for event := range events {
+		last = event
+	}
+	assert.Equal(t, "drain", last.Type)
+	assert.Equal(t, StatusSucceeded, last.Drain.Status)
+
+	node, err := clientset.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, node.Spec.Unschedulable)
+	_, err = clientset.CoreV1().Pods("shop").Get(ctx, "web-2", metav1.GetOptions{})
+	assert.NoError(t, err, "pods on other nodes are left alone")
+}
+
+func TestDrainBlockedPods(t *testing.T) {
+	standalone := testPod("shop", "debug", "node-1", "")
+	scratch := testPod("shop", "cache-1", "node-1", "StatefulSet")
+	scratch.Spec.Volumes = []corev1.Volume{{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+	manager, clientset := newTestManager(t, 0, standalone, scratch)
+	ctx := context.Background()
+
+	_, err := manager.Start(ctx, "prod", "node-1", DrainOptions{})
+	pods, ok := blockedPods(err)
+	require.True(t, ok, "unexpected error %v", err)
+	require.Len(t, pods, 2)
+	assert.Equal(t, "cache-1", pods[0].Name)
+	assert.Contains(t, pods[0].Reason, "deleteEmptyDirData")
+	assert.Equal(t, "debug", pods[1].Name)
+	assert.Contains(t, pods[1].Reason, "force")
+
+	node, err := clientset.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.False(t, node.Spec.Unschedulable, "a drain that can't start leaves the node schedulable")
+	_, err = manager.Get("prod", "node-1")
+	assert.ErrorIs(t, err, errDrainNotFound)
+
+	_, err = manager.Start(ctx, "prod", "node-1", DrainOptions{Force: true, DeleteEmptyDirData: true, GracePeriodSeconds: new(int64)})
+	require.NoError(t, err)
+	drain := waitForDrain(t, manager, "node-1")
+	assert.Equal(t, StatusSucceeded, drain.Status)
+	assert.Equal(t, 4, drain.Evicted)
+}
+
+func TestDrainCancelAndTimeout(t *testing.T) {
+	manager, clientset := newTestManager(t, -1)
+	ctx := context.Background()
+
+	_, err := manager.Start(ctx, "prod", "node-1", DrainOptions{})
+	require.NoError(t, err)
+	_, err = manager.Start(ctx, "prod", "node-1", DrainOptions{})
+	assert.ErrorIs(t, err, errDrainRunning)
+
+	require.Eventually(t, func() bool {
+		drain, err := manager.Get("prod", "node-1")
+		require.NoError(t, err)
+		statuses := podStatuses(drain)
+		return statuses["shop/api-1"] == StatusBlocked && statuses["shop/web-1"] == StatusEvicted
+	}, 5*time.Second, 5*time.Millisecond)
+	_, err = manager.Cancel("prod", "node-1")
+	require.NoError(t, err)
+	drain := waitForDrain(t, manager, "node-1")
+	assert.Equal(t, StatusCancelled, drain.Status)
+	assert.Equal(t, StatusCancelled, podStatuses(drain)["shop/api-1"])
+	assert.Equal(t, StatusEvicted, podStatuses(drain)["shop/web-1"])
+	node, err := clientset.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, node.Spec.Unschedulable, "a cancelled drain leaves the node cordoned")
+
+	_, err = manager.Start(ctx, "prod", "node-1", DrainOptions{TimeoutSeconds: 1})
+	require.NoError(t, err)
+	drain = waitForDrain(t, manager, "node-1")
+	assert.Equal(t, StatusFailed, drain.Status)
+	assert.Equal(t, "timed out after 1s with 0 pods evicted", drain.Message)
+	assert.Equal(t, "drain timed out", drain.Pods[len(drain.Pods)-1].Error)
+}
+
+func TestSetSchedulable(t *testing.T) {
+	manager, _ := newTestManager(t, 0)
+	ctx := context.Background()
+
+	state, err := manager.SetSchedulable(ctx, "prod", "node-2", true)
+	require.NoError(t, err)
+	assert.Equal(t, NodeState{Context: "prod", Name: "node-2", Unschedulable: true, Changed: true}, *state)
+	state, err = manager.SetSchedulable(ctx, "prod", "node-2", true)
+	require.NoError(t, err)
+	assert.False(t, state.Changed)
+	state, err = manager.SetSchedulable(ctx, "prod", "node-2", false)
+	require.NoError(t, err)
+	assert.True(t, state.Changed)
+
+	_, err = manager.SetSchedulable(ctx, "prod", "node-9", true)
+	assert.ErrorIs(t, err, errNodeNotFound)
+	_, err = manager.SetSchedulable(ctx, "dev", "node-2", true)
+	assert.ErrorIs(t, err, errClusterNotConnected)
+	_, err = manager.Start(ctx, "prod", "node-2", DrainOptions{Concurrency: 100})
+	assert.ErrorIs(t, err, errInvalidOptions)
+}
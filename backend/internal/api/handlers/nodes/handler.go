@@ -0,0 +1,204 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sclient "k8s.io/client-go/kubernetes"
+)
+
+var manager *Manager
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Allow all origins in development
+	},
+}
+
+// Initialize sets up the node operation handlers with the cluster manager
+func Initialize(cm *kubernetes.ClusterManager) {
+	manager = NewManager(func(context string) (k8sclient.Interface, error) {
+		conn, err := cm.GetConnection(context)
+		if err != nil || conn == nil {
+			return nil, fmt.Errorf("%w: %s", errClusterNotConnected, context)
+		}
+		return conn.ClientSet, nil
+	})
+}
+
+// CordonNode marks a node unschedulable
+// POST /api/v1/nodes/:context/:name/cordon
+func CordonNode(c *gin.Context) {
+	setSchedulable(c, true)
+}
+
+// UncordonNode marks a node schedulable again
+// POST /api/v1/nodes/:context/:name/uncordon
+func UncordonNode(c *gin.Context) {
+	setSchedulable(c, false)
+}
+
+func setSchedulable(c *gin.Context, unschedulable bool) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	state, err := manager.SetSchedulable(c.Request.Context(), c.Param("context"), c.Param("name"), unschedulable)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// StartDrain cordons a node and evicts its pods in the background. The body holds
+// DrainOptions and may be empty.
+// POST /api/v1/nodes/:context/:name/drain
+func StartDrain(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	var opts DrainOptions
+	if err := c.ShouldBindJSON(&opts); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	drain, err := manager.Start(c.Request.Context(), c.Param("context"), c.Param("name"), opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, drain)
+}
+
+// GetDrain returns the latest drain of a node with the progress of every pod
+// GET /api/v1/nodes/:context/:name/drain
+func GetDrain(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	drain, err := manager.Get(c.Param("context"), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, drain)
+}
+
+// CancelDrain stops a running drain, leaving the node cordoned
+// POST /api/v1/nodes/:context/:name/drain/cancel
+func CancelDrain(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	drain, err := manager.Cancel(c.Param("context"), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, drain)
+}
+
+// DrainWebSocket streams a drain's progress: a snapshot, then pod events as pods are
+// evicted or retried behind a disruption budget, then a final drain event before the
+// connection closes. Sending {"action":"cancel"} cancels the drain.
+// GET /api/v1/nodes/:context/:name/drain/ws
+func DrainWebSocket(c *gin.Context) {
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return
+	}
+	clusterContext, name := c.Param("context"), c.Param("name")
+	snapshot, events, unsubscribe, err := manager.Subscribe(clusterContext, name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer unsubscribe()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade node drain connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var writeMutex sync.Mutex
+	write := func(event DrainEvent) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(event)
+	}
+
+	// Read cancel requests until the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var message struct {
+				Action string `json:"action"`
+			}
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			if message.Action == "cancel" {
+				if _, err := manager.Cancel(clusterContext, name); err != nil && !errors.Is(err, errDrainNotFound) {
+					log.Printf("Failed to cancel drain of %s/%s: %v", clusterContext, name, err)
+				}
+			}
+		}
+	}()
+
+	if err := write(DrainEvent{Type: "snapshot", DrainID: snapshot.ID, Drain: snapshot}); err != nil {
+		return
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				writeMutex.Lock()
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "drain finished"))
+				writeMutex.Unlock()
+				return
+			}
+			if err := write(event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	if pods, ok := blockedPods(err); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "pods": pods})
+		return
+	}
+	code := http.StatusInternalServerError
+	var status apierrors.APIStatus
+	switch {
+	case errors.Is(err, errInvalidOptions):
+		code = http.StatusBadRequest
+	case errors.Is(err, errNodeNotFound), errors.Is(err, errDrainNotFound), errors.Is(err, errClusterNotConnected):
+		code = http.StatusNotFound
+	case errors.Is(err, errDrainRunning):
+		code = http.StatusConflict
+	case errors.As(err, &status) && status.Status().Code != 0:
+		code = int(status.Status().Code)
+	}
+	c.JSON(code, gin.H{"error": err.Error()})
+}
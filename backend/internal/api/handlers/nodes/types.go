@@ -0,0 +1,74 @@
+package nodes
+
+import (
+	"time"
+)
+
+// Drain and pod eviction states
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusEvicting  = "evicting" // the eviction was accepted and the pod is terminating
+	StatusBlocked   = "blocked"  // a PodDisruptionBudget refused the eviction; it is retried
+	StatusEvicted   = "evicted"
+	StatusSkipped   = "skipped" // DaemonSet and mirror pods, which eviction can't move
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// DrainOptions tunes a drain the way kubectl drain's flags do
+type DrainOptions struct {
+	DeleteEmptyDirData bool   `json:"deleteEmptyDirData"`           // evict pods with emptyDir volumes, losing the data
+	Force              bool   `json:"force"`                        // evict pods no controller will recreate
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"` // overrides each pod's own grace period
+	TimeoutSeconds     int    `json:"timeoutSeconds,omitempty"`     // for the whole drain, default 300
+	Concurrency        int    `json:"concurrency,omitempty"`        // pods evicted at once, default 10, at most 50
+}
+
+// NodeState is whether a node accepts new pods after cordon or uncordon
+type NodeState struct {
+	Context       string `json:"context"`
+	Name          string `json:"name"`
+	Unschedulable bool   `json:"unschedulable"`
+	Changed       bool   `json:"changed"` // false when the node was already in the requested state
+}
+
+// PodEviction is the progress of one pod on a draining node
+type PodEviction struct {
+	Namespace  string     `json:"namespace"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Reason     string     `json:"reason,omitempty"`   // why the pod is skipped, blocked or can't be evicted
+	Attempts   int        `json:"attempts,omitempty"` // eviction requests, more than one after disruption budget retries
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Drain is a drain of one node and the progress of each of its pods
+type Drain struct {
+	ID         string        `json:"id"`
+	Context    string        `json:"context"`
+	Node       string        `json:"node"`
+	Options    DrainOptions  `json:"options"`
+	Status     string        `json:"status"`
+	Message    string        `json:"message,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+	Evicted    int           `json:"evicted"`
+	Failed     int           `json:"failed"`
+	Skipped    int           `json:"skipped"`
+	Pods       []PodEviction `json:"pods"`
+}
+
+// DrainEvent is streamed to WebSocket subscribers: a snapshot when they connect, a pod
+// event whenever a pod changes state or a blocked eviction is retried, and a drain
+// event at the end
+type DrainEvent struct {
+	Type    string       `json:"type"` // snapshot, pod, drain
+	DrainID string       `json:"drainId"`
+	Index   int          `json:"index,omitempty"`
+	Pod     *PodEviction `json:"pod,omitempty"`
+	Drain   *Drain       `json:"drain,omitempty"`
+}
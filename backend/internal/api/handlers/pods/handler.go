@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -52,7 +53,7 @@ func List(c *gin.Context) {
 		namespace = metav1.NamespaceAll
 	}
 
-	pods, err := conn.ClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	pods, err := listPods(req.Context, conn, namespace)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -66,6 +67,22 @@ func List(c *gin.Context) {
 	})
 }
 
+// listPods reads pods from the cluster's informer cache, falling back to the API
+// when the cache is unavailable
+func listPods(clusterContext string, conn *kubernetes.ClusterConnection, namespace string) (*corev1.PodList, error) {
+	if resources, err := clusterManager.GetResourceCache(clusterContext); err == nil {
+		cached, err := kubernetes.ListAs[*corev1.Pod](resources, "pods", namespace)
+		if err == nil {
+			pods := &corev1.PodList{Items: make([]corev1.Pod, 0, len(cached))}
+			for _, pod := range cached {
+				pods.Items = append(pods.Items, *pod)
+			}
+			return pods, nil
+		}
+	}
+	return conn.ClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+}
+
 // Get handles getting a specific pod with full details
 func Get(c *gin.Context) {
 	context := c.Query("context")
@@ -142,7 +159,7 @@ func GetLogs(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"logs": string(buf[:n]),
+		"logs": redaction.Default().RedactLine(namespace, string(buf[:n])),
 		"container": container,
 		"lines": tailLines,
 	})
@@ -464,7 +481,7 @@ func ListDetailed(c *gin.Context) {
 		namespace = metav1.NamespaceAll
 	}
 
-	pods, err := conn.ClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	pods, err := listPods(req.Context, conn, namespace)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -11,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -118,7 +119,7 @@ func LogsWebSocket(c *gin.Context) {
 		case <-done:
 			return
 		default:
-			line := scanner.Text()
+			line := redaction.Default().RedactLine(namespace, scanner.Text())
 			
 			// Send log line as JSON
 			message := fmt.Sprintf(`{"type": "log", "data": %q, "timestamp": %q}`, line, time.Now().Format(time.RFC3339))
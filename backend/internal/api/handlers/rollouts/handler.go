@@ -0,0 +1,164 @@
+package rollouts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var clusterManager *kubernetes.ClusterManager
+
+// Initialize sets up the rollout handlers with the cluster manager
+func Initialize(cm *kubernetes.ClusterManager) {
+	clusterManager = cm
+}
+
+// GetHistory lists the revisions of a deployment, statefulset or daemonset
+// GET /api/v1/rollouts/:context/:namespace/:kind/:name/history
+func GetHistory(c *gin.Context) {
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	history, err := service.History(c.Request.Context(), c.Param("kind"), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetDiff returns a pod template diff between two revisions
+// GET /api/v1/rollouts/:context/:namespace/:kind/:name/diff?from=1&to=3
+// to defaults to the current revision and from to the revision before to
+func GetDiff(c *gin.Context) {
+	from, err := revisionParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := revisionParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	diff, err := service.Diff(c.Request.Context(), c.Param("kind"), c.Param("namespace"), c.Param("name"), from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// Rollback restores a previous revision
+// POST /api/v1/rollouts/:context/:namespace/:kind/:name/rollback {"revision": 2}
+func Rollback(c *gin.Context) {
+	var req RollbackRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Revision < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "revision must not be negative"})
+		return
+	}
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	result, err := service.Rollback(c.Request.Context(), c.Param("kind"), c.Param("namespace"), c.Param("name"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Pause stops a deployment from rolling out template changes
+// POST /api/v1/rollouts/:context/:namespace/:kind/:name/pause
+func Pause(c *gin.Context) {
+	setPaused(c, true)
+}
+
+// Resume lets a paused deployment roll out again
+// POST /api/v1/rollouts/:context/:namespace/:kind/:name/resume
+func Resume(c *gin.Context) {
+	setPaused(c, false)
+}
+
+func setPaused(c *gin.Context, paused bool) {
+	service, ok := serviceFor(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	if err := service.SetPaused(c.Request.Context(), c.Param("kind"), c.Param("namespace"), name, paused); err != nil {
+		respondError(c, err)
+		return
+	}
+	action := "resumed"
+	if paused {
+		action = "paused"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Deployment %s %s", name, action),
+		"paused":  paused,
+	})
+}
+
+func serviceFor(c *gin.Context) (*Service, bool) {
+	if clusterManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cluster manager not initialized"})
+		return nil, false
+	}
+	if _, err := NormalizeKind(c.Param("kind")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	context := c.Param("context")
+	conn, err := clusterManager.GetConnection(context)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Cluster %s not connected", context)})
+		return nil, false
+	}
+	return NewService(conn.ClientSet), true
+}
+
+func revisionParam(c *gin.Context, name string) (int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, fmt.Errorf("%s must be a revision number", name)
+	}
+	return revision, nil
+}
+
+func respondError(c *gin.Context, err error) {
+	code := http.StatusInternalServerError
+	var status apierrors.APIStatus
+	switch {
+	case errors.Is(err, errUnsupportedKind), errors.Is(err, errPauseUnsupported), errors.Is(err, errNoPreviousVersion):
+		code = http.StatusBadRequest
+	case errors.Is(err, errRevisionNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, errPaused):
+		code = http.StatusConflict
+	case errors.As(err, &status) && status.Status().Code != 0:
+		code = int(status.Status().Code)
+	}
+	c.JSON(code, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,452 @@
+package rollouts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+var (
+	errUnsupportedKind   = errors.New("rollout history is only available for deployments, statefulsets and daemonsets")
+	errPauseUnsupported  = errors.New("only deployments can be paused and resumed")
+	errRevisionNotFound  = errors.New("revision not found")
+	errNoPreviousVersion = errors.New("no previous revision to roll back to")
+	errPaused            = errors.New("cannot roll back a paused deployment; resume it first")
+)
+
+// Service reads and rolls back workload revisions
+type Service struct {
+	clientset kubernetes.Interface
+}
+
+// NewService creates a rollout service
+func NewService(clientset kubernetes.Interface) *Service {
+	return &Service{clientset: clientset}
+}
+
+// revisionEntry is a revision with the pod template it records
+type revisionEntry struct {
+	Revision
+	template corev1.PodTemplateSpec
+	data     []byte // ControllerRevision patch, applied as-is on rollback
+}
+
+// workloadHistory is the live workload and its revisions, oldest first
+type workloadHistory struct {
+	kind            string
+	namespace       string
+	name            string
+	resourceVersion string
+	hasAnnotations  bool
+	paused          bool
+	current         int64
+	template        corev1.PodTemplateSpec
+	revisions       []revisionEntry
+}
+
+// NormalizeKind maps deployment, deployments, Deployment and so on to a kind
+func NormalizeKind(kind string) (string, error) {
+	switch strings.TrimSuffix(strings.ToLower(kind), "s") {
+	case "deployment":
+		return KindDeployment, nil
+	case "statefulset":
+		return KindStatefulSet, nil
+	case "daemonset":
+		return KindDaemonSet, nil
+	}
+	return "", fmt.Errorf("%w: %s", errUnsupportedKind, kind)
+}
+
+// History lists the revisions of a workload
+func (s *Service) History(ctx context.Context, kind, namespace, name string) (*History, error) {
+	history, err := s.history(ctx, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	result := &History{
+		Kind:            history.kind,
+		Namespace:       namespace,
+		Name:            name,
+		Paused:          history.paused,
+		CurrentRevision: history.current,
+		Revisions:       make([]Revision, 0, len(history.revisions)),
+	}
+	for _, entry := range history.revisions {
+		result.Revisions = append(result.Revisions, entry.Revision)
+	}
+	return result, nil
+}
+
+// Diff compares the pod templates of two revisions. A zero to is the current
+// revision and a zero from is the revision before to.
+func (s *Service) Diff(ctx context.Context, kind, namespace, name string, from, to int64) (*RevisionDiff, error) {
+	history, err := s.history(ctx, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = history.current
+	}
+	after, err := history.find(to)
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 {
+		previous := history.previous(to)
+		if previous == nil {
+			return nil, errNoPreviousVersion
+		}
+		from = previous.Revision.Revision
+	}
+	before, err := history.find(from)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RevisionDiff{Kind: history.kind, Namespace: namespace, Name: name, From: from, To: to}
+	beforeYAML, err := yaml.Marshal(before.template)
+	if err != nil {
+		return nil, err
+	}
+	afterYAML, err := yaml.Marshal(after.template)
+	if err != nil {
+		return nil, err
+	}
+	if string(beforeYAML) == string(afterYAML) {
+		result.Identical = true
+		return result, nil
+	}
+	result.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(beforeYAML)),
+		B:        difflib.SplitLines(string(afterYAML)),
+		FromFile: fmt.Sprintf("revision %d", from),
+		ToFile:   fmt.Sprintf("revision %d", to),
+		Context:  3,
+	})
+	return result, err
+}
+
+// Rollback restores the pod template of a previous revision, as kubectl rollout undo does
+func (s *Service) Rollback(ctx context.Context, kind, namespace, name string, req RollbackRequest) (*RollbackResult, error) {
+	history, err := s.history(ctx, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if history.paused {
+		return nil, errPaused
+	}
+
+	var target *revisionEntry
+	if req.Revision == 0 {
+		if target = history.previous(history.current); target == nil {
+			return nil, errNoPreviousVersion
+		}
+	} else if target, err = history.find(req.Revision); err != nil {
+		return nil, err
+	}
+
+	result := &RollbackResult{
+		Kind:         history.kind,
+		Namespace:    namespace,
+		Name:         name,
+		FromRevision: history.current,
+		ToRevision:   target.Revision.Revision,
+		DryRun:       req.DryRun,
+	}
+	if apiequality.Semantic.DeepEqual(target.template, history.template) {
+		result.Skipped = true
+		result.Message = fmt.Sprintf("%s %s already runs the template of revision %d", history.kind, name, result.ToRevision)
+		return result, nil
+	}
+
+	opts := metav1.PatchOptions{FieldManager: manifests.FieldManager}
+	if req.DryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	switch history.kind {
+	case KindDeployment:
+		patch, err := deploymentRollbackPatch(history, target)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.JSONPatchType, patch, opts)
+		if err != nil {
+			return nil, err
+		}
+	case KindStatefulSet:
+		patch, err := controllerRevisionRollbackPatch(history, target)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.clientset.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, opts); err != nil {
+			return nil, err
+		}
+	case KindDaemonSet:
+		patch, err := controllerRevisionRollbackPatch(history, target)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.clientset.AppsV1().DaemonSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, opts); err != nil {
+			return nil, err
+		}
+	}
+	result.Message = fmt.Sprintf("%s %s rolled back to revision %d", history.kind, name, result.ToRevision)
+	return result, nil
+}
+
+// SetPaused pauses or resumes a deployment's rollouts
+func (s *Service) SetPaused(ctx context.Context, kind, namespace, name string, paused bool) error {
+	kind, err := NormalizeKind(kind)
+	if err != nil {
+		return err
+	}
+	if kind != KindDeployment {
+		return errPauseUnsupported
+	}
+	patch := fmt.Sprintf(`{"spec":{"paused":%t}}`, paused)
+	_, err = s.clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{FieldManager: manifests.FieldManager})
+	return err
+}
+
+// deploymentRollbackPatch replaces the pod template and records the change cause.
+// The resourceVersion makes the API server reject the patch if the deployment changed
+// since its history was read.
+func deploymentRollbackPatch(history *workloadHistory, target *revisionEntry) ([]byte, error) {
+	cause := fmt.Sprintf("rollback to revision %d", target.Revision.Revision)
+	operations := []map[string]interface{}{
+		{"op": "replace", "path": "/spec/template", "value": target.template},
+		{"op": "add", "path": "/metadata/resourceVersion", "value": history.resourceVersion},
+	}
+	if history.hasAnnotations {
+		operations = append(operations, map[string]interface{}{
+			"op": "add", "path": "/metadata/annotations/" + strings.ReplaceAll(changeCauseAnnotation, "/", "~1"), "value": cause,
+		})
+	} else {
+		operations = append(operations, map[string]interface{}{
+			"op": "add", "path": "/metadata/annotations", "value": map[string]string{changeCauseAnnotation: cause},
+		})
+	}
+	return json.Marshal(operations)
+}
+
+// controllerRevisionRollbackPatch is the strategic merge patch stored in a ControllerRevision
+// with the same resourceVersion precondition as deploymentRollbackPatch
+func controllerRevisionRollbackPatch(history *workloadHistory, target *revisionEntry) ([]byte, error) {
+	var patch map[string]interface{}
+	if err := json.Unmarshal(target.data, &patch); err != nil || patch == nil {
+		return nil, fmt.Errorf("controllerrevision %s does not hold a patch object", target.Revision.Name)
+	}
+	metadata, _ := patch["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["resourceVersion"] = history.resourceVersion
+	patch["metadata"] = metadata
+	return json.Marshal(patch)
+}
+
+func (s *Service) history(ctx context.Context, kind, namespace, name string) (*workloadHistory, error) {
+	kind, err := NormalizeKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case KindDeployment:
+		return s.deploymentHistory(ctx, namespace, name)
+	case KindStatefulSet:
+		statefulSet, err := s.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return s.controllerRevisionHistory(ctx, kind, statefulSet, statefulSet.Spec.Selector, statefulSet.Spec.Template)
+	default:
+		daemonSet, err := s.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return s.controllerRevisionHistory(ctx, kind, daemonSet, daemonSet.Spec.Selector, daemonSet.Spec.Template)
+	}
+}
+
+// deploymentHistory reads revisions from the ReplicaSets a deployment controls
+func (s *Service) deploymentHistory(ctx context.Context, namespace, name string) (*workloadHistory, error) {
+	deployment, err := s.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	replicaSets, err := s.clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+
+	history := newWorkloadHistory(KindDeployment, &deployment.ObjectMeta, deployment.Spec.Template)
+	history.paused = deployment.Spec.Paused
+	history.current, _ = strconv.ParseInt(deployment.Annotations[revisionAnnotation], 10, 64)
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		revision, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+		replicas, ready := rs.Status.Replicas, rs.Status.ReadyReplicas
+		entry := revisionEntry{
+			Revision: Revision{
+				Revision:    revision,
+				Name:        rs.Name,
+				ChangeCause: rs.Annotations[changeCauseAnnotation],
+				CreatedAt:   rs.CreationTimestamp.Time,
+				Replicas:    &replicas,
+				Ready:       &ready,
+			},
+			template: cleanTemplate(rs.Spec.Template),
+		}
+		history.add(entry)
+	}
+	history.finish()
+	return history, nil
+}
+
+// controllerRevisionHistory reads revisions from the ControllerRevisions a
+// StatefulSet or DaemonSet controls; the highest revision is the current one
+func (s *Service) controllerRevisionHistory(ctx context.Context, kind string, owner metav1.Object, labelSelector *metav1.LabelSelector, template corev1.PodTemplateSpec) (*workloadHistory, error) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	revisions, err := s.clientset.AppsV1().ControllerRevisions(owner.GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list controllerrevisions: %w", err)
+	}
+
+	meta := metav1.ObjectMeta{
+		Namespace:       owner.GetNamespace(),
+		Name:            owner.GetName(),
+		ResourceVersion: owner.GetResourceVersion(),
+		Annotations:     owner.GetAnnotations(),
+	}
+	history := newWorkloadHistory(kind, &meta, template)
+	for i := range revisions.Items {
+		revision := &revisions.Items[i]
+		if !metav1.IsControlledBy(revision, owner) {
+			continue
+		}
+		revisionTemplate, err := controllerRevisionTemplate(revision)
+		if err != nil {
+			return nil, fmt.Errorf("controllerrevision %s: %w", revision.Name, err)
+		}
+		history.add(revisionEntry{
+			Revision: Revision{
+				Revision:    revision.Revision,
+				Name:        revision.Name,
+				ChangeCause: revision.Annotations[changeCauseAnnotation],
+				CreatedAt:   revision.CreationTimestamp.Time,
+			},
+			template: revisionTemplate,
+			data:     revision.Data.Raw,
+		})
+	}
+	history.finish()
+	if len(history.revisions) > 0 {
+		history.current = history.revisions[len(history.revisions)-1].Revision.Revision
+		history.revisions[len(history.revisions)-1].Current = true
+	}
+	return history, nil
+}
+
+// controllerRevisionTemplate reads the pod template from a revision's data, which
+// StatefulSets and DaemonSets store as {"spec":{"template":{..., "$patch":"replace"}}}
+func controllerRevisionTemplate(revision *appsv1.ControllerRevision) (corev1.PodTemplateSpec, error) {
+	var data struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+		return corev1.PodTemplateSpec{}, fmt.Errorf("invalid revision data: %w", err)
+	}
+	return cleanTemplate(data.Spec.Template), nil
+}
+
+func newWorkloadHistory(kind string, meta *metav1.ObjectMeta, template corev1.PodTemplateSpec) *workloadHistory {
+	return &workloadHistory{
+		kind:            kind,
+		namespace:       meta.Namespace,
+		name:            meta.Name,
+		resourceVersion: meta.ResourceVersion,
+		hasAnnotations:  meta.Annotations != nil,
+		template:        cleanTemplate(template),
+	}
+}
+
+func (h *workloadHistory) add(entry revisionEntry) {
+	entry.Images = templateImages(entry.template)
+	entry.Current = entry.Revision.Revision == h.current
+	h.revisions = append(h.revisions, entry)
+}
+
+func (h *workloadHistory) finish() {
+	sort.Slice(h.revisions, func(i, j int) bool {
+		return h.revisions[i].Revision.Revision < h.revisions[j].Revision.Revision
+	})
+}
+
+func (h *workloadHistory) find(revision int64) (*revisionEntry, error) {
+	for i := range h.revisions {
+		if h.revisions[i].Revision.Revision == revision {
+			return &h.revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s has no revision %d", errRevisionNotFound, h.kind, h.name, revision)
+}
+
+// previous returns the latest revision before the given one
+func (h *workloadHistory) previous(revision int64) *revisionEntry {
+	for i := len(h.revisions) - 1; i >= 0; i-- {
+		if h.revisions[i].Revision.Revision < revision {
+			return &h.revisions[i]
+		}
+	}
+	return nil
+}
+
+// cleanTemplate drops the hash labels controllers add to each revision's pods
+func cleanTemplate(template corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	template = *template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	delete(template.Labels, appsv1.ControllerRevisionHashLabelKey)
+	return template
+}
+
+func templateImages(template corev1.PodTemplateSpec) []string {
+	images := make([]string, 0, len(template.Spec.Containers))
+	for _, container := range template.Spec.Containers {
+		images = append(images, container.Image)
+	}
+	return images
+}
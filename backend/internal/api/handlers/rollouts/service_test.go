@@ -0,0 +1,177 @@
+package rollouts
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func podTemplate(image string, labels map[string]string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api", Image: image}}},
+	}
+}
+
+func TestDeploymentRollout(t *testing.T) {
+	labels := map[string]string{"app": "api"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", UID: types.UID("api-uid"), Annotations: map[string]string{revisionAnnotation: "2"}},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: podTemplate("api:v2", labels),
+		},
+	}
+	owner := *metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	replicaSet := func(name, revision, cause, image string, replicas int32) *appsv1.ReplicaSet {
+		hashed := map[string]string{"app": "api", appsv1.DefaultDeploymentUniqueLabelKey: name}
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "shop", Labels: hashed, OwnerReferences: []metav1.OwnerReference{owner},
+				Annotations: map[string]string{revisionAnnotation: revision, changeCauseAnnotation: cause},
+			},
+			Spec:   appsv1.ReplicaSetSpec{Template: podTemplate(image, hashed)},
+			Status: appsv1.ReplicaSetStatus{Replicas: replicas, ReadyReplicas: replicas},
+		}
+	}
+	orphan := replicaSet("api-orphan", "7", "", "api:v7", 0)
+	orphan.OwnerReferences = nil
+
+	clientset := fake.NewSimpleClientset(deployment,
+		replicaSet("api-2b", "2", "bump to v2", "api:v2", 3),
+		replicaSet("api-1a", "1", "initial", "api:v1", 0),
+		orphan,
+	)
+	service := NewService(clientset)
+	ctx := context.Background()
+
+	history, err := service.History(ctx, "deployments", "shop", "api")
+	require.NoError(t, err)
+	assert.Equal(t, KindDeployment, history.Kind)
+	assert.Equal(t, int64(2), history.CurrentRevision)
+	require.Len(t, history.Revisions, 2, "replicasets the deployment does not control are ignored")
+	assert.Equal(t, "api-1a", history.Revisions[0].Name)
+	assert.Equal(t, "initial", history.Revisions[0].ChangeCause)
+	assert.False(t, history.Revisions[0].Current)
+	assert.True(t, history.Revisions[1].Current)
+	assert.Equal(t, []string{"api:v2"}, history.Revisions[1].Images)
+	assert.Equal(t, int32(3), *history.Revisions[1].Ready)
+
+	diff, err := service.Diff(ctx, "deployment", "shop", "api", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), diff.From)
+	assert.Equal(t, int64(2), diff.To)
+	assert.Contains(t, diff.Diff, "--- revision 1")
+	assert.Contains(t, diff.Diff, "-  - image: api:v1")
+	assert.Contains(t, diff.Diff, "+  - image: api:v2")
+	assert.NotContains(t, diff.Diff, "pod-template-hash", "hash labels differ between every revision")
+
+	_, err = service.Diff(ctx, "deployment", "shop", "api", 5, 0)
+	assert.ErrorIs(t, err, errRevisionNotFound)
+
+	// Rolling back to the current template is a no-op
+	result, err := service.Rollback(ctx, "deployment", "shop", "api", RollbackRequest{Revision: 2})
+	require.NoError(t, err)
+	assert.True(t, result.Skipped)
+
+	result, err = service.Rollback(ctx, "deployment", "shop", "api", RollbackRequest{})
+	require.NoError(t, err)
+	assert.False(t, result.Skipped)
+	assert.Equal(t, int64(2), result.FromRevision)
+	assert.Equal(t, int64(1), result.ToRevision)
+	updated, err := clientset.AppsV1().Deployments("shop").Get(ctx, "api", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "api:v1", updated.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, labels, updated.Spec.Template.Labels)
+	assert.Equal(t, "rollback to revision 1", updated.Annotations[changeCauseAnnotation])
+
+	require.NoError(t, service.SetPaused(ctx, "deployment", "shop", "api", true))
+	// Rollback and pause patches are attributed to Kaptivan's field manager
+	patches := 0
+	for _, action := range clientset.Actions() {
+		if patch, ok := action.(k8stesting.PatchActionImpl); ok {
+			assert.Equal(t, manifests.FieldManager, patch.GetPatchOptions().FieldManager)
+			patches++
+		}
+	}
+	assert.Equal(t, 2, patches)
+	_, err = service.Rollback(ctx, "deployment", "shop", "api", RollbackRequest{Revision: 2})
+	assert.ErrorIs(t, err, errPaused)
+	history, err = service.History(ctx, "deployment", "shop", "api")
+	require.NoError(t, err)
+	assert.True(t, history.Paused)
+
+	assert.ErrorIs(t, service.SetPaused(ctx, "daemonset", "shop", "api", true), errPauseUnsupported)
+	_, err = service.History(ctx, "replicaset", "shop", "api")
+	assert.ErrorIs(t, err, errUnsupportedKind)
+}
+
+func TestDaemonSetRollout(t *testing.T) {
+	labels := map[string]string{"app": "agent"}
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "kube-system", UID: types.UID("agent-uid"), ResourceVersion: "17"},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: podTemplate("agent:v2", labels),
+		},
+	}
+	owner := *metav1.NewControllerRef(daemonSet, appsv1.SchemeGroupVersion.WithKind("DaemonSet"))
+	controllerRevision := func(name, image string, revision int64, created time.Time) *appsv1.ControllerRevision {
+		template := podTemplate(image, labels)
+		data, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"template": template}})
+		require.NoError(t, err)
+		// Controllers mark the template as a replacement
+		data = append(data[:len(data)-3], []byte(`,"$patch":"replace"}}}`)...)
+		return &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "kube-system", Labels: labels, OwnerReferences: []metav1.OwnerReference{owner},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Data:     runtime.RawExtension{Raw: data},
+			Revision: revision,
+		}
+	}
+	created := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	clientset := fake.NewSimpleClientset(daemonSet,
+		controllerRevision("agent-6f", "agent:v1", 1, created),
+		controllerRevision("agent-9c", "agent:v2", 2, created.Add(time.Hour)),
+	)
+	service := NewService(clientset)
+	ctx := context.Background()
+
+	history, err := service.History(ctx, "DaemonSet", "kube-system", "agent")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), history.CurrentRevision)
+	require.Len(t, history.Revisions, 2)
+	assert.Equal(t, []string{"agent:v1"}, history.Revisions[0].Images)
+	assert.Nil(t, history.Revisions[0].Replicas)
+	assert.True(t, history.Revisions[1].Current)
+
+	diff, err := service.Diff(ctx, "daemonset", "kube-system", "agent", 1, 2)
+	require.NoError(t, err)
+	assert.Contains(t, diff.Diff, "+  - image: agent:v2")
+
+	result, err := service.Rollback(ctx, "daemonset", "kube-system", "agent", RollbackRequest{Revision: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.ToRevision)
+	// The patch carries the resourceVersion the history was read at as a precondition
+	actions := clientset.Actions()
+	patch := actions[len(actions)-1].(k8stesting.PatchAction).GetPatch()
+	assert.Contains(t, string(patch), `"resourceVersion":"17"`)
+	assert.Contains(t, string(patch), `"$patch":"replace"`)
+	updated, err := clientset.AppsV1().DaemonSets("kube-system").Get(ctx, "agent", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "agent:v1", updated.Spec.Template.Spec.Containers[0].Image)
+}
@@ -0,0 +1,64 @@
+package rollouts
+
+import (
+	"time"
+)
+
+// Workload kinds with rollout history
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+	KindDaemonSet   = "DaemonSet"
+)
+
+// Revision is one entry in a workload's rollout history. Deployment revisions are
+// ReplicaSets; StatefulSet and DaemonSet revisions are ControllerRevisions.
+type Revision struct {
+	Revision    int64     `json:"revision"`
+	Name        string    `json:"name"` // the ReplicaSet or ControllerRevision
+	ChangeCause string    `json:"changeCause,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Images      []string  `json:"images"`
+	Replicas    *int32    `json:"replicas,omitempty"` // ReplicaSets only
+	Ready       *int32    `json:"ready,omitempty"`    // ReplicaSets only
+	Current     bool      `json:"current"`
+}
+
+// History lists a workload's revisions, oldest first
+type History struct {
+	Kind            string     `json:"kind"`
+	Namespace       string     `json:"namespace"`
+	Name            string     `json:"name"`
+	Paused          bool       `json:"paused"`
+	CurrentRevision int64      `json:"currentRevision"`
+	Revisions       []Revision `json:"revisions"`
+}
+
+// RevisionDiff is a unified diff between the pod templates of two revisions
+type RevisionDiff struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	From      int64  `json:"from"`
+	To        int64  `json:"to"`
+	Identical bool   `json:"identical"`
+	Diff      string `json:"diff,omitempty"`
+}
+
+// RollbackRequest selects the revision to roll back to; 0 is the previous revision
+type RollbackRequest struct {
+	Revision int64 `json:"revision"`
+	DryRun   bool  `json:"dryRun,omitempty"`
+}
+
+// RollbackResult describes a rollback
+type RollbackResult struct {
+	Kind         string `json:"kind"`
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	FromRevision int64  `json:"fromRevision"`
+	ToRevision   int64  `json:"toRevision"`
+	DryRun       bool   `json:"dryRun"`
+	Skipped      bool   `json:"skipped"` // the workload already runs the requested template
+	Message      string `json:"message"`
+}
@@ -39,12 +39,12 @@ func ListServices(c *gin.Context) {
 		}
 
 		for _, namespace := range namespaces {
-			services, err := client.ClientSet.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{})
+			services, err := listServices(contextName, client, namespace)
 			if err != nil {
 				continue
 			}
 
-			transformed := TransformServiceList(services.Items)
+			transformed := TransformServiceList(services)
 			allServices = append(allServices, transformed...)
 		}
 	}
@@ -54,6 +54,27 @@ func ListServices(c *gin.Context) {
 	})
 }
 
+// listServices reads services from the cluster's informer cache, falling back to
+// the API when the cache is unavailable
+func listServices(contextName string, client *kubernetes.ClusterConnection, namespace string) ([]corev1.Service, error) {
+	if resources, err := clusterManager.GetResourceCache(contextName); err == nil {
+		cached, err := kubernetes.ListAs[*corev1.Service](resources, "services", namespace)
+		if err == nil {
+			services := make([]corev1.Service, 0, len(cached))
+			for _, service := range cached {
+				services = append(services, *service)
+			}
+			return services, nil
+		}
+	}
+
+	services, err := client.ClientSet.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return services.Items, nil
+}
+
 func GetService(c *gin.Context) {
 	contextName := c.Param("context")
 	namespace := c.Param("namespace")
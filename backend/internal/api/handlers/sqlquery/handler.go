@@ -39,42 +39,14 @@ func HandleQuery(c *gin.Context) {
 		return
 	}
 
-	// Create validator
-	validator := NewSecurityValidator()
-
 	// Log the query (for debugging, remove in production)
 	klog.Infof("SQL Query received: %s", req.Query)
 
-	// Validate the raw query for security
-	if err := validator.ValidateQuery(req.Query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Query validation failed: " + err.Error(),
-		})
-		return
-	}
-
-	// Parse the SQL query
-	parser := NewSQLParser(req.Query)
-	parsedQuery, err := parser.Parse()
+	// Validate the raw query, parse it and validate the result
+	parsedQuery, err := ParseValidatedQuery(req.Query)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Query parsing failed: " + err.Error(),
-		})
-		return
-	}
-
-	// Validate the parsed query
-	if err := validator.ValidateParsedQuery(parsedQuery); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Parsed query validation failed: " + err.Error(),
-		})
-		return
-	}
-
-	// Validate namespace if specified
-	if err := validator.ValidateNamespace(parsedQuery.Namespace); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid namespace: " + err.Error(),
+			"error": err.Error(),
 		})
 		return
 	}
@@ -272,4 +272,24 @@ func (v *SecurityValidator) ValidateNamespace(namespace string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// ParseValidatedQuery validates a raw query, parses it and validates the parsed query.
+// HandleQuery and features that run queries on a user's behalf share this one path.
+func ParseValidatedQuery(query string) (*ParsedQuery, error) {
+	validator := NewSecurityValidator()
+	if err := validator.ValidateQuery(query); err != nil {
+		return nil, fmt.Errorf("query validation failed: %w", err)
+	}
+	parsedQuery, err := NewSQLParser(query).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("query parsing failed: %w", err)
+	}
+	if err := validator.ValidateParsedQuery(parsedQuery); err != nil {
+		return nil, fmt.Errorf("parsed query validation failed: %w", err)
+	}
+	if err := validator.ValidateNamespace(parsedQuery.Namespace); err != nil {
+		return nil, fmt.Errorf("invalid namespace: %w", err)
+	}
+	return parsedQuery, nil
+}
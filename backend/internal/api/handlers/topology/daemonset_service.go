@@ -30,10 +30,14 @@ func (s *Service) GetDaemonSetTopology(ctx context.Context, namespace, name stri
 		return nil, fmt.Errorf("failed to get pods: %w", err)
 	}
 
+	usage := s.getPodUsage(ctx, namespace)
+
 	// Filter pods owned by this DaemonSet
 	for _, pod := range allPods.Items {
 		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.Kind == "DaemonSet" && owner.UID == daemonSet.UID {
-			topology.Pods = append(topology.Pods, s.buildPodRef(&pod))
+			podRef := s.buildPodRef(&pod)
+			attachPodUsage(&pod, &podRef, usage)
+			topology.Pods = append(topology.Pods, podRef)
 		}
 	}
 
@@ -47,6 +51,15 @@ func (s *Service) GetDaemonSetTopology(ctx context.Context, namespace, name stri
 		topology.Services = append(topology.Services, s.buildServiceRef(&svc))
 	}
 
+	// Ingress and Gateway API routes reaching those services
+	topology.Routes = s.getRoutesForServices(ctx, namespace, serviceNames(services))
+
+	// Disruption budgets and VPAs covering the daemonset
+	topology.Scaling, topology.Warnings = s.getScaling(ctx, namespace, "DaemonSet", name, daemonSet.Spec.Template.Labels)
+	if topology.Scaling != nil {
+		topology.DaemonSet.Status = worstStatus(topology.DaemonSet.Status, topology.Scaling.Status)
+	}
+
 	// Fetch Endpoints for services
 	for _, svc := range services {
 		endpoints, err := s.clientset.CoreV1().Endpoints(namespace).Get(ctx, svc.Name, metav1.GetOptions{})
@@ -80,16 +93,22 @@ func (s *Service) GetDaemonSetTopology(ctx context.Context, namespace, name stri
 
 // ListDaemonSets lists all DaemonSets in a namespace
 func (s *Service) ListDaemonSets(ctx context.Context, namespace string) (*ListDaemonSetsResponse, error) {
-	daemonSets, err := s.clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	daemonSets, ok := cachedList[*appsv1.DaemonSet](s.resources, "daemonsets", namespace)
+	if !ok {
+		list, err := s.clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+		}
+		for i := range list.Items {
+			daemonSets = append(daemonSets, &list.Items[i])
+		}
 	}
 
 	response := &ListDaemonSetsResponse{
-		DaemonSets: make([]DaemonSetSummary, 0, len(daemonSets.Items)),
+		DaemonSets: make([]DaemonSetSummary, 0, len(daemonSets)),
 	}
 
-	for _, ds := range daemonSets.Items {
+	for _, ds := range daemonSets {
 		response.DaemonSets = append(response.DaemonSets, DaemonSetSummary{
 			Name:                   ds.Name,
 			Namespace:              ds.Namespace,
@@ -0,0 +1,550 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Export formats
+const (
+	ExportFormatDOT     = "dot"
+	ExportFormatMermaid = "mermaid"
+	ExportFormatGraphML = "graphml"
+	ExportFormatJSON    = "json"
+)
+
+// Edge types only used by single-workload exports
+const (
+	EdgeEndpoints = "endpoints" // Service to its Endpoints object
+	EdgeTargets   = "targets"   // Endpoints address to the pod behind it
+)
+
+// exportContentTypes maps each export format to its MIME type and file extension
+var exportContentTypes = map[string][2]string{
+	ExportFormatDOT:     {"text/vnd.graphviz; charset=utf-8", "dot"},
+	ExportFormatMermaid: {"text/plain; charset=utf-8", "mmd"},
+	ExportFormatGraphML: {"application/graphml+xml; charset=utf-8", "graphml"},
+	ExportFormatJSON:    {"application/json; charset=utf-8", "json"},
+}
+
+// statusColors are the fill colours used for node health in DOT and Mermaid output
+var statusColors = map[K8sStatus]string{
+	StatusHealthy: "#d1fae5",
+	StatusWarning: "#fef3c7",
+	StatusError:   "#fee2e2",
+	StatusUnknown: "#e5e7eb",
+}
+
+// podRefStatus derives a health status from a pod reference
+func podRefStatus(pod PodRef) K8sStatus {
+	switch pod.Phase {
+	case PodFailed, PodCrashLoopBackOff:
+		return StatusError
+	case PodPending, PodTerminating:
+		return StatusWarning
+	case PodSucceeded:
+		return StatusHealthy
+	case PodRunning:
+		for _, container := range pod.Containers {
+			if !container.Ready {
+				return StatusWarning
+			}
+		}
+		return StatusHealthy
+	}
+	return StatusUnknown
+}
+
+// replicaSetRefStatus rolls up ReplicaSet readiness like replicaSetStatus
+func replicaSetRefStatus(rs ReplicaSetRef) K8sStatus {
+	switch {
+	case rs.Ready == rs.Desired:
+		return StatusHealthy
+	case rs.Ready == 0:
+		return StatusError
+	default:
+		return StatusWarning
+	}
+}
+
+// workloadParts are the pieces every single-workload topology shares
+type workloadParts struct {
+	namespace           string
+	workloadID          string
+	pods                []PodRef
+	services            []ServiceRef
+	endpoints           []EndpointsRef
+	secrets             []SecretRef
+	configMaps          []ConfigMapRef
+	serviceAccount      *ServiceAccountRef
+	roles               []RoleRef
+	roleBindings        []RoleBindingRef
+	clusterRoles        []RoleRef
+	clusterRoleBindings []RoleBindingRef
+	routes              []RouteRef
+	scaling             *ScalingRef
+}
+
+// addPodRefs adds pod nodes owned by ownerID
+func (b *graphBuilder) addPodRefs(namespace, ownerID string, pods []PodRef) {
+	for _, pod := range pods {
+		node := b.node("Pod", namespace, pod.Name, podRefStatus(pod))
+		node.Labels = pod.Labels
+		node.Details["phase"] = pod.Phase
+		if pod.NodeName != "" {
+			node.Details["nodeName"] = pod.NodeName
+		}
+		if pod.PodIP != "" {
+			node.Details["podIP"] = pod.PodIP
+		}
+		b.edge(ownerID, node.ID, EdgeOwns, "")
+	}
+}
+
+// addWorkloadParts adds services, configuration, RBAC and routes around a workload
+func (b *graphBuilder) addWorkloadParts(parts workloadParts) {
+	ns := parts.namespace
+
+	for _, svc := range parts.services {
+		node := b.node("Service", ns, svc.Name, StatusHealthy)
+		node.Details["type"] = svc.Type
+		if svc.ClusterIP != "" {
+			node.Details["clusterIP"] = svc.ClusterIP
+		}
+		selected := 0
+		if len(svc.Selector) > 0 {
+			node.Details["selector"] = labels.Set(svc.Selector).String()
+			selector := labels.SelectorFromSet(svc.Selector)
+			for _, pod := range parts.pods {
+				if selector.Matches(labels.Set(pod.Labels)) {
+					b.edge(node.ID, graphNodeID("Pod", ns, pod.Name), EdgeSelects, "")
+					selected++
+				}
+			}
+			if selected == 0 && len(parts.pods) > 0 {
+				node.Status = StatusWarning
+			}
+		}
+	}
+
+	for _, endpoints := range parts.endpoints {
+		status := StatusHealthy
+		if len(endpoints.Addresses) == 0 {
+			status = StatusWarning
+		}
+		node := b.node("Endpoints", ns, endpoints.Name, status)
+		node.Details["addresses"] = len(endpoints.Addresses)
+		b.edge(graphNodeID("Service", ns, endpoints.Name), node.ID, EdgeEndpoints, "")
+		for _, address := range endpoints.Addresses {
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+				b.edge(node.ID, graphNodeID("Pod", ns, address.TargetRef.Name), EdgeTargets, address.IP)
+			}
+		}
+	}
+
+	// Topologies list every Secret and ConfigMap in the namespace; only referenced ones are exported
+	for _, secret := range parts.secrets {
+		if len(secret.MountedAt) == 0 && len(secret.KeysUsed) == 0 {
+			continue
+		}
+		node := b.node("Secret", ns, secret.Name, StatusHealthy)
+		if secret.Type != "" {
+			node.Details["type"] = secret.Type
+		}
+		b.edge(parts.workloadID, node.ID, EdgeUses, strings.Join(secret.MountedAt, ","))
+	}
+	for _, cm := range parts.configMaps {
+		if len(cm.MountedAt) == 0 && len(cm.KeysUsed) == 0 {
+			continue
+		}
+		node := b.node("ConfigMap", ns, cm.Name, StatusHealthy)
+		b.edge(parts.workloadID, node.ID, EdgeUses, strings.Join(cm.MountedAt, ","))
+	}
+
+	if sa := parts.serviceAccount; sa != nil {
+		saNode := b.node("ServiceAccount", ns, sa.Name, StatusHealthy)
+		b.edge(parts.workloadID, saNode.ID, EdgeRunsAs, "")
+
+		for _, rb := range parts.roleBindings {
+			binding := b.node("RoleBinding", ns, rb.Name, StatusHealthy)
+			b.edge(binding.ID, saNode.ID, EdgeBinds, "")
+			roleNamespace := ns
+			if rb.RoleRef.Kind == "ClusterRole" {
+				roleNamespace = ""
+			}
+			b.edge(binding.ID, b.node(rb.RoleRef.Kind, roleNamespace, rb.RoleRef.Name, StatusHealthy).ID, EdgeGrants, "")
+		}
+		for _, crb := range parts.clusterRoleBindings {
+			binding := b.node("ClusterRoleBinding", "", crb.Name, StatusHealthy)
+			b.edge(binding.ID, saNode.ID, EdgeBinds, "")
+			b.edge(binding.ID, b.node("ClusterRole", "", crb.RoleRef.Name, StatusHealthy).ID, EdgeGrants, "")
+		}
+	}
+	for _, role := range parts.roles {
+		b.node("Role", ns, role.Name, StatusHealthy).Details["rules"] = len(role.Rules)
+	}
+	for _, role := range parts.clusterRoles {
+		b.node("ClusterRole", "", role.Name, StatusHealthy).Details["rules"] = len(role.Rules)
+	}
+
+	if scaling := parts.scaling; scaling != nil {
+		for _, hpa := range scaling.Autoscalers {
+			node := b.node("HorizontalPodAutoscaler", ns, hpa.Name, hpa.Status)
+			node.Details["minReplicas"] = hpa.MinReplicas
+			node.Details["maxReplicas"] = hpa.MaxReplicas
+			node.Details["currentReplicas"] = hpa.CurrentReplicas
+			node.Details["desiredReplicas"] = hpa.DesiredReplicas
+			b.edge(node.ID, parts.workloadID, EdgeScales, "")
+		}
+		for _, pdb := range scaling.DisruptionBudgets {
+			node := b.node("PodDisruptionBudget", ns, pdb.Name, pdb.Status)
+			node.Details["disruptionsAllowed"] = pdb.DisruptionsAllowed
+			b.edge(node.ID, parts.workloadID, EdgeProtects, "")
+		}
+		for _, vpa := range scaling.VerticalAutoscalers {
+			node := b.node("VerticalPodAutoscaler", ns, vpa.Name, vpa.Status)
+			node.Details["updateMode"] = vpa.UpdateMode
+			b.edge(node.ID, parts.workloadID, EdgeScales, "")
+		}
+	}
+
+	for _, route := range parts.routes {
+		node := b.node(route.Kind, route.Namespace, route.Name, route.Status)
+		if route.Class != "" {
+			node.Details["class"] = route.Class
+		}
+		for _, gateway := range route.Gateways {
+			gatewayNode := b.node("Gateway", gateway.Namespace, gateway.Name, gateway.Status)
+			b.edge(gatewayNode.ID, node.ID, EdgeParent, gateway.SectionName)
+		}
+		for _, rule := range route.Rules {
+			if !rule.Backend.Workload {
+				continue
+			}
+			label := strings.Join(rule.Hosts, ",") + rule.Path
+			if rule.Backend.Port != "" {
+				label += " -> " + rule.Backend.Port
+			}
+			b.edge(node.ID, graphNodeID("Service", rule.Backend.Namespace, rule.Backend.Service), EdgeRoutes, label)
+		}
+	}
+}
+
+// exportGraph converts any single-workload topology response to the graph schema
+func exportGraph(topology interface{}) (*TopologyGraph, error) {
+	b := newGraphBuilder()
+
+	switch t := topology.(type) {
+	case *DeploymentTopology:
+		workload := b.node("Deployment", t.Namespace, t.Deployment.Name, t.Deployment.Status)
+		workload.Labels = t.Deployment.Labels
+		workload.Details["replicas"] = t.Deployment.Replicas
+		var pods []PodRef
+		for _, rs := range t.ReplicaSets {
+			rsNode := b.node("ReplicaSet", t.Namespace, rs.Name, replicaSetRefStatus(rs))
+			rsNode.Details["desired"] = rs.Desired
+			rsNode.Details["ready"] = rs.Ready
+			b.edge(workload.ID, rsNode.ID, EdgeOwns, "")
+			b.addPodRefs(t.Namespace, rsNode.ID, rs.Pods)
+			pods = append(pods, rs.Pods...)
+		}
+		b.addWorkloadParts(workloadParts{
+			namespace: t.Namespace, workloadID: workload.ID, pods: pods,
+			services: t.Services, endpoints: t.Endpoints, secrets: t.Secrets, configMaps: t.ConfigMaps,
+			serviceAccount: t.ServiceAccount, roles: t.Roles, roleBindings: t.RoleBindings,
+			clusterRoles: t.ClusterRoles, clusterRoleBindings: t.ClusterRoleBindings, routes: t.Routes,
+			scaling: t.Scaling,
+		})
+		graph := finishExportGraph(b, t.Namespace, workload.ID)
+		graph.Warnings = append(graph.Warnings, t.Warnings...)
+		return graph, nil
+
+	case *DaemonSetTopology:
+		workload := b.node("DaemonSet", t.Namespace, t.DaemonSet.Name, t.DaemonSet.Status)
+		workload.Labels = t.DaemonSet.Labels
+		workload.Details["desired"] = t.DaemonSet.DesiredNumberScheduled
+		workload.Details["ready"] = t.DaemonSet.NumberReady
+		b.addPodRefs(t.Namespace, workload.ID, t.Pods)
+		b.addWorkloadParts(workloadParts{
+			namespace: t.Namespace, workloadID: workload.ID, pods: t.Pods,
+			services: t.Services, endpoints: t.Endpoints, secrets: t.Secrets, configMaps: t.ConfigMaps,
+			serviceAccount: t.ServiceAccount, roles: t.Roles, roleBindings: t.RoleBindings,
+			clusterRoles: t.ClusterRoles, clusterRoleBindings: t.ClusterRoleBindings, routes: t.Routes,
+			scaling: t.Scaling,
+		})
+		graph := finishExportGraph(b, t.Namespace, workload.ID)
+		graph.Warnings = append(graph.Warnings, t.Warnings...)
+		return graph, nil
+
+	case *StatefulSetTopology:
+		workload := b.node("StatefulSet", t.Namespace, t.StatefulSet.Name, t.StatefulSet.Status)
+		workload.Labels = t.StatefulSet.Labels
+		workload.Details["replicas"] = t.StatefulSet.Replicas
+		workload.Details["ready"] = t.StatefulSet.ReadyReplicas
+		pods := make([]PodRef, 0, len(t.Pods))
+		for _, pod := range t.Pods {
+			pods = append(pods, pod.PodRef)
+		}
+		b.addPodRefs(t.Namespace, workload.ID, pods)
+		for _, pod := range t.Pods {
+			for _, claim := range pod.Claims {
+				b.edge(graphNodeID("Pod", t.Namespace, pod.Name), b.node("PersistentVolumeClaim", t.Namespace, claim, StatusUnknown).ID, EdgeClaims, "")
+			}
+		}
+		for _, template := range t.VolumeClaimTemplates {
+			for _, claim := range template.Claims {
+				status := StatusWarning
+				if claim.Phase == "Bound" {
+					status = StatusHealthy
+				}
+				b.node("PersistentVolumeClaim", t.Namespace, claim.Name, status).Status = status // Pod claims were added as Unknown
+			}
+		}
+		services := t.Services
+		if t.GoverningService != nil {
+			services = append([]ServiceRef{*t.GoverningService}, services...)
+		}
+		b.addWorkloadParts(workloadParts{
+			namespace: t.Namespace, workloadID: workload.ID, pods: pods,
+			services: services, endpoints: t.Endpoints, secrets: t.Secrets, configMaps: t.ConfigMaps,
+			serviceAccount: t.ServiceAccount, routes: t.Routes, scaling: t.Scaling,
+		})
+		graph := finishExportGraph(b, t.Namespace, workload.ID)
+		graph.Warnings = append(graph.Warnings, t.Warnings...)
+		return graph, nil
+
+	case *JobTopology:
+		workload := b.node("Job", t.Namespace, t.Job.Name, t.Job.Status)
+		workload.Labels = t.Job.Labels
+		workload.Details["succeeded"] = t.Job.Succeeded
+		workload.Details["failed"] = t.Job.Failed
+		b.addPodRefs(t.Namespace, workload.ID, t.Pods)
+		b.addWorkloadParts(workloadParts{
+			namespace: t.Namespace, workloadID: workload.ID, pods: t.Pods,
+			services: t.Services, endpoints: t.Endpoints, secrets: t.Secrets, configMaps: t.ConfigMaps,
+			serviceAccount: t.ServiceAccount, roles: t.Roles, roleBindings: t.RoleBindings,
+			clusterRoles: t.ClusterRoles, clusterRoleBindings: t.ClusterRoleBindings,
+		})
+		return finishExportGraph(b, t.Namespace, workload.ID), nil
+
+	case *CronJobTopology:
+		workload := b.node("CronJob", t.Namespace, t.CronJob.Name, t.CronJob.Status)
+		workload.Labels = t.CronJob.Labels
+		workload.Details["schedule"] = t.CronJob.Schedule
+		for _, job := range t.Jobs {
+			jobNode := b.node("Job", t.Namespace, job.Name, job.Status)
+			jobNode.Details["succeeded"] = job.Succeeded
+			jobNode.Details["failed"] = job.Failed
+			b.edge(workload.ID, jobNode.ID, EdgeOwns, "")
+		}
+		// Pods hang off the Job that created them
+		for _, pod := range t.Pods {
+			owner := workload.ID
+			for _, ref := range pod.OwnerReferences {
+				if ref.Kind == "Job" {
+					owner = graphNodeID("Job", t.Namespace, ref.Name)
+				}
+			}
+			b.addPodRefs(t.Namespace, owner, []PodRef{pod})
+		}
+		b.addWorkloadParts(workloadParts{
+			namespace: t.Namespace, workloadID: workload.ID, pods: t.Pods,
+			services: t.Services, endpoints: t.Endpoints, secrets: t.Secrets, configMaps: t.ConfigMaps,
+			serviceAccount: t.ServiceAccount, roles: t.Roles, roleBindings: t.RoleBindings,
+			clusterRoles: t.ClusterRoles, clusterRoleBindings: t.ClusterRoleBindings,
+		})
+		return finishExportGraph(b, t.Namespace, workload.ID), nil
+	}
+
+	return nil, fmt.Errorf("unsupported topology type %T", topology)
+}
+
+// finishExportGraph drops edges to nodes the topology did not include and builds the graph
+func finishExportGraph(b *graphBuilder, namespace, workloadID string) *TopologyGraph {
+	b.roots[workloadID] = workloadID
+	b.retain(func(*GraphNode) bool { return true })
+	graph := b.graph()
+	graph.Namespaces = []string{namespace}
+	return graph
+}
+
+// RenderTopology renders a topology graph in one of the export formats
+func RenderTopology(graph *TopologyGraph, format string) ([]byte, error) {
+	switch format {
+	case ExportFormatDOT:
+		return []byte(renderDOT(graph)), nil
+	case ExportFormatMermaid:
+		return []byte(renderMermaid(graph)), nil
+	case ExportFormatGraphML:
+		return renderGraphML(graph)
+	case ExportFormatJSON:
+		return json.MarshalIndent(graph, "", "  ")
+	}
+	return nil, fmt.Errorf("unsupported export format %q: expected dot, mermaid, graphml or json", format)
+}
+
+// nodeLabel is the two-line label shown for a node
+func nodeLabel(node GraphNode) string {
+	return node.Kind + "\n" + node.Name
+}
+
+// dotQuote quotes a DOT identifier or attribute value
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+func renderDOT(graph *TopologyGraph) string {
+	var out strings.Builder
+	out.WriteString("digraph topology {\n")
+	out.WriteString("  rankdir=LR;\n")
+	out.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	out.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(&out, "  %s [label=%s, kind=%s, name=%s, namespace=%s, status=%s, fillcolor=%s];\n",
+			dotQuote(node.ID), dotQuote(nodeLabel(node)), dotQuote(node.Kind), dotQuote(node.Name),
+			dotQuote(node.Namespace), dotQuote(string(node.Status)), dotQuote(statusColors[node.Status]))
+	}
+	for _, edge := range graph.Edges {
+		label := edge.Type
+		if edge.Label != "" {
+			label += ": " + edge.Label
+		}
+		fmt.Fprintf(&out, "  %s -> %s [label=%s, type=%s];\n",
+			dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(label), dotQuote(edge.Type))
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// mermaidText escapes text for a quoted Mermaid label
+func mermaidText(value string) string {
+	value = strings.ReplaceAll(value, `"`, "#quot;")
+	value = strings.ReplaceAll(value, "|", "#124;")
+	return strings.ReplaceAll(value, "\n", "<br/>")
+}
+
+func renderMermaid(graph *TopologyGraph) string {
+	ids := make(map[string]string, len(graph.Nodes))
+	var out strings.Builder
+	out.WriteString("flowchart LR\n")
+	for i, node := range graph.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.ID] = id
+		fmt.Fprintf(&out, "  %%%% %s status=%s\n", node.ID, node.Status)
+		fmt.Fprintf(&out, "  %s[\"%s\"]:::%s\n", id, mermaidText(nodeLabel(node)), strings.ToLower(string(node.Status)))
+	}
+	for _, edge := range graph.Edges {
+		label := edge.Type
+		if edge.Label != "" {
+			label += ": " + edge.Label
+		}
+		fmt.Fprintf(&out, "  %s -->|\"%s\"| %s\n", ids[edge.Source], mermaidText(label), ids[edge.Target])
+	}
+
+	statuses := make([]string, 0, len(statusColors))
+	for status := range statusColors {
+		statuses = append(statuses, string(status))
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(&out, "  classDef %s fill:%s,stroke:#374151\n", strings.ToLower(status), statusColors[K8sStatus(status)])
+	}
+	return out.String()
+}
+
+// GraphML document structure
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderGraphML(graph *TopologyGraph) ([]byte, error) {
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+			{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+			{ID: "namespace", For: "node", AttrName: "namespace", AttrType: "string"},
+			{ID: "status", For: "node", AttrName: "status", AttrType: "string"},
+			{ID: "color", For: "node", AttrName: "color", AttrType: "string"},
+			{ID: "type", For: "edge", AttrName: "type", AttrType: "string"},
+			{ID: "label", For: "edge", AttrName: "label", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "topology", EdgeDefault: "directed"},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ID,
+			Data: []graphMLData{
+				{Key: "kind", Value: node.Kind},
+				{Key: "name", Value: node.Name},
+				{Key: "namespace", Value: node.Namespace},
+				{Key: "status", Value: string(node.Status)},
+				{Key: "color", Value: statusColors[node.Status]},
+			},
+		})
+	}
+	for _, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     edge.ID,
+			Source: edge.Source,
+			Target: edge.Target,
+			Data: []graphMLData{
+				{Key: "type", Value: edge.Type},
+				{Key: "label", Value: edge.Label},
+			},
+		})
+	}
+
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode graphml: %w", err)
+	}
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
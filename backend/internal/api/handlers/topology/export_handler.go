@@ -0,0 +1,91 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// errUnsupportedWorkloadKind is returned for kinds without a single-workload topology
+var errUnsupportedWorkloadKind = errors.New("kind must be deployment, daemonset, statefulset, job or cronjob")
+
+// workloadTopology fetches the topology response of one workload
+func (h *Handler) workloadTopology(ctx context.Context, target SnapshotTarget) (interface{}, error) {
+	clientset, err := h.getClusterClient(target.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target.Kind {
+	case "deployment":
+		return NewService(clientset).withDynamicClient(h.dynamicClient(target.Context)).GetDeploymentTopology(ctx, target.Namespace, target.Name)
+	case "daemonset":
+		return NewService(clientset).withDynamicClient(h.dynamicClient(target.Context)).GetDaemonSetTopology(ctx, target.Namespace, target.Name)
+	case "statefulset":
+		return NewService(clientset).withDynamicClient(h.dynamicClient(target.Context)).GetStatefulSetTopology(ctx, target.Namespace, target.Name)
+	case "job":
+		return NewJobService(h.manager).GetJobTopology(ctx, target.Context, target.Namespace, target.Name)
+	case "cronjob":
+		typed, ok := clientset.(*k8s.Clientset)
+		if !ok {
+			return nil, fmt.Errorf("cronjob topology requires a typed clientset")
+		}
+		return NewCronJobService(typed).GetCronJobTopology(ctx, target.Namespace, target.Name)
+	}
+	return nil, errUnsupportedWorkloadKind
+}
+
+// ExportTopology handles GET /api/v1/topology/export
+// Query params: context, namespace, kind (deployment, daemonset, statefulset, job or cronjob),
+// name (all required) and format (dot, mermaid, graphml or json; default json)
+func (h *Handler) ExportTopology(c *gin.Context) {
+	context := c.Query("context")
+	namespace := c.Query("namespace")
+	kind := strings.ToLower(c.Query("kind"))
+	name := c.Query("name")
+	format := strings.ToLower(c.DefaultQuery("format", ExportFormatJSON))
+
+	if context == "" || namespace == "" || kind == "" || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context, namespace, kind and name are required"})
+		return
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be dot, mermaid, graphml or json"})
+		return
+	}
+
+	if _, err := h.getClusterClient(context); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	topology, err := h.workloadTopology(c.Request.Context(), SnapshotTarget{Context: context, Namespace: namespace, Kind: kind, Name: name})
+	if err == errUnsupportedWorkloadKind {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	graph, err := exportGraph(topology)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := RenderTopology(graph, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s-%s.%s", kind, namespace, name, contentType[1])))
+	c.Data(http.StatusOK, contentType[0], body)
+}
@@ -0,0 +1,86 @@
+package topology
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestTopology() *DeploymentTopology {
+	return &DeploymentTopology{
+		Namespace:  "shop",
+		Deployment: DeploymentInfo{Name: "api", Replicas: 2, Status: StatusWarning},
+		ReplicaSets: []ReplicaSetRef{{
+			Name: "api-7d9f", Desired: 2, Ready: 1,
+			Pods: []PodRef{
+				{Name: "api-7d9f-a", Phase: PodRunning, Labels: map[string]string{"app": "api"}, Containers: []ContainerRef{{Name: "api", Ready: true}}},
+				{Name: "api-7d9f-b", Phase: PodCrashLoopBackOff, Labels: map[string]string{"app": "api"}},
+			},
+		}},
+		Services: []ServiceRef{{Name: "api", Type: "ClusterIP", Selector: map[string]string{"app": "api"}}},
+		Secrets: []SecretRef{
+			{Name: "api-token", MountedAt: []string{"env:TOKEN"}},
+			{Name: "unrelated"},
+		},
+		ServiceAccount: &ServiceAccountRef{Name: "api"},
+		Routes: []RouteRef{{
+			Kind: "Ingress", Name: "shop \"public\"", Namespace: "shop", Status: StatusHealthy,
+			Rules: []RouteRule{{Hosts: []string{"shop.example.com"}, Path: "/api", Backend: RouteBackend{Service: "api", Namespace: "shop", Port: "80", Workload: true}}},
+		}},
+	}
+}
+
+func TestExportGraph(t *testing.T) {
+	graph, err := exportGraph(exportTestTopology())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, graph.Summary.ByKind["Secret"], "only referenced secrets are exported")
+	assert.Equal(t, StatusWarning, graph.Summary.NamespaceStatus["shop"])
+	assert.Equal(t, 1, graph.Summary.ByStatus[StatusError])
+
+	edges := edgeSet(graph)
+	assert.Contains(t, edges, "Deployment/shop/api owns ReplicaSet/shop/api-7d9f")
+	assert.Contains(t, edges, "Service/shop/api selects Pod/shop/api-7d9f-b")
+	assert.Contains(t, edges, "Ingress/shop/shop \"public\" routes Service/shop/api")
+
+	_, err = exportGraph(&ListJobsResponse{})
+	assert.Error(t, err)
+}
+
+func TestRenderTopology(t *testing.T) {
+	graph, err := exportGraph(exportTestTopology())
+	require.NoError(t, err)
+
+	dot, err := RenderTopology(graph, ExportFormatDOT)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(dot), "digraph topology {"))
+	assert.Contains(t, string(dot), `"Pod/shop/api-7d9f-b" [label="Pod\napi-7d9f-b", kind="Pod", name="api-7d9f-b", namespace="shop", status="Error"`)
+	assert.Contains(t, string(dot), `"Ingress/shop/shop \"public\""`)
+
+	mermaid, err := RenderTopology(graph, ExportFormatMermaid)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(mermaid), "flowchart LR\n"))
+	assert.Contains(t, string(mermaid), `["Pod<br/>api-7d9f-b"]:::error`)
+	assert.Contains(t, string(mermaid), "#quot;public#quot;")
+	assert.Contains(t, string(mermaid), "classDef error fill:#fee2e2")
+
+	graphML, err := RenderTopology(graph, ExportFormatGraphML)
+	require.NoError(t, err)
+	var doc graphMLDocument
+	require.NoError(t, xml.Unmarshal(graphML, &doc))
+	assert.Len(t, doc.Graph.Nodes, len(graph.Nodes))
+	assert.Len(t, doc.Graph.Edges, len(graph.Edges))
+
+	data, err := RenderTopology(graph, ExportFormatJSON)
+	require.NoError(t, err)
+	var decoded TopologyGraph
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, graph.Summary.Nodes, len(decoded.Nodes))
+
+	_, err = RenderTopology(graph, "png")
+	assert.Error(t, err)
+}
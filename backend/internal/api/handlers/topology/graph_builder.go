@@ -0,0 +1,809 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// graphWorkload is a root workload with the pod template its pods are created from
+type graphWorkload struct {
+	id        string
+	namespace string
+	labels    map[string]string
+	template  *corev1.PodTemplateSpec
+}
+
+// graphBuilder accumulates nodes and edges while a graph is assembled
+type graphBuilder struct {
+	nodes     map[string]*GraphNode
+	nodeOrder []string
+	edges     map[string]GraphEdge
+	edgeOrder []string
+	roots     map[string]string // node ID -> ID of the workload it belongs to
+	workloads []graphWorkload
+	warnings  []string
+	collapsed int
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{
+		nodes: make(map[string]*GraphNode),
+		edges: make(map[string]GraphEdge),
+		roots: make(map[string]string),
+	}
+}
+
+// warn records a non-fatal problem
+func (b *graphBuilder) warn(format string, args ...interface{}) {
+	b.warnings = append(b.warnings, fmt.Sprintf(format, args...))
+}
+
+// node returns the node with the given identity, creating it if needed
+func (b *graphBuilder) node(kind, namespace, name string, status K8sStatus) *GraphNode {
+	id := graphNodeID(kind, namespace, name)
+	if existing, ok := b.nodes[id]; ok {
+		return existing
+	}
+	node := &GraphNode{
+		ID:        id,
+		Kind:      kind,
+		Name:      name,
+		Namespace: namespace,
+		Status:    status,
+		Details:   make(map[string]interface{}),
+	}
+	b.nodes[id] = node
+	b.nodeOrder = append(b.nodeOrder, id)
+	return node
+}
+
+// edge adds a directed edge once
+func (b *graphBuilder) edge(source, target, edgeType, label string) {
+	if source == target {
+		return
+	}
+	id := source + "->" + target + ":" + edgeType
+	if _, ok := b.edges[id]; ok {
+		return
+	}
+	b.edges[id] = GraphEdge{ID: id, Source: source, Target: target, Type: edgeType, Label: label}
+	b.edgeOrder = append(b.edgeOrder, id)
+}
+
+// build turns the loaded objects into nodes and edges
+func (b *graphBuilder) build(in *graphInputs) {
+	b.addWorkloads(in)
+	b.addPods(in)
+	b.addTemplateRefs(in)
+	b.addServices(in)
+	b.addIngresses(in)
+	b.addGatewayRoutes(in)
+	b.addAutoscalers(in)
+	b.addDisruptionBudgets(in)
+	b.addRBAC(in)
+}
+
+func (b *graphBuilder) addWorkloads(in *graphInputs) {
+	service := &Service{}
+	cronJobService := &CronJobService{}
+
+	for _, deployment := range in.deployments {
+		node := b.node("Deployment", deployment.Namespace, deployment.Name, service.getDeploymentStatus(deployment))
+		node.Labels = deployment.Labels
+		node.Details["replicas"] = deployment.Spec.Replicas
+		node.Details["ready"] = deployment.Status.ReadyReplicas
+		b.addWorkload(node, deployment.Spec.Template.Labels, &deployment.Spec.Template)
+	}
+
+	for _, rs := range in.replicaSets {
+		owner := controllerOf(rs.OwnerReferences)
+		if owner == nil || owner.Kind != "Deployment" {
+			continue
+		}
+		ownerID := graphNodeID("Deployment", rs.Namespace, owner.Name)
+		if _, ok := b.nodes[ownerID]; !ok {
+			continue
+		}
+		// Skip scaled-down revisions; they are only history
+		if (rs.Spec.Replicas == nil || *rs.Spec.Replicas == 0) && rs.Status.Replicas == 0 {
+			continue
+		}
+		node := b.node("ReplicaSet", rs.Namespace, rs.Name, replicaSetStatus(rs))
+		node.Details["replicas"] = rs.Spec.Replicas
+		node.Details["ready"] = rs.Status.ReadyReplicas
+		node.Details["revision"] = rs.Annotations["deployment.kubernetes.io/revision"]
+		b.roots[node.ID] = ownerID
+		b.edge(ownerID, node.ID, EdgeOwns, "")
+	}
+
+	for _, sts := range in.statefulSets {
+		info := service.buildStatefulSetInfo(sts)
+		node := b.node("StatefulSet", sts.Namespace, sts.Name, info.Status)
+		node.Labels = sts.Labels
+		node.Details["replicas"] = info.Replicas
+		node.Details["ready"] = info.ReadyReplicas
+		b.addWorkload(node, sts.Spec.Template.Labels, &sts.Spec.Template)
+	}
+
+	for _, ds := range in.daemonSets {
+		info := service.buildDaemonSetInfo(ds)
+		node := b.node("DaemonSet", ds.Namespace, ds.Name, info.Status)
+		node.Labels = ds.Labels
+		node.Details["desired"] = info.DesiredNumberScheduled
+		node.Details["ready"] = info.NumberReady
+		b.addWorkload(node, ds.Spec.Template.Labels, &ds.Spec.Template)
+	}
+
+	for _, cj := range in.cronJobs {
+		info := cronJobService.buildCronJobInfo(cj)
+		node := b.node("CronJob", cj.Namespace, cj.Name, info.Status)
+		node.Labels = cj.Labels
+		node.Details["schedule"] = cj.Spec.Schedule
+		node.Details["active"] = len(cj.Status.Active)
+		b.addWorkload(node, cj.Spec.JobTemplate.Spec.Template.Labels, &cj.Spec.JobTemplate.Spec.Template)
+	}
+
+	for _, job := range in.jobs {
+		info := convertJobToJobInfo(job)
+		node := b.node("Job", job.Namespace, job.Name, info.Status)
+		node.Labels = job.Labels
+		node.Details["active"] = job.Status.Active
+		node.Details["succeeded"] = job.Status.Succeeded
+		node.Details["failed"] = job.Status.Failed
+
+		if owner := controllerOf(job.OwnerReferences); owner != nil && owner.Kind == "CronJob" {
+			ownerID := graphNodeID("CronJob", job.Namespace, owner.Name)
+			if _, ok := b.nodes[ownerID]; ok {
+				b.roots[node.ID] = ownerID
+				b.edge(ownerID, node.ID, EdgeOwns, "")
+				continue
+			}
+		}
+		b.addWorkload(node, job.Spec.Template.Labels, &job.Spec.Template)
+	}
+}
+
+// addWorkload registers a node as a graph root
+func (b *graphBuilder) addWorkload(node *GraphNode, templateLabels map[string]string, template *corev1.PodTemplateSpec) {
+	b.roots[node.ID] = node.ID
+	b.workloads = append(b.workloads, graphWorkload{
+		id:        node.ID,
+		namespace: node.Namespace,
+		labels:    templateLabels,
+		template:  template,
+	})
+}
+
+func (b *graphBuilder) addPods(in *graphInputs) {
+	for _, pod := range in.pods {
+		node := b.node("Pod", pod.Namespace, pod.Name, determinePodStatus(pod))
+		node.Labels = pod.Labels
+		node.Details["phase"] = pod.Status.Phase
+		node.Details["nodeName"] = pod.Spec.NodeName
+		var restarts int32
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+		node.Details["restarts"] = restarts
+
+		owner := controllerOf(pod.OwnerReferences)
+		if owner != nil {
+			ownerID := graphNodeID(owner.Kind, pod.Namespace, owner.Name)
+			if _, ok := b.nodes[ownerID]; ok {
+				b.roots[node.ID] = b.roots[ownerID]
+				b.edge(ownerID, node.ID, EdgeOwns, "")
+				continue
+			}
+		}
+
+		// Pods without a known controller are workloads of their own
+		b.addWorkload(node, pod.Labels, &corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec})
+	}
+}
+
+// addTemplateRefs links workloads to the ConfigMaps, Secrets, PVCs and ServiceAccounts their pods use
+func (b *graphBuilder) addTemplateRefs(in *graphInputs) {
+	configMaps := make(map[string]bool)
+	for _, cm := range in.configMaps {
+		configMaps[cm.Namespace+"/"+cm.Name] = true
+	}
+	pvcs := make(map[string]*corev1.PersistentVolumeClaim)
+	for _, pvc := range in.pvcs {
+		pvcs[pvc.Namespace+"/"+pvc.Name] = pvc
+	}
+	serviceAccounts := make(map[string]bool)
+	for _, sa := range in.serviceAccounts {
+		serviceAccounts[sa.Namespace+"/"+sa.Name] = true
+	}
+
+	for _, workload := range b.workloads {
+		spec := &workload.template.Spec
+		for _, ref := range podSpecRefs(spec) {
+			key := workload.namespace + "/" + ref.name
+			switch ref.kind {
+			case "ConfigMap":
+				status := StatusHealthy
+				if !configMaps[key] {
+					status = StatusError
+				}
+				node := b.node("ConfigMap", workload.namespace, ref.name, status)
+				node.Details["missing"] = status == StatusError
+				b.edge(workload.id, node.ID, EdgeUses, strings.Join(ref.usages, ","))
+			case "Secret":
+				status := StatusUnknown
+				if in.secretsKnown {
+					status = StatusHealthy
+					if !in.secretNames[key] {
+						status = StatusError
+					}
+				}
+				node := b.node("Secret", workload.namespace, ref.name, status)
+				node.Details["missing"] = status == StatusError
+				b.edge(workload.id, node.ID, EdgeUses, strings.Join(ref.usages, ","))
+			case "PersistentVolumeClaim":
+				b.edge(workload.id, b.claimNode(pvcs, workload.namespace, ref.name).ID, EdgeClaims, "")
+			}
+		}
+
+		saName := spec.ServiceAccountName
+		if saName == "" {
+			saName = "default"
+		}
+		status := StatusHealthy
+		if len(in.serviceAccounts) > 0 && !serviceAccounts[workload.namespace+"/"+saName] {
+			status = StatusError
+		}
+		sa := b.node("ServiceAccount", workload.namespace, saName, status)
+		b.edge(workload.id, sa.ID, EdgeRunsAs, "")
+	}
+
+	// StatefulSet claims come from volumeClaimTemplates rather than pod volumes
+	for _, sts := range in.statefulSets {
+		stsID := graphNodeID("StatefulSet", sts.Namespace, sts.Name)
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			prefix := template.Name + "-"
+			for _, pvc := range in.pvcs {
+				if pvc.Namespace != sts.Namespace || !strings.HasPrefix(pvc.Name, prefix) {
+					continue
+				}
+				if statefulSetPodOrdinal(sts.Name, strings.TrimPrefix(pvc.Name, prefix)) < 0 {
+					continue
+				}
+				b.edge(stsID, b.claimNode(pvcs, pvc.Namespace, pvc.Name).ID, EdgeClaims, template.Name)
+			}
+		}
+	}
+}
+
+// claimNode adds a PVC node with a status derived from its phase
+func (b *graphBuilder) claimNode(pvcs map[string]*corev1.PersistentVolumeClaim, namespace, name string) *GraphNode {
+	pvc, ok := pvcs[namespace+"/"+name]
+	if !ok {
+		node := b.node("PersistentVolumeClaim", namespace, name, StatusError)
+		node.Details["missing"] = true
+		return node
+	}
+
+	status := StatusUnknown
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+		status = StatusHealthy
+	case corev1.ClaimPending:
+		status = StatusWarning
+	case corev1.ClaimLost:
+		status = StatusError
+	}
+	node := b.node("PersistentVolumeClaim", namespace, name, status)
+	node.Details["phase"] = pvc.Status.Phase
+	node.Details["volumeName"] = pvc.Spec.VolumeName
+	if storage, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		node.Details["capacity"] = storage.String()
+	}
+	return node
+}
+
+func (b *graphBuilder) addServices(in *graphInputs) {
+	for _, svc := range in.services {
+		node := b.node("Service", svc.Namespace, svc.Name, StatusHealthy)
+		node.Labels = svc.Labels
+		node.Details["type"] = svc.Spec.Type
+		node.Details["clusterIP"] = svc.Spec.ClusterIP
+		ports := make([]string, 0, len(svc.Spec.Ports))
+		for _, port := range svc.Spec.Ports {
+			ports = append(ports, fmt.Sprintf("%d/%s", port.Port, port.Protocol))
+		}
+		node.Details["ports"] = ports
+
+		if len(svc.Spec.Selector) == 0 {
+			continue // Manually managed endpoints or ExternalName
+		}
+
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		ready := 0
+		for _, pod := range in.pods {
+			if pod.Namespace != svc.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			b.edge(node.ID, graphNodeID("Pod", pod.Namespace, pod.Name), EdgeSelects, "")
+			if determinePodStatus(pod) == StatusHealthy {
+				ready++
+			}
+		}
+		node.Details["readyPods"] = ready
+		if ready == 0 {
+			node.Status = StatusWarning
+		}
+	}
+}
+
+// serviceBackend links a route node to a Service, flagging missing services
+func (b *graphBuilder) serviceBackend(route *GraphNode, namespace, name, label string) {
+	id := graphNodeID("Service", namespace, name)
+	if _, ok := b.nodes[id]; !ok {
+		missing := b.node("Service", namespace, name, StatusError)
+		missing.Details["missing"] = true
+		route.Status = worstStatus(route.Status, StatusWarning)
+	}
+	b.edge(route.ID, id, EdgeRoutes, label)
+}
+
+func (b *graphBuilder) addIngresses(in *graphInputs) {
+	for i := range in.ingresses {
+		ing := &in.ingresses[i]
+		node := b.node("Ingress", ing.Namespace, ing.Name, StatusHealthy)
+		node.Labels = ing.Labels
+		if ing.Spec.IngressClassName != nil {
+			node.Details["ingressClass"] = *ing.Spec.IngressClassName
+		}
+
+		hosts := make(map[string]bool)
+		if backend := ing.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+			b.serviceBackend(node, ing.Namespace, backend.Service.Name, "default -> "+ingressPortLabel(backend.Service.Port))
+		}
+		for _, rule := range ing.Spec.Rules {
+			host := rule.Host
+			if host == "" {
+				host = "*"
+			}
+			hosts[host] = true
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service == nil {
+					continue
+				}
+				label := host + path.Path + " -> " + ingressPortLabel(path.Backend.Service.Port)
+				b.serviceBackend(node, ing.Namespace, path.Backend.Service.Name, label)
+			}
+		}
+		node.Details["hosts"] = sortedKeys(hosts)
+	}
+}
+
+// ingressPortLabel formats an Ingress backend port
+func ingressPortLabel(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprintf("%d", port.Number)
+}
+
+func (b *graphBuilder) addGatewayRoutes(in *graphInputs) {
+	for i := range in.gateways {
+		gateway := &in.gateways[i]
+		node := b.node("Gateway", gateway.GetNamespace(), gateway.GetName(), unstructuredConditionStatus(gateway, "Programmed"))
+		node.Labels = gateway.GetLabels()
+		className, _, _ := unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+		node.Details["gatewayClass"] = className
+	}
+
+	for i := range in.routes {
+		route := &in.routes[i]
+		node := b.node(route.GetKind(), route.GetNamespace(), route.GetName(), StatusHealthy)
+		node.Labels = route.GetLabels()
+		hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+		node.Details["hostnames"] = hostnames
+
+		parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+		for _, parent := range parents {
+			ref, ok := parent.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind, _, _ := unstructured.NestedString(ref, "kind")
+			if kind != "" && kind != "Gateway" {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(ref, "name")
+			namespace, _, _ := unstructured.NestedString(ref, "namespace")
+			if namespace == "" {
+				namespace = route.GetNamespace()
+			}
+			b.edge(graphNodeID("Gateway", namespace, name), node.ID, EdgeParent, "")
+		}
+
+		rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+		for _, rule := range rules {
+			ruleMap, ok := rule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			backends, _, _ := unstructured.NestedSlice(ruleMap, "backendRefs")
+			for _, backend := range backends {
+				ref, ok := backend.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				kind, _, _ := unstructured.NestedString(ref, "kind")
+				if kind != "" && kind != "Service" {
+					continue
+				}
+				name, _, _ := unstructured.NestedString(ref, "name")
+				namespace, _, _ := unstructured.NestedString(ref, "namespace")
+				if namespace == "" {
+					namespace = route.GetNamespace()
+				}
+				port, _, _ := unstructured.NestedInt64(ref, "port")
+				b.serviceBackend(node, namespace, name, fmt.Sprintf("%d", port))
+			}
+		}
+	}
+}
+
+// unstructuredConditionStatus maps a status condition of a custom resource to K8sStatus
+func unstructuredConditionStatus(object *unstructured.Unstructured, conditionType string) K8sStatus {
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if t, _, _ := unstructured.NestedString(conditionMap, "type"); t != conditionType {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(conditionMap, "status")
+		switch metav1.ConditionStatus(status) {
+		case metav1.ConditionTrue:
+			return StatusHealthy
+		case metav1.ConditionFalse:
+			return StatusError
+		}
+	}
+	return StatusUnknown
+}
+
+func (b *graphBuilder) addAutoscalers(in *graphInputs) {
+	for i := range in.hpas {
+		hpa := &in.hpas[i]
+		node := b.node("HorizontalPodAutoscaler", hpa.Namespace, hpa.Name, hpaStatus(hpa))
+		node.Details["minReplicas"] = hpa.Spec.MinReplicas
+		node.Details["maxReplicas"] = hpa.Spec.MaxReplicas
+		node.Details["currentReplicas"] = hpa.Status.CurrentReplicas
+		node.Details["desiredReplicas"] = hpa.Status.DesiredReplicas
+
+		target := graphNodeID(hpa.Spec.ScaleTargetRef.Kind, hpa.Namespace, hpa.Spec.ScaleTargetRef.Name)
+		if _, ok := b.nodes[target]; !ok {
+			node.Status = StatusError
+			node.Details["targetMissing"] = true
+			continue
+		}
+		b.roots[node.ID] = b.roots[target]
+		b.edge(node.ID, target, EdgeScales, "")
+	}
+}
+
+// hpaStatus reports an autoscaler pinned at its limits or unable to scale
+func hpaStatus(hpa *autoscalingv2.HorizontalPodAutoscaler) K8sStatus {
+	status := StatusHealthy
+	for _, condition := range hpa.Status.Conditions {
+		switch {
+		case condition.Type == autoscalingv2.AbleToScale && condition.Status == corev1.ConditionFalse:
+			return StatusError
+		case condition.Type == autoscalingv2.ScalingActive && condition.Status == corev1.ConditionFalse:
+			status = StatusWarning
+		case condition.Type == autoscalingv2.ScalingLimited && condition.Status == corev1.ConditionTrue:
+			status = StatusWarning
+		}
+	}
+	if hpa.Status.CurrentReplicas >= hpa.Spec.MaxReplicas {
+		status = StatusWarning
+	}
+	return status
+}
+
+func (b *graphBuilder) addDisruptionBudgets(in *graphInputs) {
+	for i := range in.pdbs {
+		pdb := &in.pdbs[i]
+		node := b.node("PodDisruptionBudget", pdb.Namespace, pdb.Name, pdbStatus(pdb))
+		node.Details["disruptionsAllowed"] = pdb.Status.DisruptionsAllowed
+		node.Details["currentHealthy"] = pdb.Status.CurrentHealthy
+		node.Details["desiredHealthy"] = pdb.Status.DesiredHealthy
+
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		for _, workload := range b.workloads {
+			if workload.namespace != pdb.Namespace || !selector.Matches(labels.Set(workload.labels)) {
+				continue
+			}
+			b.roots[node.ID] = workload.id
+			b.edge(node.ID, workload.id, EdgeProtects, "")
+		}
+	}
+}
+
+// pdbStatus reports a budget that currently blocks every voluntary disruption
+func pdbStatus(pdb *policyv1.PodDisruptionBudget) K8sStatus {
+	if pdb.Status.DisruptionsAllowed == 0 && pdb.Status.ExpectedPods > 0 {
+		return StatusWarning
+	}
+	return StatusHealthy
+}
+
+// addRBAC links ServiceAccounts already in the graph to the bindings that grant them roles
+func (b *graphBuilder) addRBAC(in *graphInputs) {
+	bind := func(bindingKind, namespace, name string, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) {
+		var bindingID string
+		for _, subject := range subjects {
+			if subject.Kind != rbacv1.ServiceAccountKind {
+				continue
+			}
+			subjectNamespace := subject.Namespace
+			if subjectNamespace == "" {
+				subjectNamespace = namespace
+			}
+			saID := graphNodeID("ServiceAccount", subjectNamespace, subject.Name)
+			if _, ok := b.nodes[saID]; !ok {
+				continue
+			}
+			if bindingID == "" {
+				binding := b.node(bindingKind, namespace, name, StatusHealthy)
+				bindingID = binding.ID
+				roleNamespace := namespace
+				if roleRef.Kind == "ClusterRole" {
+					roleNamespace = ""
+				}
+				role := b.node(roleRef.Kind, roleNamespace, roleRef.Name, StatusHealthy)
+				b.edge(bindingID, role.ID, EdgeGrants, "")
+			}
+			b.edge(bindingID, saID, EdgeBinds, "")
+		}
+	}
+
+	for _, rb := range in.roleBindings {
+		bind("RoleBinding", rb.Namespace, rb.Name, rb.RoleRef, rb.Subjects)
+	}
+	for _, crb := range in.clusterRoleBindings {
+		bind("ClusterRoleBinding", "", crb.Name, crb.RoleRef, crb.Subjects)
+	}
+}
+
+// replicaSetStatus rolls up ReplicaSet readiness like getDeploymentStatus
+func replicaSetStatus(rs *appsv1.ReplicaSet) K8sStatus {
+	desired := int32(1)
+	if rs.Spec.Replicas != nil {
+		desired = *rs.Spec.Replicas
+	}
+	switch {
+	case rs.Status.ReadyReplicas == desired:
+		return StatusHealthy
+	case rs.Status.ReadyReplicas == 0:
+		return StatusError
+	default:
+		return StatusWarning
+	}
+}
+
+// filter keeps the workloads matching opts and everything connected to them. Nodes that
+// belong to another workload (its pods, ReplicaSets, Jobs) are never pulled in through
+// shared resources such as a common ConfigMap or Service.
+func (b *graphBuilder) filter(opts GraphOptions, selector labels.Selector) {
+	kinds := make(map[string]bool)
+	for _, kind := range opts.Kinds {
+		kinds[strings.ToLower(kind)] = true
+	}
+	statuses := make(map[K8sStatus]bool)
+	for _, status := range opts.Statuses {
+		statuses[status] = true
+	}
+	search := strings.ToLower(opts.Search)
+
+	filtering := len(kinds) > 0 || len(statuses) > 0 || !selector.Empty() || search != ""
+	if filtering {
+		keep := make(map[string]bool)
+		var queue []string
+		for _, id := range b.nodeOrder {
+			node := b.nodes[id]
+			if b.roots[id] != id || !graphWorkloadKinds[node.Kind] {
+				continue
+			}
+			if len(kinds) > 0 && !kinds[strings.ToLower(node.Kind)] {
+				continue
+			}
+			if len(statuses) > 0 && !statuses[node.Status] {
+				continue
+			}
+			if !selector.Matches(labels.Set(node.Labels)) {
+				continue
+			}
+			if search != "" && !strings.Contains(strings.ToLower(node.Name), search) {
+				continue
+			}
+			keep[id] = true
+			queue = append(queue, id)
+		}
+
+		adjacent := make(map[string][]string)
+		for _, id := range b.edgeOrder {
+			edge := b.edges[id]
+			adjacent[edge.Source] = append(adjacent[edge.Source], edge.Target)
+			adjacent[edge.Target] = append(adjacent[edge.Target], edge.Source)
+		}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, next := range adjacent[id] {
+				if keep[next] {
+					continue
+				}
+				if root, owned := b.roots[next]; owned && !keep[root] {
+					continue
+				}
+				keep[next] = true
+				queue = append(queue, next)
+			}
+		}
+		b.retain(func(node *GraphNode) bool { return keep[node.ID] })
+	}
+
+	if len(opts.HideKinds) > 0 {
+		hidden := make(map[string]bool)
+		for _, kind := range opts.HideKinds {
+			hidden[strings.ToLower(kind)] = true
+		}
+		b.retain(func(node *GraphNode) bool { return !hidden[strings.ToLower(node.Kind)] })
+	}
+}
+
+// retain drops nodes rejected by keep along with their edges
+func (b *graphBuilder) retain(keep func(node *GraphNode) bool) {
+	nodeOrder := b.nodeOrder[:0]
+	for _, id := range b.nodeOrder {
+		if keep(b.nodes[id]) {
+			nodeOrder = append(nodeOrder, id)
+			continue
+		}
+		delete(b.nodes, id)
+	}
+	b.nodeOrder = nodeOrder
+
+	edgeOrder := b.edgeOrder[:0]
+	for _, id := range b.edgeOrder {
+		edge := b.edges[id]
+		if _, ok := b.nodes[edge.Source]; !ok {
+			delete(b.edges, id)
+			continue
+		}
+		if _, ok := b.nodes[edge.Target]; !ok {
+			delete(b.edges, id)
+			continue
+		}
+		edgeOrder = append(edgeOrder, id)
+	}
+	b.edgeOrder = edgeOrder
+}
+
+// collapsePods folds the pods of any owner with more than threshold pods into one
+// PodGroup node, re-pointing their edges at the group
+func (b *graphBuilder) collapsePods(threshold int) {
+	owned := make(map[string][]string)
+	var owners []string
+	for _, id := range b.edgeOrder {
+		edge := b.edges[id]
+		if edge.Type != EdgeOwns || b.nodes[edge.Target].Kind != "Pod" {
+			continue
+		}
+		if _, ok := owned[edge.Source]; !ok {
+			owners = append(owners, edge.Source)
+		}
+		owned[edge.Source] = append(owned[edge.Source], edge.Target)
+	}
+
+	replaced := make(map[string]string)
+	for _, ownerID := range owners {
+		pods := owned[ownerID]
+		if len(pods) <= threshold {
+			continue
+		}
+		owner := b.nodes[ownerID]
+		group := b.node("PodGroup", owner.Namespace, owner.Kind+"-"+owner.Name, StatusHealthy)
+		group.Collapsed = &CollapsedPods{Owner: ownerID, StatusCounts: make(map[K8sStatus]int)}
+		b.roots[group.ID] = b.roots[ownerID]
+		for _, podID := range pods {
+			pod := b.nodes[podID]
+			group.Status = worstStatus(group.Status, pod.Status)
+			group.Collapsed.Count++
+			group.Collapsed.StatusCounts[pod.Status]++
+			group.Collapsed.Pods = append(group.Collapsed.Pods, pod.Name)
+			replaced[podID] = group.ID
+		}
+		sort.Strings(group.Collapsed.Pods)
+		b.collapsed += len(pods)
+	}
+	if len(replaced) == 0 {
+		return
+	}
+
+	edgeOrder := b.edgeOrder
+	edges := b.edges
+	b.edgeOrder = nil
+	b.edges = make(map[string]GraphEdge)
+	for _, id := range edgeOrder {
+		edge := edges[id]
+		if group, ok := replaced[edge.Source]; ok {
+			edge.Source = group
+		}
+		if group, ok := replaced[edge.Target]; ok {
+			edge.Target = group
+		}
+		b.edge(edge.Source, edge.Target, edge.Type, edge.Label)
+	}
+	b.retain(func(node *GraphNode) bool {
+		_, folded := replaced[node.ID]
+		return !folded
+	})
+}
+
+// graph returns the assembled graph with its summary
+func (b *graphBuilder) graph() *TopologyGraph {
+	graph := &TopologyGraph{
+		Nodes:    make([]GraphNode, 0, len(b.nodeOrder)),
+		Edges:    make([]GraphEdge, 0, len(b.edgeOrder)),
+		Warnings: b.warnings,
+		Summary: GraphSummary{
+			ByKind:          make(map[string]int),
+			ByStatus:        make(map[K8sStatus]int),
+			NamespaceStatus: make(map[string]K8sStatus),
+			CollapsedPods:   b.collapsed,
+		},
+	}
+
+	for _, id := range b.nodeOrder {
+		node := b.nodes[id]
+		if len(node.Details) == 0 {
+			node.Details = nil
+		}
+		graph.Nodes = append(graph.Nodes, *node)
+		graph.Summary.ByKind[node.Kind]++
+		graph.Summary.ByStatus[node.Status]++
+
+		// Namespace health rolls up from its workloads
+		if b.roots[id] == id && graphWorkloadKinds[node.Kind] {
+			current, ok := graph.Summary.NamespaceStatus[node.Namespace]
+			if !ok {
+				current = StatusHealthy
+			}
+			graph.Summary.NamespaceStatus[node.Namespace] = worstStatus(current, node.Status)
+		}
+	}
+	for _, id := range b.edgeOrder {
+		graph.Edges = append(graph.Edges, b.edges[id])
+	}
+
+	graph.Summary.Nodes = len(graph.Nodes)
+	graph.Summary.Edges = len(graph.Edges)
+	return graph
+}
@@ -0,0 +1,76 @@
+package topology
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// GetGraph handles GET /api/v1/topology/graph
+// Query params: context (required), namespaces or namespace (comma-separated, empty for all),
+// kinds, status, labelSelector, search, hide (comma-separated), collapse (pods per owner, 0 disables)
+func (h *Handler) GetGraph(c *gin.Context) {
+	context := c.Query("context")
+	if context == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context is required"})
+		return
+	}
+
+	opts := GraphOptions{
+		Namespaces:        splitQueryList(c.Query("namespaces")),
+		Kinds:             splitQueryList(c.Query("kinds")),
+		LabelSelector:     c.Query("labelSelector"),
+		Search:            c.Query("search"),
+		HideKinds:         splitQueryList(c.Query("hide")),
+		CollapseThreshold: DefaultCollapseThreshold,
+	}
+	if len(opts.Namespaces) == 0 {
+		opts.Namespaces = splitQueryList(c.Query("namespace"))
+	}
+	for _, status := range splitQueryList(c.Query("status")) {
+		opts.Statuses = append(opts.Statuses, K8sStatus(status))
+	}
+	if opts.LabelSelector != "" {
+		if _, err := labels.Parse(opts.LabelSelector); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid labelSelector: " + err.Error()})
+			return
+		}
+	}
+	if collapse := c.Query("collapse"); collapse != "" {
+		threshold, err := strconv.Atoi(collapse)
+		if err != nil || threshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "collapse must be a non-negative integer"})
+			return
+		}
+		opts.CollapseThreshold = threshold
+	}
+
+	clientset, err := h.getClusterClient(context)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := NewGraphService(clientset, h.dynamicClient(context), h.resourceCache(context))
+	graph, err := service.GetGraph(c.Request.Context(), opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// splitQueryList splits a comma-separated query value, dropping empty entries
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
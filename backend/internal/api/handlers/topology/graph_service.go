@@ -0,0 +1,492 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Graph edge types
+const (
+	EdgeOwns     = "owns"     // controller to the objects it creates
+	EdgeSelects  = "selects"  // Service to the pods behind it
+	EdgeRoutes   = "routes"   // Ingress or route to a Service
+	EdgeParent   = "parent"   // Gateway to the routes attached to it
+	EdgeUses     = "uses"     // workload to a ConfigMap or Secret
+	EdgeClaims   = "claims"   // workload to a PersistentVolumeClaim
+	EdgeRunsAs   = "runs-as"  // workload to its ServiceAccount
+	EdgeBinds    = "binds"    // RoleBinding to a ServiceAccount subject
+	EdgeGrants   = "grants"   // RoleBinding to the Role it grants
+	EdgeScales   = "scales"   // HorizontalPodAutoscaler to its target
+	EdgeProtects = "protects" // PodDisruptionBudget to the workload it covers
+)
+
+// DefaultCollapseThreshold is the number of pods per owner above which they are folded
+const DefaultCollapseThreshold = 10
+
+// gatewayGroupVersion is the Gateway API version routes are read from
+const gatewayGroupVersion = "gateway.networking.k8s.io/v1"
+
+// graphWorkloadKinds are the kinds that act as roots of the graph
+var graphWorkloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+	"Job":         true,
+	"CronJob":     true,
+	"Pod":         true, // Pods without a controller
+}
+
+// GraphService builds namespace-wide and cluster-wide topology graphs
+type GraphService struct {
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	resources *k8smanager.ResourceCache
+}
+
+// NewGraphService creates a graph service. dynamicClient is used for Gateway API
+// routes and may be nil; resources may be nil to read everything from the API.
+func NewGraphService(clientset kubernetes.Interface, dynamicClient dynamic.Interface, resources *k8smanager.ResourceCache) *GraphService {
+	return &GraphService{
+		clientset: clientset,
+		dynamic:   dynamicClient,
+		resources: resources,
+	}
+}
+
+// graphInputs holds the objects a graph is built from
+type graphInputs struct {
+	deployments         []*appsv1.Deployment
+	replicaSets         []*appsv1.ReplicaSet
+	statefulSets        []*appsv1.StatefulSet
+	daemonSets          []*appsv1.DaemonSet
+	jobs                []*batchv1.Job
+	cronJobs            []*batchv1.CronJob
+	pods                []*corev1.Pod
+	services            []*corev1.Service
+	configMaps          []*corev1.ConfigMap
+	pvcs                []*corev1.PersistentVolumeClaim
+	ingresses           []networkingv1.Ingress
+	hpas                []autoscalingv2.HorizontalPodAutoscaler
+	pdbs                []policyv1.PodDisruptionBudget
+	serviceAccounts     []corev1.ServiceAccount
+	roleBindings        []rbacv1.RoleBinding
+	clusterRoleBindings []rbacv1.ClusterRoleBinding
+	gateways            []unstructured.Unstructured
+	routes              []unstructured.Unstructured
+
+	secretNames  map[string]bool // namespace/name
+	secretsKnown bool            // false when secrets could not be listed
+}
+
+// GetGraph returns every workload in the selected namespaces as nodes and edges
+func (s *GraphService) GetGraph(ctx context.Context, opts GraphOptions) (*TopologyGraph, error) {
+	selector := labels.Everything()
+	if opts.LabelSelector != "" {
+		parsed, err := labels.Parse(opts.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %w", err)
+		}
+		selector = parsed
+	}
+
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	inputs := &graphInputs{secretNames: make(map[string]bool), secretsKnown: true}
+	b := newGraphBuilder()
+	for _, namespace := range namespaces {
+		if err := s.load(ctx, namespace, inputs, b); err != nil {
+			return nil, err
+		}
+	}
+	s.loadClusterScoped(ctx, inputs, b)
+
+	b.build(inputs)
+	b.filter(opts, selector)
+	if opts.CollapseThreshold > 0 {
+		b.collapsePods(opts.CollapseThreshold)
+	}
+
+	graph := b.graph()
+	graph.Namespaces = opts.Namespaces
+	if graph.Namespaces == nil {
+		graph.Namespaces = []string{}
+	}
+	return graph, nil
+}
+
+// load lists the namespaced objects of one namespace ("" for all) into inputs.
+// Workloads and pods are required; the rest degrade to warnings.
+func (s *GraphService) load(ctx context.Context, namespace string, in *graphInputs, b *graphBuilder) error {
+	opts := metav1.ListOptions{}
+
+	deployments, err := listCachedOr(s.resources, "deployments", namespace, func() ([]*appsv1.Deployment, error) {
+		list, err := s.clientset.AppsV1().Deployments(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	in.deployments = append(in.deployments, deployments...)
+
+	replicaSets, err := listCachedOr(s.resources, "replicasets", namespace, func() ([]*appsv1.ReplicaSet, error) {
+		list, err := s.clientset.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	in.replicaSets = append(in.replicaSets, replicaSets...)
+
+	statefulSets, err := listCachedOr(s.resources, "statefulsets", namespace, func() ([]*appsv1.StatefulSet, error) {
+		list, err := s.clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	in.statefulSets = append(in.statefulSets, statefulSets...)
+
+	daemonSets, err := listCachedOr(s.resources, "daemonsets", namespace, func() ([]*appsv1.DaemonSet, error) {
+		list, err := s.clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	in.daemonSets = append(in.daemonSets, daemonSets...)
+
+	jobs, err := listCachedOr(s.resources, "jobs", namespace, func() ([]*batchv1.Job, error) {
+		list, err := s.clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	in.jobs = append(in.jobs, jobs...)
+
+	cronJobs, err := listCachedOr(s.resources, "cronjobs", namespace, func() ([]*batchv1.CronJob, error) {
+		list, err := s.clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+	in.cronJobs = append(in.cronJobs, cronJobs...)
+
+	pods, err := listCachedOr(s.resources, "pods", namespace, func() ([]*corev1.Pod, error) {
+		list, err := s.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	in.pods = append(in.pods, pods...)
+
+	services, err := listCachedOr(s.resources, "services", namespace, func() ([]*corev1.Service, error) {
+		list, err := s.clientset.CoreV1().Services(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+	in.services = append(in.services, services...)
+
+	configMaps, err := listCachedOr(s.resources, "configmaps", namespace, func() ([]*corev1.ConfigMap, error) {
+		list, err := s.clientset.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		b.warn("Failed to list configmaps: %v", err)
+	}
+	in.configMaps = append(in.configMaps, configMaps...)
+
+	pvcs, err := listCachedOr(s.resources, "persistentvolumeclaims", namespace, func() ([]*corev1.PersistentVolumeClaim, error) {
+		list, err := s.clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		b.warn("Failed to list persistentvolumeclaims: %v", err)
+	}
+	in.pvcs = append(in.pvcs, pvcs...)
+
+	if secrets, err := s.clientset.CoreV1().Secrets(namespace).List(ctx, opts); err != nil {
+		b.warn("Failed to list secrets: %v", err)
+		in.secretsKnown = false
+	} else {
+		for _, secret := range secrets.Items {
+			in.secretNames[secret.Namespace+"/"+secret.Name] = true
+		}
+	}
+
+	if ingresses, err := s.clientset.NetworkingV1().Ingresses(namespace).List(ctx, opts); err != nil {
+		b.warn("Failed to list ingresses: %v", err)
+	} else {
+		in.ingresses = append(in.ingresses, ingresses.Items...)
+	}
+
+	if hpas, err := s.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, opts); err != nil {
+		b.warn("Failed to list horizontalpodautoscalers: %v", err)
+	} else {
+		in.hpas = append(in.hpas, hpas.Items...)
+	}
+
+	if pdbs, err := s.clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, opts); err != nil {
+		b.warn("Failed to list poddisruptionbudgets: %v", err)
+	} else {
+		in.pdbs = append(in.pdbs, pdbs.Items...)
+	}
+
+	if serviceAccounts, err := s.clientset.CoreV1().ServiceAccounts(namespace).List(ctx, opts); err != nil {
+		b.warn("Failed to list serviceaccounts: %v", err)
+	} else {
+		in.serviceAccounts = append(in.serviceAccounts, serviceAccounts.Items...)
+	}
+
+	if roleBindings, err := s.clientset.RbacV1().RoleBindings(namespace).List(ctx, opts); err != nil {
+		b.warn("Failed to list rolebindings: %v", err)
+	} else {
+		in.roleBindings = append(in.roleBindings, roleBindings.Items...)
+	}
+
+	s.loadGatewayRoutes(ctx, namespace, in, b)
+	return nil
+}
+
+// loadClusterScoped lists ClusterRoleBindings, which can grant to any namespace's ServiceAccounts
+func (s *GraphService) loadClusterScoped(ctx context.Context, in *graphInputs, b *graphBuilder) {
+	clusterRoleBindings, err := s.clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		b.warn("Failed to list clusterrolebindings: %v", err)
+		return
+	}
+	in.clusterRoleBindings = clusterRoleBindings.Items
+}
+
+// loadGatewayRoutes lists Gateway API objects when the CRDs are installed
+func (s *GraphService) loadGatewayRoutes(ctx context.Context, namespace string, in *graphInputs, b *graphBuilder) {
+	if s.dynamic == nil {
+		return
+	}
+	served, err := s.clientset.Discovery().ServerResourcesForGroupVersion(gatewayGroupVersion)
+	if err != nil {
+		return // Gateway API not installed
+	}
+
+	for _, resource := range served.APIResources {
+		if strings.Contains(resource.Name, "/") {
+			continue
+		}
+		switch resource.Name {
+		case "gateways", "httproutes", "grpcroutes":
+		default:
+			continue
+		}
+
+		gvr := schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: resource.Name}
+		list, err := s.dynamic.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			b.warn("Failed to list %s: %v", resource.Name, err)
+			continue
+		}
+		if resource.Name == "gateways" {
+			in.gateways = append(in.gateways, list.Items...)
+		} else {
+			in.routes = append(in.routes, list.Items...)
+		}
+	}
+}
+
+// listCachedOr reads a resource from the informer cache, or calls list when the cache cannot serve it
+func listCachedOr[T runtime.Object](resources *k8smanager.ResourceCache, resource, namespace string, list func() ([]T, error)) ([]T, error) {
+	if items, ok := cachedList[T](resources, resource, namespace); ok {
+		return items, nil
+	}
+	return list()
+}
+
+// toPointers returns pointers to the items of a list
+func toPointers[T any](items []T) []*T {
+	result := make([]*T, 0, len(items))
+	for i := range items {
+		result = append(result, &items[i])
+	}
+	return result
+}
+
+// graphNodeID builds the stable ID of a node
+func graphNodeID(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// statusSeverity orders statuses so rollups keep the worst one
+func statusSeverity(status K8sStatus) int {
+	switch status {
+	case StatusError:
+		return 3
+	case StatusWarning:
+		return 2
+	case StatusUnknown:
+		return 1
+	}
+	return 0
+}
+
+// worstStatus returns the more severe of two statuses
+func worstStatus(a, b K8sStatus) K8sStatus {
+	if statusSeverity(b) > statusSeverity(a) {
+		return b
+	}
+	return a
+}
+
+// podTemplateRef is a ConfigMap, Secret or PVC referenced from a pod spec
+type podTemplateRef struct {
+	kind   string
+	name   string
+	usages []string
+}
+
+// podSpecRefs lists what a pod spec references, merging the ways each object is used
+func podSpecRefs(spec *corev1.PodSpec) []podTemplateRef {
+	var refs []podTemplateRef
+	index := make(map[string]int)
+	add := func(kind, name, usage string) {
+		if name == "" {
+			return
+		}
+		key := kind + "/" + name
+		if i, ok := index[key]; ok {
+			for _, existing := range refs[i].usages {
+				if existing == usage {
+					return
+				}
+			}
+			refs[i].usages = append(refs[i].usages, usage)
+			return
+		}
+		index[key] = len(refs)
+		refs = append(refs, podTemplateRef{kind: kind, name: name, usages: []string{usage}})
+	}
+
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			add("ConfigMap", volume.ConfigMap.Name, "volume")
+		case volume.Secret != nil:
+			add("Secret", volume.Secret.SecretName, "volume")
+		case volume.PersistentVolumeClaim != nil:
+			add("PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName, "volume")
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add("ConfigMap", source.ConfigMap.Name, "volume")
+				}
+				if source.Secret != nil {
+					add("Secret", source.Secret.Name, "volume")
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add("ConfigMap", envFrom.ConfigMapRef.Name, "envFrom")
+			}
+			if envFrom.SecretRef != nil {
+				add("Secret", envFrom.SecretRef.Name, "envFrom")
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				add("ConfigMap", env.ValueFrom.ConfigMapKeyRef.Name, "env")
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				add("Secret", env.ValueFrom.SecretKeyRef.Name, "env")
+			}
+		}
+	}
+
+	for _, pullSecret := range spec.ImagePullSecrets {
+		add("Secret", pullSecret.Name, "imagePullSecret")
+	}
+	return refs
+}
+
+// controllerOf returns the controlling owner reference of an object, if any
+func controllerOf(owners []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range owners {
+		if owners[i].Controller != nil && *owners[i].Controller {
+			return &owners[i]
+		}
+	}
+	if len(owners) > 0 {
+		return &owners[0]
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
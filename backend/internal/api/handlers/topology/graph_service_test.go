@@ -0,0 +1,179 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func graphTestObjects() []runtime.Object {
+	replicas := int32(2)
+	controller := true
+	apiLabels := map[string]string{"app": "api"}
+
+	objects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", Labels: apiLabels},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: apiLabels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: apiLabels},
+					Spec: corev1.PodSpec{
+						ServiceAccountName: "api",
+						Volumes: []corev1.Volume{{
+							Name:         "config",
+							VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "api-config"}}},
+						}},
+						Containers: []corev1.Container{{
+							Name: "api",
+							Env: []corev1.EnvVar{{
+								Name:      "TOKEN",
+								ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "api-token"}, Key: "token"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 2, AvailableReplicas: 2, UpdatedReplicas: 2, Replicas: 2},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "api-7d9f", Namespace: "shop",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "api", Controller: &controller}},
+			},
+			Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+			Status: appsv1.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 2},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "shop"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{
+						Name:         "config",
+						VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "api-config"}}},
+					}},
+				}},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec:       corev1.ServiceSpec{Selector: apiLabels, Ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}}},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "shop"},
+			Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{
+				Host: "shop.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{
+					{Path: "/api", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "api", Port: networkingv1.ServiceBackendPort{Number: 80}}}},
+					{Path: "/legacy", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "legacy", Port: networkingv1.ServiceBackendPort{Name: "http"}}}},
+				}}},
+			}}},
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "api-config", Namespace: "shop"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "shop"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-reader", Namespace: "shop"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "api"}},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("api-7d9f-%d", i), Namespace: "shop", Labels: apiLabels,
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "api-7d9f", Controller: &controller}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		})
+	}
+	return objects
+}
+
+// edgeSet lists "source type target" for every edge of a graph
+func edgeSet(graph *TopologyGraph) []string {
+	edges := make([]string, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		edges = append(edges, edge.Source+" "+edge.Type+" "+edge.Target)
+	}
+	return edges
+}
+
+func findGraphNode(graph *TopologyGraph, id string) *GraphNode {
+	for i := range graph.Nodes {
+		if graph.Nodes[i].ID == id {
+			return &graph.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func TestGetGraph(t *testing.T) {
+	service := NewGraphService(fake.NewSimpleClientset(graphTestObjects()...), nil, nil)
+
+	graph, err := service.GetGraph(context.Background(), GraphOptions{Namespaces: []string{"shop"}})
+	require.NoError(t, err)
+
+	edges := edgeSet(graph)
+	assert.Contains(t, edges, "Deployment/shop/api owns ReplicaSet/shop/api-7d9f")
+	assert.Contains(t, edges, "ReplicaSet/shop/api-7d9f owns Pod/shop/api-7d9f-0")
+	assert.Contains(t, edges, "Service/shop/api selects Pod/shop/api-7d9f-1")
+	assert.Contains(t, edges, "Ingress/shop/shop routes Service/shop/api")
+	assert.Contains(t, edges, "Deployment/shop/api uses ConfigMap/shop/api-config")
+	assert.Contains(t, edges, "Deployment/shop/api uses Secret/shop/api-token")
+	assert.Contains(t, edges, "Deployment/shop/api runs-as ServiceAccount/shop/api")
+	assert.Contains(t, edges, "RoleBinding/shop/api-reader binds ServiceAccount/shop/api")
+	assert.Contains(t, edges, "RoleBinding/shop/api-reader grants Role/shop/reader")
+
+	// References to objects that do not exist are flagged
+	secret := findGraphNode(graph, "Secret/shop/api-token")
+	require.NotNil(t, secret)
+	assert.Equal(t, StatusError, secret.Status)
+	legacy := findGraphNode(graph, "Service/shop/legacy")
+	require.NotNil(t, legacy)
+	assert.Equal(t, true, legacy.Details["missing"])
+	assert.Equal(t, StatusWarning, findGraphNode(graph, "Ingress/shop/shop").Status)
+
+	assert.Equal(t, StatusError, graph.Summary.NamespaceStatus["shop"])
+	assert.Equal(t, 2, graph.Summary.ByKind["Deployment"])
+}
+
+func TestGetGraphFilters(t *testing.T) {
+	service := NewGraphService(fake.NewSimpleClientset(graphTestObjects()...), nil, nil)
+
+	// The shared ConfigMap must not pull in the other deployment
+	graph, err := service.GetGraph(context.Background(), GraphOptions{Search: "api", HideKinds: []string{"Secret"}})
+	require.NoError(t, err)
+	assert.NotNil(t, findGraphNode(graph, "Deployment/shop/api"))
+	assert.NotNil(t, findGraphNode(graph, "ConfigMap/shop/api-config"))
+	assert.Nil(t, findGraphNode(graph, "Deployment/shop/worker"))
+	assert.Nil(t, findGraphNode(graph, "Secret/shop/api-token"))
+
+	graph, err = service.GetGraph(context.Background(), GraphOptions{LabelSelector: "app=api", CollapseThreshold: 1})
+	require.NoError(t, err)
+	group := findGraphNode(graph, "PodGroup/shop/ReplicaSet-api-7d9f")
+	require.NotNil(t, group)
+	assert.Equal(t, 2, group.Collapsed.Count)
+	assert.Nil(t, findGraphNode(graph, "Pod/shop/api-7d9f-0"))
+	assert.Contains(t, edgeSet(graph), "Service/shop/api selects PodGroup/shop/ReplicaSet-api-7d9f")
+	assert.Equal(t, 2, graph.Summary.CollapsedPods)
+
+	_, err = service.GetGraph(context.Background(), GraphOptions{LabelSelector: "app in ("})
+	assert.Error(t, err)
+}
@@ -2,31 +2,48 @@ package topology
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 // Handler handles topology-related HTTP requests
 type Handler struct {
 	manager *kubernetes.ClusterManager
+
+	metricsProviders map[string]cachedMetricsProvider
+	metricsMu        sync.Mutex
+
+	snapshots *SnapshotRecorder
 }
 
 // NewHandler creates a new topology handler
 func NewHandler(clientset k8s.Interface) *Handler {
-	return &Handler{
-		manager: nil, // Will be set via NewHandlerWithManager
+	h := &Handler{
+		manager:          nil, // Will be set via NewHandlerWithManager
+		metricsProviders: make(map[string]cachedMetricsProvider),
 	}
+	h.snapshots = NewSnapshotRecorder(h, os.Getenv(SnapshotDirEnv))
+	return h
 }
 
 // NewHandlerWithManager creates a new topology handler with cluster manager
 func NewHandlerWithManager(manager *kubernetes.ClusterManager) *Handler {
-	return &Handler{
-		manager: manager,
+	h := &Handler{
+		manager:          manager,
+		metricsProviders: make(map[string]cachedMetricsProvider),
 	}
+	h.snapshots = NewSnapshotRecorder(h, os.Getenv(SnapshotDirEnv))
+	return h
 }
 
 // getClusterClient returns the client for a specific cluster context
@@ -47,6 +64,73 @@ func (h *Handler) getClusterClient(context string) (k8s.Interface, error) {
 	return conn.ClientSet, nil
 }
 
+// dynamicClient returns a dynamic client for a cluster context, or nil when one cannot be built
+func (h *Handler) dynamicClient(context string) dynamic.Interface {
+	if h.manager == nil {
+		return nil
+	}
+	conn, err := h.manager.GetConnection(context)
+	if err != nil || conn.Config == nil {
+		return nil
+	}
+	client, err := dynamic.NewForConfig(conn.Config)
+	if err != nil {
+		log.Printf("Failed to create dynamic client for %s: %v", context, err)
+		return nil
+	}
+	return client
+}
+
+// cachedMetricsProvider is a metrics provider together with the connection config it was
+// built from; a reconnect replaces the config, which retires the provider
+type cachedMetricsProvider struct {
+	config   *rest.Config
+	provider MetricsProvider
+}
+
+// MetricsProvider returns the cached metrics.k8s.io provider for a cluster context,
+// or nil when the cluster is not connected
+func (h *Handler) MetricsProvider(context string) MetricsProvider {
+	h.metricsMu.Lock()
+	defer h.metricsMu.Unlock()
+	
+	if h.manager == nil {
+		return nil
+	}
+	
+	conn, err := h.manager.GetConnection(context)
+	if err != nil || conn.Config == nil {
+		// Disconnected; a later connection gets a fresh provider
+		delete(h.metricsProviders, context)
+		return nil
+	}
+	if cached, ok := h.metricsProviders[context]; ok && cached.config == conn.Config {
+		return cached.provider
+	}
+	
+	provider, err := NewMetricsServerProvider(conn.Config, conn.ClientSet.Discovery())
+	if err != nil {
+		delete(h.metricsProviders, context)
+		log.Printf("Failed to create metrics provider for %s: %v", context, err)
+		return nil
+	}
+	h.metricsProviders[context] = cachedMetricsProvider{config: conn.Config, provider: provider}
+	return provider
+}
+
+// resourceCache returns the shared informer cache for a cluster context, or nil
+// when it cannot be created and lists should go to the API
+func (h *Handler) resourceCache(context string) *kubernetes.ResourceCache {
+	if h.manager == nil {
+		return nil
+	}
+	resources, err := h.manager.GetResourceCache(context)
+	if err != nil {
+		return nil
+	}
+	return resources
+}
+
 // GetDeploymentTopology handles GET /api/topology/:context/deployment/:namespace/:name
 func (h *Handler) GetDeploymentTopology(c *gin.Context) {
 	context := c.Query("context")
@@ -68,7 +152,7 @@ func (h *Handler) GetDeploymentTopology(c *gin.Context) {
 		return
 	}
 	
-	service := NewService(clientset)
+	service := NewServiceWithMetrics(clientset, h.MetricsProvider(context)).withDynamicClient(h.dynamicClient(context))
 	topology, err := service.GetDeploymentTopology(c.Request.Context(), namespace, deploymentName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -101,7 +185,7 @@ func (h *Handler) ListDeployments(c *gin.Context) {
 		return
 	}
 	
-	service := NewService(clientset)
+	service := NewServiceWithCache(clientset, h.MetricsProvider(context), h.resourceCache(context))
 	deployments, err := service.ListDeployments(c.Request.Context(), namespace)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -134,6 +218,17 @@ func (h *Handler) ListNamespaces(c *gin.Context) {
 		return
 	}
 	
+	var namespaceList []string
+	if cached, ok := cachedList[*corev1.Namespace](h.resourceCache(context), "namespaces", ""); ok {
+		for _, ns := range cached {
+			namespaceList = append(namespaceList, ns.Name)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"namespaces": namespaceList,
+		})
+		return
+	}
+	
 	namespaces, err := clientset.CoreV1().Namespaces().List(c.Request.Context(), metav1.ListOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -142,7 +237,6 @@ func (h *Handler) ListNamespaces(c *gin.Context) {
 		return
 	}
 	
-	var namespaceList []string
 	for _, ns := range namespaces.Items {
 		namespaceList = append(namespaceList, ns.Name)
 	}
@@ -173,7 +267,7 @@ func (h *Handler) GetDaemonSetTopology(c *gin.Context) {
 		return
 	}
 	
-	service := NewService(clientset)
+	service := NewServiceWithMetrics(clientset, h.MetricsProvider(context)).withDynamicClient(h.dynamicClient(context))
 	topology, err := service.GetDaemonSetTopology(c.Request.Context(), namespace, daemonsetName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -206,7 +300,7 @@ func (h *Handler) ListDaemonSets(c *gin.Context) {
 		return
 	}
 	
-	service := NewService(clientset)
+	service := NewServiceWithCache(clientset, h.MetricsProvider(context), h.resourceCache(context))
 	daemonsets, err := service.ListDaemonSets(c.Request.Context(), namespace)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -272,4 +366,72 @@ func (h *Handler) ListJobs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"jobs": jobs,
 	})
-}
\ No newline at end of file
+}
+
+// GetStatefulSetTopology handles GET /api/topology/statefulset
+func (h *Handler) GetStatefulSetTopology(c *gin.Context) {
+	context := c.Query("context")
+	namespace := c.Query("namespace")
+	statefulsetName := c.Query("name")
+	
+	if context == "" || namespace == "" || statefulsetName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "context, namespace and statefulset name are required",
+		})
+		return
+	}
+	
+	clientset, err := h.getClusterClient(context)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	
+	service := NewServiceWithMetrics(clientset, h.MetricsProvider(context)).withDynamicClient(h.dynamicClient(context))
+	topology, err := service.GetStatefulSetTopology(c.Request.Context(), namespace, statefulsetName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, topology)
+}
+
+// ListStatefulSets handles GET /api/topology/statefulsets/list
+// Query params: namespace (optional)
+func (h *Handler) ListStatefulSets(c *gin.Context) {
+	context := c.Query("context")
+	namespace := c.Query("namespace")
+	
+	if context == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "context is required",
+		})
+		return
+	}
+	
+	clientset, err := h.getClusterClient(context)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	
+	service := NewServiceWithCache(clientset, h.MetricsProvider(context), h.resourceCache(context))
+	statefulsets, err := service.ListStatefulSets(c.Request.Context(), namespace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"statefulsets": statefulsets.StatefulSets,
+	})
+}
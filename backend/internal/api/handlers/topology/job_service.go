@@ -304,23 +304,31 @@ func (s *JobService) ListJobs(ctx context.Context, contextName, namespace string
 		return nil, fmt.Errorf("failed to get cluster connection: %w", err)
 	}
 
-	var listOptions metav1.ListOptions
-	var jobList *batchv1.JobList
-
-	if namespace != "" {
-		// List jobs in specific namespace
-		jobList, err = conn.ClientSet.BatchV1().Jobs(namespace).List(ctx, listOptions)
-	} else {
-		// List jobs in all namespaces
-		jobList, err = conn.ClientSet.BatchV1().Jobs("").List(ctx, listOptions)
-	}
+	// Prefer the shared informer cache, falling back to the API when it is unavailable
+	resources, _ := s.clusterManager.GetResourceCache(contextName)
+	jobs, ok := cachedList[*batchv1.Job](resources, "jobs", namespace)
+	if !ok {
+		var listOptions metav1.ListOptions
+		var jobList *batchv1.JobList
+
+		if namespace != "" {
+			// List jobs in specific namespace
+			jobList, err = conn.ClientSet.BatchV1().Jobs(namespace).List(ctx, listOptions)
+		} else {
+			// List jobs in all namespaces
+			jobList, err = conn.ClientSet.BatchV1().Jobs("").List(ctx, listOptions)
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %w", err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for i := range jobList.Items {
+			jobs = append(jobs, &jobList.Items[i])
+		}
 	}
 
-	summaries := make([]JobSummary, 0, len(jobList.Items))
-	for _, job := range jobList.Items {
+	summaries := make([]JobSummary, 0, len(jobs))
+	for _, job := range jobs {
 		summary := JobSummary{
 			Name:           job.Name,
 			Namespace:      job.Namespace,
@@ -0,0 +1,240 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// metricsGroupVersion is the API served by metrics-server
+const metricsGroupVersion = "metrics.k8s.io/v1beta1"
+
+// metricsAvailabilityTTL controls how often availability is re-checked, so a
+// metrics-server installed after startup is picked up
+const metricsAvailabilityTTL = time.Minute
+
+// ResourceUsage is raw CPU and memory usage
+type ResourceUsage struct {
+	CPUMillicores int64
+	MemoryBytes   int64
+}
+
+// PodUsage is the usage of every container in a pod at a point in time
+type PodUsage struct {
+	Timestamp  time.Time
+	Window     time.Duration
+	Containers map[string]ResourceUsage
+}
+
+// MetricsProvider supplies live pod usage
+type MetricsProvider interface {
+	// Available reports whether usage can currently be fetched
+	Available(ctx context.Context) bool
+	// PodMetrics returns usage keyed by podUsageKey for a namespace ("" for all)
+	PodMetrics(ctx context.Context, namespace string) (map[string]PodUsage, error)
+}
+
+// MetricsServerProvider reads usage from the metrics.k8s.io API
+type MetricsServerProvider struct {
+	client    metricsclient.Interface
+	discovery discovery.DiscoveryInterface
+
+	mu        sync.Mutex
+	available bool
+	checkedAt time.Time
+}
+
+// NewMetricsServerProvider creates a provider for the cluster behind config
+func NewMetricsServerProvider(config *rest.Config, discovery discovery.DiscoveryInterface) (*MetricsServerProvider, error) {
+	client, err := metricsclient.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics client: %w", err)
+	}
+	return &MetricsServerProvider{
+		client:    client,
+		discovery: discovery,
+	}, nil
+}
+
+// Available checks discovery for the metrics API, caching the answer
+func (p *MetricsServerProvider) Available(ctx context.Context) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.checkedAt.IsZero() && time.Since(p.checkedAt) < metricsAvailabilityTTL {
+		return p.available
+	}
+
+	_, err := p.discovery.ServerResourcesForGroupVersion(metricsGroupVersion)
+	p.available = err == nil
+	p.checkedAt = time.Now()
+	return p.available
+}
+
+// PodMetrics lists PodMetrics for a namespace
+func (p *MetricsServerProvider) PodMetrics(ctx context.Context, namespace string) (map[string]PodUsage, error) {
+	list, err := p.client.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// Force a re-check next time; the API may have gone away
+		p.mu.Lock()
+		p.checkedAt = time.Time{}
+		p.mu.Unlock()
+		return nil, fmt.Errorf("failed to list pod metrics: %w", err)
+	}
+
+	usage := make(map[string]PodUsage, len(list.Items))
+	for _, item := range list.Items {
+		podUsage := PodUsage{
+			Timestamp:  item.Timestamp.Time,
+			Window:     item.Window.Duration,
+			Containers: make(map[string]ResourceUsage, len(item.Containers)),
+		}
+		for _, container := range item.Containers {
+			podUsage.Containers[container.Name] = ResourceUsage{
+				CPUMillicores: container.Usage.Cpu().MilliValue(),
+				MemoryBytes:   container.Usage.Memory().Value(),
+			}
+		}
+		usage[podUsageKey(item.Namespace, item.Name)] = podUsage
+	}
+
+	return usage, nil
+}
+
+// buildContainerUsage combines usage with the container's requests and limits
+func buildContainerUsage(container corev1.Container, usage ResourceUsage, timestamp time.Time) *ContainerUsage {
+	result := &ContainerUsage{
+		CPU:           resource.NewMilliQuantity(usage.CPUMillicores, resource.DecimalSI).String(),
+		Memory:        resource.NewQuantity(usage.MemoryBytes, resource.BinarySI).String(),
+		CPUMillicores: usage.CPUMillicores,
+		MemoryBytes:   usage.MemoryBytes,
+	}
+	if !timestamp.IsZero() {
+		result.Timestamp = &timestamp
+	}
+
+	if cpu, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+		result.CPURequestPercent = usagePercent(usage.CPUMillicores, cpu.MilliValue())
+	}
+	if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+		result.CPULimitPercent = usagePercent(usage.CPUMillicores, cpu.MilliValue())
+	}
+	if mem, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+		result.MemoryRequestPercent = usagePercent(usage.MemoryBytes, mem.Value())
+	}
+	if mem, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+		result.MemoryLimitPercent = usagePercent(usage.MemoryBytes, mem.Value())
+	}
+
+	return result
+}
+
+// usagePercent returns used/total as a percentage rounded to one decimal, or nil without a total
+func usagePercent(used, total int64) *float64 {
+	if total <= 0 {
+		return nil
+	}
+	percent := math.Round(float64(used)/float64(total)*1000) / 10
+	return &percent
+}
+
+// podUsageKey keys PodMetrics results by namespace/name, since pod names repeat across namespaces
+func podUsageKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// attachPodUsage fills ContainerRef.Usage for a pod built by buildPodRef
+func attachPodUsage(pod *corev1.Pod, ref *PodRef, usage map[string]PodUsage) {
+	podUsage, ok := usage[podUsageKey(pod.Namespace, pod.Name)]
+	if !ok {
+		return
+	}
+
+	for i := range ref.Containers {
+		containerUsage, ok := podUsage.Containers[ref.Containers[i].Name]
+		if !ok {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if container.Name == ref.Containers[i].Name {
+				ref.Containers[i].Usage = buildContainerUsage(container, containerUsage, podUsage.Timestamp)
+				break
+			}
+		}
+	}
+}
+
+// FakeMetricsProvider is an in-memory MetricsProvider for tests and local development
+type FakeMetricsProvider struct {
+	mu        sync.RWMutex
+	available bool
+	usage     map[string]map[string]PodUsage // namespace -> pod -> usage
+}
+
+// NewFakeMetricsProvider creates an available fake provider with no usage
+func NewFakeMetricsProvider() *FakeMetricsProvider {
+	return &FakeMetricsProvider{
+		available: true,
+		usage:     make(map[string]map[string]PodUsage),
+	}
+}
+
+// SetAvailable toggles whether the fake reports the metrics API as available
+func (f *FakeMetricsProvider) SetAvailable(available bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.available = available
+}
+
+// SetContainerUsage records usage for one container
+func (f *FakeMetricsProvider) SetContainerUsage(namespace, pod, container string, cpuMillicores, memoryBytes int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.usage[namespace] == nil {
+		f.usage[namespace] = make(map[string]PodUsage)
+	}
+	podUsage, ok := f.usage[namespace][pod]
+	if !ok {
+		podUsage = PodUsage{Timestamp: time.Now(), Window: 30 * time.Second, Containers: make(map[string]ResourceUsage)}
+	}
+	podUsage.Containers[container] = ResourceUsage{CPUMillicores: cpuMillicores, MemoryBytes: memoryBytes}
+	f.usage[namespace][pod] = podUsage
+}
+
+// Available implements MetricsProvider
+func (f *FakeMetricsProvider) Available(ctx context.Context) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.available
+}
+
+// PodMetrics implements MetricsProvider
+func (f *FakeMetricsProvider) PodMetrics(ctx context.Context, namespace string) (map[string]PodUsage, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.available {
+		return nil, fmt.Errorf("metrics API not available")
+	}
+
+	result := make(map[string]PodUsage)
+	for ns, pods := range f.usage {
+		if namespace != "" && ns != namespace {
+			continue
+		}
+		for name, usage := range pods {
+			result[podUsageKey(ns, name)] = usage
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package topology
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestDaemonSetTopologyUsage verifies usage and request/limit percentages reach ContainerRef
+func TestDaemonSetTopologyUsage(t *testing.T) {
+	isController := true
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "ops", UID: types.UID("ds-uid")}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "agent-x1",
+			Namespace:       "ops",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent", UID: ds.UID, Controller: &isController}},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "agent",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("100Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			},
+		}}},
+	}
+
+	metrics := NewFakeMetricsProvider()
+	metrics.SetContainerUsage("ops", "agent-x1", "agent", 50, 50*1024*1024)
+	// A pod of the same name elsewhere must not shadow the DaemonSet's pod
+	metrics.SetContainerUsage("staging", "agent-x1", "agent", 900, 1024)
+
+	service := NewServiceWithMetrics(fake.NewSimpleClientset(ds, pod), metrics)
+	topology, err := service.GetDaemonSetTopology(context.Background(), "ops", "agent")
+	require.NoError(t, err)
+	require.Len(t, topology.Pods, 1)
+
+	usage := topology.Pods[0].Containers[0].Usage
+	require.NotNil(t, usage)
+	assert.Equal(t, "50m", usage.CPU)
+	assert.Equal(t, 25.0, *usage.CPURequestPercent)
+	assert.Equal(t, 5.0, *usage.CPULimitPercent)
+	assert.Equal(t, 50.0, *usage.MemoryRequestPercent)
+	assert.Nil(t, usage.MemoryLimitPercent)
+
+	// Unavailable metrics degrade to no usage rather than an error
+	metrics.SetAvailable(false)
+	topology, err = service.GetDaemonSetTopology(context.Background(), "ops", "agent")
+	require.NoError(t, err)
+	assert.Nil(t, topology.Pods[0].Containers[0].Usage)
+}
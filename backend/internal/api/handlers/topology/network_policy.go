@@ -0,0 +1,372 @@
+package topology
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// netEndpoint is an endpoint as seen by the policy evaluator
+type netEndpoint struct {
+	NetworkEndpoint
+	ports []corev1.ContainerPort // Declared container ports, used to resolve named ports
+}
+
+// external reports whether the endpoint is outside the cluster
+func (e *netEndpoint) external() bool {
+	return e.Kind == "External"
+}
+
+// trafficPort is the port a connection is made to; nil means any port
+type trafficPort struct {
+	protocol corev1.Protocol
+	number   int32
+	name     string // Named container port, resolved against the destination
+}
+
+// String formats a port as protocol/port
+func (p *trafficPort) String() string {
+	if p == nil {
+		return "all"
+	}
+	if p.number == 0 {
+		return string(p.protocol) + "/" + p.name
+	}
+	return fmt.Sprintf("%s/%d", p.protocol, p.number)
+}
+
+// parseTrafficPort parses a port number or name; an empty port means any port
+func parseTrafficPort(port, protocol string) *trafficPort {
+	if port == "" {
+		return nil
+	}
+	result := &trafficPort{protocol: corev1.Protocol(protocol)}
+	if result.protocol == "" {
+		result.protocol = corev1.ProtocolTCP
+	}
+	if number, err := strconv.Atoi(port); err == nil {
+		result.number = int32(number)
+	} else {
+		result.name = port
+	}
+	return result
+}
+
+// policyAnalyzer evaluates NetworkPolicies the way the networking.k8s.io/v1 API defines them
+type policyAnalyzer struct {
+	policies        []networkingv1.NetworkPolicy
+	namespaceLabels map[string]map[string]string
+}
+
+// policyAppliesTo reports whether a policy governs the given direction. Without
+// policyTypes a policy always covers ingress, and egress only when it has egress rules.
+func policyAppliesTo(policy *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return policyType == networkingv1.PolicyTypeIngress || len(policy.Spec.Egress) > 0
+	}
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// selecting returns the policies that isolate an endpoint for a direction
+func (a *policyAnalyzer) selecting(endpoint *netEndpoint, policyType networkingv1.PolicyType) []*networkingv1.NetworkPolicy {
+	if endpoint.external() {
+		return nil
+	}
+	var result []*networkingv1.NetworkPolicy
+	for i := range a.policies {
+		policy := &a.policies[i]
+		if policy.Namespace != endpoint.Namespace || !policyAppliesTo(policy, policyType) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil || !selector.Matches(labels.Set(endpoint.Labels)) {
+			continue
+		}
+		result = append(result, policy)
+	}
+	return result
+}
+
+// peersMatch reports whether an endpoint is one of a rule's peers; no peers means everyone
+func (a *policyAnalyzer) peersMatch(peers []networkingv1.NetworkPolicyPeer, policyNamespace string, endpoint *netEndpoint) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if a.peerMatches(peer, policyNamespace, endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *policyAnalyzer) peerMatches(peer networkingv1.NetworkPolicyPeer, policyNamespace string, endpoint *netEndpoint) bool {
+	if peer.IPBlock != nil {
+		// Pod IPs are matched too; whether ipBlock applies to in-cluster traffic is implementation-specific
+		return endpoint.IP != "" && ipBlockContains(peer.IPBlock, endpoint.IP)
+	}
+	if endpoint.external() {
+		return false
+	}
+
+	if peer.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil || !selector.Matches(labels.Set(a.namespaceLabels[endpoint.Namespace])) {
+			return false
+		}
+	} else if endpoint.Namespace != policyNamespace {
+		return false
+	}
+
+	if peer.PodSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+		if err != nil || !selector.Matches(labels.Set(endpoint.Labels)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ipBlockContains reports whether ip is inside the block and not in one of its exceptions
+func ipBlockContains(block *networkingv1.IPBlock, ip string) bool {
+	address := net.ParseIP(ip)
+	if address == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil || !cidr.Contains(address) {
+		return false
+	}
+	for _, except := range block.Except {
+		if _, excluded, err := net.ParseCIDR(except); err == nil && excluded.Contains(address) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePort returns the numeric port of a named container port on the destination
+func resolvePort(destination *netEndpoint, name string, protocol corev1.Protocol) int32 {
+	for _, port := range destination.ports {
+		portProtocol := port.Protocol
+		if portProtocol == "" {
+			portProtocol = corev1.ProtocolTCP
+		}
+		if port.Name == name && portProtocol == protocol {
+			return port.ContainerPort
+		}
+	}
+	return 0
+}
+
+// portsMatch reports whether a rule's ports admit the traffic; no ports means all ports
+func portsMatch(ports []networkingv1.NetworkPolicyPort, port *trafficPort, destination *netEndpoint) bool {
+	if len(ports) == 0 || port == nil {
+		return true
+	}
+
+	number := port.number
+	if number == 0 {
+		number = resolvePort(destination, port.name, port.protocol)
+	}
+	for _, rulePort := range ports {
+		protocol := corev1.ProtocolTCP
+		if rulePort.Protocol != nil {
+			protocol = *rulePort.Protocol
+		}
+		if protocol != port.protocol {
+			continue
+		}
+		if rulePort.Port == nil {
+			return true
+		}
+		if rulePort.Port.StrVal != "" {
+			if rulePort.Port.StrVal == port.name || (number != 0 && resolvePort(destination, rulePort.Port.StrVal, protocol) == number) {
+				return true
+			}
+			continue
+		}
+		start := rulePort.Port.IntVal
+		end := start
+		if rulePort.EndPort != nil {
+			end = *rulePort.EndPort
+		}
+		if number >= start && number <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// ingressVerdict evaluates the policies selecting the destination
+func (a *policyAnalyzer) ingressVerdict(source, destination *netEndpoint, port *trafficPort) PolicyVerdict {
+	policies := a.selecting(destination, networkingv1.PolicyTypeIngress)
+	if len(policies) == 0 {
+		return PolicyVerdict{Allowed: true, Reason: "no NetworkPolicy selects the destination for ingress"}
+	}
+
+	verdict := PolicyVerdict{Isolated: true}
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Ingress {
+			if a.peersMatch(rule.From, policy.Namespace, source) && portsMatch(rule.Ports, port, destination) {
+				verdict.Policies = append(verdict.Policies, policy.Name)
+				break
+			}
+		}
+	}
+	verdict.Allowed = len(verdict.Policies) > 0
+	verdict.Reason = isolationReason(verdict, "ingress", policies)
+	return verdict
+}
+
+// egressVerdict evaluates the policies selecting the source
+func (a *policyAnalyzer) egressVerdict(source, destination *netEndpoint, port *trafficPort) PolicyVerdict {
+	policies := a.selecting(source, networkingv1.PolicyTypeEgress)
+	if len(policies) == 0 {
+		return PolicyVerdict{Allowed: true, Reason: "no NetworkPolicy selects the source for egress"}
+	}
+
+	verdict := PolicyVerdict{Isolated: true}
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Egress {
+			if a.peersMatch(rule.To, policy.Namespace, destination) && portsMatch(rule.Ports, port, destination) {
+				verdict.Policies = append(verdict.Policies, policy.Name)
+				break
+			}
+		}
+	}
+	verdict.Allowed = len(verdict.Policies) > 0
+	verdict.Reason = isolationReason(verdict, "egress", policies)
+	return verdict
+}
+
+func isolationReason(verdict PolicyVerdict, direction string, policies []*networkingv1.NetworkPolicy) string {
+	if verdict.Allowed {
+		return fmt.Sprintf("%s allowed by %v", direction, verdict.Policies)
+	}
+	names := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		names = append(names, policy.Name)
+	}
+	return fmt.Sprintf("%s isolated by %v and no rule allows this traffic", direction, names)
+}
+
+// check evaluates a full connection: egress from the source and ingress to the destination
+func (a *policyAnalyzer) check(source, destination *netEndpoint, port *trafficPort) ReachabilityCheck {
+	result := ReachabilityCheck{
+		Source:      source.NetworkEndpoint,
+		Destination: destination.NetworkEndpoint,
+		Egress:      a.egressVerdict(source, destination, port),
+		Ingress:     a.ingressVerdict(source, destination, port),
+	}
+	if port != nil {
+		result.Protocol = string(port.protocol)
+		result.Port = strconv.Itoa(int(port.number))
+		if port.number == 0 {
+			result.Port = port.name
+		}
+	}
+	result.Allowed = result.Egress.Allowed && result.Ingress.Allowed
+	return result
+}
+
+// destinationPorts lists the ports to evaluate against a destination: its declared
+// container ports, or any port when it declares none
+func destinationPorts(destination *netEndpoint) []*trafficPort {
+	var ports []*trafficPort
+	for _, port := range destination.ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		ports = append(ports, &trafficPort{protocol: protocol, number: port.ContainerPort, name: port.Name})
+	}
+	if len(ports) == 0 {
+		ports = append(ports, nil)
+	}
+	return ports
+}
+
+// allowedPorts returns the destination ports a source may connect to
+func (a *policyAnalyzer) allowedPorts(source, destination *netEndpoint) []string {
+	seen := make(map[string]bool)
+	var allowed []string
+	for _, port := range destinationPorts(destination) {
+		if !a.check(source, destination, port).Allowed {
+			continue
+		}
+		if key := port.String(); !seen[key] {
+			seen[key] = true
+			allowed = append(allowed, key)
+		}
+	}
+	return allowed
+}
+
+// rulesFor describes the rules of the policies selecting an endpoint for a direction
+func rulesFor(policies []*networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) ([]string, []NetworkPolicyRule) {
+	var names []string
+	var rules []NetworkPolicyRule
+	for _, policy := range policies {
+		names = append(names, policy.Name)
+		if policyType == networkingv1.PolicyTypeIngress {
+			for _, rule := range policy.Spec.Ingress {
+				rules = append(rules, describeRule(policy, rule.From, rule.Ports))
+			}
+		} else {
+			for _, rule := range policy.Spec.Egress {
+				rules = append(rules, describeRule(policy, rule.To, rule.Ports))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, rules
+}
+
+func describeRule(policy *networkingv1.NetworkPolicy, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) NetworkPolicyRule {
+	rule := NetworkPolicyRule{Policy: policy.Name, Peers: []PolicyPeer{}}
+	if len(peers) == 0 {
+		rule.Peers = append(rule.Peers, PolicyPeer{Type: "all"})
+	}
+	for _, peer := range peers {
+		switch {
+		case peer.IPBlock != nil:
+			rule.Peers = append(rule.Peers, PolicyPeer{Type: "ipBlock", CIDR: peer.IPBlock.CIDR, Except: peer.IPBlock.Except})
+		case peer.NamespaceSelector != nil && peer.PodSelector != nil:
+			rule.Peers = append(rule.Peers, PolicyPeer{
+				Type:              "namespacePods",
+				NamespaceSelector: metav1.FormatLabelSelector(peer.NamespaceSelector),
+				PodSelector:       metav1.FormatLabelSelector(peer.PodSelector),
+			})
+		case peer.NamespaceSelector != nil:
+			rule.Peers = append(rule.Peers, PolicyPeer{Type: "namespaces", NamespaceSelector: metav1.FormatLabelSelector(peer.NamespaceSelector)})
+		case peer.PodSelector != nil:
+			rule.Peers = append(rule.Peers, PolicyPeer{Type: "pods", Namespace: policy.Namespace, PodSelector: metav1.FormatLabelSelector(peer.PodSelector)})
+		}
+	}
+	for _, port := range ports {
+		described := PolicyPort{Protocol: string(corev1.ProtocolTCP)}
+		if port.Protocol != nil {
+			described.Protocol = string(*port.Protocol)
+		}
+		if port.Port != nil {
+			described.Port = port.Port.String()
+		}
+		if port.EndPort != nil {
+			described.EndPort = *port.EndPort
+		}
+		rule.Ports = append(rule.Ports, described)
+	}
+	return rule
+}
@@ -0,0 +1,99 @@
+package topology
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNetworkReachability handles GET /api/v1/topology/network/reachability
+// Query params: context, namespace, kind and name of the workload or pod (all required)
+func (h *Handler) GetNetworkReachability(c *gin.Context) {
+	context := c.Query("context")
+	namespace := c.Query("namespace")
+	kind := c.Query("kind")
+	name := c.Query("name")
+
+	if context == "" || namespace == "" || kind == "" || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context, namespace, kind and name are required"})
+		return
+	}
+
+	clientset, err := h.getClusterClient(context)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := NewNetworkPolicyService(clientset, h.resourceCache(context))
+	reachability, err := service.GetReachability(c.Request.Context(), namespace, kind, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reachability)
+}
+
+// CheckNetworkReachability handles GET /api/v1/topology/network/check
+// Query params: context, source and destination (namespace/Kind/name or an IP), port and protocol (optional)
+func (h *Handler) CheckNetworkReachability(c *gin.Context) {
+	context := c.Query("context")
+	source := c.Query("source")
+	destination := c.Query("destination")
+
+	if context == "" || source == "" || destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context, source and destination are required"})
+		return
+	}
+	protocol := strings.ToUpper(c.DefaultQuery("protocol", "TCP"))
+	if protocol != "TCP" && protocol != "UDP" && protocol != "SCTP" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "protocol must be TCP, UDP or SCTP"})
+		return
+	}
+
+	clientset, err := h.getClusterClient(context)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := NewNetworkPolicyService(clientset, h.resourceCache(context))
+	result, err := service.Check(c.Request.Context(), source, destination, c.Query("port"), protocol)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetNetworkOverlay handles GET /api/v1/topology/network/overlay
+// Query params: context (required), namespaces or namespace (comma-separated, empty for all)
+func (h *Handler) GetNetworkOverlay(c *gin.Context) {
+	context := c.Query("context")
+	if context == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context is required"})
+		return
+	}
+	namespaces := splitQueryList(c.Query("namespaces"))
+	if len(namespaces) == 0 {
+		namespaces = splitQueryList(c.Query("namespace"))
+	}
+
+	clientset, err := h.getClusterClient(context)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := NewNetworkPolicyService(clientset, h.resourceCache(context))
+	overlay, err := service.GetOverlay(c.Request.Context(), namespaces)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, overlay)
+}
@@ -0,0 +1,390 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NetworkPolicyService analyzes which workloads NetworkPolicies let talk to each other
+type NetworkPolicyService struct {
+	clientset kubernetes.Interface
+	resources *k8smanager.ResourceCache
+}
+
+// NewNetworkPolicyService creates a NetworkPolicy analyzer; resources may be nil
+func NewNetworkPolicyService(clientset kubernetes.Interface, resources *k8smanager.ResourceCache) *NetworkPolicyService {
+	return &NetworkPolicyService{
+		clientset: clientset,
+		resources: resources,
+	}
+}
+
+// analyzer loads every NetworkPolicy and namespace label set in the cluster
+func (s *NetworkPolicyService) analyzer(ctx context.Context) (*policyAnalyzer, error) {
+	policies, err := s.clientset.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies: %w", err)
+	}
+
+	namespaces, err := listCachedOr(s.resources, "namespaces", metav1.NamespaceAll, func() ([]*corev1.Namespace, error) {
+		list, err := s.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	analyzer := &policyAnalyzer{
+		policies:        policies.Items,
+		namespaceLabels: make(map[string]map[string]string, len(namespaces)),
+	}
+	for _, ns := range namespaces {
+		analyzer.namespaceLabels[ns.Name] = ns.Labels
+	}
+	return analyzer, nil
+}
+
+// templateEndpoint builds an endpoint from a workload's pod template
+func templateEndpoint(kind, namespace, name string, template *corev1.PodTemplateSpec) *netEndpoint {
+	endpoint := &netEndpoint{NetworkEndpoint: NetworkEndpoint{
+		ID:        graphNodeID(kind, namespace, name),
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Labels:    template.Labels,
+	}}
+	for _, container := range template.Spec.Containers {
+		endpoint.ports = append(endpoint.ports, container.Ports...)
+	}
+	return endpoint
+}
+
+// podEndpoint builds an endpoint for a single pod, including its IP
+func podEndpoint(pod *corev1.Pod) *netEndpoint {
+	endpoint := templateEndpoint("Pod", pod.Namespace, pod.Name, &corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec})
+	endpoint.IP = pod.Status.PodIP
+	return endpoint
+}
+
+// endpoints lists the workloads of a namespace ("" for all) as policy endpoints.
+// Pods are represented by their controller, except pods without one.
+func (s *NetworkPolicyService) endpoints(ctx context.Context, namespace string) ([]*netEndpoint, error) {
+	opts := metav1.ListOptions{}
+	var endpoints []*netEndpoint
+
+	deployments, err := listCachedOr(s.resources, "deployments", namespace, func() ([]*appsv1.Deployment, error) {
+		list, err := s.clientset.AppsV1().Deployments(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments {
+		endpoints = append(endpoints, templateEndpoint("Deployment", d.Namespace, d.Name, &d.Spec.Template))
+	}
+
+	statefulSets, err := listCachedOr(s.resources, "statefulsets", namespace, func() ([]*appsv1.StatefulSet, error) {
+		list, err := s.clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, sts := range statefulSets {
+		endpoints = append(endpoints, templateEndpoint("StatefulSet", sts.Namespace, sts.Name, &sts.Spec.Template))
+	}
+
+	daemonSets, err := listCachedOr(s.resources, "daemonsets", namespace, func() ([]*appsv1.DaemonSet, error) {
+		list, err := s.clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for _, ds := range daemonSets {
+		endpoints = append(endpoints, templateEndpoint("DaemonSet", ds.Namespace, ds.Name, &ds.Spec.Template))
+	}
+
+	cronJobs, err := listCachedOr(s.resources, "cronjobs", namespace, func() ([]*batchv1.CronJob, error) {
+		list, err := s.clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+	for _, cj := range cronJobs {
+		endpoints = append(endpoints, templateEndpoint("CronJob", cj.Namespace, cj.Name, &cj.Spec.JobTemplate.Spec.Template))
+	}
+
+	jobs, err := listCachedOr(s.resources, "jobs", namespace, func() ([]*batchv1.Job, error) {
+		list, err := s.clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+			continue // Covered by the CronJob's template
+		}
+		endpoints = append(endpoints, templateEndpoint("Job", job.Namespace, job.Name, &job.Spec.Template))
+	}
+
+	pods, err := listCachedOr(s.resources, "pods", namespace, func() ([]*corev1.Pod, error) {
+		list, err := s.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return toPointers(list.Items), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, pod := range pods {
+		if metav1.GetControllerOf(pod) == nil {
+			endpoints = append(endpoints, podEndpoint(pod))
+		}
+	}
+
+	return endpoints, nil
+}
+
+// resolveEndpoint resolves "namespace/Kind/name" to a workload or pod, or a bare IP to an external endpoint
+func (s *NetworkPolicyService) resolveEndpoint(ctx context.Context, ref string) (*netEndpoint, error) {
+	if ip := net.ParseIP(ref); ip != nil {
+		return s.endpointForIP(ctx, ref)
+	}
+
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid endpoint %q: expected namespace/Kind/name or an IP address", ref)
+	}
+	return s.workloadEndpoint(ctx, parts[0], parts[1], parts[2])
+}
+
+// endpointForIP returns the pod with the IP, or an external endpoint when no pod has it
+func (s *NetworkPolicyService) endpointForIP(ctx context.Context, ip string) (*netEndpoint, error) {
+	pods, err := s.clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.podIP=" + ip,
+	})
+	if err == nil {
+		for i := range pods.Items {
+			if pods.Items[i].Status.PodIP == ip && !pods.Items[i].Spec.HostNetwork {
+				return podEndpoint(&pods.Items[i]), nil
+			}
+		}
+	}
+	return &netEndpoint{NetworkEndpoint: NetworkEndpoint{Kind: "External", IP: ip}}, nil
+}
+
+// workloadEndpoint looks up a workload or pod by kind
+func (s *NetworkPolicyService) workloadEndpoint(ctx context.Context, namespace, kind, name string) (*netEndpoint, error) {
+	opts := metav1.GetOptions{}
+	switch strings.ToLower(kind) {
+	case "deployment":
+		d, err := s.clientset.AppsV1().Deployments(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deployment: %w", err)
+		}
+		return templateEndpoint("Deployment", namespace, name, &d.Spec.Template), nil
+	case "statefulset":
+		sts, err := s.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get statefulset: %w", err)
+		}
+		return templateEndpoint("StatefulSet", namespace, name, &sts.Spec.Template), nil
+	case "daemonset":
+		ds, err := s.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daemonset: %w", err)
+		}
+		return templateEndpoint("DaemonSet", namespace, name, &ds.Spec.Template), nil
+	case "job":
+		job, err := s.clientset.BatchV1().Jobs(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get job: %w", err)
+		}
+		return templateEndpoint("Job", namespace, name, &job.Spec.Template), nil
+	case "cronjob":
+		cj, err := s.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cronjob: %w", err)
+		}
+		return templateEndpoint("CronJob", namespace, name, &cj.Spec.JobTemplate.Spec.Template), nil
+	case "pod":
+		pod, err := s.clientset.CoreV1().Pods(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod: %w", err)
+		}
+		return podEndpoint(pod), nil
+	}
+	return nil, fmt.Errorf("unsupported kind %q: expected Deployment, StatefulSet, DaemonSet, Job, CronJob or Pod", kind)
+}
+
+// GetReachability reports who can reach a workload and where it can connect to.
+// Peers are listed when a policy decides the traffic; when neither side is isolated
+// everything is allowed and the peer is implied rather than listed.
+func (s *NetworkPolicyService) GetReachability(ctx context.Context, namespace, kind, name string) (*WorkloadReachability, error) {
+	analyzer, err := s.analyzer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.workloadEndpoint(ctx, namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	peers, err := s.endpoints(ctx, metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+
+	ingressPolicies := analyzer.selecting(target, networkingv1.PolicyTypeIngress)
+	egressPolicies := analyzer.selecting(target, networkingv1.PolicyTypeEgress)
+	result := &WorkloadReachability{
+		Target:             target.NetworkEndpoint,
+		IngressIsolated:    len(ingressPolicies) > 0,
+		EgressIsolated:     len(egressPolicies) > 0,
+		IngressSources:     []ReachablePeer{},
+		EgressDestinations: []ReachablePeer{},
+	}
+	result.IngressPolicies, result.IngressRules = rulesFor(ingressPolicies, networkingv1.PolicyTypeIngress)
+	result.EgressPolicies, result.EgressRules = rulesFor(egressPolicies, networkingv1.PolicyTypeEgress)
+
+	for _, peer := range peers {
+		if peer.ID == target.ID {
+			continue
+		}
+		if result.IngressIsolated || len(analyzer.selecting(peer, networkingv1.PolicyTypeEgress)) > 0 {
+			if ports := analyzer.allowedPorts(peer, target); len(ports) > 0 {
+				result.IngressSources = append(result.IngressSources, ReachablePeer{NetworkEndpoint: peer.NetworkEndpoint, Ports: ports})
+			}
+		}
+		if result.EgressIsolated || len(analyzer.selecting(peer, networkingv1.PolicyTypeIngress)) > 0 {
+			if ports := analyzer.allowedPorts(target, peer); len(ports) > 0 {
+				result.EgressDestinations = append(result.EgressDestinations, ReachablePeer{NetworkEndpoint: peer.NetworkEndpoint, Ports: ports})
+			}
+		}
+	}
+	return result, nil
+}
+
+// Check evaluates whether source can connect to destination on a port. Endpoints are
+// "namespace/Kind/name" or IP addresses; an empty port checks whether any port is allowed.
+func (s *NetworkPolicyService) Check(ctx context.Context, source, destination, port, protocol string) (*ReachabilityCheck, error) {
+	analyzer, err := s.analyzer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	src, err := s.resolveEndpoint(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	dst, err := s.resolveEndpoint(ctx, destination)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+
+	result := analyzer.check(src, dst, parseTrafficPort(port, protocol))
+	return &result, nil
+}
+
+// GetOverlay returns NetworkPolicy isolation and allowed traffic between the workloads of
+// the given namespaces (all when empty), keyed by TopologyGraph node IDs
+func (s *NetworkPolicyService) GetOverlay(ctx context.Context, namespaces []string) (*NetworkOverlay, error) {
+	analyzer, err := s.analyzer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := namespaces
+	if len(scopes) == 0 {
+		scopes = []string{metav1.NamespaceAll}
+	}
+	var endpoints []*netEndpoint
+	for _, namespace := range scopes {
+		found, err := s.endpoints(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, found...)
+	}
+
+	overlay := &NetworkOverlay{
+		Namespaces: namespaces,
+		Nodes:      make([]NetworkOverlayNode, 0, len(endpoints)),
+		Edges:      []NetworkOverlayEdge{},
+		Policies:   len(analyzer.policies),
+	}
+	if overlay.Namespaces == nil {
+		overlay.Namespaces = []string{}
+	}
+
+	ingressIsolated := make(map[string]bool)
+	egressIsolated := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		ingress := analyzer.selecting(endpoint, networkingv1.PolicyTypeIngress)
+		egress := analyzer.selecting(endpoint, networkingv1.PolicyTypeEgress)
+		ingressIsolated[endpoint.ID] = len(ingress) > 0
+		egressIsolated[endpoint.ID] = len(egress) > 0
+
+		names := make(map[string]bool)
+		for _, policy := range append(ingress, egress...) {
+			names[policy.Name] = true
+		}
+		overlay.Nodes = append(overlay.Nodes, NetworkOverlayNode{
+			ID:              endpoint.ID,
+			IngressIsolated: ingressIsolated[endpoint.ID],
+			EgressIsolated:  egressIsolated[endpoint.ID],
+			Policies:        sortedKeys(names),
+		})
+	}
+
+	for _, source := range endpoints {
+		for _, destination := range endpoints {
+			if source.ID == destination.ID || (!egressIsolated[source.ID] && !ingressIsolated[destination.ID]) {
+				continue
+			}
+			ports := analyzer.allowedPorts(source, destination)
+			if len(ports) == 0 {
+				continue
+			}
+			overlay.Edges = append(overlay.Edges, NetworkOverlayEdge{
+				ID:     source.ID + "->" + destination.ID + ":network",
+				Source: source.ID,
+				Target: destination.ID,
+				Ports:  ports,
+			})
+		}
+	}
+	sort.Slice(overlay.Edges, func(i, j int) bool { return overlay.Edges[i].ID < overlay.Edges[j].ID })
+	return overlay, nil
+}
@@ -0,0 +1,149 @@
+package topology
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func policyTestDeployment(namespace, name string, ports ...corev1.ContainerPort) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: name, Ports: ports}}},
+		}},
+	}
+}
+
+func policyTestObjects() []runtime.Object {
+	tcp := corev1.ProtocolTCP
+	httpPort := intstr.FromString("http")
+	dnsPort := intstr.FromInt32(53)
+	udp := corev1.ProtocolUDP
+
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "shop"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "ops"}}},
+		policyTestDeployment("shop", "web"),
+		policyTestDeployment("shop", "api", corev1.ContainerPort{Name: "http", ContainerPort: 8080}, corev1.ContainerPort{Name: "debug", ContainerPort: 9090}),
+		policyTestDeployment("shop", "db", corev1.ContainerPort{Name: "pg", ContainerPort: 5432}),
+		policyTestDeployment("monitoring", "prometheus"),
+		// api accepts web on its named http port and anything from the ops namespaces
+		&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-ingress", Namespace: "shop"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}},
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &httpPort}},
+					},
+					{
+						From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}}}},
+					},
+				},
+			},
+		},
+		// db denies all ingress
+		&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-deny", Namespace: "shop"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+		// web may only egress to DNS and an external range
+		&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-egress", Namespace: "shop"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &dnsPort}}},
+					{To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "203.0.113.0/24", Except: []string{"203.0.113.128/25"}}}}},
+					{To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}}}},
+				},
+			},
+		},
+	}
+}
+
+func TestNetworkPolicyCheck(t *testing.T) {
+	service := NewNetworkPolicyService(fake.NewSimpleClientset(policyTestObjects()...), nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		port        string
+		protocol    string
+		allowed     bool
+	}{
+		{"named port allowed", "shop/Deployment/web", "shop/Deployment/api", "8080", "TCP", true},
+		{"port name allowed", "shop/Deployment/web", "shop/Deployment/api", "http", "TCP", true},
+		{"other port denied", "shop/Deployment/web", "shop/Deployment/api", "9090", "TCP", false},
+		{"namespace selector allows any port", "monitoring/Deployment/prometheus", "shop/Deployment/api", "9090", "TCP", true},
+		{"default deny", "shop/Deployment/api", "shop/Deployment/db", "5432", "TCP", false},
+		{"egress isolation", "shop/Deployment/web", "shop/Deployment/db", "5432", "TCP", false},
+		{"egress to dns", "shop/Deployment/web", "198.51.100.10", "53", "UDP", true},
+		{"egress ipBlock", "shop/Deployment/web", "203.0.113.10", "443", "TCP", true},
+		{"egress ipBlock except", "shop/Deployment/web", "203.0.113.200", "443", "TCP", false},
+		{"unisolated", "monitoring/Deployment/prometheus", "shop/Deployment/web", "", "TCP", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.Check(ctx, tt.source, tt.destination, tt.port, tt.protocol)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, result.Allowed, "egress: %s; ingress: %s", result.Egress.Reason, result.Ingress.Reason)
+		})
+	}
+
+	_, err := service.Check(ctx, "shop/web", "shop/Deployment/api", "", "TCP")
+	assert.Error(t, err)
+}
+
+func TestNetworkPolicyReachabilityAndOverlay(t *testing.T) {
+	service := NewNetworkPolicyService(fake.NewSimpleClientset(policyTestObjects()...), nil)
+	ctx := context.Background()
+
+	reachability, err := service.GetReachability(ctx, "shop", "Deployment", "api")
+	require.NoError(t, err)
+	assert.True(t, reachability.IngressIsolated)
+	assert.False(t, reachability.EgressIsolated)
+	assert.Equal(t, []string{"api-ingress"}, reachability.IngressPolicies)
+	require.Len(t, reachability.IngressRules, 2)
+	assert.Equal(t, "namespaces", reachability.IngressRules[1].Peers[0].Type)
+
+	sources := make(map[string][]string)
+	for _, peer := range reachability.IngressSources {
+		sources[peer.ID] = peer.Ports
+	}
+	assert.Equal(t, map[string][]string{
+		"Deployment/shop/web":              {"TCP/8080"},
+		"Deployment/monitoring/prometheus": {"TCP/8080", "TCP/9090"},
+	}, sources)
+
+	overlay, err := service.GetOverlay(ctx, []string{"shop"})
+	require.NoError(t, err)
+	assert.Len(t, overlay.Nodes, 3)
+	assert.Equal(t, 3, overlay.Policies)
+
+	edges := make(map[string][]string)
+	for _, edge := range overlay.Edges {
+		edges[edge.Source+" "+edge.Target] = edge.Ports
+	}
+	assert.Equal(t, map[string][]string{
+		"Deployment/shop/web Deployment/shop/api": {"TCP/8080"},
+	}, edges)
+}
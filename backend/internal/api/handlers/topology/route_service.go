@@ -0,0 +1,562 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// gatewayRouteResources are the Gateway API route kinds resolved for workloads
+var gatewayRouteResources = map[string]string{
+	"httproutes": "HTTPRoute",
+	"grpcroutes": "GRPCRoute",
+}
+
+// routeResolver looks up routes and the objects they reference, memoising lookups
+// so routes sharing services, secrets or gateways cost one API call each
+type routeResolver struct {
+	s         *Service
+	ctx       context.Context
+	namespace string
+	targets   map[string]bool // names of the workload's services
+
+	services map[string]lookupResult[corev1.Service] // namespace/name
+	secrets  map[string]bool
+	gateways map[string]lookupResult[unstructured.Unstructured]
+}
+
+// lookupResult memoises a Get: object is nil without an error when the object does not
+// exist, while err holds any other failure, such as an RBAC denial
+type lookupResult[T any] struct {
+	object *T
+	err    error
+}
+
+// getRoutesForServices returns the Ingresses and Gateway API routes that send traffic to
+// any of the named services. Lookup failures are logged and yield fewer routes, never an error.
+func (s *Service) getRoutesForServices(ctx context.Context, namespace string, serviceNames []string) []RouteRef {
+	if len(serviceNames) == 0 {
+		return nil
+	}
+
+	r := &routeResolver{
+		s:         s,
+		ctx:       ctx,
+		namespace: namespace,
+		targets:   make(map[string]bool),
+		services:  make(map[string]lookupResult[corev1.Service]),
+		secrets:   make(map[string]bool),
+		gateways:  make(map[string]lookupResult[unstructured.Unstructured]),
+	}
+	for _, name := range serviceNames {
+		r.targets[name] = true
+	}
+
+	var routes []RouteRef
+	ingresses, err := s.clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("Failed to list ingresses in %s: %v", namespace, err)
+	} else {
+		for i := range ingresses.Items {
+			if route, ok := r.ingressRoute(&ingresses.Items[i]); ok {
+				routes = append(routes, route)
+			}
+		}
+	}
+
+	routes = append(routes, r.gatewayRoutes()...)
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Kind != routes[j].Kind {
+			return routes[i].Kind < routes[j].Kind
+		}
+		if routes[i].Namespace != routes[j].Namespace {
+			return routes[i].Namespace < routes[j].Namespace
+		}
+		return routes[i].Name < routes[j].Name
+	})
+	return routes
+}
+
+// ingressRoute converts an Ingress, reporting ok=false when none of its backends are workload services
+func (r *routeResolver) ingressRoute(ing *networkingv1.Ingress) (RouteRef, bool) {
+	route := RouteRef{
+		Kind:      "Ingress",
+		Name:      ing.Name,
+		Namespace: ing.Namespace,
+		Status:    StatusHealthy,
+	}
+	if ing.Spec.IngressClassName != nil {
+		route.Class = *ing.Spec.IngressClassName
+	}
+
+	addBackend := func(hosts []string, path, pathType string, backend *networkingv1.IngressServiceBackend) {
+		port := ""
+		if backend.Port.Name != "" {
+			port = backend.Port.Name
+		} else if backend.Port.Number != 0 {
+			port = strconv.Itoa(int(backend.Port.Number))
+		}
+		route.Rules = append(route.Rules, RouteRule{
+			Hosts:    hosts,
+			Path:     path,
+			PathType: pathType,
+			Backend:  r.backend(ing.Namespace, backend.Name, port, nil),
+		})
+	}
+
+	if backend := ing.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+		addBackend(nil, "", "", backend.Service)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		var hosts []string
+		if rule.Host != "" {
+			hosts = []string{rule.Host}
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				continue
+			}
+			pathType := ""
+			if path.PathType != nil {
+				pathType = string(*path.PathType)
+			}
+			addBackend(hosts, path.Path, pathType, path.Backend.Service)
+		}
+	}
+
+	for _, tls := range ing.Spec.TLS {
+		ref := RouteTLS{Hosts: tls.Hosts, SecretName: tls.SecretName, Namespace: ing.Namespace}
+		// An empty secret name means the controller's default certificate
+		if tls.SecretName != "" && !r.secretExists(ing.Namespace, tls.SecretName) {
+			ref.Missing = true
+			route.Status = worstStatus(route.Status, StatusWarning)
+			route.Warnings = append(route.Warnings, fmt.Sprintf("TLS secret %s not found", tls.SecretName))
+		}
+		route.TLS = append(route.TLS, ref)
+	}
+
+	return route, r.finish(&route)
+}
+
+// gatewayRoutes resolves HTTPRoutes and GRPCRoutes when the Gateway API is installed
+func (r *routeResolver) gatewayRoutes() []RouteRef {
+	if r.s.dynamic == nil {
+		return nil
+	}
+	served, err := r.s.clientset.Discovery().ServerResourcesForGroupVersion(gatewayGroupVersion)
+	if err != nil {
+		return nil // Gateway API not installed
+	}
+
+	var routes []RouteRef
+	for _, resource := range served.APIResources {
+		kind, ok := gatewayRouteResources[resource.Name]
+		if !ok {
+			continue
+		}
+		gvr := schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: resource.Name}
+
+		// Routes may reference services across namespaces through a ReferenceGrant
+		list, err := r.s.dynamic.Resource(gvr).Namespace(metav1.NamespaceAll).List(r.ctx, metav1.ListOptions{})
+		if err != nil {
+			list, err = r.s.dynamic.Resource(gvr).Namespace(r.namespace).List(r.ctx, metav1.ListOptions{})
+		}
+		if err != nil {
+			log.Printf("Failed to list %s: %v", resource.Name, err)
+			continue
+		}
+		for i := range list.Items {
+			if route, ok := r.gatewayRoute(kind, &list.Items[i]); ok {
+				routes = append(routes, route)
+			}
+		}
+	}
+	return routes
+}
+
+// gatewayRoute converts an HTTPRoute or GRPCRoute, reporting ok=false when none of its
+// backends are workload services
+func (r *routeResolver) gatewayRoute(kind string, object *unstructured.Unstructured) (RouteRef, bool) {
+	route := RouteRef{
+		Kind:      kind,
+		Name:      object.GetName(),
+		Namespace: object.GetNamespace(),
+		Status:    StatusHealthy,
+	}
+	hosts, _, _ := unstructured.NestedStringSlice(object.Object, "spec", "hostnames")
+
+	rules, _, _ := unstructured.NestedSlice(object.Object, "spec", "rules")
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matches := routeMatches(kind, ruleMap)
+		backends, _, _ := unstructured.NestedSlice(ruleMap, "backendRefs")
+		for _, backend := range backends {
+			ref, ok := backend.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if group, _, _ := unstructured.NestedString(ref, "group"); group != "" {
+				continue
+			}
+			if refKind, _, _ := unstructured.NestedString(ref, "kind"); refKind != "" && refKind != "Service" {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(ref, "name")
+			namespace, _, _ := unstructured.NestedString(ref, "namespace")
+			if namespace == "" {
+				namespace = route.Namespace
+			}
+			port := ""
+			if number, found, _ := unstructured.NestedInt64(ref, "port"); found {
+				port = strconv.FormatInt(number, 10)
+			}
+			var weight *int64
+			if value, found, _ := unstructured.NestedInt64(ref, "weight"); found {
+				weight = &value
+			}
+
+			resolved := r.backend(namespace, name, port, weight)
+			if port == "" && resolved.Error == "" {
+				// Gateway API requires a port for Service backends
+				resolved.Status = StatusError
+				resolved.Error = "backend port is required"
+			}
+			for _, match := range matches {
+				route.Rules = append(route.Rules, RouteRule{
+					Hosts:    hosts,
+					Path:     match.path,
+					PathType: match.pathType,
+					Backend:  resolved,
+				})
+			}
+		}
+	}
+	if !r.targetsRoute(&route) {
+		return route, false
+	}
+
+	route.Gateways = r.parentGateways(&route, object)
+	for _, gateway := range route.Gateways {
+		if gateway.Missing {
+			route.Status = worstStatus(route.Status, StatusWarning)
+			route.Warnings = append(route.Warnings, fmt.Sprintf("parent gateway %s/%s not found", gateway.Namespace, gateway.Name))
+		} else if gateway.Error != "" {
+			route.Status = worstStatus(route.Status, StatusWarning)
+			route.Warnings = append(route.Warnings, gateway.Error)
+		}
+	}
+
+	// Conditions reported by the controllers of each parent
+	parents, _, _ := unstructured.NestedSlice(object.Object, "status", "parents")
+	for _, parent := range parents {
+		parentMap, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(parentMap, "conditions")
+		for _, condition := range conditions {
+			conditionMap, ok := condition.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			conditionType, _, _ := unstructured.NestedString(conditionMap, "type")
+			status, _, _ := unstructured.NestedString(conditionMap, "status")
+			message, _, _ := unstructured.NestedString(conditionMap, "message")
+			if status != string(metav1.ConditionFalse) {
+				continue
+			}
+			switch conditionType {
+			case "Accepted":
+				route.Status = StatusError
+				route.Warnings = append(route.Warnings, "route not accepted: "+message)
+			case "ResolvedRefs":
+				route.Status = worstStatus(route.Status, StatusWarning)
+				route.Warnings = append(route.Warnings, "unresolved references: "+message)
+			}
+		}
+	}
+
+	return route, r.finish(&route)
+}
+
+// routeMatch is one path or method match of a route rule
+type routeMatch struct {
+	path     string
+	pathType string
+}
+
+// routeMatches lists the matches of a rule; a rule without matches matches everything
+func routeMatches(kind string, rule map[string]interface{}) []routeMatch {
+	var matches []routeMatch
+	entries, _, _ := unstructured.NestedSlice(rule, "matches")
+	for _, entry := range entries {
+		match, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if kind == "GRPCRoute" {
+			service, _, _ := unstructured.NestedString(match, "method", "service")
+			method, _, _ := unstructured.NestedString(match, "method", "method")
+			matchType, _, _ := unstructured.NestedString(match, "method", "type")
+			if service == "" && method == "" {
+				continue
+			}
+			matches = append(matches, routeMatch{path: service + "/" + method, pathType: matchType})
+			continue
+		}
+		value, found, _ := unstructured.NestedString(match, "path", "value")
+		if !found {
+			continue
+		}
+		matchType, _, _ := unstructured.NestedString(match, "path", "type")
+		if matchType == "" {
+			matchType = "PathPrefix"
+		}
+		matches = append(matches, routeMatch{path: value, pathType: matchType})
+	}
+
+	if len(matches) == 0 {
+		if kind == "GRPCRoute" {
+			return []routeMatch{{}}
+		}
+		return []routeMatch{{path: "/", pathType: "PathPrefix"}}
+	}
+	return matches
+}
+
+// parentGateways resolves the Gateways a route attaches to
+func (r *routeResolver) parentGateways(route *RouteRef, object *unstructured.Unstructured) []GatewayRef {
+	var gateways []GatewayRef
+	parents, _, _ := unstructured.NestedSlice(object.Object, "spec", "parentRefs")
+	for _, parent := range parents {
+		ref, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if kind, _, _ := unstructured.NestedString(ref, "kind"); kind != "" && kind != "Gateway" {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(ref, "name")
+		namespace, _, _ := unstructured.NestedString(ref, "namespace")
+		if namespace == "" {
+			namespace = route.Namespace
+		}
+		sectionName, _, _ := unstructured.NestedString(ref, "sectionName")
+
+		gatewayRef := GatewayRef{Name: name, Namespace: namespace, SectionName: sectionName, Status: StatusUnknown}
+		gateway, err := r.gateway(namespace, name)
+		if err != nil {
+			// Status stays unknown: the gateway may well exist
+			gatewayRef.Error = fmt.Sprintf("could not check parent gateway %s/%s: %v", namespace, name, err)
+			gateways = append(gateways, gatewayRef)
+			continue
+		}
+		if gateway == nil {
+			gatewayRef.Missing = true
+			gatewayRef.Status = StatusError
+			gateways = append(gateways, gatewayRef)
+			continue
+		}
+		gatewayRef.Class, _, _ = unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+		gatewayRef.Status = unstructuredConditionStatus(gateway, "Programmed")
+
+		addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+		for _, address := range addresses {
+			if addressMap, ok := address.(map[string]interface{}); ok {
+				if value, _, _ := unstructured.NestedString(addressMap, "value"); value != "" {
+					gatewayRef.Addresses = append(gatewayRef.Addresses, value)
+				}
+			}
+		}
+
+		listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+		for _, listener := range listeners {
+			listenerMap, ok := listener.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			listenerName, _, _ := unstructured.NestedString(listenerMap, "name")
+			if sectionName != "" && listenerName != sectionName {
+				continue
+			}
+			protocol, _, _ := unstructured.NestedString(listenerMap, "protocol")
+			port, _, _ := unstructured.NestedInt64(listenerMap, "port")
+			hostname, _, _ := unstructured.NestedString(listenerMap, "hostname")
+			gatewayRef.Listeners = append(gatewayRef.Listeners, GatewayListener{
+				Name:     listenerName,
+				Protocol: protocol,
+				Port:     port,
+				Hostname: hostname,
+			})
+			r.listenerTLS(route, namespace, hostname, listenerMap)
+		}
+		gateways = append(gateways, gatewayRef)
+	}
+	return gateways
+}
+
+// listenerTLS records the certificates a Gateway listener terminates TLS with
+func (r *routeResolver) listenerTLS(route *RouteRef, gatewayNamespace, hostname string, listener map[string]interface{}) {
+	certificates, _, _ := unstructured.NestedSlice(listener, "tls", "certificateRefs")
+	for _, certificate := range certificates {
+		ref, ok := certificate.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if kind, _, _ := unstructured.NestedString(ref, "kind"); kind != "" && kind != "Secret" {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(ref, "name")
+		namespace, _, _ := unstructured.NestedString(ref, "namespace")
+		if namespace == "" {
+			namespace = gatewayNamespace
+		}
+
+		tls := RouteTLS{SecretName: name, Namespace: namespace}
+		if hostname != "" {
+			tls.Hosts = []string{hostname}
+		}
+		if !r.secretExists(namespace, name) {
+			tls.Missing = true
+			route.Status = worstStatus(route.Status, StatusWarning)
+			route.Warnings = append(route.Warnings, fmt.Sprintf("TLS secret %s/%s not found", namespace, name))
+		}
+		route.TLS = append(route.TLS, tls)
+	}
+}
+
+// backend resolves a route backend to a Service port, flagging missing services and ports
+func (r *routeResolver) backend(namespace, name, port string, weight *int64) RouteBackend {
+	backend := RouteBackend{
+		Service:   name,
+		Namespace: namespace,
+		Port:      port,
+		Weight:    weight,
+		Workload:  namespace == r.namespace && r.targets[name],
+		Status:    StatusHealthy,
+	}
+
+	svc, err := r.service(namespace, name)
+	if err != nil {
+		backend.Status = StatusUnknown
+		backend.Error = fmt.Sprintf("could not check service %s/%s: %v", namespace, name, err)
+		return backend
+	}
+	if svc == nil {
+		backend.Status = StatusError
+		backend.Error = fmt.Sprintf("service %s/%s not found", namespace, name)
+		return backend
+	}
+	if port == "" || svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return backend
+	}
+
+	for _, servicePort := range svc.Spec.Ports {
+		if servicePort.Name == port || strconv.Itoa(int(servicePort.Port)) == port {
+			backend.TargetPort = servicePort.TargetPort.String()
+			if servicePort.TargetPort.IntVal == 0 && servicePort.TargetPort.StrVal == "" {
+				backend.TargetPort = strconv.Itoa(int(servicePort.Port))
+			}
+			return backend
+		}
+	}
+	backend.Status = StatusError
+	backend.Error = fmt.Sprintf("service %s/%s has no port %s", namespace, name, port)
+	return backend
+}
+
+// targetsRoute reports whether any rule of a route reaches a workload service
+func (r *routeResolver) targetsRoute(route *RouteRef) bool {
+	for _, rule := range route.Rules {
+		if rule.Backend.Workload {
+			return true
+		}
+	}
+	return false
+}
+
+// finish rolls backend problems into the route status and reports whether the route is relevant
+func (r *routeResolver) finish(route *RouteRef) bool {
+	if !r.targetsRoute(route) {
+		return false
+	}
+	for _, rule := range route.Rules {
+		if rule.Backend.Error == "" {
+			continue
+		}
+		// A broken workload backend means the workload is unreachable through this rule;
+		// one that could not be checked is only a warning
+		if rule.Backend.Workload && rule.Backend.Status == StatusError {
+			route.Status = StatusError
+		} else {
+			route.Status = worstStatus(route.Status, StatusWarning)
+		}
+		route.Warnings = append(route.Warnings, rule.Backend.Error)
+	}
+	return true
+}
+
+// service returns a service, nil without an error when it does not exist
+func (r *routeResolver) service(namespace, name string) (*corev1.Service, error) {
+	key := namespace + "/" + name
+	if result, ok := r.services[key]; ok {
+		return result.object, result.err
+	}
+	svc, err := r.s.clientset.CoreV1().Services(namespace).Get(r.ctx, name, metav1.GetOptions{})
+	result := lookupResult[corev1.Service]{object: svc, err: err}
+	if err != nil {
+		result.object = nil
+		// Only a definite NotFound is missing; RBAC denials should not look like broken routes
+		if apierrors.IsNotFound(err) {
+			result.err = nil
+		}
+	}
+	r.services[key] = result
+	return result.object, result.err
+}
+
+func (r *routeResolver) secretExists(namespace, name string) bool {
+	key := namespace + "/" + name
+	if exists, ok := r.secrets[key]; ok {
+		return exists
+	}
+	_, err := r.s.clientset.CoreV1().Secrets(namespace).Get(r.ctx, name, metav1.GetOptions{})
+	// Only a definite NotFound is reported; RBAC denials should not look like broken routes
+	exists := err == nil || !apierrors.IsNotFound(err)
+	r.secrets[key] = exists
+	return exists
+}
+
+// gateway returns a Gateway, nil without an error when it does not exist
+func (r *routeResolver) gateway(namespace, name string) (*unstructured.Unstructured, error) {
+	key := namespace + "/" + name
+	if result, ok := r.gateways[key]; ok {
+		return result.object, result.err
+	}
+	gvr := schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	gateway, err := r.s.dynamic.Resource(gvr).Namespace(namespace).Get(r.ctx, name, metav1.GetOptions{})
+	result := lookupResult[unstructured.Unstructured]{object: gateway, err: err}
+	if err != nil {
+		result.object = nil
+		if apierrors.IsNotFound(err) {
+			result.err = nil
+		}
+	}
+	r.gateways[key] = result
+	return result.object, result.err
+}
@@ -0,0 +1,175 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetRoutesForServices(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt32(8080)},
+			}},
+		},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "gateway-cert", Namespace: "infra"}},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}},
+				Rules: []networkingv1.IngressRule{{
+					Host: "shop.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{
+						{Path: "/", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web", Port: networkingv1.ServiceBackendPort{Name: "http"}}}},
+						{Path: "/admin", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web", Port: networkingv1.ServiceBackendPort{Number: 9000}}}},
+						{Path: "/legacy", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "legacy", Port: networkingv1.ServiceBackendPort{Number: 80}}}},
+					}}},
+				}},
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "shop"},
+			Spec: networkingv1.IngressSpec{DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{Name: "other", Port: networkingv1.ServiceBackendPort{Number: 80}},
+			}},
+		},
+	)
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: gatewayGroupVersion,
+		APIResources: []metav1.APIResource{{Name: "gateways"}, {Name: "httproutes"}},
+	}}
+
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gatewayGroupVersion,
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": "public", "namespace": "infra"},
+		"spec": map[string]interface{}{
+			"gatewayClassName": "istio",
+			"listeners": []interface{}{
+				map[string]interface{}{
+					"name": "https", "protocol": "HTTPS", "port": int64(443), "hostname": "api.example.com",
+					"tls": map[string]interface{}{"certificateRefs": []interface{}{map[string]interface{}{"name": "gateway-cert"}}},
+				},
+				map[string]interface{}{"name": "http", "protocol": "HTTP", "port": int64(80)},
+			},
+		},
+	}}
+	httpRoute := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gatewayGroupVersion,
+		"kind":       "HTTPRoute",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"hostnames":  []interface{}{"api.example.com"},
+			"parentRefs": []interface{}{map[string]interface{}{"name": "public", "namespace": "infra", "sectionName": "https"}},
+			"rules": []interface{}{map[string]interface{}{
+				"matches":     []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": "/v1"}}},
+				"backendRefs": []interface{}{map[string]interface{}{"name": "web", "port": int64(80)}},
+			}},
+		},
+	}}
+	scheme := runtime.NewScheme()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}:   "GatewayList",
+		{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}: "HTTPRouteList",
+	}, httpRoute)
+	// Created explicitly: the fake tracker would guess "gatewaies" as the resource for kind Gateway
+	_, err := dynamicClient.Resource(schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}).
+		Namespace("infra").Create(context.Background(), gateway, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	service := NewService(clientset).withDynamicClient(dynamicClient)
+	routes := service.getRoutesForServices(context.Background(), "shop", []string{"web"})
+	require.Len(t, routes, 2)
+
+	route := routes[0]
+	assert.Equal(t, "HTTPRoute", route.Kind)
+	assert.Equal(t, StatusHealthy, route.Status)
+	require.Len(t, route.Rules, 1)
+	assert.Equal(t, "/v1", route.Rules[0].Path)
+	assert.Equal(t, "8080", route.Rules[0].Backend.TargetPort)
+	require.Len(t, route.Gateways, 1)
+	assert.Equal(t, "istio", route.Gateways[0].Class)
+	require.Len(t, route.Gateways[0].Listeners, 1)
+	assert.Equal(t, int64(443), route.Gateways[0].Listeners[0].Port)
+	require.Len(t, route.TLS, 1)
+	assert.False(t, route.TLS[0].Missing)
+
+	// The ingress has a missing TLS secret, a missing port on the workload service and a missing service
+	ingress := routes[1]
+	assert.Equal(t, "Ingress", ingress.Kind)
+	assert.Equal(t, "web", ingress.Name)
+	assert.Equal(t, StatusError, ingress.Status)
+	require.Len(t, ingress.Rules, 3)
+	assert.Equal(t, "8080", ingress.Rules[0].Backend.TargetPort)
+	assert.Contains(t, ingress.Rules[1].Backend.Error, "has no port 9000")
+	assert.False(t, ingress.Rules[2].Backend.Workload)
+	assert.Contains(t, ingress.Rules[2].Backend.Error, "not found")
+	require.Len(t, ingress.TLS, 1)
+	assert.True(t, ingress.TLS[0].Missing)
+}
+
+// TestRouteLookupErrors verifies a denied lookup is reported as unknown, not as a missing
+// backend or gateway
+func TestRouteLookupErrors(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec: networkingv1.IngressSpec{DefaultBackend: &networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{Name: "web", Port: networkingv1.ServiceBackendPort{Number: 80}},
+		}},
+	})
+	clientset.PrependReactor("get", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "services"}, "web", errors.New("denied"))
+	})
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: gatewayGroupVersion,
+		APIResources: []metav1.APIResource{{Name: "gateways"}, {Name: "httproutes"}},
+	}}
+
+	httpRoute := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gatewayGroupVersion,
+		"kind":       "HTTPRoute",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{map[string]interface{}{"name": "public", "namespace": "infra"}},
+			"rules":      []interface{}{map[string]interface{}{"backendRefs": []interface{}{map[string]interface{}{"name": "web", "port": int64(80)}}}},
+		},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}:   "GatewayList",
+		{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}: "HTTPRouteList",
+	}, httpRoute)
+	dynamicClient.PrependReactor("get", "gateways", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "gateway.networking.k8s.io", Resource: "gateways"}, "public", errors.New("denied"))
+	})
+
+	routes := NewService(clientset).withDynamicClient(dynamicClient).getRoutesForServices(context.Background(), "shop", []string{"web"})
+	require.Len(t, routes, 2)
+	for _, route := range routes {
+		assert.Equal(t, StatusWarning, route.Status, route.Kind)
+		require.Len(t, route.Rules, 1)
+		assert.Equal(t, StatusUnknown, route.Rules[0].Backend.Status)
+		assert.Contains(t, route.Rules[0].Backend.Error, "could not check service shop/web")
+	}
+
+	gateways := routes[0].Gateways
+	require.Len(t, gateways, 1)
+	assert.False(t, gateways[0].Missing)
+	assert.Equal(t, StatusUnknown, gateways[0].Status)
+	assert.Contains(t, routes[0].Warnings, gateways[0].Error)
+}
@@ -0,0 +1,310 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// vpaResource is served only when the VerticalPodAutoscaler CRDs are installed
+var vpaResource = schema.GroupVersionResource{Group: "autoscaling.k8s.io", Version: "v1", Resource: "verticalpodautoscalers"}
+
+// getScaling returns the autoscalers and disruption budgets that apply to a workload of the
+// given kind (Deployment, StatefulSet or DaemonSet) and pod labels, with warnings for any that
+// limit it. It returns nil when nothing applies.
+func (s *Service) getScaling(ctx context.Context, namespace, kind, name string, podLabels map[string]string) (*ScalingRef, []string) {
+	scaling := &ScalingRef{Status: StatusHealthy}
+	var warnings []string
+
+	hpas, err := s.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("Failed to list horizontalpodautoscalers in %s: %v", namespace, err)
+	} else {
+		for i := range hpas.Items {
+			hpa := &hpas.Items[i]
+			if hpa.Spec.ScaleTargetRef.Kind != kind || hpa.Spec.ScaleTargetRef.Name != name {
+				continue
+			}
+			ref := buildHPARef(hpa)
+			scaling.Autoscalers = append(scaling.Autoscalers, ref)
+			scaling.Status = worstStatus(scaling.Status, ref.Status)
+			warnings = append(warnings, hpaWarnings(hpa)...)
+		}
+		if len(scaling.Autoscalers) > 1 {
+			warnings = append(warnings, fmt.Sprintf("%d HorizontalPodAutoscalers target this %s and will fight over its replicas", len(scaling.Autoscalers), kind))
+			scaling.Status = worstStatus(scaling.Status, StatusWarning)
+		}
+	}
+
+	pdbs, err := s.clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("Failed to list poddisruptionbudgets in %s: %v", namespace, err)
+	} else {
+		for i := range pdbs.Items {
+			pdb := &pdbs.Items[i]
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || selector.Empty() || !selector.Matches(labels.Set(podLabels)) {
+				continue
+			}
+			ref := buildPDBRef(pdb)
+			scaling.DisruptionBudgets = append(scaling.DisruptionBudgets, ref)
+			scaling.Status = worstStatus(scaling.Status, ref.Status)
+			if ref.Status != StatusHealthy {
+				warnings = append(warnings, fmt.Sprintf("PodDisruptionBudget %s allows no disruptions; evictions and node drains will be blocked", pdb.Name))
+			}
+		}
+	}
+
+	for _, vpa := range s.getVerticalAutoscalers(ctx, namespace, kind, name) {
+		scaling.VerticalAutoscalers = append(scaling.VerticalAutoscalers, vpa)
+		if vpa.UpdateMode != "Off" && vpa.UpdateMode != "Initial" && scalesOnResources(scaling.Autoscalers) {
+			warnings = append(warnings, fmt.Sprintf("VerticalPodAutoscaler %s updates pods in %s mode while an HPA scales on CPU or memory", vpa.Name, vpa.UpdateMode))
+			scaling.Status = worstStatus(scaling.Status, StatusWarning)
+		}
+	}
+
+	if len(scaling.Autoscalers) == 0 && len(scaling.DisruptionBudgets) == 0 && len(scaling.VerticalAutoscalers) == 0 {
+		return nil, nil
+	}
+	return scaling, warnings
+}
+
+// buildHPARef builds an HPARef from an autoscaling/v2 HorizontalPodAutoscaler
+func buildHPARef(hpa *autoscalingv2.HorizontalPodAutoscaler) HPARef {
+	ref := HPARef{
+		Name:            hpa.Name,
+		MinReplicas:     1,
+		MaxReplicas:     hpa.Spec.MaxReplicas,
+		CurrentReplicas: hpa.Status.CurrentReplicas,
+		DesiredReplicas: hpa.Status.DesiredReplicas,
+		Status:          hpaStatus(hpa),
+	}
+	if hpa.Spec.MinReplicas != nil {
+		ref.MinReplicas = *hpa.Spec.MinReplicas
+	}
+	if hpa.Status.LastScaleTime != nil {
+		ref.LastScaleTime = &hpa.Status.LastScaleTime.Time
+	}
+
+	for i, metric := range hpa.Spec.Metrics {
+		name, target := hpaMetricTarget(metric)
+		entry := HPAMetric{Type: string(metric.Type), Name: name, Target: target}
+		// Current metrics are reported in the same order as the spec
+		if i < len(hpa.Status.CurrentMetrics) && hpa.Status.CurrentMetrics[i].Type == metric.Type {
+			entry.Current = hpaMetricCurrent(hpa.Status.CurrentMetrics[i])
+		}
+		ref.Metrics = append(ref.Metrics, entry)
+	}
+
+	for _, cond := range hpa.Status.Conditions {
+		ref.Conditions = append(ref.Conditions, Condition{
+			Type:    string(cond.Type),
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		})
+	}
+	return ref
+}
+
+// hpaWarnings explains why an autoscaler cannot follow its metrics
+func hpaWarnings(hpa *autoscalingv2.HorizontalPodAutoscaler) []string {
+	var warnings []string
+	pinned := hpa.Spec.MaxReplicas > 0 && hpa.Status.CurrentReplicas >= hpa.Spec.MaxReplicas
+	if pinned {
+		warnings = append(warnings, fmt.Sprintf("HorizontalPodAutoscaler %s is pinned at its maximum of %d replicas", hpa.Name, hpa.Spec.MaxReplicas))
+	}
+	for _, cond := range hpa.Status.Conditions {
+		switch {
+		case cond.Type == autoscalingv2.AbleToScale && cond.Status == corev1.ConditionFalse:
+			warnings = append(warnings, fmt.Sprintf("HorizontalPodAutoscaler %s cannot scale: %s", hpa.Name, cond.Message))
+		case cond.Type == autoscalingv2.ScalingActive && cond.Status == corev1.ConditionFalse:
+			warnings = append(warnings, fmt.Sprintf("HorizontalPodAutoscaler %s is not active: %s", hpa.Name, cond.Message))
+		case cond.Type == autoscalingv2.ScalingLimited && cond.Status == corev1.ConditionTrue && !pinned:
+			warnings = append(warnings, fmt.Sprintf("HorizontalPodAutoscaler %s is limited: %s", hpa.Name, cond.Message))
+		}
+	}
+	return warnings
+}
+
+// hpaMetricTarget returns the name and target of an autoscaler metric
+func hpaMetricTarget(metric autoscalingv2.MetricSpec) (string, string) {
+	switch metric.Type {
+	case autoscalingv2.ResourceMetricSourceType:
+		if metric.Resource != nil {
+			return string(metric.Resource.Name), formatMetricTarget(metric.Resource.Target)
+		}
+	case autoscalingv2.ContainerResourceMetricSourceType:
+		if metric.ContainerResource != nil {
+			return metric.ContainerResource.Container + "/" + string(metric.ContainerResource.Name), formatMetricTarget(metric.ContainerResource.Target)
+		}
+	case autoscalingv2.PodsMetricSourceType:
+		if metric.Pods != nil {
+			return metric.Pods.Metric.Name, formatMetricTarget(metric.Pods.Target)
+		}
+	case autoscalingv2.ObjectMetricSourceType:
+		if metric.Object != nil {
+			return metric.Object.DescribedObject.Kind + "/" + metric.Object.DescribedObject.Name + " " + metric.Object.Metric.Name, formatMetricTarget(metric.Object.Target)
+		}
+	case autoscalingv2.ExternalMetricSourceType:
+		if metric.External != nil {
+			return metric.External.Metric.Name, formatMetricTarget(metric.External.Target)
+		}
+	}
+	return "", ""
+}
+
+// hpaMetricCurrent returns the current value of an autoscaler metric
+func hpaMetricCurrent(metric autoscalingv2.MetricStatus) string {
+	switch {
+	case metric.Resource != nil:
+		return formatMetricValue(metric.Resource.Current)
+	case metric.ContainerResource != nil:
+		return formatMetricValue(metric.ContainerResource.Current)
+	case metric.Pods != nil:
+		return formatMetricValue(metric.Pods.Current)
+	case metric.Object != nil:
+		return formatMetricValue(metric.Object.Current)
+	case metric.External != nil:
+		return formatMetricValue(metric.External.Current)
+	}
+	return ""
+}
+
+// formatMetricTarget renders a target as a utilization percentage or quantity
+func formatMetricTarget(target autoscalingv2.MetricTarget) string {
+	switch {
+	case target.AverageUtilization != nil:
+		return fmt.Sprintf("%d%%", *target.AverageUtilization)
+	case target.AverageValue != nil:
+		return target.AverageValue.String()
+	case target.Value != nil:
+		return target.Value.String()
+	}
+	return ""
+}
+
+// formatMetricValue renders a current value as a utilization percentage or quantity
+func formatMetricValue(value autoscalingv2.MetricValueStatus) string {
+	switch {
+	case value.AverageUtilization != nil:
+		return fmt.Sprintf("%d%%", *value.AverageUtilization)
+	case value.AverageValue != nil:
+		return value.AverageValue.String()
+	case value.Value != nil:
+		return value.Value.String()
+	}
+	return ""
+}
+
+// scalesOnResources reports whether any autoscaler scales on CPU or memory, which a VPA
+// updating pods would fight with
+func scalesOnResources(hpas []HPARef) bool {
+	for _, hpa := range hpas {
+		for _, metric := range hpa.Metrics {
+			if metric.Type != string(autoscalingv2.ResourceMetricSourceType) && metric.Type != string(autoscalingv2.ContainerResourceMetricSourceType) {
+				continue
+			}
+			if strings.HasSuffix(metric.Name, "cpu") || strings.HasSuffix(metric.Name, "memory") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildPDBRef builds a PDBRef from a PodDisruptionBudget
+func buildPDBRef(pdb *policyv1.PodDisruptionBudget) PDBRef {
+	ref := PDBRef{
+		Name:               pdb.Name,
+		CurrentHealthy:     pdb.Status.CurrentHealthy,
+		DesiredHealthy:     pdb.Status.DesiredHealthy,
+		ExpectedPods:       pdb.Status.ExpectedPods,
+		DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+		Status:             pdbStatus(pdb),
+	}
+	if pdb.Spec.MinAvailable != nil {
+		ref.MinAvailable = pdb.Spec.MinAvailable.String()
+	}
+	if pdb.Spec.MaxUnavailable != nil {
+		ref.MaxUnavailable = pdb.Spec.MaxUnavailable.String()
+	}
+	return ref
+}
+
+// getVerticalAutoscalers returns the VPAs targeting a workload when the VPA CRDs are installed
+func (s *Service) getVerticalAutoscalers(ctx context.Context, namespace, kind, name string) []VPARef {
+	if s.dynamic == nil {
+		return nil
+	}
+	list, err := s.dynamic.Resource(vpaResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil // VPA not installed
+	}
+
+	var refs []VPARef
+	for i := range list.Items {
+		vpa := &list.Items[i]
+		targetKind, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "kind")
+		targetName, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "name")
+		if targetKind != kind || targetName != name {
+			continue
+		}
+		refs = append(refs, buildVPARef(vpa))
+	}
+	return refs
+}
+
+// buildVPARef builds a VPARef from an unstructured VerticalPodAutoscaler
+func buildVPARef(vpa *unstructured.Unstructured) VPARef {
+	ref := VPARef{Name: vpa.GetName(), UpdateMode: "Auto", Status: StatusUnknown}
+	if mode, ok, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode"); ok {
+		ref.UpdateMode = mode
+	}
+
+	containers, _, _ := unstructured.NestedSlice(vpa.Object, "status", "recommendation", "containerRecommendations")
+	for _, item := range containers {
+		container, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		recommendation := VPARecommendation{
+			Target:         vpaResources(container, "target"),
+			LowerBound:     vpaResources(container, "lowerBound"),
+			UpperBound:     vpaResources(container, "upperBound"),
+			UncappedTarget: vpaResources(container, "uncappedTarget"),
+		}
+		recommendation.Container, _, _ = unstructured.NestedString(container, "containerName")
+		ref.Recommendations = append(ref.Recommendations, recommendation)
+	}
+	sort.Slice(ref.Recommendations, func(i, j int) bool {
+		return ref.Recommendations[i].Container < ref.Recommendations[j].Container
+	})
+	if len(ref.Recommendations) > 0 {
+		ref.Status = StatusHealthy
+	}
+	return ref
+}
+
+// vpaResources reads a resource list such as {cpu: 250m, memory: 256Mi} from a recommendation
+func vpaResources(container map[string]interface{}, field string) map[string]string {
+	values, ok, _ := unstructured.NestedMap(container, field)
+	if !ok || len(values) == 0 {
+		return nil
+	}
+	resources := make(map[string]string, len(values))
+	for resource, value := range values {
+		resources[resource] = fmt.Sprint(value)
+	}
+	return resources
+}
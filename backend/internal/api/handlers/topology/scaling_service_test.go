@@ -0,0 +1,133 @@
+package topology
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeploymentScaling(t *testing.T) {
+	replicas := int32(5)
+	minReplicas := int32(2)
+	utilization := int32(70)
+	current := int32(95)
+	minAvailable := intstr.FromInt32(5)
+	podLabels := map[string]string{"app": "api"}
+
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: podLabels}},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 5, AvailableReplicas: 5},
+		},
+		&autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "api"},
+				MinReplicas:    &minReplicas,
+				MaxReplicas:    5,
+				Metrics: []autoscalingv2.MetricSpec{{
+					Type: autoscalingv2.ResourceMetricSourceType,
+					Resource: &autoscalingv2.ResourceMetricSource{
+						Name:   corev1.ResourceCPU,
+						Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization},
+					},
+				}},
+			},
+			Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: 5,
+				DesiredReplicas: 5,
+				CurrentMetrics: []autoscalingv2.MetricStatus{{
+					Type:     autoscalingv2.ResourceMetricSourceType,
+					Resource: &autoscalingv2.ResourceMetricStatus{Name: corev1.ResourceCPU, Current: autoscalingv2.MetricValueStatus{AverageUtilization: &current}},
+				}},
+				Conditions: []autoscalingv2.HorizontalPodAutoscalerCondition{
+					{Type: autoscalingv2.ScalingLimited, Status: corev1.ConditionTrue, Reason: "TooManyReplicas", Message: "the desired replica count is more than the maximum replica count"},
+				},
+			},
+		},
+		// Targets another deployment
+		&autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "web"}, MaxReplicas: 3},
+		},
+		&policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{MinAvailable: &minAvailable, Selector: &metav1.LabelSelector{MatchLabels: podLabels}},
+			Status:     policyv1.PodDisruptionBudgetStatus{CurrentHealthy: 5, DesiredHealthy: 5, ExpectedPods: 5},
+		},
+	)
+
+	vpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling.k8s.io/v1",
+		"kind":       "VerticalPodAutoscaler",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"targetRef":    map[string]interface{}{"kind": "Deployment", "name": "api"},
+			"updatePolicy": map[string]interface{}{"updateMode": "Auto"},
+		},
+		"status": map[string]interface{}{"recommendation": map[string]interface{}{"containerRecommendations": []interface{}{
+			map[string]interface{}{"containerName": "api", "target": map[string]interface{}{"cpu": "250m", "memory": "256Mi"}},
+		}}},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		vpaResource: "VerticalPodAutoscalerList",
+	}, vpa)
+
+	topology, err := NewService(clientset).withDynamicClient(dynamicClient).GetDeploymentTopology(context.Background(), "shop", "api")
+	require.NoError(t, err)
+	require.NotNil(t, topology.Scaling)
+
+	require.Len(t, topology.Scaling.Autoscalers, 1)
+	hpa := topology.Scaling.Autoscalers[0]
+	assert.Equal(t, int32(2), hpa.MinReplicas)
+	assert.Equal(t, StatusWarning, hpa.Status)
+	assert.Equal(t, []HPAMetric{{Type: "Resource", Name: "cpu", Target: "70%", Current: "95%"}}, hpa.Metrics)
+	assert.Equal(t, "ScalingLimited", hpa.Conditions[0].Type)
+
+	require.Len(t, topology.Scaling.DisruptionBudgets, 1)
+	assert.Equal(t, "5", topology.Scaling.DisruptionBudgets[0].MinAvailable)
+	assert.Equal(t, StatusWarning, topology.Scaling.DisruptionBudgets[0].Status)
+
+	require.Len(t, topology.Scaling.VerticalAutoscalers, 1)
+	assert.Equal(t, map[string]string{"cpu": "250m", "memory": "256Mi"}, topology.Scaling.VerticalAutoscalers[0].Recommendations[0].Target)
+
+	assert.Equal(t, StatusWarning, topology.Deployment.Status, "a ready deployment is degraded by its scaling limits")
+	require.Len(t, topology.Warnings, 3)
+	assert.Contains(t, topology.Warnings[0], "pinned at its maximum of 5 replicas")
+	assert.Contains(t, topology.Warnings[1], "allows no disruptions")
+	assert.Contains(t, topology.Warnings[2], "VerticalPodAutoscaler api updates pods in Auto mode")
+
+	graph, err := exportGraph(topology)
+	require.NoError(t, err)
+	edges := edgeSet(graph)
+	assert.Contains(t, edges, "HorizontalPodAutoscaler/shop/api scales Deployment/shop/api")
+	assert.Contains(t, edges, "PodDisruptionBudget/shop/api protects Deployment/shop/api")
+	assert.Len(t, graph.Warnings, 3)
+
+	// Without autoscalers or budgets the topology has no scaling section
+	unscaled, err := NewService(fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: podLabels}},
+	})).GetDeploymentTopology(context.Background(), "shop", "api")
+	require.NoError(t, err)
+	assert.Nil(t, unscaled.Scaling)
+	assert.Empty(t, unscaled.Warnings)
+}
@@ -5,16 +5,22 @@ import (
 	"fmt"
 	"log"
 
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 // Service handles topology operations
 type Service struct {
 	clientset kubernetes.Interface
+	metrics   MetricsProvider
+	resources *k8smanager.ResourceCache
+	dynamic   dynamic.Interface // Gateway API routes; nil skips them
 }
 
 // NewService creates a new topology service
@@ -24,6 +30,56 @@ func NewService(clientset kubernetes.Interface) *Service {
 	}
 }
 
+// NewServiceWithMetrics creates a topology service that attaches live container usage
+func NewServiceWithMetrics(clientset kubernetes.Interface, metrics MetricsProvider) *Service {
+	return &Service{
+		clientset: clientset,
+		metrics:   metrics,
+	}
+}
+
+// NewServiceWithCache creates a topology service that serves lists from the cluster's
+// shared informer cache, falling back to the API when the cache cannot be used
+func NewServiceWithCache(clientset kubernetes.Interface, metrics MetricsProvider, resources *k8smanager.ResourceCache) *Service {
+	return &Service{
+		clientset: clientset,
+		metrics:   metrics,
+		resources: resources,
+	}
+}
+
+// withDynamicClient lets the service resolve Gateway API routes
+func (s *Service) withDynamicClient(client dynamic.Interface) *Service {
+	s.dynamic = client
+	return s
+}
+
+// cachedList returns cached objects of a resource, or ok=false when the caller should query the API
+func cachedList[T runtime.Object](resources *k8smanager.ResourceCache, resource, namespace string) ([]T, bool) {
+	if resources == nil {
+		return nil, false
+	}
+	items, err := k8smanager.ListAs[T](resources, resource, namespace)
+	if err != nil {
+		log.Printf("Resource cache unavailable for %s, falling back to API: %v", resource, err)
+		return nil, false
+	}
+	return items, true
+}
+
+// getPodUsage returns live usage keyed by podUsageKey, or nil when metrics are unavailable
+func (s *Service) getPodUsage(ctx context.Context, namespace string) map[string]PodUsage {
+	if s.metrics == nil || !s.metrics.Available(ctx) {
+		return nil
+	}
+	usage, err := s.metrics.PodMetrics(ctx, namespace)
+	if err != nil {
+		log.Printf("Failed to get pod metrics for namespace %s: %v", namespace, err)
+		return nil
+	}
+	return usage
+}
+
 // GetDeploymentTopology fetches the complete topology for a deployment
 func (s *Service) GetDeploymentTopology(ctx context.Context, namespace, deploymentName string) (*DeploymentTopology, error) {
 	// Fetch the deployment
@@ -58,6 +114,8 @@ func (s *Service) GetDeploymentTopology(ctx context.Context, namespace, deployme
 		}
 	}
 
+	usage := s.getPodUsage(ctx, namespace)
+
 	// Build ReplicaSet references with their pods
 	for _, rs := range replicaSets {
 		rsRef := s.buildReplicaSetRef(&rs)
@@ -65,7 +123,9 @@ func (s *Service) GetDeploymentTopology(ctx context.Context, namespace, deployme
 		// Get pods for this ReplicaSet from our pre-fetched map
 		if pods, exists := rsPodMap[string(rs.UID)]; exists {
 			for _, pod := range pods {
-				rsRef.Pods = append(rsRef.Pods, s.buildPodRef(&pod))
+				podRef := s.buildPodRef(&pod)
+				attachPodUsage(&pod, &podRef, usage)
+				rsRef.Pods = append(rsRef.Pods, podRef)
 			}
 		}
 		
@@ -82,6 +142,15 @@ func (s *Service) GetDeploymentTopology(ctx context.Context, namespace, deployme
 		topology.Services = append(topology.Services, s.buildServiceRef(&svc))
 	}
 
+	// Ingress and Gateway API routes reaching those services
+	topology.Routes = s.getRoutesForServices(ctx, namespace, serviceNames(services))
+
+	// Autoscalers and disruption budgets, which can hold the deployment below its desired state
+	topology.Scaling, topology.Warnings = s.getScaling(ctx, namespace, "Deployment", deploymentName, deployment.Spec.Template.Labels)
+	if topology.Scaling != nil {
+		topology.Deployment.Status = worstStatus(topology.Deployment.Status, topology.Scaling.Status)
+	}
+
 	// Fetch Secrets and ConfigMaps mounted by the pods
 	// Get all secrets and configmaps in the namespace, not just mounted ones
 	secrets, configMaps := s.getAllSecretsAndConfigMaps(ctx, namespace, deployment)
@@ -187,6 +256,18 @@ func (s *Service) GetDeploymentTopology(ctx context.Context, namespace, deployme
 func (s *Service) ListDeployments(ctx context.Context, namespace string) ([]DeploymentSummary, error) {
 	var deployments []DeploymentSummary
 	
+	if cached, ok := cachedList[*appsv1.Deployment](s.resources, "deployments", namespace); ok {
+		for _, d := range cached {
+			deployments = append(deployments, DeploymentSummary{
+				Name:      d.Name,
+				Namespace: d.Namespace,
+				Replicas:  *d.Spec.Replicas,
+				Ready:     d.Status.ReadyReplicas,
+			})
+		}
+		return deployments, nil
+	}
+	
 	// If namespace is empty, list all namespaces
 	if namespace == "" {
 		namespaces, err := s.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
@@ -565,6 +646,15 @@ func (s *Service) getServicesForDeployment(ctx context.Context, namespace string
 	return matchingServices, nil
 }
 
+// serviceNames returns the names of services
+func serviceNames(services []corev1.Service) []string {
+	names := make([]string, 0, len(services))
+	for _, svc := range services {
+		names = append(names, svc.Name)
+	}
+	return names
+}
+
 func (s *Service) selectorMatches(selector, labels map[string]string) bool {
 	if len(selector) == 0 {
 		return false
@@ -0,0 +1,163 @@
+package topology
+
+import (
+	"sort"
+)
+
+// graphIndex gives quick access to the nodes and edges of a snapshot graph
+type graphIndex struct {
+	nodes map[string]GraphNode
+	edges []GraphEdge
+}
+
+// newGraphIndex indexes a graph, which may be nil
+func newGraphIndex(graph *TopologyGraph) graphIndex {
+	index := graphIndex{nodes: make(map[string]GraphNode)}
+	if graph == nil {
+		return index
+	}
+	for _, node := range graph.Nodes {
+		index.nodes[node.ID] = node
+	}
+	index.edges = graph.Edges
+	return index
+}
+
+// names returns the names of the nodes of a kind
+func (g graphIndex) names(kind string) map[string]bool {
+	names := make(map[string]bool)
+	for _, node := range g.nodes {
+		if node.Kind == kind {
+			names[node.Name] = true
+		}
+	}
+	return names
+}
+
+// references returns the names of the nodes of a kind used by the workload, keyed to
+// where they are mounted or used
+func (g graphIndex) references(kind string) map[string]string {
+	refs := make(map[string]string)
+	for _, edge := range g.edges {
+		if edge.Type != EdgeUses {
+			continue
+		}
+		if node, ok := g.nodes[edge.Target]; ok && node.Kind == kind {
+			refs[node.Name] = edge.Label
+		}
+	}
+	return refs
+}
+
+// serviceTargets returns the selector of each service and the controllers owning the
+// pods it selects, or the pods themselves when they have no owner in the graph
+func (g graphIndex) serviceTargets() (map[string]string, map[string]map[string]bool) {
+	owners := make(map[string]string)
+	for _, edge := range g.edges {
+		if edge.Type == EdgeOwns {
+			owners[edge.Target] = edge.Source
+		}
+	}
+
+	selectors := make(map[string]string)
+	targets := make(map[string]map[string]bool)
+	for _, node := range g.nodes {
+		if node.Kind != "Service" {
+			continue
+		}
+		selector, _ := node.Details["selector"].(string)
+		selectors[node.Name] = selector
+		targets[node.Name] = make(map[string]bool)
+	}
+	for _, edge := range g.edges {
+		if edge.Type != EdgeSelects {
+			continue
+		}
+		service, ok := g.nodes[edge.Source]
+		if !ok {
+			continue
+		}
+		target := edge.Target
+		if owner, ok := owners[target]; ok {
+			target = owner
+		}
+		targets[service.Name][target] = true
+	}
+	return selectors, targets
+}
+
+// diffSets compares two name sets
+func diffSets(before, after map[string]bool) RefDiff {
+	diff := RefDiff{Added: []string{}, Removed: []string{}}
+	for name := range after {
+		if !before[name] {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// diffReferences compares two sets of references, reporting ones used differently as changed
+func diffReferences(before, after map[string]string) RefDiff {
+	beforeSet := make(map[string]bool)
+	for name := range before {
+		beforeSet[name] = true
+	}
+	afterSet := make(map[string]bool)
+	for name := range after {
+		afterSet[name] = true
+	}
+
+	diff := diffSets(beforeSet, afterSet)
+	for name, usage := range after {
+		if previous, ok := before[name]; ok && previous != usage {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// diffTopologyGraphs compares the graphs of two snapshots of the same workload
+func diffTopologyGraphs(before, after *TopologyGraph) *TopologyDiff {
+	old := newGraphIndex(before)
+	current := newGraphIndex(after)
+
+	diff := &TopologyDiff{
+		ReplicaSets: diffSets(old.names("ReplicaSet"), current.names("ReplicaSet")),
+		Pods:        diffSets(old.names("Pod"), current.names("Pod")),
+		ConfigMaps:  diffReferences(old.references("ConfigMap"), current.references("ConfigMap")),
+		Secrets:     diffReferences(old.references("Secret"), current.references("Secret")),
+		Services:    []ServiceRepoint{},
+	}
+
+	oldSelectors, oldTargets := old.serviceTargets()
+	selectors, targets := current.serviceTargets()
+	names := make(map[string]bool)
+	for name := range oldSelectors {
+		names[name] = true
+	}
+	for name := range selectors {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		repoint := ServiceRepoint{
+			Name:           name,
+			SelectorBefore: oldSelectors[name],
+			SelectorAfter:  selectors[name],
+			Targets:        diffSets(oldTargets[name], targets[name]),
+		}
+		if repoint.SelectorBefore == repoint.SelectorAfter && len(repoint.Targets.Added) == 0 && len(repoint.Targets.Removed) == 0 {
+			continue
+		}
+		diff.Services = append(diff.Services, repoint)
+	}
+	return diff
+}
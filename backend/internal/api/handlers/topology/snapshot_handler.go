@@ -0,0 +1,181 @@
+package topology
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WatchSnapshotsRequest starts recording a workload
+type WatchSnapshotsRequest struct {
+	SnapshotTarget
+	Interval string `json:"interval,omitempty"` // Go duration, e.g. 30s; default 1m
+}
+
+// snapshotTargetFromQuery reads the workload query params shared by the snapshot endpoints
+func snapshotTargetFromQuery(c *gin.Context) SnapshotTarget {
+	return SnapshotTarget{
+		Context:   c.Query("context"),
+		Namespace: c.Query("namespace"),
+		Kind:      strings.ToLower(c.Query("kind")),
+		Name:      c.Query("name"),
+	}
+}
+
+// parseSnapshotTime parses an RFC3339 query param, using fallback when it is empty
+func parseSnapshotTime(c *gin.Context, param string, fallback time.Time) (time.Time, error) {
+	value := c.Query(param)
+	if value == "" {
+		if fallback.IsZero() {
+			return fallback, fmt.Errorf("%s is required", param)
+		}
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("%s must be an RFC3339 timestamp: %w", param, err)
+	}
+	return t, nil
+}
+
+// WatchTopologySnapshots handles POST /api/v1/topology/snapshots/watch
+// Body: context, namespace, kind, name (required) and interval (optional)
+func (h *Handler) WatchTopologySnapshots(c *gin.Context) {
+	var req WatchSnapshotsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Kind = strings.ToLower(req.Kind)
+
+	var interval time.Duration
+	if req.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(req.Interval); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid interval: %v", err)})
+			return
+		}
+	}
+
+	watch, err := h.snapshots.Watch(req.SnapshotTarget, interval)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, watch)
+}
+
+// UnwatchTopologySnapshots handles DELETE /api/v1/topology/snapshots/watch
+// Query params: context, namespace, kind and name (all required). Recorded history is kept.
+func (h *Handler) UnwatchTopologySnapshots(c *gin.Context) {
+	if err := h.snapshots.Unwatch(snapshotTargetFromQuery(c)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recording stopped"})
+}
+
+// ListTopologySnapshotWatches handles GET /api/v1/topology/snapshots/watches
+func (h *Handler) ListTopologySnapshotWatches(c *gin.Context) {
+	watches := h.snapshots.Watches()
+	c.JSON(http.StatusOK, gin.H{
+		"watches": watches,
+		"total":   len(watches),
+	})
+}
+
+// CaptureTopologySnapshot handles POST /api/v1/topology/snapshots/capture
+// Query params: context, namespace, kind and name (all required). Records a snapshot
+// immediately, whether or not the workload is watched.
+func (h *Handler) CaptureTopologySnapshot(c *gin.Context) {
+	target := snapshotTargetFromQuery(c)
+	if err := validateSnapshotTarget(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, err := h.snapshots.Capture(c.Request.Context(), target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// ListTopologySnapshots handles GET /api/v1/topology/snapshots
+// Query params: context, namespace, kind and name (all required). Returns snapshot timestamps.
+func (h *Handler) ListTopologySnapshots(c *gin.Context) {
+	target := snapshotTargetFromQuery(c)
+	times, err := h.snapshots.Snapshots(target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"target":    target,
+		"snapshots": times,
+		"total":     len(times),
+	})
+}
+
+// GetTopologyAsOf handles GET /api/v1/topology/snapshots/at
+// Query params: context, namespace, kind, name (all required) and time (RFC3339, default now)
+func (h *Handler) GetTopologyAsOf(c *gin.Context) {
+	target := snapshotTargetFromQuery(c)
+	if err := validateSnapshotTarget(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	at, err := parseSnapshotTime(c, "time", time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	asOf, err := h.snapshots.AsOf(target, at)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, asOf)
+}
+
+// DiffTopologySnapshots handles GET /api/v1/topology/snapshots/diff
+// Query params: context, namespace, kind, name, from (all required) and to (RFC3339, default now)
+func (h *Handler) DiffTopologySnapshots(c *gin.Context) {
+	target := snapshotTargetFromQuery(c)
+	if err := validateSnapshotTarget(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := parseSnapshotTime(c, "from", time.Time{})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseSnapshotTime(c, "to", time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	diff, err := h.snapshots.Diff(target, from, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
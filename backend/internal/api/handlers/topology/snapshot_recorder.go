@@ -0,0 +1,579 @@
+package topology
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// SnapshotDirEnv names the directory where topology snapshots are persisted; unset keeps them in memory
+	SnapshotDirEnv = "TOPOLOGY_SNAPSHOT_DIR"
+
+	// DefaultSnapshotInterval is how often watched workloads are recorded
+	DefaultSnapshotInterval = time.Minute
+	// MinSnapshotInterval keeps recorders from hammering the API server
+	MinSnapshotInterval = 10 * time.Second
+
+	// maxSnapshots and maxRecordedChanges bound the history kept in memory per workload
+	maxSnapshots       = 1440
+	maxRecordedChanges = 10000
+)
+
+// snapshotSource fetches topologies and change streams for the recorder
+type snapshotSource interface {
+	workloadTopology(ctx context.Context, target SnapshotTarget) (interface{}, error)
+	resourceCache(context string) *k8smanager.ResourceCache
+}
+
+// snapshotRecord is one line of a snapshot history file
+type snapshotRecord struct {
+	Snapshot *TopologySnapshot `json:"snapshot,omitempty"`
+	Change   *RecordedChange   `json:"change,omitempty"`
+}
+
+// snapshotHistory is the recorded past of one workload, oldest first
+type snapshotHistory struct {
+	snapshots []TopologySnapshot
+	changes   []RecordedChange
+	watch     WatchedWorkload
+	cancel    context.CancelFunc // nil when not recording
+	persisted int                // records in the history file, including ones dropped from memory
+}
+
+// SnapshotRecorder periodically records the topology of watched workloads together with
+// the resource changes between recordings, so past states can be replayed and compared.
+// When dir is set every record is also appended to a JSON lines file per workload and
+// history survives restarts; otherwise it is only kept in memory. A file is rewritten
+// from memory once it holds twice the records kept, so it stays within the same caps.
+type SnapshotRecorder struct {
+	source snapshotSource
+	dir    string
+
+	histories map[string]*snapshotHistory
+	mu        sync.Mutex
+
+	// fileMu serializes writers and history file I/O; r.mu is never held during file I/O
+	// so readers do not wait on the disk
+	fileMu sync.Mutex
+
+	now func() time.Time
+}
+
+// NewSnapshotRecorder creates a recorder persisting to dir, or memory only when dir is empty
+func NewSnapshotRecorder(source snapshotSource, dir string) *SnapshotRecorder {
+	return &SnapshotRecorder{
+		source:    source,
+		dir:       dir,
+		histories: make(map[string]*snapshotHistory),
+		now:       time.Now,
+	}
+}
+
+// key identifies a target in the recorder and its history file. Every part is
+// escaped so the key stays a single file name inside the snapshot directory.
+func (t SnapshotTarget) key() string {
+	return url.PathEscape(t.Context) + "_" + url.PathEscape(t.Namespace) + "_" + url.PathEscape(t.Kind) + "_" + url.PathEscape(t.Name)
+}
+
+// validateSnapshotTarget checks that a target names a supported workload
+func validateSnapshotTarget(target SnapshotTarget) error {
+	if target.Context == "" || target.Namespace == "" || target.Kind == "" || target.Name == "" {
+		return fmt.Errorf("context, namespace, kind and name are required")
+	}
+	if _, ok := workloadResources[target.Kind]; !ok {
+		return fmt.Errorf("kind must be deployment, daemonset, statefulset, job or cronjob")
+	}
+	if errs := validation.IsDNS1123Label(target.Namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %q: %s", target.Namespace, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsDNS1123Subdomain(target.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name %q: %s", target.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Watch starts recording a workload every interval (DefaultSnapshotInterval when zero).
+// Watching an already recorded workload restarts it with the new interval.
+func (r *SnapshotRecorder) Watch(target SnapshotTarget, interval time.Duration) (*WatchedWorkload, error) {
+	if err := validateSnapshotTarget(target); err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		interval = DefaultSnapshotInterval
+	}
+	if interval < MinSnapshotInterval {
+		return nil, fmt.Errorf("interval must be at least %s", MinSnapshotInterval)
+	}
+
+	r.ensureLoaded(target)
+	r.mu.Lock()
+	history := r.historyLocked(target, true)
+	if history.cancel != nil {
+		history.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	history.cancel = cancel
+	history.watch.Interval = interval.String()
+	history.watch.Recording = true
+	history.watch.Since = r.now()
+	history.watch.LastError = ""
+	r.mu.Unlock()
+
+	r.recordChanges(ctx, target)
+	go r.run(ctx, target, interval)
+
+	return r.status(target), nil
+}
+
+// Unwatch stops recording a workload. Its history stays available.
+func (r *SnapshotRecorder) Unwatch(target SnapshotTarget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history, ok := r.histories[target.key()]
+	if !ok || history.cancel == nil {
+		return fmt.Errorf("%s %s/%s is not being recorded in context %s", target.Kind, target.Namespace, target.Name, target.Context)
+	}
+	history.cancel()
+	history.cancel = nil
+	history.watch.Recording = false
+	return nil
+}
+
+// Watches lists every workload with recorded history, sorted by context, namespace, kind and name
+func (r *SnapshotRecorder) Watches() []WatchedWorkload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.histories))
+	for key := range r.histories {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	watches := make([]WatchedWorkload, 0, len(keys))
+	for _, key := range keys {
+		watches = append(watches, r.histories[key].statusLocked())
+	}
+	return watches
+}
+
+// Snapshots lists the recorded snapshots of a workload without their topology, oldest first
+func (r *SnapshotRecorder) Snapshots(target SnapshotTarget) ([]time.Time, error) {
+	if err := validateSnapshotTarget(target); err != nil {
+		return nil, err
+	}
+	r.ensureLoaded(target)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.historyLocked(target, false)
+	times := make([]time.Time, len(history.snapshots))
+	for i, snapshot := range history.snapshots {
+		times[i] = snapshot.Timestamp
+	}
+	return times, nil
+}
+
+// AsOf returns the topology of a workload as recorded at the given time
+func (r *SnapshotRecorder) AsOf(target SnapshotTarget, at time.Time) (*TopologyAsOf, error) {
+	if err := validateSnapshotTarget(target); err != nil {
+		return nil, err
+	}
+	r.ensureLoaded(target)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.historyLocked(target, false)
+	snapshot := history.snapshotAt(at)
+	if snapshot == nil {
+		return nil, fmt.Errorf("no snapshot of %s %s/%s recorded at or before %s", target.Kind, target.Namespace, target.Name, at.Format(time.RFC3339))
+	}
+	return &TopologyAsOf{
+		Time:     at,
+		Snapshot: snapshot,
+		Changes:  history.changesBetween(snapshot.Timestamp, at),
+	}, nil
+}
+
+// Diff compares the topology of a workload as recorded at two points in time
+func (r *SnapshotRecorder) Diff(target SnapshotTarget, from, to time.Time) (*TopologyDiff, error) {
+	if err := validateSnapshotTarget(target); err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+	r.ensureLoaded(target)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.historyLocked(target, false)
+	before := history.snapshotAt(from)
+	after := history.snapshotAt(to)
+	if before == nil || after == nil {
+		return nil, fmt.Errorf("no snapshot of %s %s/%s recorded at or before %s", target.Kind, target.Namespace, target.Name, from.Format(time.RFC3339))
+	}
+
+	diff := diffTopologyGraphs(before.Graph, after.Graph)
+	diff.Target = target
+	diff.From = before.Timestamp
+	diff.To = after.Timestamp
+	diff.Changes = history.changesBetween(before.Timestamp, after.Timestamp)
+	return diff, nil
+}
+
+// Capture records a snapshot of a workload now
+func (r *SnapshotRecorder) Capture(ctx context.Context, target SnapshotTarget) (*TopologySnapshot, error) {
+	snapshot, err := r.snapshot(ctx, target)
+	if err != nil {
+		r.ensureLoaded(target)
+		r.mu.Lock()
+		r.historyLocked(target, true).watch.LastError = err.Error()
+		r.mu.Unlock()
+		return nil, err
+	}
+
+	r.record(target, snapshotRecord{Snapshot: snapshot})
+	return snapshot, nil
+}
+
+// snapshot fetches the current topology of a target and converts it into a snapshot
+func (r *SnapshotRecorder) snapshot(ctx context.Context, target SnapshotTarget) (*TopologySnapshot, error) {
+	topology, err := r.source.workloadTopology(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := exportGraph(topology)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(topology)
+	if err != nil {
+		return nil, err
+	}
+	return &TopologySnapshot{Timestamp: r.now(), Target: target, Topology: data, Graph: graph}, nil
+}
+
+// run captures the workload every interval until ctx is done
+func (r *SnapshotRecorder) run(ctx context.Context, target SnapshotTarget, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Capture(ctx, target); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to record topology of %s %s/%s: %v", target.Kind, target.Namespace, target.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordChanges subscribes to the same cached streams as the topology WebSocket and
+// records every change until ctx is done. Without a cache only snapshots are recorded.
+func (r *SnapshotRecorder) recordChanges(ctx context.Context, target SnapshotTarget) {
+	resources := r.source.resourceCache(target.Context)
+	if resources == nil {
+		return
+	}
+
+	subscription := subscriptionTarget{Namespace: target.Namespace, Kind: target.Kind, Name: target.Name}
+	for _, stream := range subscription.streams(resources) {
+		sub, err := resources.Subscribe(stream.resource, target.Namespace, false)
+		if err != nil {
+			log.Printf("Failed to watch %s for snapshots: %v", stream.resource, err)
+			continue
+		}
+		go func(sub *k8smanager.Subscription, stream topologyStream) {
+			defer sub.Close()
+			for {
+				select {
+				case event, ok := <-sub.Events():
+					if !ok {
+						return
+					}
+					if change := stream.convert(event); change != nil {
+						r.recordChange(target, *change)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(sub, stream)
+	}
+}
+
+// recordChange appends one change to the workload's history
+func (r *SnapshotRecorder) recordChange(target SnapshotTarget, change ResourceChange) {
+	recorded := RecordedChange{RecordedAt: r.now(), ResourceChange: change}
+	r.record(target, snapshotRecord{Change: &recorded})
+}
+
+// record adds a snapshot or change to a target's history and its history file
+func (r *SnapshotRecorder) record(target SnapshotTarget, record snapshotRecord) {
+	r.ensureLoaded(target)
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+
+	r.mu.Lock()
+	history := r.historyLocked(target, true)
+	if record.Snapshot != nil {
+		history.watch.LastError = ""
+		history.appendSnapshot(*record.Snapshot)
+	}
+	if record.Change != nil {
+		history.appendChange(*record.Change)
+	}
+	// Once the file holds twice what is kept, rewrite it from a copy of the kept records
+	var kept []snapshotRecord
+	if r.dir != "" {
+		history.persisted++
+		if history.persisted >= 2*(len(history.snapshots)+len(history.changes)) {
+			kept = history.records()
+			history.persisted = len(kept)
+		}
+	}
+	r.mu.Unlock()
+
+	if r.dir == "" {
+		return
+	}
+	var err error
+	if kept != nil {
+		err = r.rewrite(target, kept)
+	} else {
+		err = r.appendRecord(target, record)
+	}
+	if err != nil {
+		log.Printf("Failed to persist topology snapshot: %v", err)
+	}
+}
+
+// status returns the recording state of a target
+func (r *SnapshotRecorder) status(target SnapshotTarget) *WatchedWorkload {
+	r.ensureLoaded(target)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.historyLocked(target, true).statusLocked()
+	return &status
+}
+
+// historyLocked returns the history of a target; r.mu must be held and ensureLoaded must
+// have run. Lookups only register a history that is being recorded.
+func (r *SnapshotRecorder) historyLocked(target SnapshotTarget, record bool) *snapshotHistory {
+	key := target.key()
+	if history, ok := r.histories[key]; ok {
+		return history
+	}
+
+	history := &snapshotHistory{watch: WatchedWorkload{SnapshotTarget: target}}
+	if record {
+		r.histories[key] = history
+	}
+	return history
+}
+
+// ensureLoaded reads a target's history file the first time the target is used. It must
+// be called without r.mu, which is only taken to check for and register the history.
+func (r *SnapshotRecorder) ensureLoaded(target SnapshotTarget) {
+	if r.dir == "" {
+		return
+	}
+	key := target.key()
+	loaded := func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		_, ok := r.histories[key]
+		return ok
+	}
+	if loaded() {
+		return
+	}
+
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+	if loaded() {
+		return
+	}
+
+	history := &snapshotHistory{watch: WatchedWorkload{SnapshotTarget: target}}
+	if err := r.load(target, history); err != nil {
+		log.Printf("Failed to load topology snapshots of %s %s/%s: %v", target.Kind, target.Namespace, target.Name, err)
+	}
+	if len(history.snapshots) == 0 && len(history.changes) == 0 {
+		return
+	}
+	if len(history.snapshots) > 0 {
+		history.watch.Since = history.snapshots[0].Timestamp
+	}
+	r.mu.Lock()
+	r.histories[key] = history
+	r.mu.Unlock()
+}
+
+// path is the history file of a target
+func (r *SnapshotRecorder) path(target SnapshotTarget) string {
+	return filepath.Join(r.dir, target.key()+".jsonl")
+}
+
+// load reads a target's history file, if any
+func (r *SnapshotRecorder) load(target SnapshotTarget, history *snapshotHistory) error {
+	if r.dir == "" {
+		return nil
+	}
+	f, err := os.Open(r.path(target))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record snapshotRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // skip a line truncated by a crash
+		}
+		if record.Snapshot != nil {
+			history.appendSnapshot(*record.Snapshot)
+		}
+		if record.Change != nil {
+			history.appendChange(*record.Change)
+		}
+		history.persisted++
+	}
+	return scanner.Err()
+}
+
+// appendRecord appends a record to the target's history file; r.fileMu must be held
+func (r *SnapshotRecorder) appendRecord(target SnapshotTarget, record snapshotRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path(target), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// rewrite replaces the target's history file with the given records; r.fileMu must be held.
+// The new file is renamed into place so a crash leaves either the old or the new history.
+func (r *SnapshotRecorder) rewrite(target SnapshotTarget, records []snapshotRecord) error {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(r.dir, target.key()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), r.path(target))
+}
+
+// appendSnapshot adds a snapshot, dropping the oldest beyond maxSnapshots
+func (h *snapshotHistory) appendSnapshot(snapshot TopologySnapshot) {
+	h.snapshots = append(h.snapshots, snapshot)
+	if len(h.snapshots) > maxSnapshots {
+		h.snapshots = h.snapshots[len(h.snapshots)-maxSnapshots:]
+	}
+}
+
+// appendChange adds a change, dropping the oldest beyond maxRecordedChanges
+func (h *snapshotHistory) appendChange(change RecordedChange) {
+	h.changes = append(h.changes, change)
+	if len(h.changes) > maxRecordedChanges {
+		h.changes = h.changes[len(h.changes)-maxRecordedChanges:]
+	}
+}
+
+// records returns the kept snapshots and changes as history file records
+func (h *snapshotHistory) records() []snapshotRecord {
+	records := make([]snapshotRecord, 0, len(h.snapshots)+len(h.changes))
+	for i := range h.snapshots {
+		snapshot := h.snapshots[i]
+		records = append(records, snapshotRecord{Snapshot: &snapshot})
+	}
+	for i := range h.changes {
+		change := h.changes[i]
+		records = append(records, snapshotRecord{Change: &change})
+	}
+	return records
+}
+
+// snapshotAt returns the latest snapshot taken at or before t, or nil
+func (h *snapshotHistory) snapshotAt(t time.Time) *TopologySnapshot {
+	i := sort.Search(len(h.snapshots), func(i int) bool {
+		return h.snapshots[i].Timestamp.After(t)
+	})
+	if i == 0 {
+		return nil
+	}
+	return &h.snapshots[i-1]
+}
+
+// changesBetween returns the changes recorded after from and up to and including to
+func (h *snapshotHistory) changesBetween(from, to time.Time) []RecordedChange {
+	changes := []RecordedChange{}
+	for _, change := range h.changes {
+		if change.RecordedAt.After(from) && !change.RecordedAt.After(to) {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// statusLocked summarizes the history; the recorder's mutex must be held
+func (h *snapshotHistory) statusLocked() WatchedWorkload {
+	status := h.watch
+	status.Snapshots = len(h.snapshots)
+	status.Changes = len(h.changes)
+	if len(h.snapshots) > 0 {
+		last := h.snapshots[len(h.snapshots)-1].Timestamp
+		status.LastSnapshot = &last
+	}
+	return status
+}
@@ -0,0 +1,179 @@
+package topology
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSnapshotSource serves a fixed topology that tests mutate between captures
+type fakeSnapshotSource struct {
+	topology *DeploymentTopology
+}
+
+func (f *fakeSnapshotSource) workloadTopology(ctx context.Context, target SnapshotTarget) (interface{}, error) {
+	// Marshal a copy so later mutations do not leak into recorded snapshots
+	data, err := json.Marshal(f.topology)
+	if err != nil {
+		return nil, err
+	}
+	var topology DeploymentTopology
+	return &topology, json.Unmarshal(data, &topology)
+}
+
+func (f *fakeSnapshotSource) resourceCache(context string) *k8smanager.ResourceCache {
+	return nil
+}
+
+// rollOut replaces the topology's replicaset, pods and config as a new rollout would
+func rollOut(topology *DeploymentTopology) {
+	topology.ReplicaSets = []ReplicaSetRef{{
+		Name: "api-8c2e", Desired: 2, Ready: 2,
+		Pods: []PodRef{
+			{Name: "api-8c2e-a", Phase: PodRunning, Labels: map[string]string{"app": "api", "track": "stable"}},
+			{Name: "api-8c2e-b", Phase: PodRunning, Labels: map[string]string{"app": "api", "track": "stable"}},
+		},
+	}}
+	topology.Secrets = []SecretRef{{Name: "api-token", MountedAt: []string{"/etc/token"}}}
+	topology.ConfigMaps = []ConfigMapRef{{Name: "api-config", MountedAt: []string{"/etc/api"}}}
+	topology.Services[0].Selector = map[string]string{"app": "api", "track": "stable"}
+}
+
+func TestSnapshotRecorder(t *testing.T) {
+	source := &fakeSnapshotSource{topology: exportTestTopology()}
+	dir := t.TempDir()
+	recorder := NewSnapshotRecorder(source, dir)
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return clock }
+
+	target := SnapshotTarget{Context: "arn:aws:eks:eu-west-1:1:cluster/prod", Namespace: "shop", Kind: "deployment", Name: "api"}
+	ctx := context.Background()
+
+	_, err := recorder.Capture(ctx, target)
+	require.NoError(t, err)
+
+	clock = clock.Add(30 * time.Second)
+	recorder.recordChange(target, ResourceChange{Type: "DELETED", ResourceType: "pod", ResourceID: "api-7d9f-b", Namespace: "shop"})
+
+	clock = clock.Add(30 * time.Second)
+	rollOut(source.topology)
+	_, err = recorder.Capture(ctx, target)
+	require.NoError(t, err)
+
+	asOf, err := recorder.AsOf(target, clock.Add(-10*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, clock.Add(-time.Minute), asOf.Snapshot.Timestamp)
+	require.Len(t, asOf.Changes, 1)
+	assert.Equal(t, "api-7d9f-b", asOf.Changes[0].ResourceID)
+	var topology DeploymentTopology
+	require.NoError(t, json.Unmarshal(asOf.Snapshot.Topology, &topology))
+	assert.Equal(t, "api-7d9f", topology.ReplicaSets[0].Name)
+
+	_, err = recorder.AsOf(target, clock.Add(-2*time.Minute))
+	assert.Error(t, err, "nothing was recorded before the first snapshot")
+
+	diff, err := recorder.Diff(target, clock.Add(-time.Minute), clock)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api-8c2e"}, diff.ReplicaSets.Added)
+	assert.Equal(t, []string{"api-7d9f"}, diff.ReplicaSets.Removed)
+	assert.Equal(t, []string{"api-8c2e-a", "api-8c2e-b"}, diff.Pods.Added)
+	assert.Equal(t, []string{"api-7d9f-a", "api-7d9f-b"}, diff.Pods.Removed)
+	assert.Equal(t, []string{"api-config"}, diff.ConfigMaps.Added)
+	assert.Equal(t, []string{"api-token"}, diff.Secrets.Changed)
+	require.Len(t, diff.Services, 1)
+	assert.Equal(t, "app=api", diff.Services[0].SelectorBefore)
+	assert.Equal(t, "app=api,track=stable", diff.Services[0].SelectorAfter)
+	assert.Equal(t, []string{"ReplicaSet/shop/api-8c2e"}, diff.Services[0].Targets.Added)
+	assert.Len(t, diff.Changes, 1)
+
+	// History is reloaded from disk by a new recorder
+	reloaded := NewSnapshotRecorder(source, dir)
+	times, err := reloaded.Snapshots(target)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{clock.Add(-time.Minute), clock}, times)
+	reloadedDiff, err := reloaded.Diff(target, clock.Add(-time.Minute), clock)
+	require.NoError(t, err)
+	assert.Equal(t, diff.Services, reloadedDiff.Services)
+	assert.Len(t, reloadedDiff.Changes, 1)
+
+	_, err = recorder.Snapshots(SnapshotTarget{Context: "prod", Namespace: "shop", Kind: "service", Name: "api"})
+	assert.Error(t, err)
+
+	// Targets that would escape the snapshot directory are rejected
+	for _, escaping := range []SnapshotTarget{
+		{Context: "prod", Namespace: "/../../..", Kind: "deployment", Name: "api"},
+		{Context: "prod", Namespace: "shop", Kind: "deployment", Name: "/../x"},
+	} {
+		_, err = recorder.Snapshots(escaping)
+		assert.Error(t, err, escaping)
+		assert.Equal(t, dir, filepath.Dir(recorder.path(escaping)), "keys are a single file name")
+	}
+}
+
+func TestSnapshotRecorderWatch(t *testing.T) {
+	recorder := NewSnapshotRecorder(&fakeSnapshotSource{topology: exportTestTopology()}, "")
+	target := SnapshotTarget{Context: "prod", Namespace: "shop", Kind: "deployment", Name: "api"}
+
+	_, err := recorder.Watch(target, time.Second)
+	assert.Error(t, err, "intervals below the minimum are rejected")
+
+	watch, err := recorder.Watch(target, 0)
+	require.NoError(t, err)
+	assert.True(t, watch.Recording)
+	assert.Equal(t, DefaultSnapshotInterval.String(), watch.Interval)
+
+	// The first snapshot is taken as soon as recording starts
+	require.Eventually(t, func() bool {
+		return recorder.Watches()[0].Snapshots == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, recorder.Unwatch(target))
+	assert.Error(t, recorder.Unwatch(target))
+	watches := recorder.Watches()
+	require.Len(t, watches, 1)
+	assert.False(t, watches[0].Recording)
+	assert.NotNil(t, watches[0].LastSnapshot)
+}
+
+// TestSnapshotRecorderCompaction verifies the history file is rewritten to the kept records
+// once it holds twice as many, and that a new recorder loads the same history
+func TestSnapshotRecorderCompaction(t *testing.T) {
+	source := &fakeSnapshotSource{topology: exportTestTopology()}
+	dir := t.TempDir()
+	recorder := NewSnapshotRecorder(source, dir)
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return clock }
+	target := SnapshotTarget{Context: "prod", Namespace: "shop", Kind: "deployment", Name: "api"}
+
+	_, err := recorder.Capture(context.Background(), target)
+	require.NoError(t, err)
+	// One snapshot and maxRecordedChanges changes are kept, so the file is rewritten on
+	// the record that brings it to twice that
+	kept := 1 + maxRecordedChanges
+	for i := 0; i < 2*kept-1; i++ {
+		clock = clock.Add(time.Millisecond)
+		recorder.recordChange(target, ResourceChange{Type: "MODIFIED", ResourceType: "pod", ResourceID: fmt.Sprintf("api-%d", i), Namespace: "shop"})
+	}
+
+	lines := func() int {
+		data, err := os.ReadFile(recorder.path(target))
+		require.NoError(t, err)
+		return bytes.Count(data, []byte("\n"))
+	}
+	assert.Equal(t, kept, lines())
+
+	reloaded := NewSnapshotRecorder(source, dir)
+	status := reloaded.status(target)
+	assert.Equal(t, 1, status.Snapshots)
+	assert.Equal(t, maxRecordedChanges, status.Changes)
+	assert.Equal(t, recorder.status(target).Changes, status.Changes)
+}
@@ -0,0 +1,719 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// revisionHashLabel is set by the statefulset controller on every pod
+const revisionHashLabel = "controller-revision-hash"
+
+// defaultStorageClassAnnotation marks the cluster default StorageClass
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// GetStatefulSetTopology retrieves the complete topology for a StatefulSet
+func (s *Service) GetStatefulSetTopology(ctx context.Context, namespace, name string) (*StatefulSetTopology, error) {
+	sts, err := s.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statefulset: %w", err)
+	}
+
+	topology := &StatefulSetTopology{
+		Namespace:   namespace,
+		StatefulSet: s.buildStatefulSetInfo(sts),
+	}
+
+	// Fetch all pods in namespace once, then filter locally
+	allPods, err := s.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods: %w", err)
+	}
+
+	usage := s.getPodUsage(ctx, namespace)
+	for _, pod := range allPods.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.Kind == "StatefulSet" && owner.UID == sts.UID {
+			podRef := s.buildStatefulSetPodRef(sts, &pod)
+			attachPodUsage(&pod, &podRef.PodRef, usage)
+			topology.Pods = append(topology.Pods, podRef)
+		}
+	}
+	sort.Slice(topology.Pods, func(i, j int) bool {
+		return topology.Pods[i].Ordinal < topology.Pods[j].Ordinal
+	})
+
+	topology.Rollout = buildStatefulSetRollout(sts, topology.Pods)
+
+	// Governing headless service
+	if sts.Spec.ServiceName != "" {
+		svc, err := s.clientset.CoreV1().Services(namespace).Get(ctx, sts.Spec.ServiceName, metav1.GetOptions{})
+		if err != nil {
+			topology.Warnings = append(topology.Warnings, fmt.Sprintf("governing service %s not found", sts.Spec.ServiceName))
+		} else {
+			ref := s.buildServiceRef(svc)
+			topology.GoverningService = &ref
+			if svc.Spec.ClusterIP != corev1.ClusterIPNone {
+				topology.Warnings = append(topology.Warnings, fmt.Sprintf("governing service %s is not headless", svc.Name))
+			}
+		}
+	}
+
+	// Other services selecting the pods, e.g. a client-facing ClusterIP service
+	services, err := s.clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get services: %w", err)
+	}
+	for _, svc := range services.Items {
+		if svc.Name == sts.Spec.ServiceName || !s.selectorMatches(svc.Spec.Selector, sts.Spec.Template.Labels) {
+			continue
+		}
+		topology.Services = append(topology.Services, s.buildServiceRef(&svc))
+	}
+
+	// Endpoints for the governing service and the selecting services
+	endpointNames := []string{}
+	if topology.GoverningService != nil {
+		endpointNames = append(endpointNames, topology.GoverningService.Name)
+	}
+	for _, svc := range topology.Services {
+		endpointNames = append(endpointNames, svc.Name)
+	}
+	for _, epName := range endpointNames {
+		endpoints, err := s.clientset.CoreV1().Endpoints(namespace).Get(ctx, epName, metav1.GetOptions{})
+		if err == nil {
+			topology.Endpoints = append(topology.Endpoints, s.buildEndpointsRef(endpoints))
+		}
+	}
+
+	// Ingress and Gateway API routes reaching those services
+	topology.Routes = s.getRoutesForServices(ctx, namespace, endpointNames)
+
+	// Autoscalers and disruption budgets, which can hold the statefulset below its desired state
+	scaling, warnings := s.getScaling(ctx, namespace, "StatefulSet", sts.Name, sts.Spec.Template.Labels)
+	if scaling != nil {
+		topology.Scaling = scaling
+		topology.StatefulSet.Status = worstStatus(topology.StatefulSet.Status, scaling.Status)
+		topology.Warnings = append(topology.Warnings, warnings...)
+	}
+
+	// volumeClaimTemplates mapped to PVCs, PVs and StorageClasses
+	templates, storageClasses, warnings := s.getVolumeClaimTemplates(ctx, sts)
+	topology.VolumeClaimTemplates = templates
+	topology.StorageClasses = storageClasses
+	topology.Warnings = append(topology.Warnings, warnings...)
+
+	// Controller revisions
+	revisions, err := s.getControllerRevisions(ctx, namespace, sts)
+	if err == nil {
+		topology.ControllerRevisions = revisions
+	}
+
+	// Secrets and ConfigMaps referenced by the pod template
+	secrets, configMaps := s.getSecretsAndConfigMapsForPodSpec(ctx, namespace, &sts.Spec.Template.Spec)
+	topology.Secrets = secrets
+	topology.ConfigMaps = configMaps
+
+	saName := sts.Spec.Template.Spec.ServiceAccountName
+	if saName == "" {
+		saName = "default"
+	}
+	sa, err := s.clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, saName, metav1.GetOptions{})
+	if err == nil {
+		topology.ServiceAccount = s.buildServiceAccountRef(sa)
+	}
+
+	return topology, nil
+}
+
+// ListStatefulSets lists all StatefulSets in a namespace
+func (s *Service) ListStatefulSets(ctx context.Context, namespace string) (*ListStatefulSetsResponse, error) {
+	statefulSets, ok := cachedList[*appsv1.StatefulSet](s.resources, "statefulsets", namespace)
+	if !ok {
+		list, err := s.clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+		}
+		for i := range list.Items {
+			statefulSets = append(statefulSets, &list.Items[i])
+		}
+	}
+
+	response := &ListStatefulSetsResponse{
+		StatefulSets: make([]StatefulSetSummary, 0, len(statefulSets)),
+	}
+
+	for _, sts := range statefulSets {
+		response.StatefulSets = append(response.StatefulSets, StatefulSetSummary{
+			Name:          sts.Name,
+			Namespace:     sts.Namespace,
+			Replicas:      statefulSetReplicas(sts),
+			ReadyReplicas: sts.Status.ReadyReplicas,
+			ServiceName:   sts.Spec.ServiceName,
+		})
+	}
+
+	return response, nil
+}
+
+// buildStatefulSetInfo builds StatefulSetInfo from a k8s StatefulSet
+func (s *Service) buildStatefulSetInfo(sts *appsv1.StatefulSet) StatefulSetInfo {
+	replicas := statefulSetReplicas(sts)
+	info := StatefulSetInfo{
+		Name:                sts.Name,
+		Replicas:            replicas,
+		ReadyReplicas:       sts.Status.ReadyReplicas,
+		CurrentReplicas:     sts.Status.CurrentReplicas,
+		UpdatedReplicas:     sts.Status.UpdatedReplicas,
+		AvailableReplicas:   sts.Status.AvailableReplicas,
+		ServiceName:         sts.Spec.ServiceName,
+		PodManagementPolicy: string(sts.Spec.PodManagementPolicy),
+		Labels:              sts.Labels,
+		CreationTimestamp:   &sts.CreationTimestamp.Time,
+	}
+
+	if info.PodManagementPolicy == "" {
+		info.PodManagementPolicy = string(appsv1.OrderedReadyPodManagement)
+	}
+
+	if sts.Spec.UpdateStrategy.Type != "" {
+		info.UpdateStrategy = string(sts.Spec.UpdateStrategy.Type)
+	} else {
+		info.UpdateStrategy = string(appsv1.RollingUpdateStatefulSetStrategyType)
+	}
+
+	if policy := sts.Spec.PersistentVolumeClaimRetentionPolicy; policy != nil {
+		info.PVCRetentionPolicy = map[string]string{
+			"whenDeleted": string(policy.WhenDeleted),
+			"whenScaled":  string(policy.WhenScaled),
+		}
+	}
+
+	// Determine status
+	if sts.Status.ReadyReplicas == replicas {
+		info.Status = StatusHealthy
+	} else if sts.Status.ReadyReplicas > 0 {
+		info.Status = StatusWarning
+	} else {
+		info.Status = StatusError
+	}
+
+	for _, cond := range sts.Status.Conditions {
+		info.Conditions = append(info.Conditions, Condition{
+			Type:    string(cond.Type),
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		})
+	}
+
+	return info
+}
+
+// buildStatefulSetPodRef builds an ordinal pod reference
+func (s *Service) buildStatefulSetPodRef(sts *appsv1.StatefulSet, pod *corev1.Pod) StatefulSetPodRef {
+	ordinal := statefulSetPodOrdinal(sts.Name, pod.Name)
+	ref := StatefulSetPodRef{
+		PodRef:   s.buildPodRef(pod),
+		Ordinal:  ordinal,
+		Revision: pod.Labels[revisionHashLabel],
+	}
+	ref.Updated = ref.Revision != "" && ref.Revision == sts.Status.UpdateRevision
+
+	if ordinal >= 0 {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			ref.Claims = append(ref.Claims, statefulSetClaimName(template.Name, sts.Name, ordinal))
+		}
+	}
+
+	return ref
+}
+
+// buildStatefulSetRollout derives the partition-based rollout state.
+// With RollingUpdate, only ordinals >= start + partition move to the update revision.
+func buildStatefulSetRollout(sts *appsv1.StatefulSet, pods []StatefulSetPodRef) StatefulSetRollout {
+	rollout := StatefulSetRollout{
+		Strategy:        string(sts.Spec.UpdateStrategy.Type),
+		CurrentRevision: sts.Status.CurrentRevision,
+		UpdateRevision:  sts.Status.UpdateRevision,
+		UpdatedReplicas: sts.Status.UpdatedReplicas,
+	}
+	if rollout.Strategy == "" {
+		rollout.Strategy = string(appsv1.RollingUpdateStatefulSetStrategyType)
+	}
+
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil {
+		if ru.Partition != nil {
+			rollout.Partition = *ru.Partition
+		}
+		if ru.MaxUnavailable != nil {
+			rollout.MaxUnavailable = ru.MaxUnavailable.String()
+		}
+	}
+
+	for _, pod := range pods {
+		if pod.Updated || pod.Ordinal < 0 {
+			continue
+		}
+		if pod.Ordinal >= statefulSetStartOrdinal(sts)+rollout.Partition {
+			rollout.PendingOrdinals = append(rollout.PendingOrdinals, pod.Ordinal)
+		} else {
+			rollout.HeldOrdinals = append(rollout.HeldOrdinals, pod.Ordinal)
+		}
+	}
+
+	// Complete once every ordinal at or above the partition runs the update revision
+	expectedUpdated := statefulSetReplicas(sts) - rollout.Partition
+	if expectedUpdated < 0 {
+		expectedUpdated = 0
+	}
+	rollout.Complete = len(rollout.PendingOrdinals) == 0 &&
+		sts.Status.ObservedGeneration >= sts.Generation &&
+		sts.Status.UpdatedReplicas >= expectedUpdated
+
+	return rollout
+}
+
+// getVolumeClaimTemplates maps each volumeClaimTemplate to the PVCs created for every
+// ordinal, their bound PVs and the StorageClasses involved
+func (s *Service) getVolumeClaimTemplates(ctx context.Context, sts *appsv1.StatefulSet) ([]VolumeClaimTemplateRef, []StorageClassRef, []string) {
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return nil, nil, nil
+	}
+
+	var warnings []string
+	claims := make(map[string]*corev1.PersistentVolumeClaim)
+	pvcList, err := s.clientset.CoreV1().PersistentVolumeClaims(sts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("failed to list persistentvolumeclaims: %v", err))
+	} else {
+		for i := range pvcList.Items {
+			claims[pvcList.Items[i].Name] = &pvcList.Items[i]
+		}
+	}
+
+	// Ordinals the controller is expected to have claims for
+	start := statefulSetStartOrdinal(sts)
+	replicas := statefulSetReplicas(sts)
+	expected := make(map[int32]bool, replicas)
+	for i := int32(0); i < replicas; i++ {
+		expected[start+i] = true
+	}
+
+	storageClassNames := make(map[string]bool)
+	templates := make([]VolumeClaimTemplateRef, 0, len(sts.Spec.VolumeClaimTemplates))
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		ref := VolumeClaimTemplateRef{
+			Name:        template.Name,
+			AccessModes: accessModeStrings(template.Spec.AccessModes),
+			MountedAt:   volumeMountsFor(template.Name, &sts.Spec.Template.Spec),
+			Claims:      []PersistentVolumeClaimRef{},
+		}
+		if template.Spec.StorageClassName != nil {
+			ref.StorageClass = *template.Spec.StorageClassName
+		}
+		if storage, ok := template.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			ref.Storage = storage.String()
+		}
+
+		for _, ordinal := range statefulSetClaimOrdinals(template.Name, sts.Name, claims, expected) {
+			claimName := statefulSetClaimName(template.Name, sts.Name, ordinal)
+			pvc, ok := claims[claimName]
+			if !ok {
+				ref.Claims = append(ref.Claims, PersistentVolumeClaimRef{
+					Name:    claimName,
+					Ordinal: ordinal,
+					Phase:   "Missing",
+					Missing: true,
+				})
+				continue
+			}
+
+			claimRef := s.buildPersistentVolumeClaimRef(ctx, pvc, ordinal)
+			if claimRef.StorageClass != "" {
+				storageClassNames[claimRef.StorageClass] = true
+			}
+			if claimRef.Phase != string(corev1.ClaimBound) {
+				warnings = append(warnings, fmt.Sprintf("persistentvolumeclaim %s is %s", claimName, claimRef.Phase))
+			}
+			ref.Claims = append(ref.Claims, claimRef)
+		}
+		if ref.StorageClass != "" {
+			storageClassNames[ref.StorageClass] = true
+		}
+
+		templates = append(templates, ref)
+	}
+
+	var storageClasses []StorageClassRef
+	for name := range storageClassNames {
+		sc, err := s.clientset.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("storageclass %s not found", name))
+			continue
+		}
+		storageClasses = append(storageClasses, buildStorageClassRef(sc))
+	}
+	sort.Slice(storageClasses, func(i, j int) bool {
+		return storageClasses[i].Name < storageClasses[j].Name
+	})
+
+	return templates, storageClasses, warnings
+}
+
+// buildPersistentVolumeClaimRef builds a claim reference including its bound volume
+func (s *Service) buildPersistentVolumeClaimRef(ctx context.Context, pvc *corev1.PersistentVolumeClaim, ordinal int32) PersistentVolumeClaimRef {
+	ref := PersistentVolumeClaimRef{
+		Name:              pvc.Name,
+		Ordinal:           ordinal,
+		Phase:             string(pvc.Status.Phase),
+		AccessModes:       accessModeStrings(pvc.Spec.AccessModes),
+		VolumeName:        pvc.Spec.VolumeName,
+		CreationTimestamp: &pvc.CreationTimestamp.Time,
+	}
+	if pvc.Spec.StorageClassName != nil {
+		ref.StorageClass = *pvc.Spec.StorageClassName
+	}
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		ref.Capacity = capacity.String()
+	}
+	if requested, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		ref.Requested = requested.String()
+	}
+
+	if pvc.Spec.VolumeName != "" {
+		pv, err := s.clientset.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err == nil {
+			ref.Volume = buildPersistentVolumeRef(pv)
+		}
+	}
+
+	return ref
+}
+
+// getControllerRevisions returns revisions owned by the StatefulSet, newest first
+func (s *Service) getControllerRevisions(ctx context.Context, namespace string, sts *appsv1.StatefulSet) ([]ControllerRevisionRef, error) {
+	revisions, err := s.clientset.AppsV1().ControllerRevisions(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []ControllerRevisionRef
+	for _, rev := range revisions.Items {
+		if owner := metav1.GetControllerOf(&rev); owner == nil || owner.UID != sts.UID {
+			continue
+		}
+		refs = append(refs, ControllerRevisionRef{
+			Name:              rev.Name,
+			Revision:          rev.Revision,
+			Current:           rev.Name == sts.Status.CurrentRevision,
+			Update:            rev.Name == sts.Status.UpdateRevision,
+			CreationTimestamp: &rev.CreationTimestamp.Time,
+		})
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Revision > refs[j].Revision
+	})
+
+	return refs, nil
+}
+
+// getSecretsAndConfigMapsForPodSpec gets secrets and configmaps referenced by a pod spec
+func (s *Service) getSecretsAndConfigMapsForPodSpec(ctx context.Context, namespace string, spec *corev1.PodSpec) ([]SecretRef, []ConfigMapRef) {
+	var secrets []SecretRef
+	var configMaps []ConfigMapRef
+
+	secretNames := make(map[string]bool)
+	configMapNames := make(map[string]bool)
+
+	for _, vol := range spec.Volumes {
+		if vol.Secret != nil {
+			secretNames[vol.Secret.SecretName] = true
+		}
+		if vol.ConfigMap != nil {
+			configMapNames[vol.ConfigMap.Name] = true
+		}
+	}
+
+	containers := append([]corev1.Container{}, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil {
+				if env.ValueFrom.SecretKeyRef != nil {
+					secretNames[env.ValueFrom.SecretKeyRef.Name] = true
+				}
+				if env.ValueFrom.ConfigMapKeyRef != nil {
+					configMapNames[env.ValueFrom.ConfigMapKeyRef.Name] = true
+				}
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				secretNames[envFrom.SecretRef.Name] = true
+			}
+			if envFrom.ConfigMapRef != nil {
+				configMapNames[envFrom.ConfigMapRef.Name] = true
+			}
+		}
+	}
+
+	for name := range secretNames {
+		secret, err := s.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		secretRef := SecretRef{
+			Name:              secret.Name,
+			Type:              string(secret.Type),
+			Immutable:         secret.Immutable != nil && *secret.Immutable,
+			Data:              make(map[string]string),
+			CreationTimestamp: &secret.CreationTimestamp.Time,
+			MountedAt:         checkIfMountedInPodSpec(secret.Name, "secret", spec),
+		}
+		for key := range secret.Data {
+			secretRef.Data[key] = "***" // Redacted for security
+		}
+		secrets = append(secrets, secretRef)
+	}
+
+	for name := range configMapNames {
+		cm, err := s.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		configMapRef := ConfigMapRef{
+			Name:              cm.Name,
+			Immutable:         cm.Immutable != nil && *cm.Immutable,
+			Data:              make(map[string]string),
+			CreationTimestamp: &cm.CreationTimestamp.Time,
+			MountedAt:         checkIfMountedInPodSpec(cm.Name, "configmap", spec),
+		}
+		for key, value := range cm.Data {
+			configMapRef.Data[key] = value
+		}
+		for key := range cm.BinaryData {
+			configMapRef.Data[key+" (binary)"] = "***" // Don't expose binary data
+		}
+		configMaps = append(configMaps, configMapRef)
+	}
+
+	return secrets, configMaps
+}
+
+// checkIfMountedInPodSpec lists where a ConfigMap or Secret is used in a pod spec,
+// using the same container:path / container:env:NAME format as the other workloads
+func checkIfMountedInPodSpec(name string, resourceType string, spec *corev1.PodSpec) []string {
+	var mountedAt []string
+	volumeNameMap := make(map[string]bool)
+
+	for _, volume := range spec.Volumes {
+		if resourceType == "secret" && volume.Secret != nil && volume.Secret.SecretName == name {
+			volumeNameMap[volume.Name] = true
+		}
+		if resourceType == "configmap" && volume.ConfigMap != nil && volume.ConfigMap.Name == name {
+			volumeNameMap[volume.Name] = true
+		}
+	}
+
+	check := func(prefix string, container corev1.Container) {
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeNameMap[volumeMount.Name] {
+				mountedAt = append(mountedAt, fmt.Sprintf("%s%s:%s", prefix, container.Name, volumeMount.MountPath))
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if resourceType == "secret" && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == name {
+				mountedAt = append(mountedAt, fmt.Sprintf("%s%s:env:%s", prefix, container.Name, env.Name))
+			}
+			if resourceType == "configmap" && env.ValueFrom.ConfigMapKeyRef != nil && env.ValueFrom.ConfigMapKeyRef.Name == name {
+				mountedAt = append(mountedAt, fmt.Sprintf("%s%s:env:%s", prefix, container.Name, env.Name))
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if resourceType == "secret" && envFrom.SecretRef != nil && envFrom.SecretRef.Name == name {
+				mountedAt = append(mountedAt, fmt.Sprintf("%s%s:envFrom:all", prefix, container.Name))
+			}
+			if resourceType == "configmap" && envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == name {
+				mountedAt = append(mountedAt, fmt.Sprintf("%s%s:envFrom:all", prefix, container.Name))
+			}
+		}
+	}
+
+	for _, container := range spec.Containers {
+		check("", container)
+	}
+	for _, container := range spec.InitContainers {
+		check("init-", container)
+	}
+
+	return mountedAt
+}
+
+// volumeMountsFor lists container:path mounts of a named volume
+func volumeMountsFor(volumeName string, spec *corev1.PodSpec) []string {
+	var mounts []string
+	for _, container := range spec.Containers {
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeMount.Name == volumeName {
+				mounts = append(mounts, fmt.Sprintf("%s:%s", container.Name, volumeMount.MountPath))
+			}
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeMount.Name == volumeName {
+				mounts = append(mounts, fmt.Sprintf("init-%s:%s", container.Name, volumeMount.MountPath))
+			}
+		}
+	}
+	return mounts
+}
+
+// buildPersistentVolumeRef builds a PersistentVolume reference
+func buildPersistentVolumeRef(pv *corev1.PersistentVolume) *PersistentVolumeRef {
+	ref := &PersistentVolumeRef{
+		Name:          pv.Name,
+		Phase:         string(pv.Status.Phase),
+		ReclaimPolicy: string(pv.Spec.PersistentVolumeReclaimPolicy),
+		StorageClass:  pv.Spec.StorageClassName,
+		Source:        persistentVolumeSource(pv),
+	}
+	if capacity, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
+		ref.Capacity = capacity.String()
+	}
+
+	// Local volumes are pinned to a node; show the required term
+	if pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+		var terms []string
+		for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			for _, expr := range term.MatchExpressions {
+				terms = append(terms, fmt.Sprintf("%s %s %s", expr.Key, strings.ToLower(string(expr.Operator)), strings.Join(expr.Values, ",")))
+			}
+		}
+		ref.NodeAffinity = strings.Join(terms, "; ")
+	}
+
+	return ref
+}
+
+// persistentVolumeSource names the driver or plugin backing a volume
+func persistentVolumeSource(pv *corev1.PersistentVolume) string {
+	switch {
+	case pv.Spec.CSI != nil:
+		return pv.Spec.CSI.Driver
+	case pv.Spec.Local != nil:
+		return "local"
+	case pv.Spec.HostPath != nil:
+		return "hostPath"
+	case pv.Spec.NFS != nil:
+		return "nfs"
+	case pv.Spec.AWSElasticBlockStore != nil:
+		return "awsElasticBlockStore"
+	case pv.Spec.GCEPersistentDisk != nil:
+		return "gcePersistentDisk"
+	case pv.Spec.AzureDisk != nil:
+		return "azureDisk"
+	default:
+		return ""
+	}
+}
+
+// buildStorageClassRef builds a StorageClass reference
+func buildStorageClassRef(sc *storagev1.StorageClass) StorageClassRef {
+	ref := StorageClassRef{
+		Name:                 sc.Name,
+		Provisioner:          sc.Provisioner,
+		AllowVolumeExpansion: sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion,
+		IsDefault:            sc.Annotations[defaultStorageClassAnnotation] == "true",
+	}
+	if sc.ReclaimPolicy != nil {
+		ref.ReclaimPolicy = string(*sc.ReclaimPolicy)
+	}
+	if sc.VolumeBindingMode != nil {
+		ref.VolumeBindingMode = string(*sc.VolumeBindingMode)
+	}
+	return ref
+}
+
+// statefulSetReplicas returns the desired replicas, defaulting to 1
+func statefulSetReplicas(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Replicas == nil {
+		return 1
+	}
+	return *sts.Spec.Replicas
+}
+
+// statefulSetStartOrdinal returns the first ordinal, which spec.ordinals.start may move from 0
+func statefulSetStartOrdinal(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Ordinals == nil {
+		return 0
+	}
+	return sts.Spec.Ordinals.Start
+}
+
+// statefulSetClaimOrdinals returns, in order, the expected ordinals plus those of existing
+// claims of a template, so claims left behind by a scale down are not hidden
+func statefulSetClaimOrdinals(templateName, stsName string, claims map[string]*corev1.PersistentVolumeClaim, expected map[int32]bool) []int32 {
+	seen := make(map[int32]bool, len(expected))
+	ordinals := make([]int32, 0, len(expected))
+	for ordinal := range expected {
+		seen[ordinal] = true
+		ordinals = append(ordinals, ordinal)
+	}
+	prefix := templateName + "-" + stsName + "-"
+	for name := range claims {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		parsed, err := strconv.ParseInt(strings.TrimPrefix(name, prefix), 10, 32)
+		if err != nil || parsed < 0 {
+			continue
+		}
+		ordinal := int32(parsed)
+		// Only the controller's own spelling, so "data-web-01" is not taken for ordinal 1
+		if seen[ordinal] || statefulSetClaimName(templateName, stsName, ordinal) != name {
+			continue
+		}
+		seen[ordinal] = true
+		ordinals = append(ordinals, ordinal)
+	}
+	sort.Slice(ordinals, func(i, j int) bool { return ordinals[i] < ordinals[j] })
+	return ordinals
+}
+
+// statefulSetPodOrdinal parses the ordinal from a <statefulset>-<ordinal> pod name, or -1
+func statefulSetPodOrdinal(stsName, podName string) int32 {
+	prefix := stsName + "-"
+	if !strings.HasPrefix(podName, prefix) {
+		return -1
+	}
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(podName, prefix), 10, 32)
+	if err != nil {
+		return -1
+	}
+	return int32(ordinal)
+}
+
+// statefulSetClaimName returns the PVC name the controller creates for a template and ordinal
+func statefulSetClaimName(templateName, stsName string, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", templateName, stsName, ordinal)
+}
+
+// accessModeStrings converts access modes to strings
+func accessModeStrings(modes []corev1.PersistentVolumeAccessMode) []string {
+	result := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		result = append(result, string(mode))
+	}
+	return result
+}
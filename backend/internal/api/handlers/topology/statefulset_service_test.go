@@ -0,0 +1,130 @@
+package topology
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestStatefulSetTopology verifies ordinal pods, claim mapping and partition rollout state
+func TestStatefulSetTopology(t *testing.T) {
+	replicas := int32(3)
+	partition := int32(2)
+	storageClass := "fast"
+	isController := true
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "data", UID: types.UID("sts-uid"), Generation: 2},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: "db-headless",
+			Selector:    &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:         "postgres",
+					VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/var/lib/postgresql"}},
+				}}},
+			},
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "data"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClass,
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("10Gi"),
+					}},
+				},
+			}},
+		},
+		Status: appsv1.StatefulSetStatus{
+			ObservedGeneration: 2,
+			ReadyReplicas:      3,
+			UpdatedReplicas:    1,
+			CurrentRevision:    "db-old",
+			UpdateRevision:     "db-new",
+		},
+	}
+
+	owner := []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", UID: sts.UID, Controller: &isController}}
+	pod := func(name, revision string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "data",
+			Labels:          map[string]string{"app": "db", revisionHashLabel: revision},
+			OwnerReferences: owner,
+		}}
+	}
+	claim := func(name, volume string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "data"},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClass, VolumeName: volume},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		}
+	}
+
+	clientset := fake.NewSimpleClientset(
+		sts,
+		pod("db-0", "db-old"),
+		pod("db-1", "db-old"),
+		pod("db-2", "db-new"),
+		claim("data-db-0", "pv-0"),
+		claim("data-db-1", "pv-1"),
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-0"},
+			Spec: corev1.PersistentVolumeSpec{PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "ebs.csi.aws.com"},
+			}},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-headless", Namespace: "data"},
+			Spec:       corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone, Selector: map[string]string{"app": "db"}},
+		},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: "ebs.csi.aws.com"},
+		&appsv1.ControllerRevision{ObjectMeta: metav1.ObjectMeta{Name: "db-old", Namespace: "data", OwnerReferences: owner}, Revision: 1},
+		&appsv1.ControllerRevision{ObjectMeta: metav1.ObjectMeta{Name: "db-new", Namespace: "data", OwnerReferences: owner}, Revision: 2},
+	)
+
+	topology, err := NewService(clientset).GetStatefulSetTopology(context.Background(), "data", "db")
+	require.NoError(t, err)
+
+	require.Len(t, topology.Pods, 3)
+	assert.Equal(t, int32(0), topology.Pods[0].Ordinal)
+	assert.Equal(t, []string{"data-db-0"}, topology.Pods[0].Claims)
+	assert.True(t, topology.Pods[2].Updated)
+
+	require.NotNil(t, topology.GoverningService)
+	assert.Equal(t, "db-headless", topology.GoverningService.Name)
+	assert.Empty(t, topology.Services, "governing service is not repeated")
+
+	require.Len(t, topology.VolumeClaimTemplates, 1)
+	claims := topology.VolumeClaimTemplates[0].Claims
+	require.Len(t, claims, 3)
+	assert.Equal(t, "ebs.csi.aws.com", claims[0].Volume.Source)
+	assert.True(t, claims[2].Missing)
+	assert.Equal(t, []string{"postgres:/var/lib/postgresql"}, topology.VolumeClaimTemplates[0].MountedAt)
+	require.Len(t, topology.StorageClasses, 1)
+
+	assert.Equal(t, int32(2), topology.Rollout.Partition)
+	assert.Equal(t, []int32{0, 1}, topology.Rollout.HeldOrdinals)
+	assert.Empty(t, topology.Rollout.PendingOrdinals)
+	assert.True(t, topology.Rollout.Complete)
+
+	require.Len(t, topology.ControllerRevisions, 2)
+	assert.Equal(t, "db-new", topology.ControllerRevisions[0].Name)
+	assert.True(t, topology.ControllerRevisions[0].Update)
+}
@@ -0,0 +1,469 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Update types sent alongside incremental changes
+const (
+	UpdateTypeSnapshot     = "snapshot"     // changes hold the full current state of the subscription
+	UpdateTypeUnsubscribed = "unsubscribed" // the connection no longer follows any target
+	UpdateTypeError        = "error"        // a subscription message could not be applied
+)
+
+// workloadResources maps subscription kinds to their cached resource
+var workloadResources = map[string]string{
+	"deployment":  "deployments",
+	"daemonset":   "daemonsets",
+	"job":         "jobs",
+	"cronjob":     "cronjobs",
+	"statefulset": "statefulsets",
+}
+
+// subscriptionTarget is what a topology connection is following: a single workload,
+// or every deployment in a namespace when Kind is empty
+type subscriptionTarget struct {
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+// targetFromMessage validates a subscription message and extracts its target
+func targetFromMessage(msg SubscriptionMessage) (subscriptionTarget, error) {
+	target := subscriptionTarget{Namespace: msg.Namespace}
+	workloads := []struct{ kind, name string }{
+		{"deployment", msg.Deployment},
+		{"daemonset", msg.DaemonSet},
+		{"job", msg.Job},
+		{"cronjob", msg.CronJob},
+		{"statefulset", msg.StatefulSet},
+	}
+	for _, workload := range workloads {
+		if workload.name == "" {
+			continue
+		}
+		if target.Kind != "" {
+			return target, fmt.Errorf("only one workload can be subscribed at a time, got %s and %s", target.Kind, workload.kind)
+		}
+		target.Kind = workload.kind
+		target.Name = workload.name
+	}
+	if target.Kind != "" && target.Namespace == "" {
+		return target, fmt.Errorf("namespace is required to subscribe to a %s", target.Kind)
+	}
+	return target, nil
+}
+
+// message converts the target back into the message form echoed to clients
+func (t subscriptionTarget) message() *SubscriptionMessage {
+	msg := &SubscriptionMessage{Type: "subscribe", Namespace: t.Namespace}
+	switch t.Kind {
+	case "deployment":
+		msg.Deployment = t.Name
+	case "daemonset":
+		msg.DaemonSet = t.Name
+	case "job":
+		msg.Job = t.Name
+	case "cronjob":
+		msg.CronJob = t.Name
+	case "statefulset":
+		msg.StatefulSet = t.Name
+	}
+	return msg
+}
+
+// topologyStream is one cached resource streamed for a target
+type topologyStream struct {
+	resource string
+	convert  changeConverter
+}
+
+// streams returns the resources and converters that make up the target's topology
+func (t subscriptionTarget) streams(resources *k8smanager.ResourceCache) []topologyStream {
+	pods := topologyStream{"pods", podChange(t.podFilter(resources))}
+
+	var streams []topologyStream
+	switch t.Kind {
+	case "":
+		// Every deployment in the namespace and related resources
+		streams = []topologyStream{
+			{"deployments", deploymentChange},
+			pods,
+			{"replicasets", replicaSetChange},
+		}
+	case "deployment":
+		streams = []topologyStream{
+			{"deployments", namedChange(t.Name, deploymentChange)},
+			pods,
+			{"replicasets", ownedChange("Deployment", t.Name, replicaSetChange)},
+		}
+	case "daemonset":
+		streams = []topologyStream{
+			{"daemonsets", namedChange(t.Name, daemonSetChange)},
+			pods,
+		}
+	case "job":
+		streams = []topologyStream{
+			{"jobs", namedChange(t.Name, jobChange)},
+			pods,
+		}
+	case "cronjob":
+		streams = []topologyStream{
+			{"cronjobs", namedChange(t.Name, cronJobChange)},
+			{"jobs", ownedChange("CronJob", t.Name, jobChange)},
+			pods,
+		}
+	case "statefulset":
+		streams = []topologyStream{
+			{"statefulsets", namedChange(t.Name, statefulSetChange)},
+			pods,
+			{"persistentvolumeclaims", claimChange(resources, t.Namespace, t.Name)},
+		}
+	}
+
+	// Common streams for all resource types
+	return append(streams,
+		topologyStream{"services", serviceChange},
+		topologyStream{"endpoints", endpointsChange},
+	)
+}
+
+// podFilter returns which pods belong to the target, or nil for every pod in the namespace.
+// The workload is read from the cache on each call so selector changes apply immediately.
+func (t subscriptionTarget) podFilter(resources *k8smanager.ResourceCache) func(pod *v1.Pod) bool {
+	switch t.Kind {
+	case "":
+		return nil
+	case "cronjob":
+		return func(pod *v1.Pod) bool {
+			return ownedByCronJob(resources, pod, t.Name)
+		}
+	}
+	return func(pod *v1.Pod) bool {
+		return workloadSelector(resources, t).Matches(labels.Set(pod.Labels))
+	}
+}
+
+// workloadSelector returns the target workload's spec.selector, or a selector matching
+// nothing when the workload is not cached or has no usable selector
+func workloadSelector(resources *k8smanager.ResourceCache, t subscriptionTarget) labels.Selector {
+	object, exists, err := resources.Get(workloadResources[t.Kind], t.Namespace, t.Name)
+	if err != nil || !exists {
+		return labels.Nothing()
+	}
+
+	var selector *metav1.LabelSelector
+	switch workload := object.(type) {
+	case *appsv1.Deployment:
+		selector = workload.Spec.Selector
+	case *appsv1.DaemonSet:
+		selector = workload.Spec.Selector
+	case *appsv1.StatefulSet:
+		selector = workload.Spec.Selector
+	case *batchv1.Job:
+		selector = workload.Spec.Selector
+	}
+	if selector == nil {
+		return labels.Nothing()
+	}
+
+	result, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		log.Printf("Invalid selector on %s %s/%s: %v", t.Kind, t.Namespace, t.Name, err)
+		return labels.Nothing()
+	}
+	return result
+}
+
+// ownedByCronJob reports whether a pod was created by a Job of the named CronJob
+func ownedByCronJob(resources *k8smanager.ResourceCache, pod *v1.Pod, cronJobName string) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind != "Job" {
+			continue
+		}
+		object, exists, err := resources.Get("jobs", pod.Namespace, owner.Name)
+		if err != nil || !exists {
+			continue
+		}
+		if job, ok := object.(*batchv1.Job); ok && hasOwner(job.OwnerReferences, "CronJob", cronJobName) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOwner reports whether the owner references include the given kind and name
+func hasOwner(owners []metav1.OwnerReference, kind, name string) bool {
+	for _, owner := range owners {
+		if owner.Kind == kind && owner.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedChange limits a converter to objects owned by the given controller
+func ownedChange(ownerKind, ownerName string, convert changeConverter) changeConverter {
+	return func(event k8smanager.ResourceEvent) *ResourceChange {
+		object, err := meta.Accessor(event.Object)
+		if err != nil || !hasOwner(object.GetOwnerReferences(), ownerKind, ownerName) {
+			return nil
+		}
+		return convert(event)
+	}
+}
+
+// claimChange limits PVC changes to claims created from the StatefulSet's volumeClaimTemplates
+func claimChange(resources *k8smanager.ResourceCache, namespace, stsName string) changeConverter {
+	return func(event k8smanager.ResourceEvent) *ResourceChange {
+		pvc, ok := event.Object.(*v1.PersistentVolumeClaim)
+		if !ok {
+			return nil
+		}
+		if event.Type == watch.Deleted {
+			return persistentVolumeClaimChange(event)
+		}
+
+		object, exists, err := resources.Get("statefulsets", namespace, stsName)
+		if err != nil || !exists {
+			return nil
+		}
+		sts, ok := object.(*appsv1.StatefulSet)
+		if !ok {
+			return nil
+		}
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			prefix := template.Name + "-"
+			if strings.HasPrefix(pvc.Name, prefix) && statefulSetPodOrdinal(stsName, strings.TrimPrefix(pvc.Name, prefix)) >= 0 {
+				return persistentVolumeClaimChange(event)
+			}
+		}
+		return nil
+	}
+}
+
+// topologySession tracks the current subscription of one WebSocket connection.
+// Every subscribe, refresh or unsubscribe starts a new generation; updates from
+// older generations still in flight are dropped before they reach the client.
+type topologySession struct {
+	ctx       context.Context
+	resources *k8smanager.ResourceCache
+	metrics   MetricsProvider
+	updates   chan TopologyUpdate
+
+	mu         sync.Mutex
+	target     *subscriptionTarget
+	cancel     context.CancelFunc
+	generation atomic.Uint64
+}
+
+// newTopologySession creates a session bound to the connection's lifetime
+func newTopologySession(ctx context.Context, resources *k8smanager.ResourceCache, metrics MetricsProvider) *topologySession {
+	return &topologySession{
+		ctx:       ctx,
+		resources: resources,
+		metrics:   metrics,
+		updates:   make(chan TopologyUpdate, 10),
+	}
+}
+
+// current reports whether an update belongs to the active subscription
+func (s *topologySession) current(update TopologyUpdate) bool {
+	return update.generation == s.generation.Load()
+}
+
+// handle applies a message received from the client
+func (s *topologySession) handle(msg SubscriptionMessage) {
+	switch msg.Type {
+	case "subscribe":
+		target, err := targetFromMessage(msg)
+		if err != nil {
+			s.sendError(err)
+			return
+		}
+		log.Printf("Subscription updated: namespace=%s, %s=%s", target.Namespace, target.Kind, target.Name)
+		s.subscribe(target)
+	case "unsubscribe":
+		s.unsubscribe()
+	case "refresh":
+		s.mu.Lock()
+		target := s.target
+		if target != nil {
+			log.Printf("Refresh requested for namespace %s", target.Namespace)
+			s.subscribeLocked(*target)
+		}
+		s.mu.Unlock()
+		if target == nil {
+			s.sendError(fmt.Errorf("nothing to refresh: no active subscription"))
+		}
+	default:
+		s.sendError(fmt.Errorf("unknown message type %q", msg.Type))
+	}
+}
+
+// subscribe replaces the current subscription with target. Subscriptions are opened
+// before the snapshot is read so no change between the two is lost; changes already
+// reflected in the snapshot are re-sent, which clients apply idempotently.
+func (s *topologySession) subscribe(target subscriptionTarget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribeLocked(target)
+}
+
+// subscribeLocked is subscribe with s.mu held
+func (s *topologySession) subscribeLocked(target subscriptionTarget) {
+	s.stopLocked()
+	generation := s.generation.Add(1)
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.cancel = cancel
+	s.target = &target
+
+	streams := target.streams(s.resources)
+	subs := make([]*k8smanager.Subscription, 0, len(streams))
+	for _, stream := range streams {
+		sub, err := s.resources.Subscribe(stream.resource, target.Namespace, false)
+		if err != nil {
+			log.Printf("Failed to watch %s: %v", stream.resource, err)
+			subs = append(subs, nil)
+			continue
+		}
+		subs = append(subs, sub)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	snapshot := TopologyUpdate{
+		Type:         UpdateTypeSnapshot,
+		Changes:      []ResourceChange{},
+		Subscription: target.message(),
+		Timestamp:    now,
+		generation:   generation,
+	}
+	for i, stream := range streams {
+		if subs[i] == nil {
+			continue
+		}
+		objects, err := s.resources.List(stream.resource, target.Namespace)
+		if err != nil {
+			continue
+		}
+		for _, object := range objects {
+			change := stream.convert(k8smanager.ResourceEvent{Type: watch.Added, Resource: stream.resource, Object: object})
+			if change != nil {
+				snapshot.Changes = append(snapshot.Changes, *change)
+			}
+		}
+	}
+	s.send(ctx, snapshot)
+
+	for i, stream := range streams {
+		if subs[i] != nil {
+			go forwardChanges(ctx, subs[i], stream, generation, s.updates, func() { s.resync(generation) })
+		}
+	}
+
+	// Start metrics collection for pods (CPU/Memory updates)
+	go collectPodMetrics(ctx, s.resources, s.metrics, target.Namespace, target.podFilter(s.resources), generation, s.updates)
+}
+
+// resync re-sends the snapshot of a subscription after one of its streams dropped
+// events, unless a newer subscribe or unsubscribe already replaced it. The check and
+// the re-subscribe happen under s.mu so a client message cannot slip in between.
+func (s *topologySession) resync(generation uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.target == nil || s.generation.Load() != generation {
+		return
+	}
+	s.subscribeLocked(*s.target)
+}
+
+// unsubscribe stops the current subscription
+func (s *topologySession) unsubscribe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopLocked()
+	s.target = nil
+	generation := s.generation.Add(1)
+	s.send(s.ctx, TopologyUpdate{
+		Type:       UpdateTypeUnsubscribed,
+		Changes:    []ResourceChange{},
+		Timestamp:  time.Now().Format(time.RFC3339),
+		generation: generation,
+	})
+}
+
+// stopLocked cancels the streams of the current subscription; s.mu must be held
+func (s *topologySession) stopLocked() {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// sendError reports a rejected message without changing the subscription
+func (s *topologySession) sendError(err error) {
+	log.Printf("Topology subscription error: %v", err)
+	s.send(s.ctx, TopologyUpdate{
+		Type:       UpdateTypeError,
+		Changes:    []ResourceChange{},
+		Error:      err.Error(),
+		Timestamp:  time.Now().Format(time.RFC3339),
+		generation: s.generation.Load(),
+	})
+}
+
+// send queues an update unless ctx is done
+func (s *topologySession) send(ctx context.Context, update TopologyUpdate) {
+	select {
+	case s.updates <- update:
+	case <-ctx.Done():
+	}
+}
+
+// forwardChanges forwards cached changes of one stream until ctx is done. Once the
+// subscription drops events the client's view is stale, so resync replaces it.
+func forwardChanges(ctx context.Context, sub *k8smanager.Subscription, stream topologyStream, generation uint64, updates chan<- TopologyUpdate, resync func()) {
+	defer sub.Close()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				log.Printf("%s stream closed", stream.resource)
+				return
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				log.Printf("%s stream fell behind and dropped %d events, resyncing", stream.resource, dropped)
+				resync()
+				return
+			}
+			change := stream.convert(event)
+			if change == nil {
+				continue
+			}
+			select {
+			case updates <- TopologyUpdate{Changes: []ResourceChange{*change}, Timestamp: change.Timestamp, generation: generation}:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package topology
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// nextUpdate returns the next update of the session's current subscription
+func nextUpdate(t *testing.T, session *topologySession) TopologyUpdate {
+	t.Helper()
+	for {
+		select {
+		case update := <-session.updates:
+			if session.current(update) && update.Changes != nil && !isMetricsUpdate(update) {
+				return update
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for update")
+			return TopologyUpdate{}
+		}
+	}
+}
+
+func isMetricsUpdate(update TopologyUpdate) bool {
+	return len(update.Changes) > 0 && update.Changes[0].ResourceType == "metrics"
+}
+
+// resourceIDs lists "type/id" for every change in an update
+func resourceIDs(update TopologyUpdate) []string {
+	ids := make([]string, 0, len(update.Changes))
+	for _, change := range update.Changes {
+		ids = append(ids, change.ResourceType+"/"+change.ResourceID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TestTopologySessionSubscriptions verifies snapshots, selector-based pod filtering,
+// switching targets, refresh and unsubscribe on one session
+func TestTopologySessionSubscriptions(t *testing.T) {
+	replicas := int32(1)
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "api"}},
+			},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop"},
+			Spec: appsv1.StatefulSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "db"}},
+				VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+					ObjectMeta: metav1.ObjectMeta{Name: "data"},
+				}},
+			},
+		},
+		// Labelled app=api but not selected by the deployment
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-debug", Namespace: "shop", Labels: map[string]string{"app": "api"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-7d9f-x2", Namespace: "shop", Labels: map[string]string{"component": "api"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "shop", Labels: map[string]string{"component": "db"}}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-db-0", Namespace: "shop"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "scratch", Namespace: "shop"}},
+	)
+	resources := k8smanager.NewResourceCache("test", clientset, time.Minute)
+	defer resources.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newTopologySession(ctx, resources, nil)
+
+	session.handle(SubscriptionMessage{Type: "subscribe", Namespace: "shop", Deployment: "api"})
+	snapshot := nextUpdate(t, session)
+	assert.Equal(t, UpdateTypeSnapshot, snapshot.Type)
+	assert.Equal(t, "api", snapshot.Subscription.Deployment)
+	assert.Equal(t, []string{"deployment/api", "pod/api-7d9f-x2"}, resourceIDs(snapshot))
+
+	// Pods created later are filtered by the deployment's selector too
+	_, err := clientset.CoreV1().Pods("shop").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-7d9f-y3", Namespace: "shop", Labels: map[string]string{"component": "api"}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pod/api-7d9f-y3"}, resourceIDs(nextUpdate(t, session)))
+
+	session.handle(SubscriptionMessage{Type: "subscribe", Namespace: "shop", StatefulSet: "db"})
+	snapshot = nextUpdate(t, session)
+	assert.Equal(t, UpdateTypeSnapshot, snapshot.Type)
+	assert.Equal(t, []string{"persistentvolumeclaim/data-db-0", "pod/db-0", "statefulset/db"}, resourceIDs(snapshot))
+
+	session.handle(SubscriptionMessage{Type: "refresh"})
+	refreshed := nextUpdate(t, session)
+	assert.Equal(t, UpdateTypeSnapshot, refreshed.Type)
+	assert.Equal(t, resourceIDs(snapshot), resourceIDs(refreshed))
+
+	session.handle(SubscriptionMessage{Type: "subscribe", Namespace: "shop", Deployment: "api", Job: "migrate"})
+	rejected := nextUpdate(t, session)
+	assert.Equal(t, UpdateTypeError, rejected.Type)
+	assert.Contains(t, rejected.Error, "only one workload")
+
+	// A stream resyncs only the subscription it belongs to
+	generation := session.generation.Load()
+	session.resync(generation)
+	resynced := nextUpdate(t, session)
+	assert.Equal(t, UpdateTypeSnapshot, resynced.Type)
+	assert.Equal(t, resourceIDs(snapshot), resourceIDs(resynced))
+
+	session.handle(SubscriptionMessage{Type: "unsubscribe"})
+	assert.Equal(t, UpdateTypeUnsubscribed, nextUpdate(t, session).Type)
+
+	// A resync from a stream of the old subscription does not revive it
+	session.resync(generation + 1)
+	session.mu.Lock()
+	assert.Nil(t, session.target)
+	session.mu.Unlock()
+
+	session.handle(SubscriptionMessage{Type: "refresh"})
+	assert.Equal(t, UpdateTypeError, nextUpdate(t, session).Type)
+}
+
+// TestTopologySessionMetricsFiltered verifies streamed usage covers only the target's pods
+func TestTopologySessionMetricsFiltered(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "api"}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-7d9f-x2", Namespace: "shop", Labels: map[string]string{"component": "api"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "shop", Labels: map[string]string{"component": "db"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "db"}}},
+		},
+	)
+	resources := k8smanager.NewResourceCache("test", clientset, time.Minute)
+	defer resources.Stop()
+
+	metrics := NewFakeMetricsProvider()
+	metrics.SetContainerUsage("shop", "api-7d9f-x2", "api", 10, 1024)
+	metrics.SetContainerUsage("shop", "db-0", "db", 20, 2048)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newTopologySession(ctx, resources, metrics)
+	session.handle(SubscriptionMessage{Type: "subscribe", Namespace: "shop", Deployment: "api"})
+
+	for {
+		select {
+		case update := <-session.updates:
+			if !session.current(update) || len(update.Changes) == 0 || update.Changes[0].Type != "METRICS" {
+				continue
+			}
+			assert.Equal(t, []string{"pod/api-7d9f-x2"}, resourceIDs(update))
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for metrics")
+		}
+	}
+}
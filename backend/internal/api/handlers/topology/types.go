@@ -1,6 +1,7 @@
 package topology
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -51,6 +52,21 @@ type ContainerRef struct {
 	Resources    *ResourceRequirements `json:"resources,omitempty"`
 	Ports        []ContainerPort       `json:"ports,omitempty"`
 	Mounts       []string              `json:"mounts,omitempty"`
+	Usage        *ContainerUsage       `json:"usage,omitempty"`
+}
+
+// ContainerUsage represents live container usage from metrics.k8s.io and its
+// share of the container's requests and limits
+type ContainerUsage struct {
+	CPU                  string     `json:"cpu"`
+	Memory               string     `json:"memory"`
+	CPUMillicores        int64      `json:"cpuMillicores"`
+	MemoryBytes          int64      `json:"memoryBytes"`
+	CPURequestPercent    *float64   `json:"cpuRequestPercent,omitempty"`
+	CPULimitPercent      *float64   `json:"cpuLimitPercent,omitempty"`
+	MemoryRequestPercent *float64   `json:"memoryRequestPercent,omitempty"`
+	MemoryLimitPercent   *float64   `json:"memoryLimitPercent,omitempty"`
+	Timestamp            *time.Time `json:"timestamp,omitempty"`
 }
 
 // ContainerPort represents a container port
@@ -242,6 +258,71 @@ type DeploymentTopology struct {
 	RoleBindings        []RoleBindingRef   `json:"roleBindings,omitempty"`
 	ClusterRoles        []RoleRef          `json:"clusterRoles,omitempty"`
 	ClusterRoleBindings []RoleBindingRef   `json:"clusterRoleBindings,omitempty"`
+	Routes              []RouteRef         `json:"routes,omitempty"`
+	Scaling             *ScalingRef        `json:"scaling,omitempty"`
+	Warnings            []string           `json:"warnings,omitempty"`
+}
+
+// RouteRef represents an Ingress or Gateway API route that sends traffic to a workload's services
+type RouteRef struct {
+	Kind      string       `json:"kind"` // Ingress, HTTPRoute or GRPCRoute
+	Name      string       `json:"name"`
+	Namespace string       `json:"namespace"`
+	Class     string       `json:"class,omitempty"` // ingressClassName for Ingresses
+	Gateways  []GatewayRef `json:"gateways,omitempty"`
+	Rules     []RouteRule  `json:"rules"`
+	TLS       []RouteTLS   `json:"tls,omitempty"`
+	Status    K8sStatus    `json:"status"`
+	Warnings  []string     `json:"warnings,omitempty"`
+}
+
+// RouteRule represents one host/path match and the backend it forwards to
+type RouteRule struct {
+	Hosts    []string     `json:"hosts,omitempty"`
+	Path     string       `json:"path,omitempty"`     // URL path, or service/method for gRPC
+	PathType string       `json:"pathType,omitempty"` // Prefix, Exact, ImplementationSpecific, RegularExpression
+	Backend  RouteBackend `json:"backend"`
+}
+
+// RouteBackend represents the Service port a route rule resolves to
+type RouteBackend struct {
+	Service    string    `json:"service"`
+	Namespace  string    `json:"namespace"`
+	Port       string    `json:"port,omitempty"`       // Port as written in the route
+	TargetPort string    `json:"targetPort,omitempty"` // Resolved pod port
+	Weight     *int64    `json:"weight,omitempty"`
+	Workload   bool      `json:"workload"` // Backend is one of the workload's services
+	Status     K8sStatus `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RouteTLS represents a TLS certificate served for a route
+type RouteTLS struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	SecretName string   `json:"secretName,omitempty"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Missing    bool     `json:"missing,omitempty"`
+}
+
+// GatewayRef represents a Gateway a route is attached to
+type GatewayRef struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	SectionName string            `json:"sectionName,omitempty"`
+	Class       string            `json:"class,omitempty"`
+	Listeners   []GatewayListener `json:"listeners,omitempty"`
+	Addresses   []string          `json:"addresses,omitempty"`
+	Status      K8sStatus         `json:"status"`
+	Missing     bool              `json:"missing,omitempty"`
+	Error       string            `json:"error,omitempty"` // Set when the gateway could not be read
+}
+
+// GatewayListener represents a Gateway listener
+type GatewayListener struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Port     int64  `json:"port"`
+	Hostname string `json:"hostname,omitempty"`
 }
 
 // ListDeploymentsResponse represents the response for listing deployments
@@ -288,6 +369,9 @@ type DaemonSetTopology struct {
 	RoleBindings        []RoleBindingRef   `json:"roleBindings,omitempty"`
 	ClusterRoles        []RoleRef          `json:"clusterRoles,omitempty"`
 	ClusterRoleBindings []RoleBindingRef   `json:"clusterRoleBindings,omitempty"`
+	Routes              []RouteRef         `json:"routes,omitempty"`
+	Scaling             *ScalingRef        `json:"scaling,omitempty"`
+	Warnings            []string           `json:"warnings,omitempty"`
 }
 
 // DaemonSetSummary represents a summary of a daemonset
@@ -422,4 +506,418 @@ type CronJobSummary struct {
 // ListCronJobsResponse represents the response for listing CronJobs
 type ListCronJobsResponse struct {
 	CronJobs []CronJobSummary `json:"cronjobs"`
-}
\ No newline at end of file
+}
+// StatefulSetInfo represents statefulset metadata
+type StatefulSetInfo struct {
+	Name                string            `json:"name"`
+	Replicas            int32             `json:"replicas"`
+	ReadyReplicas       int32             `json:"readyReplicas"`
+	CurrentReplicas     int32             `json:"currentReplicas,omitempty"`
+	UpdatedReplicas     int32             `json:"updatedReplicas,omitempty"`
+	AvailableReplicas   int32             `json:"availableReplicas,omitempty"`
+	ServiceName         string            `json:"serviceName"`
+	PodManagementPolicy string            `json:"podManagementPolicy,omitempty"`
+	UpdateStrategy      string            `json:"updateStrategy,omitempty"`
+	PVCRetentionPolicy  map[string]string `json:"pvcRetentionPolicy,omitempty"` // whenDeleted/whenScaled
+	Status              K8sStatus         `json:"status"`
+	Labels              map[string]string `json:"labels,omitempty"`
+	Conditions          []Condition       `json:"conditions,omitempty"`
+	CreationTimestamp   *time.Time        `json:"creationTimestamp,omitempty"`
+}
+
+// StatefulSetPodRef represents an ordinal pod of a statefulset
+type StatefulSetPodRef struct {
+	PodRef
+	Ordinal  int32    `json:"ordinal"`
+	Revision string   `json:"revision,omitempty"` // controller-revision-hash of the pod
+	Updated  bool     `json:"updated"`            // Pod runs the update revision
+	Claims   []string `json:"claims,omitempty"`   // PVCs created from volumeClaimTemplates
+}
+
+// StatefulSetRollout represents the partition-based rollout state of a statefulset
+type StatefulSetRollout struct {
+	Strategy        string  `json:"strategy"`
+	Partition       int32   `json:"partition"`
+	MaxUnavailable  string  `json:"maxUnavailable,omitempty"`
+	CurrentRevision string  `json:"currentRevision,omitempty"`
+	UpdateRevision  string  `json:"updateRevision,omitempty"`
+	UpdatedReplicas int32   `json:"updatedReplicas"`
+	PendingOrdinals []int32 `json:"pendingOrdinals,omitempty"` // Ordinals at or above the partition not yet updated
+	HeldOrdinals    []int32 `json:"heldOrdinals,omitempty"`    // Ordinals below the partition kept on the current revision
+	Complete        bool    `json:"complete"`
+}
+
+// PersistentVolumeRef represents a PersistentVolume bound to a claim
+type PersistentVolumeRef struct {
+	Name          string `json:"name"`
+	Capacity      string `json:"capacity,omitempty"`
+	Phase         string `json:"phase"`
+	ReclaimPolicy string `json:"reclaimPolicy,omitempty"`
+	StorageClass  string `json:"storageClass,omitempty"`
+	Source        string `json:"source,omitempty"` // CSI driver or volume plugin
+	NodeAffinity  string `json:"nodeAffinity,omitempty"`
+}
+
+// PersistentVolumeClaimRef represents a PersistentVolumeClaim
+type PersistentVolumeClaimRef struct {
+	Name              string               `json:"name"`
+	Ordinal           int32                `json:"ordinal"`
+	Phase             string               `json:"phase"`
+	Capacity          string               `json:"capacity,omitempty"`
+	Requested         string               `json:"requested,omitempty"`
+	AccessModes       []string             `json:"accessModes,omitempty"`
+	StorageClass      string               `json:"storageClass,omitempty"`
+	VolumeName        string               `json:"volumeName,omitempty"`
+	Volume            *PersistentVolumeRef `json:"volume,omitempty"`
+	Missing           bool                 `json:"missing,omitempty"` // Expected from the template but not found
+	CreationTimestamp *time.Time           `json:"creationTimestamp,omitempty"`
+}
+
+// VolumeClaimTemplateRef represents a volumeClaimTemplate and the claims created from it
+type VolumeClaimTemplateRef struct {
+	Name         string                     `json:"name"`
+	StorageClass string                     `json:"storageClass,omitempty"`
+	AccessModes  []string                   `json:"accessModes,omitempty"`
+	Storage      string                     `json:"storage,omitempty"`
+	MountedAt    []string                   `json:"mountedAt,omitempty"`
+	Claims       []PersistentVolumeClaimRef `json:"claims"`
+}
+
+// StorageClassRef represents a StorageClass
+type StorageClassRef struct {
+	Name                 string `json:"name"`
+	Provisioner          string `json:"provisioner"`
+	ReclaimPolicy        string `json:"reclaimPolicy,omitempty"`
+	VolumeBindingMode    string `json:"volumeBindingMode,omitempty"`
+	AllowVolumeExpansion bool   `json:"allowVolumeExpansion"`
+	IsDefault            bool   `json:"isDefault,omitempty"`
+}
+
+// ControllerRevisionRef represents a ControllerRevision owned by a workload
+type ControllerRevisionRef struct {
+	Name              string     `json:"name"`
+	Revision          int64      `json:"revision"`
+	Current           bool       `json:"current,omitempty"`
+	Update            bool       `json:"update,omitempty"`
+	CreationTimestamp *time.Time `json:"creationTimestamp,omitempty"`
+}
+
+// StatefulSetTopology represents the complete topology of a statefulset
+type StatefulSetTopology struct {
+	Namespace            string                   `json:"namespace"`
+	StatefulSet          StatefulSetInfo          `json:"statefulset"`
+	Pods                 []StatefulSetPodRef      `json:"pods,omitempty"`
+	GoverningService     *ServiceRef              `json:"governingService,omitempty"`
+	Services             []ServiceRef             `json:"services,omitempty"`
+	Endpoints            []EndpointsRef           `json:"endpoints,omitempty"`
+	VolumeClaimTemplates []VolumeClaimTemplateRef `json:"volumeClaimTemplates,omitempty"`
+	StorageClasses       []StorageClassRef        `json:"storageClasses,omitempty"`
+	ControllerRevisions  []ControllerRevisionRef  `json:"controllerRevisions,omitempty"`
+	Rollout              StatefulSetRollout       `json:"rollout"`
+	Secrets              []SecretRef              `json:"secrets,omitempty"`
+	ConfigMaps           []ConfigMapRef           `json:"configmaps,omitempty"`
+	ServiceAccount       *ServiceAccountRef       `json:"serviceAccount,omitempty"`
+	Routes               []RouteRef               `json:"routes,omitempty"`
+	Scaling              *ScalingRef              `json:"scaling,omitempty"`
+	Warnings             []string                 `json:"warnings,omitempty"`
+}
+
+// StatefulSetSummary represents a summary of a statefulset
+type StatefulSetSummary struct {
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+	ServiceName   string `json:"serviceName"`
+}
+
+// ListStatefulSetsResponse represents the response for listing statefulsets
+type ListStatefulSetsResponse struct {
+	StatefulSets []StatefulSetSummary `json:"statefulsets"`
+}
+
+// GraphNode is a resource in an application topology graph
+type GraphNode struct {
+	ID        string                 `json:"id"` // Kind/namespace/name
+	Kind      string                 `json:"kind"`
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Status    K8sStatus              `json:"status"`
+	Labels    map[string]string      `json:"labels,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Collapsed *CollapsedPods         `json:"collapsed,omitempty"`
+}
+
+// CollapsedPods summarizes the pods of one owner folded into a single PodGroup node
+type CollapsedPods struct {
+	Owner        string            `json:"owner"`
+	Count        int               `json:"count"`
+	StatusCounts map[K8sStatus]int `json:"statusCounts"`
+	Pods         []string          `json:"pods"`
+}
+
+// GraphEdge is a directed relationship between two graph nodes
+type GraphEdge struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"` // owns, selects, routes, parent, uses, mounts, claims, runs-as, binds, grants, scales, protects
+	Label  string `json:"label,omitempty"`
+}
+
+// GraphSummary counts the nodes in a graph and rolls health up per namespace
+type GraphSummary struct {
+	Nodes           int                  `json:"nodes"`
+	Edges           int                  `json:"edges"`
+	ByKind          map[string]int       `json:"byKind"`
+	ByStatus        map[K8sStatus]int    `json:"byStatus"`
+	NamespaceStatus map[string]K8sStatus `json:"namespaceStatus"`
+	CollapsedPods   int                  `json:"collapsedPods,omitempty"`
+}
+
+// TopologyGraph is the topology of every workload in one or more namespaces
+type TopologyGraph struct {
+	Namespaces []string     `json:"namespaces"`
+	Nodes      []GraphNode  `json:"nodes"`
+	Edges      []GraphEdge  `json:"edges"`
+	Summary    GraphSummary `json:"summary"`
+	Warnings   []string     `json:"warnings,omitempty"`
+}
+
+// GraphOptions selects and shapes a topology graph
+type GraphOptions struct {
+	Namespaces        []string    // empty for all namespaces
+	Kinds             []string    // workload kinds to include, empty for all
+	Statuses          []K8sStatus // only workloads in these states, empty for all
+	LabelSelector     string      // label selector applied to workloads
+	Search            string      // case-insensitive substring of workload names
+	HideKinds         []string    // node kinds to leave out, e.g. Secret or Role
+	CollapseThreshold int         // pods per owner above which they fold into a PodGroup; 0 disables
+}
+
+// NetworkEndpoint identifies one side of a network connection: a workload, a pod or an external IP
+type NetworkEndpoint struct {
+	ID        string            `json:"id,omitempty"` // Graph node ID, empty for external IPs
+	Kind      string            `json:"kind"`         // Deployment, StatefulSet, DaemonSet, Job, CronJob, Pod or External
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// PolicyPeer describes one from/to entry of a NetworkPolicy rule
+type PolicyPeer struct {
+	Type              string   `json:"type"` // all, pods, namespaces, namespacePods or ipBlock
+	Namespace         string   `json:"namespace,omitempty"`
+	PodSelector       string   `json:"podSelector,omitempty"`
+	NamespaceSelector string   `json:"namespaceSelector,omitempty"`
+	CIDR              string   `json:"cidr,omitempty"`
+	Except            []string `json:"except,omitempty"`
+}
+
+// PolicyPort describes one port entry of a NetworkPolicy rule
+type PolicyPort struct {
+	Protocol string `json:"protocol"`
+	Port     string `json:"port,omitempty"` // Empty for all ports
+	EndPort  int32  `json:"endPort,omitempty"`
+}
+
+// NetworkPolicyRule is one ingress or egress rule of a NetworkPolicy selecting the workload
+type NetworkPolicyRule struct {
+	Policy string       `json:"policy"`
+	Peers  []PolicyPeer `json:"peers"`
+	Ports  []PolicyPort `json:"ports,omitempty"`
+}
+
+// ReachablePeer is a workload that can open connections to, or receive them from, the target
+type ReachablePeer struct {
+	NetworkEndpoint
+	Ports []string `json:"ports"` // protocol/port pairs, or "all"
+}
+
+// WorkloadReachability answers who can reach a workload and where it can connect to
+type WorkloadReachability struct {
+	Target             NetworkEndpoint     `json:"target"`
+	IngressIsolated    bool                `json:"ingressIsolated"`
+	EgressIsolated     bool                `json:"egressIsolated"`
+	IngressPolicies    []string            `json:"ingressPolicies,omitempty"`
+	EgressPolicies     []string            `json:"egressPolicies,omitempty"`
+	IngressRules       []NetworkPolicyRule `json:"ingressRules,omitempty"`
+	EgressRules        []NetworkPolicyRule `json:"egressRules,omitempty"`
+	IngressSources     []ReachablePeer     `json:"ingressSources"`
+	EgressDestinations []ReachablePeer     `json:"egressDestinations"`
+}
+
+// PolicyVerdict is the outcome of one direction of a connection check
+type PolicyVerdict struct {
+	Allowed  bool     `json:"allowed"`
+	Isolated bool     `json:"isolated"`           // Some policy selects the endpoint for this direction
+	Policies []string `json:"policies,omitempty"` // Policies whose rules allow the traffic
+	Reason   string   `json:"reason"`
+}
+
+// ReachabilityCheck is the result of checking a single source, destination and port
+type ReachabilityCheck struct {
+	Source      NetworkEndpoint `json:"source"`
+	Destination NetworkEndpoint `json:"destination"`
+	Protocol    string          `json:"protocol"`
+	Port        string          `json:"port,omitempty"`
+	Allowed     bool            `json:"allowed"`
+	Egress      PolicyVerdict   `json:"egress"`  // Evaluated against policies selecting the source
+	Ingress     PolicyVerdict   `json:"ingress"` // Evaluated against policies selecting the destination
+}
+
+// NetworkOverlayNode marks the isolation of a graph node
+type NetworkOverlayNode struct {
+	ID              string   `json:"id"`
+	IngressIsolated bool     `json:"ingressIsolated"`
+	EgressIsolated  bool     `json:"egressIsolated"`
+	Policies        []string `json:"policies,omitempty"`
+}
+
+// NetworkOverlayEdge is allowed traffic between two graph nodes
+type NetworkOverlayEdge struct {
+	ID     string   `json:"id"`
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Ports  []string `json:"ports"`
+}
+
+// NetworkOverlay is NetworkPolicy reachability keyed by TopologyGraph node IDs.
+// Edges are only listed where a policy restricts one side; traffic between two
+// unisolated workloads is always allowed and would otherwise connect every pair.
+type NetworkOverlay struct {
+	Namespaces []string             `json:"namespaces"`
+	Nodes      []NetworkOverlayNode `json:"nodes"`
+	Edges      []NetworkOverlayEdge `json:"edges"`
+	Policies   int                  `json:"policies"`
+}
+
+// SnapshotTarget identifies a workload whose topology is recorded over time
+type SnapshotTarget struct {
+	Context   string `json:"context"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"` // deployment, daemonset, statefulset, job or cronjob
+	Name      string `json:"name"`
+}
+
+// WatchedWorkload reports the recording state of one snapshot target
+type WatchedWorkload struct {
+	SnapshotTarget
+	Interval     string     `json:"interval"`
+	Recording    bool       `json:"recording"` // false once unwatched; history is kept
+	Since        time.Time  `json:"since"`
+	Snapshots    int        `json:"snapshots"`
+	Changes      int        `json:"changes"`
+	LastSnapshot *time.Time `json:"lastSnapshot,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+// TopologySnapshot is the topology of a workload at one point in time. Topology holds
+// the kind-specific response (e.g. DeploymentTopology) and Graph its graph form.
+type TopologySnapshot struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Target    SnapshotTarget  `json:"target"`
+	Topology  json.RawMessage `json:"topology"`
+	Graph     *TopologyGraph  `json:"graph"`
+}
+
+// RecordedChange is a ResourceChange stamped with the time it was recorded
+type RecordedChange struct {
+	RecordedAt time.Time `json:"recordedAt"`
+	ResourceChange
+}
+
+// TopologyAsOf is the latest snapshot at or before a point in time, followed by the
+// changes recorded between that snapshot and the requested time
+type TopologyAsOf struct {
+	Time     time.Time         `json:"time"`
+	Snapshot *TopologySnapshot `json:"snapshot"`
+	Changes  []RecordedChange  `json:"changes"`
+}
+
+// RefDiff lists names added, removed or changed between two snapshots
+type RefDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed,omitempty"` // still referenced, but mounted or used differently
+}
+
+// ServiceRepoint describes a service whose selector or selected owners changed
+type ServiceRepoint struct {
+	Name           string  `json:"name"`
+	SelectorBefore string  `json:"selectorBefore,omitempty"`
+	SelectorAfter  string  `json:"selectorAfter,omitempty"`
+	Targets        RefDiff `json:"targets"` // graph IDs of the controllers owning selected pods
+}
+
+// TopologyDiff is the structural difference of a workload's topology between two snapshots
+type TopologyDiff struct {
+	Target      SnapshotTarget   `json:"target"`
+	From        time.Time        `json:"from"` // timestamps of the snapshots compared
+	To          time.Time        `json:"to"`
+	ReplicaSets RefDiff          `json:"replicaSets"`
+	Pods        RefDiff          `json:"pods"`
+	ConfigMaps  RefDiff          `json:"configMaps"`
+	Secrets     RefDiff          `json:"secrets"`
+	Services    []ServiceRepoint `json:"services"`
+	Changes     []RecordedChange `json:"changes"` // recorded between the two snapshots
+}
+
+// ScalingRef holds the autoscalers and disruption budgets that apply to a workload
+type ScalingRef struct {
+	Autoscalers         []HPARef  `json:"autoscalers,omitempty"`
+	DisruptionBudgets   []PDBRef  `json:"disruptionBudgets,omitempty"`
+	VerticalAutoscalers []VPARef  `json:"verticalAutoscalers,omitempty"` // only when the VPA CRDs are installed
+	Status              K8sStatus `json:"status"`
+}
+
+// HPARef represents a HorizontalPodAutoscaler targeting a workload
+type HPARef struct {
+	Name            string      `json:"name"`
+	MinReplicas     int32       `json:"minReplicas"`
+	MaxReplicas     int32       `json:"maxReplicas"`
+	CurrentReplicas int32       `json:"currentReplicas"`
+	DesiredReplicas int32       `json:"desiredReplicas"`
+	Metrics         []HPAMetric `json:"metrics,omitempty"`
+	Conditions      []Condition `json:"conditions,omitempty"`
+	LastScaleTime   *time.Time  `json:"lastScaleTime,omitempty"`
+	Status          K8sStatus   `json:"status"`
+}
+
+// HPAMetric is one metric an autoscaler scales on, with its target and current value
+type HPAMetric struct {
+	Type    string `json:"type"` // Resource, ContainerResource, Pods, Object or External
+	Name    string `json:"name"`
+	Target  string `json:"target"`            // e.g. 80% or 500m
+	Current string `json:"current,omitempty"` // empty until the metric has been read
+}
+
+// PDBRef represents a PodDisruptionBudget selecting a workload's pods
+type PDBRef struct {
+	Name               string    `json:"name"`
+	MinAvailable       string    `json:"minAvailable,omitempty"`
+	MaxUnavailable     string    `json:"maxUnavailable,omitempty"`
+	CurrentHealthy     int32     `json:"currentHealthy"`
+	DesiredHealthy     int32     `json:"desiredHealthy"`
+	ExpectedPods       int32     `json:"expectedPods"`
+	DisruptionsAllowed int32     `json:"disruptionsAllowed"`
+	Status             K8sStatus `json:"status"`
+}
+
+// VPARef represents a VerticalPodAutoscaler targeting a workload
+type VPARef struct {
+	Name            string              `json:"name"`
+	UpdateMode      string              `json:"updateMode,omitempty"` // Off, Initial, Recreate or Auto
+	Recommendations []VPARecommendation `json:"recommendations,omitempty"`
+	Status          K8sStatus           `json:"status"`
+}
+
+// VPARecommendation is the recommended resources of one container, keyed by resource name
+type VPARecommendation struct {
+	Container      string            `json:"container"`
+	Target         map[string]string `json:"target,omitempty"`
+	LowerBound     map[string]string `json:"lowerBound,omitempty"`
+	UpperBound     map[string]string `json:"upperBound,omitempty"`
+	UncappedTarget map[string]string `json:"uncappedTarget,omitempty"`
+}
@@ -9,11 +9,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	k8smanager "github.com/prasad/kaptivan/backend/internal/kubernetes"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
 var upgrader = websocket.Upgrader{
@@ -32,26 +32,52 @@ type ResourceChange struct {
 }
 
 type TopologyUpdate struct {
-	Changes   []ResourceChange `json:"changes"`
-	Timestamp string           `json:"timestamp"`
+	Type         string               `json:"type,omitempty"` // snapshot, unsubscribed, error; empty for incremental changes
+	Changes      []ResourceChange     `json:"changes"`
+	Subscription *SubscriptionMessage `json:"subscription,omitempty"`
+	Error        string               `json:"error,omitempty"`
+	Timestamp    string               `json:"timestamp"`
+
+	generation uint64 // subscription the update belongs to
 }
 
+// SubscriptionMessage is sent by clients to change what the connection follows.
+// Type is "subscribe" (with a namespace and at most one workload), "unsubscribe" or "refresh".
 type SubscriptionMessage struct {
-	Type       string `json:"type"`
-	Namespace  string `json:"namespace"`
-	Deployment string `json:"deployment,omitempty"`
-	DaemonSet  string `json:"daemonset,omitempty"`
-	Job        string `json:"job,omitempty"`
+	Type        string `json:"type"`
+	Namespace   string `json:"namespace"`
+	Deployment  string `json:"deployment,omitempty"`
+	DaemonSet   string `json:"daemonset,omitempty"`
+	Job         string `json:"job,omitempty"`
+	CronJob     string `json:"cronjob,omitempty"`
+	StatefulSet string `json:"statefulset,omitempty"`
 }
 
-// HandleTopologyWebSocket handles WebSocket connections for real-time topology updates
-func HandleTopologyWebSocket(clientset kubernetes.Interface) gin.HandlerFunc {
+// changeConverter turns a cached resource event into a topology change, or nil to skip it
+type changeConverter func(event k8smanager.ResourceEvent) *ResourceChange
+
+// HandleTopologyWebSocket handles WebSocket connections for real-time topology updates.
+// The connection starts subscribed to the target in the query parameters and can switch
+// targets with subscription messages. Changes come from the cluster's shared informer
+// cache, so every connection shares one watch per resource. metrics may be nil, in which
+// case usage is reported as unavailable.
+func HandleTopologyWebSocket(resources *k8smanager.ResourceCache, metrics MetricsProvider) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clusterContext := c.Query("context")
-		namespace := c.Query("namespace")
-		deployment := c.Query("deployment")
-		daemonset := c.Query("daemonset")
-		job := c.Query("job")
+		initial := SubscriptionMessage{
+			Type:        "subscribe",
+			Namespace:   c.Query("namespace"),
+			Deployment:  c.Query("deployment"),
+			DaemonSet:   c.Query("daemonset"),
+			Job:         c.Query("job"),
+			CronJob:     c.Query("cronjob"),
+			StatefulSet: c.Query("statefulset"),
+		}
+		target, err := targetFromMessage(initial)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
@@ -60,44 +86,20 @@ func HandleTopologyWebSocket(clientset kubernetes.Interface) gin.HandlerFunc {
 		}
 		defer conn.Close()
 
-		log.Printf("WebSocket connected for topology updates: context=%s, namespace=%s, deployment=%s, daemonset=%s, job=%s", 
-			clusterContext, namespace, deployment, daemonset, job)
+		log.Printf("WebSocket connected for topology updates: context=%s, namespace=%s, %s=%s",
+			clusterContext, target.Namespace, target.Kind, target.Name)
 
 		// Create context for cancellation
 		ctx, cancel := context.WithCancel(c.Request.Context())
 		defer cancel()
 
-		// Channel for sending updates to client
-		updates := make(chan TopologyUpdate, 10)
-
-		// Send initial pods first
-		go sendInitialPods(ctx, clientset, namespace, deployment, updates)
-
-		// Start watchers based on resource type
-		if job != "" {
-			// Watch Jobs and their pods
-			go watchJobs(ctx, clientset, namespace, job, updates)
-			go watchPods(ctx, clientset, namespace, "", updates) // Watch all pods in namespace for job
-		} else if daemonset != "" {
-			// Watch DaemonSets and their pods
-			go watchDaemonSets(ctx, clientset, namespace, daemonset, updates)
-			go watchPods(ctx, clientset, namespace, "", updates) // Watch all pods in namespace for daemonset
-		} else {
-			// Watch Deployments and related resources
-			go watchDeployments(ctx, clientset, namespace, updates)
-			go watchPods(ctx, clientset, namespace, deployment, updates)
-			go watchReplicaSets(ctx, clientset, namespace, updates)
-		}
-		
-		// Common watchers for all resource types
-		go watchServices(ctx, clientset, namespace, updates)
-		go watchEndpoints(ctx, clientset, namespace, updates)
-		
-		// Start metrics collection for pods (CPU/Memory updates)
-		go collectPodMetrics(ctx, clientset, namespace, updates)
-
-		// Handle incoming messages from client
+		session := newTopologySession(ctx, resources, metrics)
+
+		// Handle incoming messages from client. The initial subscription is made here
+		// too so it is in place before any client message is handled; streams that fall
+		// behind resync the session from their own goroutines, under its lock.
 		go func() {
+			session.subscribe(target)
 			for {
 				var msg SubscriptionMessage
 				err := conn.ReadJSON(&msg)
@@ -108,28 +110,21 @@ func HandleTopologyWebSocket(clientset kubernetes.Interface) gin.HandlerFunc {
 					cancel()
 					return
 				}
-
-				// Handle different message types
-				switch msg.Type {
-				case "refresh":
-					// Send a full refresh signal
-					log.Printf("Refresh requested for namespace %s", namespace)
-				case "subscribe":
-					log.Printf("Subscription updated: namespace=%s, deployment=%s", msg.Namespace, msg.Deployment)
-				}
+				session.handle(msg)
 			}
 		}()
 
-		// Send updates to client
+		// Send updates to client, dropping any left over from a replaced subscription
 		for {
 			select {
-			case update := <-updates:
-				log.Printf("Sending WebSocket update with %d changes", len(update.Changes))
+			case update := <-session.updates:
+				if !session.current(update) {
+					continue
+				}
 				if err := conn.WriteJSON(update); err != nil {
 					log.Printf("Error sending update: %v", err)
 					return
 				}
-				log.Printf("Successfully sent WebSocket update")
 			case <-ctx.Done():
 				log.Printf("WebSocket context cancelled")
 				return
@@ -138,684 +133,516 @@ func HandleTopologyWebSocket(clientset kubernetes.Interface) gin.HandlerFunc {
 	}
 }
 
-func sendInitialPods(ctx context.Context, clientset kubernetes.Interface, namespace string, deploymentName string, updates chan<- TopologyUpdate) {
-	// Create label selector if deployment is specified
-	var labelSelector string
-	if deploymentName != "" {
-		labelSelector = fmt.Sprintf("app=%s", deploymentName)
+// namedChange limits a converter to objects with the given name, when set
+func namedChange(name string, convert changeConverter) changeConverter {
+	if name == "" {
+		return convert
 	}
-
-	log.Printf("Fetching initial pods for namespace: %s, deployment: %s", namespace, deploymentName)
-
-	// List all existing pods
-	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
-
-	if err != nil {
-		log.Printf("Failed to list initial pods: %v", err)
-		return
+	return func(event k8smanager.ResourceEvent) *ResourceChange {
+		change := convert(event)
+		if change == nil || change.ResourceID != name {
+			return nil
+		}
+		return change
 	}
+}
 
-	log.Printf("Found %d existing pods in namespace %s", len(pods.Items), namespace)
+func deploymentChange(event k8smanager.ResourceEvent) *ResourceChange {
+	deployment, ok := event.Object.(*appsv1.Deployment)
+	if !ok {
+		return nil
+	}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "deployment",
+		ResourceID:   deployment.Name,
+		Namespace:    deployment.Namespace,
+		Data: map[string]interface{}{
+			"name":      deployment.Name,
+			"namespace": deployment.Namespace,
+			"replicas":  deployment.Spec.Replicas,
+			"available": deployment.Status.AvailableReplicas,
+			"ready":     deployment.Status.ReadyReplicas,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-	// Send each pod as an ADDED event
-	for _, pod := range pods.Items {
-		// Extract owner ReplicaSet information
-		var ownerReplicaSet string
-		for _, owner := range pod.OwnerReferences {
-			if owner.Kind == "ReplicaSet" {
-				ownerReplicaSet = owner.Name
-				break
-			}
+// podChange converts pod events, keeping only pods accepted by filter when set.
+// Deletions are always forwarded so pods of a deleted or re-labelled workload do not linger.
+func podChange(filter func(pod *v1.Pod) bool) changeConverter {
+	return func(event k8smanager.ResourceEvent) *ResourceChange {
+		pod, ok := event.Object.(*v1.Pod)
+		if !ok {
+			return nil
 		}
-
-		// Extract container info with resources
-		containers := []map[string]interface{}{}
-		for _, container := range pod.Spec.Containers {
-			containerInfo := map[string]interface{}{
-				"name":  container.Name,
-				"image": container.Image,
-				"ready": false,
-			}
-
-			// Add resource requirements
-			if container.Resources.Requests != nil || container.Resources.Limits != nil {
-				resources := map[string]interface{}{}
-				if container.Resources.Requests != nil {
-					requests := map[string]string{}
-					if cpu := container.Resources.Requests.Cpu(); cpu != nil {
-						requests["cpu"] = cpu.String()
-					}
-					if mem := container.Resources.Requests.Memory(); mem != nil {
-						requests["memory"] = mem.String()
-					}
-					resources["requests"] = requests
-				}
-				if container.Resources.Limits != nil {
-					limits := map[string]string{}
-					if cpu := container.Resources.Limits.Cpu(); cpu != nil {
-						limits["cpu"] = cpu.String()
-					}
-					if mem := container.Resources.Limits.Memory(); mem != nil {
-						limits["memory"] = mem.String()
-					}
-					resources["limits"] = limits
-				}
-				containerInfo["resources"] = resources
-			}
-
-			// Check container status
-			for _, status := range pod.Status.ContainerStatuses {
-				if status.Name == container.Name {
-					containerInfo["ready"] = status.Ready
-					containerInfo["restartCount"] = status.RestartCount
-					if status.State.Running != nil {
-						containerInfo["state"] = "running"
-						containerInfo["startTime"] = status.State.Running.StartedAt.Time
-					} else if status.State.Waiting != nil {
-						containerInfo["state"] = "waiting"
-					} else if status.State.Terminated != nil {
-						containerInfo["state"] = "terminated"
-						containerInfo["startTime"] = status.State.Terminated.StartedAt.Time
-					}
-					break
-				}
-			}
-
-			containers = append(containers, containerInfo)
+		if filter != nil && event.Type != watch.Deleted && !filter(pod) {
+			return nil
 		}
-
-		// Calculate pod age
-		age := "Unknown"
-		if !pod.CreationTimestamp.IsZero() {
-			age = time.Since(pod.CreationTimestamp.Time).Round(time.Second).String()
+		return &ResourceChange{
+			Type:         string(event.Type),
+			ResourceType: "pod",
+			ResourceID:   pod.Name,
+			Namespace:    pod.Namespace, // Use pod's actual namespace, not the parameter
+			Data:         podChangeData(pod),
+			Timestamp:    time.Now().Format(time.RFC3339),
 		}
+	}
+}
 
-		// Calculate ready state (e.g., "1/1" or "0/1")
-		readyContainers := 0
-		totalContainers := len(pod.Status.ContainerStatuses)
-		for _, status := range pod.Status.ContainerStatuses {
-			if status.Ready {
-				readyContainers++
-			}
+// podChangeData builds the pod payload shared by the initial snapshot and updates
+func podChangeData(pod *v1.Pod) map[string]interface{} {
+	// Extract owner ReplicaSet information
+	var ownerReplicaSet string
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "ReplicaSet" {
+			ownerReplicaSet = owner.Name
+			break
 		}
-		ready := fmt.Sprintf("%d/%d", readyContainers, totalContainers)
+	}
 
-		// Get total restart count
-		restartCount := int32(0)
-		for _, status := range pod.Status.ContainerStatuses {
-			restartCount += status.RestartCount
+	// Extract container info with resources
+	containers := []map[string]interface{}{}
+	for _, container := range pod.Spec.Containers {
+		containerInfo := map[string]interface{}{
+			"name":  container.Name,
+			"image": container.Image,
+			"ready": false,
 		}
 
-		// Calculate total CPU and memory requests/limits from containers
-		var cpuRequestSum, cpuLimitSum, memRequestSum, memLimitSum int64
-
-		for _, container := range pod.Spec.Containers {
-			// Sum requests
+		// Add resource requirements
+		if container.Resources.Requests != nil || container.Resources.Limits != nil {
+			resources := map[string]interface{}{}
 			if container.Resources.Requests != nil {
+				requests := map[string]string{}
 				if cpu := container.Resources.Requests.Cpu(); cpu != nil {
-					cpuRequestSum += cpu.MilliValue()
+					requests["cpu"] = cpu.String()
 				}
 				if mem := container.Resources.Requests.Memory(); mem != nil {
-					memRequestSum += mem.Value()
+					requests["memory"] = mem.String()
 				}
+				resources["requests"] = requests
 			}
-			// Sum limits
 			if container.Resources.Limits != nil {
+				limits := map[string]string{}
 				if cpu := container.Resources.Limits.Cpu(); cpu != nil {
-					cpuLimitSum += cpu.MilliValue()
+					limits["cpu"] = cpu.String()
 				}
 				if mem := container.Resources.Limits.Memory(); mem != nil {
-					memLimitSum += mem.Value()
+					limits["memory"] = mem.String()
 				}
+				resources["limits"] = limits
 			}
+			containerInfo["resources"] = resources
 		}
 
-		// Format CPU in requests/limits format
-		var totalCPU string
-		if cpuRequestSum > 0 || cpuLimitSum > 0 {
-			requestStr := "0"
-			limitStr := "-"
-			if cpuRequestSum > 0 {
-				requestStr = fmt.Sprintf("%dm", cpuRequestSum)
-			}
-			if cpuLimitSum > 0 {
-				limitStr = fmt.Sprintf("%dm", cpuLimitSum)
+		// Check container status
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == container.Name {
+				containerInfo["ready"] = status.Ready
+				containerInfo["restartCount"] = status.RestartCount
+				if status.State.Running != nil {
+					containerInfo["state"] = "running"
+					containerInfo["startTime"] = status.State.Running.StartedAt.Time
+				} else if status.State.Waiting != nil {
+					containerInfo["state"] = "waiting"
+				} else if status.State.Terminated != nil {
+					containerInfo["state"] = "terminated"
+					containerInfo["startTime"] = status.State.Terminated.StartedAt.Time
+				}
+				break
 			}
-			totalCPU = fmt.Sprintf("%s/%s", requestStr, limitStr)
-		} else {
-			totalCPU = "-/-"
 		}
 
-		// Format memory in requests/limits format
-		var totalMemory string
-		if memRequestSum > 0 || memLimitSum > 0 {
-			requestStr := "0"
-			limitStr := "-"
-			if memRequestSum > 0 {
-				requestStr = fmt.Sprintf("%dMi", memRequestSum/(1024*1024))
-			}
-			if memLimitSum > 0 {
-				limitStr = fmt.Sprintf("%dMi", memLimitSum/(1024*1024))
-			}
-			totalMemory = fmt.Sprintf("%s/%s", requestStr, limitStr)
-		} else {
-			totalMemory = "-/-"
-		}
+		containers = append(containers, containerInfo)
+	}
 
-		change := ResourceChange{
-			Type:         "ADDED",
-			ResourceType: "pod",
-			ResourceID:   pod.Name,
-			Namespace:    pod.Namespace,
-			Data: map[string]interface{}{
-				"name":               pod.Name,
-				"namespace":          pod.Namespace,
-				"phase":              pod.Status.Phase,
-				"podIP":              pod.Status.PodIP,
-				"hostIP":             pod.Status.HostIP,
-				"nodeName":           pod.Spec.NodeName,
-				"containers":         containers,
-				"ownerReplicaSet":    ownerReplicaSet,
-				"age":                age,
-				"ready":              ready,
-				"restartCount":       restartCount,
-				"cpu":                totalCPU,
-				"memory":             totalMemory,
-			},
-			Timestamp: time.Now().Format(time.RFC3339),
-		}
+	// Calculate pod age
+	age := "Unknown"
+	if !pod.CreationTimestamp.IsZero() {
+		age = time.Since(pod.CreationTimestamp.Time).Round(time.Second).String()
+	}
 
-		updates <- TopologyUpdate{
-			Changes:   []ResourceChange{change},
-			Timestamp: time.Now().Format(time.RFC3339),
+	// Calculate ready state (e.g., "1/1" or "0/1") and total restarts
+	readyContainers := 0
+	restartCount := int32(0)
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Ready {
+			readyContainers++
 		}
+		restartCount += status.RestartCount
 	}
+	ready := fmt.Sprintf("%d/%d", readyContainers, len(pod.Status.ContainerStatuses))
 
-	log.Printf("Sent %d initial pods to client", len(pods.Items))
-}
-
-func watchDeployments(ctx context.Context, clientset kubernetes.Interface, namespace string, updates chan<- TopologyUpdate) {
-	log.Printf("Starting deployment watcher for namespace: %s", namespace)
-	watcher, err := clientset.AppsV1().Deployments(namespace).Watch(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to watch deployments: %v", err)
-		return
-	}
-	defer watcher.Stop()
-
-	log.Printf("Deployment watcher started successfully for namespace: %s", namespace)
-	for event := range watcher.ResultChan() {
-		log.Printf("Deployment event: %s for %v", event.Type, event.Object)
-		if deployment, ok := event.Object.(*appsv1.Deployment); ok {
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "deployment",
-				ResourceID:   deployment.Name,
-				Namespace:    deployment.Namespace,
-				Data: map[string]interface{}{
-					"name":      deployment.Name,
-					"namespace": deployment.Namespace,
-					"replicas":  deployment.Spec.Replicas,
-					"available": deployment.Status.AvailableReplicas,
-					"ready":     deployment.Status.ReadyReplicas,
-				},
-				Timestamp: time.Now().Format(time.RFC3339),
+	// Calculate total CPU and memory requests/limits from containers
+	var cpuRequestSum, cpuLimitSum, memRequestSum, memLimitSum int64
+	for _, container := range pod.Spec.Containers {
+		if container.Resources.Requests != nil {
+			if cpu := container.Resources.Requests.Cpu(); cpu != nil {
+				cpuRequestSum += cpu.MilliValue()
 			}
-
-			updates <- TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
+			if mem := container.Resources.Requests.Memory(); mem != nil {
+				memRequestSum += mem.Value()
+			}
+		}
+		if container.Resources.Limits != nil {
+			if cpu := container.Resources.Limits.Cpu(); cpu != nil {
+				cpuLimitSum += cpu.MilliValue()
+			}
+			if mem := container.Resources.Limits.Memory(); mem != nil {
+				memLimitSum += mem.Value()
 			}
-			log.Printf("Sent deployment update to channel: %s", deployment.Name)
 		}
 	}
-	log.Printf("Deployment watcher stopped for namespace: %s", namespace)
+
+	return map[string]interface{}{
+		"name":            pod.Name,
+		"namespace":       pod.Namespace,
+		"phase":           pod.Status.Phase,
+		"podIP":           pod.Status.PodIP,
+		"hostIP":          pod.Status.HostIP,
+		"nodeName":        pod.Spec.NodeName,
+		"containers":      containers,
+		"ownerReplicaSet": ownerReplicaSet,
+		"age":             age,
+		"ready":           ready,
+		"restartCount":    restartCount,
+		"cpu":             formatRequestLimit(cpuRequestSum, cpuLimitSum, "m", 1),
+		"memory":          formatRequestLimit(memRequestSum, memLimitSum, "Mi", 1024*1024),
+	}
 }
 
-func watchPods(ctx context.Context, clientset kubernetes.Interface, namespace string, deploymentName string, updates chan<- TopologyUpdate) {
-	// Create label selector if deployment is specified
-	var labelSelector string
-	if deploymentName != "" {
-		labelSelector = fmt.Sprintf("app=%s", deploymentName)
-	}
-
-	log.Printf("Starting pod watcher for namespace: %s, deployment: %s, selector: %s", namespace, deploymentName, labelSelector)
-	watcher, err := clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
-	if err != nil {
-		log.Printf("Failed to watch pods: %v", err)
-		return
-	}
-	defer watcher.Stop()
-
-	log.Printf("Pod watcher started successfully for namespace: %s", namespace)
-	for event := range watcher.ResultChan() {
-		log.Printf("Pod event: %s for %v", event.Type, event.Object)
-		if pod, ok := event.Object.(*v1.Pod); ok {
-			// Extract owner ReplicaSet information
-			var ownerReplicaSet string
-			for _, owner := range pod.OwnerReferences {
-				if owner.Kind == "ReplicaSet" {
-					ownerReplicaSet = owner.Name
-					break
-				}
-			}
+// formatRequestLimit formats summed requests/limits as "request/limit", e.g. "250m/500m"
+func formatRequestLimit(request, limit int64, unit string, divisor int64) string {
+	if request == 0 && limit == 0 {
+		return "-/-"
+	}
+	requestStr := "0"
+	limitStr := "-"
+	if request > 0 {
+		requestStr = fmt.Sprintf("%d%s", request/divisor, unit)
+	}
+	if limit > 0 {
+		limitStr = fmt.Sprintf("%d%s", limit/divisor, unit)
+	}
+	return fmt.Sprintf("%s/%s", requestStr, limitStr)
+}
 
-			// Extract container info with resources
-			containers := []map[string]interface{}{}
-			for _, container := range pod.Spec.Containers {
-				containerInfo := map[string]interface{}{
-					"name":  container.Name,
-					"image": container.Image,
-					"ready": false,
-				}
+func serviceChange(event k8smanager.ResourceEvent) *ResourceChange {
+	service, ok := event.Object.(*v1.Service)
+	if !ok {
+		return nil
+	}
+	ports := []map[string]interface{}{}
+	for _, port := range service.Spec.Ports {
+		ports = append(ports, map[string]interface{}{
+			"port":       port.Port,
+			"targetPort": port.TargetPort.IntVal,
+			"protocol":   port.Protocol,
+		})
+	}
 
-				// Add resource requirements
-				if container.Resources.Requests != nil || container.Resources.Limits != nil {
-					resources := map[string]interface{}{}
-					if container.Resources.Requests != nil {
-						requests := map[string]string{}
-						if cpu := container.Resources.Requests.Cpu(); cpu != nil {
-							requests["cpu"] = cpu.String()
-						}
-						if mem := container.Resources.Requests.Memory(); mem != nil {
-							requests["memory"] = mem.String()
-						}
-						resources["requests"] = requests
-					}
-					if container.Resources.Limits != nil {
-						limits := map[string]string{}
-						if cpu := container.Resources.Limits.Cpu(); cpu != nil {
-							limits["cpu"] = cpu.String()
-						}
-						if mem := container.Resources.Limits.Memory(); mem != nil {
-							limits["memory"] = mem.String()
-						}
-						resources["limits"] = limits
-					}
-					containerInfo["resources"] = resources
-				}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "service",
+		ResourceID:   service.Name,
+		Namespace:    service.Namespace,
+		Data: map[string]interface{}{
+			"name":      service.Name,
+			"namespace": service.Namespace,
+			"type":      service.Spec.Type,
+			"clusterIP": service.Spec.ClusterIP,
+			"ports":     ports,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-				// Check container status
-				for _, status := range pod.Status.ContainerStatuses {
-					if status.Name == container.Name {
-						containerInfo["ready"] = status.Ready
-						containerInfo["restartCount"] = status.RestartCount
-						if status.State.Running != nil {
-							containerInfo["state"] = "running"
-							containerInfo["startTime"] = status.State.Running.StartedAt.Time
-						} else if status.State.Waiting != nil {
-							containerInfo["state"] = "waiting"
-						} else if status.State.Terminated != nil {
-							containerInfo["state"] = "terminated"
-							containerInfo["startTime"] = status.State.Terminated.StartedAt.Time
-						}
-						break
-					}
-				}
+func endpointsChange(event k8smanager.ResourceEvent) *ResourceChange {
+	endpoints, ok := event.Object.(*v1.Endpoints)
+	if !ok {
+		return nil
+	}
+	addresses := []string{}
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			addresses = append(addresses, addr.IP)
+		}
+	}
 
-				containers = append(containers, containerInfo)
-			}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "endpoints",
+		ResourceID:   endpoints.Name,
+		Namespace:    endpoints.Namespace,
+		Data: map[string]interface{}{
+			"name":      endpoints.Name,
+			"namespace": endpoints.Namespace,
+			"addresses": addresses,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-			// Calculate pod age
-			age := "Unknown"
-			if !pod.CreationTimestamp.IsZero() {
-				age = time.Since(pod.CreationTimestamp.Time).Round(time.Second).String()
-			}
+func replicaSetChange(event k8smanager.ResourceEvent) *ResourceChange {
+	rs, ok := event.Object.(*appsv1.ReplicaSet)
+	if !ok {
+		return nil
+	}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "replicaset",
+		ResourceID:   rs.Name,
+		Namespace:    rs.Namespace,
+		Data: map[string]interface{}{
+			"name":      rs.Name,
+			"namespace": rs.Namespace,
+			"replicas":  rs.Spec.Replicas,
+			"ready":     rs.Status.ReadyReplicas,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-			// Calculate ready state (e.g., "1/1" or "0/1")
-			readyContainers := 0
-			totalContainers := len(pod.Status.ContainerStatuses)
-			for _, status := range pod.Status.ContainerStatuses {
-				if status.Ready {
-					readyContainers++
-				}
-			}
-			ready := fmt.Sprintf("%d/%d", readyContainers, totalContainers)
+func jobChange(event k8smanager.ResourceEvent) *ResourceChange {
+	job, ok := event.Object.(*batchv1.Job)
+	if !ok {
+		return nil
+	}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "job",
+		ResourceID:   job.Name,
+		Namespace:    job.Namespace,
+		Data: map[string]interface{}{
+			"name":        job.Name,
+			"namespace":   job.Namespace,
+			"completions": job.Spec.Completions,
+			"parallelism": job.Spec.Parallelism,
+			"active":      job.Status.Active,
+			"succeeded":   job.Status.Succeeded,
+			"failed":      job.Status.Failed,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-			// Get total restart count
-			restartCount := int32(0)
-			for _, status := range pod.Status.ContainerStatuses {
-				restartCount += status.RestartCount
-			}
+func cronJobChange(event k8smanager.ResourceEvent) *ResourceChange {
+	cj, ok := event.Object.(*batchv1.CronJob)
+	if !ok {
+		return nil
+	}
+	suspended := cj.Spec.Suspend != nil && *cj.Spec.Suspend
+	var lastSchedule interface{}
+	if cj.Status.LastScheduleTime != nil {
+		lastSchedule = cj.Status.LastScheduleTime.Time
+	}
 
-			// Calculate total CPU and memory requests/limits from containers
-			var cpuRequestSum, cpuLimitSum, memRequestSum, memLimitSum int64
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "cronjob",
+		ResourceID:   cj.Name,
+		Namespace:    cj.Namespace,
+		Data: map[string]interface{}{
+			"name":             cj.Name,
+			"namespace":        cj.Namespace,
+			"schedule":         cj.Spec.Schedule,
+			"suspend":          suspended,
+			"active":           len(cj.Status.Active),
+			"lastScheduleTime": lastSchedule,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-			for _, container := range pod.Spec.Containers {
-				// Sum requests
-				if container.Resources.Requests != nil {
-					if cpu := container.Resources.Requests.Cpu(); cpu != nil {
-						cpuRequestSum += cpu.MilliValue()
-					}
-					if mem := container.Resources.Requests.Memory(); mem != nil {
-						memRequestSum += mem.Value()
-					}
-				}
-				// Sum limits
-				if container.Resources.Limits != nil {
-					if cpu := container.Resources.Limits.Cpu(); cpu != nil {
-						cpuLimitSum += cpu.MilliValue()
-					}
-					if mem := container.Resources.Limits.Memory(); mem != nil {
-						memLimitSum += mem.Value()
-					}
-				}
-			}
+func daemonSetChange(event k8smanager.ResourceEvent) *ResourceChange {
+	ds, ok := event.Object.(*appsv1.DaemonSet)
+	if !ok {
+		return nil
+	}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "daemonset",
+		ResourceID:   ds.Name,
+		Namespace:    ds.Namespace,
+		Data: map[string]interface{}{
+			"name":          ds.Name,
+			"namespace":     ds.Namespace,
+			"desiredNumber": ds.Status.DesiredNumberScheduled,
+			"currentNumber": ds.Status.CurrentNumberScheduled,
+			"readyNumber":   ds.Status.NumberReady,
+			"updatedNumber": ds.Status.UpdatedNumberScheduled,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-			// Format CPU in requests/limits format
-			var totalCPU string
-			if cpuRequestSum > 0 || cpuLimitSum > 0 {
-				requestStr := "0"
-				limitStr := "-"
-				if cpuRequestSum > 0 {
-					requestStr = fmt.Sprintf("%dm", cpuRequestSum)
-				}
-				if cpuLimitSum > 0 {
-					limitStr = fmt.Sprintf("%dm", cpuLimitSum)
-				}
-				totalCPU = fmt.Sprintf("%s/%s", requestStr, limitStr)
-			} else {
-				totalCPU = "-/-"
-			}
+func statefulSetChange(event k8smanager.ResourceEvent) *ResourceChange {
+	sts, ok := event.Object.(*appsv1.StatefulSet)
+	if !ok {
+		return nil
+	}
+	var partition int32
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		partition = *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
 
-			// Format memory in requests/limits format
-			var totalMemory string
-			if memRequestSum > 0 || memLimitSum > 0 {
-				requestStr := "0"
-				limitStr := "-"
-				if memRequestSum > 0 {
-					requestStr = fmt.Sprintf("%dMi", memRequestSum/(1024*1024))
-				}
-				if memLimitSum > 0 {
-					limitStr = fmt.Sprintf("%dMi", memLimitSum/(1024*1024))
-				}
-				totalMemory = fmt.Sprintf("%s/%s", requestStr, limitStr)
-			} else {
-				totalMemory = "-/-"
-			}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "statefulset",
+		ResourceID:   sts.Name,
+		Namespace:    sts.Namespace,
+		Data: map[string]interface{}{
+			"name":            sts.Name,
+			"namespace":       sts.Namespace,
+			"replicas":        sts.Spec.Replicas,
+			"ready":           sts.Status.ReadyReplicas,
+			"current":         sts.Status.CurrentReplicas,
+			"updated":         sts.Status.UpdatedReplicas,
+			"partition":       partition,
+			"currentRevision": sts.Status.CurrentRevision,
+			"updateRevision":  sts.Status.UpdateRevision,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
 
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "pod",
-				ResourceID:   pod.Name,
-				Namespace:    pod.Namespace, // Use pod's actual namespace, not the parameter
-				Data: map[string]interface{}{
-					"name":               pod.Name,
-					"namespace":          pod.Namespace, // Also include namespace in data
-					"phase":              pod.Status.Phase,
-					"podIP":              pod.Status.PodIP,
-					"hostIP":             pod.Status.HostIP,
-					"nodeName":           pod.Spec.NodeName,
-					"containers":         containers,
-					"ownerReplicaSet":    ownerReplicaSet, // Include owner ReplicaSet information
-					"age":                age,
-					"ready":              ready,
-					"restartCount":       restartCount,
-					"cpu":                totalCPU,
-					"memory":             totalMemory,
-				},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
+func persistentVolumeClaimChange(event k8smanager.ResourceEvent) *ResourceChange {
+	pvc, ok := event.Object.(*v1.PersistentVolumeClaim)
+	if !ok {
+		return nil
+	}
+	capacity := ""
+	if storage, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok {
+		capacity = storage.String()
+	}
 
-			updates <- TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
-			// Debug: log container resources
-			for _, c := range containers {
-				if resources, ok := c["resources"].(map[string]interface{}); ok {
-					log.Printf("  Container %s resources: %+v", c["name"], resources)
-				}
-			}
-			log.Printf("Sent pod update to channel: %s (type: %s, owner: %s)", pod.Name, event.Type, ownerReplicaSet)
-		}
+	return &ResourceChange{
+		Type:         string(event.Type),
+		ResourceType: "persistentvolumeclaim",
+		ResourceID:   pvc.Name,
+		Namespace:    pvc.Namespace,
+		Data: map[string]interface{}{
+			"name":         pvc.Name,
+			"namespace":    pvc.Namespace,
+			"phase":        pvc.Status.Phase,
+			"volumeName":   pvc.Spec.VolumeName,
+			"storageClass": pvc.Spec.StorageClassName,
+			"capacity":     capacity,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
 	}
-	log.Printf("Pod watcher stopped for namespace: %s", namespace)
 }
 
-func watchServices(ctx context.Context, clientset kubernetes.Interface, namespace string, updates chan<- TopologyUpdate) {
-	watcher, err := clientset.CoreV1().Services(namespace).Watch(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to watch services: %v", err)
-		return
-	}
-	defer watcher.Stop()
-
-	for event := range watcher.ResultChan() {
-		if service, ok := event.Object.(*v1.Service); ok {
-			ports := []map[string]interface{}{}
-			for _, port := range service.Spec.Ports {
-				ports = append(ports, map[string]interface{}{
-					"port":       port.Port,
-					"targetPort": port.TargetPort.IntVal,
-					"protocol":   port.Protocol,
-				})
-			}
+// collectPodMetrics periodically streams per-container CPU and memory usage from
+// metrics.k8s.io as "METRICS" pod changes for the pods accepted by filter (nil for
+// every pod in the namespace). When the metrics API is missing a single
+// "UNAVAILABLE" metrics change is sent and collection resumes if it appears later.
+func collectPodMetrics(ctx context.Context, resources *k8smanager.ResourceCache, metrics MetricsProvider, namespace string, filter func(pod *v1.Pod) bool, generation uint64, updates chan<- TopologyUpdate) {
+	ticker := time.NewTicker(10 * time.Second) // Update metrics every 10 seconds
+	defer ticker.Stop()
 
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "service",
-				ResourceID:   service.Name,
-				Namespace:    service.Namespace,
-				Data: map[string]interface{}{
-					"name":      service.Name,
-					"namespace": service.Namespace,
-					"type":      service.Spec.Type,
-					"clusterIP": service.Spec.ClusterIP,
-					"ports":     ports,
-				},
-				Timestamp: time.Now().Format(time.RFC3339),
+	reportedUnavailable := false
+	collect := func() {
+		if metrics == nil || !metrics.Available(ctx) {
+			if !reportedUnavailable {
+				reportedUnavailable = true
+				sendMetricsUnavailable(ctx, namespace, generation, updates)
 			}
+			return
+		}
+		reportedUnavailable = false
 
-			updates <- TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
+		usage, err := metrics.PodMetrics(ctx, namespace)
+		if err != nil {
+			log.Printf("Failed to collect pod metrics: %v", err)
+			return
 		}
-	}
-}
 
-func watchEndpoints(ctx context.Context, clientset kubernetes.Interface, namespace string, updates chan<- TopologyUpdate) {
-	watcher, err := clientset.CoreV1().Endpoints(namespace).Watch(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to watch endpoints: %v", err)
-		return
-	}
-	defer watcher.Stop()
+		pods, err := k8smanager.ListAs[*v1.Pod](resources, "pods", namespace)
+		if err != nil {
+			log.Printf("Failed to list pods for metrics: %v", err)
+			return
+		}
 
-	for event := range watcher.ResultChan() {
-		if endpoints, ok := event.Object.(*v1.Endpoints); ok {
-			addresses := []string{}
-			for _, subset := range endpoints.Subsets {
-				for _, addr := range subset.Addresses {
-					addresses = append(addresses, addr.IP)
-				}
+		now := time.Now().Format(time.RFC3339)
+		changes := []ResourceChange{}
+		for _, pod := range pods {
+			if filter != nil && !filter(pod) {
+				continue
 			}
-
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "endpoints",
-				ResourceID:   endpoints.Name,
-				Namespace:    endpoints.Namespace,
-				Data: map[string]interface{}{
-					"name":      endpoints.Name,
-					"namespace": endpoints.Namespace,
-					"addresses": addresses,
-				},
-				Timestamp: time.Now().Format(time.RFC3339),
+			podUsage, ok := usage[podUsageKey(pod.Namespace, pod.Name)]
+			if !ok {
+				continue
 			}
 
-			updates <- TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
+			containers := []map[string]interface{}{}
+			var cpuTotal, memoryTotal int64
+			for _, container := range pod.Spec.Containers {
+				containerUsage, ok := podUsage.Containers[container.Name]
+				if !ok {
+					continue
+				}
+				cpuTotal += containerUsage.CPUMillicores
+				memoryTotal += containerUsage.MemoryBytes
+				containers = append(containers, map[string]interface{}{
+					"name":  container.Name,
+					"usage": buildContainerUsage(container, containerUsage, podUsage.Timestamp),
+				})
 			}
-		}
-	}
-}
 
-func watchReplicaSets(ctx context.Context, clientset kubernetes.Interface, namespace string, updates chan<- TopologyUpdate) {
-	watcher, err := clientset.AppsV1().ReplicaSets(namespace).Watch(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to watch replicasets: %v", err)
-		return
-	}
-	defer watcher.Stop()
-
-	for event := range watcher.ResultChan() {
-		if rs, ok := event.Object.(*appsv1.ReplicaSet); ok {
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "replicaset",
-				ResourceID:   rs.Name,
-				Namespace:    rs.Namespace,
+			changes = append(changes, ResourceChange{
+				Type:         "METRICS",
+				ResourceType: "pod",
+				ResourceID:   pod.Name,
+				Namespace:    pod.Namespace,
 				Data: map[string]interface{}{
-					"name":      rs.Name,
-					"namespace": rs.Namespace,
-					"replicas": rs.Spec.Replicas,
-					"ready":    rs.Status.ReadyReplicas,
+					"name":          pod.Name,
+					"namespace":     pod.Namespace,
+					"containers":    containers,
+					"cpuMillicores": cpuTotal,
+					"memoryBytes":   memoryTotal,
 				},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
+				Timestamp: now,
+			})
+		}
 
-			updates <- TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
+		if len(changes) == 0 {
+			return
+		}
+		select {
+		case updates <- TopologyUpdate{Changes: changes, Timestamp: now, generation: generation}:
+		case <-ctx.Done():
 		}
 	}
-}
-
-// collectPodMetrics periodically collects CPU and memory metrics for pods
-func collectPodMetrics(ctx context.Context, clientset kubernetes.Interface, namespace string, updates chan<- TopologyUpdate) {
-	ticker := time.NewTicker(10 * time.Second) // Update metrics every 10 seconds
-	defer ticker.Stop()
 
+	collect()
 	for {
 		select {
 		case <-ticker.C:
-			// Get pod metrics from metrics server
-			// This requires the metrics-server to be installed in the cluster
-			// For now, we'll send a placeholder update
-			// In production, you'd use the metrics client to get actual CPU/memory usage
-			
+			collect()
 		case <-ctx.Done():
 			return
 		}
 	}
 }
-// watchJobs watches for changes to Jobs in the namespace
-func watchJobs(ctx context.Context, clientset kubernetes.Interface, namespace, jobName string, updates chan<- TopologyUpdate) {
-	log.Printf("Starting job watcher for namespace: %s, job: %s", namespace, jobName)
-	
-	listOptions := metav1.ListOptions{}
-	if jobName != "" {
-		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", jobName)
-	}
-	
-	watcher, err := clientset.BatchV1().Jobs(namespace).Watch(ctx, listOptions)
-	if err != nil {
-		log.Printf("Failed to watch jobs: %v", err)
-		return
-	}
-	defer watcher.Stop()
-
-	log.Printf("Job watcher started successfully for namespace: %s", namespace)
-	
-	for event := range watcher.ResultChan() {
-		if job, ok := event.Object.(*batchv1.Job); ok {
-			log.Printf("Job event: %s for %s", event.Type, job.Name)
-			
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "job",
-				ResourceID:   job.Name,
-				Namespace:    job.Namespace,
-				Data: map[string]interface{}{
-					"name":        job.Name,
-					"namespace":   job.Namespace,
-					"completions": job.Spec.Completions,
-					"parallelism": job.Spec.Parallelism,
-					"active":      job.Status.Active,
-					"succeeded":   job.Status.Succeeded,
-					"failed":      job.Status.Failed,
-				},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
 
-			update := TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
-
-			log.Printf("Sending WebSocket update with %d changes", len(update.Changes))
-			select {
-			case updates <- update:
-				log.Printf("Successfully sent WebSocket update")
-			case <-ctx.Done():
-				return
-			}
-		}
+// sendMetricsUnavailable tells the client usage is not available for this cluster
+func sendMetricsUnavailable(ctx context.Context, namespace string, generation uint64, updates chan<- TopologyUpdate) {
+	now := time.Now().Format(time.RFC3339)
+	change := ResourceChange{
+		Type:         "UNAVAILABLE",
+		ResourceType: "metrics",
+		ResourceID:   metricsGroupVersion,
+		Namespace:    namespace,
+		Data: map[string]interface{}{
+			"message": "metrics.k8s.io API is not available; install metrics-server to see live usage",
+		},
+		Timestamp: now,
 	}
-	
-	log.Printf("Job watcher stopped for namespace: %s", namespace)
-}
-
-// watchDaemonSets watches for changes to DaemonSets in the namespace
-func watchDaemonSets(ctx context.Context, clientset kubernetes.Interface, namespace, daemonsetName string, updates chan<- TopologyUpdate) {
-	log.Printf("Starting daemonset watcher for namespace: %s, daemonset: %s", namespace, daemonsetName)
-	
-	listOptions := metav1.ListOptions{}
-	if daemonsetName != "" {
-		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", daemonsetName)
-	}
-	
-	watcher, err := clientset.AppsV1().DaemonSets(namespace).Watch(ctx, listOptions)
-	if err != nil {
-		log.Printf("Failed to watch daemonsets: %v", err)
-		return
-	}
-	defer watcher.Stop()
-
-	log.Printf("DaemonSet watcher started successfully for namespace: %s", namespace)
-	
-	for event := range watcher.ResultChan() {
-		if ds, ok := event.Object.(*appsv1.DaemonSet); ok {
-			log.Printf("DaemonSet event: %s for %s", event.Type, ds.Name)
-			
-			change := ResourceChange{
-				Type:         string(event.Type),
-				ResourceType: "daemonset",
-				ResourceID:   ds.Name,
-				Namespace:    ds.Namespace,
-				Data: map[string]interface{}{
-					"name":           ds.Name,
-					"namespace":      ds.Namespace,
-					"desiredNumber":  ds.Status.DesiredNumberScheduled,
-					"currentNumber":  ds.Status.CurrentNumberScheduled,
-					"readyNumber":    ds.Status.NumberReady,
-					"updatedNumber":  ds.Status.UpdatedNumberScheduled,
-				},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
-
-			update := TopologyUpdate{
-				Changes:   []ResourceChange{change},
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
 
-			log.Printf("Sending WebSocket update with %d changes", len(update.Changes))
-			select {
-			case updates <- update:
-				log.Printf("Successfully sent WebSocket update")
-			case <-ctx.Done():
-				return
-			}
-		}
+	select {
+	case updates <- TopologyUpdate{Changes: []ResourceChange{change}, Timestamp: now, generation: generation}:
+	case <-ctx.Done():
 	}
-	
-	log.Printf("DaemonSet watcher stopped for namespace: %s", namespace)
 }
@@ -7,12 +7,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/apidocs"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/bulk"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/compare"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/deployments"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/events"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/gitops"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/helm"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/manifests"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/namespaces"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/nodes"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/pods"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/resources"
+	"github.com/prasad/kaptivan/backend/internal/api/handlers/rollouts"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/services"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/sqlquery"
 	"github.com/prasad/kaptivan/backend/internal/api/handlers/topology"
@@ -37,6 +43,8 @@ func SetupRoutes(r *gin.Engine) {
 		pods.Initialize(manager)
 		// Initialize deployment handlers
 		deployments.Initialize(manager)
+		// Initialize rollout handlers
+		rollouts.Initialize(manager)
 		// Initialize services handlers
 		services.Initialize(manager)
 		// Initialize manifest handlers
@@ -51,6 +59,16 @@ func SetupRoutes(r *gin.Engine) {
 		resources.Initialize(manager)
 		// Initialize SQL query handlers
 		sqlquery.Initialize(manager)
+		// Initialize bulk job handlers
+		bulk.Initialize(manager)
+		// Initialize GitOps drift handlers
+		gitops.Initialize(manager)
+		// Initialize Helm release handlers
+		helm.Initialize(manager)
+		// Initialize cross-cluster comparison handlers
+		compare.Initialize(manager)
+		// Initialize node operation handlers
+		nodes.Initialize(manager)
 	}
 
 	// Initialize linter (doesn't require cluster manager)
@@ -95,6 +113,12 @@ func SetupRoutes(r *gin.Engine) {
 		nodesGroup := v1.Group("/nodes")
 		{
 			nodesGroup.GET("/describe", handlers.DescribeNode)
+			nodesGroup.POST("/:context/:name/cordon", nodes.CordonNode)
+			nodesGroup.POST("/:context/:name/uncordon", nodes.UncordonNode)
+			nodesGroup.POST("/:context/:name/drain", nodes.StartDrain)
+			nodesGroup.GET("/:context/:name/drain", nodes.GetDrain)
+			nodesGroup.POST("/:context/:name/drain/cancel", nodes.CancelDrain)
+			nodesGroup.GET("/:context/:name/drain/ws", nodes.DrainWebSocket)
 		}
 
 		// Deployment endpoints (new structured handlers)
@@ -107,11 +131,54 @@ func SetupRoutes(r *gin.Engine) {
 			deploymentsGroup.DELETE("/:context/:namespace/:name", deployments.Delete)
 		}
 
+		// Rollout history for deployments, statefulsets and daemonsets
+		rolloutsGroup := v1.Group("/rollouts/:context/:namespace/:kind/:name")
+		{
+			rolloutsGroup.GET("/history", rollouts.GetHistory)
+			rolloutsGroup.GET("/diff", rollouts.GetDiff)
+			rolloutsGroup.POST("/rollback", rollouts.Rollback)
+			rolloutsGroup.POST("/pause", rollouts.Pause)
+			rolloutsGroup.POST("/resume", rollouts.Resume)
+		}
+
+		// Bulk operations across resources and clusters
+		bulkGroup := v1.Group("/bulk")
+		{
+			bulkGroup.POST("/jobs", bulk.StartJob)
+			bulkGroup.GET("/jobs", bulk.ListJobs)
+			bulkGroup.GET("/jobs/:id", bulk.GetJob)
+			bulkGroup.POST("/jobs/:id/cancel", bulk.CancelJob)
+			bulkGroup.GET("/jobs/:id/ws", bulk.JobWebSocket)
+		}
+
+		// GitOps drift between a local Git repository and clusters
+		gitopsGroup := v1.Group("/gitops")
+		{
+			gitopsGroup.POST("/drift", gitops.DetectDrift)
+		}
+
+		// Helm releases decoded from the records Helm stores in the cluster
+		helmGroup := v1.Group("/helm/:context/releases")
+		{
+			helmGroup.GET("", helm.ListReleases)
+			helmGroup.GET("/:namespace/:name", helm.GetRelease)
+			helmGroup.GET("/:namespace/:name/history", helm.GetHistory)
+			helmGroup.GET("/:namespace/:name/diff", helm.GetDiff)
+		}
+
+		// Field-level comparison of objects between clusters and namespaces
+		compareGroup := v1.Group("/compare")
+		{
+			compareGroup.POST("/resources", compare.CompareResources)
+		}
+
 		// Services endpoints (new structured handlers)
 		servicesGroup := v1.Group("/services")
 		{
 			servicesGroup.POST("/list", services.ListServices)
 			servicesGroup.GET("/:context/:namespace/:name", services.GetService)
+			servicesGroup.PUT("/:context/:namespace/:name", services.UpdateService)
+			servicesGroup.GET("/:context/:namespace/:name/endpoints", services.GetServiceEndpoints)
 			servicesGroup.DELETE("/:context/:namespace/:name", services.DeleteService)
 		}
 
@@ -121,6 +188,12 @@ func SetupRoutes(r *gin.Engine) {
 			manifestsGroup.GET("/discover", manifests.ListAPIResources)
 			manifestsGroup.POST("/list", manifests.ListResources)
 			manifestsGroup.GET("/get", manifests.GetManifest)
+			manifestsGroup.POST("/apply", manifests.ApplyManifest)
+			manifestsGroup.POST("/patch", manifests.PatchResource)
+			manifestsGroup.POST("/labels", manifests.LabelResource)
+			manifestsGroup.POST("/annotations", manifests.AnnotateResource)
+			manifestsGroup.DELETE("/delete", manifests.DeleteResource)
+			manifestsGroup.POST("/export", manifests.ExportManifests)
 			manifestsGroup.GET("/related", manifests.GetRelatedResources)
 			// Add path-based route for related resources to match frontend expectations
 			manifestsGroup.GET("/:context/:name/related", manifests.GetRelatedResourcesWithPath)
@@ -146,13 +219,27 @@ func SetupRoutes(r *gin.Engine) {
 				topologyGroup.GET("/job", topologyHandler.GetJobTopology)
 				topologyGroup.GET("/cronjobs/list", topologyHandler.ListCronJobs)
 				topologyGroup.GET("/cronjob", topologyHandler.GetCronJobTopology)
+				topologyGroup.GET("/statefulsets/list", topologyHandler.ListStatefulSets)
+				topologyGroup.GET("/statefulset", topologyHandler.GetStatefulSetTopology)
+				topologyGroup.GET("/graph", topologyHandler.GetGraph)
+				topologyGroup.GET("/export", topologyHandler.ExportTopology)
+				topologyGroup.GET("/network/reachability", topologyHandler.GetNetworkReachability)
+				topologyGroup.GET("/network/check", topologyHandler.CheckNetworkReachability)
+				topologyGroup.GET("/network/overlay", topologyHandler.GetNetworkOverlay)
+				topologyGroup.GET("/snapshots", topologyHandler.ListTopologySnapshots)
+				topologyGroup.GET("/snapshots/watches", topologyHandler.ListTopologySnapshotWatches)
+				topologyGroup.POST("/snapshots/watch", topologyHandler.WatchTopologySnapshots)
+				topologyGroup.DELETE("/snapshots/watch", topologyHandler.UnwatchTopologySnapshots)
+				topologyGroup.POST("/snapshots/capture", topologyHandler.CaptureTopologySnapshot)
+				topologyGroup.GET("/snapshots/at", topologyHandler.GetTopologyAsOf)
+				topologyGroup.GET("/snapshots/diff", topologyHandler.DiffTopologySnapshots)
 
 				// WebSocket endpoint for real-time updates
 				topologyGroup.GET("/ws", func(c *gin.Context) {
 					context := c.Query("context")
 
-					// Try to get clientset, if not connected, try to connect
-					clientset, err := manager.GetClientset(context)
+					// Try to get the resource cache, if not connected, try to connect
+					resources, err := manager.GetResourceCache(context)
 					if err != nil {
 						// Try to connect to the cluster
 						log.Printf("Cluster not connected, attempting to connect: %s", context)
@@ -161,13 +248,13 @@ func SetupRoutes(r *gin.Engine) {
 							return
 						}
 						// Try again after connecting
-						clientset, err = manager.GetClientset(context)
+						resources, err = manager.GetResourceCache(context)
 						if err != nil {
-							c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to get clientset after connecting: %v", err)})
+							c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to get resource cache after connecting: %v", err)})
 							return
 						}
 					}
-					topology.HandleTopologyWebSocket(clientset)(c)
+					topology.HandleTopologyWebSocket(resources, topologyHandler.MetricsProvider(context))(c)
 				})
 			} else {
 				// Log error but don't crash - topology endpoints won't work
@@ -191,6 +278,19 @@ func SetupRoutes(r *gin.Engine) {
 			}
 		}
 
+		// Log alert engine, fed by live log streams as well as its own polling
+		var alertsHandler *logsHandlers.AlertsHandler
+		// Log search engine, which mines patterns from live log streams and searches
+		var searchHandler *logsHandlers.SearchHandler
+		if manager != nil {
+			alertsHandler = logsHandlers.NewAlertsHandler(manager)
+
+			// Create pooled manager wrapper
+			poolConfig := logsHandlers.DefaultPoolConfig()
+			pooledManager := logsHandlers.NewClusterManagerWithPool(manager, poolConfig)
+			searchHandler = logsHandlers.NewSearchHandler(pooledManager)
+		}
+
 		// Logs endpoints (multi-cluster log aggregation)
 		logsGroup := v1.Group("/logs")
 		{
@@ -198,15 +298,51 @@ func SetupRoutes(r *gin.Engine) {
 				logsHandler := logsHandlers.NewLogsHandler(manager)
 				// Use optimized streaming handler for production performance
 				streamHandler := logsHandlers.NewStreamHandlerOptimized(manager)
+				streamHandler.AddLogObserver(alertsHandler.Engine())
+				streamHandler.AddLogObserver(searchHandler)
 
 				logsGroup.GET("/", logsHandler.GetLogs)
 				logsGroup.POST("/search", logsHandler.SearchLogs)
+				logsGroup.GET("/stats", logsHandler.GetLogStats)
+				logsGroup.GET("/export", logsHandler.ExportLogs)
+				logsGroup.POST("/export", logsHandler.ExportLogs)
 				logsGroup.GET("/stream", streamHandler.StreamLogs)
 			} else {
 				println("Warning: Logs handler not initialized - logs endpoints will not be available")
 			}
 		}
 
+		// Log redaction endpoints (shared by every log pipeline)
+		redactionGroup := v1.Group("/logs/redaction")
+		{
+			redactionHandler := logsHandlers.NewRedactionHandler()
+			redactionGroup.GET("/rules", redactionHandler.ListRules)
+			redactionGroup.POST("/rules", redactionHandler.CreateRule)
+			redactionGroup.DELETE("/rules/:id", redactionHandler.DeleteRule)
+			redactionGroup.PUT("/detectors/:name", redactionHandler.SetDetector)
+			redactionGroup.POST("/preview", redactionHandler.Preview)
+		}
+
+		// Log alerting endpoints
+		alertsGroup := v1.Group("/alerts")
+		{
+			if alertsHandler != nil {
+				alertsGroup.GET("/rules", alertsHandler.ListRules)
+				alertsGroup.POST("/rules", alertsHandler.CreateRule)
+				alertsGroup.GET("/rules/:id", alertsHandler.GetRule)
+				alertsGroup.PUT("/rules/:id", alertsHandler.UpdateRule)
+				alertsGroup.DELETE("/rules/:id", alertsHandler.DeleteRule)
+				alertsGroup.GET("/active", alertsHandler.ListStates)
+				alertsGroup.GET("/history", alertsHandler.ListHistory)
+				alertsGroup.GET("/sinks", alertsHandler.ListSinks)
+				alertsGroup.POST("/sinks", alertsHandler.CreateSink)
+				alertsGroup.DELETE("/sinks/:name", alertsHandler.DeleteSink)
+				alertsGroup.POST("/sinks/:name/test", alertsHandler.TestSink)
+			} else {
+				println("Warning: Alerts handler not initialized - alerting endpoints will not be available")
+			}
+		}
+
 		// Events endpoints
 		eventsGroup := v1.Group("/events")
 		{
@@ -218,18 +354,15 @@ func SetupRoutes(r *gin.Engine) {
 		// Logs V2 endpoints (with connection pooling and search optimization)
 		logsV2 := v1.Group("/logs/v2")
 		{
-			if manager != nil {
-				// Create pooled manager wrapper
-				poolConfig := logsHandlers.DefaultPoolConfig()
-				pooledManager := logsHandlers.NewClusterManagerWithPool(manager, poolConfig)
-
+			if searchHandler != nil {
 				// Optimized streaming handler (removed duplicate - already defined above)
 
 				// Search handler with optimization
-				searchHandler := logsHandlers.NewSearchHandler(pooledManager)
 				logsV2.GET("/search", searchHandler.HandleSearchLogs)
 				logsV2.GET("/search/metrics", searchHandler.GetSearchMetrics)
 				logsV2.POST("/search/cache/clear", searchHandler.ClearSearchCache)
+				logsV2.GET("/patterns", searchHandler.GetTopPatterns)
+				logsV2.GET("/patterns/new", searchHandler.GetNewPatterns)
 			} else {
 				println("Warning: Logs V2 handler not initialized - optimized logs endpoints will not be available")
 			}
@@ -282,9 +415,6 @@ func SetupRoutes(r *gin.Engine) {
 			resourcesLegacy.POST("/services", handlers.ListServices)
 			resourcesLegacy.GET("/namespaces", handlers.ListNamespaces)
 			resourcesLegacy.GET("/nodes", handlers.ListNodes)
-			// TODO: Implement these handlers
-			// resources.POST("/apply", handlers.ApplyManifest)
-			// resources.POST("/delete", handlers.DeleteResource)
 		}
 
 		// Test endpoints (only in debug mode)
@@ -322,6 +452,7 @@ func SetupRoutes(r *gin.Engine) {
 				"/api/v1/apidocs/*",
 				"/api/v1/events/*",
 				"/api/v1/logs/*",
+				"/api/v1/alerts/*",
 				"/api/v1/sql/*",
 				"/api/v1/linter/*",
 				"/api/v1/resources/*",
@@ -27,6 +27,9 @@ type ClusterManager struct {
 	kubeConfigPath string
 	connections    map[string]*ClusterConnection
 	mu             sync.RWMutex
+
+	caches  map[string]*ResourceCache
+	cacheMu sync.Mutex
 }
 
 // NewClusterManager creates a new cluster manager
@@ -34,6 +37,7 @@ func NewClusterManager(kubeConfigPath string) *ClusterManager {
 	return &ClusterManager{
 		kubeConfigPath: kubeConfigPath,
 		connections:    make(map[string]*ClusterConnection),
+		caches:         make(map[string]*ResourceCache),
 	}
 }
 
@@ -100,6 +104,9 @@ func (cm *ClusterManager) ConnectToCluster(contextName string) error {
 		return fmt.Errorf("failed to connect to cluster: %w", err)
 	}
 
+	// Informers built on the previous clientset must not outlive it
+	cm.stopResourceCache(contextName)
+
 	// Update connection
 	conn.Config = config
 	conn.ClientSet = clientset
@@ -127,6 +134,8 @@ func (cm *ClusterManager) DisconnectFromCluster(contextName string) error {
 	conn.ClientSet = nil
 	conn.Config = nil
 
+	cm.stopResourceCache(actualContext)
+
 	return nil
 }
 
@@ -160,6 +169,54 @@ func (cm *ClusterManager) GetClientset(contextName string) (kubernetes.Interface
 	return conn.ClientSet, nil
 }
 
+// GetResourceCache returns the shared informer cache for a connected cluster,
+// creating it on first use
+func (cm *ClusterManager) GetResourceCache(contextName string) (*ResourceCache, error) {
+	// Holding the read lock keeps connect and disconnect, which stop the cache, from
+	// running between the connection lookup and storing a cache for it
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	actualContext := cm.findContextCaseInsensitive(contextName)
+	if actualContext == "" {
+		return nil, fmt.Errorf("cluster context %s not found", contextName)
+	}
+	conn := cm.connections[actualContext]
+	if !conn.Connected {
+		return nil, fmt.Errorf("cluster %s is not connected", actualContext)
+	}
+
+	cm.cacheMu.Lock()
+	defer cm.cacheMu.Unlock()
+
+	if cm.caches == nil {
+		cm.caches = make(map[string]*ResourceCache)
+	}
+	if rc, ok := cm.caches[actualContext]; ok {
+		if rc.clientset == conn.ClientSet {
+			return rc, nil
+		}
+		// Built on a clientset the connection no longer uses
+		go rc.Stop()
+	}
+
+	rc := NewResourceCache(actualContext, conn.ClientSet, DefaultResyncPeriod)
+	cm.caches[actualContext] = rc
+	return rc, nil
+}
+
+// stopResourceCache stops and forgets a cluster's informer cache. Callers hold mu.
+func (cm *ClusterManager) stopResourceCache(contextName string) {
+	cm.cacheMu.Lock()
+	rc, ok := cm.caches[contextName]
+	delete(cm.caches, contextName)
+	cm.cacheMu.Unlock()
+
+	if ok {
+		go rc.Stop()
+	}
+}
+
 // GetAllConnections returns all cluster connections
 func (cm *ClusterManager) GetAllConnections() map[string]*ClusterConnection {
 	cm.mu.RLock()
@@ -0,0 +1,514 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultResyncPeriod is how often informers resync their store. Resyncs
+	// re-deliver unchanged objects and are not forwarded to subscribers.
+	DefaultResyncPeriod = 10 * time.Minute
+
+	// cacheSyncTimeout bounds how long a new subscription waits for the initial list
+	cacheSyncTimeout = 30 * time.Second
+
+	// failedSyncRetry is how long a failed initial list is remembered before the
+	// informer is tried again; until then reads go straight to the API
+	failedSyncRetry = 5 * time.Minute
+
+	// subscriptionBuffer is the per-subscriber event buffer beyond the replayed snapshot
+	subscriptionBuffer = 1024
+)
+
+// ErrCacheNotSynced is returned by reads while a resource's initial list is still
+// running; callers should query the API instead of waiting
+var ErrCacheNotSynced = errors.New("resource cache has not synced yet")
+
+// informerFactories maps cacheable resources to their typed informers
+var informerFactories = map[string]func(informers.SharedInformerFactory) cache.SharedIndexInformer{
+	"pods": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Pods().Informer()
+	},
+	"services": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Services().Informer()
+	},
+	"endpoints": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Endpoints().Informer()
+	},
+	"events": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Events().Informer()
+	},
+	"namespaces": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Namespaces().Informer()
+	},
+	"configmaps": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().ConfigMaps().Informer()
+	},
+	"persistentvolumeclaims": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().PersistentVolumeClaims().Informer()
+	},
+	"deployments": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().Deployments().Informer()
+	},
+	"replicasets": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().ReplicaSets().Informer()
+	},
+	"daemonsets": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().DaemonSets().Informer()
+	},
+	"statefulsets": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().StatefulSets().Informer()
+	},
+	"jobs": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Batch().V1().Jobs().Informer()
+	},
+	"cronjobs": func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Batch().V1().CronJobs().Informer()
+	},
+}
+
+// CachedResources lists the resource names the cache can serve
+func CachedResources() []string {
+	resources := make([]string, 0, len(informerFactories))
+	for resource := range informerFactories {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// ResourceEvent is a change fanned out to subscribers
+type ResourceEvent struct {
+	Type     watch.EventType // ADDED, MODIFIED or DELETED
+	Resource string
+	Object   runtime.Object
+}
+
+// Subscription receives events for one resource, optionally limited to a namespace
+type Subscription struct {
+	id        int
+	resource  string
+	namespace string
+	events    chan ResourceEvent
+	dropped   atomic.Int64
+	cache     *ResourceCache
+	closeOnce sync.Once
+}
+
+// Events returns the event channel. It is closed when the subscription or cache closes.
+func (s *Subscription) Events() <-chan ResourceEvent {
+	return s.events
+}
+
+// Dropped returns how many events were dropped because the subscriber fell behind.
+// A subscriber that sees this grow should re-list from the cache.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the event channel
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.cache.mu.Lock()
+		delete(s.cache.subscribers[s.resource], s.id)
+		s.cache.mu.Unlock()
+		close(s.events)
+	})
+}
+
+// ResourceCache is a per-cluster shared informer cache. Informers start lazily on
+// first use and are shared by every reader and subscriber for that cluster, so the
+// apiserver sees one list+watch per resource regardless of how many clients connect.
+// Watch expiry and relists are handled by the informers' reflectors.
+type ResourceCache struct {
+	contextName string
+	clientset   kubernetes.Interface
+	resync      time.Duration
+
+	mu          sync.RWMutex
+	informers   map[string]*resourceInformer
+	failures    map[string]syncFailure
+	subscribers map[string]map[int]*Subscription
+	nextID      int
+	stopped     bool
+}
+
+// resourceInformer is one resource's informer. Each runs from its own factory so an
+// informer whose initial list fails can be stopped without touching the others.
+type resourceInformer struct {
+	informer cache.SharedIndexInformer
+	factory  informers.SharedInformerFactory
+	stopCh   chan struct{}
+	failed   chan struct{} // closed once err is set
+	err      error
+}
+
+// syncFailure records why a resource's initial list failed
+type syncFailure struct {
+	err error
+	at  time.Time
+}
+
+// NewResourceCache creates a cache for a cluster; no informers run until a resource is used
+func NewResourceCache(contextName string, clientset kubernetes.Interface, resync time.Duration) *ResourceCache {
+	return &ResourceCache{
+		contextName: contextName,
+		clientset:   clientset,
+		resync:      resync,
+		informers:   make(map[string]*resourceInformer),
+		failures:    make(map[string]syncFailure),
+		subscribers: make(map[string]map[int]*Subscription),
+	}
+}
+
+// informer returns the synced informer for a resource, starting it if needed. It does
+// not wait: until the initial list completes it returns ErrCacheNotSynced.
+func (rc *ResourceCache) informer(resource string) (cache.SharedIndexInformer, error) {
+	entry, err := rc.startInformer(resource)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.informer.HasSynced() {
+		return nil, fmt.Errorf("%w: %s in cluster %s", ErrCacheNotSynced, resource, rc.contextName)
+	}
+	return entry.informer, nil
+}
+
+// syncedInformer returns the informer for a resource once its initial list completes,
+// waiting up to cacheSyncTimeout or until the list fails
+func (rc *ResourceCache) syncedInformer(resource string) (cache.SharedIndexInformer, error) {
+	entry, err := rc.startInformer(resource)
+	if err != nil {
+		return nil, err
+	}
+	if entry.informer.HasSynced() {
+		return entry.informer, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-entry.failed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if cache.WaitForCacheSync(ctx.Done(), entry.informer.HasSynced) {
+		return entry.informer, nil
+	}
+	select {
+	case <-entry.failed:
+		return nil, entry.err
+	default:
+		return nil, fmt.Errorf("timed out waiting for %s cache to sync in cluster %s", resource, rc.contextName)
+	}
+}
+
+// startInformer returns the informer entry for a resource, starting it if needed. A
+// resource whose initial list failed recently returns that failure without a new list.
+func (rc *ResourceCache) startInformer(resource string) (*resourceInformer, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.stopped {
+		return nil, fmt.Errorf("resource cache for %s is stopped", rc.contextName)
+	}
+	if entry, ok := rc.informers[resource]; ok {
+		return entry, nil
+	}
+	if failure, ok := rc.failures[resource]; ok {
+		if time.Since(failure.at) < failedSyncRetry {
+			return nil, failure.err
+		}
+		delete(rc.failures, resource)
+	}
+
+	newInformer, supported := informerFactories[resource]
+	if !supported {
+		return nil, fmt.Errorf("resource %s is not cached", resource)
+	}
+	factory := informers.NewSharedInformerFactory(rc.clientset, rc.resync)
+	entry := &resourceInformer{
+		informer: newInformer(factory),
+		factory:  factory,
+		stopCh:   make(chan struct{}),
+		failed:   make(chan struct{}),
+	}
+	if _, err := entry.informer.AddEventHandler(rc.fanOutHandler(resource)); err != nil {
+		return nil, fmt.Errorf("failed to register %s handler: %w", resource, err)
+	}
+	// Errors after the initial list are watch expiries the reflector recovers from;
+	// a failed initial list, e.g. a forbidden cluster-wide list, is not retried
+	if err := entry.informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		if entry.informer.HasSynced() {
+			log.Printf("Watch of %s in cluster %s failed, retrying: %v", resource, rc.contextName, err)
+			return
+		}
+		rc.failInformer(resource, entry, err)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register %s error handler: %w", resource, err)
+	}
+	rc.informers[resource] = entry
+	factory.Start(entry.stopCh)
+	log.Printf("Started %s informer for cluster %s", resource, rc.contextName)
+
+	return entry, nil
+}
+
+// failInformer stops and drops an informer whose initial list failed, remembering the
+// failure so reads go to the API until failedSyncRetry has passed
+func (rc *ResourceCache) failInformer(resource string, entry *resourceInformer, err error) {
+	rc.mu.Lock()
+	if rc.stopped || rc.informers[resource] != entry {
+		rc.mu.Unlock()
+		return
+	}
+	delete(rc.informers, resource)
+	entry.err = fmt.Errorf("%s cache in cluster %s failed to sync: %w", resource, rc.contextName, err)
+	rc.failures[resource] = syncFailure{err: entry.err, at: time.Now()}
+	rc.mu.Unlock()
+
+	log.Printf("Stopped %s informer for cluster %s: %v", resource, rc.contextName, err)
+	close(entry.failed)
+	close(entry.stopCh)
+	// Shutdown waits for the informer goroutines, which include this caller
+	go entry.factory.Shutdown()
+}
+
+// fanOutHandler forwards informer notifications to subscribers
+func (rc *ResourceCache) fanOutHandler(resource string) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			rc.publish(resource, watch.Added, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// Periodic resyncs re-deliver objects with an unchanged resourceVersion
+			if sameResourceVersion(oldObj, newObj) {
+				return
+			}
+			rc.publish(resource, watch.Modified, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			// Deletes missed during a relist arrive as tombstones
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			rc.publish(resource, watch.Deleted, obj)
+		},
+	}
+}
+
+// publish delivers an event to matching subscribers without blocking the informer
+func (rc *ResourceCache) publish(resource string, eventType watch.EventType, obj interface{}) {
+	object, ok := obj.(runtime.Object)
+	if !ok {
+		return
+	}
+	namespace := objectNamespace(object)
+
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	for _, sub := range rc.subscribers[resource] {
+		if sub.namespace != "" && sub.namespace != namespace {
+			continue
+		}
+		select {
+		case sub.events <- ResourceEvent{Type: eventType, Resource: resource, Object: object}:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe registers for changes to a resource in a namespace ("" for all).
+// With replay, current objects are delivered first as ADDED events, so a new
+// subscriber sees the same initial state a fresh watch would.
+func (rc *ResourceCache) Subscribe(resource, namespace string, replay bool) (*Subscription, error) {
+	informer, err := rc.syncedInformer(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.stopped {
+		return nil, fmt.Errorf("resource cache for %s is stopped", rc.contextName)
+	}
+
+	var snapshot []runtime.Object
+	if replay {
+		snapshot = filterNamespace(informer.GetStore().List(), namespace)
+	}
+
+	rc.nextID++
+	sub := &Subscription{
+		id:        rc.nextID,
+		resource:  resource,
+		namespace: namespace,
+		events:    make(chan ResourceEvent, len(snapshot)+subscriptionBuffer),
+		cache:     rc,
+	}
+	for _, object := range snapshot {
+		sub.events <- ResourceEvent{Type: watch.Added, Resource: resource, Object: object}
+	}
+
+	if rc.subscribers[resource] == nil {
+		rc.subscribers[resource] = make(map[int]*Subscription)
+	}
+	rc.subscribers[resource][sub.id] = sub
+
+	return sub, nil
+}
+
+// List returns cached objects of a resource in a namespace ("" for all), ordered
+// by namespace and name like an API list
+func (rc *ResourceCache) List(resource, namespace string) ([]runtime.Object, error) {
+	informer, err := rc.informer(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	if namespace != "" {
+		if items, err := informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace); err == nil {
+			objects = filterNamespace(items, "")
+		}
+	}
+	if objects == nil {
+		objects = filterNamespace(informer.GetStore().List(), namespace)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objectKey(objects[i]) < objectKey(objects[j])
+	})
+	return objects, nil
+}
+
+// Get returns a cached object by namespace and name
+func (rc *ResourceCache) Get(resource, namespace, name string) (runtime.Object, bool, error) {
+	informer, err := rc.informer(resource)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	item, exists, err := informer.GetStore().GetByKey(key)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	object, ok := item.(runtime.Object)
+	return object, ok, nil
+}
+
+// Stop stops all informers and closes every subscription
+func (rc *ResourceCache) Stop() {
+	rc.mu.Lock()
+	if rc.stopped {
+		rc.mu.Unlock()
+		return
+	}
+	rc.stopped = true
+	entries := make([]*resourceInformer, 0, len(rc.informers))
+	for _, entry := range rc.informers {
+		close(entry.stopCh)
+		entries = append(entries, entry)
+	}
+
+	var subs []*Subscription
+	for _, byID := range rc.subscribers {
+		for _, sub := range byID {
+			subs = append(subs, sub)
+		}
+	}
+	rc.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Close()
+	}
+	for _, entry := range entries {
+		entry.factory.Shutdown()
+	}
+}
+
+// ListAs returns cached objects of a resource converted to their typed form
+func ListAs[T runtime.Object](rc *ResourceCache, resource, namespace string) ([]T, error) {
+	objects, err := rc.List(resource, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make([]T, 0, len(objects))
+	for _, object := range objects {
+		if item, ok := object.(T); ok {
+			typed = append(typed, item)
+		}
+	}
+	return typed, nil
+}
+
+// filterNamespace converts store items to objects in a namespace ("" for all)
+func filterNamespace(items []interface{}, namespace string) []runtime.Object {
+	objects := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		object, ok := item.(runtime.Object)
+		if !ok {
+			continue
+		}
+		if namespace != "" && objectNamespace(object) != namespace {
+			continue
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// objectNamespace returns an object's namespace, or "" for cluster-scoped objects
+func objectNamespace(object runtime.Object) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetNamespace()
+}
+
+// objectKey returns the namespace/name store key of an object
+func objectKey(object runtime.Object) string {
+	key, err := cache.MetaNamespaceKeyFunc(object)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// sameResourceVersion reports whether an update is a resync of an unchanged object
+func sameResourceVersion(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
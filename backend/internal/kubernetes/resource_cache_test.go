@@ -0,0 +1,157 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) ResourceEvent {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return ResourceEvent{}
+	}
+}
+
+// TestResourceCacheFanOut verifies replay, namespace filtering and fan-out to
+// several subscribers from one informer
+func TestResourceCacheFanOut(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "shop"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "kube-system"}},
+	)
+	rc := NewResourceCache("test", clientset, time.Minute)
+	defer rc.Stop()
+
+	// Subscribing waits for the initial list, after which reads are served from the cache
+	first, err := rc.Subscribe("pods", "shop", true)
+	require.NoError(t, err)
+	pods, err := ListAs[*corev1.Pod](rc, "pods", "shop")
+	require.NoError(t, err)
+	require.Len(t, pods, 1)
+	assert.Equal(t, "api-1", pods[0].Name)
+	second, err := rc.Subscribe("pods", "", false)
+	require.NoError(t, err)
+
+	replayed := nextEvent(t, first)
+	assert.Equal(t, watch.Added, replayed.Type)
+	assert.Equal(t, "api-1", replayed.Object.(*corev1.Pod).Name)
+
+	ctx := context.Background()
+	_, err = clientset.CoreV1().Pods("shop").Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-2", Namespace: "shop"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = clientset.CoreV1().Pods("kube-system").Create(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "proxy", Namespace: "kube-system"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	added := nextEvent(t, first)
+	assert.Equal(t, "api-2", added.Object.(*corev1.Pod).Name)
+
+	// The unfiltered subscriber sees both pods, in order
+	assert.Equal(t, "api-2", nextEvent(t, second).Object.(*corev1.Pod).Name)
+	assert.Equal(t, "proxy", nextEvent(t, second).Object.(*corev1.Pod).Name)
+
+	require.NoError(t, clientset.CoreV1().Pods("shop").Delete(ctx, "api-1", metav1.DeleteOptions{}))
+	deleted := nextEvent(t, first)
+	assert.Equal(t, watch.Deleted, deleted.Type)
+	assert.Equal(t, "api-1", deleted.Object.(*corev1.Pod).Name)
+
+	first.Close()
+	_, open := <-first.Events()
+	assert.False(t, open)
+
+	_, err = rc.Subscribe("widgets", "", false)
+	assert.Error(t, err)
+}
+
+// TestResourceCacheSyncFailure verifies reads do not wait for the initial list and that a
+// forbidden list stops the informer and is remembered instead of retried on every read
+func TestResourceCacheSyncFailure(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	release := make(chan struct{})
+	var lists atomic.Int32
+	clientset.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		lists.Add(1)
+		<-release
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("cluster-wide list denied"))
+	})
+	rc := NewResourceCache("test", clientset, time.Minute)
+	defer rc.Stop()
+
+	start := time.Now()
+	_, err := rc.List("pods", "")
+	assert.ErrorIs(t, err, ErrCacheNotSynced)
+	assert.Less(t, time.Since(start), time.Second, "reads must not wait for the sync")
+
+	close(release)
+	_, err = rc.Subscribe("pods", "", true)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsForbidden(err), err.Error())
+
+	_, err = rc.List("pods", "")
+	assert.True(t, apierrors.IsForbidden(err), "the failure is cached")
+	assert.Equal(t, int32(1), lists.Load(), "a failed informer is not restarted")
+}
+
+// TestResyncIsNotForwarded verifies unchanged objects from a resync are suppressed
+func TestResyncIsNotForwarded(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "ns", ResourceVersion: "7"}}
+	assert.True(t, sameResourceVersion(pod, pod.DeepCopy()))
+
+	changed := pod.DeepCopy()
+	changed.ResourceVersion = "8"
+	assert.False(t, sameResourceVersion(pod, changed))
+}
+
+// TestGetResourceCacheFollowsConnection verifies a cache is never handed out or kept
+// for a connection that was dropped or replaced
+func TestGetResourceCacheFollowsConnection(t *testing.T) {
+	cm := NewClusterManager("")
+	conn := &ClusterConnection{Name: "prod", Context: "prod", ClientSet: fake.NewSimpleClientset(), Connected: true}
+	cm.connections["prod"] = conn
+
+	first, err := cm.GetResourceCache("prod")
+	require.NoError(t, err)
+	again, err := cm.GetResourceCache("prod")
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	// A cache built on an old clientset is replaced
+	conn.ClientSet = fake.NewSimpleClientset()
+	replaced, err := cm.GetResourceCache("prod")
+	require.NoError(t, err)
+	assert.NotSame(t, first, replaced)
+	assert.Equal(t, conn.ClientSet, replaced.clientset)
+
+	// Lookups racing a disconnect never leave a cache behind
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			cm.GetResourceCache("prod")
+		}
+	}()
+	require.NoError(t, cm.DisconnectFromCluster("prod"))
+	<-done
+	_, err = cm.GetResourceCache("prod")
+	assert.Error(t, err)
+	cm.cacheMu.Lock()
+	assert.Empty(t, cm.caches)
+	cm.cacheMu.Unlock()
+}
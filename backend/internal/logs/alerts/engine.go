@@ -0,0 +1,616 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+)
+
+// LogSource fetches log lines for a query, e.g. services.LogAggregator
+type LogSource interface {
+	FetchLogs(ctx context.Context, query models.LogQuery) (*models.LogResponse, error)
+}
+
+// maxHistory is the number of notifications kept in memory
+const maxHistory = 500
+
+// Engine evaluates alert rules against the log pipeline and notifies sinks
+// when a rule group starts or stops firing
+type Engine struct {
+	source   LogSource
+	interval time.Duration
+	policy   SinkPolicy
+
+	rules   map[string]*AlertRule
+	groups  map[string]map[string]*groupState // rule ID -> group -> state
+	polled  map[string]time.Time              // rule ID -> end of last fetched range
+	sinks   map[string]Sink
+	configs map[string]SinkConfig
+	history []Notification
+	mu      sync.RWMutex
+
+	cancel  context.CancelFunc // stops the evaluation loop; nil when not running
+	stopped chan struct{}
+
+	now func() time.Time
+}
+
+// groupState holds the sliding window of matches for one group of a rule
+type groupState struct {
+	matches []match
+	seen    map[string]struct{} // keys of matches, as streams and polling can both see a line
+	state   AlertState
+}
+
+// match is a log line counted towards a rule group
+type match struct {
+	at  time.Time
+	key string
+}
+
+// NewEngine creates an alert engine that polls the source every interval.
+// Sinks added through AddSink are limited by policy.
+func NewEngine(source LogSource, interval time.Duration, policy SinkPolicy) *Engine {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	return &Engine{
+		source:   source,
+		interval: interval,
+		policy:   policy,
+		rules:    make(map[string]*AlertRule),
+		groups:   make(map[string]map[string]*groupState),
+		polled:   make(map[string]time.Time),
+		sinks:    make(map[string]Sink),
+		configs:  make(map[string]SinkConfig),
+		history:  []Notification{},
+		now:      time.Now,
+	}
+}
+
+// Start runs the evaluation loop in the background until Stop is called or
+// the context is cancelled. Starting a running engine does nothing.
+func (e *Engine) Start(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		return
+	}
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.stopped = make(chan struct{})
+	go e.run(ctx, e.stopped)
+}
+
+// Stop ends the evaluation loop and waits for it to return
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	cancel, stopped := e.cancel, e.stopped
+	e.cancel, e.stopped = nil, nil
+	e.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-stopped
+}
+
+// run polls and evaluates every interval until the context is cancelled
+func (e *Engine) run(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.poll(ctx)
+			e.Evaluate(ctx)
+		}
+	}
+}
+
+// poll fetches new log lines for every enabled rule since its last poll
+func (e *Engine) poll(ctx context.Context) {
+	if e.source == nil {
+		return
+	}
+
+	for _, rule := range e.enabledRules() {
+		now := e.now()
+
+		e.mu.Lock()
+		since, ok := e.polled[rule.ID]
+		if !ok {
+			since = now.Add(-rule.window)
+		}
+		e.polled[rule.ID] = now
+		e.mu.Unlock()
+
+		query := rule.Query
+		query.StartTime = since
+		query.EndTime = now
+		query.Limit = 0
+
+		response, err := e.source.FetchLogs(ctx, query)
+		if err != nil {
+			log.Printf("Alert rule %s: failed to fetch logs: %v", rule.Name, err)
+			continue
+		}
+
+		for _, entry := range response.Logs {
+			e.observe(rule, entry)
+		}
+	}
+}
+
+// Observe feeds a log line from the pipeline, e.g. a live log stream, to every
+// enabled rule whose query selects it. A line also fetched by polling is counted once.
+func (e *Engine) Observe(entry models.LogEntry) {
+	for _, rule := range e.enabledRules() {
+		if selectsEntry(rule.Query, entry) {
+			e.observe(rule, entry)
+		}
+	}
+}
+
+// observe records a matching line for a rule
+func (e *Engine) observe(rule *AlertRule, entry models.LogEntry) {
+	if !rule.matches(entry) {
+		return
+	}
+
+	group := groupKey(rule.GroupBy, entry)
+	at := entry.Timestamp
+	if at.IsZero() {
+		at = e.now()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	groups, ok := e.groups[rule.ID]
+	if !ok {
+		groups = make(map[string]*groupState)
+		e.groups[rule.ID] = groups
+	}
+	gs, ok := groups[group]
+	if !ok {
+		gs = &groupState{
+			seen: make(map[string]struct{}),
+			state: AlertState{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				Group:     group,
+				Status:    StatusResolved,
+				Threshold: rule.Threshold,
+			},
+		}
+		groups[group] = gs
+	}
+
+	key := matchKey(entry)
+	if key != "" {
+		if _, ok := gs.seen[key]; ok {
+			return
+		}
+		gs.seen[key] = struct{}{}
+	}
+	gs.matches = append(gs.matches, match{at: at, key: key})
+	if at.After(gs.state.LastMatchAt) {
+		gs.state.LastMatchAt = at
+		gs.state.Sample = entry.Message
+	}
+}
+
+// Evaluate checks every rule group against its threshold and sends
+// notifications for groups that changed state
+func (e *Engine) Evaluate(ctx context.Context) {
+	now := e.now()
+	pending := []Notification{}
+	targets := map[int][]string{}
+
+	e.mu.Lock()
+	for ruleID, groups := range e.groups {
+		rule, ok := e.rules[ruleID]
+		if !ok {
+			delete(e.groups, ruleID)
+			continue
+		}
+
+		cutoff := now.Add(-rule.window)
+		for group, gs := range groups {
+			// Drop matches that left the window
+			kept := gs.matches[:0]
+			for _, m := range gs.matches {
+				if m.at.After(cutoff) {
+					kept = append(kept, m)
+				} else {
+					delete(gs.seen, m.key)
+				}
+			}
+			gs.matches = kept
+			gs.state.Count = len(kept)
+
+			firing := rule.Enabled && gs.state.Count >= rule.Threshold
+			switch {
+			case firing && gs.state.Status != StatusFiring:
+				gs.state.Status = StatusFiring
+				gs.state.StartedAt = now
+				gs.state.ResolvedAt = time.Time{}
+			case !firing && gs.state.Status == StatusFiring:
+				gs.state.Status = StatusResolved
+				gs.state.ResolvedAt = now
+			default:
+				// Forget quiet groups that never fired or resolved a while ago
+				if gs.state.Count == 0 && gs.state.Status != StatusFiring {
+					delete(groups, group)
+				}
+				continue
+			}
+
+			notification := Notification{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				Group:     group,
+				Status:    gs.state.Status,
+				Count:     gs.state.Count,
+				Threshold: rule.Threshold,
+				Window:    rule.Window,
+				StartedAt: gs.state.StartedAt,
+				Sample:    gs.state.Sample,
+				Timestamp: now,
+			}
+			targets[len(pending)] = rule.Sinks
+			pending = append(pending, notification)
+			e.history = append(e.history, notification)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	e.mu.Unlock()
+
+	for i, notification := range pending {
+		e.notify(ctx, notification, targets[i])
+	}
+}
+
+// notify delivers a notification to the named sinks, or all sinks when none are named
+func (e *Engine) notify(ctx context.Context, notification Notification, names []string) {
+	e.mu.RLock()
+	sinks := []Sink{}
+	if len(names) == 0 {
+		for _, sink := range e.sinks {
+			sinks = append(sinks, sink)
+		}
+	} else {
+		for _, name := range names {
+			if sink, ok := e.sinks[name]; ok {
+				sinks = append(sinks, sink)
+			}
+		}
+	}
+	e.mu.RUnlock()
+
+	for _, sink := range sinks {
+		sendCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		if err := sink.Send(sendCtx, notification); err != nil {
+			log.Printf("Alert rule %s: failed to notify sink %s: %v", notification.RuleName, sink.Name(), err)
+		}
+		cancel()
+	}
+}
+
+// AddRule validates and stores a new rule
+func (e *Engine) AddRule(rule AlertRule) (*AlertRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := e.now()
+	rule.ID = generateRuleID()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules[rule.ID] = &rule
+
+	stored := rule
+	return &stored, nil
+}
+
+// UpdateRule replaces an existing rule, keeping its firing state when the
+// grouping is unchanged
+func (e *Engine) UpdateRule(id string, rule AlertRule) (*AlertRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	existing, ok := e.rules[id]
+	if !ok {
+		return nil, fmt.Errorf("rule %s not found", id)
+	}
+
+	rule.ID = id
+	rule.CreatedAt = existing.CreatedAt
+	rule.UpdatedAt = e.now()
+	if rule.GroupBy != existing.GroupBy {
+		delete(e.groups, id)
+	}
+	e.rules[id] = &rule
+
+	stored := rule
+	return &stored, nil
+}
+
+// DeleteRule removes a rule and its state
+func (e *Engine) DeleteRule(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.rules[id]; !ok {
+		return fmt.Errorf("rule %s not found", id)
+	}
+	delete(e.rules, id)
+	delete(e.groups, id)
+	delete(e.polled, id)
+	return nil
+}
+
+// GetRule returns a rule by ID
+func (e *Engine) GetRule(id string) (*AlertRule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rule, ok := e.rules[id]
+	if !ok {
+		return nil, false
+	}
+	stored := *rule
+	return &stored, true
+}
+
+// ListRules returns all rules ordered by name
+func (e *Engine) ListRules() []AlertRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := make([]AlertRule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, *rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+	return rules
+}
+
+// States returns the current state of every tracked rule group. When
+// firingOnly is set only firing groups are returned.
+func (e *Engine) States(firingOnly bool) []AlertState {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	states := []AlertState{}
+	for _, groups := range e.groups {
+		for _, gs := range groups {
+			if firingOnly && gs.state.Status != StatusFiring {
+				continue
+			}
+			states = append(states, gs.state)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].RuleName != states[j].RuleName {
+			return states[i].RuleName < states[j].RuleName
+		}
+		return states[i].Group < states[j].Group
+	})
+	return states
+}
+
+// History returns recent notifications, newest first
+func (e *Engine) History() []Notification {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	history := make([]Notification, len(e.history))
+	for i, notification := range e.history {
+		history[len(e.history)-1-i] = notification
+	}
+	return history
+}
+
+// AddSink registers or replaces a sink
+func (e *Engine) AddSink(config SinkConfig) error {
+	sink, err := NewSink(config, e.policy)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sinks[config.Name] = sink
+	e.configs[config.Name] = config
+	return nil
+}
+
+// RegisterSink registers a custom sink implementation
+func (e *Engine) RegisterSink(sink Sink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sinks[sink.Name()] = sink
+	e.configs[sink.Name()] = SinkConfig{Name: sink.Name(), Type: "custom"}
+}
+
+// RemoveSink removes a sink by name
+func (e *Engine) RemoveSink(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.sinks[name]; !ok {
+		return fmt.Errorf("sink %s not found", name)
+	}
+	delete(e.sinks, name)
+	delete(e.configs, name)
+	return nil
+}
+
+// ListSinks returns sink configurations with secrets redacted
+func (e *Engine) ListSinks() []SinkConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	configs := make([]SinkConfig, 0, len(e.configs))
+	for _, config := range e.configs {
+		configs = append(configs, config.Redacted())
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Name < configs[j].Name
+	})
+	return configs
+}
+
+// TestSink sends a synthetic notification to a sink
+func (e *Engine) TestSink(ctx context.Context, name string) error {
+	e.mu.RLock()
+	sink, ok := e.sinks[name]
+	e.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("sink %s not found", name)
+	}
+
+	now := e.now()
+	return sink.Send(ctx, Notification{
+		RuleID:    "test",
+		RuleName:  "Test notification",
+		Group:     "test",
+		Status:    StatusFiring,
+		StartedAt: now,
+		Sample:    "This is a test notification from Kaptivan",
+		Timestamp: now,
+	})
+}
+
+// enabledRules returns a snapshot of enabled rules
+func (e *Engine) enabledRules() []*AlertRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := []*AlertRule{}
+	for _, rule := range e.rules {
+		if rule.Enabled {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// selectsEntry checks if a log entry falls within a rule query's scope and filters
+func selectsEntry(query models.LogQuery, entry models.LogEntry) bool {
+	if len(query.Clusters) > 0 && !containsString(query.Clusters, entry.Cluster) {
+		return false
+	}
+	if len(query.Namespaces) > 0 && !containsString(query.Namespaces, entry.Namespace) {
+		return false
+	}
+	if len(query.Pods) > 0 {
+		found := false
+		for _, pod := range query.Pods {
+			if entry.Pod == pod || strings.Contains(entry.Pod, pod) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(query.Containers) > 0 && !containsString(query.Containers, entry.Container) {
+		return false
+	}
+	if len(query.LogLevels) > 0 {
+		found := false
+		for _, level := range query.LogLevels {
+			if strings.EqualFold(level, entry.Level) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if query.SearchTerm != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(query.SearchTerm)) {
+		return false
+	}
+	return true
+}
+
+// groupKey derives the alert group for a log entry
+func groupKey(groupBy string, entry models.LogEntry) string {
+	switch groupBy {
+	case GroupByCluster:
+		return entry.Cluster
+	case GroupByNamespace:
+		return entry.Cluster + "/" + entry.Namespace
+	case GroupByPod:
+		return entry.Cluster + "/" + entry.Namespace + "/" + entry.Pod
+	case GroupByDeployment:
+		return entry.Cluster + "/" + entry.Namespace + "/" + deploymentFromPod(entry.Pod)
+	default:
+		return "all"
+	}
+}
+
+// matchKey identifies a log line across the pipelines that deliver it; lines
+// without a timestamp can't be told apart and get no key
+func matchKey(entry models.LogEntry) string {
+	if entry.Timestamp.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s/%s@%d:%s", entry.Cluster, entry.Namespace, entry.Pod, entry.Container, entry.Timestamp.UnixNano(), entry.Message)
+}
+
+// deploymentFromPod derives the owning deployment name from a pod name of the
+// form <deployment>-<replicaset hash>-<pod hash>. Names that do not follow the
+// pattern are returned unchanged.
+func deploymentFromPod(pod string) string {
+	parts := strings.Split(pod, "-")
+	if len(parts) < 3 {
+		return pod
+	}
+	return strings.Join(parts[:len(parts)-2], "-")
+}
+
+// containsString checks if a slice contains a string
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// generateRuleID generates a random rule ID
+func generateRuleID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "rule-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,456 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Sink types supported by NewSink
+const (
+	SinkTypeWebhook = "webhook"
+	SinkTypeSMTP    = "smtp"
+	SinkTypeFile    = "file"
+)
+
+// Environment variables that configure SinkPolicyFromEnv
+const (
+	// SinkDirEnv names the directory file sinks write under; paths are relative to it
+	SinkDirEnv = "ALERT_SINK_DIR"
+	// AllowPrivateWebhooksEnv set to true lets webhooks and SMTP servers reach loopback and private addresses
+	AllowPrivateWebhooksEnv = "ALERT_WEBHOOK_ALLOW_PRIVATE"
+)
+
+// SinkPolicy limits where sinks created through the API may deliver
+type SinkPolicy struct {
+	FileDir              string // file sink paths are resolved against and confined to it
+	AllowPrivateWebhooks bool   // webhooks and SMTP servers may reach loopback and private addresses
+}
+
+// SinkPolicyFromEnv reads the sink policy from the environment. File sinks
+// default to a kaptivan-alerts directory under the system temp directory.
+func SinkPolicyFromEnv() SinkPolicy {
+	dir := os.Getenv(SinkDirEnv)
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "kaptivan-alerts")
+	}
+	allow, _ := strconv.ParseBool(os.Getenv(AllowPrivateWebhooksEnv))
+	return SinkPolicy{FileDir: dir, AllowPrivateWebhooks: allow}
+}
+
+// filePath resolves a file sink path inside FileDir, rejecting absolute paths and ".."
+func (p SinkPolicy) filePath(path string) (string, error) {
+	if p.FileDir == "" {
+		return "", fmt.Errorf("file sinks are disabled; set %s", SinkDirEnv)
+	}
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") || strings.HasPrefix(path, "\\") {
+		return "", fmt.Errorf("file sink path must be relative to %s", SinkDirEnv)
+	}
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return "", fmt.Errorf("file sink path must not contain ..")
+		}
+	}
+	dir, err := filepath.Abs(p.FileDir)
+	if err != nil {
+		return "", err
+	}
+	resolved := filepath.Join(dir, path)
+	rel, err := filepath.Rel(dir, resolved)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file sink path %s is outside %s", path, SinkDirEnv)
+	}
+	return resolved, nil
+}
+
+// webhookURL checks a webhook URL is http or https with a host the policy allows
+func (p SinkPolicy) webhookURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webhook url must be http or https")
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("webhook url requires a host")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("webhook url must not contain credentials; use headers")
+	}
+	if err := p.checkHost(u.Hostname()); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// smtpAddress checks an SMTP server host and port the policy allows
+func (p SinkPolicy) smtpAddress(host string, port int) (string, error) {
+	if strings.ContainsAny(host, "/@\r\n") {
+		return "", fmt.Errorf("invalid smtp host %q", host)
+	}
+	if port < 1 || port > 65535 {
+		return "", fmt.Errorf("invalid smtp port %d", port)
+	}
+	if err := p.checkHost(host); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// checkHost rejects localhost and literal addresses the policy disallows; other
+// hostnames are checked when the dialer connects
+func (p SinkPolicy) checkHost(host string) error {
+	if strings.EqualFold(host, "localhost") && !p.AllowPrivateWebhooks {
+		return fmt.Errorf("host %s is not allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(ip)
+	}
+	return nil
+}
+
+// checkIP rejects addresses sinks must not reach. Link-local addresses,
+// cloud metadata endpoints among them, are always rejected.
+func (p SinkPolicy) checkIP(ip net.IP) error {
+	switch {
+	case ip.IsUnspecified(), ip.IsMulticast(), ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return fmt.Errorf("address %s is not allowed", ip)
+	case !p.AllowPrivateWebhooks && (ip.IsLoopback() || ip.IsPrivate()):
+		return fmt.Errorf("address %s is private; set %s to allow it", ip, AllowPrivateWebhooksEnv)
+	}
+	return nil
+}
+
+// dialer checks every address it connects to, so hostnames that resolve to a
+// disallowed address are refused as well
+func (p SinkPolicy) dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("address %s is not an IP", host)
+			}
+			return p.checkIP(ip)
+		},
+	}
+}
+
+// webhookClient connects through the policy dialer, which covers redirects too
+func (p SinkPolicy) webhookClient() *http.Client {
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: p.dialer().DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+}
+
+// Sink delivers alert notifications
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, notification Notification) error
+}
+
+// SinkConfig describes a notification sink
+type SinkConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // webhook, smtp or file
+
+	// Webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// SMTP
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// File
+	Path string `json:"path,omitempty"`
+}
+
+// Redacted returns a copy of the config that is safe to return from the API
+func (c SinkConfig) Redacted() SinkConfig {
+	redacted := c
+	if redacted.Password != "" {
+		redacted.Password = "********"
+	}
+	if len(c.Headers) > 0 {
+		redacted.Headers = make(map[string]string, len(c.Headers))
+		for key := range c.Headers {
+			redacted.Headers[key] = "********"
+		}
+	}
+	return redacted
+}
+
+// NewSink builds a sink from its configuration, within the limits of the policy
+func NewSink(config SinkConfig, policy SinkPolicy) (Sink, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("sink name is required")
+	}
+
+	switch config.Type {
+	case SinkTypeWebhook:
+		if config.URL == "" {
+			return nil, fmt.Errorf("webhook sink requires url")
+		}
+		u, err := policy.webhookURL(config.URL)
+		if err != nil {
+			return nil, err
+		}
+		return &WebhookSink{
+			name:    config.Name,
+			url:     u.String(),
+			headers: config.Headers,
+			client:  policy.webhookClient(),
+		}, nil
+	case SinkTypeSMTP:
+		if config.Host == "" || config.From == "" || len(config.To) == 0 {
+			return nil, fmt.Errorf("smtp sink requires host, from and to")
+		}
+		port := config.Port
+		if port == 0 {
+			port = 25
+		}
+		addr, err := policy.smtpAddress(config.Host, port)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range append([]string{config.From}, config.To...) {
+			if _, err := mail.ParseAddress(address); err != nil {
+				return nil, fmt.Errorf("invalid smtp address %q: %w", address, err)
+			}
+		}
+		return &SMTPSink{
+			name:     config.Name,
+			addr:     addr,
+			dialer:   policy.dialer(),
+			host:     config.Host,
+			username: config.Username,
+			password: config.Password,
+			from:     config.From,
+			to:       config.To,
+		}, nil
+	case SinkTypeFile:
+		if config.Path == "" {
+			return nil, fmt.Errorf("file sink requires path")
+		}
+		path, err := policy.filePath(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileSink(config.Name, path), nil
+	default:
+		return nil, fmt.Errorf("unsupported sink type %q", config.Type)
+	}
+}
+
+// WebhookSink POSTs notifications as JSON to a URL
+type WebhookSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// Name returns the sink name
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+// Send posts the notification
+func (s *WebhookSink) Send(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", s.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", s.name, resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPSink emails notifications
+type SMTPSink struct {
+	name     string
+	addr     string
+	dialer   *net.Dialer
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// Name returns the sink name
+func (s *SMTPSink) Name() string {
+	return s.name
+}
+
+// Send emails the notification
+func (s *SMTPSink) Send(ctx context.Context, notification Notification) error {
+	subject := fmt.Sprintf("[%s] %s (%s)", strings.ToUpper(notification.Status), notification.RuleName, notification.Group)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&body, "Rule: %s\r\n", notification.RuleName)
+	fmt.Fprintf(&body, "Group: %s\r\n", notification.Group)
+	fmt.Fprintf(&body, "Status: %s\r\n", notification.Status)
+	fmt.Fprintf(&body, "Matches: %d in %s (threshold %d)\r\n", notification.Count, notification.Window, notification.Threshold)
+	fmt.Fprintf(&body, "Started: %s\r\n", notification.StartedAt.Format(time.RFC3339))
+	if notification.Sample != "" {
+		fmt.Fprintf(&body, "\r\nSample:\r\n%s\r\n", notification.Sample)
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	conn, err := s.dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("smtp %s: %w", s.name, err)
+	}
+
+	// net/smtp has no context support, so run it in the background and honour cancellation
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.sendMail(conn, auth, []byte(body.String()))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("smtp %s: %w", s.name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		conn.Close()
+		return ctx.Err()
+	}
+}
+
+// sendMail does what smtp.SendMail does over a connection the sink dialed itself
+func (s *SMTPSink) sendMail(conn net.Conn, auth smtp.Auth, msg []byte) error {
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(msg); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileSink appends notifications as JSON lines to a local file
+type FileSink struct {
+	name string
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSink creates a file sink
+func NewFileSink(name, path string) *FileSink {
+	return &FileSink{name: name, path: path}
+}
+
+// Name returns the sink name
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+// Send appends the notification to the file
+func (s *FileSink) Send(ctx context.Context, notification Notification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("file sink %s: %w", s.name, err)
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("file sink %s: %w", s.name, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
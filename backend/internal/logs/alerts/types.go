@@ -0,0 +1,124 @@
+package alerts
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+)
+
+// GroupBy values supported by alert rules
+const (
+	GroupByNone       = "none"
+	GroupByCluster    = "cluster"
+	GroupByNamespace  = "namespace"
+	GroupByPod        = "pod"
+	GroupByDeployment = "deployment"
+)
+
+// Alert states
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// AlertRule defines when a burst of matching log lines raises an alert
+type AlertRule struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Query     models.LogQuery `json:"query"`
+	Pattern   string          `json:"pattern,omitempty"` // Optional regex applied on top of query.searchTerm
+	Threshold int             `json:"threshold"`         // Matching lines within the window needed to fire
+	Window    string          `json:"window"`            // Duration such as "5m"
+	GroupBy   string          `json:"groupBy"`
+	Sinks     []string        `json:"sinks"` // Names of sinks to notify; all sinks when empty
+	Enabled   bool            `json:"enabled"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+
+	window  time.Duration
+	pattern *regexp.Regexp
+}
+
+// AlertState tracks one group of a rule, e.g. a single pod
+type AlertState struct {
+	RuleID      string    `json:"ruleId"`
+	RuleName    string    `json:"ruleName"`
+	Group       string    `json:"group"`
+	Status      string    `json:"status"`
+	Count       int       `json:"count"`
+	Threshold   int       `json:"threshold"`
+	StartedAt   time.Time `json:"startedAt"`
+	ResolvedAt  time.Time `json:"resolvedAt,omitempty"`
+	LastMatchAt time.Time `json:"lastMatchAt"`
+	Sample      string    `json:"sample"`
+}
+
+// Notification is delivered to sinks on every firing/resolved transition
+type Notification struct {
+	RuleID    string    `json:"ruleId"`
+	RuleName  string    `json:"ruleName"`
+	Group     string    `json:"group"`
+	Status    string    `json:"status"`
+	Count     int       `json:"count"`
+	Threshold int       `json:"threshold"`
+	Window    string    `json:"window"`
+	StartedAt time.Time `json:"startedAt"`
+	Sample    string    `json:"sample"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Validate checks a rule and compiles its window and pattern
+func (r *AlertRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.ContainsFunc(r.Name, unicode.IsControl) {
+		return fmt.Errorf("name must not contain control characters")
+	}
+	if len(r.Query.Clusters) == 0 || len(r.Query.Namespaces) == 0 {
+		return fmt.Errorf("query must include at least one cluster and namespace")
+	}
+	if r.Query.SearchTerm == "" && r.Pattern == "" && len(r.Query.LogLevels) == 0 {
+		return fmt.Errorf("query must include a searchTerm, logLevels or pattern")
+	}
+	if r.Threshold < 1 {
+		return fmt.Errorf("threshold must be at least 1")
+	}
+
+	window, err := time.ParseDuration(r.Window)
+	if err != nil {
+		return fmt.Errorf("invalid window %q: %w", r.Window, err)
+	}
+	if window < 10*time.Second {
+		return fmt.Errorf("window must be at least 10s")
+	}
+	r.window = window
+
+	r.pattern = nil
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.pattern = pattern
+	}
+
+	switch r.GroupBy {
+	case "":
+		r.GroupBy = GroupByNone
+	case GroupByNone, GroupByCluster, GroupByNamespace, GroupByPod, GroupByDeployment:
+	default:
+		return fmt.Errorf("unsupported groupBy %q", r.GroupBy)
+	}
+
+	return nil
+}
+
+// matches checks if a log entry matches the rule's pattern
+func (r *AlertRule) matches(entry models.LogEntry) bool {
+	return r.pattern == nil || r.pattern.MatchString(entry.Message)
+}
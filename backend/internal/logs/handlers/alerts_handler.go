@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"github.com/prasad/kaptivan/backend/internal/logs/alerts"
+	"github.com/prasad/kaptivan/backend/internal/logs/services"
+)
+
+// AlertsHandler handles log alert rule and sink management
+type AlertsHandler struct {
+	engine *alerts.Engine
+}
+
+// NewAlertsHandler creates an alerts handler backed by a running alert engine
+// that polls the log aggregator. Sinks are limited by alerts.SinkPolicyFromEnv.
+func NewAlertsHandler(manager *kubernetes.ClusterManager) *AlertsHandler {
+	engine := alerts.NewEngine(services.NewLogAggregator(manager), 15*time.Second, alerts.SinkPolicyFromEnv())
+	engine.Start(context.Background())
+
+	return &AlertsHandler{engine: engine}
+}
+
+// Engine returns the alert engine, e.g. to feed it lines from live log streams
+func (h *AlertsHandler) Engine() *alerts.Engine {
+	return h.engine
+}
+
+// Close stops the alert engine
+func (h *AlertsHandler) Close() {
+	h.engine.Stop()
+}
+
+// ListRules handles GET /alerts/rules
+func (h *AlertsHandler) ListRules(c *gin.Context) {
+	rules := h.engine.ListRules()
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// GetRule handles GET /alerts/rules/:id
+func (h *AlertsHandler) GetRule(c *gin.Context) {
+	rule, ok := h.engine.GetRule(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateRule handles POST /alerts/rules
+func (h *AlertsHandler) CreateRule(c *gin.Context) {
+	var rule alerts.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.engine.AddRule(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateRule handles PUT /alerts/rules/:id
+func (h *AlertsHandler) UpdateRule(c *gin.Context) {
+	var rule alerts.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := h.engine.GetRule(c.Param("id")); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	updated, err := h.engine.UpdateRule(c.Param("id"), rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteRule handles DELETE /alerts/rules/:id
+func (h *AlertsHandler) DeleteRule(c *gin.Context) {
+	if err := h.engine.DeleteRule(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted"})
+}
+
+// ListStates handles GET /alerts/active. Pass all=true to include resolved groups.
+func (h *AlertsHandler) ListStates(c *gin.Context) {
+	states := h.engine.States(c.Query("all") != "true")
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": states,
+		"count":  len(states),
+	})
+}
+
+// ListHistory handles GET /alerts/history
+func (h *AlertsHandler) ListHistory(c *gin.Context) {
+	history := h.engine.History()
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": history,
+		"count":         len(history),
+	})
+}
+
+// ListSinks handles GET /alerts/sinks
+func (h *AlertsHandler) ListSinks(c *gin.Context) {
+	sinks := h.engine.ListSinks()
+	c.JSON(http.StatusOK, gin.H{
+		"sinks": sinks,
+		"count": len(sinks),
+	})
+}
+
+// CreateSink handles POST /alerts/sinks
+func (h *AlertsHandler) CreateSink(c *gin.Context) {
+	var config alerts.SinkConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.engine.AddSink(config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, config.Redacted())
+}
+
+// DeleteSink handles DELETE /alerts/sinks/:name
+func (h *AlertsHandler) DeleteSink(c *gin.Context) {
+	if err := h.engine.RemoveSink(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sink deleted"})
+}
+
+// TestSink handles POST /alerts/sinks/:name/test
+func (h *AlertsHandler) TestSink(c *gin.Context) {
+	if err := h.engine.TestSink(c.Request.Context(), c.Param("name")); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Test notification sent"})
+}
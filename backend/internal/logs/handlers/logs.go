@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,17 +16,62 @@ import (
 // LogsHandler handles log-related HTTP requests
 type LogsHandler struct {
 	aggregator *services.LogAggregator
+	exporter   *services.LogExporter
 }
 
 // NewLogsHandler creates a new logs handler
 func NewLogsHandler(manager *kubernetes.ClusterManager) *LogsHandler {
 	return &LogsHandler{
 		aggregator: services.NewLogAggregator(manager),
+		exporter:   services.NewLogExporter(manager),
 	}
 }
 
 // GetLogs handles GET /api/logs request
 func (h *LogsHandler) GetLogs(c *gin.Context) {
+	query := parseLogQuery(c)
+	
+	// Set defaults
+	if query.Limit == 0 {
+		query.Limit = 1000
+	}
+	
+	// Fetch logs
+	response, err := h.aggregator.FetchLogs(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLogStats handles GET /api/logs/stats request
+func (h *LogsHandler) GetLogStats(c *gin.Context) {
+	query := parseLogQuery(c)
+	
+	// Optional fixed bucket size, e.g. "30s" or "5m"; chosen from the range otherwise
+	var bucketSize time.Duration
+	if bucket := c.Query("bucket"); bucket != "" {
+		val, err := time.ParseDuration(bucket)
+		if err != nil || val < time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be a duration of at least 1s"})
+			return
+		}
+		bucketSize = val
+	}
+	
+	stats, err := h.aggregator.ComputeStats(c.Request.Context(), query, bucketSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, stats)
+}
+
+// parseLogQuery builds a log query from URL query parameters
+func parseLogQuery(c *gin.Context) models.LogQuery {
 	query := models.LogQuery{
 		Clusters:   c.QueryArray("clusters"),
 		Namespaces: c.QueryArray("namespaces"),
@@ -47,11 +94,6 @@ func (h *LogsHandler) GetLogs(c *gin.Context) {
 		}
 	}
 	
-	// Set defaults
-	if query.Limit == 0 {
-		query.Limit = 1000
-	}
-	
 	// Parse time range if provided
 	if startTime := c.Query("startTime"); startTime != "" {
 		if t, err := time.Parse(time.RFC3339, startTime); err == nil {
@@ -65,14 +107,7 @@ func (h *LogsHandler) GetLogs(c *gin.Context) {
 		}
 	}
 	
-	// Fetch logs
-	response, err := h.aggregator.FetchLogs(c.Request.Context(), query)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	
-	c.JSON(http.StatusOK, response)
+	return query
 }
 
 // SearchLogs handles POST /api/logs/search request
@@ -97,4 +132,46 @@ func (h *LogsHandler) SearchLogs(c *gin.Context) {
 	}
 	
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// ExportLogs handles GET/POST /api/logs/export and streams a tar.gz or zip archive
+// with one file per container plus a manifest.json. GET takes the same filters as
+// GetLogs; POST takes a LogQuery body. The archive format is the "format" query param.
+func (h *LogsHandler) ExportLogs(c *gin.Context) {
+	var query models.LogQuery
+	if c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		query = parseLogQuery(c)
+		query.Previous = c.Query("previous") == "true"
+	}
+
+	if len(query.Clusters) == 0 || len(query.Namespaces) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clusters and namespaces are required"})
+		return
+	}
+
+	format, err := services.ValidateArchiveFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/gzip"
+	if format == services.ArchiveFormatZip {
+		contentType = "application/zip"
+	}
+	filename := fmt.Sprintf("logs-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so failures can only be logged
+	if _, err := h.exporter.Export(c.Request.Context(), query, format, c.Writer); err != nil {
+		log.Printf("Log export failed: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLogStatsValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/logs/stats", NewLogsHandler(nil).GetLogStats)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"unparseable bucket", "bucket=soon", http.StatusBadRequest},
+		{"bucket under a second", "bucket=500ms", http.StatusBadRequest},
+		{"end before start", "startTime=2024-05-02T00:00:00Z&endTime=2024-05-01T00:00:00Z", http.StatusBadRequest},
+		{"too many buckets", "startTime=2024-05-01T00:00:00Z&endTime=2024-05-31T00:00:00Z&bucket=1s", http.StatusBadRequest},
+		{"fixed bucket", "startTime=2024-05-01T00:00:00Z&endTime=2024-05-01T01:00:00Z&bucket=5m", http.StatusOK},
+		{"chosen bucket", "startTime=2024-05-01T00:00:00Z&endTime=2024-05-02T00:00:00Z", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/logs/stats?"+tt.query, nil))
+			assert.Equal(t, tt.code, recorder.Code, recorder.Body.String())
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/logs/stats?startTime=2024-05-01T00:00:00Z&endTime=2024-05-01T01:00:00Z&bucket=5m", nil))
+	var stats models.LogStats
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stats))
+	assert.Equal(t, int64(300), stats.BucketSeconds)
+	assert.Len(t, stats.Histogram, 13)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultTopPatterns is the number of templates returned when no limit is given
+const defaultTopPatterns = 20
+
+// GetTopPatterns returns the most frequent log templates for a search query
+func (h *SearchHandler) GetTopPatterns(c *gin.Context) {
+	opts := h.parseSearchOptions(c)
+	opts.Limit = 0 // Patterns are mined over every match, not a page of results
+
+	top := defaultTopPatterns
+	if topStr := c.Query("top"); topStr != "" {
+		if val, err := strconv.Atoi(topStr); err == nil && val > 0 {
+			top = val
+		}
+	}
+
+	patterns, err := h.searchEngine.TopPatterns(c.Request.Context(), opts, top)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"patterns": patterns,
+		"count":    len(patterns),
+	})
+}
+
+// GetNewPatterns returns log templates that first appeared after a deploy.
+// The deploy time is either given directly via "since" (RFC3339) or resolved
+// from the current rollout of the deployment named by "cluster", "namespace"
+// and "deployment".
+func (h *SearchHandler) GetNewPatterns(c *gin.Context) {
+	opts := h.parseSearchOptions(c)
+
+	var since time.Time
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		t, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp"})
+			return
+		}
+		since = t
+	} else if deployment := c.Query("deployment"); deployment != "" {
+		cluster := c.DefaultQuery("cluster", "default")
+		namespace := c.DefaultQuery("namespace", "default")
+
+		t, err := h.resolveDeployTime(c.Request.Context(), cluster, namespace, deployment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		since = t
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either since or deployment is required"})
+		return
+	}
+
+	patterns, err := h.searchEngine.NewPatternsSince(since, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"since":    since,
+		"patterns": patterns,
+		"count":    len(patterns),
+	})
+}
+
+// resolveDeployTime finds when the current revision of a deployment was rolled out,
+// using the creation time of the ReplicaSet that carries its revision
+func (h *SearchHandler) resolveDeployTime(ctx context.Context, cluster, namespace, name string) (time.Time, error) {
+	client, err := h.clusterManager.GetPooledClient(ctx, cluster)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get client for cluster %s: %w", cluster, err)
+	}
+
+	deployment, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid selector on deployment %s/%s: %w", namespace, name, err)
+	}
+
+	replicaSets, err := client.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list replicasets for %s/%s: %w", namespace, name, err)
+	}
+
+	revision := deployment.Annotations["deployment.kubernetes.io/revision"]
+	var newest *appsv1.ReplicaSet
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		if revision != "" && rs.Annotations["deployment.kubernetes.io/revision"] == revision {
+			return rs.CreationTimestamp.Time, nil
+		}
+		if newest == nil || rs.CreationTimestamp.After(newest.CreationTimestamp.Time) {
+			newest = rs
+		}
+	}
+
+	if newest == nil {
+		return deployment.CreationTimestamp.Time, nil
+	}
+	return newest.CreationTimestamp.Time, nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"github.com/prasad/kaptivan/backend/internal/logs/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// discardWebSocket opens a WebSocket to a server that drops every message
+func discardWebSocket(t *testing.T) *SafeWebSocketConn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	safe := &SafeWebSocketConn{conn: conn}
+	t.Cleanup(func() { safe.Close() })
+	return safe
+}
+
+// TestStreamedLogsFeedPatternEndpoints verifies lines sent on a live log stream
+// are mined by the search handler and returned by the pattern endpoints
+func TestStreamedLogsFeedPatternEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	searchHandler := NewSearchHandler(nil)
+	streamHandler := NewStreamHandlerOptimized(&kubernetes.ClusterManager{})
+	streamHandler.AddLogObserver(searchHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &LogStream{
+		ctx:    ctx,
+		cancel: cancel,
+		conn:   discardWebSocket(t),
+		parser: streamHandler.parser,
+		stats:  newStreamStats(),
+	}
+
+	var lines strings.Builder
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&lines, "2025-01-01T12:00:%02dZ ERROR connection refused to 10.0.0.%d:5432\n", i, i)
+	}
+	lines.WriteString("2025-01-01T12:01:00Z INFO cache warmed for tenant acme\n")
+	streamHandler.processLogStreamWithReconnect(stream, io.NopCloser(strings.NewReader(lines.String())), "prod", "shop", "api-1", "app")
+
+	router := gin.New()
+	router.GET("/patterns", searchHandler.GetTopPatterns)
+	router.GET("/patterns/new", searchHandler.GetNewPatterns)
+
+	var top struct {
+		Patterns []search.LogPattern `json:"patterns"`
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/patterns?namespaces[]=shop", nil))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &top))
+	require.Len(t, top.Patterns, 2)
+	assert.Equal(t, 30, top.Patterns[0].Count)
+	assert.Equal(t, "<*> ERROR connection refused to <*>", top.Patterns[0].Template)
+
+	var fresh struct {
+		Patterns []search.LogPattern `json:"patterns"`
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/patterns/new?since=2025-01-01T12:00:30Z&namespaces[]=shop", nil))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &fresh))
+	require.Len(t, fresh.Patterns, 1)
+	assert.Contains(t, fresh.Patterns[0].Template, "cache warmed")
+
+	// Templates emitted only by other namespaces are filtered out
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/patterns/new?since=2025-01-01T12:00:30Z&namespaces[]=billing", nil))
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &fresh))
+	assert.Empty(t, fresh.Patterns)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
+)
+
+// RedactionHandler manages the redaction rules applied to every log pipeline
+type RedactionHandler struct {
+	redactor *redaction.Redactor
+}
+
+// NewRedactionHandler creates a handler for the shared redactor
+func NewRedactionHandler() *RedactionHandler {
+	return &RedactionHandler{redactor: redaction.Default()}
+}
+
+// ListRules handles GET /logs/redaction/rules. Pass namespace to list the
+// rules that apply to it.
+func (h *RedactionHandler) ListRules(c *gin.Context) {
+	rules := h.redactor.ListRules(c.Query("namespace"))
+	c.JSON(http.StatusOK, gin.H{
+		"detectors": h.redactor.Detectors(),
+		"rules":     rules,
+		"count":     len(rules),
+	})
+}
+
+// CreateRule handles POST /logs/redaction/rules
+func (h *RedactionHandler) CreateRule(c *gin.Context) {
+	var rule redaction.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.redactor.AddRule(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteRule handles DELETE /logs/redaction/rules/:id
+func (h *RedactionHandler) DeleteRule(c *gin.Context) {
+	if err := h.redactor.DeleteRule(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted"})
+}
+
+// SetDetector handles PUT /logs/redaction/detectors/:name
+func (h *RedactionHandler) SetDetector(c *gin.Context) {
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.redactor.SetDetectorEnabled(c.Param("name"), req.Enabled); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"detectors": h.redactor.Detectors()})
+}
+
+// Preview handles POST /logs/redaction/preview, showing how a line would be redacted
+func (h *RedactionHandler) Preview(c *gin.Context) {
+	var req struct {
+		Namespace string `json:"namespace"`
+		Line      string `json:"line" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redacted, counts := h.redactor.Redact(req.Namespace, req.Line)
+	c.JSON(http.StatusOK, gin.H{
+		"redacted":   redacted,
+		"redactions": counts,
+	})
+}
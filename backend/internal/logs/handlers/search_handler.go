@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,13 +13,22 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/prasad/kaptivan/backend/internal/logs/models"
 	"github.com/prasad/kaptivan/backend/internal/logs/search"
+	"github.com/prasad/kaptivan/backend/internal/logs/services"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
 )
 
+// searchTailLines is how many recent lines are read per container when a
+// search has no start time
+const searchTailLines = 500
+
 // SearchHandler handles log search operations with optimization
 type SearchHandler struct {
 	clusterManager *ClusterManagerWithPool
 	searchEngine   *search.SearchEngine
+	patterns       *services.LogPatterns
+	timestamps     *services.TimestampParser
 	wsUpgrader     websocket.Upgrader
 }
 
@@ -26,6 +37,8 @@ func NewSearchHandler(manager *ClusterManagerWithPool) *SearchHandler {
 	return &SearchHandler{
 		clusterManager: manager,
 		searchEngine:   search.NewSearchEngine(),
+		patterns:       services.NewLogPatterns(),
+		timestamps:     services.NewTimestampParser(),
 		wsUpgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins in development
@@ -36,11 +49,36 @@ func NewSearchHandler(manager *ClusterManagerWithPool) *SearchHandler {
 	}
 }
 
+// Observe indexes a line sent on a live log stream and mines its template, so
+// the pattern endpoints cover streamed logs. The stream's parser has already
+// redacted the line.
+func (h *SearchHandler) Observe(entry models.LogEntry) {
+	h.searchEngine.IndexRedactedLog(search.LogEntry{
+		Timestamp: entry.Timestamp,
+		Namespace: entry.Namespace,
+		Pod:       entry.Pod,
+		Container: entry.Container,
+		Level:     entry.Level,
+		Message:   entry.Message,
+	})
+}
+
 // HandleSearchLogs handles optimized log search requests
 func (h *SearchHandler) HandleSearchLogs(c *gin.Context) {
 	// Parse search options from query parameters
 	opts := h.parseSearchOptions(c)
 	
+	// Optional rollout time used to flag templates that only appear afterwards
+	var deployedAt *time.Time
+	if deployedStr := c.Query("deployedAt"); deployedStr != "" {
+		t, err := time.Parse(time.RFC3339, deployedStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deployedAt must be an RFC3339 timestamp"})
+			return
+		}
+		deployedAt = &t
+	}
+	
 	// Upgrade to WebSocket
 	conn, err := h.wsUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -64,23 +102,42 @@ func (h *SearchHandler) HandleSearchLogs(c *gin.Context) {
 	ticker := time.NewTicker(batchInterval)
 	defer ticker.Stop()
 	
+	// Templates of the results matched by this query
+	queryMiner := search.NewPatternMiner(search.DefaultPatternMinerConfig())
+	patternsTicker := time.NewTicker(5 * time.Second)
+	defer patternsTicker.Stop()
+	
+	// processLog indexes a streamed line, flags new templates and batches matches
+	processLog := func(log search.LogEntry) {
+		// Index the log for future searches; the indexed entry is redacted
+		// before it is mined or sent
+		log, update := h.searchEngine.IndexLog(log)
+		if update.Created && deployedAt != nil && update.Pattern.FirstSeen.After(*deployedAt) {
+			conn.WriteJSON(models.StreamMessage{
+				Type:    "new_pattern",
+				Data:    update.Pattern,
+				EventID: generateSecureEventID(),
+			})
+		}
+		
+		// Perform search on the log
+		result := h.searchLog(log, opts)
+		if result != nil {
+			queryMiner.Add(log)
+			batch = append(batch, *result)
+			
+			// Send batch if full
+			if len(batch) >= batchSize {
+				h.sendBatch(conn, batch)
+				batch = batch[:0]
+			}
+		}
+	}
+	
 	for {
 		select {
 		case log := <-searchCh:
-			// Index the log for future searches
-			h.searchEngine.IndexLog(log)
-			
-			// Perform search on the log
-			result := h.searchLog(log, opts)
-			if result != nil {
-				batch = append(batch, *result)
-				
-				// Send batch if full
-				if len(batch) >= batchSize {
-					h.sendBatch(conn, batch)
-					batch = batch[:0]
-				}
-			}
+			processLog(log)
 			
 		case <-ticker.C:
 			// Send partial batch on interval
@@ -89,6 +146,12 @@ func (h *SearchHandler) HandleSearchLogs(c *gin.Context) {
 				batch = batch[:0]
 			}
 			
+		case <-patternsTicker.C:
+			// Send the current top templates for this query
+			if queryMiner.Size() > 0 {
+				h.sendPatterns(conn, queryMiner)
+			}
+			
 		case err := <-errorCh:
 			// Send error message
 			msg := models.StreamMessage{
@@ -99,10 +162,18 @@ func (h *SearchHandler) HandleSearchLogs(c *gin.Context) {
 			conn.WriteJSON(msg)
 			
 		case <-done:
+			// Process lines still buffered when the search finished
+			for len(searchCh) > 0 {
+				processLog(<-searchCh)
+			}
+			
 			// Send final batch
 			if len(batch) > 0 {
 				h.sendBatch(conn, batch)
 			}
+			if queryMiner.Size() > 0 {
+				h.sendPatterns(conn, queryMiner)
+			}
 			
 			// Send completion message with metrics
 			metrics := h.searchEngine.GetMetrics()
@@ -229,9 +300,65 @@ func (h *SearchHandler) performSearch(ctx context.Context, opts search.SearchOpt
 			}
 			
 			// Stream container logs
-			// TODO: Implement streamContainerLogs
+			if err := h.streamContainerLogs(ctx, client, &pod, container.Name, opts, searchCh); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				select {
+				case errorCh <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// streamContainerLogs reads the logs of a container from the search start time,
+// or its most recent lines, and sends each line for indexing and matching
+func (h *SearchHandler) streamContainerLogs(ctx context.Context, client k8sclient.Interface, pod *corev1.Pod, container string, opts search.SearchOptions, searchCh chan<- search.LogEntry) error {
+	logOpts := &corev1.PodLogOptions{
+		Container:  container,
+		Timestamps: true,
+	}
+	if opts.StartTime != nil {
+		sinceTime := metav1.NewTime(*opts.StartTime)
+		logOpts.SinceTime = &sinceTime
+	} else {
+		tailLines := int64(searchTailLines)
+		logOpts.TailLines = &tailLines
+	}
+
+	stream, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOpts).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to stream logs from %s/%s/%s: %w", pod.Namespace, pod.Name, container, err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text()
+		entry := search.LogEntry{
+			Timestamp: h.timestamps.ExtractTimestamp(line, []*services.LogPatterns{h.patterns}),
+			Namespace: pod.Namespace,
+			Pod:       pod.Name,
+			Container: container,
+			Level:     h.patterns.ExtractLogLevel(line),
+			Message:   line,
+			Labels:    pod.Labels,
+		}
+
+		select {
+		case searchCh <- entry:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read logs from %s/%s/%s: %w", pod.Namespace, pod.Name, container, err)
+	}
+	return nil
 }
 
 // matchesPod checks if a pod matches search criteria
@@ -328,6 +455,19 @@ func (h *SearchHandler) sendBatch(conn *websocket.Conn, batch []search.SearchRes
 	}
 }
 
+// sendPatterns sends the most frequent templates of a query over WebSocket
+func (h *SearchHandler) sendPatterns(conn *websocket.Conn, miner *search.PatternMiner) {
+	msg := models.StreamMessage{
+		Type:    "patterns",
+		Data:    miner.TopPatterns(defaultTopPatterns),
+		EventID: generateSecureEventID(),
+	}
+	
+	if err := conn.WriteJSON(msg); err != nil {
+		println("Error sending patterns:", err.Error())
+	}
+}
+
 // GetSearchMetrics returns current search metrics
 func (h *SearchHandler) GetSearchMetrics(c *gin.Context) {
 	metrics := h.searchEngine.GetMetrics()
@@ -36,6 +36,38 @@ type LogStream struct {
 	manager    *kubernetes.ClusterManager
 	parser     *services.LogParser
 	activeJobs sync.WaitGroup
+	stats      *streamStats
+}
+
+// streamStats accumulates statistics for the logs sent on a stream
+type streamStats struct {
+	builder   *services.LogStatsBuilder
+	startedAt time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+const (
+	// statsInterval is how often a stream sends a stats message
+	statsInterval = 10 * time.Second
+	// statsWindow is the histogram range covered by stream stats messages
+	statsWindow = 15 * time.Minute
+)
+
+// newStreamStats creates a stats accumulator for a live stream
+func newStreamStats() *streamStats {
+	now := time.Now()
+	return &streamStats{
+		builder:   services.NewLogStatsBuilder(services.ChooseBucketSize(now.Add(-statsWindow), now)),
+		startedAt: now,
+		now:       time.Now,
+	}
+}
+
+// LogObserver receives every log line sent on a stream, e.g. the alert engine
+// or the search engine that mines log patterns
+type LogObserver interface {
+	Observe(entry models.LogEntry)
 }
 
 // StreamHandlerOptimized handles WebSocket connections for real-time log streaming
@@ -44,6 +76,7 @@ type StreamHandlerOptimized struct {
 	parser        *services.LogParser
 	streamManager *StreamManager
 	clientPool    *ClientPool
+	observers     []LogObserver
 }
 
 // ClientPool manages Kubernetes client connections
@@ -64,6 +97,12 @@ func NewStreamHandlerOptimized(manager *kubernetes.ClusterManager) *StreamHandle
 	}
 }
 
+// AddLogObserver feeds the lines of every stream to observer. Observers are
+// added while routes are registered, before any stream starts.
+func (h *StreamHandlerOptimized) AddLogObserver(observer LogObserver) {
+	h.observers = append(h.observers, observer)
+}
+
 // getClient gets or creates a Kubernetes client for a cluster
 func (cp *ClientPool) getClient(manager *kubernetes.ClusterManager, cluster string) (k8sclient.Interface, error) {
 	// Try to get existing client
@@ -134,6 +173,7 @@ func (h *StreamHandlerOptimized) StreamLogs(c *gin.Context) {
 		manager: h.manager,
 		parser:  h.parser,
 		query:   query, // Set initial query from URL params
+		stats:   newStreamStats(),
 	}
 
 	// Register stream
@@ -149,7 +189,7 @@ func (h *StreamHandlerOptimized) StreamLogs(c *gin.Context) {
 		stream.activeJobs.Wait() // Wait for all goroutines to finish
 	}()
 
-	// Start connection health monitor
+	// Start connection health monitor, which also publishes stream stats
 	go h.monitorConnection(stream)
 
 	// Log received query parameters for debugging
@@ -177,10 +217,13 @@ func (h *StreamHandlerOptimized) StreamLogs(c *gin.Context) {
 	<-ctx.Done()
 }
 
-// monitorConnection monitors WebSocket connection health
+// monitorConnection monitors WebSocket connection health and periodically sends
+// histogram and volume statistics for the stream
 func (h *StreamHandlerOptimized) monitorConnection(stream *LogStream) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
+	statsTicker := time.NewTicker(statsInterval)
+	defer statsTicker.Stop()
 
 	for {
 		select {
@@ -194,10 +237,56 @@ func (h *StreamHandlerOptimized) monitorConnection(stream *LogStream) {
 				stream.cancel()
 				return
 			}
+		case <-statsTicker.C:
+			stats := stream.stats.snapshot()
+			if stats.TotalLogs == 0 {
+				continue
+			}
+			if err := stream.conn.WriteJSON(models.StreamMessage{
+				Type:    "stats",
+				Data:    stats,
+				EventID: generateSecureEventID(),
+			}); err != nil {
+				stream.cancel()
+				return
+			}
 		}
 	}
 }
 
+// add records sent log entries
+func (s *streamStats) add(logs []models.LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range logs {
+		s.builder.Add(entry)
+	}
+}
+
+// reset discards accumulated statistics, e.g. when the query changes
+func (s *streamStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.builder = services.NewLogStatsBuilder(services.ChooseBucketSize(now.Add(-statsWindow), now))
+	s.startedAt = now
+}
+
+// snapshot builds statistics for the most recent window of the stream; logs
+// older than the window no longer count towards any of the figures
+func (s *streamStats) snapshot() *models.LogStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end := s.now()
+	start := end.Add(-statsWindow)
+	if s.startedAt.After(start) {
+		start = s.startedAt
+	}
+	s.builder.Prune(start)
+	return s.builder.Build(start, end)
+}
+
 // handleStreamMessages handles incoming WebSocket messages
 func (h *StreamHandlerOptimized) handleStreamMessages(stream *LogStream) {
 	for {
@@ -212,6 +301,7 @@ func (h *StreamHandlerOptimized) handleStreamMessages(stream *LogStream) {
 
 		// Update query
 		stream.query = query
+		stream.stats.reset()
 
 		// Start native Kubernetes log streaming for each pod/container
 		h.startNativeStreaming(stream)
@@ -619,6 +709,15 @@ func (h *StreamHandlerOptimized) sendLogBatch(stream *LogStream, logs []models.L
 	logsCopy := make([]models.LogEntry, len(logs))
 	copy(logsCopy, logs)
 
+	if stream.stats != nil {
+		stream.stats.add(logsCopy)
+	}
+	for _, observer := range h.observers {
+		for _, entry := range logsCopy {
+			observer.Observe(entry)
+		}
+	}
+
 	stream.conn.WriteJSON(models.StreamMessage{
 		Type:    "logs",
 		Data:    logsCopy,
@@ -100,6 +100,37 @@ func TestConnectionPooling(t *testing.T) {
 	}
 }
 
+// TestStreamStatsWindow verifies stream stats only count logs inside the stats window
+func TestStreamStatsWindow(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	stats := newStreamStats()
+	stats.startedAt = clock
+	stats.now = func() time.Time { return clock }
+
+	stats.add([]models.LogEntry{
+		{Timestamp: clock.Add(time.Second), Level: "ERROR", Cluster: "prod", Namespace: "shop", Pod: "web-1", Message: "timeout"},
+		{Timestamp: clock.Add(2 * time.Second), Level: "INFO", Cluster: "prod", Namespace: "shop", Pod: "web-1", Message: "ok"},
+	})
+	assert.Equal(t, 2, stats.snapshot().TotalLogs)
+
+	clock = clock.Add(statsWindow + 2*time.Minute)
+	stats.add([]models.LogEntry{
+		{Timestamp: clock.Add(-time.Second), Level: "INFO", Cluster: "prod", Namespace: "shop", Pod: "api-1", Message: "ok"},
+	})
+	snapshot := stats.snapshot()
+
+	histogramTotal := 0
+	for _, bucket := range snapshot.Histogram {
+		histogramTotal += bucket.Count
+	}
+	assert.Equal(t, 1, snapshot.TotalLogs)
+	assert.Equal(t, snapshot.TotalLogs, histogramTotal)
+	assert.Equal(t, map[string]int{"INFO": 1}, snapshot.LogsPerLevel)
+	assert.Zero(t, snapshot.ErrorRate)
+	assert.Equal(t, map[string]int{"prod/shop/api-1": 1}, snapshot.LogsPerPod)
+	assert.Empty(t, snapshot.TopErrorPatterns)
+}
+
 // BenchmarkStreamingVsPolling benchmarks the performance difference
 func BenchmarkStreamingVsPolling(b *testing.B) {
 	b.Run("Polling", func(b *testing.B) {
@@ -31,6 +31,7 @@ type LogQuery struct {
 	Limit      int       `json:"limit" form:"limit"`
 	Tail       int       `json:"tail" form:"tail"`
 	Follow     bool      `json:"follow" form:"follow"`
+	Previous   bool      `json:"previous" form:"previous"` // Include logs of previous container instances
 }
 
 // LogResponse represents the response containing logs
@@ -40,6 +41,7 @@ type LogResponse struct {
 	HasMore    bool       `json:"hasMore"`
 	Clusters   []string   `json:"clusters"`
 	Query      LogQuery   `json:"query"`
+	Stats      *LogStats  `json:"stats,omitempty"`
 }
 
 // LogHistogram represents log count over time for visualization
@@ -51,11 +53,72 @@ type LogHistogram struct {
 
 // LogStats represents statistics about logs
 type LogStats struct {
-	TotalLogs     int                    `json:"totalLogs"`
-	LogsPerLevel  map[string]int         `json:"logsPerLevel"`
-	LogsPerPod    map[string]int         `json:"logsPerPod"`
-	ErrorRate     float64                `json:"errorRate"`
-	Histogram     []LogHistogram         `json:"histogram"`
+	TotalLogs        int                    `json:"totalLogs"`
+	LogsPerLevel     map[string]int         `json:"logsPerLevel"`
+	LogsPerPod       map[string]int         `json:"logsPerPod"`
+	ErrorRate        float64                `json:"errorRate"`
+	Histogram        []LogHistogram         `json:"histogram"`
+	StartTime        time.Time              `json:"startTime"`
+	EndTime          time.Time              `json:"endTime"`
+	BucketSeconds    int64                  `json:"bucketSeconds"`
+	TopPods          []PodLogCount          `json:"topPods"`
+	TopErrorPatterns []ErrorPattern         `json:"topErrorPatterns"`
+}
+
+// PodLogCount represents the log volume of a single pod
+type PodLogCount struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Count     int    `json:"count"`
+	Errors    int    `json:"errors"`
+}
+
+// ErrorPattern represents a recurring error message template
+type ErrorPattern struct {
+	Template  string    `json:"template"`
+	Count     int       `json:"count"`
+	Sample    string    `json:"sample"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// LogArchiveFile describes one container log file in an exported archive
+type LogArchiveFile struct {
+	Path           string    `json:"path"`
+	Cluster        string    `json:"cluster"`
+	Namespace      string    `json:"namespace"`
+	Pod            string    `json:"pod"`
+	Container      string    `json:"container"`
+	Previous       bool      `json:"previous"`
+	Lines          int       `json:"lines"`
+	Bytes          int64     `json:"bytes"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+	Truncated      bool      `json:"truncated,omitempty"` // the file reached the per-file size limit
+}
+
+// LogArchiveFailure describes a pod or container whose logs could not be collected
+type LogArchiveFailure struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	Previous  bool   `json:"previous,omitempty"`
+	Error     string `json:"error"`
+}
+
+// LogArchiveManifest is written as manifest.json into every exported archive
+type LogArchiveManifest struct {
+	GeneratedAt time.Time           `json:"generatedAt"`
+	Format      string              `json:"format"`
+	Query       LogQuery            `json:"query"`
+	Files       []LogArchiveFile    `json:"files"`
+	Failures    []LogArchiveFailure `json:"failures"`
+	TotalFiles  int                 `json:"totalFiles"`
+	TotalLines  int                 `json:"totalLines"`
+	TotalBytes  int64               `json:"totalBytes"`
+	Truncated   bool                `json:"truncated,omitempty"` // a size limit cut a file or left containers out
 }
 
 // StreamMessage represents a message sent over WebSocket
@@ -0,0 +1,347 @@
+package redaction
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Built-in detector names
+const (
+	DetectorJWT        = "jwt"
+	DetectorAWSKey     = "aws_key"
+	DetectorBearer     = "bearer_token"
+	DetectorCredential = "credential"
+	DetectorEmail      = "email"
+	DetectorCard       = "credit_card"
+)
+
+// redactedPrefix starts every replacement so redacted text is never redacted twice
+const redactedPrefix = "[REDACTED:"
+
+// Recorder receives a count for every redaction performed
+type Recorder interface {
+	RecordRedaction(detector string, count int)
+}
+
+// Rule is a custom redaction rule scoped to a namespace
+type Rule struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"` // Empty applies the rule to every namespace
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"` // Defaults to [REDACTED:<name>]
+
+	regex *regexp.Regexp
+}
+
+// DetectorInfo describes a built-in detector
+type DetectorInfo struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// detector finds sensitive values. When group is non-zero only that
+// submatch is replaced, keeping surrounding context such as "password=".
+type detector struct {
+	name     string
+	regex    *regexp.Regexp
+	group    int
+	validate func(match string) bool
+	enabled  bool
+}
+
+// Redactor masks secrets and personal data in log lines
+type Redactor struct {
+	detectors []*detector
+	rules     []*Rule // applied in the order they were added
+	recorders []Recorder
+	mu        sync.RWMutex
+}
+
+var defaultRedactor = NewRedactor()
+
+// Default returns the process-wide redactor shared by all log pipelines
+func Default() *Redactor {
+	return defaultRedactor
+}
+
+// NewRedactor creates a redactor with all built-in detectors enabled
+func NewRedactor() *Redactor {
+	return &Redactor{
+		detectors: []*detector{
+			{
+				name:    DetectorJWT,
+				regex:   regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`),
+				enabled: true,
+			},
+			{
+				name:    DetectorAWSKey,
+				regex:   regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`),
+				enabled: true,
+			},
+			{
+				name:    DetectorAWSKey,
+				regex:   regexp.MustCompile(`(?i)\baws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`),
+				group:   1,
+				enabled: true,
+			},
+			{
+				name:    DetectorBearer,
+				regex:   regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+				group:   1,
+				enabled: true,
+			},
+			{
+				name:    DetectorCredential,
+				regex:   regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|token)["']?\s*[:=]\s*["']?([^\s"',;&]+)`),
+				group:   1,
+				enabled: true,
+			},
+			{
+				name:    DetectorEmail,
+				regex:   regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+				enabled: true,
+			},
+			{
+				name:     DetectorCard,
+				regex:    regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+				validate: luhnValid,
+				enabled:  true,
+			},
+		},
+	}
+}
+
+// AddRecorder registers a recorder that is told about every redaction
+func (r *Redactor) AddRecorder(recorder Recorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorders = append(r.recorders, recorder)
+}
+
+// Redact masks sensitive values in a log line from the given namespace and
+// returns the redacted line with the number of redactions per detector
+func (r *Redactor) Redact(namespace, text string) (string, map[string]int) {
+	if text == "" {
+		return text, nil
+	}
+
+	r.mu.RLock()
+	var counts map[string]int
+	record := func(name string, n int) {
+		if n == 0 {
+			return
+		}
+		if counts == nil {
+			counts = make(map[string]int)
+		}
+		counts[name] += n
+	}
+
+	for _, d := range r.detectors {
+		if !d.enabled {
+			continue
+		}
+		var n int
+		text, n = replaceMatches(text, d.regex, d.group, redactedPrefix+d.name+"]", d.validate)
+		record(d.name, n)
+	}
+
+	for _, rule := range r.rules {
+		if rule.Namespace != "" && rule.Namespace != namespace {
+			continue
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = redactedPrefix + rule.Name + "]"
+		}
+		var n int
+		text, n = replaceMatches(text, rule.regex, 0, replacement, nil)
+		record(rule.Name, n)
+	}
+
+	recorders := r.recorders
+	r.mu.RUnlock()
+
+	for name, n := range counts {
+		for _, recorder := range recorders {
+			recorder.RecordRedaction(name, n)
+		}
+	}
+
+	return text, counts
+}
+
+// RedactLine is Redact without the per-detector counts
+func (r *Redactor) RedactLine(namespace, text string) string {
+	redacted, _ := r.Redact(namespace, text)
+	return redacted
+}
+
+// AddRule validates and stores a custom rule
+func (r *Redactor) AddRule(rule Rule) (*Rule, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	regex, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	rule.regex = regex
+	rule.ID = generateRuleID()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, &rule)
+
+	stored := rule
+	return &stored, nil
+}
+
+// DeleteRule removes a custom rule
+func (r *Redactor) DeleteRule(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rule := range r.rules {
+		if rule.ID == id {
+			r.rules = append(r.rules[:i:i], r.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("rule %s not found", id)
+}
+
+// ListRules returns custom rules, optionally limited to those that apply to a namespace
+func (r *Redactor) ListRules(namespace string) []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := []Rule{}
+	for _, rule := range r.rules {
+		if namespace != "" && rule.Namespace != "" && rule.Namespace != namespace {
+			continue
+		}
+		rules = append(rules, *rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Namespace != rules[j].Namespace {
+			return rules[i].Namespace < rules[j].Namespace
+		}
+		return rules[i].Name < rules[j].Name
+	})
+	return rules
+}
+
+// Detectors lists the built-in detectors and whether they are enabled
+func (r *Redactor) Detectors() []DetectorInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	infos := []DetectorInfo{}
+	for _, d := range r.detectors {
+		if seen[d.name] {
+			continue
+		}
+		seen[d.name] = true
+		infos = append(infos, DetectorInfo{Name: d.name, Enabled: d.enabled})
+	}
+	return infos
+}
+
+// SetDetectorEnabled turns a built-in detector on or off
+func (r *Redactor) SetDetectorEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := false
+	for _, d := range r.detectors {
+		if d.name == name {
+			d.enabled = enabled
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("detector %s not found", name)
+	}
+	return nil
+}
+
+// replaceMatches replaces every match (or the given submatch group) with the
+// replacement and returns the number of replacements
+func replaceMatches(text string, regex *regexp.Regexp, group int, replacement string, validate func(string) bool) (string, int) {
+	indexes := regex.FindAllStringSubmatchIndex(text, -1)
+	if len(indexes) == 0 {
+		return text, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	count := 0
+	for _, idx := range indexes {
+		start, end := idx[2*group], idx[2*group+1]
+		if start < 0 {
+			continue
+		}
+		value := text[start:end]
+		if strings.HasPrefix(value, redactedPrefix) {
+			continue
+		}
+		if validate != nil && !validate(value) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(replacement)
+		last = end
+		count++
+	}
+	if count == 0 {
+		return text, 0
+	}
+	b.WriteString(text[last:])
+	return b.String(), count
+}
+
+// luhnValid checks a candidate card number with the Luhn algorithm
+func luhnValid(candidate string) bool {
+	digits := make([]int, 0, len(candidate))
+	for _, c := range candidate {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// generateRuleID generates a random rule ID
+func generateRuleID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "redact-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,429 @@
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wildcardToken marks a variable slot in a log template
+const wildcardToken = "<*>"
+
+// PatternMiner groups log lines into templates using a Drain-style parse tree.
+// Lines are routed by token count and their leading tokens to a small set of
+// candidate clusters; the most similar cluster absorbs the line and any
+// differing token positions become variable slots.
+type PatternMiner struct {
+	root        *minerNode
+	clusters    map[int]*patternCluster
+	nextID      int
+	depth       int
+	similarity  float64
+	maxChildren int
+	maxClusters int
+	maskers     []*regexp.Regexp
+	mu          sync.RWMutex
+}
+
+// PatternMinerConfig tunes the parse tree
+type PatternMinerConfig struct {
+	Depth       int     // Number of tree levels including the length level
+	Similarity  float64 // Minimum token similarity for a line to join a cluster
+	MaxChildren int     // Maximum children per internal node before routing to a wildcard child
+	MaxClusters int     // Maximum number of templates kept in memory
+}
+
+// LogPattern is a point-in-time view of a mined template
+type LogPattern struct {
+	ID         int            `json:"id"`
+	Template   string         `json:"template"`
+	Count      int            `json:"count"`
+	FirstSeen  time.Time      `json:"firstSeen"`
+	LastSeen   time.Time      `json:"lastSeen"`
+	Levels     map[string]int `json:"levels"`
+	Namespaces map[string]int `json:"namespaces"`
+	Pods       map[string]int `json:"pods"`
+	Sample     string         `json:"sample"`
+}
+
+// PatternUpdate describes the outcome of adding a line to the miner
+type PatternUpdate struct {
+	Pattern LogPattern
+	Created bool // True when the line started a new template
+}
+
+type minerNode struct {
+	children map[string]*minerNode
+	clusters []*patternCluster
+}
+
+type patternCluster struct {
+	id         int
+	tokens     []string
+	count      int
+	firstSeen  time.Time
+	lastSeen   time.Time
+	levels     map[string]int
+	namespaces map[string]int
+	pods       map[string]int
+	sample     string
+	leaf       *minerNode
+}
+
+// DefaultPatternMinerConfig returns the parameters recommended by the Drain paper
+func DefaultPatternMinerConfig() PatternMinerConfig {
+	return PatternMinerConfig{
+		Depth:       4,
+		Similarity:  0.4,
+		MaxChildren: 100,
+		MaxClusters: 5000,
+	}
+}
+
+// NewPatternMiner creates a new pattern miner
+func NewPatternMiner(config PatternMinerConfig) *PatternMiner {
+	defaults := DefaultPatternMinerConfig()
+	if config.Depth < 3 {
+		config.Depth = defaults.Depth
+	}
+	if config.Similarity <= 0 || config.Similarity > 1 {
+		config.Similarity = defaults.Similarity
+	}
+	if config.MaxChildren <= 0 {
+		config.MaxChildren = defaults.MaxChildren
+	}
+	if config.MaxClusters <= 0 {
+		config.MaxClusters = defaults.MaxClusters
+	}
+
+	return &PatternMiner{
+		root:        newMinerNode(),
+		clusters:    make(map[int]*patternCluster),
+		nextID:      1,
+		depth:       config.Depth,
+		similarity:  config.Similarity,
+		maxChildren: config.MaxChildren,
+		maxClusters: config.MaxClusters,
+		maskers: []*regexp.Regexp{
+			// UUIDs
+			regexp.MustCompile(`(?i)\b[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}\b`),
+			// ISO 8601 timestamps
+			regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+			// IPv4 addresses with optional port
+			regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b`),
+			// Long hex identifiers (hashes, trace IDs, pod template hashes)
+			regexp.MustCompile(`(?i)\b(?:0x)?[a-f0-9]{12,}\b`),
+			// Numbers, durations and sizes
+			regexp.MustCompile(`\b-?\d+(?:\.\d+)?(?:ms|us|ns|s|m|h|b|kb|mb|gb|ki|mi|gi|%)?\b`),
+		},
+	}
+}
+
+func newMinerNode() *minerNode {
+	return &minerNode{children: make(map[string]*minerNode)}
+}
+
+// Add mines a log entry and returns the template it was assigned to
+func (pm *PatternMiner) Add(log LogEntry) PatternUpdate {
+	tokens := pm.tokenize(log.Message)
+	seenAt := log.Timestamp
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	leaf := pm.findLeaf(tokens, true)
+	cluster := pm.bestMatch(leaf.clusters, tokens)
+	created := false
+
+	if cluster == nil {
+		if len(pm.clusters) >= pm.maxClusters {
+			pm.evictStalest()
+		}
+		cluster = &patternCluster{
+			id:         pm.nextID,
+			tokens:     append([]string(nil), tokens...),
+			firstSeen:  seenAt,
+			lastSeen:   seenAt,
+			levels:     make(map[string]int),
+			namespaces: make(map[string]int),
+			pods:       make(map[string]int),
+			sample:     log.Message,
+			leaf:       leaf,
+		}
+		pm.nextID++
+		pm.clusters[cluster.id] = cluster
+		leaf.clusters = append(leaf.clusters, cluster)
+		created = true
+	} else {
+		for i, token := range tokens {
+			if cluster.tokens[i] != token {
+				cluster.tokens[i] = wildcardToken
+			}
+		}
+		if seenAt.Before(cluster.firstSeen) {
+			cluster.firstSeen = seenAt
+		}
+		if seenAt.After(cluster.lastSeen) {
+			cluster.lastSeen = seenAt
+		}
+	}
+
+	cluster.count++
+	if log.Level != "" {
+		cluster.levels[strings.ToUpper(log.Level)]++
+	}
+	if log.Namespace != "" {
+		cluster.namespaces[log.Namespace]++
+	}
+	if log.Pod != "" {
+		cluster.pods[log.Pod]++
+	}
+
+	return PatternUpdate{Pattern: cluster.snapshot(), Created: created}
+}
+
+// Match returns the template a message would be assigned to without mining it
+func (pm *PatternMiner) Match(message string) (LogPattern, bool) {
+	tokens := pm.tokenize(message)
+
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	leaf := pm.findLeaf(tokens, false)
+	if leaf == nil {
+		return LogPattern{}, false
+	}
+	cluster := pm.bestMatch(leaf.clusters, tokens)
+	if cluster == nil {
+		return LogPattern{}, false
+	}
+	return cluster.snapshot(), true
+}
+
+// TopPatterns returns up to limit templates ordered by occurrence count
+func (pm *PatternMiner) TopPatterns(limit int) []LogPattern {
+	patterns := pm.Patterns()
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].LastSeen.After(patterns[j].LastSeen)
+	})
+	if limit > 0 && len(patterns) > limit {
+		patterns = patterns[:limit]
+	}
+	return patterns
+}
+
+// NewSince returns templates whose first occurrence is after the given time,
+// newest first. Used to flag patterns introduced by a rollout.
+func (pm *PatternMiner) NewSince(since time.Time) []LogPattern {
+	patterns := []LogPattern{}
+	for _, pattern := range pm.Patterns() {
+		if pattern.FirstSeen.After(since) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].FirstSeen.After(patterns[j].FirstSeen)
+	})
+	return patterns
+}
+
+// Patterns returns all templates in no particular order
+func (pm *PatternMiner) Patterns() []LogPattern {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	patterns := make([]LogPattern, 0, len(pm.clusters))
+	for _, cluster := range pm.clusters {
+		patterns = append(patterns, cluster.snapshot())
+	}
+	return patterns
+}
+
+// Size returns the number of templates
+func (pm *PatternMiner) Size() int {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return len(pm.clusters)
+}
+
+// Reset removes all templates
+func (pm *PatternMiner) Reset() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.root = newMinerNode()
+	pm.clusters = make(map[int]*patternCluster)
+}
+
+// tokenize masks well-known variables and splits a message on whitespace
+func (pm *PatternMiner) tokenize(message string) []string {
+	masked := strings.TrimSpace(message)
+	for _, masker := range pm.maskers {
+		masked = masker.ReplaceAllString(masked, wildcardToken)
+	}
+	tokens := strings.Fields(masked)
+	if len(tokens) == 0 {
+		tokens = []string{""}
+	}
+	return tokens
+}
+
+// findLeaf walks the parse tree by token count and leading tokens.
+// When create is false a missing branch returns nil.
+func (pm *PatternMiner) findLeaf(tokens []string, create bool) *minerNode {
+	lengthKey := lengthToken(len(tokens))
+	node, ok := pm.root.children[lengthKey]
+	if !ok {
+		if !create {
+			return nil
+		}
+		node = newMinerNode()
+		pm.root.children[lengthKey] = node
+	}
+
+	// Root, length and leaf levels do not consume prefix tokens
+	prefixDepth := pm.depth - 3
+	for i := 0; i < prefixDepth && i < len(tokens); i++ {
+		key := tokens[i]
+		if hasDigit(key) {
+			key = wildcardToken
+		}
+
+		child, ok := node.children[key]
+		if !ok {
+			child, ok = node.children[wildcardToken]
+		}
+		if !ok {
+			if !create {
+				return nil
+			}
+			if len(node.children) >= pm.maxChildren-1 {
+				key = wildcardToken
+			}
+			child = newMinerNode()
+			node.children[key] = child
+		}
+		node = child
+	}
+
+	return node
+}
+
+// bestMatch returns the most similar cluster above the similarity threshold
+func (pm *PatternMiner) bestMatch(candidates []*patternCluster, tokens []string) *patternCluster {
+	var best *patternCluster
+	bestSim := -1.0
+	bestParams := -1
+
+	for _, cluster := range candidates {
+		sim, params := sequenceSimilarity(cluster.tokens, tokens)
+		if sim > bestSim || (sim == bestSim && params > bestParams) {
+			best = cluster
+			bestSim = sim
+			bestParams = params
+		}
+	}
+
+	if best == nil || bestSim < pm.similarity {
+		return nil
+	}
+	return best
+}
+
+// evictStalest removes the template that was seen least recently
+func (pm *PatternMiner) evictStalest() {
+	var stalest *patternCluster
+	for _, cluster := range pm.clusters {
+		if stalest == nil || cluster.lastSeen.Before(stalest.lastSeen) {
+			stalest = cluster
+		}
+	}
+	if stalest == nil {
+		return
+	}
+
+	delete(pm.clusters, stalest.id)
+	remaining := stalest.leaf.clusters[:0]
+	for _, cluster := range stalest.leaf.clusters {
+		if cluster != stalest {
+			remaining = append(remaining, cluster)
+		}
+	}
+	stalest.leaf.clusters = remaining
+}
+
+// snapshot copies a cluster into its public representation
+func (c *patternCluster) snapshot() LogPattern {
+	levels := make(map[string]int, len(c.levels))
+	for level, count := range c.levels {
+		levels[level] = count
+	}
+	namespaces := make(map[string]int, len(c.namespaces))
+	for namespace, count := range c.namespaces {
+		namespaces[namespace] = count
+	}
+	pods := make(map[string]int, len(c.pods))
+	for pod, count := range c.pods {
+		pods[pod] = count
+	}
+
+	return LogPattern{
+		ID:         c.id,
+		Template:   strings.Join(c.tokens, " "),
+		Count:      c.count,
+		FirstSeen:  c.firstSeen,
+		LastSeen:   c.lastSeen,
+		Levels:     levels,
+		Namespaces: namespaces,
+		Pods:       pods,
+		Sample:     c.sample,
+	}
+}
+
+// sequenceSimilarity returns the fraction of template tokens the line matches and
+// the number of wildcard slots in the template. As in Drain, a wildcard matches any
+// token, so templates made only of parameters still attract their lines.
+func sequenceSimilarity(template, tokens []string) (float64, int) {
+	if len(template) != len(tokens) || len(template) == 0 {
+		return 0, 0
+	}
+
+	matches := 0
+	params := 0
+	for i, token := range template {
+		if token == wildcardToken {
+			params++
+			matches++
+			continue
+		}
+		if token == tokens[i] {
+			matches++
+		}
+	}
+
+	return float64(matches) / float64(len(template)), params
+}
+
+// lengthToken builds the tree key for the token count level
+func lengthToken(n int) string {
+	return "len:" + strconv.Itoa(n)
+}
+
+// hasDigit reports whether a token contains a digit
+func hasDigit(token string) bool {
+	for _, r := range token {
+		if r >= '0' && r <= '9' {
+			return true
+		}
+	}
+	return false
+}
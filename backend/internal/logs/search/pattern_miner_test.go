@@ -0,0 +1,94 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPatternMinerGroupsSimilarLines verifies near-identical lines collapse into one template
+func TestPatternMinerGroupsSimilarLines(t *testing.T) {
+	miner := NewPatternMiner(DefaultPatternMinerConfig())
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		miner.Add(LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Pod:       fmt.Sprintf("api-%d", i%3),
+			Level:     "ERROR",
+			Message:   fmt.Sprintf("connection refused to 10.0.0.%d:5432 after %dms user=alice", i%250, i*10),
+		})
+	}
+	miner.Add(LogEntry{Timestamp: base, Level: "INFO", Message: "server started on port 8080"})
+
+	top := miner.TopPatterns(10)
+	assert.Len(t, top, 2)
+	assert.Equal(t, 100, top[0].Count)
+	assert.Equal(t, "connection refused to <*> after <*> user=alice", top[0].Template)
+	assert.Equal(t, base, top[0].FirstSeen)
+	assert.Equal(t, base.Add(99*time.Second), top[0].LastSeen)
+	assert.Equal(t, 100, top[0].Levels["ERROR"])
+	assert.Len(t, top[0].Pods, 3)
+}
+
+// TestPatternMinerParameterOnlyLines verifies lines made only of parameters share one template
+func TestPatternMinerParameterOnlyLines(t *testing.T) {
+	miner := NewPatternMiner(DefaultPatternMinerConfig())
+	for i := 0; i < 50; i++ {
+		miner.Add(LogEntry{Message: fmt.Sprintf("%d", 10000+i)})
+		miner.Add(LogEntry{Message: fmt.Sprintf("%d %d", i, i*7)})
+	}
+
+	top := miner.TopPatterns(10)
+	assert.Len(t, top, 2)
+	for _, pattern := range top {
+		assert.Equal(t, 50, pattern.Count, pattern.Template)
+	}
+}
+
+// TestPatternMinerVariableSlots verifies differing constant tokens become wildcards
+func TestPatternMinerVariableSlots(t *testing.T) {
+	miner := NewPatternMiner(DefaultPatternMinerConfig())
+
+	first := miner.Add(LogEntry{Message: "user alice logged in from web"})
+	second := miner.Add(LogEntry{Message: "user bob logged in from web"})
+
+	assert.True(t, first.Created)
+	assert.False(t, second.Created)
+	assert.Equal(t, first.Pattern.ID, second.Pattern.ID)
+	assert.Equal(t, "user <*> logged in from web", second.Pattern.Template)
+
+	matched, ok := miner.Match("user carol logged in from web")
+	assert.True(t, ok)
+	assert.Equal(t, first.Pattern.ID, matched.ID)
+}
+
+// TestPatternMinerNewSince verifies templates introduced after a deploy are flagged
+func TestPatternMinerNewSince(t *testing.T) {
+	miner := NewPatternMiner(DefaultPatternMinerConfig())
+	deployedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	miner.Add(LogEntry{Timestamp: deployedAt.Add(-time.Hour), Message: "health check ok"})
+	miner.Add(LogEntry{Timestamp: deployedAt.Add(time.Minute), Message: "health check ok"})
+	miner.Add(LogEntry{Timestamp: deployedAt.Add(2 * time.Minute), Message: "panic: nil pointer dereference in handler"})
+
+	fresh := miner.NewSince(deployedAt)
+	assert.Len(t, fresh, 1)
+	assert.Equal(t, "panic: nil pointer dereference in handler", fresh[0].Template)
+}
+
+// TestPatternMinerEviction verifies the template cap evicts the stalest template
+func TestPatternMinerEviction(t *testing.T) {
+	miner := NewPatternMiner(PatternMinerConfig{MaxClusters: 2})
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	miner.Add(LogEntry{Timestamp: base, Message: "alpha event happened"})
+	miner.Add(LogEntry{Timestamp: base.Add(time.Second), Message: "beta something else entirely different"})
+	miner.Add(LogEntry{Timestamp: base.Add(2 * time.Second), Message: "gamma"})
+
+	assert.Equal(t, 2, miner.Size())
+	_, ok := miner.Match("alpha event happened")
+	assert.False(t, ok)
+}
@@ -3,10 +3,15 @@ package search
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
 )
 
 // SearchEngine provides optimized log search capabilities
@@ -14,18 +19,51 @@ type SearchEngine struct {
 	index       *SearchIndex
 	cache       *SearchCache
 	filters     *FilterChain
+	miner       *PatternMiner
+	redactor    *redaction.Redactor
 	mu          sync.RWMutex
 	metrics     *SearchMetrics
+	nextID      uint64
+
+	// Recently indexed lines, so a line read again by a later search or seen on a
+	// live stream as well is indexed and mined once
+	seenMu    sync.Mutex
+	seen      map[logKey]struct{}
+	seenOrder []logKey
+	seenNext  int
 }
 
+// maxSeenLogs bounds how many indexed lines are remembered for deduplication
+const maxSeenLogs = 100000
+
+// logKey identifies a log line by namespace, pod, container, timestamp and message
+type logKey [16]byte
+
+// sharedRedactions counts every redaction made by the shared redactor, including
+// the streaming pipelines. It is registered with the redactor once and reported
+// in the metrics of every engine.
+var (
+	sharedRedactions   = NewSearchMetrics()
+	registerRedactions sync.Once
+)
+
 // NewSearchEngine creates a new search engine
 func NewSearchEngine() *SearchEngine {
-	return &SearchEngine{
-		index:   NewSearchIndex(),
-		cache:   NewSearchCache(1000, 5*time.Minute),
-		filters: NewFilterChain(),
-		metrics: NewSearchMetrics(),
+	se := &SearchEngine{
+		index:    NewSearchIndex(),
+		cache:    NewSearchCache(1000, 5*time.Minute),
+		filters:  NewFilterChain(),
+		miner:    NewPatternMiner(DefaultPatternMinerConfig()),
+		redactor: redaction.Default(),
+		metrics:  NewSearchMetrics(),
+		seen:     make(map[logKey]struct{}),
 	}
+	
+	registerRedactions.Do(func() {
+		redaction.Default().AddRecorder(sharedRedactions)
+	})
+	
+	return se
 }
 
 // SearchOptions defines search parameters
@@ -144,10 +182,129 @@ func (se *SearchEngine) generateCacheKey(opts SearchOptions) string {
 	return strings.Join(parts, "|")
 }
 
-// IndexLog adds a log entry to the search index
-func (se *SearchEngine) IndexLog(log LogEntry) {
+// IndexLog redacts a log entry, adds it to the search index and mines its
+// template. Redacting here keeps secrets out of the index and cache; callers
+// use the returned entry rather than redacting again.
+func (se *SearchEngine) IndexLog(log LogEntry) (LogEntry, PatternUpdate) {
+	log.Message = se.redactor.RedactLine(log.Namespace, log.Message)
+	return log, se.indexRedacted(log)
+}
+
+// IndexRedactedLog adds a log entry whose message the log parser has already
+// redacted, e.g. a line sent on a live log stream, and mines its template
+func (se *SearchEngine) IndexRedactedLog(log LogEntry) PatternUpdate {
+	return se.indexRedacted(log)
+}
+
+// indexRedacted assigns an ID if needed, then indexes and mines a redacted entry.
+// Lines indexed before are skipped, so template counts follow log volume rather
+// than how often the logs are searched.
+func (se *SearchEngine) indexRedacted(log LogEntry) PatternUpdate {
+	if !se.markSeen(log) {
+		return PatternUpdate{}
+	}
+	if log.ID == "" {
+		log.ID = strconv.FormatUint(atomic.AddUint64(&se.nextID, 1), 10)
+	}
 	se.index.Add(log)
 	se.metrics.RecordIndexedLog()
+	return se.miner.Add(log)
+}
+
+// markSeen records a line and reports whether it was new
+func (se *SearchEngine) markSeen(log LogEntry) bool {
+	hash := fnv.New128a()
+	for _, field := range []string{log.Namespace, log.Pod, log.Container, log.Timestamp.UTC().Format(time.RFC3339Nano), log.Message} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	var key logKey
+	hash.Sum(key[:0])
+
+	se.seenMu.Lock()
+	defer se.seenMu.Unlock()
+	if _, ok := se.seen[key]; ok {
+		return false
+	}
+	if len(se.seenOrder) < maxSeenLogs {
+		se.seenOrder = append(se.seenOrder, key)
+	} else {
+		delete(se.seen, se.seenOrder[se.seenNext])
+		se.seenOrder[se.seenNext] = key
+		se.seenNext = (se.seenNext + 1) % maxSeenLogs
+	}
+	se.seen[key] = struct{}{}
+	return true
+}
+
+// TopPatterns clusters the logs matching the search options into templates
+// and returns the most frequent ones
+func (se *SearchEngine) TopPatterns(ctx context.Context, opts SearchOptions, limit int) ([]LogPattern, error) {
+	pattern, err := se.buildSearchPattern(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+
+	miner := NewPatternMiner(DefaultPatternMinerConfig())
+	for _, match := range se.index.Search(pattern, opts) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if se.filters.Apply(match, opts) {
+			miner.Add(LogEntry{
+				Timestamp: match.Timestamp,
+				Namespace: match.Namespace,
+				Pod:       match.Pod,
+				Container: match.Container,
+				Level:     match.Level,
+				Message:   match.Message,
+			})
+		}
+	}
+
+	return miner.TopPatterns(limit), nil
+}
+
+// NewPatternsSince returns templates first seen after the given time, such as
+// the start of a rollout. The namespace and pod filters from opts are applied
+// to the namespaces and pods that emitted each template, and the query to its
+// sample line.
+func (se *SearchEngine) NewPatternsSince(since time.Time, opts SearchOptions) ([]LogPattern, error) {
+	pattern, err := se.buildSearchPattern(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+
+	podFilter := &PodFilter{}
+	patterns := []LogPattern{}
+	for _, candidate := range se.miner.NewSince(since) {
+		if opts.Query != "" && !pattern.Match(candidate.Sample) {
+			continue
+		}
+		if len(opts.Namespaces) > 0 && !patternFromAny(candidate.Namespaces, func(namespace string) bool {
+			return contains(opts.Namespaces, namespace)
+		}) {
+			continue
+		}
+		if len(opts.Pods) > 0 && !patternFromAny(candidate.Pods, func(pod string) bool {
+			return podFilter.Apply(SearchResult{Pod: pod}, opts)
+		}) {
+			continue
+		}
+		patterns = append(patterns, candidate)
+	}
+
+	return patterns, nil
+}
+
+// patternFromAny checks if any of the emitters counted for a template is selected
+func patternFromAny(emitters map[string]int, selected func(string) bool) bool {
+	for emitter, count := range emitters {
+		if count > 0 && selected(emitter) {
+			return true
+		}
+	}
+	return false
 }
 
 // ClearCache clears the search cache
@@ -155,9 +312,13 @@ func (se *SearchEngine) ClearCache() {
 	se.cache.Clear()
 }
 
-// GetMetrics returns search engine metrics
+// GetMetrics returns search engine metrics, with the redactions of every log pipeline
 func (se *SearchEngine) GetMetrics() SearchMetricsSnapshot {
-	return se.metrics.Snapshot()
+	snapshot := se.metrics.Snapshot()
+	redactions := sharedRedactions.Snapshot()
+	snapshot.Redactions = redactions.Redactions
+	snapshot.RedactionsByType = redactions.RedactionsByType
+	return snapshot
 }
 
 // LogEntry represents a log entry for indexing
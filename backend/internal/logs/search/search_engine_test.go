@@ -0,0 +1,54 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestIndexLogRedactsOnce verifies indexed lines are redacted once and counted
+// once, however many engines exist
+func TestIndexLogRedactsOnce(t *testing.T) {
+	engine := NewSearchEngine()
+	NewSearchEngine()
+	before := engine.GetMetrics().RedactionsByType["email"]
+
+	indexed, _ := engine.IndexLog(LogEntry{
+		Timestamp: time.Now(),
+		Namespace: "shop",
+		Pod:       "web-1",
+		Message:   "sent receipt to jane.doe@example.com",
+	})
+	assert.Equal(t, "sent receipt to [REDACTED:email]", indexed.Message)
+
+	metrics := engine.GetMetrics()
+	assert.Equal(t, before+1, metrics.RedactionsByType["email"])
+	assert.Equal(t, uint64(1), metrics.IndexedLogs)
+}
+
+// TestIndexLogSkipsDuplicates verifies a line read again by a later search, or seen
+// on a live stream as well, is indexed and mined once
+func TestIndexLogSkipsDuplicates(t *testing.T) {
+	engine := NewSearchEngine()
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	line := LogEntry{Timestamp: at, Namespace: "shop", Pod: "web-1", Container: "web", Message: "GET /cart 200"}
+
+	_, update := engine.IndexLog(line)
+	assert.True(t, update.Created)
+	_, update = engine.IndexLog(line)
+	assert.False(t, update.Created)
+	engine.IndexRedactedLog(line)
+
+	later := line
+	later.Timestamp = at.Add(time.Second)
+	engine.IndexRedactedLog(later)
+
+	assert.Equal(t, uint64(2), engine.GetMetrics().IndexedLogs)
+	patterns, err := engine.TopPatterns(context.Background(), SearchOptions{}, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, patterns, 1) {
+		assert.Equal(t, 2, patterns[0].Count)
+	}
+}
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -28,13 +29,19 @@ type SearchMetrics struct {
 	// Result metrics
 	totalResults uint64
 	avgResults   float64
+	
+	// Redaction metrics
+	redactions       uint64
+	redactionsByType map[string]uint64
+	redactionsMu     sync.Mutex
 }
 
 // NewSearchMetrics creates new search metrics
 func NewSearchMetrics() *SearchMetrics {
 	return &SearchMetrics{
-		searchLatencies: make([]time.Duration, 0, 100),
-		minLatency:      time.Hour, // Start with high value
+		searchLatencies:  make([]time.Duration, 0, 100),
+		minLatency:       time.Hour, // Start with high value
+		redactionsByType: make(map[string]uint64),
 	}
 }
 
@@ -75,6 +82,18 @@ func (m *SearchMetrics) RecordIndexedLog() {
 	atomic.AddUint64(&m.indexedLogs, 1)
 }
 
+// RecordRedaction records values masked by a redaction detector or rule
+func (m *SearchMetrics) RecordRedaction(detector string, count int) {
+	if count <= 0 {
+		return
+	}
+	atomic.AddUint64(&m.redactions, uint64(count))
+	
+	m.redactionsMu.Lock()
+	m.redactionsByType[detector] += uint64(count)
+	m.redactionsMu.Unlock()
+}
+
 // RecordSearchLatency records search operation latency
 func (m *SearchMetrics) RecordSearchLatency(duration time.Duration) {
 	m.searchLatencies = append(m.searchLatencies, duration)
@@ -106,7 +125,15 @@ func (m *SearchMetrics) Snapshot() SearchMetricsSnapshot {
 		AvgResults:         m.avgResults,
 		MaxLatency:         m.maxLatency,
 		MinLatency:         m.minLatency,
+		Redactions:         atomic.LoadUint64(&m.redactions),
+		RedactionsByType:   make(map[string]uint64),
+	}
+	
+	m.redactionsMu.Lock()
+	for detector, count := range m.redactionsByType {
+		snapshot.RedactionsByType[detector] = count
 	}
+	m.redactionsMu.Unlock()
 	
 	// Calculate cache hit rate
 	totalCacheOps := snapshot.CacheHits + snapshot.CacheMisses
@@ -144,6 +171,10 @@ func (m *SearchMetrics) Reset() {
 	m.searchLatencies = m.searchLatencies[:0]
 	m.maxLatency = 0
 	m.minLatency = time.Hour
+	atomic.StoreUint64(&m.redactions, 0)
+	m.redactionsMu.Lock()
+	m.redactionsByType = make(map[string]uint64)
+	m.redactionsMu.Unlock()
 }
 
 // SearchMetricsSnapshot represents a point-in-time snapshot of search metrics
@@ -170,4 +201,8 @@ type SearchMetricsSnapshot struct {
 	// Result metrics
 	TotalResults uint64
 	AvgResults   float64
+	
+	// Redaction metrics
+	Redactions       uint64
+	RedactionsByType map[string]uint64
 }
\ No newline at end of file
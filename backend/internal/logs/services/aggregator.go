@@ -2,8 +2,10 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"sync"
+	"time"
 	
 	"github.com/prasad/kaptivan/backend/internal/kubernetes"
 	"github.com/prasad/kaptivan/backend/internal/logs/models"
@@ -28,6 +30,60 @@ func NewLogAggregator(manager *kubernetes.ClusterManager) *LogAggregator {
 
 // FetchLogs fetches logs from multiple clusters in parallel
 func (a *LogAggregator) FetchLogs(ctx context.Context, query models.LogQuery) (*models.LogResponse, error) {
+	allLogs := a.collectLogs(ctx, query)
+	
+	// Compute stats over everything that matched, before the limit is applied
+	stats := ComputeLogStats(allLogs, query.StartTime, query.EndTime, 0)
+	
+	// Apply limit
+	hasMore := false
+	if query.Limit > 0 && len(allLogs) > query.Limit {
+		allLogs = allLogs[:query.Limit]
+		hasMore = true
+	}
+	
+	return &models.LogResponse{
+		Logs:       allLogs,
+		TotalCount: stats.TotalLogs,
+		HasMore:    hasMore,
+		Clusters:   query.Clusters,
+		Query:      query,
+		Stats:      stats,
+	}, nil
+}
+
+// ComputeStats fetches logs for the query and returns bucketed statistics
+// without the log lines. A zero bucketSize picks one based on the time range.
+func (a *LogAggregator) ComputeStats(ctx context.Context, query models.LogQuery, bucketSize time.Duration) (*models.LogStats, error) {
+	// Statistics always cover every matching line
+	query.Limit = 0
+	
+	if query.EndTime.IsZero() {
+		query.EndTime = time.Now()
+	}
+	if query.StartTime.IsZero() {
+		query.StartTime = query.EndTime.Add(-time.Hour)
+	}
+	if !query.EndTime.After(query.StartTime) {
+		return nil, fmt.Errorf("endTime must be after startTime")
+	}
+	
+	if bucketSize > 0 {
+		if count := BucketCount(query.StartTime, query.EndTime, bucketSize); count > MaxBuckets {
+			return nil, fmt.Errorf("bucket %s splits the range into %d buckets; at most %d are allowed", bucketSize, count, MaxBuckets)
+		}
+	}
+	
+	logs := a.collectLogs(ctx, query)
+	
+	if bucketSize <= 0 {
+		bucketSize = ChooseBucketSize(query.StartTime, query.EndTime)
+	}
+	return ComputeLogStats(logs, query.StartTime, query.EndTime, bucketSize), nil
+}
+
+// collectLogs fetches logs from all clusters in parallel, newest first, with filters applied
+func (a *LogAggregator) collectLogs(ctx context.Context, query models.LogQuery) []models.LogEntry {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	allLogs := make([]models.LogEntry, 0)
@@ -59,18 +115,7 @@ func (a *LogAggregator) FetchLogs(ctx context.Context, query models.LogQuery) (*
 	// Apply filters
 	allLogs = a.applyFilters(allLogs, query)
 	
-	// Apply limit
-	if query.Limit > 0 && len(allLogs) > query.Limit {
-		allLogs = allLogs[:query.Limit]
-	}
-	
-	return &models.LogResponse{
-		Logs:       allLogs,
-		TotalCount: len(allLogs),
-		HasMore:    false,
-		Clusters:   query.Clusters,
-		Query:      query,
-	}, nil
+	return allLogs
 }
 
 // fetchClusterLogs fetches logs from a single cluster
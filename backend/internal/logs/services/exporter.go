@@ -0,0 +1,463 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/kubernetes"
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+)
+
+// Archive formats supported by LogExporter
+const (
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatZip   = "zip"
+)
+
+// Export size limits; logs past them are left out and the manifest says so
+const (
+	defaultMaxFileBytes    = 100 << 20 // per container log file
+	defaultMaxArchiveBytes = 1 << 30   // for all log files together
+	maxLineBytes           = 1 << 20
+)
+
+// LogExporter bundles container logs into a downloadable archive
+type LogExporter struct {
+	clients         func(cluster string) (k8sclient.Interface, error)
+	redactor        *redaction.Redactor
+	spool           func() (*os.File, error)
+	maxFileBytes    int64
+	maxArchiveBytes int64
+}
+
+// NewLogExporter creates a new log exporter
+func NewLogExporter(manager *kubernetes.ClusterManager) *LogExporter {
+	return &LogExporter{
+		clients:         manager.GetClientset,
+		redactor:        redaction.Default(),
+		spool:           createSpool,
+		maxFileBytes:    defaultMaxFileBytes,
+		maxArchiveBytes: defaultMaxArchiveBytes,
+	}
+}
+
+// createSpool creates the temporary file a container's log is spooled to
+func createSpool() (*os.File, error) {
+	return os.CreateTemp("", "kaptivan-logs-*.log")
+}
+
+// archiveWriter abstracts over tar.gz and zip output
+type archiveWriter interface {
+	// WriteFile adds a file whose content is read from r; size is the exact length
+	WriteFile(name string, size int64, modTime time.Time, r io.Reader) error
+	Close() error
+}
+
+// ValidateArchiveFormat normalizes and checks an archive format
+func ValidateArchiveFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "tar.gz", "tgz", "tar":
+		return ArchiveFormatTarGz, nil
+	case "zip":
+		return ArchiveFormatZip, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+// Export streams an archive with one log file per cluster/namespace/pod/container
+// followed by a manifest.json. Collection failures are recorded in the manifest
+// rather than aborting the export; only write errors are returned. Files are cut
+// at maxFileBytes, and containers past maxArchiveBytes are left out as failures.
+func (e *LogExporter) Export(ctx context.Context, query models.LogQuery, format string, w io.Writer) (*models.LogArchiveManifest, error) {
+	format, err := ValidateArchiveFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := newArchiveWriter(format, w)
+	manifest := &models.LogArchiveManifest{
+		GeneratedAt: time.Now(),
+		Format:      format,
+		Query:       query,
+		Files:       []models.LogArchiveFile{},
+		Failures:    []models.LogArchiveFailure{},
+	}
+
+	// Requested pods are looked for across every cluster and namespace
+	matched := make(map[string]bool)
+	for _, cluster := range query.Clusters {
+		if ctx.Err() != nil {
+			break
+		}
+
+		client, err := e.clients(cluster)
+		if err != nil {
+			manifest.Failures = append(manifest.Failures, models.LogArchiveFailure{
+				Cluster: cluster,
+				Error:   err.Error(),
+			})
+			continue
+		}
+
+		for _, namespace := range query.Namespaces {
+			if err := e.exportNamespace(ctx, client, cluster, namespace, query, matched, archive, manifest); err != nil {
+				archive.Close()
+				return manifest, err
+			}
+		}
+	}
+
+	// Report explicitly requested pods that were found nowhere
+	if ctx.Err() == nil {
+		for _, requested := range query.Pods {
+			if !matched[requested] {
+				manifest.Failures = append(manifest.Failures, models.LogArchiveFailure{
+					Pod:   requested,
+					Error: "pod not found",
+				})
+			}
+		}
+	}
+
+	for _, file := range manifest.Files {
+		manifest.TotalLines += file.Lines
+		manifest.TotalBytes += file.Bytes
+	}
+	manifest.TotalFiles = len(manifest.Files)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		archive.Close()
+		return manifest, err
+	}
+	if err := archive.WriteFile("manifest.json", int64(len(data)), manifest.GeneratedAt, strings.NewReader(string(data))); err != nil {
+		archive.Close()
+		return manifest, err
+	}
+
+	return manifest, archive.Close()
+}
+
+// exportNamespace adds the logs of every matching pod in a namespace, recording
+// which requested pods it found in matched
+func (e *LogExporter) exportNamespace(
+	ctx context.Context,
+	client k8sclient.Interface,
+	cluster, namespace string,
+	query models.LogQuery,
+	matched map[string]bool,
+	archive archiveWriter,
+	manifest *models.LogArchiveManifest,
+) error {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		manifest.Failures = append(manifest.Failures, models.LogArchiveFailure{
+			Cluster:   cluster,
+			Namespace: namespace,
+			Error:     err.Error(),
+		})
+		return nil
+	}
+
+	for _, pod := range pods.Items {
+		if !matchesPodFilter(pod.Name, query.Pods, matched) {
+			continue
+		}
+
+		containers := append([]corev1.Container{}, pod.Spec.InitContainers...)
+		containers = append(containers, pod.Spec.Containers...)
+
+		for _, container := range containers {
+			if len(query.Containers) > 0 && !containsString(query.Containers, container.Name) {
+				continue
+			}
+
+			if err := e.exportContainer(ctx, client, cluster, pod, container.Name, false, query, archive, manifest); err != nil {
+				return err
+			}
+			if query.Previous && hasPreviousInstance(pod, container.Name) {
+				if err := e.exportContainer(ctx, client, cluster, pod, container.Name, true, query, archive, manifest); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportContainer streams one container's logs into a temporary file and adds
+// it to the archive. Fetch errors are recorded as failures.
+func (e *LogExporter) exportContainer(
+	ctx context.Context,
+	client k8sclient.Interface,
+	cluster string,
+	pod corev1.Pod,
+	container string,
+	previous bool,
+	query models.LogQuery,
+	archive archiveWriter,
+	manifest *models.LogArchiveManifest,
+) error {
+	failure := func(err error) {
+		manifest.Failures = append(manifest.Failures, models.LogArchiveFailure{
+			Cluster:   cluster,
+			Namespace: pod.Namespace,
+			Pod:       pod.Name,
+			Container: container,
+			Previous:  previous,
+			Error:     err.Error(),
+		})
+	}
+
+	limit := e.maxFileBytes
+	if remaining := e.maxArchiveBytes - archivedBytes(manifest); remaining < limit {
+		limit = remaining
+	}
+	if limit <= 0 {
+		manifest.Truncated = true
+		failure(fmt.Errorf("left out: the archive reached its %d byte limit", e.maxArchiveBytes))
+		return nil
+	}
+
+	opts := &corev1.PodLogOptions{
+		Container:  container,
+		Timestamps: true,
+		Previous:   previous,
+	}
+	if !query.StartTime.IsZero() {
+		sinceTime := metav1.NewTime(query.StartTime)
+		opts.SinceTime = &sinceTime
+	} else if query.Tail > 0 {
+		tailLines := int64(query.Tail)
+		opts.TailLines = &tailLines
+	}
+
+	stream, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		failure(err)
+		return nil
+	}
+	defer stream.Close()
+
+	// tar needs the file size up front, so spool to disk first
+	tmp, err := e.spool()
+	if err != nil {
+		failure(err)
+		return nil
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	file := models.LogArchiveFile{
+		Path:      archivePath(cluster, pod.Namespace, pod.Name, container, previous),
+		Cluster:   cluster,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Container: container,
+		Previous:  previous,
+	}
+
+	writer := bufio.NewWriter(tmp)
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		ts, hasTimestamp := lineTimestamp(line)
+
+		if hasTimestamp && !query.EndTime.IsZero() && ts.After(query.EndTime) {
+			break
+		}
+		if hasTimestamp {
+			if file.FirstTimestamp.IsZero() {
+				file.FirstTimestamp = ts
+			}
+			file.LastTimestamp = ts
+		}
+
+		line = e.redactor.RedactLine(pod.Namespace, line)
+		if file.Bytes+int64(len(line))+1 > limit {
+			file.Truncated = true
+			manifest.Truncated = true
+			break
+		}
+		n, err := writer.WriteString(line + "\n")
+		if err != nil {
+			failure(fmt.Errorf("failed to spool log: %w", err))
+			return nil
+		}
+		file.Bytes += int64(n)
+		file.Lines++
+	}
+	if err := scanner.Err(); err != nil {
+		failure(fmt.Errorf("log stream interrupted: %w", err))
+	}
+	if err := writer.Flush(); err != nil {
+		failure(err)
+		return nil
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		failure(err)
+		return nil
+	}
+
+	modTime := file.LastTimestamp
+	if modTime.IsZero() {
+		modTime = manifest.GeneratedAt
+	}
+	if err := archive.WriteFile(file.Path, file.Bytes, modTime, tmp); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file.Path, err)
+	}
+
+	manifest.Files = append(manifest.Files, file)
+	return nil
+}
+
+// archivedBytes is the size of the log files added so far
+func archivedBytes(manifest *models.LogArchiveManifest) int64 {
+	var total int64
+	for _, file := range manifest.Files {
+		total += file.Bytes
+	}
+	return total
+}
+
+// matchesPodFilter checks a pod name against requested pods (exact or substring)
+// and records which requested names matched
+func matchesPodFilter(name string, requested []string, matched map[string]bool) bool {
+	if len(requested) == 0 {
+		return true
+	}
+
+	found := false
+	for _, p := range requested {
+		if name == p || strings.Contains(name, p) {
+			matched[p] = true
+			found = true
+		}
+	}
+	return found
+}
+
+// hasPreviousInstance checks if a container has restarted and has a previous instance
+func hasPreviousInstance(pod corev1.Pod, container string) bool {
+	statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.Name == container {
+			return status.RestartCount > 0 || status.LastTerminationState.Terminated != nil
+		}
+	}
+	return false
+}
+
+// lineTimestamp parses the RFC3339 timestamp the kubelet prefixes to each line
+func lineTimestamp(line string) (time.Time, bool) {
+	idx := strings.IndexByte(line, ' ')
+	if idx <= 0 {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, line[:idx])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+// archivePath builds the in-archive path for a container log
+func archivePath(cluster, namespace, pod, container string, previous bool) string {
+	name := container + ".log"
+	if previous {
+		name = container + ".previous.log"
+	}
+	return path.Join(sanitizePathSegment(cluster), sanitizePathSegment(namespace), sanitizePathSegment(pod), sanitizePathSegment(name))
+}
+
+// sanitizePathSegment makes a context or resource name safe as a single path segment,
+// e.g. EKS context ARNs contain ':' and '/'
+func sanitizePathSegment(segment string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", "..", "_")
+	return replacer.Replace(segment)
+}
+
+// containsString checks if a slice contains a string
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// newArchiveWriter creates a writer for the given format
+func newArchiveWriter(format string, w io.Writer) archiveWriter {
+	if format == ArchiveFormatZip {
+		return &zipArchive{zw: zip.NewWriter(w)}
+	}
+	gz := gzip.NewWriter(w)
+	return &tarGzArchive{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+// tarGzArchive writes a gzip-compressed tar stream
+type tarGzArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a *tarGzArchive) WriteFile(name string, size int64, modTime time.Time, r io.Reader) error {
+	if err := a.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(a.tw, r, size)
+	return err
+}
+
+func (a *tarGzArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// zipArchive writes a zip stream
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) WriteFile(name string, size int64, modTime time.Time, r io.Reader) error {
+	fw, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(fw, r, size)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	restclient "k8s.io/client-go/rest"
+	fakerest "k8s.io/client-go/rest/fake"
+)
+
+// logsClientset serves container logs from a map keyed by namespace/pod/container,
+// which the fake clientset can't; a ".previous" suffix holds previous instances
+type logsClientset struct {
+	*fake.Clientset
+	logs map[string]string
+}
+
+func (c *logsClientset) CoreV1() typedcorev1.CoreV1Interface {
+	return logsCoreV1{c.Clientset.CoreV1(), c.logs}
+}
+
+type logsCoreV1 struct {
+	typedcorev1.CoreV1Interface
+	logs map[string]string
+}
+
+func (c logsCoreV1) Pods(namespace string) typedcorev1.PodInterface {
+	return logsPods{c.CoreV1Interface.Pods(namespace), namespace, c.logs}
+}
+
+type logsPods struct {
+	typedcorev1.PodInterface
+	namespace string
+	logs      map[string]string
+}
+
+func (p logsPods) GetLogs(name string, opts *corev1.PodLogOptions) *restclient.Request {
+	key := p.namespace + "/" + name + "/" + opts.Container
+	if opts.Previous {
+		key += ".previous"
+	}
+	client := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(*http.Request) (*http.Response, error) {
+			body, ok := p.logs[key]
+			if !ok {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         corev1.SchemeGroupVersion,
+		VersionedAPIPath:     fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", p.namespace, name),
+	}
+	return client.Request()
+}
+
+func testPod(name string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"}}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+	}
+	return pod
+}
+
+func newTestExporter(logs map[string]string) *LogExporter {
+	restarted := testPod("api-1", "api")
+	restarted.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "api", RestartCount: 1}}
+	clientset := &logsClientset{
+		Clientset: fake.NewSimpleClientset(testPod("web-1", "web", "proxy"), restarted),
+		logs:      logs,
+	}
+	return &LogExporter{
+		clients: func(cluster string) (k8sclient.Interface, error) {
+			if cluster != "prod" {
+				return nil, fmt.Errorf("cluster %s not connected", cluster)
+			}
+			return clientset, nil
+		},
+		redactor:        redaction.NewRedactor(),
+		spool:           createSpool,
+		maxFileBytes:    defaultMaxFileBytes,
+		maxArchiveBytes: defaultMaxArchiveBytes,
+	}
+}
+
+var testLogs = map[string]string{
+	"shop/web-1/web":          "2024-05-01T10:00:00Z GET /cart 200\n2024-05-01T10:00:01Z sent receipt to jane.doe@example.com\n2024-05-01T10:05:00Z GET /checkout 200\n",
+	"shop/web-1/proxy":        "2024-05-01T10:00:00Z upstream ready\n",
+	"shop/api-1/api":          "2024-05-01T10:00:02Z connecting with password=\"hunter2\"\n",
+	"shop/api-1/api.previous": "2024-05-01T09:59:00Z panic: out of memory\n",
+}
+
+// readTarGz returns the files of a tar.gz archive by name
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+// readZip returns the files of a zip archive by name
+func readZip(t *testing.T, data []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func readManifest(t *testing.T, files map[string]string) models.LogArchiveManifest {
+	var manifest models.LogArchiveManifest
+	require.NoError(t, json.Unmarshal([]byte(files["manifest.json"]), &manifest))
+	return manifest
+}
+
+func TestExportArchives(t *testing.T) {
+	query := models.LogQuery{
+		Clusters:   []string{"prod", "arn:aws:eks:eu-west-1:1234:cluster/staging"},
+		Namespaces: []string{"shop", "batch"},
+		Pods:       []string{"web-1", "api-1", "gone-1"},
+		EndTime:    time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC),
+		Previous:   true,
+	}
+
+	for _, format := range []string{"tgz", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			manifest, err := newTestExporter(testLogs).Export(context.Background(), query, format, &buf)
+			require.NoError(t, err)
+
+			var files map[string]string
+			if format == "zip" {
+				files = readZip(t, buf.Bytes())
+			} else {
+				files = readTarGz(t, buf.Bytes())
+			}
+			assert.Len(t, files, 5)
+			// Lines past the end time are left out and secrets are masked
+			assert.Equal(t, "2024-05-01T10:00:00Z GET /cart 200\n2024-05-01T10:00:01Z sent receipt to [REDACTED:email]\n", files["prod/shop/web-1/web.log"])
+			assert.Equal(t, "2024-05-01T10:00:00Z upstream ready\n", files["prod/shop/web-1/proxy.log"])
+			assert.Equal(t, "2024-05-01T10:00:02Z connecting with password=\"[REDACTED:credential]\"\n", files["prod/shop/api-1/api.log"])
+			assert.Equal(t, "2024-05-01T09:59:00Z panic: out of memory\n", files["prod/shop/api-1/api.previous.log"])
+			assert.NotContains(t, files["manifest.json"], "hunter2")
+
+			written := readManifest(t, files)
+			assert.Equal(t, 4, written.TotalFiles)
+			assert.Equal(t, 5, written.TotalLines)
+			assert.Equal(t, manifest.TotalBytes, written.TotalBytes)
+			assert.False(t, written.Truncated)
+			for _, file := range written.Files {
+				assert.Equal(t, int64(len(files[file.Path])), file.Bytes, file.Path)
+			}
+			// Pods found in one namespace are not missing from the others; a pod found
+			// nowhere is reported once
+			require.Len(t, written.Failures, 2)
+			assert.Equal(t, "arn:aws:eks:eu-west-1:1234:cluster/staging", written.Failures[0].Cluster)
+			assert.Equal(t, models.LogArchiveFailure{Pod: "gone-1", Error: "pod not found"}, written.Failures[1])
+		})
+	}
+}
+
+func TestExportLimits(t *testing.T) {
+	query := models.LogQuery{Clusters: []string{"prod"}, Namespaces: []string{"shop"}, Pods: []string{"web-1"}}
+
+	// A file stops at the last whole line within the per-file limit
+	exporter := newTestExporter(testLogs)
+	exporter.maxFileBytes = 40
+	var buf bytes.Buffer
+	_, err := exporter.Export(context.Background(), query, "tar.gz", &buf)
+	require.NoError(t, err)
+	files := readTarGz(t, buf.Bytes())
+	assert.Equal(t, "2024-05-01T10:00:00Z GET /cart 200\n", files["prod/shop/web-1/web.log"])
+	manifest := readManifest(t, files)
+	assert.True(t, manifest.Truncated)
+	require.Len(t, manifest.Files, 2)
+	assert.True(t, manifest.Files[0].Truncated, "proxy's 36 bytes fit, web's log does not")
+	assert.Equal(t, "proxy", manifest.Files[1].Container)
+	assert.False(t, manifest.Files[1].Truncated)
+
+	// Once the archive limit is reached the remaining containers are left out
+	exporter = newTestExporter(testLogs)
+	exporter.maxArchiveBytes = 89 // web's first two lines
+	buf.Reset()
+	_, err = exporter.Export(context.Background(), query, "zip", &buf)
+	require.NoError(t, err)
+	manifest = readManifest(t, readZip(t, buf.Bytes()))
+	assert.True(t, manifest.Truncated)
+	require.Len(t, manifest.Files, 1)
+	assert.Equal(t, int64(89), manifest.TotalBytes)
+	assert.True(t, manifest.Files[0].Truncated)
+	require.Len(t, manifest.Failures, 1)
+	assert.Equal(t, "proxy", manifest.Failures[0].Container)
+	assert.Contains(t, manifest.Failures[0].Error, "byte limit")
+
+	// A line longer than the scanner allows ends the file with a failure
+	exporter = newTestExporter(map[string]string{
+		"shop/web-1/web":   "2024-05-01T10:00:00Z ok\n" + strings.Repeat("x", maxLineBytes+1) + "\n",
+		"shop/web-1/proxy": "",
+	})
+	buf.Reset()
+	_, err = exporter.Export(context.Background(), query, "tar.gz", &buf)
+	require.NoError(t, err)
+	files = readTarGz(t, buf.Bytes())
+	assert.Equal(t, "2024-05-01T10:00:00Z ok\n", files["prod/shop/web-1/web.log"])
+	manifest = readManifest(t, files)
+	require.Len(t, manifest.Failures, 1)
+	assert.Contains(t, manifest.Failures[0].Error, "log stream interrupted")
+
+	// A spool write error drops that container with a failure; the others still export
+	exporter = newTestExporter(map[string]string{
+		"shop/web-1/web":   "2024-05-01T10:00:00Z " + strings.Repeat("x", 8192) + "\n",
+		"shop/web-1/proxy": "2024-05-01T10:00:00Z upstream ready\n",
+	})
+	spools := 0
+	exporter.spool = func() (*os.File, error) {
+		spools++
+		file, err := createSpool()
+		if err != nil || spools > 1 {
+			return file, err
+		}
+		file.Close()
+		return os.Open(file.Name()) // read-only, so writes fail
+	}
+	buf.Reset()
+	_, err = exporter.Export(context.Background(), query, "tar.gz", &buf)
+	require.NoError(t, err)
+	manifest = readManifest(t, readTarGz(t, buf.Bytes()))
+	require.Len(t, manifest.Failures, 1)
+	assert.Equal(t, "web", manifest.Failures[0].Container)
+	assert.Contains(t, manifest.Failures[0].Error, "failed to spool log")
+	require.Len(t, manifest.Files, 1)
+	assert.Equal(t, "proxy", manifest.Files[0].Container)
+}
+
+func TestValidateArchiveFormat(t *testing.T) {
+	for input, want := range map[string]string{"": ArchiveFormatTarGz, "TGZ": ArchiveFormatTarGz, "tar": ArchiveFormatTarGz, "zip": ArchiveFormatZip} {
+		format, err := ValidateArchiveFormat(input)
+		require.NoError(t, err)
+		assert.Equal(t, want, format)
+	}
+	_, err := ValidateArchiveFormat("rar")
+	assert.Error(t, err)
+}
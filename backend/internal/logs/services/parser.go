@@ -3,12 +3,14 @@ package services
 import (
 	"time"
 	"github.com/prasad/kaptivan/backend/internal/logs/models"
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
 )
 
 // LogParser parses raw log lines into structured LogEntry objects
 type LogParser struct {
 	patterns  *LogPatterns
 	timestamp *TimestampParser
+	redactor  *redaction.Redactor
 }
 
 // NewLogParser creates a new log parser
@@ -16,6 +18,7 @@ func NewLogParser() *LogParser {
 	return &LogParser{
 		patterns:  NewLogPatterns(),
 		timestamp: NewTimestampParser(),
+		redactor:  redaction.Default(),
 	}
 }
 
@@ -43,5 +46,8 @@ func (p *LogParser) ParseLogLine(line string, cluster, namespace, pod, container
 		entry.Source = "stderr"
 	}
 
+	// Mask secrets and personal data before the entry reaches any consumer
+	entry.Message = p.redactor.RedactLine(namespace, line)
+
 	return entry
 }
\ No newline at end of file
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/prasad/kaptivan/backend/internal/logs/redaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseLogLineRedacts verifies streamed lines are redacted with the detectors and
+// the namespace's custom rules, applied in the order the rules were added
+func TestParseLogLineRedacts(t *testing.T) {
+	redactor := redaction.NewRedactor()
+	_, err := redactor.AddRule(redaction.Rule{Name: "ticket", Namespace: "support", Pattern: `T-[0-9]+`})
+	require.NoError(t, err)
+	_, err = redactor.AddRule(redaction.Rule{Name: "digits", Namespace: "support", Pattern: `[0-9]{5,}`})
+	require.NoError(t, err)
+	parser := NewLogParser()
+	parser.redactor = redactor
+
+	entry := parser.ParseLogLine("2024-05-01T10:00:00Z ERROR T-12345 from ops@example.com", "prod", "support", "desk-1", "desk", 1)
+	assert.Equal(t, "2024-05-01T10:00:00Z ERROR [REDACTED:ticket] from [REDACTED:email]", entry.Message)
+	assert.Equal(t, "ERROR", entry.Level)
+
+	entry = parser.ParseLogLine("2024-05-01T10:00:00Z INFO T-12345", "prod", "shop", "web-1", "web", 2)
+	assert.Equal(t, "2024-05-01T10:00:00Z INFO T-12345", entry.Message, "rules are scoped to their namespace")
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+	"github.com/prasad/kaptivan/backend/internal/logs/search"
+)
+
+const (
+	// targetBuckets is the number of histogram buckets aimed for when choosing a bucket size
+	targetBuckets = 60
+	// MaxBuckets is the most histogram buckets a stats request may ask for
+	MaxBuckets = 1000
+	// maxTopPods is the number of pods reported as top emitters
+	maxTopPods = 10
+	// maxTopErrorPatterns is the number of error templates reported
+	maxTopErrorPatterns = 10
+)
+
+// bucketSizes are the histogram resolutions, smallest first
+var bucketSizes = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// ChooseBucketSize picks the smallest standard bucket size that keeps the
+// histogram for the given range at or below targetBuckets buckets
+func ChooseBucketSize(start, end time.Time) time.Duration {
+	span := end.Sub(start)
+	if span <= 0 {
+		return bucketSizes[0]
+	}
+
+	for _, size := range bucketSizes {
+		if span/size <= targetBuckets {
+			return size
+		}
+	}
+	return bucketSizes[len(bucketSizes)-1]
+}
+
+// BucketCount is the number of histogram buckets of the given size that cover a range
+func BucketCount(start, end time.Time, bucketSize time.Duration) int64 {
+	if !end.After(start) || bucketSize <= 0 {
+		return 0
+	}
+	return int64(end.Sub(start.Truncate(bucketSize))/bucketSize) + 1
+}
+
+// LogStatsBuilder accumulates log entries into bucketed statistics
+type LogStatsBuilder struct {
+	bucketSize  time.Duration
+	buckets     map[int64]*statsBucket
+	levels      map[string]int
+	pods        map[string]*models.PodLogCount
+	errors      *search.PatternMiner
+	errorCounts map[int]int // error template ID -> occurrences in the kept buckets
+	total       int
+}
+
+// statsBucket is one histogram bucket and its share of the per-pod and error
+// template counts, which Prune subtracts when the bucket is dropped
+type statsBucket struct {
+	histogram *models.LogHistogram
+	pods      map[string]*models.PodLogCount
+	errors    map[int]int
+}
+
+// NewLogStatsBuilder creates a stats builder with a fixed bucket size
+func NewLogStatsBuilder(bucketSize time.Duration) *LogStatsBuilder {
+	if bucketSize <= 0 {
+		bucketSize = bucketSizes[0]
+	}
+
+	return &LogStatsBuilder{
+		bucketSize:  bucketSize,
+		buckets:     make(map[int64]*statsBucket),
+		levels:      make(map[string]int),
+		pods:        make(map[string]*models.PodLogCount),
+		errors:      search.NewPatternMiner(search.DefaultPatternMinerConfig()),
+		errorCounts: make(map[int]int),
+	}
+}
+
+// Add records a log entry
+func (b *LogStatsBuilder) Add(entry models.LogEntry) {
+	level := strings.ToUpper(entry.Level)
+	if level == "" {
+		level = "INFO"
+	}
+
+	b.total++
+	b.levels[level]++
+
+	bucketStart := entry.Timestamp.Truncate(b.bucketSize)
+	key := bucketStart.Unix()
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &statsBucket{
+			histogram: &models.LogHistogram{
+				Timestamp: bucketStart,
+				Levels:    make(map[string]int),
+			},
+			pods:   make(map[string]*models.PodLogCount),
+			errors: make(map[int]int),
+		}
+		b.buckets[key] = bucket
+	}
+	bucket.histogram.Count++
+	bucket.histogram.Levels[level]++
+
+	podKey := entry.Cluster + "/" + entry.Namespace + "/" + entry.Pod
+	pod := countPod(b.pods, podKey, entry)
+	bucketPod := countPod(bucket.pods, podKey, entry)
+
+	if isErrorLevel(level) {
+		pod.Errors++
+		bucketPod.Errors++
+		update := b.errors.Add(search.LogEntry{
+			Timestamp: entry.Timestamp,
+			Namespace: entry.Namespace,
+			Pod:       entry.Pod,
+			Container: entry.Container,
+			Level:     level,
+			Message:   entry.Message,
+		})
+		b.errorCounts[update.Pattern.ID]++
+		bucket.errors[update.Pattern.ID]++
+	}
+}
+
+// countPod counts an entry for its pod in counts and returns the pod's count
+func countPod(counts map[string]*models.PodLogCount, key string, entry models.LogEntry) *models.PodLogCount {
+	pod, ok := counts[key]
+	if !ok {
+		pod = &models.PodLogCount{
+			Cluster:   entry.Cluster,
+			Namespace: entry.Namespace,
+			Pod:       entry.Pod,
+		}
+		counts[key] = pod
+	}
+	pod.Count++
+	return pod
+}
+
+// Prune drops histogram buckets that end before the given time, together with
+// their share of the totals, per-level, per-pod and error template counts, so
+// Build only reports the logs of the kept buckets
+func (b *LogStatsBuilder) Prune(before time.Time) {
+	for key, bucket := range b.buckets {
+		if !bucket.histogram.Timestamp.Add(b.bucketSize).Before(before) {
+			continue
+		}
+		delete(b.buckets, key)
+
+		b.total -= bucket.histogram.Count
+		for level, count := range bucket.histogram.Levels {
+			b.levels[level] -= count
+			if b.levels[level] <= 0 {
+				delete(b.levels, level)
+			}
+		}
+		for podKey, counted := range bucket.pods {
+			pod := b.pods[podKey]
+			pod.Count -= counted.Count
+			pod.Errors -= counted.Errors
+			if pod.Count <= 0 {
+				delete(b.pods, podKey)
+			}
+		}
+		for id, count := range bucket.errors {
+			b.errorCounts[id] -= count
+			if b.errorCounts[id] <= 0 {
+				delete(b.errorCounts, id)
+			}
+		}
+	}
+}
+
+// Build produces statistics for the given time range. Empty buckets inside
+// the range are included so the histogram can be plotted directly, unless the
+// range holds more than MaxBuckets buckets, when only non-empty ones are.
+func (b *LogStatsBuilder) Build(start, end time.Time) *models.LogStats {
+	stats := &models.LogStats{
+		TotalLogs:        b.total,
+		LogsPerLevel:     make(map[string]int, len(b.levels)),
+		LogsPerPod:       make(map[string]int, len(b.pods)),
+		StartTime:        start,
+		EndTime:          end,
+		BucketSeconds:    int64(b.bucketSize / time.Second),
+		Histogram:        []models.LogHistogram{},
+		TopPods:          []models.PodLogCount{},
+		TopErrorPatterns: []models.ErrorPattern{},
+	}
+
+	errorCount := 0
+	for level, count := range b.levels {
+		stats.LogsPerLevel[level] = count
+		if isErrorLevel(level) {
+			errorCount += count
+		}
+	}
+	if b.total > 0 {
+		stats.ErrorRate = float64(errorCount) / float64(b.total) * 100
+	}
+
+	// Histogram, filling gaps with empty buckets
+	if !start.IsZero() && !end.IsZero() && end.After(start) && BucketCount(start, end, b.bucketSize) <= MaxBuckets {
+		for t := start.Truncate(b.bucketSize); !t.After(end); t = t.Add(b.bucketSize) {
+			if bucket, ok := b.buckets[t.Unix()]; ok {
+				stats.Histogram = append(stats.Histogram, copyHistogram(bucket.histogram))
+			} else {
+				stats.Histogram = append(stats.Histogram, models.LogHistogram{
+					Timestamp: t,
+					Levels:    map[string]int{},
+				})
+			}
+		}
+	} else {
+		for _, bucket := range b.buckets {
+			stats.Histogram = append(stats.Histogram, copyHistogram(bucket.histogram))
+		}
+		sort.Slice(stats.Histogram, func(i, j int) bool {
+			return stats.Histogram[i].Timestamp.Before(stats.Histogram[j].Timestamp)
+		})
+	}
+
+	// Top emitting pods
+	for key, pod := range b.pods {
+		stats.LogsPerPod[key] = pod.Count
+		stats.TopPods = append(stats.TopPods, *pod)
+	}
+	sort.Slice(stats.TopPods, func(i, j int) bool {
+		if stats.TopPods[i].Count != stats.TopPods[j].Count {
+			return stats.TopPods[i].Count > stats.TopPods[j].Count
+		}
+		return stats.TopPods[i].Pod < stats.TopPods[j].Pod
+	})
+	if len(stats.TopPods) > maxTopPods {
+		stats.TopPods = stats.TopPods[:maxTopPods]
+	}
+
+	// Top error patterns, counted over the kept buckets
+	patterns := []search.LogPattern{}
+	for _, pattern := range b.errors.Patterns() {
+		if count := b.errorCounts[pattern.ID]; count > 0 {
+			pattern.Count = count
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].LastSeen.After(patterns[j].LastSeen)
+	})
+	if len(patterns) > maxTopErrorPatterns {
+		patterns = patterns[:maxTopErrorPatterns]
+	}
+	for _, pattern := range patterns {
+		stats.TopErrorPatterns = append(stats.TopErrorPatterns, models.ErrorPattern{
+			Template:  pattern.Template,
+			Count:     pattern.Count,
+			Sample:    pattern.Sample,
+			FirstSeen: pattern.FirstSeen,
+			LastSeen:  pattern.LastSeen,
+		})
+	}
+
+	return stats
+}
+
+// ComputeLogStats builds statistics for a set of log entries over a time range.
+// A zero range is derived from the entries themselves.
+func ComputeLogStats(entries []models.LogEntry, start, end time.Time, bucketSize time.Duration) *models.LogStats {
+	if start.IsZero() || end.IsZero() {
+		for _, entry := range entries {
+			if start.IsZero() || entry.Timestamp.Before(start) {
+				start = entry.Timestamp
+			}
+			if end.IsZero() || entry.Timestamp.After(end) {
+				end = entry.Timestamp
+			}
+		}
+	}
+	if bucketSize <= 0 {
+		bucketSize = ChooseBucketSize(start, end)
+	}
+
+	builder := NewLogStatsBuilder(bucketSize)
+	for _, entry := range entries {
+		builder.Add(entry)
+	}
+	return builder.Build(start, end)
+}
+
+// copyHistogram copies a histogram bucket so callers cannot mutate builder state
+func copyHistogram(bucket *models.LogHistogram) models.LogHistogram {
+	levels := make(map[string]int, len(bucket.Levels))
+	for level, count := range bucket.Levels {
+		levels[level] = count
+	}
+	return models.LogHistogram{
+		Timestamp: bucket.Timestamp,
+		Count:     bucket.Count,
+		Levels:    levels,
+	}
+}
+
+// isErrorLevel checks if a level counts towards the error rate
+func isErrorLevel(level string) bool {
+	return level == "ERROR" || level == "FATAL"
+}
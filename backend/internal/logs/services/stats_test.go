@@ -0,0 +1,113 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prasad/kaptivan/backend/internal/logs/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChooseBucketSize(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		span time.Duration
+		want time.Duration
+	}{
+		{0, time.Second},
+		{-time.Hour, time.Second},
+		{time.Minute, time.Second},
+		{5 * time.Minute, 5 * time.Second},
+		{time.Hour, time.Minute},
+		{6 * time.Hour, 10 * time.Minute},
+		{24 * time.Hour, 30 * time.Minute},
+		{7 * 24 * time.Hour, 3 * time.Hour},
+		{365 * 24 * time.Hour, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.span.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, ChooseBucketSize(start, start.Add(tt.span)))
+		})
+	}
+}
+
+func TestLogStatsBuilderFillsEmptyBuckets(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	builder := NewLogStatsBuilder(time.Minute)
+	builder.Add(models.LogEntry{Timestamp: start.Add(10 * time.Second), Level: "info", Cluster: "prod", Namespace: "shop", Pod: "web-1", Message: "started"})
+	builder.Add(models.LogEntry{Timestamp: start.Add(3*time.Minute + 5*time.Second), Level: "ERROR", Cluster: "prod", Namespace: "shop", Pod: "web-1", Message: "connection refused to 10.0.0.1"})
+	builder.Add(models.LogEntry{Timestamp: start.Add(3*time.Minute + 50*time.Second), Cluster: "prod", Namespace: "shop", Pod: "api-1", Message: "ok"})
+
+	stats := builder.Build(start, start.Add(4*time.Minute))
+	require.Len(t, stats.Histogram, 5)
+	counts := make([]int, 0, len(stats.Histogram))
+	for i, bucket := range stats.Histogram {
+		assert.Equal(t, start.Add(time.Duration(i)*time.Minute), bucket.Timestamp)
+		assert.NotNil(t, bucket.Levels)
+		counts = append(counts, bucket.Count)
+	}
+	assert.Equal(t, []int{1, 0, 0, 2, 0}, counts)
+	assert.Equal(t, map[string]int{"ERROR": 1, "INFO": 1}, stats.Histogram[3].Levels)
+
+	assert.Equal(t, 3, stats.TotalLogs)
+	assert.Equal(t, int64(60), stats.BucketSeconds)
+	assert.InDelta(t, 100.0/3, stats.ErrorRate, 0.001)
+	require.Len(t, stats.TopPods, 2)
+	assert.Equal(t, models.PodLogCount{Cluster: "prod", Namespace: "shop", Pod: "web-1", Count: 2, Errors: 1}, stats.TopPods[0])
+	require.Len(t, stats.TopErrorPatterns, 1)
+	assert.Equal(t, 1, stats.TopErrorPatterns[0].Count)
+
+	// Without a range only the buckets holding logs are returned
+	stats = builder.Build(time.Time{}, time.Time{})
+	require.Len(t, stats.Histogram, 2)
+	assert.Equal(t, start, stats.Histogram[0].Timestamp)
+	assert.Equal(t, start.Add(3*time.Minute), stats.Histogram[1].Timestamp)
+}
+
+func TestLogStatsBuilderCapsBuckets(t *testing.T) {
+	end := time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-30 * 24 * time.Hour)
+	builder := NewLogStatsBuilder(time.Second)
+	builder.Add(models.LogEntry{Timestamp: end.Add(-time.Minute), Pod: "web-1", Message: "ok"})
+
+	assert.Equal(t, int64(30*24*60*60+1), BucketCount(start, end, time.Second))
+	stats := builder.Build(start, end)
+	require.Len(t, stats.Histogram, 1, "a range past MaxBuckets only returns non-empty buckets")
+	assert.Equal(t, 1, stats.Histogram[0].Count)
+}
+
+func TestBucketCount(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)
+	assert.Equal(t, int64(0), BucketCount(start, start, time.Minute))
+	assert.Equal(t, int64(0), BucketCount(start, start.Add(time.Hour), 0))
+	assert.Equal(t, int64(1), BucketCount(start, start.Add(20*time.Second), time.Minute))
+	assert.Equal(t, int64(61), BucketCount(start, start.Add(time.Hour), time.Minute))
+}
+
+func TestLogStatsBuilderPruneDropsExpiredCounts(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	builder := NewLogStatsBuilder(time.Minute)
+	builder.Add(models.LogEntry{Timestamp: start, Level: "ERROR", Cluster: "prod", Namespace: "shop", Pod: "web-1", Message: "connection refused to 10.0.0.1"})
+	builder.Add(models.LogEntry{Timestamp: start.Add(10 * time.Second), Level: "ERROR", Cluster: "prod", Namespace: "shop", Pod: "web-1", Message: "disk full on /data"})
+	builder.Add(models.LogEntry{Timestamp: start.Add(20 * time.Minute), Level: "ERROR", Cluster: "prod", Namespace: "shop", Pod: "api-1", Message: "connection refused to 10.0.0.2"})
+	builder.Add(models.LogEntry{Timestamp: start.Add(21 * time.Minute), Cluster: "prod", Namespace: "shop", Pod: "api-1", Message: "ok"})
+
+	windowStart := start.Add(7 * time.Minute)
+	builder.Prune(windowStart)
+	stats := builder.Build(windowStart, start.Add(22*time.Minute))
+
+	histogramTotal := 0
+	for _, bucket := range stats.Histogram {
+		histogramTotal += bucket.Count
+	}
+	assert.Equal(t, 2, stats.TotalLogs)
+	assert.Equal(t, stats.TotalLogs, histogramTotal)
+	assert.Equal(t, map[string]int{"ERROR": 1, "INFO": 1}, stats.LogsPerLevel)
+	assert.InDelta(t, 50.0, stats.ErrorRate, 0.001)
+	assert.Equal(t, []models.PodLogCount{{Cluster: "prod", Namespace: "shop", Pod: "api-1", Count: 2, Errors: 1}}, stats.TopPods)
+	assert.Equal(t, map[string]int{"prod/shop/api-1": 2}, stats.LogsPerPod)
+	require.Len(t, stats.TopErrorPatterns, 1)
+	assert.Equal(t, 1, stats.TopErrorPatterns[0].Count)
+	assert.Contains(t, stats.TopErrorPatterns[0].Template, "connection refused")
+}
@@ -29,7 +29,9 @@ interface RelatedResource {
   namespace?: string
   kind: string
   apiVersion: string
-  relationship: 'owner' | 'child'
+  relationship: 'owner' | 'child' | 'references' | 'referenced-by'
+  via?: string
+  depth?: number
   uid?: string
 }
 
@@ -115,7 +117,8 @@ export function RelatedResourcesPopover({
 
   const owners = relatedResources.filter(r => r.relationship === 'owner')
   const children = relatedResources.filter(r => r.relationship === 'child')
-  const hasRelated = owners.length > 0 || children.length > 0
+  const references = relatedResources.filter(r => r.relationship === 'references' || r.relationship === 'referenced-by')
+  const hasRelated = owners.length > 0 || children.length > 0 || references.length > 0
 
   const handleResourceClick = (resource: RelatedResource) => {
     onNavigate(resource)
@@ -261,6 +264,58 @@ export function RelatedResourcesPopover({
                     </div>
                   </div>
                 )}
+
+                {/* Separator before references */}
+                {(owners.length > 0 || children.length > 0) && references.length > 0 && (
+                  <Separator />
+                )}
+
+                {/* References */}
+                {references.length > 0 && (
+                  <div>
+                    <div className="flex items-center gap-2 mb-2">
+                      <Link2 className="h-3.5 w-3.5 text-muted-foreground" />
+                      <span className="text-xs font-medium text-muted-foreground">
+                        REFERENCES
+                      </span>
+                    </div>
+                    <div className="space-y-1">
+                      {references.map((resource, index) => {
+                        const Icon = getResourceIcon(resource.kind)
+                        return (
+                          <Button
+                            key={`${resource.uid || index}`}
+                            variant="ghost"
+                            size="sm"
+                            className="w-full justify-start text-left h-8 px-2"
+                            onClick={() => handleResourceClick(resource)}
+                          >
+                            <Icon className="h-3.5 w-3.5 mr-2 flex-shrink-0" />
+                            <div className="flex-1 min-w-0">
+                              <div className="flex items-center gap-1.5">
+                                <span className="font-medium text-xs">{resource.kind}</span>
+                                {resource.via && (
+                                  <span className="text-[10px] text-muted-foreground">
+                                    {resource.relationship === 'referenced-by' ? `${resource.via} this` : resource.via}
+                                  </span>
+                                )}
+                                {resource.namespace && (
+                                  <Badge variant="outline" className="h-4 px-1 text-[10px]">
+                                    {resource.namespace}
+                                  </Badge>
+                                )}
+                              </div>
+                              <div className="text-[11px] text-muted-foreground truncate">
+                                {resource.name}
+                              </div>
+                            </div>
+                            <ChevronRight className="h-3.5 w-3.5 ml-2 flex-shrink-0 text-muted-foreground" />
+                          </Button>
+                        )
+                      })}
+                    </div>
+                  </div>
+                )}
               </div>
             </ScrollArea>
           )}
@@ -13,7 +13,8 @@ import {
   Database,
   GitBranch,
   Network,
-  Loader2
+  Loader2,
+  Link2
 } from 'lucide-react'
 import { cn } from '@/utils/cn'
 
@@ -22,7 +23,9 @@ interface RelatedResource {
   namespace?: string
   kind: string
   apiVersion: string
-  relationship: 'owner' | 'child'
+  relationship: 'owner' | 'child' | 'references' | 'referenced-by'
+  via?: string
+  depth?: number
   uid?: string
 }
 
@@ -96,6 +99,7 @@ export function RelatedResources({
 
   const owners = relatedResources.filter(r => r.relationship === 'owner')
   const children = relatedResources.filter(r => r.relationship === 'child')
+  const references = relatedResources.filter(r => r.relationship === 'references' || r.relationship === 'referenced-by')
 
   if (loading) {
     return (
@@ -214,6 +218,59 @@ export function RelatedResources({
             </div>
           </div>
         )}
+
+        {/* Separator before references */}
+        {(owners.length > 0 || children.length > 0) && references.length > 0 && (
+          <Separator />
+        )}
+
+        {/* References */}
+        {references.length > 0 && (
+          <div>
+            <div className="flex items-center gap-2 mb-3">
+              <Link2 className="h-4 w-4 text-muted-foreground" />
+              <h3 className="text-sm font-medium">References</h3>
+              <Badge variant="secondary" className="h-5 px-1.5 text-xs">
+                {references.length}
+              </Badge>
+            </div>
+            <div className="space-y-2">
+              {references.map((resource, index) => {
+                const Icon = getResourceIcon(resource.kind)
+                return (
+                  <Button
+                    key={`${resource.uid || index}`}
+                    variant="outline"
+                    size="sm"
+                    className="w-full justify-start text-left"
+                    onClick={() => onNavigate(resource)}
+                  >
+                    <Icon className="h-4 w-4 mr-2 flex-shrink-0" />
+                    <div className="flex-1 min-w-0">
+                      <div className="flex items-center gap-2">
+                        <span className="font-medium text-xs">{resource.kind}</span>
+                        {resource.via && (
+                          <span className="text-[10px] text-muted-foreground">
+                            {resource.relationship === 'referenced-by' ? `${resource.via} this` : resource.via}
+                          </span>
+                        )}
+                        {resource.namespace && (
+                          <Badge variant="outline" className="h-4 px-1 text-[10px]">
+                            {resource.namespace}
+                          </Badge>
+                        )}
+                      </div>
+                      <div className="text-xs text-muted-foreground truncate">
+                        {resource.name}
+                      </div>
+                    </div>
+                    <ChevronRight className="h-4 w-4 ml-2 flex-shrink-0" />
+                  </Button>
+                )
+              })}
+            </div>
+          </div>
+        )}
       </div>
     </ScrollArea>
   )
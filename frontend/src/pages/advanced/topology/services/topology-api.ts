@@ -9,6 +9,75 @@ export interface DeploymentSummary {
   ready: number;
 }
 
+export type TopologyExportFormat = 'dot' | 'mermaid' | 'graphml' | 'json';
+
+export type TopologyExportKind = 'deployment' | 'daemonset' | 'statefulset' | 'job' | 'cronjob';
+
+export interface SnapshotTarget {
+  context: string;
+  namespace: string;
+  kind: TopologyExportKind;
+  name: string;
+}
+
+export interface WatchedWorkload extends SnapshotTarget {
+  interval: string;
+  recording: boolean;
+  since: string;
+  snapshots: number;
+  changes: number;
+  lastSnapshot?: string;
+  lastError?: string;
+}
+
+export interface RecordedChange {
+  recordedAt: string;
+  type: string;
+  resourceType: string;
+  resourceId: string;
+  namespace: string;
+  data?: unknown;
+  timestamp: string;
+}
+
+export interface TopologySnapshot {
+  timestamp: string;
+  target: SnapshotTarget;
+  topology: unknown; // kind-specific topology, e.g. DeploymentTopology
+  graph: unknown;
+}
+
+export interface TopologyAsOf {
+  time: string;
+  snapshot: TopologySnapshot;
+  changes: RecordedChange[];
+}
+
+export interface RefDiff {
+  added: string[];
+  removed: string[];
+  changed?: string[];
+}
+
+export interface ServiceRepoint {
+  name: string;
+  selectorBefore?: string;
+  selectorAfter?: string;
+  targets: RefDiff;
+}
+
+export interface TopologyDiff {
+  target: SnapshotTarget;
+  from: string;
+  to: string;
+  replicaSets: RefDiff;
+  pods: RefDiff;
+  configMaps: RefDiff;
+  secrets: RefDiff;
+  services: ServiceRepoint[];
+  changes: RecordedChange[];
+}
+
 export interface TopologyAPIResponse<T> {
   data?: T;
   error?: string;
@@ -116,6 +185,108 @@ class TopologyAPI {
       return null;
     }
   }
+
+  async exportTopology(
+    clusterContext: string,
+    namespace: string,
+    kind: TopologyExportKind,
+    name: string,
+    format: TopologyExportFormat
+  ): Promise<Blob> {
+    const params = new URLSearchParams({
+      context: clusterContext,
+      namespace,
+      kind,
+      name,
+      format
+    });
+    const response = await this.fetchWithAuth(
+      `${this.baseUrl}/api/v1/topology/export?${params.toString()}`
+    );
+
+    if (!response.ok) {
+      throw new Error(`Failed to export topology: ${response.statusText}`);
+    }
+
+    return response.blob();
+  }
+
+  async watchSnapshots(target: SnapshotTarget, interval?: string): Promise<WatchedWorkload> {
+    const response = await this.fetchWithAuth(`${this.baseUrl}/api/v1/topology/snapshots/watch`, {
+      method: 'POST',
+      body: JSON.stringify({ ...target, interval })
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to watch topology: ${response.statusText}`);
+    }
+
+    return response.json();
+  }
+
+  async unwatchSnapshots(target: SnapshotTarget): Promise<void> {
+    const params = new URLSearchParams({ ...target });
+    const response = await this.fetchWithAuth(
+      `${this.baseUrl}/api/v1/topology/snapshots/watch?${params.toString()}`,
+      { method: 'DELETE' }
+    );
+
+    if (!response.ok) {
+      throw new Error(`Failed to stop recording topology: ${response.statusText}`);
+    }
+  }
+
+  async listSnapshotWatches(): Promise<WatchedWorkload[]> {
+    const response = await this.fetchWithAuth(`${this.baseUrl}/api/v1/topology/snapshots/watches`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch recorded workloads: ${response.statusText}`);
+    }
+
+    const data = await response.json();
+    return data.watches || [];
+  }
+
+  async listSnapshots(target: SnapshotTarget): Promise<string[]> {
+    const params = new URLSearchParams({ ...target });
+    const response = await this.fetchWithAuth(
+      `${this.baseUrl}/api/v1/topology/snapshots?${params.toString()}`
+    );
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch snapshots: ${response.statusText}`);
+    }
+
+    const data = await response.json();
+    return data.snapshots || [];
+  }
+
+  async getTopologyAsOf(target: SnapshotTarget, time: string): Promise<TopologyAsOf> {
+    const params = new URLSearchParams({ ...target, time });
+    const response = await this.fetchWithAuth(
+      `${this.baseUrl}/api/v1/topology/snapshots/at?${params.toString()}`
+    );
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch topology snapshot: ${response.statusText}`);
+    }
+
+    return response.json();
+  }
+
+  async diffTopology(target: SnapshotTarget, from: string, to?: string): Promise<TopologyDiff> {
+    const params = new URLSearchParams({ ...target, from });
+    if (to) params.append('to', to);
+    const response = await this.fetchWithAuth(
+      `${this.baseUrl}/api/v1/topology/snapshots/diff?${params.toString()}`
+    );
+
+    if (!response.ok) {
+      throw new Error(`Failed to diff topology: ${response.statusText}`);
+    }
+
+    return response.json();
+  }
 }
 
 // Export a singleton instance
@@ -9,8 +9,24 @@ export interface ResourceChange {
   timestamp: string;
 }
 
+export type TopologyResourceType = 'deployment' | 'daemonset' | 'job' | 'cronjob' | 'statefulset';
+
+export interface SubscriptionMessage {
+  type: 'subscribe' | 'unsubscribe' | 'refresh';
+  namespace?: string;
+  deployment?: string;
+  daemonset?: string;
+  job?: string;
+  cronjob?: string;
+  statefulset?: string;
+}
+
 export interface TopologyUpdate {
+  // 'snapshot' replaces all state for the subscription; absent for incremental changes
+  type?: 'snapshot' | 'unsubscribed' | 'error';
   changes: ResourceChange[];
+  subscription?: SubscriptionMessage;
+  error?: string;
   timestamp: string;
 }
 
@@ -29,7 +45,7 @@ export class TopologyWebSocketClient {
     private context: string,
     private namespace: string,
     private resourceName?: string,
-    private resourceType: 'deployment' | 'daemonset' | 'job' = 'deployment'
+    private resourceType: TopologyResourceType = 'deployment'
   ) {
     // Convert HTTP URL to WebSocket URL
     const wsUrl = baseUrl.replace('http', 'ws');
@@ -62,16 +78,7 @@ export class TopologyWebSocketClient {
       this.reconnectAttempts = 0;
       
       // Send initial subscription message
-      const subscriptionMsg: any = {
-        type: 'subscribe',
-        namespace: this.namespace
-      };
-      
-      if (this.resourceName) {
-        subscriptionMsg[this.resourceType] = this.resourceName;
-      }
-      
-      this.ws?.send(JSON.stringify(subscriptionMsg));
+      this.send(this.subscriptionMessage());
     };
 
     this.ws.onmessage = (event) => {
@@ -150,19 +157,40 @@ export class TopologyWebSocketClient {
     return this.isConnected;
   }
 
-  // Send a manual refresh request
+  // Request a full snapshot of the current subscription
   refresh(): void {
+    this.send({ type: 'refresh' });
+  }
+
+  // Switch the connection to another namespace or workload without reconnecting
+  subscribe(namespace: string, resourceName?: string, resourceType: TopologyResourceType = 'deployment'): void {
+    this.namespace = namespace;
+    this.resourceName = resourceName;
+    this.resourceType = resourceType;
+    this.send(this.subscriptionMessage());
+  }
+
+  // Stop receiving updates while keeping the connection open
+  unsubscribe(): void {
+    this.send({ type: 'unsubscribe' });
+  }
+
+  private subscriptionMessage(): SubscriptionMessage {
+    const msg: SubscriptionMessage = {
+      type: 'subscribe',
+      namespace: this.namespace
+    };
+
+    if (this.resourceName) {
+      msg[this.resourceType] = this.resourceName;
+    }
+
+    return msg;
+  }
+
+  private send(msg: SubscriptionMessage): void {
     if (this.ws?.readyState === WebSocket.OPEN) {
-      const refreshMsg: any = {
-        type: 'refresh',
-        namespace: this.namespace
-      };
-      
-      if (this.resourceName) {
-        refreshMsg[this.resourceType] = this.resourceName;
-      }
-      
-      this.ws.send(JSON.stringify(refreshMsg));
+      this.ws.send(JSON.stringify(msg));
     }
   }
-}
\ No newline at end of file
+}
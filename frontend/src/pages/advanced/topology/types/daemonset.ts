@@ -33,6 +33,9 @@ export interface DaemonSetTopology {
   roleBindings?: RoleBindingRef[];
   clusterRoles?: RoleRef[];
   clusterRoleBindings?: RoleBindingRef[];
+  routes?: RouteRef[];
+  scaling?: ScalingRef;
+  warnings?: string[];
 }
 
 export interface DaemonSetSummary {
@@ -51,5 +54,7 @@ import type {
   ConfigMapRef,
   ServiceAccountRef,
   RoleRef,
-  RoleBindingRef
+  RoleBindingRef,
+  RouteRef,
+  ScalingRef
 } from './index';
\ No newline at end of file
@@ -156,6 +156,116 @@ export interface DeploymentTopology {
   roleBindings?: RoleBindingRef[];
   clusterRoles?: RoleRef[];
   clusterRoleBindings?: RoleBindingRef[];
+  routes?: RouteRef[];
+  scaling?: ScalingRef;
+  warnings?: string[];
+}
+
+// Autoscalers and disruption budgets applying to a workload
+export interface ScalingRef {
+  autoscalers?: HPARef[];
+  disruptionBudgets?: PDBRef[];
+  verticalAutoscalers?: VPARef[];
+  status: 'Healthy' | 'Warning' | 'Error' | 'Unknown';
+}
+
+export interface HPARef {
+  name: string;
+  minReplicas: number;
+  maxReplicas: number;
+  currentReplicas: number;
+  desiredReplicas: number;
+  metrics?: Array<{
+    type: string;
+    name: string;
+    target: string;
+    current?: string;
+  }>;
+  conditions?: Array<{
+    type: string;
+    status: string;
+    reason?: string;
+    message?: string;
+  }>;
+  lastScaleTime?: string;
+  status: 'Healthy' | 'Warning' | 'Error' | 'Unknown';
+}
+
+export interface PDBRef {
+  name: string;
+  minAvailable?: string;
+  maxUnavailable?: string;
+  currentHealthy: number;
+  desiredHealthy: number;
+  expectedPods: number;
+  disruptionsAllowed: number;
+  status: 'Healthy' | 'Warning' | 'Error' | 'Unknown';
+}
+
+export interface VPARef {
+  name: string;
+  updateMode?: string;
+  recommendations?: Array<{
+    container: string;
+    target?: Record<string, string>;
+    lowerBound?: Record<string, string>;
+    upperBound?: Record<string, string>;
+    uncappedTarget?: Record<string, string>;
+  }>;
+  status: 'Healthy' | 'Warning' | 'Error' | 'Unknown';
+}
+
+// Ingress or Gateway API route reaching a workload's services
+export interface RouteRef {
+  kind: "Ingress" | "HTTPRoute" | "GRPCRoute";
+  name: string;
+  namespace: string;
+  class?: string;
+  gateways?: GatewayRef[];
+  rules: RouteRule[];
+  tls?: RouteTLS[];
+  status: K8sStatus;
+  warnings?: string[];
+}
+
+export interface RouteRule {
+  hosts?: string[];
+  path?: string;
+  pathType?: string;
+  backend: {
+    service: string;
+    namespace: string;
+    port?: string;
+    targetPort?: string;
+    weight?: number;
+    workload: boolean;
+    status: K8sStatus;
+    error?: string;
+  };
+}
+
+export interface RouteTLS {
+  hosts?: string[];
+  secretName?: string;
+  namespace?: string;
+  missing?: boolean;
+}
+
+export interface GatewayRef {
+  name: string;
+  namespace: string;
+  sectionName?: string;
+  class?: string;
+  listeners?: {
+    name: string;
+    protocol: string;
+    port: number;
+    hostname?: string;
+  }[];
+  addresses?: string[];
+  status: K8sStatus;
+  missing?: boolean;
+  error?: string;
 }
 
 export type NodeType = 